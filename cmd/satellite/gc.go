@@ -36,7 +36,16 @@ func cmdGCRun(cmd *cobra.Command, args []string) (err error) {
 		err = errs.Combine(err, db.Close())
 	}()
 
-	metabaseDB, err := metabase.Open(ctx, log.Named("metabase"), runCfg.Metainfo.DatabaseURL, metabase.Config{
+	// garbage collection only iterates the metabase, which can be a snapshot
+	// to keep the load off the metabase.
+	metabaseURL := runCfg.Metainfo.DatabaseURL
+	if runCfg.GarbageCollection.SnapshotDatabaseURL != "" {
+		log.Info("Generating garbage collection filters from a metabase snapshot.",
+			zap.String("Snapshot Created", runCfg.GarbageCollection.SnapshotCreated))
+		metabaseURL = runCfg.GarbageCollection.SnapshotDatabaseURL
+	}
+
+	metabaseDB, err := metabase.Open(ctx, log.Named("metabase"), metabaseURL, metabase.Config{
 		MinPartSize:      runCfg.Config.Metainfo.MinPartSize,
 		MaxNumberOfParts: runCfg.Config.Metainfo.MaxNumberOfParts,
 	})
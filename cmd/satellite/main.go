@@ -95,6 +95,51 @@ var (
 		Short: "Run the satellite database migration",
 		RunE:  cmdMigrationRun,
 	}
+	migrationCmd = &cobra.Command{
+		Use:   "migration",
+		Short: "Satellite database migration commands",
+	}
+	migrationPlanCmd = &cobra.Command{
+		Use:   "plan",
+		Short: "Print the SQL of the pending database migration steps without executing them",
+		RunE:  cmdMigrationPlan,
+	}
+	migrationRunCmd = &cobra.Command{
+		Use:   "run",
+		Short: "Migrate the satellite databases, up to --target-version when it's set; fails when another process is migrating",
+		RunE:  cmdMigrationRunTarget,
+	}
+	migrationStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Print whether the satellite databases are at the latest version, exits with 1 when they are behind and 2 on errors",
+		RunE:  cmdMigrationStatus,
+	}
+	migrationVerifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Compare the schema of the satellite databases with the expected schema, exits with 1 when they differ and 2 on errors",
+		RunE:  cmdMigrationVerify,
+	}
+	migrationBackfillCmd = &cobra.Command{
+		Use:   "backfill",
+		Short: "Long running data backfills of the satellite database",
+	}
+	migrationBackfillListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the backfill jobs with their status and cursor",
+		RunE:  cmdMigrationBackfillList,
+	}
+	migrationBackfillPauseCmd = &cobra.Command{
+		Use:   "pause <name>",
+		Short: "Pause a pending backfill job after its current batch",
+		Args:  cobra.ExactArgs(1),
+		RunE:  cmdMigrationBackfillPause,
+	}
+	migrationBackfillResumeCmd = &cobra.Command{
+		Use:   "resume <name>",
+		Short: "Resume a paused backfill job from its cursor",
+		Args:  cobra.ExactArgs(1),
+		RunE:  cmdMigrationBackfillResume,
+	}
 	runAPICmd = &cobra.Command{
 		Use:   "api",
 		Short: "Run the satellite API",
@@ -293,6 +338,17 @@ var (
 
 	confDir     string
 	identityDir string
+
+	migrationNoWait        bool
+	migrationStatusOutput  string
+	migrationPlanOutput    string
+	migrationTargetVersion int
+
+	migrationIgnoreChecksumMismatch bool
+	migrationVerifyScratchDatabase  string
+	migrationStatementTimeout       time.Duration
+	migrationLockTimeout            time.Duration
+	migrationConfirmedVersions      []int
 )
 
 func init() {
@@ -307,6 +363,15 @@ func init() {
 	runCmd.AddCommand(runAdminCmd)
 	runCmd.AddCommand(runRepairerCmd)
 	runCmd.AddCommand(runGCCmd)
+	rootCmd.AddCommand(migrationCmd)
+	migrationCmd.AddCommand(migrationPlanCmd)
+	migrationCmd.AddCommand(migrationRunCmd)
+	migrationCmd.AddCommand(migrationStatusCmd)
+	migrationCmd.AddCommand(migrationVerifyCmd)
+	migrationCmd.AddCommand(migrationBackfillCmd)
+	migrationBackfillCmd.AddCommand(migrationBackfillListCmd)
+	migrationBackfillCmd.AddCommand(migrationBackfillPauseCmd)
+	migrationBackfillCmd.AddCommand(migrationBackfillResumeCmd)
 	rootCmd.AddCommand(setupCmd)
 	rootCmd.AddCommand(qdiagCmd)
 	rootCmd.AddCommand(reportsCmd)
@@ -330,7 +395,27 @@ func init() {
 	billingCmd.AddCommand(stripeCustomerCmd)
 	consistencyCmd.AddCommand(consistencyGECleanupCmd)
 	process.Bind(runCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
+	runMigrationCmd.Flags().BoolVar(&migrationIgnoreChecksumMismatch, "ignore-checksum-mismatch", false, "only log applied migration steps that were changed since, for emergencies")
+	runMigrationCmd.Flags().BoolVar(&migrationNoWait, "no-wait", false, "fail instead of waiting when another process is migrating the database")
+	runMigrationCmd.Flags().DurationVar(&migrationStatementTimeout, "statement-timeout", 0, "fail a migration statement of the satellite database that runs longer than this, 0 means no limit")
+	runMigrationCmd.Flags().IntSliceVar(&migrationConfirmedVersions, "confirm-version", nil, "confirm a satellite database migration step that requires confirmation, can be repeated")
+	runMigrationCmd.Flags().DurationVar(&migrationLockTimeout, "lock-timeout", 0, "fail a migration statement of the satellite database that waits longer than this on a lock, 0 means no limit")
 	process.Bind(runMigrationCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
+	migrationRunCmd.Flags().BoolVar(&migrationIgnoreChecksumMismatch, "ignore-checksum-mismatch", false, "only log applied migration steps that were changed since, for emergencies")
+	migrationRunCmd.Flags().DurationVar(&migrationStatementTimeout, "statement-timeout", 0, "fail a migration statement of the satellite database that runs longer than this, 0 means no limit")
+	migrationRunCmd.Flags().IntSliceVar(&migrationConfirmedVersions, "confirm-version", nil, "confirm a satellite database migration step that requires confirmation, can be repeated")
+	migrationRunCmd.Flags().DurationVar(&migrationLockTimeout, "lock-timeout", 0, "fail a migration statement of the satellite database that waits longer than this on a lock, 0 means no limit")
+	migrationRunCmd.Flags().IntVar(&migrationTargetVersion, "target-version", -1, "migrate the satellite database up to this version and stop, without migrating the metabase; -1 migrates everything to the latest version")
+	process.Bind(migrationRunCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
+	migrationPlanCmd.Flags().StringVar(&migrationPlanOutput, "output", "text", "output format, text or json")
+	process.Bind(migrationPlanCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
+	migrationStatusCmd.Flags().StringVar(&migrationStatusOutput, "output", "text", "output format, text or json")
+	process.Bind(migrationStatusCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
+	migrationVerifyCmd.Flags().StringVar(&migrationVerifyScratchDatabase, "scratch-database", "", "database to create the expected schema in a temporary schema of, the satellite database by default")
+	process.Bind(migrationVerifyCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
+	process.Bind(migrationBackfillListCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
+	process.Bind(migrationBackfillPauseCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
+	process.Bind(migrationBackfillResumeCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
 	process.Bind(runAPICmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
 	process.Bind(runAdminCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
 	process.Bind(runRepairerCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
@@ -457,9 +542,29 @@ func cmdRun(cmd *cobra.Command, args []string) (err error) {
 
 func cmdMigrationRun(cmd *cobra.Command, args []string) (err error) {
 	ctx, _ := process.Ctx(cmd)
-	log := zap.L()
+	return runMigration(ctx, zap.L(), -1, migrationNoWait)
+}
 
-	db, err := satellitedb.Open(ctx, log.Named("migration"), runCfg.Database, satellitedb.Options{ApplicationName: "satellite-migration"})
+func cmdMigrationRunTarget(cmd *cobra.Command, args []string) (err error) {
+	ctx, _ := process.Ctx(cmd)
+	// a migration to a target version is started by an operator, it shouldn't
+	// queue behind a migration that is running already.
+	return runMigration(ctx, zap.L(), migrationTargetVersion, true)
+}
+
+// runMigration migrates the satellite databases and the metabase to the latest
+// version. When the target version is not negative, only the satellite
+// databases are migrated, up to the target version.
+func runMigration(ctx context.Context, log *zap.Logger, targetVersion int, noWait bool) (err error) {
+	db, err := satellitedb.Open(ctx, log.Named("migration"), runCfg.Database, satellitedb.Options{
+		ApplicationName: "satellite-migration",
+		MigrationNoWait: noWait,
+
+		MigrationIgnoreChecksumMismatch: migrationIgnoreChecksumMismatch,
+		MigrationStatementTimeout:       migrationStatementTimeout,
+		MigrationLockTimeout:            migrationLockTimeout,
+		MigrationConfirmedVersions:      migrationConfirmedVersions,
+	})
 	if err != nil {
 		return errs.New("Error creating new master database connection for satellitedb migration: %+v", err)
 	}
@@ -467,6 +572,14 @@ func cmdMigrationRun(cmd *cobra.Command, args []string) (err error) {
 		err = errs.Combine(err, db.Close())
 	}()
 
+	if targetVersion >= 0 {
+		err = db.MigrateToVersion(ctx, targetVersion)
+		if err != nil {
+			return errs.New("Error migrating master database on satellite to version %d: %+v", targetVersion, err)
+		}
+		return nil
+	}
+
 	err = db.MigrateToLatest(ctx)
 	if err != nil {
 		return errs.New("Error creating tables for master database on satellite: %+v", err)
@@ -475,6 +588,9 @@ func cmdMigrationRun(cmd *cobra.Command, args []string) (err error) {
 	metabaseDB, err := metabase.Open(ctx, log.Named("metabase"), runCfg.Metainfo.DatabaseURL, metabase.Config{
 		MinPartSize:      runCfg.Config.Metainfo.MinPartSize,
 		MaxNumberOfParts: runCfg.Config.Metainfo.MaxNumberOfParts,
+		MigrationNoWait:  noWait,
+
+		MigrationIgnoreChecksumMismatch: migrationIgnoreChecksumMismatch,
 	})
 	if err != nil {
 		return errs.New("Error creating metabase connection: %+v", err)
@@ -490,6 +606,54 @@ func cmdMigrationRun(cmd *cobra.Command, args []string) (err error) {
 	return nil
 }
 
+func cmdMigrationPlan(cmd *cobra.Command, args []string) (err error) {
+	ctx, _ := process.Ctx(cmd)
+	log := zap.L()
+
+	switch migrationPlanOutput {
+	case "text":
+	case "json":
+		return printMigrationPlanJSON(ctx, log, os.Stdout)
+	default:
+		return errs.New("unknown output format %q", migrationPlanOutput)
+	}
+
+	db, err := satellitedb.Open(ctx, log.Named("migration"), runCfg.Database, satellitedb.Options{ApplicationName: "satellite-migration"})
+	if err != nil {
+		return errs.New("Error creating new master database connection for satellitedb migration: %+v", err)
+	}
+	defer func() {
+		err = errs.Combine(err, db.Close())
+	}()
+
+	err = db.PlanMigration(ctx, os.Stdout)
+	if err != nil {
+		return errs.New("Error planning migration for master database on satellite: %+v", err)
+	}
+
+	metabaseDB, err := metabase.Open(ctx, log.Named("metabase"), runCfg.Metainfo.DatabaseURL, metabase.Config{
+		MinPartSize:      runCfg.Config.Metainfo.MinPartSize,
+		MaxNumberOfParts: runCfg.Config.Metainfo.MaxNumberOfParts,
+	})
+	if err != nil {
+		return errs.New("Error creating metabase connection: %+v", err)
+	}
+	defer func() {
+		err = errs.Combine(err, metabaseDB.Close())
+	}()
+
+	_, err = fmt.Fprintln(os.Stdout, "-- metabase")
+	if err != nil {
+		return err
+	}
+	err = metabaseDB.PlanMigration(ctx, os.Stdout)
+	if err != nil {
+		return errs.New("Error planning metabase migration: %+v", err)
+	}
+
+	return nil
+}
+
 func cmdSetup(cmd *cobra.Command, args []string) (err error) {
 	setupDir, err := filepath.Abs(confDir)
 	if err != nil {
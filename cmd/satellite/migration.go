@@ -0,0 +1,327 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/private/dbutil"
+	"storj.io/private/process"
+	"storj.io/storj/private/migrate"
+	"storj.io/storj/satellite/metabase"
+	"storj.io/storj/satellite/satellitedb"
+)
+
+// Exit codes of the migration status and verify commands.
+const (
+	migrationStatusUpToDate = 0
+	migrationStatusBehind   = 1
+	migrationStatusError    = 2
+)
+
+// migrationComponent is the migration state of a database of the satellite.
+type migrationComponent struct {
+	Name    string          `json:"name"`
+	Current int             `json:"current"`
+	Latest  int             `json:"latest"`
+	Pending []migrationStep `json:"pending"`
+}
+
+// migrationStep is a pending step of a migration.
+type migrationStep struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+
+	RequiresConfirmation bool   `json:"requires_confirmation,omitempty"`
+	ConfirmationReason   string `json:"confirmation_reason,omitempty"`
+}
+
+func newMigrationComponent(name string, status migrate.Status) migrationComponent {
+	component := migrationComponent{
+		Name:    name,
+		Current: status.Current,
+		Latest:  status.Latest,
+		Pending: []migrationStep{},
+	}
+	for _, step := range status.Pending {
+		component.Pending = append(component.Pending, migrationStep{
+			Version:     step.Version,
+			Description: step.Description,
+
+			RequiresConfirmation: step.RequiresConfirmation,
+			ConfirmationReason:   step.ConfirmationReason,
+		})
+	}
+	return component
+}
+
+func cmdMigrationStatus(cmd *cobra.Command, args []string) (err error) {
+	ctx, _ := process.Ctx(cmd)
+
+	if migrationStatusOutput != "text" && migrationStatusOutput != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", migrationStatusOutput)
+		os.Exit(migrationStatusError)
+	}
+
+	components, err := migrationStatus(ctx, zap.L())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading the migration status: %+v\n", err)
+		os.Exit(migrationStatusError)
+	}
+
+	if migrationStatusOutput == "json" {
+		err = json.NewEncoder(os.Stdout).Encode(components)
+	} else {
+		err = printMigrationStatus(os.Stdout, components)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error printing the migration status: %+v\n", err)
+		os.Exit(migrationStatusError)
+	}
+
+	for _, component := range components {
+		if len(component.Pending) > 0 {
+			os.Exit(migrationStatusBehind)
+		}
+	}
+	return nil
+}
+
+// migrationStatus returns the migration state of the satellite databases and
+// the metabase. The databases are only read.
+func migrationStatus(ctx context.Context, log *zap.Logger) (_ []migrationComponent, err error) {
+	db, err := satellitedb.Open(ctx, log.Named("migration"), runCfg.Database, satellitedb.Options{ApplicationName: "satellite-migration-status"})
+	if err != nil {
+		return nil, errs.New("Error creating new master database connection: %+v", err)
+	}
+	defer func() {
+		err = errs.Combine(err, db.Close())
+	}()
+
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return nil, errs.New("Error reading the master database version: %+v", err)
+	}
+
+	var components []migrationComponent
+	for name, status := range statuses {
+		if name == "" {
+			name = "satellitedb"
+		} else {
+			name = "satellitedb:" + name
+		}
+		components = append(components, newMigrationComponent(name, status))
+	}
+	sort.Slice(components, func(i, k int) bool {
+		return components[i].Name < components[k].Name
+	})
+
+	metabaseDB, err := metabase.Open(ctx, log.Named("metabase"), runCfg.Metainfo.DatabaseURL, metabase.Config{
+		MinPartSize:      runCfg.Config.Metainfo.MinPartSize,
+		MaxNumberOfParts: runCfg.Config.Metainfo.MaxNumberOfParts,
+	})
+	if err != nil {
+		return nil, errs.New("Error creating metabase connection: %+v", err)
+	}
+	defer func() {
+		err = errs.Combine(err, metabaseDB.Close())
+	}()
+
+	status, err := metabaseDB.MigrationStatus(ctx)
+	if err != nil {
+		return nil, errs.New("Error reading the metabase version: %+v", err)
+	}
+	components = append(components, newMigrationComponent("metabase", status))
+
+	return components, nil
+}
+
+// printMigrationStatus prints the current and latest version of every database
+// followed by its pending steps.
+func printMigrationStatus(w io.Writer, components []migrationComponent) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATABASE\tCURRENT\tLATEST\tPENDING")
+	for _, component := range components {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", component.Name, component.Current, component.Latest, len(component.Pending))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, component := range components {
+		if len(component.Pending) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\nPending steps of %s:\n", component.Name)
+		for _, step := range component.Pending {
+			fmt.Fprintf(w, "  %d  %s\n", step.Version, step.Description)
+			if step.RequiresConfirmation {
+				fmt.Fprintf(w, "      requires --confirm-version %d: %s\n", step.Version, step.ConfirmationReason)
+			}
+		}
+	}
+	return nil
+}
+
+func cmdMigrationVerify(cmd *cobra.Command, args []string) (err error) {
+	ctx, _ := process.Ctx(cmd)
+	log := zap.L()
+
+	scratch := migrationVerifyScratchDatabase
+	if scratch == "" {
+		mapping, err := dbutil.ParseDBMapping(runCfg.Database)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing the database URL: %+v\n", err)
+			os.Exit(migrationStatusError)
+		}
+		scratch = mapping[""]
+	}
+
+	db, err := satellitedb.Open(ctx, log.Named("migration"), runCfg.Database, satellitedb.Options{
+		ApplicationName: "satellite-migration-verify",
+		ReadOnly:        true,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating new master database connection: %+v\n", err)
+		os.Exit(migrationStatusError)
+	}
+	defer func() {
+		err = errs.Combine(err, db.Close())
+	}()
+
+	err = db.VerifySchema(ctx, scratch)
+	switch {
+	case err == nil:
+		fmt.Println("The schema of the satellite database matches the expected schema.")
+		return nil
+	case satellitedb.ErrSchemaDrift.Has(err):
+		fmt.Fprintf(os.Stdout, "%v\n", err)
+		_ = db.Close()
+		os.Exit(migrationStatusBehind)
+	default:
+		fmt.Fprintf(os.Stderr, "Error verifying the schema: %+v\n", err)
+		_ = db.Close()
+		os.Exit(migrationStatusError)
+	}
+	return nil
+}
+
+func cmdMigrationBackfillList(cmd *cobra.Command, args []string) (err error) {
+	ctx, _ := process.Ctx(cmd)
+
+	return withBackfillJobs(ctx, zap.L(), func(backfills *migrate.Backfills) error {
+		jobs, err := backfills.List(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tSTATUS\tCURSOR\tCREATED\tUPDATED")
+		for _, job := range jobs {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", job.Name, job.Status, job.Cursor,
+				job.CreatedAt.Format(time.RFC3339), job.UpdatedAt.Format(time.RFC3339))
+		}
+		return tw.Flush()
+	})
+}
+
+func cmdMigrationBackfillPause(cmd *cobra.Command, args []string) (err error) {
+	ctx, _ := process.Ctx(cmd)
+
+	return withBackfillJobs(ctx, zap.L(), func(backfills *migrate.Backfills) error {
+		return backfills.Pause(ctx, args[0])
+	})
+}
+
+func cmdMigrationBackfillResume(cmd *cobra.Command, args []string) (err error) {
+	ctx, _ := process.Ctx(cmd)
+
+	return withBackfillJobs(ctx, zap.L(), func(backfills *migrate.Backfills) error {
+		return backfills.Resume(ctx, args[0])
+	})
+}
+
+// withBackfillJobs calls fn with the backfill jobs of the satellite database.
+func withBackfillJobs(ctx context.Context, log *zap.Logger, fn func(backfills *migrate.Backfills) error) (err error) {
+	db, err := satellitedb.Open(ctx, log.Named("migration"), runCfg.Database, satellitedb.Options{ApplicationName: "satellite-migration-backfill"})
+	if err != nil {
+		return errs.New("Error creating new master database connection: %+v", err)
+	}
+	defer func() {
+		err = errs.Combine(err, db.Close())
+	}()
+
+	return fn(db.BackfillJobs())
+}
+
+// migrationPlan is the plan of a database of the satellite.
+type migrationPlan struct {
+	Name  string          `json:"name"`
+	Steps json.RawMessage `json:"steps"`
+}
+
+// printMigrationPlanJSON writes the pending steps of the satellite databases
+// and the metabase as JSON, in the order of the names of the databases.
+func printMigrationPlanJSON(ctx context.Context, log *zap.Logger, w io.Writer) (err error) {
+	db, err := satellitedb.Open(ctx, log.Named("migration"), runCfg.Database, satellitedb.Options{ApplicationName: "satellite-migration"})
+	if err != nil {
+		return errs.New("Error creating new master database connection for satellitedb migration: %+v", err)
+	}
+	defer func() {
+		err = errs.Combine(err, db.Close())
+	}()
+
+	plans, err := db.PlanMigrationJSON(ctx)
+	if err != nil {
+		return errs.New("Error planning migration for master database on satellite: %+v", err)
+	}
+
+	var components []migrationPlan
+	for name, plan := range plans {
+		if name == "" {
+			name = "satellitedb"
+		} else {
+			name = "satellitedb:" + name
+		}
+		components = append(components, migrationPlan{Name: name, Steps: plan})
+	}
+	sort.Slice(components, func(i, k int) bool {
+		return components[i].Name < components[k].Name
+	})
+
+	metabaseDB, err := metabase.Open(ctx, log.Named("metabase"), runCfg.Metainfo.DatabaseURL, metabase.Config{
+		MinPartSize:      runCfg.Config.Metainfo.MinPartSize,
+		MaxNumberOfParts: runCfg.Config.Metainfo.MaxNumberOfParts,
+	})
+	if err != nil {
+		return errs.New("Error creating metabase connection: %+v", err)
+	}
+	defer func() {
+		err = errs.Combine(err, metabaseDB.Close())
+	}()
+
+	plan, err := metabaseDB.PlanMigrationJSON(ctx)
+	if err != nil {
+		return errs.New("Error planning metabase migration: %+v", err)
+	}
+	components = append(components, migrationPlan{Name: "metabase", Steps: plan})
+
+	data, err := json.MarshalIndent(components, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
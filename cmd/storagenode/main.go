@@ -88,6 +88,39 @@ var (
 		RunE:        cmdGracefulExitStatus,
 		Annotations: map[string]string{"type": "helper"},
 	}
+	restoreTrashCmd = &cobra.Command{
+		Use:   "restore-trash",
+		Short: "Restore the trash of a satellite",
+		Long: "Restore the pieces in the trash of a satellite, e.g. after a faulty garbage collection.\n" +
+			"Every restored piece is verified to have a readable header before it is removed from the trash, " +
+			"and pieces that exist outside of the trash already are kept. " +
+			"The storage node should be stopped while the trash is restored.",
+		RunE:        cmdRestoreTrash,
+		Annotations: map[string]string{"type": "helper"},
+		Example: `
+#=> restore the trash of a satellite
+$ storagenode restore-trash --satellite '<satellite-id>' --config-dir '<path/to/config-dir>' --identity-dir '<path/to/identity-dir>'
+`,
+		Args: cobra.ExactArgs(0),
+	}
+	verifyPiecesCmd = &cobra.Command{
+		Use:   "verify-pieces",
+		Short: "Estimate the corruption rate of the pieces of a satellite",
+		Long: "Estimate the corruption rate of the pieces of a satellite, e.g. after a filesystem incident.\n" +
+			"A uniform sample of the pieces is checked to open and to have a readable header, " +
+			"and the content of a fraction of them is checked against the stored hash. " +
+			"The command fails when the estimated corruption rate exceeds the maximum.",
+		RunE:        cmdVerifyPieces,
+		Annotations: map[string]string{"type": "helper"},
+		Example: `
+#=> verify a sample of the pieces of a satellite
+$ storagenode verify-pieces --satellite '<satellite-id>' --sample 10000 --config-dir '<path/to/config-dir>' --identity-dir '<path/to/identity-dir>'
+
+#=> also verify the content of a tenth of the sampled pieces
+$ storagenode verify-pieces --satellite '<satellite-id>' --sample 10000 --deep 0.1 --rate-limit 10MB --config-dir '<path/to/config-dir>' --identity-dir '<path/to/identity-dir>'
+`,
+		Args: cobra.ExactArgs(0),
+	}
 	issueAPITokenCmd = &cobra.Command{
 		Use:   "issue-apikey",
 		Short: "Issue apikey for mnd",
@@ -126,6 +159,20 @@ $ storagenode info --json --config-dir '<path/to/config-dir>' --identity-dir '<p
 
 		JSON bool `default:"false" help:"print node info in JSON format"`
 	}
+	restoreTrashCfg struct {
+		storagenode.Config
+
+		Satellite string `default:"" help:"ID of the satellite whose trash is restored"`
+	}
+	verifyPiecesCfg struct {
+		storagenode.Config
+
+		Satellite     string      `default:"" help:"ID of the satellite whose pieces are verified"`
+		Sample        int         `default:"10000" help:"number of pieces that are verified"`
+		Deep          float64     `default:"0" help:"fraction of the sampled pieces whose content is verified against the stored hash"`
+		RateLimit     memory.Size `default:"0" help:"maximum number of bytes per second read from the pieces, 0 is unlimited"`
+		MaxCorruption float64     `default:"0.001" help:"estimated corruption rate above which the command fails"`
+	}
 	dashboardCfg struct {
 		Address string `default:"127.0.0.1:7778" help:"address for dashboard service"`
 	}
@@ -157,6 +204,8 @@ func init() {
 	rootCmd.AddCommand(gracefulExitInitCmd)
 	rootCmd.AddCommand(gracefulExitStatusCmd)
 	rootCmd.AddCommand(issueAPITokenCmd)
+	rootCmd.AddCommand(restoreTrashCmd)
+	rootCmd.AddCommand(verifyPiecesCmd)
 	rootCmd.AddCommand(nodeInfoCmd)
 	process.Bind(runCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
 	process.Bind(setupCmd, &setupCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir), cfgstruct.SetupMode())
@@ -166,6 +215,8 @@ func init() {
 	process.Bind(gracefulExitInitCmd, &diagCfg, defaults, cfgstruct.ConfDir(defaultDiagDir))
 	process.Bind(gracefulExitStatusCmd, &diagCfg, defaults, cfgstruct.ConfDir(defaultDiagDir))
 	process.Bind(issueAPITokenCmd, &diagCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
+	process.Bind(restoreTrashCmd, &restoreTrashCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
+	process.Bind(verifyPiecesCmd, &verifyPiecesCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
 	process.Bind(nodeInfoCmd, &nodeInfoCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.IdentityDir(identityDir))
 }
 
@@ -0,0 +1,76 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/memory"
+	"storj.io/common/storj"
+	"storj.io/private/process"
+	"storj.io/storj/storagenode/pieces"
+	"storj.io/storj/storagenode/storagenodedb"
+)
+
+// restoreTrashProgressInterval is how often the progress of restoring the
+// trash is printed.
+const restoreTrashProgressInterval = 5 * time.Second
+
+func cmdRestoreTrash(cmd *cobra.Command, args []string) (err error) {
+	ctx, _ := process.Ctx(cmd)
+	log := zap.L()
+
+	satelliteID, err := storj.NodeIDFromString(restoreTrashCfg.Satellite)
+	if err != nil {
+		return errs.New("invalid satellite ID %q: %v", restoreTrashCfg.Satellite, err)
+	}
+
+	db, err := storagenodedb.OpenExisting(ctx, log.Named("db"), restoreTrashCfg.DatabaseConfig())
+	if err != nil {
+		return errs.New("Error starting master database on storage node: %v", err)
+	}
+	defer func() {
+		err = errs.Combine(err, db.Close())
+	}()
+
+	store := pieces.NewStore(log.Named("pieces"),
+		db.Pieces(),
+		db.V0PieceInfo(),
+		db.PieceExpirationDB(),
+		db.PieceSpaceUsedDB(),
+		restoreTrashCfg.Pieces,
+	)
+
+	fmt.Printf("Restoring the trash of satellite %s.\n", satelliteID)
+
+	lastPrinted := time.Now()
+	totals, err := store.RestoreTrashVerified(ctx, satelliteID, func(progress pieces.RestoreProgress) {
+		if time.Since(lastPrinted) < restoreTrashProgressInterval {
+			return
+		}
+		lastPrinted = time.Now()
+		printRestoreProgress(progress)
+	})
+	printRestoreProgress(totals)
+	if err != nil {
+		return errs.New("restoring the trash failed: %v", err)
+	}
+	if totals.PiecesFailed > 0 {
+		fmt.Printf("%d pieces could not be restored and were kept in the trash, see the log for details.\n", totals.PiecesFailed)
+	}
+	return nil
+}
+
+func printRestoreProgress(progress pieces.RestoreProgress) {
+	fmt.Printf("Restored %d pieces (%s), %d collisions, %d failed.\n",
+		progress.PiecesRestored,
+		memory.Size(progress.BytesRestored).Base10String(),
+		progress.Collisions,
+		progress.PiecesFailed)
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/storj"
+	"storj.io/private/process"
+	"storj.io/storj/storagenode/pieces"
+	"storj.io/storj/storagenode/storagenodedb"
+)
+
+// verifyPiecesProgressInterval is how often the progress of verifying the
+// pieces is printed.
+const verifyPiecesProgressInterval = 5 * time.Second
+
+func cmdVerifyPieces(cmd *cobra.Command, args []string) (err error) {
+	ctx, _ := process.Ctx(cmd)
+	log := zap.L()
+
+	satelliteID, err := storj.NodeIDFromString(verifyPiecesCfg.Satellite)
+	if err != nil {
+		return errs.New("invalid satellite ID %q: %v", verifyPiecesCfg.Satellite, err)
+	}
+	if verifyPiecesCfg.Sample <= 0 {
+		return errs.New("sample must be positive, got %d", verifyPiecesCfg.Sample)
+	}
+	if verifyPiecesCfg.Deep < 0 || verifyPiecesCfg.Deep > 1 {
+		return errs.New("deep must be between 0 and 1, got %v", verifyPiecesCfg.Deep)
+	}
+
+	db, err := storagenodedb.OpenExisting(ctx, log.Named("db"), verifyPiecesCfg.DatabaseConfig())
+	if err != nil {
+		return errs.New("Error starting master database on storage node: %v", err)
+	}
+	defer func() {
+		err = errs.Combine(err, db.Close())
+	}()
+
+	store := pieces.NewStore(log.Named("pieces"),
+		db.Pieces(),
+		db.V0PieceInfo(),
+		db.PieceExpirationDB(),
+		db.PieceSpaceUsedDB(),
+		verifyPiecesCfg.Pieces,
+	)
+
+	fmt.Printf("Verifying %d pieces of satellite %s.\n", verifyPiecesCfg.Sample, satelliteID)
+
+	lastPrinted := time.Now()
+	result, err := store.VerifySample(ctx, satelliteID, pieces.VerifyConfig{
+		Sample:       verifyPiecesCfg.Sample,
+		DeepFraction: verifyPiecesCfg.Deep,
+		RateLimit:    verifyPiecesCfg.RateLimit,
+	}, func(progress pieces.VerifyResult) {
+		if time.Since(lastPrinted) < verifyPiecesProgressInterval {
+			return
+		}
+		lastPrinted = time.Now()
+		fmt.Printf("Verified %d pieces, %d corrupt.\n", progress.Verified, progress.Corrupt)
+	})
+	if err != nil {
+		return errs.New("verifying the pieces failed: %v", err)
+	}
+
+	rate, lower, upper := result.CorruptionRate()
+	fmt.Printf("Verified %d of %d pieces, %d of them deeply, %d corrupt.\n",
+		result.Verified, result.Pieces, result.DeepVerified, result.Corrupt)
+	fmt.Printf("Estimated corruption rate: %.4f%% (95%% confidence: %.4f%% - %.4f%%).\n",
+		100*rate, 100*lower, 100*upper)
+	if result.Corrupt > 0 {
+		fmt.Println("See the log for the corrupt pieces.")
+	}
+
+	if rate > verifyPiecesCfg.MaxCorruption {
+		return errs.New("estimated corruption rate %.4f%% exceeds the maximum of %.4f%%", 100*rate, 100*verifyPiecesCfg.MaxCorruption)
+	}
+	return nil
+}
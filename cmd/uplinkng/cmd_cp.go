@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	progressbar "github.com/cheggaaa/pb/v3"
 	"github.com/zeebo/clingy"
@@ -21,17 +22,21 @@ import (
 	"storj.io/storj/cmd/uplinkng/ulext"
 	"storj.io/storj/cmd/uplinkng/ulfs"
 	"storj.io/storj/cmd/uplinkng/ulloc"
+	"storj.io/uplink"
 )
 
 type cmdCp struct {
 	ex ulext.External
 
-	access    string
-	recursive bool
-	transfers int
-	dryrun    bool
-	progress  bool
-	byteRange string
+	access       string
+	sourceAccess string
+	recursive    bool
+	transfers    int
+	dryrun       bool
+	progress     bool
+	byteRange    string
+	noAutoPrefix bool
+	retries      int
 
 	parallelism          int
 	parallelismChunkSize memory.Size
@@ -46,6 +51,7 @@ func newCmdCp(ex ulext.External) *cmdCp {
 
 func (c *cmdCp) Setup(params clingy.Parameters) {
 	c.access = params.Flag("access", "Access name or value to use", "").(string)
+	c.sourceAccess = params.Flag("source-access", "Access name or value to use when reading remote sources, if different from --access", "").(string)
 	c.recursive = params.Flag("recursive", "Peform a recursive copy", false,
 		clingy.Short('r'),
 		clingy.Transform(strconv.ParseBool), clingy.Boolean,
@@ -66,6 +72,18 @@ func (c *cmdCp) Setup(params clingy.Parameters) {
 	c.progress = params.Flag("progress", "Show a progress bar when possible", true,
 		clingy.Transform(strconv.ParseBool), clingy.Boolean,
 	).(bool)
+	c.noAutoPrefix = params.Flag("no-auto-prefix", "Never treat a remote destination without a trailing slash as a prefix, even if objects exist beneath it", false,
+		clingy.Transform(strconv.ParseBool), clingy.Boolean,
+	).(bool)
+	c.retries = params.Flag("retries", "Number of times to retry listing, reading, or removing objects on transient errors", 0,
+		clingy.Transform(strconv.Atoi),
+		clingy.Transform(func(n int) (int, error) {
+			if n < 0 {
+				return 0, errs.New("retries must be non-negative")
+			}
+			return n, nil
+		}),
+	).(int)
 	c.byteRange = params.Flag("range", "Downloads the specified range bytes of an object. For more information about the HTTP Range header, see https://www.w3.org/Protocols/rfc2616/rfc2616-sec14.html#sec14.35", "").(string)
 
 	c.parallelism = params.Flag("parallelism", "Controls how many parallel chunks to upload/download from a file", 4,
@@ -93,19 +111,31 @@ func (c *cmdCp) Setup(params clingy.Parameters) {
 }
 
 func (c *cmdCp) Execute(ctx clingy.Context) error {
-	fs, err := c.ex.OpenFilesystem(ctx, c.access)
+	fs, err := c.ex.OpenFilesystem(ctx, c.access, ulext.SourceAccess(c.sourceAccess))
 	if err != nil {
 		return err
 	}
 	defer func() { _ = fs.Close() }()
 
+	if c.retries > 0 {
+		fs = ulfs.NewRetryingFilesystem(fs, ulfs.RetryConfig{
+			Attempts:       c.retries + 1,
+			InitialBackoff: 500 * time.Millisecond,
+			MaxBackoff:     30 * time.Second,
+		})
+	}
+
 	// we ensure the source and destination are lexically directoryish
 	// if they map to directories. the destination is always converted to be
 	// directoryish if the copy is recursive.
 	if fs.IsLocalDir(ctx, c.source) {
 		c.source = c.source.AsDirectoryish()
 	}
-	if c.recursive || fs.IsLocalDir(ctx, c.dest) {
+	remotePrefix, err := c.isRemotePrefix(ctx, fs, c.dest)
+	if err != nil {
+		return err
+	}
+	if c.recursive || fs.IsLocalDir(ctx, c.dest) || remotePrefix {
 		c.dest = c.dest.AsDirectoryish()
 	}
 
@@ -128,7 +158,10 @@ func (c *cmdCp) Execute(ctx clingy.Context) error {
 			return errs.New("destination is a directory and cannot find base name for source %q", c.source)
 		}
 	}
-	c.dest = joinDestWith(c.dest, base)
+	c.dest, err = joinDestWith(c.dest, base)
+	if err != nil {
+		return err
+	}
 
 	if !c.source.Std() && !c.dest.Std() {
 		fmt.Fprintln(ctx.Stdout(), copyVerb(c.source, c.dest), c.source, "to", c.dest)
@@ -137,6 +170,25 @@ func (c *cmdCp) Execute(ctx clingy.Context) error {
 	return c.copyFile(ctx, fs, c.source, c.dest, c.progress)
 }
 
+// isRemotePrefix returns true if the destination is a remote key without a
+// trailing slash that has objects beneath it as a prefix. If an object exists
+// at exactly that key, the destination is ambiguous and it is treated literally:
+// an explicit trailing slash is required to copy beneath it.
+func (c *cmdCp) isRemotePrefix(ctx clingy.Context, fs ulfs.Filesystem, dest ulloc.Location) (bool, error) {
+	if c.noAutoPrefix || !dest.Remote() || dest.Directoryish() {
+		return false, nil
+	}
+	isDir, err := fs.IsRemoteDir(ctx, dest)
+	if err != nil || !isDir {
+		return false, err
+	}
+	_, err = fs.StatDestination(ctx, dest)
+	if errs.Is(err, uplink.ErrObjectNotFound) {
+		return true, nil
+	}
+	return false, err
+}
+
 func (c *cmdCp) copyRecursive(ctx clingy.Context, fs ulfs.Filesystem) error {
 	if c.source.Std() || c.dest.Std() {
 		return errs.New("cannot recursively copy to stdin/stdout")
@@ -175,7 +227,12 @@ func (c *cmdCp) copyRecursive(ctx clingy.Context, fs ulfs.Filesystem) error {
 		if err != nil {
 			return err
 		}
-		dest := joinDestWith(c.dest, rel)
+		dest, err := joinDestWith(c.dest, rel)
+		if err != nil {
+			fprintln(ctx.Stderr(), copyVerb(source, c.dest), "failed:", err.Error())
+			addError(err)
+			continue
+		}
 
 		ok := limiter.Go(ctx, func() {
 			fprintln(ctx.Stdout(), copyVerb(source, dest), source, "to", dest)
@@ -210,7 +267,7 @@ func (c *cmdCp) copyFile(ctx clingy.Context, fs ulfs.Filesystem, source, dest ul
 		return errs.Wrap(err)
 	}
 
-	mrh, err := fs.Open(ctx, source)
+	mrh, err := fs.Open(ctx, source, nil)
 	if err != nil {
 		return err
 	}
@@ -223,7 +280,15 @@ func (c *cmdCp) copyFile(ctx clingy.Context, fs ulfs.Filesystem, source, dest ul
 	defer func() { _ = mwh.Abort(ctx) }()
 
 	var bar *progressbar.ProgressBar
-	if progress && !c.dest.Std() {
+	if progress && (source.Std() || dest.Std()) {
+		// stdout may be carrying the data, so report a byte counter on
+		// stderr from whichever handle is attached to stdin/stdout.
+		if pr, ok := mrh.(ulfs.ProgressReporter); ok && source.Std() {
+			defer stdProgress(ctx, pr)()
+		} else if pr, ok := mwh.(ulfs.ProgressReporter); ok && dest.Std() {
+			defer stdProgress(ctx, pr)()
+		}
+	} else if progress {
 		bar = progressbar.New64(0).SetWriter(ctx.Stdout())
 		defer bar.Finish()
 	}
@@ -237,6 +302,36 @@ func (c *cmdCp) copyFile(ctx clingy.Context, fs ulfs.Filesystem, source, dest ul
 	))
 }
 
+// stdProgress periodically writes the number of bytes reported by pr to stderr
+// until the returned function is called.
+func stdProgress(ctx clingy.Context, pr ulfs.ProgressReporter) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintf(ctx.Stderr(), "\r%v transferred", memory.Size(pr.Progress()))
+			case <-done:
+				fmt.Fprintf(ctx.Stderr(), "\r%v transferred\n", memory.Size(pr.Progress()))
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
 func copyVerb(source, dest ulloc.Location) string {
 	switch {
 	case dest.Remote():
@@ -248,8 +343,14 @@ func copyVerb(source, dest ulloc.Location) string {
 	}
 }
 
-func joinDestWith(dest ulloc.Location, suffix string) ulloc.Location {
-	dest = dest.AppendKey(suffix)
+// joinDestWith appends the suffix to the destination. It returns an error if
+// the destination is local and the suffix would escape it.
+func joinDestWith(dest ulloc.Location, suffix string) (ulloc.Location, error) {
+	joined, err := dest.JoinKey(suffix)
+	if err != nil {
+		return ulloc.Location{}, errs.New("refusing to write outside of %q: %w", dest, err)
+	}
+	dest = joined
 	// if the destination is local and directoryish, remove any
 	// trailing slashes that it has. this makes it so that if
 	// a remote file is name "foo/", then we copy it down as
@@ -257,7 +358,7 @@ func joinDestWith(dest ulloc.Location, suffix string) ulloc.Location {
 	if dest.Local() && dest.Directoryish() {
 		dest = dest.Undirectoryish()
 	}
-	return dest
+	return dest, nil
 }
 
 func parallelCopy(
@@ -274,6 +375,14 @@ func parallelCopy(
 		}
 	}
 
+	if bar != nil {
+		info, err := src.Info(clctx)
+		if err != nil {
+			return err
+		}
+		bar.SetTotal(ulfs.RangeLength(info.ContentLength, offset, length)).Start()
+	}
+
 	var (
 		limiter = sync2.NewLimiter(p)
 		es      errs.Group
@@ -324,7 +433,6 @@ func parallelCopy(
 
 			var w io.Writer = wh
 			if bar != nil {
-				bar.SetTotal(rh.Info().ContentLength).Start()
 				w = bar.NewProxyWriter(w)
 			}
 
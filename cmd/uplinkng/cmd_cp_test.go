@@ -4,9 +4,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/clingy"
+	"github.com/zeebo/errs"
+
+	"storj.io/common/memory"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
 	"storj.io/storj/cmd/uplinkng/ultest"
+	"storj.io/storj/private/testplanet"
 )
 
 func TestCpDownload(t *testing.T) {
@@ -128,6 +138,42 @@ func TestCpUpload(t *testing.T) {
 		)
 	})
 
+	t.Run("ExistingPrefix", func(t *testing.T) {
+		state := ultest.Setup(commands,
+			ultest.WithFile("/home/user/file1.txt", "local"),
+			ultest.WithFile("sj://user/prefix/other.txt", "other"),
+		)
+
+		state.Succeed(t, "cp", "/home/user/file1.txt", "sj://user/prefix").RequireRemoteFiles(t,
+			ultest.File{Loc: "sj://user/prefix/file1.txt", Contents: "local"},
+			ultest.File{Loc: "sj://user/prefix/other.txt", Contents: "other"},
+		)
+
+		state.Succeed(t, "cp", "/home/user/file1.txt", "sj://user/prefix", "--no-auto-prefix").RequireRemoteFiles(t,
+			ultest.File{Loc: "sj://user/prefix", Contents: "local"},
+			ultest.File{Loc: "sj://user/prefix/other.txt", Contents: "other"},
+		)
+	})
+
+	t.Run("ExistingKeyAndPrefix", func(t *testing.T) {
+		state := ultest.Setup(commands,
+			ultest.WithFile("/home/user/file1.txt", "local"),
+			ultest.WithFile("sj://user/both", "key"),
+			ultest.WithFile("sj://user/both/other.txt", "other"),
+		)
+
+		state.Succeed(t, "cp", "/home/user/file1.txt", "sj://user/both").RequireRemoteFiles(t,
+			ultest.File{Loc: "sj://user/both", Contents: "local"},
+			ultest.File{Loc: "sj://user/both/other.txt", Contents: "other"},
+		)
+
+		state.Succeed(t, "cp", "/home/user/file1.txt", "sj://user/both/").RequireRemoteFiles(t,
+			ultest.File{Loc: "sj://user/both", Contents: "key"},
+			ultest.File{Loc: "sj://user/both/file1.txt", Contents: "local"},
+			ultest.File{Loc: "sj://user/both/other.txt", Contents: "other"},
+		)
+	})
+
 	t.Run("Recursive", func(t *testing.T) {
 		state := ultest.Setup(commands,
 			ultest.WithBucket("user"),
@@ -149,9 +195,7 @@ func TestCpUpload(t *testing.T) {
 func TestCpRecursiveDifficult(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		state := ultest.Setup(commands,
-			ultest.WithFile("sj://user/dot-dot/../../../../../foo"),
-			ultest.WithFile("sj://user/dot-dot/../../foo"),
-			ultest.WithFile("sj://user/dot-dot/../foo"),
+			ultest.WithFile("sj://user/dot-dot/./foo"),
 			ultest.WithFile("sj://user//starts-slash"),
 			ultest.WithFile("sj://user/ends-slash"),
 			ultest.WithFile("sj://user/ends-slash/"),
@@ -160,13 +204,29 @@ func TestCpRecursiveDifficult(t *testing.T) {
 		)
 
 		state.Succeed(t, "cp", "sj://user", "some/deep/folder", "--recursive").RequireLocalFiles(t,
-			ultest.File{Loc: "some/deep/folder/foo", Contents: "sj://user/dot-dot/../foo"},
+			ultest.File{Loc: "some/deep/folder/dot-dot/foo", Contents: "sj://user/dot-dot/./foo"},
 			ultest.File{Loc: "some/deep/folder/starts-slash", Contents: "sj://user//starts-slash"},
 			ultest.File{Loc: "some/deep/folder/ends-slash", Contents: "sj://user/ends-slash//"},
 			ultest.File{Loc: "some/deep/folder/mid-slash/file", Contents: "sj://user/mid-slash//file"},
 		)
 	})
 
+	t.Run("DotDot", func(t *testing.T) {
+		state := ultest.Setup(commands,
+			ultest.WithFile("sj://user/dot-dot/../../../../../foo"),
+			ultest.WithFile("sj://user/dot-dot/../../foo"),
+			ultest.WithFile("sj://user/dot-dot/../foo"),
+			ultest.WithFile("sj://user/file"),
+		)
+
+		result := state.Fail(t, "cp", "sj://user", "some/deep/folder", "--recursive").RequireLocalFiles(t,
+			ultest.File{Loc: "some/deep/folder/file", Contents: "sj://user/file"},
+		)
+		require.Contains(t, result.Stderr, `"dot-dot/../../../../../foo"`)
+		require.Contains(t, result.Stderr, `"dot-dot/../../foo"`)
+		require.Contains(t, result.Stderr, `"dot-dot/../foo"`)
+	})
+
 	t.Run("DirectoryConflict", func(t *testing.T) {
 		state := ultest.Setup(commands,
 			ultest.WithFile("sj://user/fileder"),
@@ -436,3 +496,100 @@ func TestCpStandard(t *testing.T) {
 		)
 	})
 }
+
+func TestCpSourceAccess(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 4, UplinkCount: 2,
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		srcUplink, dstUplink := planet.Uplinks[0], planet.Uplinks[1]
+
+		expected := map[string][]byte{
+			"dir/a":     testrand.Bytes(memory.KiB),
+			"dir/b":     testrand.Bytes(memory.KiB),
+			"dir/sub/c": testrand.Bytes(memory.KiB),
+		}
+		for key, data := range expected {
+			require.NoError(t, srcUplink.Upload(ctx, satellite, "source", key, data))
+		}
+		require.NoError(t, dstUplink.CreateBucket(ctx, satellite, "dest"))
+
+		srcAccess, err := srcUplink.Access[satellite.ID()].Serialize()
+		require.NoError(t, err)
+		dstAccess, err := dstUplink.Access[satellite.ID()].Serialize()
+		require.NoError(t, err)
+
+		ex := newExternal()
+		ex.access.loaded = true
+		ex.access.defaultName = "dst"
+		ex.access.accesses = map[string]string{
+			"src": srcAccess,
+			"dst": dstAccess,
+		}
+
+		// the destination project does not have the source bucket.
+		_, err = runCommand(ctx, ex, "cp", "sj://source/dir/a", "sj://dest/a")
+		require.Error(t, err)
+
+		_, err = runCommand(ctx, ex, "cp", "--source-access", "src", "--recursive", "sj://source/dir/", "sj://dest/copied/")
+		require.NoError(t, err)
+
+		_, err = runCommand(ctx, ex, "cp", "--source-access", "src", "sj://source/dir/a", "sj://dest/single")
+		require.NoError(t, err)
+
+		for key, data := range expected {
+			got, err := dstUplink.Download(ctx, satellite, "dest", "copied/"+key[len("dir/"):])
+			require.NoError(t, err)
+			require.Equal(t, data, got)
+		}
+		got, err := dstUplink.Download(ctx, satellite, "dest", "single")
+		require.NoError(t, err)
+		require.Equal(t, expected["dir/a"], got)
+
+		// an existing destination prefix is looked up in the destination
+		// project, so an object at the exact key is overwritten.
+		require.NoError(t, dstUplink.Upload(ctx, satellite, "dest", "both", []byte("key")))
+		require.NoError(t, dstUplink.Upload(ctx, satellite, "dest", "both/other", []byte("other")))
+		_, err = runCommand(ctx, ex, "cp", "--source-access", "src", "sj://source/dir/a", "sj://dest/both")
+		require.NoError(t, err)
+		got, err = dstUplink.Download(ctx, satellite, "dest", "both")
+		require.NoError(t, err)
+		require.Equal(t, expected["dir/a"], got)
+
+		// without an object at the exact key, the prefix is copied into.
+		_, err = runCommand(ctx, ex, "cp", "--source-access", "src", "sj://source/dir/b", "sj://dest/copied")
+		require.NoError(t, err)
+		got, err = dstUplink.Download(ctx, satellite, "dest", "copied/b")
+		require.NoError(t, err)
+		require.Equal(t, expected["dir/b"], got)
+
+		// the source is left alone.
+		objects, err := srcUplink.ListObjects(ctx, satellite, "source")
+		require.NoError(t, err)
+		require.Len(t, objects, len(expected))
+	})
+}
+
+// runCommand runs the uplink command with the args in the external, and
+// returns its output.
+func runCommand(ctx context.Context, ex *external, args ...string) (stdout string, err error) {
+	var stdin, out, stderr bytes.Buffer
+	ok, err := clingy.Environment{
+		Name: "uplink-test",
+		Args: args,
+
+		Stdin:  &stdin,
+		Stdout: &out,
+		Stderr: &stderr,
+
+		Wrap: func(ctx clingy.Context, cmd clingy.Command) error {
+			return cmd.Execute(ctx)
+		},
+	}.Run(ctx, func(cmds clingy.Commands) {
+		commands(cmds, ex)
+	})
+	if err == nil && !ok {
+		err = errs.New("command failed: %s", stderr.String())
+	}
+	return out.String(), err
+}
@@ -4,10 +4,12 @@
 package main
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/zeebo/clingy"
+	"github.com/zeebo/errs"
 
 	"storj.io/storj/cmd/uplinkng/ulext"
 	"storj.io/storj/cmd/uplinkng/ulfs"
@@ -24,6 +26,8 @@ type cmdLs struct {
 	expanded  bool
 	pending   bool
 	utc       bool
+	count     bool
+	keysOnly  bool
 
 	prefix *ulloc.Location
 }
@@ -51,6 +55,13 @@ func (c *cmdLs) Setup(params clingy.Parameters) {
 	c.utc = params.Flag("utc", "Show all timestamps in UTC instead of local time", false,
 		clingy.Transform(strconv.ParseBool), clingy.Boolean,
 	).(bool)
+	c.count = params.Flag("count-prefix-items", "Show how many objects are contained in each prefix", false,
+		clingy.Transform(strconv.ParseBool), clingy.Boolean,
+	).(bool)
+
+	c.keysOnly = params.Flag("keys-only", "Only list keys, skipping creation times and sizes for faster listings", false,
+		clingy.Transform(strconv.ParseBool), clingy.Boolean,
+	).(bool)
 
 	c.prefix = params.Arg("prefix", "Prefix to list (sj://BUCKET[/KEY])", clingy.Optional,
 		clingy.Transform(ulloc.Parse),
@@ -65,16 +76,16 @@ func (c *cmdLs) Execute(ctx clingy.Context) error {
 }
 
 func (c *cmdLs) listBuckets(ctx clingy.Context) error {
-	project, err := c.ex.OpenProject(ctx, c.access)
+	fs, err := c.ex.OpenFilesystem(ctx, c.access)
 	if err != nil {
 		return err
 	}
-	defer func() { _ = project.Close() }()
+	defer func() { _ = fs.Close() }()
 
 	tw := newTabbedWriter(ctx.Stdout(), "CREATED", "NAME")
 	defer tw.Done()
 
-	iter := project.ListBuckets(ctx, nil)
+	iter := fs.ListBuckets(ctx)
 	for iter.Next() {
 		item := iter.Item()
 		tw.WriteLine(formatTime(c.utc, item.Created), item.Name)
@@ -83,6 +94,10 @@ func (c *cmdLs) listBuckets(ctx clingy.Context) error {
 }
 
 func (c *cmdLs) listLocation(ctx clingy.Context, prefix ulloc.Location) error {
+	if c.keysOnly && c.expanded {
+		return errs.New("--keys-only and --expanded cannot be used together")
+	}
+
 	fs, err := c.ex.OpenFilesystem(ctx, c.access, ulext.BypassEncryption(c.encrypted))
 	if err != nil {
 		return err
@@ -94,9 +109,15 @@ func (c *cmdLs) listLocation(ctx clingy.Context, prefix ulloc.Location) error {
 	}
 
 	headers := []string{"KIND", "CREATED", "SIZE", "KEY"}
+	if c.keysOnly {
+		headers = []string{"KIND", "KEY"}
+	}
 	if c.expanded {
 		headers = append(headers, "EXPIRES", "META")
 	}
+	if c.count {
+		headers = append(headers, "ITEMS")
+	}
 
 	tw := newTabbedWriter(ctx.Stdout(), headers...)
 	defer tw.Done()
@@ -106,6 +127,9 @@ func (c *cmdLs) listLocation(ctx clingy.Context, prefix ulloc.Location) error {
 		Recursive: c.recursive,
 		Pending:   c.pending,
 		Expanded:  c.expanded,
+		KeysOnly:  c.keysOnly,
+
+		CountPrefixItems: c.count,
 	})
 	if err != nil {
 		return err
@@ -116,16 +140,33 @@ func (c *cmdLs) listLocation(ctx clingy.Context, prefix ulloc.Location) error {
 		obj := iter.Item()
 
 		var parts []interface{}
-		if obj.IsPrefix {
+		if c.keysOnly {
+			kind := "OBJ"
+			if obj.IsPrefix {
+				kind = "PRE"
+			}
+			parts = append(parts, kind, obj.Loc.Loc())
+			if c.count && obj.IsPrefix {
+				parts = append(parts, formatPrefixItems(obj.PrefixItems))
+			} else if c.count {
+				parts = append(parts, "")
+			}
+		} else if obj.IsPrefix {
 			parts = append(parts, "PRE", "", "", obj.Loc.Loc())
 			if c.expanded {
 				parts = append(parts, "", "")
 			}
+			if c.count {
+				parts = append(parts, formatPrefixItems(obj.PrefixItems))
+			}
 		} else {
 			parts = append(parts, "OBJ", formatTime(c.utc, obj.Created), obj.ContentLength, obj.Loc.Loc())
 			if c.expanded {
 				parts = append(parts, formatTime(c.utc, obj.Expires), sumMetadataSize(obj.Metadata))
 			}
+			if c.count {
+				parts = append(parts, "")
+			}
 		}
 
 		tw.WriteLine(parts...)
@@ -146,6 +187,13 @@ func formatTime(utc bool, x time.Time) string {
 	return x.Format("2006-01-02 15:04:05")
 }
 
+func formatPrefixItems(n int) string {
+	if n > ulfs.PrefixItemsLimit {
+		return fmt.Sprintf("%d+", ulfs.PrefixItemsLimit)
+	}
+	return strconv.Itoa(n)
+}
+
 func sumMetadataSize(md uplink.CustomMetadata) int {
 	size := 0
 	for k, v := range md {
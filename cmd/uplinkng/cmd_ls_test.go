@@ -16,6 +16,22 @@ func TestLsErrors(t *testing.T) {
 	state.Fail(t, "ls", "sj:///user")
 }
 
+func TestLsBuckets(t *testing.T) {
+	state := ultest.Setup(commands,
+		ultest.WithBucket("zzz"),
+		ultest.WithBucket("aaa"),
+		ultest.WithFile("sj://mmm/file"),
+		ultest.WithFile("/home/user/file"),
+	)
+
+	state.Succeed(t, "ls").RequireStdout(t, `
+		CREATED    NAME
+		           aaa
+		           mmm
+		           zzz
+	`)
+}
+
 func TestLsRemote(t *testing.T) {
 	state := ultest.Setup(commands,
 		ultest.WithFile("sj://user/deep/aaa/bbb/1"),
@@ -88,6 +104,60 @@ func TestLsRemote(t *testing.T) {
 	})
 }
 
+func TestLsCountPrefixItems(t *testing.T) {
+	state := ultest.Setup(commands,
+		ultest.WithFile("sj://user/deep/aaa/bbb/1"),
+		ultest.WithFile("sj://user/deep/aaa/bbb/2"),
+		ultest.WithFile("sj://user/deep/ccc"),
+		ultest.WithFile("sj://user/foobar/1"),
+		ultest.WithFile("sj://user/top"),
+	)
+
+	state.Succeed(t, "ls", "sj://user", "--count-prefix-items", "--utc").RequireStdout(t, `
+		KIND    CREATED                SIZE    KEY        ITEMS
+		PRE                                    deep/      3
+		PRE                                    foobar/    1
+		OBJ     1970-01-01 00:00:05    0       top
+	`)
+}
+
+func TestLsKeysOnly(t *testing.T) {
+	state := ultest.Setup(commands,
+		ultest.WithFile("sj://user/deep/aaa/bbb/1"),
+		ultest.WithFile("sj://user/deep/ccc"),
+		ultest.WithFile("sj://user/top"),
+	)
+
+	t.Run("Basic", func(t *testing.T) {
+		state.Succeed(t, "ls", "sj://user", "--keys-only").RequireStdout(t, `
+			KIND    KEY
+			PRE     deep/
+			OBJ     top
+		`)
+	})
+
+	t.Run("Recursive", func(t *testing.T) {
+		state.Succeed(t, "ls", "sj://user", "--keys-only", "--recursive").RequireStdout(t, `
+			KIND    KEY
+			OBJ     deep/aaa/bbb/1
+			OBJ     deep/ccc
+			OBJ     top
+		`)
+	})
+
+	t.Run("CountPrefixItems", func(t *testing.T) {
+		state.Succeed(t, "ls", "sj://user", "--keys-only", "--count-prefix-items").RequireStdout(t, `
+			KIND    KEY      ITEMS
+			PRE     deep/    2
+			OBJ     top
+		`)
+	})
+
+	t.Run("Expanded", func(t *testing.T) {
+		state.Fail(t, "ls", "sj://user", "--keys-only", "--expanded")
+	})
+}
+
 func TestLsPending(t *testing.T) {
 	state := ultest.Setup(commands,
 		ultest.WithPendingFile("sj://user/deep/aaa/bbb/1"),
@@ -108,7 +108,10 @@ func (c *cmdMv) Execute(ctx clingy.Context) error {
 			return errs.New("destination is a directory and cannot find base name for source %q", c.source)
 		}
 	}
-	c.dest = joinDestWith(c.dest, base)
+	c.dest, err = joinDestWith(c.dest, base)
+	if err != nil {
+		return err
+	}
 
 	return c.moveFile(ctx, fs, c.source, c.dest)
 }
@@ -162,7 +165,12 @@ func (c *cmdMv) moveRecursive(ctx clingy.Context, fs ulfs.Filesystem) error {
 		if err != nil {
 			return err
 		}
-		dest := joinDestWith(c.dest, rel)
+		dest, err := joinDestWith(c.dest, rel)
+		if err != nil {
+			fprintln(ctx.Stderr(), "Move", "failed:", err.Error())
+			addError(err)
+			continue
+		}
 
 		ok := limiter.Go(ctx, func() {
 			if c.progress {
@@ -70,20 +70,21 @@ func (c *cmdRm) Execute(ctx clingy.Context) error {
 	defer func() { _ = fs.Close() }()
 
 	if !c.recursive {
-		err := fs.Remove(ctx, c.location, &ulfs.RemoveOptions{
+		aborted, err := fs.Remove(ctx, c.location, &ulfs.RemoveOptions{
 			Pending: c.pending,
 		})
 		if err != nil {
 			return err
 		}
 
-		fmt.Fprintln(ctx.Stdout(), "removed", c.location)
+		fmt.Fprintln(ctx.Stdout(), c.removed(c.location, aborted))
 		return nil
 	}
 
 	iter, err := fs.List(ctx, c.location, &ulfs.ListOptions{
 		Recursive: true,
 		Pending:   c.pending,
+		KeysOnly:  true,
 	})
 	if err != nil {
 		return err
@@ -113,14 +114,14 @@ func (c *cmdRm) Execute(ctx clingy.Context) error {
 		loc := iter.Item().Loc
 
 		ok := limiter.Go(ctx, func() {
-			err := fs.Remove(ctx, loc, &ulfs.RemoveOptions{
+			aborted, err := fs.Remove(ctx, loc, &ulfs.RemoveOptions{
 				Pending: c.pending,
 			})
 			if err != nil {
 				fprintln(ctx.Stderr(), "remove", loc, "failed:", err.Error())
 				addError(err)
 			} else {
-				fprintln(ctx.Stdout(), "removed", loc)
+				fprintln(ctx.Stdout(), c.removed(loc, aborted))
 			}
 		})
 		if !ok {
@@ -137,3 +138,11 @@ func (c *cmdRm) Execute(ctx clingy.Context) error {
 	}
 	return nil
 }
+
+// removed describes what removing the location did.
+func (c *cmdRm) removed(loc ulloc.Location, aborted int) string {
+	if c.pending {
+		return fmt.Sprintf("removed %d pending uploads of %s", aborted, loc)
+	}
+	return fmt.Sprintf("removed %s", loc)
+}
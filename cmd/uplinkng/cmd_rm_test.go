@@ -48,7 +48,9 @@ func TestRmRemote(t *testing.T) {
 			ultest.WithPendingFile("sj://user/other_file1.txt"),
 		)
 
-		state.Succeed(t, "rm", "sj://user/files/file1.txt", "--pending").RequirePending(t,
+		state.Succeed(t, "rm", "sj://user/files/file1.txt", "--pending").RequireStdout(t, `
+			removed 1 pending uploads of sj://user/files/file1.txt
+		`).RequirePending(t,
 			ultest.File{Loc: "sj://user/files/file2.txt"},
 			ultest.File{Loc: "sj://user/other_file1.txt"},
 		)
@@ -4,16 +4,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/zeebo/clingy"
 	"github.com/zeebo/errs"
 	"golang.org/x/term"
+
+	"storj.io/uplink"
 )
 
 type external struct {
@@ -40,10 +44,18 @@ type external struct {
 		defaultName string            // default access name to use from accesses
 		accesses    map[string]string // map of all of the stored accesses
 	}
+
+	projects struct {
+		mu     sync.Mutex
+		open   func(ctx context.Context, access *uplink.Access) (*uplink.Project, error)
+		shared map[string]*sharedProject // open projects keyed by access and options
+	}
 }
 
 func newExternal() *external {
-	return &external{}
+	ex := &external{}
+	ex.projects.open = uplink.Config{UserAgent: uplinkCLIUserAgent}.OpenProject
+	return ex
 }
 
 func (ex *external) Setup(f clingy.Flags) {
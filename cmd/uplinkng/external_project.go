@@ -5,6 +5,9 @@ package main
 
 import (
 	"context"
+	"strconv"
+
+	"github.com/zeebo/errs"
 
 	"storj.io/storj/cmd/uplinkng/ulext"
 	"storj.io/storj/cmd/uplinkng/ulfs"
@@ -14,15 +17,36 @@ import (
 
 const uplinkCLIUserAgent = "uplink-cli"
 
+// sharedProject is a project and the number of outstanding handles to it.
+type sharedProject struct {
+	project *uplink.Project
+	refs    int
+}
+
 func (ex *external) OpenFilesystem(ctx context.Context, accessName string, options ...ulext.Option) (ulfs.Filesystem, error) {
+	opts := ulext.LoadOptions(options...)
+
 	project, err := ex.OpenProject(ctx, accessName, options...)
 	if err != nil {
 		return nil, err
 	}
-	return ulfs.NewMixed(ulfs.NewLocal(), ulfs.NewRemote(project)), nil
+	remote := ulfs.NewRemoteWithRelease(project.Project, project.Close)
+
+	if opts.SourceAccess == "" || opts.SourceAccess == accessName {
+		return ulfs.NewMixed(ulfs.NewLocal(), remote), nil
+	}
+
+	source, err := ex.OpenProject(ctx, opts.SourceAccess, options...)
+	if err != nil {
+		return nil, errs.Combine(err, project.Close())
+	}
+	return ulfs.NewMixedWithSource(ulfs.NewLocal(), remote, ulfs.NewRemoteWithRelease(source.Project, source.Close)), nil
 }
 
-func (ex *external) OpenProject(ctx context.Context, accessName string, options ...ulext.Option) (*uplink.Project, error) {
+// OpenProject returns a handle to a project for the access. Projects are
+// shared by every caller that uses the same access and options until all of
+// the handles to it are closed.
+func (ex *external) OpenProject(ctx context.Context, accessName string, options ...ulext.Option) (*ulext.Project, error) {
 	opts := ulext.LoadOptions(options...)
 
 	access, err := ex.OpenAccess(accessName)
@@ -30,15 +54,57 @@ func (ex *external) OpenProject(ctx context.Context, accessName string, options
 		return nil, err
 	}
 
-	if opts.EncryptionBypass {
-		if err := privateAccess.EnablePathEncryptionBypass(access); err != nil {
+	serialized, err := access.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	key := strconv.FormatBool(opts.EncryptionBypass) + ":" + serialized
+
+	ex.projects.mu.Lock()
+	defer ex.projects.mu.Unlock()
+
+	shared, ok := ex.projects.shared[key]
+	if !ok {
+		if opts.EncryptionBypass {
+			if err := privateAccess.EnablePathEncryptionBypass(access); err != nil {
+				return nil, err
+			}
+		}
+
+		project, err := ex.projects.open(ctx, access)
+		if err != nil {
 			return nil, err
 		}
+
+		if ex.projects.shared == nil {
+			ex.projects.shared = make(map[string]*sharedProject)
+		}
+		shared = &sharedProject{project: project}
+		ex.projects.shared[key] = shared
+	}
+	shared.refs++
+
+	return ulext.NewProject(shared.project, func() error {
+		return ex.releaseProject(key)
+	}), nil
+}
+
+// releaseProject drops a reference to the shared project for the key and
+// closes it if it was the last one.
+func (ex *external) releaseProject(key string) error {
+	ex.projects.mu.Lock()
+	defer ex.projects.mu.Unlock()
+
+	shared, ok := ex.projects.shared[key]
+	if !ok {
+		return errs.New("project released too many times")
 	}
 
-	config := uplink.Config{
-		UserAgent: uplinkCLIUserAgent,
+	shared.refs--
+	if shared.refs > 0 {
+		return nil
 	}
 
-	return config.OpenProject(ctx, access)
+	delete(ex.projects.shared, key)
+	return shared.project.Close()
 }
@@ -0,0 +1,78 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/sync2"
+	"storj.io/common/testcontext"
+	"storj.io/storj/cmd/uplinkng/ulext"
+	"storj.io/uplink"
+)
+
+func TestOpenProjectShared(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	ex := newExternal()
+	ex.access.loaded = true
+	ex.access.defaultName = "a"
+	ex.access.accesses = map[string]string{
+		"a": "12edqrJX1V243n5fWtUrwpMQXL8gKdY2wbyqRPSG3rsA1tzmZiQjtCyF896egifN2C2qdY6g5S1t6e8iDhMUon9Pb7HdecBFheAcvmN8652mqu8hRx5zcTUaRTWfFCKS2S6DHmTeqPUHJLEp6cJGXNHcdqegcKfeahVZGP4rTagHvFGEraXjYRJ3knAcWDGW6BxACqogEWez6r274JiUBfs4yRSbRNRqUEURd28CwDXMSHLRKKA7TEDKEdQ",
+		"b": "1QiUjN497AySNH4ZX3wJCUZZNGKzpJwmZ1EcjKGgNR3Z9ADLawZNJbHXqm6VjH71nbWRRX6KfR9HHCr8sH3G9LA8e9qGuqWqkPPeskbD3Z12y4NuyxzwHYvcTSxa3Xk35Ts3ESGvP4785Rgeu5H8BF4kDriic6tRVUTPcAaYGCbHJPC2AfyPijLg4zZ627EuzeuWuo12mWGWiAZW3JJaVwD4657UJTGaUcuQqZxsjA1eTDkNFRfbv7zt9nW5si3E8FC6ZZFQ",
+	}
+
+	var mu sync.Mutex
+	opened := 0
+	open := ex.projects.open
+	ex.projects.open = func(ctx context.Context, access *uplink.Access) (*uplink.Project, error) {
+		mu.Lock()
+		opened++
+		mu.Unlock()
+		return open(ctx, access)
+	}
+
+	// like a sync, list the source and destination concurrently while also
+	// holding a project open for auxiliary work.
+	project, err := ex.OpenProject(ctx, "a")
+	require.NoError(t, err)
+
+	limiter := sync2.NewLimiter(2)
+	for i := 0; i < 2; i++ {
+		limiter.Go(ctx, func() {
+			fs, err := ex.OpenFilesystem(ctx, "a")
+			if assert.NoError(t, err) {
+				assert.NoError(t, fs.Close())
+			}
+		})
+	}
+	limiter.Wait()
+
+	require.Equal(t, 1, opened)
+	require.Len(t, ex.projects.shared, 1)
+
+	// closing the handle more than once only releases it once.
+	require.NoError(t, project.Close())
+	require.NoError(t, project.Close())
+	require.Len(t, ex.projects.shared, 0)
+
+	// different accesses and options get different projects.
+	projectA, err := ex.OpenProject(ctx, "a")
+	require.NoError(t, err)
+	projectB, err := ex.OpenProject(ctx, "b")
+	require.NoError(t, err)
+	projectBypass, err := ex.OpenProject(ctx, "a", ulext.BypassEncryption(true))
+	require.NoError(t, err)
+	require.Equal(t, 4, opened)
+
+	require.NoError(t, projectA.Close())
+	require.NoError(t, projectB.Close())
+	require.NoError(t, projectBypass.Close())
+	require.Len(t, ex.projects.shared, 0)
+}
@@ -6,6 +6,7 @@ package ulext
 
 import (
 	"context"
+	"sync"
 
 	"github.com/zeebo/clingy"
 
@@ -17,7 +18,7 @@ import (
 // any external state.
 type External interface {
 	OpenFilesystem(ctx context.Context, accessName string, options ...Option) (ulfs.Filesystem, error)
-	OpenProject(ctx context.Context, accessName string, options ...Option) (*uplink.Project, error)
+	OpenProject(ctx context.Context, accessName string, options ...Option) (*Project, error)
 
 	AccessInfoFile() string
 	OpenAccess(accessName string) (access *uplink.Access, err error)
@@ -32,9 +33,35 @@ type External interface {
 	PromptSecret(ctx clingy.Context, prompt string) (secret string, err error)
 }
 
+// Project is a handle to an *uplink.Project that may be shared with every other
+// user of the same access during a single command invocation. Closing the
+// handle releases it, and the project is closed once every handle is released.
+type Project struct {
+	*uplink.Project
+
+	once    sync.Once
+	release func() error
+	err     error
+}
+
+// NewProject returns a handle to the project that calls release when closed.
+func NewProject(project *uplink.Project, release func() error) *Project {
+	return &Project{
+		Project: project,
+		release: release,
+	}
+}
+
+// Close releases the handle. It is safe to call more than once.
+func (p *Project) Close() error {
+	p.once.Do(func() { p.err = p.release() })
+	return p.err
+}
+
 // Options contains all of the possible options for opening a filesystem or project.
 type Options struct {
 	EncryptionBypass bool
+	SourceAccess     string
 }
 
 // LoadOptions takes a slice of Option values and returns a filled out Options struct.
@@ -54,3 +81,10 @@ type Option struct {
 func BypassEncryption(bypass bool) Option {
 	return Option{apply: func(opt *Options) { opt.EncryptionBypass = bypass }}
 }
+
+// SourceAccess causes remote reads of a filesystem to use the provided access
+// name or value, while remote writes continue to use the main access. An empty
+// access means that the main access is used for both.
+func SourceAccess(access string) Option {
+	return Option{apply: func(opt *Options) { opt.SourceAccess = access }}
+}
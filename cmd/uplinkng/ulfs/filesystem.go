@@ -5,6 +5,8 @@ package ulfs
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"time"
 
@@ -19,28 +21,96 @@ type ListOptions struct {
 	Recursive bool
 	Pending   bool
 	Expanded  bool
+
+	// CountPrefixItems causes every collapsed prefix in a non-recursive
+	// listing to report how many objects exist beneath it, up to
+	// PrefixItemsLimit.
+	CountPrefixItems bool
+
+	// PendingDetails causes a listing of pending uploads to fetch the parts
+	// of every upload so that ContentLength and Parts are filled in. It costs
+	// an extra request per upload.
+	PendingDetails bool
+
+	// KeysOnly skips fetching anything but the keys of remote objects. The
+	// creation time, size, expiration, and metadata of the returned
+	// ObjectInfos are left zero.
+	KeysOnly bool
+
+	// AllVersions causes every version of each object to be listed instead
+	// of only the latest. It is an error if the backend does not support
+	// versions.
+	AllVersions bool
 }
 
 func (lo *ListOptions) isRecursive() bool { return lo != nil && lo.Recursive }
 func (lo *ListOptions) isPending() bool   { return lo != nil && lo.Pending }
+func (lo *ListOptions) isExpanded() bool  { return lo != nil && lo.Expanded }
+func (lo *ListOptions) keysOnly() bool    { return lo != nil && lo.KeysOnly }
+func (lo *ListOptions) allVersions() bool {
+	return lo != nil && lo.AllVersions
+}
+func (lo *ListOptions) pendingDetails() bool {
+	return lo != nil && lo.Pending && lo.PendingDetails
+}
+func (lo *ListOptions) countPrefixItems() bool {
+	return lo != nil && lo.CountPrefixItems && !lo.Recursive
+}
+
+// PrefixItemsLimit is the largest number of objects that will be counted
+// beneath a collapsed prefix. Counts larger than this are reported as
+// PrefixItemsLimit+1.
+const PrefixItemsLimit = 1000
+
+// OpenOptions describes options to the Open command.
+type OpenOptions struct {
+	// Version selects a specific version of the object instead of the latest.
+	Version []byte
+}
+
+func (oo *OpenOptions) version() []byte {
+	if oo == nil {
+		return nil
+	}
+	return oo.Version
+}
 
 // RemoveOptions describes options to the Remove command.
 type RemoveOptions struct {
+	// Pending aborts every pending upload of the key instead of removing the
+	// object. Remove returns the number of aborted uploads.
 	Pending bool
+
+	// Version selects a specific version of the object to remove instead of
+	// the latest.
+	Version []byte
 }
 
 func (ro *RemoveOptions) isPending() bool { return ro != nil && ro.Pending }
+func (ro *RemoveOptions) version() []byte {
+	if ro == nil {
+		return nil
+	}
+	return ro.Version
+}
+
+// ErrVersionsUnsupported is returned when an operation asks for a specific
+// object version, or for all versions, from a backend that does not have them.
+var ErrVersionsUnsupported = errors.New("object versions are not supported")
 
 // Filesystem represents either the local Filesystem or the data backed by a project.
 type Filesystem interface {
 	Close() error
-	Open(ctx clingy.Context, loc ulloc.Location) (MultiReadHandle, error)
+	Open(ctx clingy.Context, loc ulloc.Location, opts *OpenOptions) (MultiReadHandle, error)
 	Create(ctx clingy.Context, loc ulloc.Location) (MultiWriteHandle, error)
 	Move(ctx clingy.Context, source, dest ulloc.Location) error
-	Remove(ctx context.Context, loc ulloc.Location, opts *RemoveOptions) error
+	Remove(ctx context.Context, loc ulloc.Location, opts *RemoveOptions) (aborted int, err error)
 	List(ctx context.Context, prefix ulloc.Location, opts *ListOptions) (ObjectIterator, error)
+	ListBuckets(ctx context.Context) BucketIterator
 	IsLocalDir(ctx context.Context, loc ulloc.Location) bool
+	IsRemoteDir(ctx context.Context, loc ulloc.Location) (bool, error)
 	Stat(ctx context.Context, loc ulloc.Location) (*ObjectInfo, error)
+	StatDestination(ctx context.Context, loc ulloc.Location) (*ObjectInfo, error)
 }
 
 //
@@ -56,21 +126,48 @@ type ObjectInfo struct {
 	ContentLength int64
 	Expires       time.Time
 	Metadata      uplink.CustomMetadata
+
+	// Modified is the time the contents of the object were last changed and
+	// ETag is an opaque value that changes whenever the contents do. Neither
+	// are guaranteed to be set for every kind of ObjectInfo.
+	Modified time.Time
+	ETag     []byte
+
+	// PrefixItems is the number of objects beneath the prefix when IsPrefix
+	// is true and the listing requested CountPrefixItems. It is capped at
+	// PrefixItemsLimit+1.
+	PrefixItems int
+
+	// UploadID identifies the upload when the ObjectInfo is for a pending
+	// upload. Parts is the number of committed parts of the upload and is
+	// only set when the listing requested PendingDetails.
+	UploadID string
+	Parts    int
+
+	// Version identifies the version of the object when the backend supports
+	// versions. It is nil otherwise.
+	Version []byte
 }
 
 // uplinkObjectToObjectInfo returns an objectInfo converted from an *uplink.Object.
 func uplinkObjectToObjectInfo(bucket string, obj *uplink.Object) ObjectInfo {
-	return ObjectInfo{
+	info := ObjectInfo{
 		Loc:           ulloc.NewRemote(bucket, obj.Key),
 		IsPrefix:      obj.IsPrefix,
 		Created:       obj.System.Created,
 		ContentLength: obj.System.ContentLength,
 		Expires:       obj.System.Expires,
 		Metadata:      obj.Custom,
+		Modified:      obj.System.Created,
 	}
+	// system metadata is not fetched for keys only listings.
+	if !obj.System.Created.IsZero() {
+		info.ETag = statETag(obj.System.Created, obj.System.ContentLength)
+	}
+	return info
 }
 
-// uplinkUploadInfoToObjectInfo returns an objectInfo converted from an *uplink.Object.
+// uplinkUploadInfoToObjectInfo returns an objectInfo converted from an *uplink.UploadInfo.
 func uplinkUploadInfoToObjectInfo(bucket string, upl *uplink.UploadInfo) ObjectInfo {
 	return ObjectInfo{
 		Loc:           ulloc.NewRemote(bucket, upl.Key),
@@ -79,9 +176,29 @@ func uplinkUploadInfoToObjectInfo(bucket string, upl *uplink.UploadInfo) ObjectI
 		ContentLength: upl.System.ContentLength,
 		Expires:       upl.System.Expires,
 		Metadata:      upl.Custom,
+		UploadID:      upl.UploadID,
 	}
 }
 
+// statETag returns an ETag derived from the modification time and size of
+// some data. Objects are immutable once committed and files are assumed to not
+// be rewritten without their modification time changing, so this is sufficient
+// to detect when contents differ without reading them.
+func statETag(modified time.Time, size int64) []byte {
+	return []byte(fmt.Sprintf("%x-%x", modified.UnixNano(), size))
+}
+
+//
+// bucket info
+//
+
+// BucketInfo is a simpler *uplink.Bucket that contains the minimal information the
+// uplink command needs.
+type BucketInfo struct {
+	Name    string
+	Created time.Time
+}
+
 //
 // read handles
 //
@@ -100,8 +217,28 @@ type MultiReadHandle interface {
 	Info(ctx context.Context) (*ObjectInfo, error)
 }
 
+// RangeLength returns how many bytes of something of the given size are covered
+// by reading length bytes starting at offset, where a negative offset counts
+// back from the end and a negative length reads until the end.
+func RangeLength(size, offset, length int64) int64 {
+	if offset < 0 {
+		offset += size
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	if offset > size {
+		return 0
+	}
+	if length < 0 || offset+length > size {
+		length = size - offset
+	}
+	return length
+}
+
 // ReadHandle is something that can be read from distinct parts possibly
-// in parallel.
+// in parallel. The ContentLength reported by Info is the length of the part
+// while the rest of the information describes the whole object.
 type ReadHandle interface {
 	io.Closer
 	io.Reader
@@ -131,6 +268,13 @@ type WriteHandle interface {
 	Abort() error
 }
 
+// ProgressReporter is implemented by handles that can cheaply report how many
+// bytes have passed through them so far. It is safe to call concurrently with
+// reads and writes.
+type ProgressReporter interface {
+	Progress() int64
+}
+
 //
 // object iteration
 //
@@ -141,3 +285,14 @@ type ObjectIterator interface {
 	Err() error
 	Item() ObjectInfo
 }
+
+//
+// bucket iteration
+//
+
+// BucketIterator is an interface type for iterating over bucketInfo values.
+type BucketIterator interface {
+	Next() bool
+	Err() error
+	Item() BucketInfo
+}
@@ -26,6 +26,8 @@ func newOSMultiReadHandle(fh *os.File) (MultiReadHandle, error) {
 		IsPrefix:      false,
 		Created:       fi.ModTime(), // TODO: os specific crtime
 		ContentLength: fi.Size(),
+		Modified:      fi.ModTime(),
+		ETag:          statETag(fi.ModTime(), fi.Size()),
 	}), nil
 }
 
@@ -93,6 +93,7 @@ func (o *GenericMultiReadHandle) NextPart(ctx context.Context, length int64) (Re
 	r := &genericReadHandle{
 		r:    o.r,
 		info: o.info,
+		size: length,
 		off:  o.off,
 		len:  length,
 	}
@@ -110,12 +111,18 @@ func (o *GenericMultiReadHandle) Info(ctx context.Context) (*ObjectInfo, error)
 type genericReadHandle struct {
 	r    GenericReader
 	info ObjectInfo
+	size int64
 	off  int64
 	len  int64
 }
 
-func (o *genericReadHandle) Close() error     { return nil }
-func (o *genericReadHandle) Info() ObjectInfo { return o.info }
+func (o *genericReadHandle) Close() error { return nil }
+
+func (o *genericReadHandle) Info() ObjectInfo {
+	info := o.info
+	info.ContentLength = o.size
+	return info
+}
 
 func (o *genericReadHandle) Read(p []byte) (int, error) {
 	if o.len <= 0 {
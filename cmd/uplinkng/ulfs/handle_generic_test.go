@@ -0,0 +1,65 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ulfs_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/storj/cmd/uplinkng/ulfs"
+)
+
+type nopClosingReader struct{ *bytes.Reader }
+
+func (nopClosingReader) Close() error { return nil }
+
+func TestGenericReadHandleRangedInfo(t *testing.T) {
+	ctx := context.Background()
+	modified := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mrh := ulfs.NewGenericMultiReadHandle(nopClosingReader{bytes.NewReader([]byte("abcdefghij"))}, ulfs.ObjectInfo{
+		ContentLength: 10,
+		Modified:      modified,
+		ETag:          []byte("etag"),
+	})
+
+	require.NoError(t, mrh.SetOffset(-4))
+	rh, err := mrh.NextPart(ctx, 3)
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadAll(rh)
+	require.NoError(t, err)
+	require.Equal(t, "ghi", string(data))
+
+	info := rh.Info()
+	require.Equal(t, int64(3), info.ContentLength)
+	require.Equal(t, modified, info.Modified)
+	require.Equal(t, []byte("etag"), info.ETag)
+
+	full, err := mrh.Info(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), full.ContentLength)
+}
+
+func TestRangeLength(t *testing.T) {
+	for _, tc := range []struct {
+		size, offset, length int64
+		expected             int64
+	}{
+		{10, 0, -1, 10},
+		{10, 2, -1, 8},
+		{10, 2, 3, 3},
+		{10, 8, 5, 2},
+		{10, -3, -1, 3},
+		{10, -30, -1, 10},
+		{10, 11, -1, 0},
+	} {
+		require.Equal(t, tc.expected, ulfs.RangeLength(tc.size, tc.offset, tc.length), "%+v", tc)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"io"
 	"sync"
+	"sync/atomic"
 
 	"github.com/zeebo/errs"
 
@@ -19,6 +20,7 @@ import (
 
 // stdMultiReadHandle implements MultiReadHandle for stdin.
 type stdMultiReadHandle struct {
+	read  int64 // atomic, first for alignment
 	stdin io.Reader
 	mu    sync.Mutex
 	curr  *stdReadHandle
@@ -67,6 +69,7 @@ func (o *stdMultiReadHandle) NextPart(ctx context.Context, length int64) (ReadHa
 
 	o.curr = &stdReadHandle{
 		stdin: o.stdin,
+		read:  &o.read,
 		len:   length,
 	}
 
@@ -77,9 +80,13 @@ func (o *stdMultiReadHandle) Info(ctx context.Context) (*ObjectInfo, error) {
 	return &ObjectInfo{ContentLength: -1}, nil
 }
 
+// Progress returns the number of bytes read from stdin so far.
+func (o *stdMultiReadHandle) Progress() int64 { return atomic.LoadInt64(&o.read) }
+
 // stdReadHandle implements ReadHandle for stdin.
 type stdReadHandle struct {
 	stdin  io.Reader
+	read   *int64 // atomic, shared with the parent
 	mu     sync.Mutex
 	done   sync2.Fence
 	err    error
@@ -115,6 +122,7 @@ func (o *stdReadHandle) Read(p []byte) (int, error) {
 
 	n, err := o.stdin.Read(p)
 	o.len -= int64(n)
+	atomic.AddInt64(o.read, int64(n))
 
 	if err != nil && o.err == nil {
 		o.err = err
@@ -134,8 +142,13 @@ func (o *stdReadHandle) Read(p []byte) (int, error) {
 //
 
 // stdMultiWriteHandle implements MultiWriteHandle for stdouts.
+//
+// Because data written to stdout cannot be taken back, Abort does not attempt
+// to undo or truncate anything already written: both Commit and Abort only
+// flush any buffered output and prevent further parts from being created.
 type stdMultiWriteHandle struct {
-	stdout io.Writer
+	written int64 // atomic, first for alignment
+	stdout  io.Writer
 
 	mu   sync.Mutex
 	next *sync.Mutex
@@ -164,11 +177,12 @@ func (s *stdMultiWriteHandle) NextPart(ctx context.Context, length int64) (Write
 	next.Lock()
 
 	w := &stdWriteHandle{
-		stdout: s.stdout,
-		mu:     s.next,
-		next:   next,
-		tail:   length < 0,
-		len:    length,
+		stdout:  s.stdout,
+		written: &s.written,
+		mu:      s.next,
+		next:    next,
+		tail:    length < 0,
+		len:     length,
 	}
 
 	s.tail = w.tail
@@ -178,28 +192,39 @@ func (s *stdMultiWriteHandle) NextPart(ctx context.Context, length int64) (Write
 }
 
 func (s *stdMultiWriteHandle) Commit(ctx context.Context) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.done = true
-	return nil
+	return s.finish()
 }
 
 func (s *stdMultiWriteHandle) Abort(ctx context.Context) error {
+	return s.finish()
+}
+
+func (s *stdMultiWriteHandle) finish() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.done {
+		return nil
+	}
 	s.done = true
+
+	if f, ok := s.stdout.(interface{ Flush() error }); ok {
+		return errs.Wrap(f.Flush())
+	}
 	return nil
 }
 
+// Progress returns the number of bytes written to stdout so far.
+func (s *stdMultiWriteHandle) Progress() int64 { return atomic.LoadInt64(&s.written) }
+
 // stdWriteHandle implements WriteHandle for stdouts.
 type stdWriteHandle struct {
-	stdout io.Writer
-	mu     *sync.Mutex
-	next   *sync.Mutex
-	tail   bool
-	len    int64
+	stdout  io.Writer
+	written *int64 // atomic, shared with the parent
+	mu      *sync.Mutex
+	next    *sync.Mutex
+	tail    bool
+	len     int64
 }
 
 func (s *stdWriteHandle) unlockNext() {
@@ -222,6 +247,7 @@ func (s *stdWriteHandle) Write(p []byte) (int, error) {
 	}
 
 	n, err := s.stdout.Write(p)
+	atomic.AddInt64(s.written, int64(n))
 
 	if !s.tail {
 		s.len -= int64(n)
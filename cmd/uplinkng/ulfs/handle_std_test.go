@@ -0,0 +1,106 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ulfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdMultiReadHandleProgress(t *testing.T) {
+	ctx := context.Background()
+
+	rh := newStdMultiReadHandle(strings.NewReader("hello world"))
+	require.Equal(t, int64(0), rh.Progress())
+
+	part, err := rh.NextPart(ctx, 5)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(part)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+	require.Equal(t, int64(5), rh.Progress())
+
+	part, err = rh.NextPart(ctx, 100)
+	require.NoError(t, err)
+	data, err = ioutil.ReadAll(part)
+	require.NoError(t, err)
+	require.Equal(t, " world", string(data))
+	require.Equal(t, int64(11), rh.Progress())
+}
+
+func TestStdMultiWriteHandleProgress(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	wh := newStdMultiWriteHandle(&buf)
+
+	part, err := wh.NextPart(ctx, 5)
+	require.NoError(t, err)
+	_, err = part.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, part.Commit())
+	require.Equal(t, int64(5), wh.Progress())
+
+	part, err = wh.NextPart(ctx, -1)
+	require.NoError(t, err)
+	_, err = part.Write([]byte(" world"))
+	require.NoError(t, err)
+	require.NoError(t, part.Commit())
+	require.Equal(t, int64(11), wh.Progress())
+
+	require.NoError(t, wh.Commit(ctx))
+	require.Equal(t, "hello world", buf.String())
+
+	_, err = wh.NextPart(ctx, 1)
+	require.Error(t, err)
+}
+
+func TestStdMultiWriteHandleCommitAbort(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("CommitFlushes", func(t *testing.T) {
+		var buf bytes.Buffer
+		bw := bufio.NewWriter(&buf)
+		wh := newStdMultiWriteHandle(bw)
+
+		part, err := wh.NextPart(ctx, -1)
+		require.NoError(t, err)
+		_, err = part.Write([]byte("data"))
+		require.NoError(t, err)
+		require.NoError(t, part.Commit())
+		require.Equal(t, "", buf.String())
+
+		require.NoError(t, wh.Commit(ctx))
+		require.Equal(t, "data", buf.String())
+
+		// a later abort is a no-op
+		require.NoError(t, wh.Abort(ctx))
+		require.Equal(t, "data", buf.String())
+	})
+
+	t.Run("AbortKeepsOutput", func(t *testing.T) {
+		var buf bytes.Buffer
+		bw := bufio.NewWriter(&buf)
+		wh := newStdMultiWriteHandle(bw)
+
+		part, err := wh.NextPart(ctx, -1)
+		require.NoError(t, err)
+		_, err = part.Write([]byte("partial"))
+		require.NoError(t, err)
+		require.NoError(t, part.Abort())
+
+		require.NoError(t, wh.Abort(ctx))
+		require.Equal(t, "partial", buf.String())
+		require.Equal(t, int64(7), wh.Progress())
+
+		_, err = wh.NextPart(ctx, -1)
+		require.Error(t, err)
+	})
+}
@@ -110,6 +110,7 @@ func (u *uplinkMultiReadHandle) NextPart(ctx context.Context, length int64) (Rea
 
 	return &uplinkReadHandle{
 		info: u.info,
+		size: RangeLength(u.info.ContentLength, opts.Offset, opts.Length),
 		dl:   dl,
 	}, nil
 }
@@ -144,12 +145,18 @@ func (u *uplinkMultiReadHandle) Info(ctx context.Context) (*ObjectInfo, error) {
 // uplinkReadHandle implements readHandle for *uplink.Downloads.
 type uplinkReadHandle struct {
 	info *ObjectInfo
+	size int64
 	dl   *uplink.Download
 }
 
 func (u *uplinkReadHandle) Read(p []byte) (int, error) { return u.dl.Read(p) }
 func (u *uplinkReadHandle) Close() error               { return u.dl.Close() }
-func (u *uplinkReadHandle) Info() ObjectInfo           { return *u.info }
+
+func (u *uplinkReadHandle) Info() ObjectInfo {
+	info := *u.info
+	info.ContentLength = u.size
+	return info
+}
 
 //
 // write handles
@@ -0,0 +1,103 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ulfs
+
+import (
+	"context"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/common/sync2"
+)
+
+const (
+	// uploadDetailsBatch is how many pending uploads are read ahead from the
+	// listing before their parts are fetched.
+	uploadDetailsBatch = 100
+
+	// uploadDetailsConcurrency is how many part listings happen at once.
+	uploadDetailsConcurrency = 10
+)
+
+// uploadDetailsIterator decorates an iterator of pending uploads by filling in
+// the part count and total size of every upload it returns.
+type uploadDetailsIterator struct {
+	ctx   context.Context
+	iter  ObjectIterator
+	parts func(ctx context.Context, info ObjectInfo) (int, int64, error)
+
+	batch []ObjectInfo
+	errs  []error
+	idx   int
+	done  bool
+	err   error
+}
+
+// newUploadDetailsIterator constructs an *uploadDetailsIterator that uses parts
+// to look up the parts of every pending upload in iter.
+func newUploadDetailsIterator(ctx context.Context, iter ObjectIterator, parts func(context.Context, ObjectInfo) (int, int64, error)) *uploadDetailsIterator {
+	return &uploadDetailsIterator{
+		ctx:   ctx,
+		iter:  iter,
+		parts: parts,
+	}
+}
+
+func (u *uploadDetailsIterator) Next() bool {
+	if u.err != nil {
+		return false
+	}
+	if u.idx+1 < len(u.batch) {
+		u.idx++
+		return true
+	}
+	if u.done {
+		return false
+	}
+
+	u.batch, u.idx = u.batch[:0], 0
+	for len(u.batch) < uploadDetailsBatch && u.iter.Next() {
+		u.batch = append(u.batch, u.iter.Item())
+	}
+	if len(u.batch) < uploadDetailsBatch {
+		u.done = true
+	}
+
+	u.fill()
+	if u.err != nil {
+		return false
+	}
+	return len(u.batch) > 0
+}
+
+// fill fetches the parts for the current batch concurrently.
+func (u *uploadDetailsIterator) fill() {
+	u.errs = append(u.errs[:0], make([]error, len(u.batch))...)
+
+	limiter := sync2.NewLimiter(uploadDetailsConcurrency)
+	for i := range u.batch {
+		if u.batch[i].IsPrefix {
+			continue
+		}
+
+		i := i
+		if !limiter.Go(u.ctx, func() {
+			info := &u.batch[i]
+			info.Parts, info.ContentLength, u.errs[i] = u.parts(u.ctx, *info)
+		}) {
+			break
+		}
+	}
+	limiter.Wait()
+
+	u.err = errs.Combine(append(u.errs, u.ctx.Err())...)
+}
+
+func (u *uploadDetailsIterator) Err() error {
+	return errs.Combine(u.err, u.iter.Err())
+}
+
+func (u *uploadDetailsIterator) Item() ObjectInfo {
+	return u.batch[u.idx]
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ulfs
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
+
+	"storj.io/storj/cmd/uplinkng/ulloc"
+)
+
+type sliceObjectIterator struct {
+	infos []ObjectInfo
+	idx   int
+}
+
+func (s *sliceObjectIterator) Next() bool {
+	if s.idx >= len(s.infos) {
+		return false
+	}
+	s.idx++
+	return true
+}
+
+func (s *sliceObjectIterator) Err() error       { return nil }
+func (s *sliceObjectIterator) Item() ObjectInfo { return s.infos[s.idx-1] }
+
+func TestUploadDetailsIterator(t *testing.T) {
+	ctx := context.Background()
+
+	// more than a single batch so that refilling is exercised.
+	var infos []ObjectInfo
+	for i := 0; i < uploadDetailsBatch*2+5; i++ {
+		infos = append(infos, ObjectInfo{
+			Loc:      ulloc.NewRemote("bucket", fmt.Sprintf("key%04d", i)),
+			UploadID: fmt.Sprint(i),
+		})
+	}
+	infos = append(infos, ObjectInfo{Loc: ulloc.NewRemote("bucket", "prefix/"), IsPrefix: true})
+
+	var calls int64
+	parts := func(ctx context.Context, info ObjectInfo) (int, int64, error) {
+		atomic.AddInt64(&calls, 1)
+		var n int
+		_, err := fmt.Sscan(info.UploadID, &n)
+		return n % 3, int64(n) * 10, err
+	}
+
+	iter := newUploadDetailsIterator(ctx, &sliceObjectIterator{infos: infos}, parts)
+
+	var got []ObjectInfo
+	for iter.Next() {
+		got = append(got, iter.Item())
+	}
+	require.NoError(t, iter.Err())
+	require.Len(t, got, len(infos))
+	require.Equal(t, int64(len(infos)-1), atomic.LoadInt64(&calls))
+
+	for i, info := range got[:len(got)-1] {
+		require.Equal(t, infos[i].Loc, info.Loc)
+		require.Equal(t, i%3, info.Parts)
+		require.Equal(t, int64(i)*10, info.ContentLength)
+	}
+	require.True(t, got[len(got)-1].IsPrefix)
+	require.Zero(t, got[len(got)-1].Parts)
+}
+
+func TestUploadDetailsIteratorError(t *testing.T) {
+	ctx := context.Background()
+
+	infos := []ObjectInfo{
+		{Loc: ulloc.NewRemote("bucket", "key1")},
+		{Loc: ulloc.NewRemote("bucket", "key2")},
+	}
+	parts := func(ctx context.Context, info ObjectInfo) (int, int64, error) {
+		return 0, 0, errs.New("boom")
+	}
+
+	iter := newUploadDetailsIterator(ctx, &sliceObjectIterator{infos: infos}, parts)
+	require.False(t, iter.Next())
+	require.Error(t, iter.Err())
+}
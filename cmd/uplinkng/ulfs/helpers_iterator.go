@@ -3,15 +3,26 @@
 
 package ulfs
 
-import "storj.io/storj/cmd/uplinkng/ulloc"
+import (
+	"github.com/zeebo/errs"
+
+	"storj.io/storj/cmd/uplinkng/ulloc"
+)
 
 // filteredObjectIterator removes any iteration entries that do not begin with the filter.
 // all entries must begin with the trim string which is removed before checking for the
 // filter.
+//
+// If count is set, it is used to fill in the PrefixItems field of any prefix
+// entries. It is called once for every prefix entry that Next moves to, and it
+// is passed the untrimmed location of the prefix.
 type filteredObjectIterator struct {
 	trim   ulloc.Location
 	filter ulloc.Location
 	iter   ObjectIterator
+	count  func(prefix ulloc.Location) (int, error)
+	item   ObjectInfo
+	err    error
 }
 
 func (f *filteredObjectIterator) Next() bool {
@@ -19,27 +30,51 @@ func (f *filteredObjectIterator) Next() bool {
 		if !f.iter.Next() {
 			return false
 		}
-		loc := f.iter.Item().Loc
-		if !loc.HasPrefix(f.trim) {
+		item := f.iter.Item()
+		if !item.Loc.HasPrefix(f.trim) {
 			return false
 		}
-		if loc.HasPrefix(f.filter.AsDirectoryish()) || loc == f.filter {
+		if item.Loc.HasPrefix(f.filter.AsDirectoryish()) || item.Loc == f.filter {
+			f.item = f.prepare(item)
 			return true
 		}
 	}
 }
 
-func (f *filteredObjectIterator) Err() error { return f.iter.Err() }
-
-func (f *filteredObjectIterator) Item() ObjectInfo {
-	item := f.iter.Item()
+// prepare counts the items beneath a prefix entry and trims the location.
+func (f *filteredObjectIterator) prepare(item ObjectInfo) ObjectInfo {
+	if item.IsPrefix && f.count != nil {
+		n, err := f.count(item.Loc)
+		if err != nil && f.err == nil {
+			f.err = err
+		}
+		item.PrefixItems = n
+	}
 	item.Loc = item.Loc.RemovePrefix(f.trim)
 	return item
 }
 
+func (f *filteredObjectIterator) Err() error { return errs.Combine(f.err, f.iter.Err()) }
+
+func (f *filteredObjectIterator) Item() ObjectInfo { return f.item }
+
 // emptyObjectIterator is an objectIterator that has no objects.
 type emptyObjectIterator struct{}
 
 func (emptyObjectIterator) Next() bool       { return false }
 func (emptyObjectIterator) Err() error       { return nil }
 func (emptyObjectIterator) Item() ObjectInfo { return ObjectInfo{} }
+
+// errorObjectIterator is an objectIterator that has no objects and fails with err.
+type errorObjectIterator struct{ err error }
+
+func (errorObjectIterator) Next() bool       { return false }
+func (e errorObjectIterator) Err() error     { return e.err }
+func (errorObjectIterator) Item() ObjectInfo { return ObjectInfo{} }
+
+// emptyBucketIterator is a bucketIterator that has no buckets.
+type emptyBucketIterator struct{}
+
+func (emptyBucketIterator) Next() bool       { return false }
+func (emptyBucketIterator) Err() error       { return nil }
+func (emptyBucketIterator) Item() BucketInfo { return BucketInfo{} }
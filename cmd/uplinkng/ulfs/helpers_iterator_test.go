@@ -0,0 +1,47 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ulfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/storj/cmd/uplinkng/ulloc"
+)
+
+func TestFilteredObjectIteratorCount(t *testing.T) {
+	trim := ulloc.NewRemote("bucket", "dir/")
+	infos := []ObjectInfo{
+		{Loc: ulloc.NewRemote("bucket", "dir/a/"), IsPrefix: true},
+		{Loc: ulloc.NewRemote("bucket", "dir/b")},
+		{Loc: ulloc.NewRemote("bucket", "dir/c/"), IsPrefix: true},
+	}
+
+	counted := map[ulloc.Location]int{}
+	iter := &filteredObjectIterator{
+		trim:   trim,
+		filter: trim,
+		iter:   &sliceObjectIterator{infos: infos},
+		count: func(prefix ulloc.Location) (int, error) {
+			counted[prefix]++
+			return len(counted) * 10, nil
+		},
+	}
+
+	var got []ObjectInfo
+	for iter.Next() {
+		// asking for the item again must not count the prefix again.
+		require.Equal(t, iter.Item(), iter.Item())
+		got = append(got, iter.Item())
+	}
+	require.NoError(t, iter.Err())
+
+	require.Equal(t, map[ulloc.Location]int{infos[0].Loc: 1, infos[2].Loc: 1}, counted)
+	require.Len(t, got, 3)
+	require.Equal(t, ulloc.NewRemote("bucket", "a/"), got[0].Loc)
+	require.Equal(t, 10, got[0].PrefixItems)
+	require.Zero(t, got[1].PrefixItems)
+	require.Equal(t, 20, got[2].PrefixItems)
+}
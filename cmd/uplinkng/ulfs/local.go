@@ -5,6 +5,7 @@ package ulfs
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -25,7 +26,11 @@ func NewLocal() *Local {
 }
 
 // Open returns a read ReadHandle for the given local path.
-func (l *Local) Open(ctx context.Context, path string) (MultiReadHandle, error) {
+func (l *Local) Open(ctx context.Context, path string, opts *OpenOptions) (MultiReadHandle, error) {
+	if opts.version() != nil {
+		return nil, errs.Wrap(ErrVersionsUnsupported)
+	}
+
 	fh, err := os.Open(path)
 	if err != nil {
 		return nil, errs.Wrap(err)
@@ -60,23 +65,30 @@ func (l *Local) Move(ctx context.Context, oldpath, newpath string) error {
 }
 
 // Remove unlinks the file at the path. It is not an error if the file does not exist.
-func (l *Local) Remove(ctx context.Context, path string, opts *RemoveOptions) error {
+// There are no pending uploads of local files.
+func (l *Local) Remove(ctx context.Context, path string, opts *RemoveOptions) (int, error) {
+	if opts.version() != nil {
+		return 0, errs.Wrap(ErrVersionsUnsupported)
+	}
 	if opts.isPending() {
-		return nil
+		return 0, nil
 	}
 
 	if err := os.Remove(path); os.IsNotExist(err) {
-		return nil
+		return 0, nil
 	} else if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return 0, nil
 }
 
 // List returns an ObjectIterator listing files and directories that have string prefix
 // with the provided path.
 func (l *Local) List(ctx context.Context, path string, opts *ListOptions) (ObjectIterator, error) {
+	if opts.allVersions() {
+		return nil, errs.Wrap(ErrVersionsUnsupported)
+	}
 	if opts.isPending() {
 		return emptyObjectIterator{}, nil
 	}
@@ -129,14 +141,51 @@ func (l *Local) List(ctx context.Context, path string, opts *ListOptions) (Objec
 		trim = ulloc.NewLocal(prefix)
 	}
 
-	return &filteredObjectIterator{
+	filtered := &filteredObjectIterator{
 		trim:   trim,
 		filter: ulloc.NewLocal(prefix),
 		iter: &fileinfoObjectIterator{
 			base:  prefix,
 			files: files,
 		},
-	}, nil
+	}
+	if opts.countPrefixItems() {
+		filtered.count = l.countPrefixItems
+	}
+	return filtered, nil
+}
+
+// errPrefixItemsLimit is used to stop walking a directory once enough files
+// have been counted.
+var errPrefixItemsLimit = errs.New("prefix items limit reached")
+
+// countPrefixItems returns the number of files beneath the directory, stopping
+// once more than PrefixItemsLimit have been found.
+func (l *Local) countPrefixItems(prefix ulloc.Location) (int, error) {
+	path, ok := prefix.LocalParts()
+	if !ok {
+		return 0, errs.New("unable to count items for non-local prefix %q", prefix)
+	}
+
+	count := 0
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count++
+			if count > PrefixItemsLimit {
+				return errPrefixItemsLimit
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPrefixItemsLimit) {
+		return count, errs.Wrap(err)
+	}
+	return count, nil
+}
+
+// ListBuckets returns an empty BucketIterator because there are no local buckets.
+func (l *Local) ListBuckets(ctx context.Context) BucketIterator {
+	return emptyBucketIterator{}
 }
 
 // IsLocalDir returns true if the path is a directory.
@@ -159,6 +208,8 @@ func (l *Local) Stat(ctx context.Context, path string) (*ObjectInfo, error) {
 		Loc:           ulloc.NewLocal(path),
 		Created:       fi.ModTime(),
 		ContentLength: fi.Size(),
+		Modified:      fi.ModTime(),
+		ETag:          statETag(fi.ModTime(), fi.Size()),
 	}, nil
 }
 
@@ -196,5 +247,7 @@ func (fi *fileinfoObjectIterator) Item() ObjectInfo {
 		IsPrefix:      isDir,
 		Created:       fi.current.ModTime(), // TODO: use real crtime
 		ContentLength: fi.current.Size(),
+		Modified:      fi.current.ModTime(),
+		ETag:          statETag(fi.current.ModTime(), fi.current.Size()),
 	}
 }
@@ -0,0 +1,30 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ulfs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalVersionsUnsupported(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+
+	local := NewLocal()
+
+	_, err := local.Open(ctx, path, &OpenOptions{Version: []byte("v1")})
+	require.True(t, errors.Is(err, ErrVersionsUnsupported))
+
+	_, err = local.Remove(ctx, path, &RemoveOptions{Version: []byte("v1")})
+	require.True(t, errors.Is(err, ErrVersionsUnsupported))
+
+	_, err = local.List(ctx, dir+"/", &ListOptions{AllVersions: true})
+	require.True(t, errors.Is(err, ErrVersionsUnsupported))
+}
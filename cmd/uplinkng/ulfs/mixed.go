@@ -13,9 +13,14 @@ import (
 )
 
 // Mixed dispatches to either the local or remote filesystem depending on the location.
+//
+// If a separate source remote filesystem is provided, every operation that reads
+// remote objects (Open, List, and Stat) uses it, while operations that modify remote
+// data, and listing the buckets, use the main remote filesystem.
 type Mixed struct {
 	local  *Local
 	remote *Remote
+	source *Remote
 }
 
 // NewMixed returns a Mixed backed by the provided local and remote filesystems.
@@ -23,20 +28,36 @@ func NewMixed(local *Local, remote *Remote) *Mixed {
 	return &Mixed{
 		local:  local,
 		remote: remote,
+		source: remote,
+	}
+}
+
+// NewMixedWithSource returns a Mixed backed by the provided local and remote
+// filesystems where remote reads are served by the source filesystem.
+func NewMixedWithSource(local *Local, remote, source *Remote) *Mixed {
+	return &Mixed{
+		local:  local,
+		remote: remote,
+		source: source,
 	}
 }
 
 // Close releases any resources that the Mixed contails.
 func (m *Mixed) Close() error {
+	if m.source != m.remote {
+		return errs.Combine(m.remote.Close(), m.source.Close())
+	}
 	return m.remote.Close()
 }
 
 // Open returns a MultiReadHandle to either a local file, remote object, or stdin.
-func (m *Mixed) Open(ctx clingy.Context, loc ulloc.Location) (MultiReadHandle, error) {
+func (m *Mixed) Open(ctx clingy.Context, loc ulloc.Location, opts *OpenOptions) (MultiReadHandle, error) {
 	if bucket, key, ok := loc.RemoteParts(); ok {
-		return m.remote.Open(ctx, bucket, key)
+		return m.source.Open(ctx, bucket, key, opts)
 	} else if path, ok := loc.LocalParts(); ok {
-		return m.local.Open(ctx, path)
+		return m.local.Open(ctx, path, opts)
+	} else if opts.version() != nil {
+		return nil, errs.Wrap(ErrVersionsUnsupported)
 	}
 	return newStdMultiReadHandle(ctx.Stdin()), nil
 }
@@ -55,6 +76,9 @@ func (m *Mixed) Create(ctx clingy.Context, loc ulloc.Location) (MultiWriteHandle
 func (m *Mixed) Move(ctx clingy.Context, source, dest ulloc.Location) error {
 	if oldbucket, oldkey, ok := source.RemoteParts(); ok {
 		if newbucket, newkey, ok := dest.RemoteParts(); ok {
+			if m.source != m.remote {
+				return errs.New("moving objects between different accesses is not supported")
+			}
 			return m.remote.Move(ctx, oldbucket, oldkey, newbucket, newkey)
 		}
 	} else if oldpath, ok := source.LocalParts(); ok {
@@ -66,26 +90,31 @@ func (m *Mixed) Move(ctx clingy.Context, source, dest ulloc.Location) error {
 }
 
 // Remove deletes either a local file or remote object.
-func (m *Mixed) Remove(ctx context.Context, loc ulloc.Location, opts *RemoveOptions) error {
+func (m *Mixed) Remove(ctx context.Context, loc ulloc.Location, opts *RemoveOptions) (int, error) {
 	if bucket, key, ok := loc.RemoteParts(); ok {
 		return m.remote.Remove(ctx, bucket, key, opts)
 	} else if path, ok := loc.LocalParts(); ok {
 		return m.local.Remove(ctx, path, opts)
 	}
-	return nil
+	return 0, nil
 }
 
 // List lists either files and directories with some local path prefix or remote objects
 // with a given bucket and key.
 func (m *Mixed) List(ctx context.Context, prefix ulloc.Location, opts *ListOptions) (ObjectIterator, error) {
 	if bucket, key, ok := prefix.RemoteParts(); ok {
-		return m.remote.List(ctx, bucket, key, opts), nil
+		return m.source.List(ctx, bucket, key, opts), nil
 	} else if path, ok := prefix.LocalParts(); ok {
 		return m.local.List(ctx, path, opts)
 	}
 	return nil, errs.New("unable to list objects for prefix %q", prefix)
 }
 
+// ListBuckets lists the buckets of the main remote filesystem.
+func (m *Mixed) ListBuckets(ctx context.Context) BucketIterator {
+	return m.remote.ListBuckets(ctx)
+}
+
 // IsLocalDir returns true if the location is a directory that is local.
 func (m *Mixed) IsLocalDir(ctx context.Context, loc ulloc.Location) bool {
 	if path, ok := loc.LocalParts(); ok {
@@ -94,8 +123,29 @@ func (m *Mixed) IsLocalDir(ctx context.Context, loc ulloc.Location) bool {
 	return false
 }
 
+// IsRemoteDir returns true if the location is remote and other objects exist
+// beneath it in the main remote filesystem when treated as a prefix.
+func (m *Mixed) IsRemoteDir(ctx context.Context, loc ulloc.Location) (bool, error) {
+	if bucket, key, ok := loc.RemoteParts(); ok {
+		return m.remote.IsRemoteDir(ctx, bucket, key)
+	}
+	return false, nil
+}
+
 // Stat returns information about an object at the specified Location.
 func (m *Mixed) Stat(ctx context.Context, loc ulloc.Location) (*ObjectInfo, error) {
+	if bucket, key, ok := loc.RemoteParts(); ok {
+		return m.source.Stat(ctx, bucket, key)
+	} else if path, ok := loc.LocalParts(); ok {
+		return m.local.Stat(ctx, path)
+	}
+	return nil, errs.New("unable to stat loc %q", loc.Loc())
+}
+
+// StatDestination returns information about an object at the specified Location
+// when it is written to, so a remote object is looked up in the main remote
+// filesystem instead of the source one.
+func (m *Mixed) StatDestination(ctx context.Context, loc ulloc.Location) (*ObjectInfo, error) {
 	if bucket, key, ok := loc.RemoteParts(); ok {
 		return m.remote.Stat(ctx, bucket, key)
 	} else if path, ok := loc.LocalParts(); ok {
@@ -0,0 +1,74 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ulfs_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/clingy"
+
+	"storj.io/common/memory"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/storj/cmd/uplinkng/ulfs"
+	"storj.io/storj/cmd/uplinkng/ulloc"
+	"storj.io/storj/private/testplanet"
+)
+
+func TestMixedWithSource(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 4, UplinkCount: 2,
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		srcUplink, dstUplink := planet.Uplinks[0], planet.Uplinks[1]
+
+		require.NoError(t, srcUplink.Upload(ctx, satellite, "source", "dir/a", testrand.Bytes(memory.KiB)))
+		require.NoError(t, dstUplink.CreateBucket(ctx, satellite, "dest"))
+
+		srcProject, err := srcUplink.OpenProject(ctx, satellite)
+		require.NoError(t, err)
+		dstProject, err := dstUplink.OpenProject(ctx, satellite)
+		require.NoError(t, err)
+
+		fs := ulfs.NewMixedWithSource(ulfs.NewLocal(), ulfs.NewRemote(dstProject), ulfs.NewRemote(srcProject))
+		defer ctx.Check(fs.Close)
+
+		clctx := testClingyContext{Context: ctx}
+
+		// objects are read through the source access.
+		_, err = fs.Stat(ctx, ulloc.NewRemote("source", "dir/a"))
+		require.NoError(t, err)
+
+		// buckets are listed through the main access.
+		var buckets []string
+		iter := fs.ListBuckets(ctx)
+		for iter.Next() {
+			buckets = append(buckets, iter.Item().Name)
+		}
+		require.NoError(t, iter.Err())
+		require.Equal(t, []string{"dest"}, buckets)
+
+		// server-side moves cannot cross accesses.
+		err = fs.Move(clctx, ulloc.NewRemote("source", "dir/a"), ulloc.NewRemote("dest", "moved"))
+		require.Error(t, err)
+	})
+}
+
+// testClingyContext is a clingy.Context with no stdio for calling Mixed directly.
+type testClingyContext struct {
+	context.Context
+}
+
+var _ clingy.Context = testClingyContext{}
+
+func (testClingyContext) Read(p []byte) (int, error)  { return 0, io.EOF }
+func (testClingyContext) Write(p []byte) (int, error) { return len(p), nil }
+func (testClingyContext) Stdin() io.Reader            { return strings.NewReader("") }
+func (testClingyContext) Stdout() io.Writer           { return ioutil.Discard }
+func (testClingyContext) Stderr() io.Writer           { return ioutil.Discard }
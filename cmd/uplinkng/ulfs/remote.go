@@ -16,22 +16,33 @@ import (
 // Remote implements something close to a filesystem but backed by an uplink project.
 type Remote struct {
 	project *uplink.Project
+	release func() error
 }
 
 // NewRemote returns something close to a filesystem and returns objects using the project.
 func NewRemote(project *uplink.Project) *Remote {
+	return NewRemoteWithRelease(project, project.Close)
+}
+
+// NewRemoteWithRelease is like NewRemote except that closing the Remote calls
+// release instead of closing the project. This allows the project to be shared.
+func NewRemoteWithRelease(project *uplink.Project, release func() error) *Remote {
 	return &Remote{
 		project: project,
+		release: release,
 	}
 }
 
 // Close releases any resources that the Remote contains.
 func (r *Remote) Close() error {
-	return r.project.Close()
+	return r.release()
 }
 
 // Open returns a MultiReadHandle for the object identified by a given bucket and key.
-func (r *Remote) Open(ctx context.Context, bucket, key string) (MultiReadHandle, error) {
+func (r *Remote) Open(ctx context.Context, bucket, key string, opts *OpenOptions) (MultiReadHandle, error) {
+	if opts.version() != nil {
+		return nil, errs.Wrap(ErrVersionsUnsupported)
+	}
 	return newUplinkMultiReadHandle(r.project, bucket, key), nil
 }
 
@@ -59,34 +70,55 @@ func (r *Remote) Move(ctx context.Context, oldbucket, oldkey, newbucket, newkey
 	return errs.Wrap(r.project.MoveObject(ctx, oldbucket, oldkey, newbucket, newkey, nil))
 }
 
-// Remove deletes the object at the provided key and bucket.
-func (r *Remote) Remove(ctx context.Context, bucket, key string, opts *RemoveOptions) error {
+// Remove deletes the object at the provided key and bucket. When pending
+// uploads are removed, it returns how many of them were aborted.
+func (r *Remote) Remove(ctx context.Context, bucket, key string, opts *RemoveOptions) (int, error) {
+	if opts.version() != nil {
+		return 0, errs.Wrap(ErrVersionsUnsupported)
+	}
+
 	if !opts.isPending() {
 		_, err := r.project.DeleteObject(ctx, bucket, key)
 		if err != nil {
-			return errs.Wrap(err)
+			return 0, errs.Wrap(err)
 		}
-		return nil
+		return 0, nil
 	}
 
 	// TODO: we may need a dedicated endpoint for deleting pending object streams
 	list := r.project.ListUploads(ctx, bucket, &uplink.ListUploadsOptions{Prefix: key})
 
-	// TODO: modify when we can have several pending objects for the same object key
-	if list.Next() {
-		err := r.project.AbortUpload(ctx, bucket, key, list.Item().UploadID)
-		if err != nil {
-			return errs.Wrap(err)
+	// there may be several pending object streams for the same key, so
+	// abort every one of them that is an exact match for the key.
+	var group errs.Group
+	aborted, total := 0, 0
+	for list.Next() {
+		item := list.Item()
+		if item.Key != key {
+			continue
 		}
+		total++
+
+		if err := r.project.AbortUpload(ctx, bucket, key, item.UploadID); err != nil {
+			group.Add(err)
+			continue
+		}
+		aborted++
 	}
-	if err := list.Err(); err != nil {
-		return errs.Wrap(err)
+	group.Add(list.Err())
+
+	if err := group.Err(); err != nil {
+		return aborted, errs.New("aborted %d of %d pending uploads: %w", aborted, total, err)
 	}
-	return nil
+	return aborted, nil
 }
 
 // List lists all of the objects in some bucket that begin with the given prefix.
 func (r *Remote) List(ctx context.Context, bucket, prefix string, opts *ListOptions) ObjectIterator {
+	if opts.allVersions() {
+		return errorObjectIterator{err: errs.Wrap(ErrVersionsUnsupported)}
+	}
+
 	parentPrefix := ""
 	if idx := strings.LastIndexByte(prefix, '/'); idx >= 0 {
 		parentPrefix = prefix[:idx+1]
@@ -103,28 +135,126 @@ func (r *Remote) List(ctx context.Context, bucket, prefix string, opts *ListOpti
 			bucket,
 			r.project.ListUploads(ctx, bucket, &uplink.ListUploadsOptions{
 				Prefix:    parentPrefix,
-				Recursive: opts.Recursive,
-				System:    true,
-				Custom:    opts.Expanded,
+				Recursive: opts.isRecursive(),
+				System:    !opts.keysOnly(),
+				Custom:    opts.isExpanded() && !opts.keysOnly(),
 			}),
 		)
+		if opts.pendingDetails() {
+			// filter before fetching details so that uploads outside of the
+			// prefix do not cost a request each.
+			iter = newUploadDetailsIterator(ctx, &filteredObjectIterator{
+				trim:   ulloc.NewRemote(bucket, ""),
+				filter: ulloc.NewRemote(bucket, prefix),
+				iter:   iter,
+			}, r.uploadParts)
+		}
 	} else {
 		iter = newUplinkObjectIterator(
 			bucket,
 			r.project.ListObjects(ctx, bucket, &uplink.ListObjectsOptions{
 				Prefix:    parentPrefix,
-				Recursive: opts.Recursive,
-				System:    true,
-				Custom:    opts.Expanded,
+				Recursive: opts.isRecursive(),
+				System:    !opts.keysOnly(),
+				Custom:    opts.isExpanded() && !opts.keysOnly(),
 			}),
 		)
 	}
 
-	return &filteredObjectIterator{
+	filtered := &filteredObjectIterator{
 		trim:   trim,
 		filter: ulloc.NewRemote(bucket, prefix),
 		iter:   iter,
 	}
+	if opts.countPrefixItems() {
+		filtered.count = func(prefix ulloc.Location) (int, error) {
+			return r.countPrefixItems(ctx, prefix, opts.isPending())
+		}
+	}
+	return filtered
+}
+
+// countPrefixItems returns the number of objects or pending uploads beneath the
+// prefix, stopping once more than PrefixItemsLimit have been found.
+func (r *Remote) countPrefixItems(ctx context.Context, prefix ulloc.Location, pending bool) (int, error) {
+	bucket, key, ok := prefix.RemoteParts()
+	if !ok {
+		return 0, errs.New("unable to count items for non-remote prefix %q", prefix)
+	}
+
+	var iter ObjectIterator
+	if pending {
+		iter = newUplinkUploadIterator(bucket, r.project.ListUploads(ctx, bucket, &uplink.ListUploadsOptions{
+			Prefix:    key,
+			Recursive: true,
+		}))
+	} else {
+		iter = newUplinkObjectIterator(bucket, r.project.ListObjects(ctx, bucket, &uplink.ListObjectsOptions{
+			Prefix:    key,
+			Recursive: true,
+		}))
+	}
+
+	count := 0
+	for count <= PrefixItemsLimit && iter.Next() {
+		count++
+	}
+	return count, errs.Wrap(iter.Err())
+}
+
+// uploadParts returns the number of committed parts and their total size for
+// the pending upload.
+func (r *Remote) uploadParts(ctx context.Context, info ObjectInfo) (parts int, size int64, err error) {
+	bucket, key, ok := info.Loc.RemoteParts()
+	if !ok {
+		return 0, 0, errs.New("unable to list parts for non-remote location %q", info.Loc)
+	}
+
+	iter := r.project.ListUploadParts(ctx, bucket, key, info.UploadID, nil)
+	for iter.Next() {
+		parts++
+		size += iter.Item().Size
+	}
+	return parts, size, errs.Wrap(iter.Err())
+}
+
+// IsRemoteDir returns true if there are any objects beneath the key when treated
+// as a prefix.
+func (r *Remote) IsRemoteDir(ctx context.Context, bucket, key string) (bool, error) {
+	if key == "" {
+		return true, nil
+	}
+	if !strings.HasSuffix(key, "/") {
+		key += "/"
+	}
+
+	iter := r.project.ListObjects(ctx, bucket, &uplink.ListObjectsOptions{
+		Prefix: key,
+	})
+	if iter.Next() {
+		return true, nil
+	}
+	return false, errs.Wrap(iter.Err())
+}
+
+// ListBuckets lists all of the buckets in the project.
+func (r *Remote) ListBuckets(ctx context.Context) BucketIterator {
+	return &uplinkBucketIterator{iter: r.project.ListBuckets(ctx, nil)}
+}
+
+// uplinkBucketIterator implements bucketIterator for *uplink.BucketIterator.
+type uplinkBucketIterator struct {
+	iter *uplink.BucketIterator
+}
+
+func (u *uplinkBucketIterator) Next() bool { return u.iter.Next() }
+func (u *uplinkBucketIterator) Err() error { return u.iter.Err() }
+func (u *uplinkBucketIterator) Item() BucketInfo {
+	bucket := u.iter.Item()
+	return BucketInfo{
+		Name:    bucket.Name,
+		Created: bucket.Created,
+	}
 }
 
 // uplinkObjectIterator implements objectIterator for *uplink.ObjectIterator.
@@ -0,0 +1,160 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ulfs_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/memory"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/storj/cmd/uplinkng/ulfs"
+	"storj.io/storj/cmd/uplinkng/ulloc"
+	"storj.io/storj/private/testplanet"
+	"storj.io/storj/satellite/metabase"
+	"storj.io/uplink"
+)
+
+func TestRemoteRemovePending(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 0, UplinkCount: 1,
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		project, err := planet.Uplinks[0].OpenProject(ctx, planet.Satellites[0])
+		require.NoError(t, err)
+
+		_, err = project.CreateBucket(ctx, "bucket")
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err := project.BeginUpload(ctx, "bucket", "key", nil)
+			require.NoError(t, err)
+		}
+		_, err = project.BeginUpload(ctx, "bucket", "key2", nil)
+		require.NoError(t, err)
+
+		remote := ulfs.NewRemote(project)
+		defer ctx.Check(remote.Close)
+
+		aborted, err := remote.Remove(ctx, "bucket", "key", &ulfs.RemoveOptions{Pending: true})
+		require.NoError(t, err)
+		require.Equal(t, 3, aborted)
+
+		var keys []string
+		uploads := project.ListUploads(ctx, "bucket", &uplink.ListUploadsOptions{Recursive: true})
+		for uploads.Next() {
+			keys = append(keys, uploads.Item().Key)
+		}
+		require.NoError(t, uploads.Err())
+		require.Equal(t, []string{"key2"}, keys)
+	})
+}
+
+func TestRemoteListPendingDetails(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 4, UplinkCount: 1,
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		project, err := planet.Uplinks[0].OpenProject(ctx, planet.Satellites[0])
+		require.NoError(t, err)
+
+		_, err = project.CreateBucket(ctx, "bucket")
+		require.NoError(t, err)
+
+		upload, err := project.BeginUpload(ctx, "bucket", "key", nil)
+		require.NoError(t, err)
+
+		sizes := []memory.Size{memory.KiB, 10 * memory.KiB}
+		for i, size := range sizes {
+			part, err := project.UploadPart(ctx, "bucket", "key", upload.UploadID, uint32(i+1))
+			require.NoError(t, err)
+			_, err = part.Write(testrand.BytesInt(size.Int()))
+			require.NoError(t, err)
+			require.NoError(t, part.Commit())
+		}
+
+		remote := ulfs.NewRemote(project)
+		defer ctx.Check(remote.Close)
+
+		iter := remote.List(ctx, "bucket", "", &ulfs.ListOptions{
+			Pending:        true,
+			PendingDetails: true,
+		})
+		require.True(t, iter.Next())
+		info := iter.Item()
+		require.False(t, iter.Next())
+		require.NoError(t, iter.Err())
+
+		require.Equal(t, ulloc.NewRemote("bucket", "key"), info.Loc)
+		require.Equal(t, upload.UploadID, info.UploadID)
+		require.Equal(t, 2, info.Parts)
+		require.Equal(t, (sizes[0] + sizes[1]).Int64(), info.ContentLength)
+
+		// without the option the parts are not fetched.
+		iter = remote.List(ctx, "bucket", "", &ulfs.ListOptions{Pending: true})
+		require.True(t, iter.Next())
+		require.Equal(t, upload.UploadID, iter.Item().UploadID)
+		require.Zero(t, iter.Item().Parts)
+		require.NoError(t, iter.Err())
+
+	})
+}
+
+func TestRemoteListKeysOnly(t *testing.T) {
+	// enough objects for the satellite to return several pages.
+	numObjects := 2*int(metabase.ListLimit) + 1
+	if testing.Short() {
+		numObjects = 25
+	}
+
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 0, UplinkCount: 1,
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		project, err := planet.Uplinks[0].OpenProject(ctx, planet.Satellites[0])
+		require.NoError(t, err)
+
+		_, err = project.CreateBucket(ctx, "bucket")
+		require.NoError(t, err)
+
+		for i := 0; i < numObjects; i++ {
+			upload, err := project.UploadObject(ctx, "bucket", fmt.Sprintf("prefix/%05d", i), nil)
+			require.NoError(t, err)
+			require.NoError(t, upload.Commit())
+		}
+
+		remote := ulfs.NewRemote(project)
+		defer ctx.Check(remote.Close)
+
+		list := func(keysOnly bool) (infos []ulfs.ObjectInfo, elapsed time.Duration) {
+			start := time.Now()
+			iter := remote.List(ctx, "bucket", "prefix/", &ulfs.ListOptions{
+				Recursive: true,
+				KeysOnly:  keysOnly,
+			})
+			for iter.Next() {
+				infos = append(infos, iter.Item())
+			}
+			require.NoError(t, iter.Err())
+			return infos, time.Since(start)
+		}
+
+		full, fullElapsed := list(false)
+		keys, keysElapsed := list(true)
+
+		require.Len(t, full, numObjects)
+		require.Len(t, keys, numObjects)
+		for i := range keys {
+			require.Equal(t, full[i].Loc, keys[i].Loc)
+			require.False(t, full[i].Created.IsZero())
+			require.True(t, keys[i].Created.IsZero())
+			require.Nil(t, keys[i].ETag)
+		}
+
+		t.Logf("listed %d objects: %v with system metadata, %v keys only (%.0f vs %.0f objects/s)",
+			numObjects, fullElapsed, keysElapsed,
+			float64(numObjects)/fullElapsed.Seconds(), float64(numObjects)/keysElapsed.Seconds())
+	})
+}
@@ -0,0 +1,209 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ulfs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/zeebo/clingy"
+	"github.com/zeebo/errs"
+
+	"storj.io/common/rpc/rpcstatus"
+	"storj.io/storj/cmd/uplinkng/ulloc"
+	"storj.io/uplink"
+)
+
+// RetryConfig controls how a RetryingFilesystem retries operations.
+type RetryConfig struct {
+	// Attempts is the maximum number of times an operation is tried. Values
+	// less than 1 mean an operation is tried once.
+	Attempts int
+	// InitialBackoff is how long to wait before the first retry. It is
+	// doubled for every retry after that up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// RetryingFilesystem wraps a Filesystem and retries idempotent operations
+// (Open, List, Stat, and Remove) that fail with transient errors. Create and
+// Move are passed through untouched.
+type RetryingFilesystem struct {
+	Filesystem
+	config RetryConfig
+}
+
+// NewRetryingFilesystem returns a RetryingFilesystem wrapping fs.
+func NewRetryingFilesystem(fs Filesystem, config RetryConfig) *RetryingFilesystem {
+	return &RetryingFilesystem{
+		Filesystem: fs,
+		config:     config,
+	}
+}
+
+// retry calls fn until it succeeds, fails with an error that is not retryable,
+// the context is canceled, or the configured number of attempts is reached.
+func (r *RetryingFilesystem) retry(ctx context.Context, fn func() error) (err error) {
+	backoff := r.config.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= r.config.Attempts || !isRetryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if r.config.MaxBackoff > 0 && backoff > r.config.MaxBackoff {
+			backoff = r.config.MaxBackoff
+		}
+	}
+}
+
+// Open returns a MultiReadHandle for the location, retrying transient errors.
+func (r *RetryingFilesystem) Open(ctx clingy.Context, loc ulloc.Location, opts *OpenOptions) (mrh MultiReadHandle, err error) {
+	err = r.retry(ctx, func() (err error) {
+		mrh, err = r.Filesystem.Open(ctx, loc, opts)
+		return err
+	})
+	return mrh, err
+}
+
+// Remove removes the location, retrying transient errors.
+func (r *RetryingFilesystem) Remove(ctx context.Context, loc ulloc.Location, opts *RemoveOptions) (aborted int, err error) {
+	err = r.retry(ctx, func() (err error) {
+		aborted, err = r.Filesystem.Remove(ctx, loc, opts)
+		return err
+	})
+	return aborted, err
+}
+
+// Stat returns information about the location, retrying transient errors.
+func (r *RetryingFilesystem) Stat(ctx context.Context, loc ulloc.Location) (info *ObjectInfo, err error) {
+	err = r.retry(ctx, func() (err error) {
+		info, err = r.Filesystem.Stat(ctx, loc)
+		return err
+	})
+	return info, err
+}
+
+// StatDestination returns information about the location as a destination,
+// retrying transient errors.
+func (r *RetryingFilesystem) StatDestination(ctx context.Context, loc ulloc.Location) (info *ObjectInfo, err error) {
+	err = r.retry(ctx, func() (err error) {
+		info, err = r.Filesystem.StatDestination(ctx, loc)
+		return err
+	})
+	return info, err
+}
+
+// List lists the prefix, retrying transient errors. If iteration fails part of
+// the way through, the listing is restarted and the entries that were already
+// returned are skipped.
+func (r *RetryingFilesystem) List(ctx context.Context, prefix ulloc.Location, opts *ListOptions) (ObjectIterator, error) {
+	list := func() (iter ObjectIterator, err error) {
+		err = r.retry(ctx, func() (err error) {
+			iter, err = r.Filesystem.List(ctx, prefix, opts)
+			return err
+		})
+		return iter, err
+	}
+
+	iter, err := list()
+	if err != nil {
+		return nil, err
+	}
+
+	return &retryingObjectIterator{
+		ctx:  ctx,
+		fs:   r,
+		list: list,
+		iter: iter,
+	}, nil
+}
+
+// retryingObjectIterator restarts a listing that fails with a transient error.
+type retryingObjectIterator struct {
+	ctx      context.Context
+	fs       *RetryingFilesystem
+	list     func() (ObjectIterator, error)
+	iter     ObjectIterator
+	seen     int
+	restarts int
+	err      error
+}
+
+func (r *retryingObjectIterator) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	if r.iter.Next() {
+		r.seen++
+		return true
+	}
+	if err := r.iter.Err(); err == nil || !isRetryable(err) {
+		return false
+	} else if r.restarts++; r.restarts >= r.fs.config.Attempts {
+		return false
+	}
+
+	r.err = r.fs.retry(r.ctx, func() error {
+		iter, err := r.list()
+		if err != nil {
+			return err
+		}
+		for skip := 0; skip < r.seen; skip++ {
+			if !iter.Next() {
+				if err := iter.Err(); err != nil {
+					return err
+				}
+				return errs.New("listing changed while retrying")
+			}
+		}
+		r.iter = iter
+		return nil
+	})
+	if r.err != nil {
+		return false
+	}
+
+	return r.Next()
+}
+
+func (r *retryingObjectIterator) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.iter.Err()
+}
+
+func (r *retryingObjectIterator) Item() ObjectInfo { return r.iter.Item() }
+
+// isRetryable returns true if the error is likely to be transient.
+func isRetryable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, uplink.ErrTooManyRequests):
+		return true
+	}
+
+	switch rpcstatus.Code(err) {
+	case rpcstatus.Unavailable, rpcstatus.ResourceExhausted:
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,122 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ulfs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
+
+	"storj.io/common/rpc/rpcstatus"
+	"storj.io/storj/cmd/uplinkng/ulloc"
+	"storj.io/uplink"
+)
+
+func TestIsRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		retryable bool
+	}{
+		{nil, false},
+		{errs.New("generic"), false},
+		{uplink.ErrTooManyRequests, true},
+		{errs.Wrap(uplink.ErrTooManyRequests), true},
+		{fmt.Errorf("listing: %w", uplink.ErrTooManyRequests), true},
+		{rpcstatus.Error(rpcstatus.Unavailable, "satellite unavailable"), true},
+		{errs.Wrap(rpcstatus.Error(rpcstatus.ResourceExhausted, "slow down")), true},
+		{rpcstatus.Error(rpcstatus.NotFound, "not found"), false},
+		{errs.Wrap(uplink.ErrObjectNotFound), false},
+		{errs.Wrap(uplink.ErrPermissionDenied), false},
+		{&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, true},
+		{context.Canceled, false},
+		{errs.Wrap(context.DeadlineExceeded), false},
+	} {
+		require.Equal(t, tc.retryable, isRetryable(tc.err), "%v", tc.err)
+	}
+}
+
+type flakyFilesystem struct {
+	Filesystem
+	failures int
+	calls    int
+}
+
+func (f *flakyFilesystem) Remove(ctx context.Context, loc ulloc.Location, opts *RemoveOptions) (int, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return 0, errs.Wrap(uplink.ErrTooManyRequests)
+	}
+	return 0, nil
+}
+
+func (f *flakyFilesystem) List(ctx context.Context, prefix ulloc.Location, opts *ListOptions) (ObjectIterator, error) {
+	f.calls++
+	return &flakyObjectIterator{fail: f.calls <= f.failures}, nil
+}
+
+// flakyObjectIterator returns two items and then fails if fail is set,
+// otherwise it returns four items.
+type flakyObjectIterator struct {
+	fail bool
+	n    int
+}
+
+func (f *flakyObjectIterator) Next() bool {
+	if f.n >= 4 || (f.fail && f.n >= 2) {
+		return false
+	}
+	f.n++
+	return true
+}
+
+func (f *flakyObjectIterator) Err() error {
+	if f.fail && f.n >= 2 {
+		return rpcstatus.Error(rpcstatus.Unavailable, "flaky")
+	}
+	return nil
+}
+
+func (f *flakyObjectIterator) Item() ObjectInfo {
+	return ObjectInfo{Loc: ulloc.NewRemote("bucket", fmt.Sprint(f.n))}
+}
+
+func TestRetryingFilesystem(t *testing.T) {
+	ctx := context.Background()
+	loc := ulloc.NewRemote("bucket", "key")
+
+	t.Run("Remove", func(t *testing.T) {
+		flaky := &flakyFilesystem{failures: 2}
+		fs := NewRetryingFilesystem(flaky, RetryConfig{Attempts: 3})
+		_, err := fs.Remove(ctx, loc, nil)
+		require.NoError(t, err)
+		require.Equal(t, 3, flaky.calls)
+
+		flaky = &flakyFilesystem{failures: 3}
+		fs = NewRetryingFilesystem(flaky, RetryConfig{Attempts: 3})
+		_, err = fs.Remove(ctx, loc, nil)
+		require.Error(t, err)
+		require.Equal(t, 3, flaky.calls)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		flaky := &flakyFilesystem{failures: 1}
+		fs := NewRetryingFilesystem(flaky, RetryConfig{Attempts: 3})
+
+		iter, err := fs.List(ctx, loc, nil)
+		require.NoError(t, err)
+
+		var keys []string
+		for iter.Next() {
+			keys = append(keys, iter.Item().Loc.Loc())
+		}
+		require.NoError(t, iter.Err())
+		require.Equal(t, []string{"1", "2", "3", "4"}, keys)
+		require.Equal(t, 2, flaky.calls)
+	})
+}
@@ -6,6 +6,7 @@ package ulloc
 import (
 	"fmt"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/zeebo/errs"
@@ -149,22 +150,42 @@ func (p Location) Base() (string, bool) {
 	return p.loc, len(p.loc) > 0
 }
 
+// localCaseInsensitive is true if local paths should be compared without regard
+// to case because the default filesystems on the platform are case-insensitive.
+var localCaseInsensitive = runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+
 // RelativeTo returns the string that when appended to the location string
-// will return a string equivalent to the passed in target location.
+// will return a string equivalent to the passed in target location. Local
+// locations are compared case-insensitively on platforms with case-insensitive
+// filesystems, but remote keys must always match exactly.
 func (p Location) RelativeTo(target Location) (string, error) {
+	return p.relativeTo(target, localCaseInsensitive)
+}
+
+func (p Location) relativeTo(target Location, foldLocal bool) (string, error) {
+	hasPrefix := strings.HasPrefix
+	if foldLocal && p.Local() {
+		hasPrefix = hasPrefixFold
+	}
+
 	if p.Std() || target.Std() {
 		return "", errs.New("cannot create relative location for stdin/stdout")
 	} else if target.Remote() != p.Remote() {
 		return "", errs.New("cannot create remote and local relative location")
 	} else if target.bucket != p.bucket {
 		return "", errs.New("cannot change buckets in relative remote location")
-	} else if !strings.HasPrefix(target.loc, p.loc) {
+	} else if !hasPrefix(target.loc, p.loc) {
 		return "", errs.New("cannot make relative location because keys are not prefixes")
 	}
 	idx := strings.LastIndexByte(p.loc, '/') + 1
 	return target.loc[idx:], nil
 }
 
+// hasPrefixFold is like strings.HasPrefix but ignores case.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
 // AppendKey adds the key to the end of the existing key, separating with the
 // appropriate slash if necessary.
 func (p Location) AppendKey(key string) Location {
@@ -180,6 +201,38 @@ func (p Location) AppendKey(key string) Location {
 	return p
 }
 
+// JoinKey is like AppendKey except that, for local locations, it returns an
+// error instead of silently cleaning away ".." components in the key. Leading
+// slashes are still treated as relative to the location.
+func (p Location) JoinKey(key string) (Location, error) {
+	if p.Local() {
+		if err := checkLocalKey(key, runtime.GOOS == "windows"); err != nil {
+			return Location{}, err
+		}
+	}
+	return p.AppendKey(key), nil
+}
+
+// checkLocalKey returns an error if the key contains components that would
+// refer outside of a local path it is joined onto. Backslashes and volume
+// names are only special when windows is true.
+func checkLocalKey(key string, windows bool) error {
+	seps := "/"
+	if windows {
+		seps = `/\`
+		if strings.Contains(key, ":") {
+			return errs.New("key %q contains a volume name", key)
+		}
+	}
+
+	for _, component := range strings.FieldsFunc(key, func(r rune) bool { return strings.ContainsRune(seps, r) }) {
+		if component == ".." {
+			return errs.New("key %q contains a parent directory reference", key)
+		}
+	}
+	return nil
+}
+
 // HasPrefix returns true if the passed in Location is a prefix.
 func (p Location) HasPrefix(pre Location) bool {
 	if p.Std() {
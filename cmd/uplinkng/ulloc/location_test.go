@@ -0,0 +1,109 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ulloc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelativeToCaseInsensitive(t *testing.T) {
+	type test struct {
+		base, target string
+		rel          string
+		ok           bool
+	}
+
+	check := func(t *testing.T, tests []test, fold bool) {
+		for _, tc := range tests {
+			base, err := Parse(tc.base)
+			require.NoError(t, err)
+			target, err := Parse(tc.target)
+			require.NoError(t, err)
+
+			rel, err := base.relativeTo(target, fold)
+			if !tc.ok {
+				require.Error(t, err, "%q %q", tc.base, tc.target)
+				continue
+			}
+			require.NoError(t, err, "%q %q", tc.base, tc.target)
+			require.Equal(t, tc.rel, rel, "%q %q", tc.base, tc.target)
+		}
+	}
+
+	t.Run("Folded", func(t *testing.T) {
+		check(t, []test{
+			{"/Users/me/Photos/", "/Users/me/photos/a.jpg", "a.jpg", true},
+			{"/users/ME/photos/", "/Users/me/Photos/Sub/B.jpg", "Sub/B.jpg", true},
+			{"/Users/me/Photos/", "/Users/me/Videos/a.mp4", "", false},
+
+			// remote keys are never folded
+			{"sj://bucket/Photos/", "sj://bucket/Photos/a.jpg", "a.jpg", true},
+			{"sj://bucket/Photos/", "sj://bucket/photos/a.jpg", "", false},
+		}, true)
+	})
+
+	t.Run("Exact", func(t *testing.T) {
+		check(t, []test{
+			{"/Users/me/Photos/", "/Users/me/Photos/a.jpg", "a.jpg", true},
+			{"/Users/me/Photos/", "/Users/me/photos/a.jpg", "", false},
+			{"sj://bucket/Photos/", "sj://bucket/photos/a.jpg", "", false},
+		}, false)
+	})
+}
+
+func TestCheckLocalKey(t *testing.T) {
+	for _, tc := range []struct {
+		key     string
+		unix    bool
+		windows bool
+	}{
+		{"file", true, true},
+		{"dir/file", true, true},
+		{"./file", true, true},
+		{"dir/./file", true, true},
+		{"dir/..file", true, true},
+		{"dir/file..", true, true},
+		{"", true, true},
+		{"/starts-slash", true, true},
+
+		{"..", false, false},
+		{"../file", false, false},
+		{"../../.ssh/authorized_keys", false, false},
+		{"dir/../../file", false, false},
+		{"dir/..", false, false},
+		{"/../file", false, false},
+
+		// backslashes are only separators on windows
+		{`..\file`, true, false},
+		{`dir\..\..\file`, true, false},
+		{`\file`, true, true},
+		{`.\file`, true, true},
+
+		// volume names are only special on windows
+		{"C:/file", true, false},
+		{`C:\file`, true, false},
+		{"dir/file:stream", true, false},
+	} {
+		require.Equal(t, tc.unix, checkLocalKey(tc.key, false) == nil, "unix %q", tc.key)
+		require.Equal(t, tc.windows, checkLocalKey(tc.key, true) == nil, "windows %q", tc.key)
+	}
+}
+
+func TestJoinKey(t *testing.T) {
+	remote := NewRemote("bucket", "prefix/")
+	joined, err := remote.JoinKey("../key")
+	require.NoError(t, err)
+	require.Equal(t, NewRemote("bucket", "prefix/../key"), joined)
+
+	local := NewLocal("/home/user/dest/")
+	joined, err = local.JoinKey("dir/file")
+	require.NoError(t, err)
+	require.Equal(t, NewLocal("/home/user/dest/dir/file"), joined)
+
+	_, err = local.JoinKey("../../.ssh/authorized_keys")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "../../.ssh/authorized_keys")
+}
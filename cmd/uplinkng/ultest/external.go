@@ -34,8 +34,8 @@ func (ex *external) OpenFilesystem(ctx context.Context, access string, options .
 	return ex.fs, nil
 }
 
-func (ex *external) OpenProject(ctx context.Context, access string, options ...ulext.Option) (*uplink.Project, error) {
-	return ex.project, nil
+func (ex *external) OpenProject(ctx context.Context, access string, options ...ulext.Option) (*ulext.Project, error) {
+	return ulext.NewProject(ex.project, func() error { return nil }), nil
 }
 
 func (ex *external) OpenAccess(accessName string) (access *uplink.Access, err error) {
@@ -6,9 +6,11 @@ package ultest
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +20,7 @@ import (
 
 	"storj.io/storj/cmd/uplinkng/ulfs"
 	"storj.io/storj/cmd/uplinkng/ulloc"
+	"storj.io/uplink"
 )
 
 //
@@ -28,6 +31,7 @@ type testFilesystem struct {
 	stdin   string
 	created int64
 	files   map[ulloc.Location]memFileData
+	history map[ulloc.Location][]memFileData // older versions of remote files
 	pending map[ulloc.Location][]*memWriteHandle
 	locals  map[string]bool // true means path is a directory
 	buckets map[string]struct{}
@@ -38,6 +42,7 @@ type testFilesystem struct {
 func newTestFilesystem() *testFilesystem {
 	return &testFilesystem{
 		files:   make(map[ulloc.Location]memFileData),
+		history: make(map[ulloc.Location][]memFileData),
 		pending: make(map[ulloc.Location][]*memWriteHandle),
 		locals:  make(map[string]bool),
 		buckets: make(map[string]struct{}),
@@ -49,6 +54,31 @@ type memFileData struct {
 	created  int64
 }
 
+// version fabricates a version for remote files from their creation counter.
+func (mf memFileData) version(loc ulloc.Location) []byte {
+	if !loc.Remote() {
+		return nil
+	}
+	return []byte("v" + strconv.FormatInt(mf.created, 10))
+}
+
+// findVersion returns the file data at the location with the given version,
+// and the index into the history for it, or -1 if it is the latest version.
+func (tfs *testFilesystem) findVersion(loc ulloc.Location, version []byte) (memFileData, int, error) {
+	if !loc.Remote() {
+		return memFileData{}, 0, errs.Wrap(ulfs.ErrVersionsUnsupported)
+	}
+	if mf, ok := tfs.files[loc]; ok && bytes.Equal(mf.version(loc), version) {
+		return mf, -1, nil
+	}
+	for i, mf := range tfs.history[loc] {
+		if bytes.Equal(mf.version(loc), version) {
+			return mf, i, nil
+		}
+	}
+	return memFileData{}, 0, errs.New("version %q of file %q does not exist", version, loc)
+}
+
 func (tfs *testFilesystem) ensureBucket(name string) {
 	tfs.buckets[name] = struct{}{}
 }
@@ -85,20 +115,31 @@ type nopClosingGenericReader struct{ io.ReaderAt }
 
 func (n nopClosingGenericReader) Close() error { return nil }
 
-func newMultiReadHandle(contents string) ulfs.MultiReadHandle {
+func newMultiReadHandle(contents string, created int64) ulfs.MultiReadHandle {
 	return ulfs.NewGenericMultiReadHandle(nopClosingGenericReader{
 		ReaderAt: bytes.NewReader([]byte(contents)),
 	}, ulfs.ObjectInfo{
+		Created:       time.Unix(created, 0),
 		ContentLength: int64(len(contents)),
+		Modified:      time.Unix(created, 0),
+		ETag:          []byte(fmt.Sprintf("%d-%d", created, len(contents))),
 	})
 }
 
-func (tfs *testFilesystem) Open(ctx clingy.Context, loc ulloc.Location) (ulfs.MultiReadHandle, error) {
+func (tfs *testFilesystem) Open(ctx clingy.Context, loc ulloc.Location, opts *ulfs.OpenOptions) (ulfs.MultiReadHandle, error) {
 	tfs.mu.Lock()
 	defer tfs.mu.Unlock()
 
+	if opts != nil && opts.Version != nil {
+		mf, _, err := tfs.findVersion(loc, opts.Version)
+		if err != nil {
+			return nil, err
+		}
+		return newMultiReadHandle(mf.contents, mf.created), nil
+	}
+
 	if loc.Std() {
-		return newMultiReadHandle("-"), nil
+		return newMultiReadHandle("-", 0), nil
 	}
 
 	mf, ok := tfs.files[loc]
@@ -106,7 +147,7 @@ func (tfs *testFilesystem) Open(ctx clingy.Context, loc ulloc.Location) (ulfs.Mu
 		return nil, errs.New("file does not exist %q", loc)
 	}
 
-	return newMultiReadHandle(mf.contents), nil
+	return newMultiReadHandle(mf.contents, mf.created), nil
 }
 
 func (tfs *testFilesystem) Create(ctx clingy.Context, loc ulloc.Location) (_ ulfs.MultiWriteHandle, err error) {
@@ -160,17 +201,38 @@ func (tfs *testFilesystem) Move(ctx clingy.Context, source, dest ulloc.Location)
 	return nil
 }
 
-func (tfs *testFilesystem) Remove(ctx context.Context, loc ulloc.Location, opts *ulfs.RemoveOptions) error {
+func (tfs *testFilesystem) Remove(ctx context.Context, loc ulloc.Location, opts *ulfs.RemoveOptions) (int, error) {
 	tfs.mu.Lock()
 	defer tfs.mu.Unlock()
 
-	if opts == nil || !opts.Pending {
+	if opts != nil && opts.Version != nil {
+		_, idx, err := tfs.findVersion(loc, opts.Version)
+		if err != nil {
+			return 0, err
+		}
+		history := tfs.history[loc]
+		if idx < 0 {
+			// the latest version was removed so the previous one takes its place.
+			delete(tfs.files, loc)
+			if len(history) > 0 {
+				tfs.files[loc], history = history[len(history)-1], history[:len(history)-1]
+			}
+		} else {
+			history = append(history[:idx], history[idx+1:]...)
+		}
+		if len(history) > 0 {
+			tfs.history[loc] = history
+		} else {
+			delete(tfs.history, loc)
+		}
+	} else if opts == nil || !opts.Pending {
 		delete(tfs.files, loc)
 	} else {
-		// TODO: Remove needs an API that understands that multiple pending files may exist
+		aborted := len(tfs.pending[loc])
 		delete(tfs.pending, loc)
+		return aborted, nil
 	}
-	return nil
+	return 0, nil
 }
 
 func (tfs *testFilesystem) List(ctx context.Context, prefix ulloc.Location, opts *ulfs.ListOptions) (ulfs.ObjectIterator, error) {
@@ -181,6 +243,10 @@ func (tfs *testFilesystem) List(ctx context.Context, prefix ulloc.Location, opts
 		return tfs.listPending(ctx, prefix, opts)
 	}
 
+	if opts != nil && opts.AllVersions && !prefix.Remote() {
+		return nil, errs.Wrap(ulfs.ErrVersionsUnsupported)
+	}
+
 	prefixDir := prefix.AsDirectoryish()
 
 	var infos []ulfs.ObjectInfo
@@ -189,14 +255,34 @@ func (tfs *testFilesystem) List(ctx context.Context, prefix ulloc.Location, opts
 			infos = append(infos, ulfs.ObjectInfo{
 				Loc:     loc,
 				Created: time.Unix(mf.created, 0),
+				Version: mf.version(loc),
 			})
 		}
 	}
+	if opts != nil && opts.AllVersions {
+		for loc, history := range tfs.history {
+			if loc.HasPrefix(prefixDir) || loc == prefix {
+				for _, mf := range history {
+					infos = append(infos, ulfs.ObjectInfo{
+						Loc:     loc,
+						Created: time.Unix(mf.created, 0),
+						Version: mf.version(loc),
+					})
+				}
+			}
+		}
+	}
 
 	sort.Sort(objectInfos(infos))
 
 	if opts == nil || !opts.Recursive {
-		infos = collapseObjectInfos(prefix, infos)
+		infos = collapseObjectInfos(prefix, infos, opts != nil && opts.CountPrefixItems)
+	}
+
+	if opts != nil && opts.KeysOnly {
+		for i := range infos {
+			infos[i].Created = time.Time{}
+		}
 	}
 
 	return &objectInfoIterator{infos: infos}, nil
@@ -224,12 +310,25 @@ func (tfs *testFilesystem) listPending(ctx context.Context, prefix ulloc.Locatio
 	sort.Sort(objectInfos(infos))
 
 	if opts == nil || !opts.Recursive {
-		infos = collapseObjectInfos(prefix, infos)
+		infos = collapseObjectInfos(prefix, infos, opts != nil && opts.CountPrefixItems)
 	}
 
 	return &objectInfoIterator{infos: infos}, nil
 }
 
+func (tfs *testFilesystem) ListBuckets(ctx context.Context) ulfs.BucketIterator {
+	tfs.mu.Lock()
+	defer tfs.mu.Unlock()
+
+	var infos []ulfs.BucketInfo
+	for name := range tfs.buckets {
+		infos = append(infos, ulfs.BucketInfo{Name: name})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return &bucketInfoIterator{infos: infos}
+}
+
 func (tfs *testFilesystem) IsLocalDir(ctx context.Context, loc ulloc.Location) (local bool) {
 	tfs.mu.Lock()
 	defer tfs.mu.Unlock()
@@ -242,6 +341,23 @@ func (tfs *testFilesystem) isLocalDir(ctx context.Context, loc ulloc.Location) (
 	return ok && (ulloc.CleanPath(path) == "." || tfs.locals[path])
 }
 
+func (tfs *testFilesystem) IsRemoteDir(ctx context.Context, loc ulloc.Location) (bool, error) {
+	tfs.mu.Lock()
+	defer tfs.mu.Unlock()
+
+	if !loc.Remote() {
+		return false, nil
+	}
+
+	prefix := loc.AsDirectoryish()
+	for floc := range tfs.files {
+		if floc.HasPrefix(prefix) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (tfs *testFilesystem) Stat(ctx context.Context, loc ulloc.Location) (*ulfs.ObjectInfo, error) {
 	if loc.Std() {
 		return nil, errs.New("unable to stat loc %q", loc.Loc())
@@ -249,6 +365,9 @@ func (tfs *testFilesystem) Stat(ctx context.Context, loc ulloc.Location) (*ulfs.
 
 	mf, ok := tfs.files[loc]
 	if !ok {
+		if loc.Remote() {
+			return nil, fmt.Errorf("%w: %q", uplink.ErrObjectNotFound, loc.Loc())
+		}
 		return nil, errs.New("file does not exist: %q", loc.Loc())
 	}
 
@@ -256,9 +375,16 @@ func (tfs *testFilesystem) Stat(ctx context.Context, loc ulloc.Location) (*ulfs.
 		Loc:           loc,
 		Created:       time.Unix(mf.created, 0),
 		ContentLength: int64(len(mf.contents)),
+		Modified:      time.Unix(mf.created, 0),
+		ETag:          []byte(fmt.Sprintf("%d-%d", mf.created, len(mf.contents))),
+		Version:       mf.version(loc),
 	}, nil
 }
 
+func (tfs *testFilesystem) StatDestination(ctx context.Context, loc ulloc.Location) (*ulfs.ObjectInfo, error) {
+	return tfs.Stat(ctx, loc)
+}
+
 func (tfs *testFilesystem) mkdirAll(ctx context.Context, dir string) error {
 	i := 0
 	for i < len(dir) {
@@ -318,6 +444,8 @@ func (b *memWriteHandle) Commit() error {
 
 	if path, ok := b.loc.LocalParts(); ok {
 		b.tfs.locals[path] = false
+	} else if mf, ok := b.tfs.files[b.loc]; ok {
+		b.tfs.history[b.loc] = append(b.tfs.history[b.loc], mf)
 	}
 
 	b.tfs.files[b.loc] = memFileData{
@@ -393,13 +521,43 @@ func (li *objectInfoIterator) Item() ulfs.ObjectInfo {
 	return li.current
 }
 
+//
+// ulfs.BucketIterator
+//
+
+type bucketInfoIterator struct {
+	infos   []ulfs.BucketInfo
+	current ulfs.BucketInfo
+}
+
+func (bi *bucketInfoIterator) Next() bool {
+	if len(bi.infos) == 0 {
+		return false
+	}
+	bi.current, bi.infos = bi.infos[0], bi.infos[1:]
+	return true
+}
+
+func (bi *bucketInfoIterator) Err() error {
+	return nil
+}
+
+func (bi *bucketInfoIterator) Item() ulfs.BucketInfo {
+	return bi.current
+}
+
 type objectInfos []ulfs.ObjectInfo
 
-func (ois objectInfos) Len() int               { return len(ois) }
-func (ois objectInfos) Swap(i int, j int)      { ois[i], ois[j] = ois[j], ois[i] }
-func (ois objectInfos) Less(i int, j int) bool { return ois[i].Loc.Less(ois[j].Loc) }
+func (ois objectInfos) Len() int          { return len(ois) }
+func (ois objectInfos) Swap(i int, j int) { ois[i], ois[j] = ois[j], ois[i] }
+func (ois objectInfos) Less(i int, j int) bool {
+	if ois[i].Loc == ois[j].Loc {
+		return ois[i].Created.After(ois[j].Created)
+	}
+	return ois[i].Loc.Less(ois[j].Loc)
+}
 
-func collapseObjectInfos(prefix ulloc.Location, infos []ulfs.ObjectInfo) []ulfs.ObjectInfo {
+func collapseObjectInfos(prefix ulloc.Location, infos []ulfs.ObjectInfo, count bool) []ulfs.ObjectInfo {
 	collapsing := false
 	current := ""
 	j := 0
@@ -408,8 +566,14 @@ func collapseObjectInfos(prefix ulloc.Location, infos []ulfs.ObjectInfo) []ulfs.
 		first, ok := oi.Loc.ListKeyName(prefix)
 		if ok {
 			if collapsing && first == current {
+				if count && infos[j-1].PrefixItems <= ulfs.PrefixItemsLimit {
+					infos[j-1].PrefixItems++
+				}
 				continue
 			}
+			if count {
+				oi.PrefixItems = 1
+			}
 
 			collapsing = true
 			current = first
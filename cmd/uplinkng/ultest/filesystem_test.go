@@ -0,0 +1,126 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ultest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/clingy"
+
+	"storj.io/storj/cmd/uplinkng/ulfs"
+	"storj.io/storj/cmd/uplinkng/ulloc"
+)
+
+func TestFilesystemVersions(t *testing.T) {
+	ctx := testContext{context.Background()}
+	tfs := newTestFilesystem()
+	tfs.ensureBucket("bucket")
+
+	loc := ulloc.NewRemote("bucket", "key")
+	write := func(contents string) {
+		mwh, err := tfs.Create(ctx, loc)
+		require.NoError(t, err)
+		wh, err := mwh.NextPart(ctx, -1)
+		require.NoError(t, err)
+		_, err = wh.Write([]byte(contents))
+		require.NoError(t, err)
+		require.NoError(t, wh.Commit())
+		require.NoError(t, mwh.Commit(ctx))
+	}
+	read := func(version []byte) string {
+		mrh, err := tfs.Open(ctx, loc, &ulfs.OpenOptions{Version: version})
+		require.NoError(t, err)
+		defer func() { _ = mrh.Close() }()
+		rh, err := mrh.NextPart(ctx, 100)
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(rh)
+		require.NoError(t, err)
+		return string(data)
+	}
+	versions := func() (versions [][]byte) {
+		iter, err := tfs.List(ctx, loc, &ulfs.ListOptions{Recursive: true, AllVersions: true})
+		require.NoError(t, err)
+		for iter.Next() {
+			require.Equal(t, loc, iter.Item().Loc)
+			versions = append(versions, iter.Item().Version)
+		}
+		require.NoError(t, iter.Err())
+		return versions
+	}
+
+	write("first")
+	write("second")
+	write("third")
+
+	all := versions()
+	require.Len(t, all, 3)
+
+	stat, err := tfs.Stat(ctx, loc)
+	require.NoError(t, err)
+	require.Equal(t, all[0], stat.Version)
+
+	// versions are listed newest first
+	require.Equal(t, "third", read(all[0]))
+	require.Equal(t, "second", read(all[1]))
+	require.Equal(t, "first", read(all[2]))
+	require.Equal(t, "third", read(nil))
+
+	// a plain listing only includes the latest version
+	iter, err := tfs.List(ctx, loc, &ulfs.ListOptions{Recursive: true})
+	require.NoError(t, err)
+	require.True(t, iter.Next())
+	require.Equal(t, all[0], iter.Item().Version)
+	require.False(t, iter.Next())
+
+	// removing an old version leaves the latest alone
+	_, err = tfs.Remove(ctx, loc, &ulfs.RemoveOptions{Version: all[1]})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{all[0], all[2]}, versions())
+	require.Equal(t, "third", read(nil))
+
+	// removing the latest version makes the previous one the latest
+	_, err = tfs.Remove(ctx, loc, &ulfs.RemoveOptions{Version: all[0]})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{all[2]}, versions())
+	require.Equal(t, "first", read(nil))
+
+	_, err = tfs.Open(ctx, loc, &ulfs.OpenOptions{Version: all[0]})
+	require.Error(t, err)
+	_, err = tfs.Remove(ctx, loc, &ulfs.RemoveOptions{Version: all[0]})
+	require.Error(t, err)
+}
+
+func TestFilesystemVersionsLocal(t *testing.T) {
+	ctx := testContext{context.Background()}
+	tfs := newTestFilesystem()
+
+	loc := ulloc.NewLocal("/home/user/file")
+
+	_, err := tfs.Open(ctx, loc, &ulfs.OpenOptions{Version: []byte("v1")})
+	require.True(t, errors.Is(err, ulfs.ErrVersionsUnsupported))
+
+	_, err = tfs.Remove(ctx, loc, &ulfs.RemoveOptions{Version: []byte("v1")})
+	require.True(t, errors.Is(err, ulfs.ErrVersionsUnsupported))
+
+	_, err = tfs.List(ctx, ulloc.NewLocal("/home/user/"), &ulfs.ListOptions{AllVersions: true})
+	require.True(t, errors.Is(err, ulfs.ErrVersionsUnsupported))
+}
+
+type testContext struct {
+	context.Context
+}
+
+var _ clingy.Context = testContext{}
+
+func (testContext) Read(p []byte) (int, error)  { return 0, io.EOF }
+func (testContext) Write(p []byte) (int, error) { return len(p), nil }
+func (testContext) Stdin() io.Reader            { return strings.NewReader("") }
+func (testContext) Stdout() io.Writer           { return ioutil.Discard }
+func (testContext) Stderr() io.Writer           { return ioutil.Discard }
@@ -86,6 +86,7 @@ func TestMigrateSQLite3(t *testing.T) {
 		currentSchema, err := sqliteutil.QuerySchema(ctx, db)
 		require.NoError(t, err)
 		currentSchema.DropTable("versions")
+		currentSchema.DropTable("versions_history")
 
 		currentData, err := sqliteutil.QueryData(ctx, db, currentSchema)
 		require.NoError(t, err)
@@ -166,6 +167,7 @@ func TestMigratePostgres(t *testing.T) {
 		currentSchema, err := pgutil.QuerySchema(ctx, db)
 		require.NoError(t, err)
 		currentSchema.DropTable("versions")
+		currentSchema.DropTable("versions_history")
 
 		currentData, err := pgutil.QueryData(ctx, db, currentSchema)
 		require.NoError(t, err)
@@ -0,0 +1,244 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/private/dbutil/txutil"
+	"storj.io/private/tagsql"
+)
+
+// BackfillTable is the table the backfill jobs are tracked in.
+const BackfillTable = "backfill_jobs"
+
+// Statuses of backfill jobs.
+const (
+	BackfillPending = "pending"
+	BackfillPaused  = "paused"
+	BackfillDone    = "done"
+)
+
+// ErrBackfill is the error class of backfill jobs.
+var ErrBackfill = errs.Class("backfill")
+
+// BackfillFunc processes the batch of a backfill job after the cursor and
+// returns the cursor of the next batch, or done when nothing is left. It runs
+// in the transaction that stores the returned cursor, so that a batch is
+// either processed and recorded or not at all.
+type BackfillFunc func(ctx context.Context, log *zap.Logger, db tagsql.DB, tx tagsql.Tx, cursor string) (next string, done bool, err error)
+
+// BackfillJob is the state of a backfill job.
+type BackfillJob struct {
+	Name      string
+	Status    string
+	Cursor    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Backfills advances long running data backfills outside of the version chain,
+// so that they don't hold back the schema version. A migration step creates
+// the job with CreateBackfillJob and Run advances it with the registered batch
+// function, persisting the cursor after every batch.
+type Backfills struct {
+	log *zap.Logger
+	db  tagsql.DB
+
+	mu    sync.Mutex
+	funcs map[string]BackfillFunc
+}
+
+// NewBackfills returns the backfill jobs of the database.
+func NewBackfills(log *zap.Logger, db tagsql.DB) *Backfills {
+	return &Backfills{
+		log:   log,
+		db:    db,
+		funcs: map[string]BackfillFunc{},
+	}
+}
+
+// CreateBackfillJob returns the action of a migration step that creates a
+// pending backfill job, unless it exists already.
+func CreateBackfillJob(name string) Action {
+	return Func(func(ctx context.Context, log *zap.Logger, db tagsql.DB, tx tagsql.Tx) error {
+		_, err := tx.Exec(ctx, rebind(db, `CREATE TABLE IF NOT EXISTS `+BackfillTable+` (
+			name text NOT NULL,
+			status text NOT NULL,
+			cursor text NOT NULL,
+			created_at timestamp NOT NULL,
+			updated_at timestamp NOT NULL,
+			PRIMARY KEY ( name )
+		)`))
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		_, err = tx.Exec(ctx, rebind(db, `
+			INSERT INTO `+BackfillTable+` (name, status, cursor, created_at, updated_at)
+			VALUES (?, ?, '', ?, ?)
+			ON CONFLICT (name) DO NOTHING`),
+			name, BackfillPending, now, now)
+		return err
+	})
+}
+
+// Register registers the batch function of a job.
+func (backfills *Backfills) Register(name string, fn BackfillFunc) {
+	backfills.mu.Lock()
+	defer backfills.mu.Unlock()
+	backfills.funcs[name] = fn
+}
+
+// Run advances the pending jobs that have a batch function, in the order of
+// their names, until they are done or paused.
+func (backfills *Backfills) Run(ctx context.Context) error {
+	jobs, err := backfills.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if job.Status != BackfillPending {
+			continue
+		}
+
+		backfills.mu.Lock()
+		fn, ok := backfills.funcs[job.Name]
+		backfills.mu.Unlock()
+		if !ok {
+			backfills.log.Debug("backfill job has no batch function", zap.String("job", job.Name))
+			continue
+		}
+
+		if err := backfills.runJob(ctx, job.Name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runJob processes the batches of the job until it's done or not pending
+// anymore.
+func (backfills *Backfills) runJob(ctx context.Context, name string, fn BackfillFunc) error {
+	log := backfills.log.Named(name)
+	db := backfills.db
+
+	for {
+		var stopped bool
+		err := txutil.WithTx(ctx, db, nil, func(ctx context.Context, tx tagsql.Tx) error {
+			var status, cursor string
+			err := tx.QueryRow(ctx, rebind(db, `SELECT status, cursor FROM `+BackfillTable+` WHERE name = ?`), name).Scan(&status, &cursor)
+			if err != nil {
+				return err
+			}
+			if status != BackfillPending {
+				stopped = true
+				return nil
+			}
+
+			next, done, err := fn(ctx, log, db, tx, cursor)
+			if err != nil {
+				return err
+			}
+
+			// the status is only set when the job is done, a job that is
+			// paused meanwhile stays paused.
+			if done {
+				_, err = tx.Exec(ctx, rebind(db, `UPDATE `+BackfillTable+` SET cursor = ?, status = ?, updated_at = ? WHERE name = ?`),
+					next, BackfillDone, time.Now().UTC(), name)
+				stopped = true
+			} else {
+				_, err = tx.Exec(ctx, rebind(db, `UPDATE `+BackfillTable+` SET cursor = ?, updated_at = ? WHERE name = ?`),
+					next, time.Now().UTC(), name)
+			}
+			return err
+		})
+		if err != nil {
+			return ErrBackfill.New("job %s failed: %w", name, err)
+		}
+		if stopped {
+			return nil
+		}
+	}
+}
+
+// List returns the jobs sorted by their names. It only reads, a database
+// without jobs has no jobs table.
+func (backfills *Backfills) List(ctx context.Context) (_ []BackfillJob, err error) {
+	db := backfills.db
+
+	exists, err := tableExists(ctx, db, BackfillTable)
+	if err != nil || !exists {
+		return nil, ErrBackfill.Wrap(err)
+	}
+
+	rows, err := db.Query(ctx, rebind(db, `SELECT name, status, cursor, created_at, updated_at FROM `+BackfillTable))
+	if err != nil {
+		return nil, ErrBackfill.Wrap(err)
+	}
+	defer func() { err = errs.Combine(err, ErrBackfill.Wrap(rows.Close())) }()
+
+	var jobs []BackfillJob
+	for rows.Next() {
+		var job BackfillJob
+		if err := rows.Scan(&job.Name, &job.Status, &job.Cursor, &job.CreatedAt, &job.UpdatedAt); err != nil {
+			return nil, ErrBackfill.Wrap(err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, ErrBackfill.Wrap(err)
+	}
+
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Name < jobs[k].Name })
+	return jobs, nil
+}
+
+// Pause pauses a pending job, it stops after the batch in progress.
+func (backfills *Backfills) Pause(ctx context.Context, name string) error {
+	return backfills.setStatus(ctx, name, BackfillPending, BackfillPaused)
+}
+
+// Resume resumes a paused job from its cursor.
+func (backfills *Backfills) Resume(ctx context.Context, name string) error {
+	return backfills.setStatus(ctx, name, BackfillPaused, BackfillPending)
+}
+
+func (backfills *Backfills) setStatus(ctx context.Context, name, from, to string) error {
+	db := backfills.db
+
+	result, err := db.Exec(ctx, rebind(db, `UPDATE `+BackfillTable+` SET status = ?, updated_at = ? WHERE name = ? AND status = ?`),
+		to, time.Now().UTC(), name, from)
+	if err != nil {
+		return ErrBackfill.Wrap(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return ErrBackfill.Wrap(err)
+	}
+	if affected == 0 {
+		return ErrBackfill.New("job %q is not %s", name, from)
+	}
+	return nil
+}
+
+// tableExists returns whether the table exists, without creating it.
+func tableExists(ctx context.Context, db tagsql.DB, table string) (bool, error) {
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+	if kind := driverKindOf(db); kind == driverPostgres || kind == driverCockroach {
+		query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`
+	}
+
+	var count int
+	err := db.QueryRow(ctx, rebind(db, query), table).Scan(&count)
+	return count > 0, err
+}
@@ -0,0 +1,128 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/private/dbutil/pgtest"
+	"storj.io/private/dbutil/tempdb"
+	"storj.io/private/tagsql"
+	"storj.io/storj/private/migrate"
+)
+
+func TestBackfill(t *testing.T) {
+	pgtest.Run(t, func(ctx *testcontext.Context, t *testing.T, connstr string) {
+		db, err := tempdb.OpenUnique(ctx, connstr, "backfill-")
+		require.NoError(t, err)
+		defer ctx.Check(db.Close)
+
+		var testDB tagsql.DB = &postgresDB{DB: db.DB}
+
+		m := migrate.Migration{
+			Table: "versions",
+			Steps: []*migrate.Step{
+				{
+					DB:          &testDB,
+					Description: "Initialize Table",
+					Version:     1,
+					Action: migrate.SQL{
+						`CREATE TABLE items (id int NOT NULL, processed int NOT NULL DEFAULT 0, PRIMARY KEY ( id ))`,
+						`INSERT INTO items (id) SELECT i FROM generate_series(1, 10) AS i`,
+					},
+				},
+				{
+					DB:          &testDB,
+					Description: "Backfill Items",
+					Version:     2,
+					Action:      migrate.CreateBackfillJob("items"),
+				},
+			},
+		}
+		require.NoError(t, m.Run(ctx, zaptest.NewLogger(t)))
+
+		// the batch processes three items after the cursor and fails once the
+		// cursor reaches crashAt, like a process that crashed before the batch
+		// was recorded.
+		var cursors []string
+		crashAt := "6"
+		batch := func(ctx context.Context, log *zap.Logger, db tagsql.DB, tx tagsql.Tx, cursor string) (string, bool, error) {
+			cursors = append(cursors, cursor)
+
+			after := 0
+			if cursor != "" {
+				after, _ = strconv.Atoi(cursor)
+			}
+			result, err := tx.Exec(ctx, `UPDATE items SET processed = processed + 1 WHERE id > $1 AND id <= $2`, after, after+3)
+			if err != nil {
+				return "", false, err
+			}
+			if cursor == crashAt {
+				return "", false, errors.New("crashed")
+			}
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return "", false, err
+			}
+			if affected == 0 {
+				return cursor, true, nil
+			}
+			return strconv.Itoa(after + 3), false, nil
+		}
+
+		backfills := migrate.NewBackfills(zaptest.NewLogger(t), testDB)
+		backfills.Register("items", batch)
+		err = backfills.Run(ctx)
+		require.True(t, migrate.ErrBackfill.Has(err), err)
+		require.Equal(t, []string{"", "3", "6"}, cursors)
+
+		jobs, err := backfills.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		require.Equal(t, migrate.BackfillPending, jobs[0].Status)
+		require.Equal(t, "6", jobs[0].Cursor)
+
+		// a paused job isn't advanced.
+		require.NoError(t, backfills.Pause(ctx, "items"))
+		require.Error(t, backfills.Pause(ctx, "items"))
+
+		cursors, crashAt = nil, ""
+		restarted := migrate.NewBackfills(zaptest.NewLogger(t), testDB)
+		restarted.Register("items", batch)
+		require.NoError(t, restarted.Run(ctx))
+		require.Empty(t, cursors)
+
+		// the resumed job continues from the stored cursor.
+		require.NoError(t, restarted.Resume(ctx, "items"))
+		require.NoError(t, restarted.Run(ctx))
+		require.Equal(t, []string{"6", "9", "12"}, cursors)
+
+		jobs, err = restarted.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, migrate.BackfillDone, jobs[0].Status)
+
+		// every item was processed exactly once.
+		rows, err := db.QueryContext(ctx, `SELECT processed FROM items`)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, rows.Close()) }()
+
+		count := 0
+		for rows.Next() {
+			var processed int
+			require.NoError(t, rows.Scan(&processed))
+			require.Equal(t, 1, processed)
+			count++
+		}
+		require.NoError(t, rows.Err())
+		require.Equal(t, 10, count)
+	})
+}
@@ -0,0 +1,122 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/private/dbutil/txutil"
+	"storj.io/private/tagsql"
+)
+
+// ErrBaseline is when the baseline can't be applied to a database.
+var ErrBaseline = errs.Class("migration baseline")
+
+// runBaseline creates an empty database from the baseline. Databases that are
+// migrated already are left alone, the steps migrate them as before. A database
+// below the baseline can only be migrated while the steps up to the baseline
+// are part of the migration.
+func (migration *Migration) runBaseline(ctx context.Context, log *zap.Logger, locks *dbLocks) error {
+	baseline := migration.Baseline
+
+	if baseline.CreateDB != nil {
+		if err := baseline.CreateDB(ctx, log); err != nil {
+			return Error.Wrap(err)
+		}
+	}
+
+	if baseline.DB == nil || *baseline.DB == nil {
+		return Error.New("baseline.DB is nil for baseline %d", baseline.Version)
+	}
+	db := *baseline.DB
+
+	err := locks.acquire(ctx, log, db)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+
+	err = migration.ensureVersionTable(ctx, log, db)
+	if err != nil {
+		return Error.New("creating version table failed: %w", err)
+	}
+
+	version, err := migration.getLatestVersion(ctx, log, db)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	if version >= baseline.Version {
+		return nil
+	}
+	if version >= 0 {
+		if missing, ok := migration.missingStep(db, version, baseline.Version); ok {
+			return ErrBaseline.New("database version %d is too old for the baseline %d: step %d was squashed into it",
+				version, baseline.Version, missing)
+		}
+		return nil
+	}
+
+	empty, err := migration.isEmpty(ctx, db)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	if !empty {
+		return ErrBaseline.New("refusing to apply the baseline %d to a database that has tables but no version", baseline.Version)
+	}
+
+	log.Info("Creating database from baseline", zap.Int("version", baseline.Version))
+	baselineCtx, cancel := migration.stepContext(ctx, baseline)
+	defer cancel()
+	baselineStarted := time.Now()
+
+	err = txutil.WithTx(baselineCtx, db, nil, func(ctx context.Context, tx tagsql.Tx) error {
+		started := time.Now()
+		err := baseline.Action.Run(ctx, log.Named("baseline"), db, tx)
+		if err != nil {
+			return err
+		}
+
+		err = migration.addVersion(ctx, tx, db, baseline.Version)
+		if err != nil {
+			return err
+		}
+
+		return migration.addHistory(ctx, tx, db, baseline, started, time.Since(started))
+	})
+	return Error.Wrap(stepError(ctx, baselineCtx, baseline, baselineStarted, err))
+}
+
+// missingStep returns the first version above the version and up to the
+// target that the migration has no step of the database for. A database at
+// the version can only be migrated step by step when none is missing.
+func (migration *Migration) missingStep(db tagsql.DB, version, target int) (missing int, ok bool) {
+	versions := map[int]bool{}
+	for _, step := range migration.Steps {
+		if step.DB != nil && *step.DB == db {
+			versions[step.Version] = true
+		}
+	}
+	for missing = version + 1; missing <= target; missing++ {
+		if !versions[missing] {
+			return missing, true
+		}
+	}
+	return 0, false
+}
+
+// isEmpty returns whether the database has no tables besides the tables of
+// the migration.
+func (migration *Migration) isEmpty(ctx context.Context, db tagsql.DB) (bool, error) {
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name NOT IN (?, ?, ?)`
+	if kind := driverKindOf(db); kind == driverPostgres || kind == driverCockroach {
+		query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name NOT IN (?, ?, ?)`
+	}
+
+	var count int
+	err := db.QueryRow(ctx, rebind(db, query), migration.Table, migration.historyTable(), migration.Table+"_lock").Scan(&count)
+	return count == 0, err
+}
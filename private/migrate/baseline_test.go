@@ -0,0 +1,212 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/private/dbutil/dbschema"
+	"storj.io/private/dbutil/pgtest"
+	"storj.io/private/dbutil/pgutil"
+	"storj.io/private/dbutil/tempdb"
+	"storj.io/private/tagsql"
+	"storj.io/storj/private/migrate"
+)
+
+// baselineSteps returns the full chain of steps of the baseline tests.
+func baselineSteps(db *tagsql.DB) []*migrate.Step {
+	return []*migrate.Step{
+		{
+			DB:          db,
+			Description: "Initialize Table",
+			Version:     1,
+			Action: migrate.SQL{
+				`CREATE TABLE users (id int NOT NULL, PRIMARY KEY ( id ))`,
+				`INSERT INTO users (id) VALUES (1)`,
+			},
+		},
+		{
+			DB:          db,
+			Description: "Add Name",
+			Version:     2,
+			Action: migrate.SQL{
+				`ALTER TABLE users ADD COLUMN name text NOT NULL DEFAULT ''`,
+				`CREATE INDEX users_name_index ON users ( name )`,
+			},
+		},
+		{
+			DB:          db,
+			Description: "Add Projects",
+			Version:     3,
+			Action: migrate.SQL{
+				`CREATE TABLE projects (id int NOT NULL, owner_id int NOT NULL REFERENCES users( id ) ON DELETE CASCADE, PRIMARY KEY ( id ))`,
+			},
+		},
+		{
+			DB:          db,
+			Description: "Add Email",
+			Version:     4,
+			Action:      migrate.SQL{`ALTER TABLE users ADD COLUMN email text`},
+		},
+	}
+}
+
+// baselineOf returns the baseline of the baseline tests, the steps up to version 2 squashed.
+func baselineOf(db *tagsql.DB) *migrate.Step {
+	return &migrate.Step{
+		DB:          db,
+		Description: "Baseline",
+		Version:     2,
+		Action: migrate.SQL{
+			`CREATE TABLE users (id int NOT NULL, name text NOT NULL DEFAULT '', PRIMARY KEY ( id ))`,
+			`CREATE INDEX users_name_index ON users ( name )`,
+			`INSERT INTO users (id) VALUES (1)`,
+		},
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	pgtest.Run(t, func(ctx *testcontext.Context, t *testing.T, connstr string) {
+		fullDB, err := tempdb.OpenUnique(ctx, connstr, "baseline-full-")
+		require.NoError(t, err)
+		defer ctx.Check(fullDB.Close)
+
+		squashedDB, err := tempdb.OpenUnique(ctx, connstr, "baseline-squashed-")
+		require.NoError(t, err)
+		defer ctx.Check(squashedDB.Close)
+
+		var fullTestDB tagsql.DB = &postgresDB{DB: fullDB.DB}
+		var squashedTestDB tagsql.DB = &postgresDB{DB: squashedDB.DB}
+
+		full := &migrate.Migration{Table: "versions", Steps: baselineSteps(&fullTestDB)}
+		squashed := &migrate.Migration{
+			Table:    "versions",
+			Baseline: baselineOf(&squashedTestDB),
+			Steps:    baselineSteps(&squashedTestDB)[2:],
+		}
+
+		status, err := squashed.Status(ctx, zaptest.NewLogger(t))
+		require.NoError(t, err)
+		require.Equal(t, -1, status.Current)
+		require.Len(t, status.Pending, 3)
+		require.Equal(t, "Baseline", status.Pending[0].Description)
+
+		require.NoError(t, full.Run(ctx, zaptest.NewLogger(t).Named("full")))
+		require.NoError(t, squashed.Run(ctx, zaptest.NewLogger(t).Named("squashed")))
+
+		// both databases end up with the same schema and seed data.
+		requireSameSnapshot(ctx, t, fullDB.DB, squashedDB.DB)
+
+		for _, db := range []tagsql.DB{fullTestDB, squashedTestDB} {
+			version, err := full.CurrentVersion(ctx, nil, db)
+			require.NoError(t, err)
+			require.Equal(t, 4, version)
+		}
+
+		// a migrated database is not touched by the baseline.
+		alreadyMigrated := &migrate.Migration{
+			Table:    "versions",
+			Baseline: baselineOf(&fullTestDB),
+			Steps:    baselineSteps(&fullTestDB)[2:],
+		}
+		require.NoError(t, alreadyMigrated.Run(ctx, zaptest.NewLogger(t)))
+		requireSameSnapshot(ctx, t, fullDB.DB, squashedDB.DB)
+	})
+}
+
+func TestBaselineBelowVersion(t *testing.T) {
+	pgtest.Run(t, func(ctx *testcontext.Context, t *testing.T, connstr string) {
+		db, err := tempdb.OpenUnique(ctx, connstr, "baseline-below-")
+		require.NoError(t, err)
+		defer ctx.Check(db.Close)
+
+		var testDB tagsql.DB = &postgresDB{DB: db.DB}
+
+		full := &migrate.Migration{Table: "versions", Steps: baselineSteps(&testDB)}
+		require.NoError(t, full.TargetVersion(1).Run(ctx, zaptest.NewLogger(t)))
+
+		// the steps up to the baseline are needed for a database below it.
+		squashed := &migrate.Migration{
+			Table:    "versions",
+			Baseline: baselineOf(&testDB),
+			Steps:    baselineSteps(&testDB)[2:],
+		}
+		err = squashed.Run(ctx, zaptest.NewLogger(t))
+		require.True(t, migrate.ErrBaseline.Has(err), err)
+
+		// a gap in the steps up to the baseline is not skipped.
+		gapBaseline := baselineOf(&testDB)
+		gapBaseline.Version = 3
+		gap := &migrate.Migration{
+			Table:    "versions",
+			Baseline: gapBaseline,
+			Steps:    append(baselineSteps(&testDB)[:1:1], baselineSteps(&testDB)[2:]...),
+		}
+		err = gap.Run(ctx, zaptest.NewLogger(t))
+		require.True(t, migrate.ErrBaseline.Has(err), err)
+		require.Contains(t, err.Error(), "step 2")
+
+		version, err := full.CurrentVersion(ctx, nil, testDB)
+		require.NoError(t, err)
+		require.Equal(t, 1, version)
+
+		// they're replayed while they're part of the migration.
+		withSteps := &migrate.Migration{
+			Table:    "versions",
+			Baseline: baselineOf(&testDB),
+			Steps:    baselineSteps(&testDB),
+		}
+		require.NoError(t, withSteps.Run(ctx, zaptest.NewLogger(t)))
+
+		version, err = withSteps.CurrentVersion(ctx, nil, testDB)
+		require.NoError(t, err)
+		require.Equal(t, 4, version)
+	})
+}
+
+func TestBaselineNonEmptyDatabase(t *testing.T) {
+	pgtest.Run(t, func(ctx *testcontext.Context, t *testing.T, connstr string) {
+		db, err := tempdb.OpenUnique(ctx, connstr, "baseline-nonempty-")
+		require.NoError(t, err)
+		defer ctx.Check(db.Close)
+
+		_, err = db.ExecContext(ctx, `CREATE TABLE users (id int)`)
+		require.NoError(t, err)
+
+		var testDB tagsql.DB = &postgresDB{DB: db.DB}
+		squashed := &migrate.Migration{
+			Table:    "versions",
+			Baseline: baselineOf(&testDB),
+			Steps:    baselineSteps(&testDB)[2:],
+		}
+		err = squashed.Run(ctx, zaptest.NewLogger(t))
+		require.True(t, migrate.ErrBaseline.Has(err), err)
+	})
+}
+
+// requireSameSnapshot requires the databases to have the same schema and data,
+// without the tables of the migration.
+func requireSameSnapshot(ctx context.Context, t *testing.T, expectedDB, actualDB tagsql.DB) {
+	snapshot := func(db tagsql.DB) (*dbschema.Schema, *dbschema.Data) {
+		schema, err := pgutil.QuerySchema(ctx, db)
+		require.NoError(t, err)
+		schema.DropTable("versions")
+		schema.DropTable("versions_history")
+		schema.DropTable("versions_lock")
+
+		data, err := pgutil.QueryData(ctx, db, schema)
+		require.NoError(t, err)
+		return schema, data
+	}
+
+	expectedSchema, expectedData := snapshot(expectedDB)
+	actualSchema, actualData := snapshot(actualDB)
+	require.Equal(t, expectedSchema, actualSchema)
+	require.Equal(t, expectedData, actualData)
+}
@@ -0,0 +1,142 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/private/dbutil/txutil"
+	"storj.io/private/tagsql"
+)
+
+// batchProgressInterval is how often the progress of a BatchedFunc is logged.
+const batchProgressInterval = 10 * time.Second
+
+// BatchedFunc is an Action that migrates the rows of a large table in batches,
+// each in a separate transaction, so that the table isn't locked for the whole
+// migration. It doesn't use the transaction of the step, so it can only be used
+// with databases that allow concurrent transactions, i.e. postgres and
+// cockroach.
+//
+// The rows are processed in the order of a cursor column. Select returns the
+// cursor values of the next batch in order, it's called with the cursor of the
+// last processed row and the batch size:
+//
+//	SELECT id FROM nodes WHERE id > ? AND country_code IS NULL ORDER BY id LIMIT ?
+//
+// Update processes the rows of the batch, it's called with the cursor of the
+// last processed row and the cursor of the last row of the batch:
+//
+//	UPDATE nodes SET country_code = '' WHERE id > ? AND id <= ? AND country_code IS NULL
+//
+// Every batch must be idempotent, so that a migration that was canceled or that
+// failed can be run again. When Select skips the rows that were processed, as
+// above, a rerun continues where the previous run stopped.
+type BatchedFunc struct {
+	// Select returns the cursors of the next batch.
+	Select string
+	// Update processes the rows of a batch.
+	Update string
+	// Start is the cursor before the first row, e.g. 0 or []byte{}.
+	Start interface{}
+	// BatchSize is the maximum number of rows in a batch.
+	BatchSize int
+	// Count optionally returns the number of rows to process, it's used to
+	// estimate the remaining time.
+	Count string
+}
+
+// Run runs the batches until Select returns no rows. The context is checked
+// between the batches.
+func (batched BatchedFunc) Run(ctx context.Context, log *zap.Logger, db tagsql.DB, _ tagsql.Tx) error {
+	if batched.BatchSize <= 0 {
+		return Error.New("invalid batch size %d", batched.BatchSize)
+	}
+
+	total := int64(-1)
+	if batched.Count != "" {
+		if err := db.QueryRow(ctx, rebind(db, batched.Count)).Scan(&total); err != nil {
+			return Error.Wrap(err)
+		}
+	}
+
+	started := time.Now()
+	lastProgress := started
+
+	cursor := batched.Start
+	var done int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var rows int64
+		var last interface{}
+		err := txutil.WithTx(ctx, db, nil, func(ctx context.Context, tx tagsql.Tx) (err error) {
+			rows, last, err = batched.batch(ctx, db, tx, cursor)
+			return err
+		})
+		if err != nil {
+			return Error.Wrap(err)
+		}
+		if rows == 0 {
+			break
+		}
+		cursor = last
+		done += rows
+
+		if now := time.Now(); now.Sub(lastProgress) >= batchProgressInterval {
+			lastProgress = now
+			log.Info("Batched migration progress", batchProgress(done, total, now.Sub(started))...)
+		}
+	}
+
+	log.Info("Batched migration finished", zap.Int64("rows", done), zap.Duration("duration", time.Since(started)))
+	return nil
+}
+
+// batch processes the rows after the cursor and returns their number and the
+// cursor of the last one.
+func (batched BatchedFunc) batch(ctx context.Context, db tagsql.DB, tx tagsql.Tx, cursor interface{}) (count int64, last interface{}, err error) {
+	rows, err := tx.Query(ctx, rebind(db, batched.Select), cursor, batched.BatchSize)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() { err = errs.Combine(err, rows.Close()) }()
+
+	for rows.Next() {
+		if err := rows.Scan(&last); err != nil {
+			return 0, nil, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	if count == 0 {
+		return 0, nil, nil
+	}
+
+	_, err = tx.Exec(ctx, rebind(db, batched.Update), cursor, last)
+	return count, last, err
+}
+
+// batchProgress returns the log fields of the progress of a batched migration.
+// The remaining time is only estimated when the total is known.
+func batchProgress(done, total int64, elapsed time.Duration) []zap.Field {
+	rate := float64(done) / elapsed.Seconds()
+	fields := []zap.Field{zap.Int64("rows", done), zap.Float64("rows/s", rate)}
+	if total >= 0 && rate > 0 {
+		remaining := total - done
+		if remaining < 0 {
+			remaining = 0
+		}
+		fields = append(fields, zap.Duration("remaining", time.Duration(float64(remaining)/rate*float64(time.Second))))
+	}
+	return fields
+}
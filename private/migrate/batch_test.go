@@ -0,0 +1,104 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/private/dbutil/pgtest"
+	"storj.io/private/dbutil/tempdb"
+	"storj.io/private/tagsql"
+	"storj.io/storj/private/migrate"
+)
+
+func TestBatchedFunc(t *testing.T) {
+	pgtest.Run(t, func(ctx *testcontext.Context, t *testing.T, connstr string) {
+		db, err := tempdb.OpenUnique(ctx, connstr, "batch-")
+		require.NoError(t, err)
+		defer ctx.Check(db.Close)
+
+		const numRows = 1000000
+		const batchSize = 10000
+
+		_, err = db.ExecContext(ctx, `CREATE TABLE items (id bigint NOT NULL, value bigint, PRIMARY KEY ( id ))`)
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, `INSERT INTO items (id) SELECT generate_series(1, $1)`, numRows)
+		require.NoError(t, err)
+
+		action := migrate.BatchedFunc{
+			Select:    `SELECT id FROM items WHERE id > ? AND value IS NULL ORDER BY id LIMIT ?`,
+			Update:    `UPDATE items SET value = id * 2 WHERE id > ? AND id <= ? AND value IS NULL`,
+			Start:     int64(0),
+			BatchSize: batchSize,
+			Count:     `SELECT count(*) FROM items WHERE value IS NULL`,
+		}
+
+		migrated := func() (count int64) {
+			err := db.QueryRowContext(ctx, `SELECT count(*) FROM items WHERE value IS NOT NULL`).Scan(&count)
+			require.NoError(t, err)
+			return count
+		}
+
+		// cancel the migration while the sixth batch runs.
+		cancelCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		canceling := &cancelingDB{postgresDB: postgresDB{DB: db.DB}, cancel: cancel, selects: 5}
+
+		err = action.Run(cancelCtx, zaptest.NewLogger(t), canceling, nil)
+		require.Error(t, err)
+
+		// the canceled batch was rolled back.
+		done := migrated()
+		require.EqualValues(t, 5*batchSize, done)
+
+		// a rerun continues and migrates the rest.
+		var testDB tagsql.DB = &postgresDB{DB: db.DB}
+		require.NoError(t, action.Run(ctx, zaptest.NewLogger(t), testDB, nil))
+		require.EqualValues(t, numRows, migrated())
+
+		var wrong int64
+		err = db.QueryRowContext(ctx, `SELECT count(*) FROM items WHERE value <> id * 2`).Scan(&wrong)
+		require.NoError(t, err)
+		require.Zero(t, wrong)
+
+		// the action can be used by a migration step.
+		m := migrate.Migration{
+			Table: "versions",
+			Steps: []*migrate.Step{
+				{
+					DB:          &testDB,
+					Description: "Fill values",
+					Version:     1,
+					Action:      action,
+				},
+			},
+		}
+		require.NoError(t, m.Run(ctx, zap.NewNop()))
+	})
+}
+
+// cancelingDB cancels the context when the select of a batch is rebound
+// after the given number of batches.
+type cancelingDB struct {
+	postgresDB
+	cancel  func()
+	selects int
+}
+
+func (db *cancelingDB) Rebind(s string) string {
+	if strings.HasPrefix(s, "SELECT id") {
+		db.selects--
+		if db.selects < 0 {
+			db.cancel()
+		}
+	}
+	return db.postgresDB.Rebind(s)
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/private/tagsql"
+)
+
+// ErrChecksumMismatch is when an applied step was changed after it was applied.
+var ErrChecksumMismatch = errs.Class("migration checksum mismatch")
+
+// Checksum returns a stable checksum of the step. The statements of SQL and
+// SQLByDialect actions are hashed with their whitespace normalized, other actions can't be inspected
+// and only their description and version are hashed.
+func (step *Step) Checksum() string {
+	var b strings.Builder
+	switch action := step.Action.(type) {
+	case SQL:
+		b.WriteString("sql\n")
+		for _, query := range action {
+			b.WriteString(normalizeSQL(query))
+			b.WriteString("\n")
+		}
+	case SQLByDialect:
+		for _, dialect := range []struct {
+			name string
+			sql  SQL
+		}{{"postgres", action.postgres}, {"cockroach", action.cockroach}} {
+			fmt.Fprintf(&b, "sql %s\n", dialect.name)
+			for _, query := range dialect.sql {
+				b.WriteString(normalizeSQL(query))
+				b.WriteString("\n")
+			}
+		}
+	case BatchedFunc:
+		fmt.Fprintf(&b, "batched\n%s\n%s\n%s\n%d\n",
+			normalizeSQL(action.Select), normalizeSQL(action.Update), normalizeSQL(action.Count), action.BatchSize)
+	default:
+		fmt.Fprintf(&b, "func\n%d\n%s\n", step.Version, step.Description)
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeSQL trims the query and collapses its whitespace, so that
+// reformatting a query doesn't change its checksum.
+func normalizeSQL(query string) string {
+	return strings.TrimSuffix(strings.Join(strings.Fields(query), " "), ";")
+}
+
+// verifyChecksums compares the checksums of the steps applied to the database,
+// up to its current version, against the checksums of the steps. Steps that
+// were applied before checksums were recorded are not verified.
+func (migration *Migration) verifyChecksums(ctx context.Context, log *zap.Logger, db tagsql.DB, version int) (err error) {
+	if err := migration.ValidTableName(); err != nil {
+		return err
+	}
+
+	/* #nosec G202 */ // Table name is white listed by the ValidTableName method
+	rows, err := db.Query(ctx, rebind(db, `
+		SELECT version, checksum FROM `+migration.historyTable()+`
+		WHERE version <= ? AND checksum IS NOT NULL
+		ORDER BY started_at`), version)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errs.Combine(err, rows.Close()) }()
+
+	// a step that was undone and applied again is verified by its last record.
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return err
+		}
+		applied[version] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var mismatched []string
+	for _, step := range migration.Steps {
+		if step.DB == nil || *step.DB != db {
+			continue
+		}
+		checksum, ok := applied[step.Version]
+		if !ok || checksum == step.Checksum() {
+			continue
+		}
+		// the database was created from the baseline of the step's version.
+		if baseline := migration.Baseline; baseline != nil && baseline.Version == step.Version && checksum == baseline.Checksum() {
+			continue
+		}
+
+		log.Error("Migration step was changed after it was applied",
+			zap.Int("version", step.Version),
+			zap.String("description", step.Description),
+			zap.String("applied checksum", checksum),
+			zap.String("checksum", step.Checksum()))
+		mismatched = append(mismatched, fmt.Sprintf("%d (%s)", step.Version, step.Description))
+	}
+	if len(mismatched) == 0 {
+		return nil
+	}
+
+	if migration.IgnoreChecksumMismatch {
+		log.Warn("Ignoring changed migration steps", zap.Strings("steps", mismatched))
+		return nil
+	}
+	return ErrChecksumMismatch.New("steps were changed after they were applied: %s", strings.Join(mismatched, ", "))
+}
@@ -0,0 +1,36 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"storj.io/private/tagsql"
+)
+
+// Component is a database of a peer that is migrated on its own, for testing
+// the migrations of every database of the peer the same way.
+type Component struct {
+	// Name identifies the component, the snapshots of its migration are in
+	// the testdata directory of the same name.
+	Name string
+	// Schema is the dbx script of the component, the result of the migration
+	// must match it. It's empty for the components without a dbx schema.
+	Schema string
+	// Migration is the production migration of the component.
+	Migration *Migration
+	// TestMigration is the migration used by the tests, nil when the tests
+	// use the production migration.
+	TestMigration *Migration
+	// DB is the raw access to the database of the component.
+	DB tagsql.DB
+}
+
+// Tables returns the tables that the migration of the component keeps for
+// itself, they aren't part of the snapshots of the component.
+func (component Component) Tables() []string {
+	return []string{
+		component.Migration.Table,
+		component.Migration.historyTable(),
+		component.Migration.Table + "_lock",
+	}
+}
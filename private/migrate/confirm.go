@@ -0,0 +1,32 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"github.com/zeebo/errs"
+)
+
+// ErrConfirmationRequired is when Run stops before a step that requires
+// confirmation and its version is not confirmed.
+var ErrConfirmationRequired = errs.Class("migration confirmation required")
+
+// confirmed returns whether the step may run, because it doesn't require
+// confirmation or its version is confirmed.
+func (migration *Migration) confirmed(step *Step) bool {
+	if !step.RequiresConfirmation {
+		return true
+	}
+	for _, version := range migration.ConfirmedVersions {
+		if version == step.Version {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmationError returns the error for a step that isn't confirmed.
+func confirmationError(step *Step) error {
+	return ErrConfirmationRequired.New("step %d (%s) requires confirmation of version %d: %s",
+		step.Version, step.Description, step.Version, step.ConfirmationReason)
+}
@@ -0,0 +1,194 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+
+	pgxerrcode "github.com/jackc/pgerrcode"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/context2"
+	"storj.io/private/dbutil/pgutil/pgerrcode"
+	"storj.io/private/dbutil/txutil"
+	"storj.io/private/tagsql"
+)
+
+// ErrLocked is when another migration holds the lock of the database.
+var ErrLocked = errs.Class("migration locked")
+
+// driverKind is the kind of database behind a driver.
+type driverKind int
+
+const (
+	// driverOther are databases that are not shared between processes, i.e. sqlite.
+	driverOther driverKind = iota
+	// driverPostgres are postgres databases.
+	driverPostgres
+	// driverCockroach are cockroach databases.
+	driverCockroach
+)
+
+// driverKindOf detects the kind of the database by its driver, without
+// importing the specific packages.
+func driverKindOf(db tagsql.DB) driverKind {
+	typ := reflect.TypeOf(db.Driver())
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ.PkgPath() == "storj.io/private/dbutil/cockroachutil" && typ.Name() == "Driver":
+		return driverCockroach
+	case typ.PkgPath() == "github.com/jackc/pgx/v4/stdlib" && typ.Name() == "Driver",
+		typ.PkgPath() == "github.com/lib/pq" && typ.Name() == "Driver":
+		return driverPostgres
+	default:
+		return driverOther
+	}
+}
+
+// dbLocks are the migration locks held on the databases of a migration.
+type dbLocks struct {
+	migration *Migration
+	unlock    map[tagsql.DB]func() error
+}
+
+// acquire locks the database unless it's locked already. It waits for
+// another migration to release its lock, or fails with ErrLocked when the
+// migration is not allowed to wait.
+func (locks *dbLocks) acquire(ctx context.Context, log *zap.Logger, db tagsql.DB) (err error) {
+	if _, ok := locks.unlock[db]; ok {
+		return nil
+	}
+	if err := locks.migration.ValidTableName(); err != nil {
+		return err
+	}
+
+	// postgres has advisory locks, cockroach doesn't and the row of a lock
+	// table is locked instead.
+	var unlock func() error
+	switch driverKindOf(db) {
+	case driverPostgres:
+		unlock, err = locks.migration.lockAdvisory(ctx, db)
+	case driverCockroach:
+		unlock, err = locks.migration.lockRow(ctx, db)
+	default:
+		unlock = func() error { return nil }
+	}
+	if err != nil {
+		return err
+	}
+
+	if locks.unlock == nil {
+		locks.unlock = make(map[tagsql.DB]func() error)
+	}
+	locks.unlock[db] = unlock
+	log.Debug("Acquired migration lock", zap.String("table", locks.migration.Table))
+	return nil
+}
+
+// release releases all the held locks.
+func (locks *dbLocks) release() error {
+	var group errs.Group
+	for db, unlock := range locks.unlock {
+		group.Add(unlock())
+		delete(locks.unlock, db)
+	}
+	return group.Err()
+}
+
+// lockAdvisory acquires an advisory lock keyed by the schema and the name of the
+// versions table. The lock is held by a dedicated connection until unlock.
+func (migration *Migration) lockAdvisory(ctx context.Context, db tagsql.DB) (unlock func() error, err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			err = errs.Combine(err, conn.Close())
+		}
+	}()
+
+	const key = `hashtext(coalesce(current_schema(), '') || '.' || $1)`
+	if migration.NoWait {
+		var locked bool
+		err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(`+key+`)`, migration.Table).Scan(&locked)
+		if err != nil {
+			return nil, err
+		}
+		if !locked {
+			return nil, ErrLocked.New("%s is migrated by another process", migration.Table)
+		}
+	} else {
+		_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock(`+key+`)`, migration.Table)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func() error {
+		// the connection goes back to the pool, so the lock must be released
+		// even when the migration was canceled.
+		_, err := conn.ExecContext(context2.WithoutCancellation(ctx), `SELECT pg_advisory_unlock(`+key+`)`, migration.Table)
+		return errs.Combine(err, conn.Close())
+	}, nil
+}
+
+// lockRow locks the row of the lock table in a transaction that is held open
+// until unlock.
+func (migration *Migration) lockRow(ctx context.Context, db tagsql.DB) (unlock func() error, err error) {
+	lockTable := migration.Table + "_lock"
+
+	err = txutil.WithTx(ctx, db, nil, func(ctx context.Context, tx tagsql.Tx) error {
+		_, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+lockTable+` (id int PRIMARY KEY)`)
+		if err != nil {
+			return err
+		}
+
+		// the row is only inserted when missing, because writing it would wait
+		// for the lock held by another migration.
+		var count int
+		err = tx.QueryRow(ctx, `SELECT count(*) FROM `+lockTable).Scan(&count)
+		if err != nil || count > 0 {
+			return err
+		}
+		_, err = tx.Exec(ctx, `INSERT INTO `+lockTable+` (id) VALUES (0) ON CONFLICT DO NOTHING`)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	/* #nosec G202 */ // Table name is white listed by the ValidTableName method
+	query := `SELECT id FROM ` + lockTable + ` WHERE id = 0 FOR UPDATE`
+	if migration.NoWait {
+		query += ` NOWAIT`
+	}
+	_, err = tx.Exec(ctx, query)
+	if err != nil {
+		if pgerrcode.FromError(err) == pgxerrcode.LockNotAvailable {
+			return nil, errs.Combine(ErrLocked.New("%s is migrated by another process", migration.Table), tx.Rollback())
+		}
+		return nil, errs.Combine(err, tx.Rollback())
+	}
+
+	return func() error {
+		// the transaction is rolled back already when the migration was canceled.
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			return err
+		}
+		return nil
+	}, nil
+}
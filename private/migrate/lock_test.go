@@ -0,0 +1,103 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/private/dbutil/pgtest"
+	"storj.io/private/dbutil/pgutil"
+	"storj.io/private/dbutil/tempdb"
+	"storj.io/private/tagsql"
+	"storj.io/storj/private/migrate"
+)
+
+func TestConcurrentMigration(t *testing.T) {
+	pgtest.Run(t, func(ctx *testcontext.Context, t *testing.T, connstr string) {
+		db, err := tempdb.OpenUnique(ctx, connstr, "lock-")
+		require.NoError(t, err)
+		defer ctx.Check(db.Close)
+
+		var testDB tagsql.DB = &postgresDB{DB: db.DB}
+
+		var applied [2]int64
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		steps := []*migrate.Step{
+			{
+				DB:          &testDB,
+				Description: "Initialize Table",
+				Version:     1,
+				Action: migrate.Func(func(ctx context.Context, log *zap.Logger, _ tagsql.DB, tx tagsql.Tx) error {
+					atomic.AddInt64(&applied[0], 1)
+					close(started)
+					<-release
+					_, err := tx.Exec(ctx, `CREATE TABLE users (id int)`)
+					return err
+				}),
+			},
+			{
+				DB:          &testDB,
+				Description: "Add Column",
+				Version:     2,
+				Action: migrate.Func(func(ctx context.Context, log *zap.Logger, _ tagsql.DB, tx tagsql.Tx) error {
+					atomic.AddInt64(&applied[1], 1)
+					_, err := tx.Exec(ctx, `ALTER TABLE users ADD COLUMN name text`)
+					return err
+				}),
+			},
+		}
+
+		first := &migrate.Migration{Table: "versions", Steps: steps}
+		second := &migrate.Migration{Table: "versions", Steps: steps}
+		noWait := &migrate.Migration{Table: "versions", Steps: steps, NoWait: true}
+
+		firstDone := make(chan error, 1)
+		go func() { firstDone <- first.Run(ctx, zaptest.NewLogger(t).Named("first")) }()
+		<-started
+
+		// a migration that is not allowed to wait fails right away.
+		err = noWait.Run(ctx, zaptest.NewLogger(t).Named("no-wait"))
+		require.True(t, migrate.ErrLocked.Has(err), err)
+
+		// a migration that waits doesn't start before the first one finished.
+		secondDone := make(chan error, 1)
+		go func() { secondDone <- second.Run(ctx, zaptest.NewLogger(t).Named("second")) }()
+		select {
+		case err := <-secondDone:
+			t.Fatalf("second migration finished while the first one holds the lock: %v", err)
+		case <-time.After(time.Second):
+		}
+
+		close(release)
+		require.NoError(t, <-firstDone)
+		require.NoError(t, <-secondDone)
+
+		// every step was applied once.
+		require.EqualValues(t, 1, atomic.LoadInt64(&applied[0]))
+		require.EqualValues(t, 1, atomic.LoadInt64(&applied[1]))
+
+		version, err := first.CurrentVersion(ctx, nil, testDB)
+		require.NoError(t, err)
+		require.Equal(t, 2, version)
+
+		schema, err := pgutil.QuerySchema(ctx, db.DB)
+		require.NoError(t, err)
+		users, ok := schema.FindTable("users")
+		require.True(t, ok)
+		require.Equal(t, []string{"id", "name"}, users.ColumnNames())
+
+		// the lock is released once the migrations are done.
+		require.NoError(t, noWait.Run(ctx, zaptest.NewLogger(t).Named("no-wait")))
+	})
+}
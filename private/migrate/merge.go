@@ -0,0 +1,61 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+// Merge merges the baseline and the steps of the migration into a single step
+// of the latest version, e.g. to set up test databases in one go. The steps up
+// to the baseline are part of it already and skipped. Only SQL can be merged,
+// the CONCURRENTLY keyword of NoTransaction steps is removed since the merged
+// step runs in a transaction.
+func (migration *Migration) Merge(description string) (*Step, error) {
+	if err := migration.ValidateSteps(); err != nil {
+		return nil, err
+	}
+
+	merged := &Step{Description: description, Version: -1}
+	var sql SQL
+
+	add := func(step *Step) error {
+		if merged.DB == nil {
+			merged.DB = step.DB
+		} else if step.DB != merged.DB {
+			return Error.New("step %d (%s) migrates another database", step.Version, step.Description)
+		}
+
+		action, ok := step.Action.(SQL)
+		if !ok {
+			return Error.New("step %d (%s) can't be merged, only SQL can be merged", step.Version, step.Description)
+		}
+		for _, query := range action {
+			if step.NoTransaction {
+				query = concurrentlyKeyword.ReplaceAllString(query, "$1")
+			}
+			sql = append(sql, query)
+		}
+
+		merged.Version = step.Version
+		return nil
+	}
+
+	if migration.Baseline != nil {
+		if err := add(migration.Baseline); err != nil {
+			return nil, err
+		}
+	}
+	for _, step := range migration.Steps {
+		if step.Version <= merged.Version {
+			continue
+		}
+		if err := add(step); err != nil {
+			return nil, err
+		}
+	}
+
+	if merged.Version < 0 {
+		return nil, Error.New("no steps to merge")
+	}
+
+	merged.Action = sql
+	return merged, nil
+}
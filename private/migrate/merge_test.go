@@ -0,0 +1,67 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"storj.io/private/tagsql"
+	"storj.io/storj/private/migrate"
+)
+
+func TestMerge(t *testing.T) {
+	var db tagsql.DB
+
+	squashed := &migrate.Migration{
+		Table:    "versions",
+		Baseline: baselineOf(&db),
+		Steps: append(baselineSteps(&db), &migrate.Step{
+			DB:            &db,
+			Description:   "Index Emails",
+			Version:       5,
+			NoTransaction: true,
+			Action:        migrate.SQL{`CREATE INDEX CONCURRENTLY users_email_index ON users ( email )`},
+		}),
+	}
+
+	merged, err := squashed.Merge("Testing setup")
+	require.NoError(t, err)
+	require.Equal(t, &db, merged.DB)
+	require.Equal(t, "Testing setup", merged.Description)
+	require.Equal(t, 5, merged.Version)
+	require.Equal(t, migrate.SQL{
+		`CREATE TABLE users (id int NOT NULL, name text NOT NULL DEFAULT '', PRIMARY KEY ( id ))`,
+		`CREATE INDEX users_name_index ON users ( name )`,
+		`INSERT INTO users (id) VALUES (1)`,
+		`CREATE TABLE projects (id int NOT NULL, owner_id int NOT NULL REFERENCES users( id ) ON DELETE CASCADE, PRIMARY KEY ( id ))`,
+		`ALTER TABLE users ADD COLUMN email text`,
+		`CREATE INDEX users_email_index ON users ( email )`,
+	}, merged.Action)
+
+	// without a baseline all the steps are merged.
+	full := &migrate.Migration{Table: "versions", Steps: baselineSteps(&db)}
+	merged, err = full.Merge("Testing setup")
+	require.NoError(t, err)
+	require.Equal(t, 4, merged.Version)
+	require.Len(t, merged.Action, 6)
+
+	// go code can't be merged.
+	withFunc := &migrate.Migration{
+		Table: "versions",
+		Steps: append(baselineSteps(&db), &migrate.Step{
+			DB:          &db,
+			Description: "Func",
+			Version:     5,
+			Action: migrate.Func(func(ctx context.Context, log *zap.Logger, db tagsql.DB, tx tagsql.Tx) error {
+				return nil
+			}),
+		}),
+	}
+	_, err = withFunc.Merge("Testing setup")
+	require.Error(t, err)
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package migrategen generates the source of a test migration from a merged
+// migration step.
+package migrategen
+
+import (
+	"bytes"
+	"go/format"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/storj/private/migrate"
+)
+
+// Error is the error class of the generator.
+var Error = errs.Class("migrategen")
+
+// Config configures the generated source.
+type Config struct {
+	// Generator is named in the header of the source.
+	Generator string
+	// Package is the package of the source.
+	Package string
+	// Receiver is the receiver of the generated method, e.g. "db *satelliteDB".
+	Receiver string
+	// Func is the name of the generated method.
+	Func string
+	// DB is the expression of the database of the step, e.g. "&db.migrationDB".
+	DB string
+	// Table is the version table of the migration.
+	Table string
+}
+
+// Generate returns the gofmt'd source of a method that returns a migration of
+// the single step. The step must be a merged SQL step.
+func Generate(config Config, step *migrate.Step) ([]byte, error) {
+	sql, ok := step.Action.(migrate.SQL)
+	if !ok {
+		return nil, Error.New("step %d (%s) is not SQL", step.Version, step.Description)
+	}
+
+	var buffer bytes.Buffer
+	err := sourceTemplate.Execute(&buffer, struct {
+		Config
+		Step       *migrate.Step
+		Statements migrate.SQL
+	}{config, step, sql})
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+
+	formatted, err := format.Source(buffer.Bytes())
+	if err != nil {
+		return nil, Error.New("formatting failed: %v\n%s", err, buffer.Bytes())
+	}
+	return formatted, nil
+}
+
+// literal returns the statement as a Go string literal, raw when possible to
+// keep the statement readable.
+func literal(statement string) string {
+	if strings.ContainsAny(statement, "`\r") {
+		return strconv.Quote(statement)
+	}
+	return "`" + statement + "`"
+}
+
+var sourceTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"quote":   strconv.Quote,
+	"literal": literal,
+}).Parse(`// AUTOGENERATED BY {{.Generator}}
+// DO NOT EDIT.
+
+package {{.Package}}
+
+import "storj.io/storj/private/migrate"
+
+// {{.Func}} returns migration that can be used for testing.
+func ({{.Receiver}}) {{.Func}}() *migrate.Migration {
+	return &migrate.Migration{
+		Table: {{quote .Table}},
+		Steps: []*migrate.Step{
+			{
+				DB: {{.DB}},
+				Description: {{quote .Step.Description}},
+				Version: {{.Step.Version}},
+				Action: migrate.SQL{
+{{- range .Statements}}
+					{{literal .}},
+{{- end}}
+				},
+			},
+		},
+	}
+}
+`))
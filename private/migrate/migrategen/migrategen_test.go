@@ -0,0 +1,98 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrategen_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/private/tagsql"
+	"storj.io/storj/private/migrate"
+	"storj.io/storj/private/migrate/migrategen"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerate(t *testing.T) {
+	var db tagsql.DB
+
+	migration := &migrate.Migration{
+		Table: "versions",
+		Baseline: &migrate.Step{
+			DB:          &db,
+			Description: "Baseline",
+			Version:     2,
+			Action: migrate.SQL{
+				`CREATE TABLE users (
+					id int NOT NULL,
+					name text NOT NULL DEFAULT '',
+					PRIMARY KEY ( id )
+				)`,
+			},
+		},
+		Steps: []*migrate.Step{
+			{
+				DB:          &db,
+				Description: "Initialize Table",
+				Version:     1,
+				Action:      migrate.SQL{`CREATE TABLE users (id int NOT NULL, PRIMARY KEY ( id ))`},
+			},
+			{
+				DB:          &db,
+				Description: "Add Name",
+				Version:     2,
+				Action:      migrate.SQL{`ALTER TABLE users ADD COLUMN name text NOT NULL DEFAULT ''`},
+			},
+			{
+				DB:          &db,
+				Description: "Add Projects",
+				Version:     3,
+				Action: migrate.SQL{
+					`CREATE TABLE projects (id int NOT NULL, name text, PRIMARY KEY ( id ))`,
+					"INSERT INTO projects (id, name) VALUES (1, 'with `backticks`')",
+				},
+			},
+			{
+				DB:            &db,
+				Description:   "Index Names",
+				Version:       4,
+				NoTransaction: true,
+				Action:        migrate.SQL{`CREATE INDEX CONCURRENTLY users_name_index ON users ( name )`},
+			},
+		},
+	}
+
+	step, err := migration.Merge("Testing setup")
+	require.NoError(t, err)
+
+	source, err := migrategen.Generate(migrategen.Config{
+		Generator: "migrategen_test.go",
+		Package:   "example",
+		Receiver:  "db *exampleDB",
+		Func:      "testMigration",
+		DB:        "&db.migrationDB",
+		Table:     migration.Table,
+	}, step)
+	require.NoError(t, err)
+
+	const golden = "testdata/synthetic.golden"
+	if *update {
+		require.NoError(t, ioutil.WriteFile(golden, source, 0644))
+		return
+	}
+
+	expected, err := ioutil.ReadFile(golden)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(source), "run the test with -update to update the golden file")
+}
+
+func TestGenerateNotSQL(t *testing.T) {
+	_, err := migrategen.Generate(migrategen.Config{}, &migrate.Step{
+		Action: migrate.NewSQLByDialect(migrate.SQL{}, migrate.SQL{}),
+	})
+	require.True(t, migrategen.Error.Has(err))
+}
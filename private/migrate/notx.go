@@ -0,0 +1,88 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"go.uber.org/zap"
+
+	"storj.io/private/dbutil/txutil"
+	"storj.io/private/tagsql"
+)
+
+// concurrentlyKeyword matches the CONCURRENTLY keyword of index statements.
+var concurrentlyKeyword = regexp.MustCompile(`(?i)\b((?:CREATE\s+(?:UNIQUE\s+)?|DROP\s+)INDEX)\s+CONCURRENTLY\b`)
+
+// withoutTransaction returns whether the step must run outside of a
+// transaction on the database. Only postgres refuses to build indexes
+// concurrently in a transaction.
+func withoutTransaction(step *Step, db tagsql.DB) bool {
+	return step.NoTransaction && driverKindOf(db) == driverPostgres
+}
+
+// inTransaction returns the action of a NoTransaction step, that runs in the
+// transaction of the step on a database that doesn't need the step to run
+// outside of it. The CONCURRENTLY keyword is removed, cockroach builds all
+// indexes without blocking writes.
+func inTransaction(step *Step) Action {
+	return Func(func(ctx context.Context, log *zap.Logger, db tagsql.DB, tx tagsql.Tx) error {
+		statements, err := stepSQL(step, db)
+		if err != nil {
+			return err
+		}
+
+		var sql SQL
+		for _, query := range statements {
+			sql = append(sql, concurrentlyKeyword.ReplaceAllString(query, "$1"))
+		}
+		return sql.Run(ctx, log, db, tx)
+	})
+}
+
+// runWithoutTransaction runs the statements of the step one by one outside of a
+// transaction and records the version afterwards. A failed statement can't be
+// rolled back, the version isn't recorded then.
+func (migration *Migration) runWithoutTransaction(ctx context.Context, log *zap.Logger, db tagsql.DB, step *Step) (duration time.Duration, err error) {
+	statements, err := stepSQL(step, db)
+	if err != nil {
+		return 0, err
+	}
+
+	started := time.Now()
+	progress := StepProgress(ctx)
+	for i, query := range statements {
+		err := progress.statement(i, func() error {
+			_, err := db.Exec(ctx, rebind(db, query))
+			return err
+		})
+		if err != nil {
+			return 0, Error.New("step %d (%s) failed outside of a transaction, the statements before it are not rolled back "+
+				"and a partially built index may have to be dropped manually: %v", step.Version, step.Description, err)
+		}
+	}
+	duration = time.Since(started)
+
+	return duration, txutil.WithTx(ctx, db, nil, func(ctx context.Context, tx tagsql.Tx) error {
+		err := migration.addVersion(ctx, tx, db, step.Version)
+		if err != nil {
+			return err
+		}
+		return migration.addHistory(ctx, tx, db, step, started, duration)
+	})
+}
+
+// stepSQL returns the statements of the action of a NoTransaction step.
+func stepSQL(step *Step, db tagsql.DB) (SQL, error) {
+	switch action := step.Action.(type) {
+	case SQL:
+		return action, nil
+	case SQLByDialect:
+		return action.resolve(db)
+	default:
+		return nil, Error.New("step %d (%s) can only run SQL outside of a transaction", step.Version, step.Description)
+	}
+}
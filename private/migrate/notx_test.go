@@ -0,0 +1,103 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/private/dbutil"
+	"storj.io/private/dbutil/pgtest"
+	"storj.io/private/dbutil/pgutil"
+	"storj.io/private/dbutil/tempdb"
+	"storj.io/private/tagsql"
+	"storj.io/storj/private/migrate"
+)
+
+func TestNoTransaction(t *testing.T) {
+	pgtest.Run(t, func(ctx *testcontext.Context, t *testing.T, connstr string) {
+		db, err := tempdb.OpenUnique(ctx, connstr, "notx-")
+		require.NoError(t, err)
+		defer ctx.Check(db.Close)
+
+		var testDB tagsql.DB = &postgresDB{DB: db.DB}
+
+		m := migrate.Migration{
+			Table: "versions",
+			Steps: []*migrate.Step{
+				{
+					DB:          &testDB,
+					Description: "Initialize Table",
+					Version:     1,
+					Action: migrate.SQL{
+						`CREATE TABLE users (id int NOT NULL, name text, value int, PRIMARY KEY ( id ))`,
+						`INSERT INTO users (id, name, value) SELECT i, 'user' || i::text, i % 10 FROM generate_series(1, 10000) AS i`,
+					},
+				},
+				{
+					DB:            &testDB,
+					Description:   "Index Names",
+					Version:       2,
+					NoTransaction: true,
+					Action: migrate.SQL{
+						`CREATE INDEX CONCURRENTLY users_name_index ON users ( name )`,
+					},
+				},
+			},
+		}
+		require.NoError(t, m.Run(ctx, zaptest.NewLogger(t)))
+
+		version, err := m.CurrentVersion(ctx, nil, testDB)
+		require.NoError(t, err)
+		require.Equal(t, 2, version)
+
+		schema, err := pgutil.QuerySchema(ctx, db.DB)
+		require.NoError(t, err)
+		var indexes []string
+		for _, index := range schema.Indexes {
+			indexes = append(indexes, index.Name)
+		}
+		require.Contains(t, indexes, "users_name_index")
+
+		// a failed step doesn't record its version.
+		failing := m
+		failing.Steps = append(failing.Steps[:2:2], &migrate.Step{
+			DB:            &testDB,
+			Description:   "Unique Values",
+			Version:       3,
+			NoTransaction: true,
+			Action: migrate.SQL{
+				`CREATE UNIQUE INDEX CONCURRENTLY users_value_index ON users ( value )`,
+			},
+		})
+		err = failing.Run(ctx, zaptest.NewLogger(t))
+		require.Error(t, err)
+		if db.Implementation == dbutil.Postgres {
+			require.Contains(t, err.Error(), "dropped manually")
+		}
+
+		version, err = m.CurrentVersion(ctx, nil, testDB)
+		require.NoError(t, err)
+		require.Equal(t, 2, version)
+
+		// only SQL can run outside of a transaction.
+		invalid := m
+		invalid.Steps = append(invalid.Steps[:2:2], &migrate.Step{
+			DB:            &testDB,
+			Description:   "Batched",
+			Version:       3,
+			NoTransaction: true,
+			Action: migrate.BatchedFunc{
+				Select:    `SELECT id FROM users WHERE id > ? ORDER BY id LIMIT ?`,
+				Update:    `UPDATE users SET value = 0 WHERE id > ? AND id <= ?`,
+				Start:     0,
+				BatchSize: 100,
+			},
+		})
+		require.Error(t, invalid.ValidateSteps())
+	})
+}
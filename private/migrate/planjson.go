@@ -0,0 +1,118 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"storj.io/private/tagsql"
+)
+
+// PlannedStep is a pending step in the machine-readable plan of a migration.
+type PlannedStep struct {
+	Version     int      `json:"version"`
+	Description string   `json:"description"`
+	SQL         []string `json:"sql"`
+	// Transactional is false for the steps that run outside of a transaction.
+	Transactional bool `json:"transactional"`
+	// EstimatedDestructive is a heuristic for the steps with statements that
+	// drop, truncate or delete, they are worth a closer look in a review.
+	EstimatedDestructive bool `json:"estimated_destructive"`
+	// RequiresConfirmation is set for the steps that only run with an
+	// explicit confirmation of their version.
+	RequiresConfirmation bool   `json:"requires_confirmation,omitempty"`
+	ConfirmationReason   string `json:"confirmation_reason,omitempty"`
+}
+
+var (
+	destructiveKeyword = regexp.MustCompile(`(?i)\b(DROP|TRUNCATE|DELETE)\b`)
+	onDeleteClause     = regexp.MustCompile(`(?i)\bON\s+DELETE\b`)
+)
+
+// PlanJSON returns the steps above the current version as a JSON array of
+// PlannedStep, e.g. to attach the DDL of an upgrade to a change ticket. An
+// empty database, with a negative current version, is created from the
+// baseline. The output has no timestamps, the plans of two releases can be
+// diffed. Opaque actions have no SQL.
+func (migration *Migration) PlanJSON(currentVersion int) ([]byte, error) {
+	err := migration.ValidateSteps()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*Step
+	if baseline := migration.Baseline; baseline != nil && currentVersion < 0 {
+		pending = append(pending, baseline)
+		currentVersion = baseline.Version
+	}
+	for _, step := range migration.Steps {
+		if step.Version > currentVersion {
+			pending = append(pending, step)
+		}
+	}
+
+	plan := []PlannedStep{}
+	for _, step := range pending {
+		planned, err := planStep(step)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, planned)
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	return data, Error.Wrap(err)
+}
+
+// planStep returns the statements of the step as they would be executed.
+func planStep(step *Step) (PlannedStep, error) {
+	var db tagsql.DB
+	if step.DB != nil {
+		db = *step.DB
+	}
+
+	planned := PlannedStep{
+		Version:       step.Version,
+		Description:   step.Description,
+		SQL:           []string{},
+		Transactional: !step.NoTransaction,
+
+		RequiresConfirmation: step.RequiresConfirmation,
+		ConfirmationReason:   step.ConfirmationReason,
+	}
+	if db != nil {
+		planned.Transactional = !withoutTransaction(step, db)
+	}
+
+	var statements SQL
+	switch action := step.Action.(type) {
+	case SQL:
+		statements = action
+	case SQLByDialect:
+		if db == nil {
+			return PlannedStep{}, Error.New("step %d (%s) has no database to resolve the dialect with", step.Version, step.Description)
+		}
+		resolved, err := action.resolve(db)
+		if err != nil {
+			return PlannedStep{}, err
+		}
+		statements = resolved
+	}
+
+	for _, query := range statements {
+		if db != nil {
+			query = rebind(db, query)
+		}
+		query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+		planned.SQL = append(planned.SQL, query)
+
+		// the referential actions of a foreign key don't delete anything.
+		if destructiveKeyword.MatchString(onDeleteClause.ReplaceAllString(query, "")) {
+			planned.EstimatedDestructive = true
+		}
+	}
+	return planned, nil
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"storj.io/private/tagsql"
+	"storj.io/storj/private/migrate"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestPlanJSON(t *testing.T) {
+	// the plan doesn't connect to the database, it only needs the driver.
+	sqlDB, err := sql.Open("pgx", "postgres://localhost/plan")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, sqlDB.Close()) }()
+
+	var testDB tagsql.DB = &postgresDB{DB: tagsql.Wrap(sqlDB)}
+
+	m := migrate.Migration{
+		Table: "versions",
+		Baseline: &migrate.Step{
+			DB:          &testDB,
+			Description: "Baseline",
+			Version:     2,
+			Action: migrate.SQL{
+				`CREATE TABLE users (id int, project_id int REFERENCES projects( id ) ON DELETE CASCADE)`,
+			},
+		},
+		Steps: []*migrate.Step{
+			{
+				DB:          &testDB,
+				Description: "Initialize Table",
+				Version:     1,
+				Action:      migrate.SQL{`CREATE TABLE users (id int)`},
+			},
+			{
+				DB:          &testDB,
+				Description: "Add Reference",
+				Version:     2,
+				Action:      migrate.SQL{`ALTER TABLE users ADD COLUMN project_id int REFERENCES projects( id ) ON DELETE CASCADE`},
+			},
+			{
+				DB:          &testDB,
+				Description: "Move files",
+				Version:     3,
+				Action: migrate.Func(func(_ context.Context, log *zap.Logger, _ tagsql.DB, tx tagsql.Tx) error {
+					return nil
+				}),
+			},
+			{
+				DB:            &testDB,
+				Description:   "Index Users",
+				Version:       4,
+				NoTransaction: true,
+				Action:        migrate.SQL{`CREATE INDEX CONCURRENTLY users_project_id ON users ( project_id );`},
+			},
+			{
+				DB:          &testDB,
+				Description: "Drop Column",
+				Version:     5,
+				Action: migrate.NewSQLByDialect(
+					migrate.SQL{`DELETE FROM users WHERE id = ?`, `ALTER TABLE users DROP COLUMN name`},
+					migrate.SQL{`ALTER TABLE users DROP COLUMN name`},
+				),
+			},
+		},
+	}
+
+	plan, err := m.PlanJSON(-1)
+	require.NoError(t, err)
+
+	const golden = "testdata/plan.golden"
+	if *update {
+		require.NoError(t, ioutil.WriteFile(golden, plan, 0644))
+		return
+	}
+
+	expected, err := ioutil.ReadFile(golden)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(plan), "run the test with -update to update the golden file")
+
+	// the plan is stable across runs.
+	again, err := m.PlanJSON(-1)
+	require.NoError(t, err)
+	require.Equal(t, plan, again)
+
+	// a migrated database only has the steps above its version.
+	plan, err = m.PlanJSON(4)
+	require.NoError(t, err)
+	require.Contains(t, string(plan), `"version": 5`)
+	require.NotContains(t, string(plan), `"version": 4`)
+
+	plan, err = m.PlanJSON(5)
+	require.NoError(t, err)
+	require.Equal(t, "[]", string(plan))
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultSlowStatementThreshold is how long a statement of a step runs before
+// its progress is logged, unless the migration sets SlowStatementThreshold.
+const DefaultSlowStatementThreshold = 10 * time.Second
+
+// Progress reports the progress of a running step. Func actions get the
+// Progress of their step with StepProgress.
+type Progress struct {
+	log       *zap.Logger
+	started   time.Time
+	threshold time.Duration
+
+	// statements is the number of statements that the step executed.
+	statements int
+}
+
+// progressKey is the context key of the Progress of a step.
+type progressKey struct{}
+
+// newProgress returns the Progress of a step that starts now.
+func (migration *Migration) newProgress(log *zap.Logger) *Progress {
+	threshold := migration.SlowStatementThreshold
+	if threshold == 0 {
+		threshold = DefaultSlowStatementThreshold
+	}
+	return &Progress{log: log, started: time.Now(), threshold: threshold}
+}
+
+// withProgress returns the context of a step with its Progress.
+func withProgress(ctx context.Context, progress *Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// StepProgress returns the Progress of the step that runs with the context.
+// Outside of a migration the reports are discarded.
+func StepProgress(ctx context.Context) *Progress {
+	if progress, ok := ctx.Value(progressKey{}).(*Progress); ok {
+		return progress
+	}
+	return &Progress{log: zap.NewNop(), started: time.Now()}
+}
+
+// Report logs that done of total units of the work of the step are finished,
+// e.g. rows. A negative total means that the total is unknown. The caller
+// decides how often it reports.
+func (progress *Progress) Report(done, total int64) {
+	fields := []zap.Field{zap.Int64("done", done)}
+	if total >= 0 {
+		fields = append(fields, zap.Int64("total", total))
+	}
+	fields = append(fields, zap.Duration("elapsed", time.Since(progress.started)))
+	progress.log.Info("Step progress", fields...)
+}
+
+// statement executes the statement with the index within the step. A
+// statement that runs longer than the threshold is logged at debug level every
+// threshold while it runs and once more when it's finished.
+func (progress *Progress) statement(index int, exec func() error) error {
+	progress.statements++
+	if progress.threshold <= 0 {
+		return exec()
+	}
+
+	started := time.Now()
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(progress.threshold)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				progress.log.Debug("Statement running", zap.Int("statement", index), zap.Duration("elapsed", now.Sub(started)))
+			}
+		}
+	}()
+
+	err := exec()
+	close(done)
+	<-stopped
+
+	if elapsed := time.Since(started); elapsed >= progress.threshold {
+		progress.log.Debug("Statement finished", zap.Int("statement", index), zap.Duration("elapsed", elapsed))
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"context"
+	"time"
+
+	pgxerrcode "github.com/jackc/pgerrcode"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/sync2"
+	"storj.io/private/dbutil/pgutil/pgerrcode"
+	"storj.io/private/tagsql"
+)
+
+// DefaultStepAttempts is how often a step that fails with a retryable error
+// is run, unless the migration sets StepAttempts.
+const DefaultStepAttempts = 5
+
+const (
+	retryBackoff    = 100 * time.Millisecond
+	retryMaxBackoff = 5 * time.Second
+)
+
+// needsRetry returns whether the error asks to run the transaction again,
+// like cockroachutil.NeedsRetry classifies it. Connection errors are not
+// retried, because the step may have been committed before the connection
+// broke.
+func needsRetry(err error) bool {
+	code := pgerrcode.FromError(err)
+	return code == pgxerrcode.SerializationFailure || code == "CR000"
+}
+
+// stepAttempts returns how often a step may be run.
+func (migration *Migration) stepAttempts() int {
+	if migration.StepAttempts > 0 {
+		return migration.StepAttempts
+	}
+	return DefaultStepAttempts
+}
+
+// retryable returns whether the step may run again after a retryable error.
+// The statements of SQL and SQLByDialect actions run in the transaction of the
+// step, which is rolled back, while a Func action may run statements on the
+// database outside of it, which would run twice.
+func retryable(step *Step) bool {
+	if step.Idempotent {
+		return true
+	}
+	switch step.Action.(type) {
+	case SQL, SQLByDialect:
+		return true
+	}
+	return false
+}
+
+// withRetry runs fn in a transaction and runs it again with a backoff when it
+// fails with a retryable error, e.g. when cockroach background jobs touch
+// the same ranges as the step. Steps that are not retryable run once.
+func (migration *Migration) withRetry(ctx context.Context, log *zap.Logger, db tagsql.DB, step *Step, fn func(ctx context.Context, tx tagsql.Tx) error) error {
+	attempts := 1
+	if retryable(step) {
+		attempts = migration.stepAttempts()
+	}
+
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := withTxOnce(ctx, db, fn)
+		if err == nil || !needsRetry(err) || attempt >= attempts || ctx.Err() != nil {
+			return err
+		}
+
+		log.Warn("retrying step",
+			zap.Int("version", step.Version),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		if !sync2.Sleep(ctx, backoff) {
+			return errs.Combine(err, ctx.Err())
+		}
+		backoff *= 2
+		if backoff > retryMaxBackoff {
+			backoff = retryMaxBackoff
+		}
+	}
+}
+
+// withTxOnce runs fn in a transaction without the retries of txutil.WithTx,
+// so that withRetry decides about them.
+func withTxOnce(ctx context.Context, db tagsql.DB, fn func(ctx context.Context, tx tagsql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			err = errs.Combine(err, tx.Rollback())
+		}
+	}()
+
+	return fn(ctx, tx)
+}
@@ -0,0 +1,50 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package migrate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zeebo/errs"
+)
+
+// NoTimeout disables the timeout of a step.
+const NoTimeout time.Duration = -1
+
+// ErrTimeout is when a step runs longer than its timeout.
+var ErrTimeout = errs.Class("migration step timeout")
+
+// stepTimeout returns the timeout of the step, zero when it has none.
+func (migration *Migration) stepTimeout(step *Step) time.Duration {
+	switch {
+	case step.Timeout < 0:
+		return 0
+	case step.Timeout > 0:
+		return step.Timeout
+	default:
+		return migration.StepTimeout
+	}
+}
+
+// stepContext returns the context to run the step with, which expires with
+// the timeout of the step.
+func (migration *Migration) stepContext(ctx context.Context, step *Step) (context.Context, context.CancelFunc) {
+	timeout := migration.stepTimeout(step)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// stepError returns the error of a step that ran out of time as ErrTimeout,
+// so that it tells which step got stuck and for how long.
+func stepError(ctx, stepCtx context.Context, step *Step, started time.Time, err error) error {
+	if err == nil || ctx.Err() != nil || !errors.Is(stepCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return ErrTimeout.New("step %d (%s) timed out after running for %v: %v",
+		step.Version, step.Description, time.Since(started).Round(time.Millisecond), err)
+}
@@ -7,9 +7,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"io"
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/zeebo/errs"
@@ -26,6 +29,8 @@ var (
 	ErrValidateVersionMismatch = errs.Class("validate db version mismatch")
 	// ErrValidateMinVersion is when the migration version does not match the current database version.
 	ErrValidateMinVersion = errs.Class("validate minimum version")
+	// ErrIrreversible is when a down migration would have to undo a step that can't be undone.
+	ErrIrreversible = errs.Class("irreversible migration")
 )
 
 /*
@@ -42,9 +47,10 @@ Scenarios it doesn't handle properly.
 
 	In this case there's no easy way to rollback the moving of files.
 
-2. Undoing migrations.
+2. Undoing arbitrary migrations.
 
-	Intentionally left out, because we do not gain that much from currently.
+	Only steps with a Down action can be undone with RunDown. Steps that destroy
+	data must be marked Irreversible, because there is nothing to restore it from.
 
 3. Snapshotting the whole state.
 
@@ -56,10 +62,41 @@ Scenarios it doesn't handle properly.
 // Migration describes a migration steps.
 type Migration struct {
 	// Table is the table name to register the applied migration version.
+	// Every applied step is also recorded with its description, checksum,
+	// start time and duration in the table with the name suffixed with
+	// "_history". Run verifies the checksums of the applied steps.
 	// NOTE: Always validates its value with the ValidTableName method before it's
 	// concatenated in a query string for avoiding SQL injection attacks.
 	Table string
 	Steps []*Step
+
+	// Baseline optionally creates the full schema of its version on an empty
+	// database, so that only the steps above its version are replayed. The
+	// steps up to its version can be squashed into it. Databases that are
+	// migrated already are not touched by it.
+	Baseline *Step
+
+	// NoWait makes Run and RunDown fail with ErrLocked instead of waiting,
+	// when another process is migrating the same database.
+	NoWait bool
+	// IgnoreChecksumMismatch makes Run only log the applied steps that were
+	// changed since, instead of failing with ErrChecksumMismatch. It's meant
+	// for emergencies.
+	IgnoreChecksumMismatch bool
+	// StepTimeout limits how long a step may run, unless the step sets its
+	// own Timeout. Zero means no limit.
+	StepTimeout time.Duration
+	// StepAttempts limits how often a step that fails with a retryable error,
+	// e.g. a cockroach serialization failure, is run. Zero uses
+	// DefaultStepAttempts.
+	StepAttempts int
+	// ConfirmedVersions are the versions of the steps that require
+	// confirmation, which Run may apply.
+	ConfirmedVersions []int
+	// SlowStatementThreshold is how long a statement of a step runs before
+	// its progress is logged at debug level. Zero uses
+	// DefaultSlowStatementThreshold, a negative threshold disables it.
+	SlowStatementThreshold time.Duration
 }
 
 // Step describes a single step in migration.
@@ -73,6 +110,38 @@ type Step struct {
 	// SeparateTx marks a step as it should not be merged together for optimization.
 	// Cockroach cannot add a column and update the value in the same transaction.
 	SeparateTx bool
+
+	// NoTransaction runs the statements of a SQL or SQLByDialect action one
+	// by one outside of a transaction on postgres, e.g. for CREATE INDEX
+	// CONCURRENTLY. The version is recorded afterwards. Other databases run
+	// the statements in a transaction, without the CONCURRENTLY keyword.
+	NoTransaction bool
+
+	// Idempotent marks a step with a Func action that may run again when it
+	// fails with a retryable error, because it only uses the transaction or
+	// its statements can be repeated. Steps with SQL and SQLByDialect actions
+	// are always run again.
+	Idempotent bool
+
+	// Timeout limits how long the step may run, a step that runs out of time
+	// fails with ErrTimeout and its version isn't recorded. Zero uses the
+	// StepTimeout of the migration, NoTimeout disables the limit for known slow
+	// steps.
+	Timeout time.Duration
+
+	// Down undoes the Action of this step, it's optional.
+	Down Action
+	// Irreversible marks a step that can't be undone, because its Action
+	// destroys data. RunDown refuses to go below such a step.
+	Irreversible bool
+
+	// RequiresConfirmation makes Run stop before the step with
+	// ErrConfirmationRequired, unless its version is in ConfirmedVersions of
+	// the migration, so that a human is in the loop for dangerous steps like
+	// dropping columns or rewriting huge tables. ConfirmationReason tells
+	// why. Databases that are created from scratch don't need it.
+	RequiresConfirmation bool
+	ConfirmationReason   string
 }
 
 // Action is something that needs to be done.
@@ -83,6 +152,9 @@ type Action interface {
 // TargetVersion returns migration with steps upto specified version.
 func (migration *Migration) TargetVersion(version int) *Migration {
 	m := *migration
+	if m.Baseline != nil && m.Baseline.Version > version {
+		m.Baseline = nil
+	}
 	m.Steps = nil
 	for _, step := range migration.Steps {
 		if step.Version <= version {
@@ -106,7 +178,8 @@ func (migration *Migration) ValidTableName() error {
 	return nil
 }
 
-// ValidateSteps checks that the version for each migration step increments in order.
+// ValidateSteps checks that the version for each migration step increments in
+// order and that NoTransaction steps only run SQL.
 func (migration *Migration) ValidateSteps() error {
 	sorted := sort.SliceIsSorted(migration.Steps, func(i, j int) bool {
 		return migration.Steps[i].Version <= migration.Steps[j].Version
@@ -114,10 +187,21 @@ func (migration *Migration) ValidateSteps() error {
 	if !sorted {
 		return Error.New("steps have incorrect order")
 	}
+
+	for _, step := range migration.Steps {
+		if step.NoTransaction {
+			switch step.Action.(type) {
+			case SQL, SQLByDialect:
+			default:
+				return Error.New("step %d (%s): only SQL can run outside of a transaction", step.Version, step.Description)
+			}
+		}
+	}
 	return nil
 }
 
 // ValidateVersions checks that the version of the migration matches the state of the database.
+// It only reads, so that it works with read-only connections.
 func (migration *Migration) ValidateVersions(ctx context.Context, log *zap.Logger) error {
 	if err := migration.ValidateSteps(); err != nil {
 		return err
@@ -129,7 +213,7 @@ func (migration *Migration) ValidateVersions(ctx context.Context, log *zap.Logge
 	}
 
 	for database, expectedVersion := range expectedVersions {
-		currentVersion, err := migration.CurrentVersion(ctx, log, database)
+		currentVersion, err := migration.peekVersion(ctx, log, database)
 		if err != nil {
 			return ErrValidateVersionQuery.Wrap(err)
 		}
@@ -149,13 +233,25 @@ func (migration *Migration) ValidateVersions(ctx context.Context, log *zap.Logge
 	return nil
 }
 
-// Run runs the migration steps.
-func (migration *Migration) Run(ctx context.Context, log *zap.Logger) error {
-	err := migration.ValidateSteps()
+// Run runs the migration steps. Every database is locked for the migration
+// before its version is checked, so that concurrent migrations are serialized.
+func (migration *Migration) Run(ctx context.Context, log *zap.Logger) (err error) {
+	err = migration.ValidateSteps()
 	if err != nil {
 		return err
 	}
 
+	locks := dbLocks{migration: migration}
+	defer func() { err = errs.Combine(err, Error.Wrap(locks.release())) }()
+
+	if migration.Baseline != nil {
+		err = migration.runBaseline(ctx, log, &locks)
+		if err != nil {
+			return err
+		}
+	}
+
+	verified := make(map[tagsql.DB]bool)
 	initialSetup := false
 	for i, step := range migration.Steps {
 		step := step
@@ -171,6 +267,11 @@ func (migration *Migration) Run(ctx context.Context, log *zap.Logger) error {
 			return Error.New("step.DB is nil for step %d", step.Version)
 		}
 
+		err = locks.acquire(ctx, log, db)
+		if err != nil {
+			return Error.Wrap(err)
+		}
+
 		err = migration.ensureVersionTable(ctx, log, db)
 		if err != nil {
 			return Error.New("creating version table failed: %w", err)
@@ -184,29 +285,69 @@ func (migration *Migration) Run(ctx context.Context, log *zap.Logger) error {
 			initialSetup = true
 		}
 
+		if !verified[db] {
+			err = migration.verifyChecksums(ctx, log, db, version)
+			if err != nil {
+				return Error.Wrap(err)
+			}
+			verified[db] = true
+		}
+
 		if step.Version <= version {
 			continue
 		}
 
+		if !initialSetup && !migration.confirmed(step) {
+			return confirmationError(step)
+		}
+
 		stepLog := log.Named(strconv.Itoa(step.Version))
 		if !initialSetup {
 			stepLog.Info(step.Description)
 		}
 
-		err = txutil.WithTx(ctx, db, nil, func(ctx context.Context, tx tagsql.Tx) error {
-			err = step.Action.Run(ctx, stepLog, db, tx)
-			if err != nil {
-				return err
-			}
+		stepCtx, cancel := migration.stepContext(ctx, step)
+		stepStarted := time.Now()
 
-			err = migration.addVersion(ctx, tx, db, step.Version)
-			if err != nil {
-				return err
+		progress := migration.newProgress(stepLog)
+		stepCtx = withProgress(stepCtx, progress)
+
+		var duration time.Duration
+		if withoutTransaction(step, db) {
+			duration, err = migration.runWithoutTransaction(stepCtx, stepLog, db, step)
+		} else {
+			action := step.Action
+			if step.NoTransaction {
+				action = inTransaction(step)
 			}
-			return nil
-		})
+
+			err = migration.withRetry(stepCtx, stepLog, db, step, func(ctx context.Context, tx tagsql.Tx) error {
+				started := time.Now()
+				progress.statements = 0
+				err = action.Run(ctx, stepLog, db, tx)
+				if err != nil {
+					return err
+				}
+				// the action may have ignored the deadline of the step.
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				duration = time.Since(started)
+
+				err = migration.addVersion(ctx, tx, db, step.Version)
+				if err != nil {
+					return err
+				}
+
+				return migration.addHistory(ctx, tx, db, step, started, duration)
+			})
+		}
+		cancel()
 		if err != nil {
-			return Error.Wrap(err)
+			return Error.Wrap(stepError(ctx, stepCtx, step, stepStarted, err))
+		}
+		if !initialSetup {
+			stepLog.Info("Applied", zap.Int("version", step.Version), zap.Duration("duration", duration), zap.Int("statements", progress.statements))
 		}
 	}
 
@@ -224,7 +365,211 @@ func (migration *Migration) Run(ctx context.Context, log *zap.Logger) error {
 	return nil
 }
 
-// ensureVersionTable creates migration.Table table if not exists.
+// Status is the state of the databases of a migration.
+type Status struct {
+	// Current is the lowest version of the databases, -1 when a database
+	// isn't migrated at all.
+	Current int
+	// Latest is the version of the last step.
+	Latest int
+	// Pending are the steps that are not applied, in order.
+	Pending []*Step
+}
+
+// UpToDate returns whether all the steps are applied.
+func (status Status) UpToDate() bool {
+	return len(status.Pending) == 0
+}
+
+// Status returns the state of the databases of the migration. It only reads
+// the versions tables and doesn't create them.
+func (migration *Migration) Status(ctx context.Context, log *zap.Logger) (status Status, err error) {
+	err = migration.ValidateSteps()
+	if err != nil {
+		return Status{}, err
+	}
+
+	status = Status{Current: -1, Latest: -1}
+	versions := make(map[tagsql.DB]int)
+
+	// an empty database is created from the baseline, the steps up to the
+	// baseline are not pending then.
+	if baseline := migration.Baseline; baseline != nil && baseline.DB != nil && *baseline.DB != nil {
+		version, err := migration.peekVersion(ctx, log, *baseline.DB)
+		if err != nil {
+			return Status{}, Error.Wrap(err)
+		}
+		status.Current = version
+		status.Latest = baseline.Version
+		if version < 0 {
+			status.Pending = append(status.Pending, baseline)
+			version = baseline.Version
+		}
+		versions[*baseline.DB] = version
+	}
+
+	for _, step := range migration.Steps {
+		db := *step.DB
+		if db == nil {
+			return Status{}, Error.New("step.DB is nil for step %d", step.Version)
+		}
+		version, ok := versions[db]
+		if !ok {
+			version, err = migration.peekVersion(ctx, log, db)
+			if err != nil {
+				return Status{}, Error.Wrap(err)
+			}
+			if len(versions) == 0 || version < status.Current {
+				status.Current = version
+			}
+			versions[db] = version
+		}
+		status.Latest = step.Version
+		if step.Version > version {
+			status.Pending = append(status.Pending, step)
+		}
+	}
+	return status, nil
+}
+
+// Plan writes the pending steps of the migration to w without executing them.
+// The SQL of every step is written as it would be executed, other actions are
+// opaque and only their description is written. Neither the databases nor the
+// versions table are changed.
+func (migration *Migration) Plan(ctx context.Context, log *zap.Logger, w io.Writer) error {
+	status, err := migration.Status(ctx, log)
+	if err != nil {
+		return err
+	}
+
+	for _, step := range status.Pending {
+		db := *step.DB
+
+		if _, err := fmt.Fprintf(w, "-- version %d: %s\n", step.Version, step.Description); err != nil {
+			return Error.Wrap(err)
+		}
+		if step.CreateDB != nil {
+			if _, err := fmt.Fprintf(w, "-- creates the database first\n"); err != nil {
+				return Error.Wrap(err)
+			}
+		}
+		if withoutTransaction(step, db) {
+			if _, err := fmt.Fprintf(w, "-- runs outside of a transaction\n"); err != nil {
+				return Error.Wrap(err)
+			}
+		}
+		if step.RequiresConfirmation {
+			if _, err := fmt.Fprintf(w, "-- requires confirmation: %s\n", step.ConfirmationReason); err != nil {
+				return Error.Wrap(err)
+			}
+		}
+		action := step.Action
+		if dialects, ok := action.(SQLByDialect); ok {
+			action, err = dialects.resolve(db)
+			if err != nil {
+				return err
+			}
+		}
+		switch action := action.(type) {
+		case SQL:
+			for _, query := range action {
+				if _, err := fmt.Fprintf(w, "%s;\n", strings.TrimSuffix(strings.TrimSpace(rebind(db, query)), ";")); err != nil {
+					return Error.Wrap(err)
+				}
+			}
+		default:
+			if _, err := fmt.Fprintf(w, "-- opaque action: %s\n", step.Description); err != nil {
+				return Error.Wrap(err)
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return Error.Wrap(err)
+		}
+	}
+
+	if status.UpToDate() {
+		_, err = fmt.Fprintf(w, "-- no pending steps\n")
+	}
+	return Error.Wrap(err)
+}
+
+// RunDown undoes the applied steps above the target version, newest first, and
+// removes their versions from the versions table. It refuses to start when any
+// of those steps is irreversible or has no down action, so that the database is
+// not left between versions.
+func (migration *Migration) RunDown(ctx context.Context, log *zap.Logger, targetVersion int) (err error) {
+	err = migration.ValidateSteps()
+	if err != nil {
+		return err
+	}
+
+	locks := dbLocks{migration: migration}
+	defer func() { err = errs.Combine(err, Error.Wrap(locks.release())) }()
+
+	versions := make(map[tagsql.DB]int)
+	var steps []*Step
+	for i := len(migration.Steps) - 1; i >= 0; i-- {
+		step := migration.Steps[i]
+		if step.Version <= targetVersion {
+			break
+		}
+
+		db := *step.DB
+		if db == nil {
+			return Error.New("step.DB is nil for step %d", step.Version)
+		}
+		version, ok := versions[db]
+		if !ok {
+			err = locks.acquire(ctx, log, db)
+			if err != nil {
+				return Error.Wrap(err)
+			}
+			version, err = migration.CurrentVersion(ctx, log, db)
+			if err != nil {
+				return Error.Wrap(err)
+			}
+			versions[db] = version
+		}
+		if step.Version > version {
+			continue
+		}
+
+		switch {
+		case step.Irreversible:
+			return ErrIrreversible.New("step %d (%s) can't be undone", step.Version, step.Description)
+		case step.Down == nil:
+			return ErrIrreversible.New("step %d (%s) has no down action", step.Version, step.Description)
+		}
+		steps = append(steps, step)
+	}
+
+	for _, step := range steps {
+		step := step
+		db := *step.DB
+
+		stepLog := log.Named(strconv.Itoa(step.Version))
+		stepLog.Info("Undo " + step.Description)
+
+		err = txutil.WithTx(ctx, db, nil, func(ctx context.Context, tx tagsql.Tx) error {
+			err = step.Down.Run(ctx, stepLog, db, tx)
+			if err != nil {
+				return err
+			}
+
+			return migration.removeVersion(ctx, tx, db, step.Version)
+		})
+		if err != nil {
+			return Error.Wrap(err)
+		}
+	}
+
+	if len(steps) > 0 {
+		log.Info("Database Version", zap.Int("version", targetVersion))
+	}
+	return nil
+}
+
+// ensureVersionTable creates migration.Table table and its history table if not exists.
 func (migration *Migration) ensureVersionTable(ctx context.Context, log *zap.Logger, db tagsql.DB) error {
 	if err := migration.ValidTableName(); err != nil {
 		return Error.Wrap(err)
@@ -232,11 +577,44 @@ func (migration *Migration) ensureVersionTable(ctx context.Context, log *zap.Log
 
 	err := txutil.WithTx(ctx, db, nil, func(ctx context.Context, tx tagsql.Tx) error {
 		_, err := tx.Exec(ctx, rebind(db, `CREATE TABLE IF NOT EXISTS `+migration.Table+` (version int, commited_at text)`)) //nolint:misspell
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, rebind(db, `CREATE TABLE IF NOT EXISTS `+migration.historyTable()+` (
+			version int,
+			description text,
+			started_at timestamp,
+			duration_ms bigint,
+			checksum text
+		)`))
 		return err
 	})
 	return Error.Wrap(err)
 }
 
+// historyTable returns the name of the table the applied steps are recorded in.
+func (migration *Migration) historyTable() string {
+	return migration.Table + "_history"
+}
+
+// peekVersion finds the latest version in migration.Table like getLatestVersion,
+// without creating the table when it doesn't exist, so that it only reads.
+func (migration *Migration) peekVersion(ctx context.Context, log *zap.Logger, db tagsql.DB) (int, error) {
+	if err := migration.ValidTableName(); err != nil {
+		return 0, err
+	}
+
+	exists, err := tableExists(ctx, db, migration.Table)
+	if err != nil {
+		return 0, Error.Wrap(err)
+	}
+	if !exists {
+		return -1, nil
+	}
+	return migration.getLatestVersion(ctx, log, db)
+}
+
 // getLatestVersion finds the latest version in migration.Table.
 // It returns -1 if there aren't rows or version is null.
 func (migration *Migration) getLatestVersion(ctx context.Context, log *zap.Logger, db tagsql.DB) (int, error) {
@@ -276,6 +654,36 @@ func (migration *Migration) addVersion(ctx context.Context, tx tagsql.Tx, db tag
 	return err
 }
 
+// addHistory records when the step was applied, how long it took and the
+// checksum of the step.
+func (migration *Migration) addHistory(ctx context.Context, tx tagsql.Tx, db tagsql.DB, step *Step, started time.Time, duration time.Duration) error {
+	err := migration.ValidTableName()
+	if err != nil {
+		return err
+	}
+
+	/* #nosec G202 */ // Table name is white listed by the ValidTableName method
+	// executed at the beginning of the function
+	_, err = tx.Exec(ctx, rebind(db, `
+		INSERT INTO `+migration.historyTable()+` (version, description, started_at, duration_ms, checksum) VALUES (?, ?, ?, ?, ?)`),
+		step.Version, step.Description, started.UTC(), duration.Milliseconds(), step.Checksum(),
+	)
+	return err
+}
+
+// removeVersion removes the information about the migration and any later one.
+func (migration *Migration) removeVersion(ctx context.Context, tx tagsql.Tx, db tagsql.DB, version int) error {
+	err := migration.ValidTableName()
+	if err != nil {
+		return err
+	}
+
+	/* #nosec G202 */ // Table name is white listed by the ValidTableName method
+	// executed at the beginning of the function
+	_, err = tx.Exec(ctx, rebind(db, `DELETE FROM `+migration.Table+` WHERE version >= ?`), version)
+	return err
+}
+
 // CurrentVersion finds the latest version for the db.
 func (migration *Migration) CurrentVersion(ctx context.Context, log *zap.Logger, db tagsql.DB) (int, error) {
 	err := migration.ensureVersionTable(ctx, log, db)
@@ -290,8 +698,12 @@ type SQL []string
 
 // Run runs the SQL statements.
 func (sql SQL) Run(ctx context.Context, log *zap.Logger, db tagsql.DB, tx tagsql.Tx) (err error) {
-	for _, query := range sql {
-		_, err := tx.Exec(ctx, rebind(db, query))
+	progress := StepProgress(ctx)
+	for i, query := range sql {
+		err := progress.statement(i, func() error {
+			_, err := tx.Exec(ctx, rebind(db, query))
+			return err
+		})
 		if err != nil {
 			return errs.Wrap(err)
 		}
@@ -299,7 +711,43 @@ func (sql SQL) Run(ctx context.Context, log *zap.Logger, db tagsql.DB, tx tagsql
 	return nil
 }
 
-// Func is an arbitrary operation.
+// SQLByDialect are SQL statements that differ between postgres and cockroach.
+// The statements for the dialect of the database are executed like SQL. It is
+// created with NewSQLByDialect, so that a step can't leave out a dialect.
+type SQLByDialect struct {
+	postgres  SQL
+	cockroach SQL
+}
+
+// NewSQLByDialect returns the statements to run on postgres and on cockroach.
+// An empty SQL marks a step that does nothing on a dialect.
+func NewSQLByDialect(postgres, cockroach SQL) SQLByDialect {
+	return SQLByDialect{postgres: postgres, cockroach: cockroach}
+}
+
+// Run runs the SQL statements of the dialect of the database.
+func (dialects SQLByDialect) Run(ctx context.Context, log *zap.Logger, db tagsql.DB, tx tagsql.Tx) error {
+	sql, err := dialects.resolve(db)
+	if err != nil {
+		return err
+	}
+	return sql.Run(ctx, log, db, tx)
+}
+
+// resolve returns the statements of the dialect of the database.
+func (dialects SQLByDialect) resolve(db tagsql.DB) (SQL, error) {
+	switch driverKindOf(db) {
+	case driverPostgres:
+		return dialects.postgres, nil
+	case driverCockroach:
+		return dialects.cockroach, nil
+	default:
+		return nil, Error.New("no SQL for the dialect of %T", db.Driver())
+	}
+}
+
+// Func is an arbitrary operation. It can report its progress with
+// StepProgress of the context.
 type Func func(ctx context.Context, log *zap.Logger, db tagsql.DB, tx tagsql.Tx) error
 
 // Run runs the migration.
@@ -11,13 +11,16 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zeebo/errs"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
 	"storj.io/common/testcontext"
+	"storj.io/private/dbutil"
 	"storj.io/private/dbutil/pgtest"
 	"storj.io/private/dbutil/tempdb"
 	"storj.io/private/tagsql"
@@ -60,7 +63,7 @@ func TestBasicMigration(t *testing.T) {
 
 func basicMigration(ctx *testcontext.Context, t *testing.T, db tagsql.DB, testDB tagsql.DB) {
 	dbName := strings.ToLower(`versions_` + strings.ReplaceAll(t.Name(), "/", "_"))
-	defer func() { assert.NoError(t, dropTables(ctx, db, dbName, "users")) }()
+	defer func() { assert.NoError(t, dropTables(ctx, db, dbName, dbName+"_history", "users")) }()
 
 	/* #nosec G306 */ // This is a test besides the file contains just test data.
 	err := ioutil.WriteFile(ctx.File("alpha.txt"), []byte("test"), 0644)
@@ -134,6 +137,28 @@ func basicMigration(ctx *testcontext.Context, t *testing.T, db tagsql.DB, testDB
 	data, err := ioutil.ReadFile(ctx.File("beta.txt"))
 	assert.NoError(t, err)
 	assert.Equal(t, []byte("test"), data)
+
+	// every applied step is recorded in the history
+	/* #nosec G202 */ // This is a test besides the dbName value is generated in
+	// a controlled way
+	rows, err := db.Query(ctx, `SELECT version, description, started_at, duration_ms FROM `+dbName+`_history ORDER BY version`)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, rows.Close()) }()
+
+	var descriptions []string
+	for rows.Next() {
+		var version int
+		var description string
+		var startedAt time.Time
+		var durationMs int64
+		require.NoError(t, rows.Scan(&version, &description, &startedAt, &durationMs))
+		assert.Equal(t, len(descriptions)+1, version)
+		assert.WithinDuration(t, time.Now(), startedAt, time.Minute)
+		assert.GreaterOrEqual(t, durationMs, int64(0))
+		descriptions = append(descriptions, description)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"Initialize Table", "Move files"}, descriptions)
 }
 
 func TestMultipleMigrationSqlite(t *testing.T) {
@@ -162,7 +187,7 @@ func TestMultipleMigrationPostgres(t *testing.T) {
 
 func multipleMigration(ctx context.Context, t *testing.T, db tagsql.DB, testDB tagsql.DB) {
 	dbName := strings.ToLower(`versions_` + t.Name())
-	defer func() { assert.NoError(t, dropTables(ctx, db, dbName)) }()
+	defer func() { assert.NoError(t, dropTables(ctx, db, dbName, dbName+"_history")) }()
 
 	steps := 0
 	m := migrate.Migration{
@@ -241,7 +266,7 @@ func TestFailedMigrationPostgres(t *testing.T) {
 
 func failedMigration(ctx context.Context, t *testing.T, db tagsql.DB, testDB tagsql.DB) {
 	dbName := strings.ToLower(`versions_` + t.Name())
-	defer func() { assert.NoError(t, dropTables(ctx, db, dbName)) }()
+	defer func() { assert.NoError(t, dropTables(ctx, db, dbName, dbName+"_history")) }()
 
 	m := migrate.Migration{
 		Table: dbName,
@@ -268,6 +293,674 @@ func failedMigration(ctx context.Context, t *testing.T, db tagsql.DB, testDB tag
 	assert.Equal(t, false, version.Valid)
 }
 
+func TestTimeoutMigrationSqlite(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	db, err := tagsql.Open(ctx, "sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	timeoutMigration(ctx, t, db, &sqliteDB{DB: db})
+}
+
+func TestTimeoutMigrationPostgres(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	connstr := pgtest.PickPostgres(t)
+
+	db, err := tagsql.Open(ctx, "pgx", connstr)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	timeoutMigration(ctx, t, db, &postgresDB{DB: db})
+}
+
+func timeoutMigration(ctx context.Context, t *testing.T, db tagsql.DB, testDB tagsql.DB) {
+	dbName := strings.ToLower(`versions_` + t.Name())
+	defer func() { assert.NoError(t, dropTables(ctx, db, dbName, dbName+"_history")) }()
+
+	sleep := func(duration time.Duration) migrate.Func {
+		return func(ctx context.Context, log *zap.Logger, _ tagsql.DB, tx tagsql.Tx) error {
+			time.Sleep(duration)
+			return nil
+		}
+	}
+
+	m := migrate.Migration{
+		Table:       dbName,
+		StepTimeout: 10 * time.Millisecond,
+		Steps: []*migrate.Step{
+			{
+				DB:          &testDB,
+				Description: "Known Slow",
+				Version:     1,
+				Timeout:     migrate.NoTimeout,
+				Action:      sleep(50 * time.Millisecond),
+			},
+			{
+				DB:          &testDB,
+				Description: "Stuck",
+				Version:     2,
+				Action:      sleep(100 * time.Millisecond),
+			},
+		},
+	}
+
+	err := m.Run(ctx, zap.NewNop())
+	require.True(t, migrate.ErrTimeout.Has(err), err)
+	require.Contains(t, err.Error(), "step 2 (Stuck) timed out after running for")
+
+	var version sql.NullInt64
+	/* #nosec G202 */ // This is a test besides the dbName value is generated in
+	// a controlled way
+	err = db.QueryRow(ctx, `SELECT MAX(version) FROM `+dbName).Scan(&version)
+	require.NoError(t, err)
+	require.True(t, version.Valid)
+	require.EqualValues(t, 1, version.Int64)
+}
+
+func TestRetryMigrationSqlite(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	db, err := tagsql.Open(ctx, "sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	retryMigration(ctx, t, db, &sqliteDB{DB: db})
+}
+
+func TestRetryMigrationPostgres(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	connstr := pgtest.PickPostgres(t)
+
+	db, err := tagsql.Open(ctx, "pgx", connstr)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	retryMigration(ctx, t, db, &postgresDB{DB: db})
+}
+
+// retryableError is an error like a cockroach serialization failure.
+type retryableError struct{}
+
+func (retryableError) Error() string    { return "restart transaction" }
+func (retryableError) SQLState() string { return "40001" }
+
+func retryMigration(ctx context.Context, t *testing.T, db tagsql.DB, testDB tagsql.DB) {
+	dbName := strings.ToLower(`versions_` + t.Name())
+	defer func() { assert.NoError(t, dropTables(ctx, db, dbName, dbName+"_history")) }()
+
+	// failing fails with a retryable error until it ran the given times.
+	attempts := map[int]int{}
+	failing := func(version, failures int) migrate.Func {
+		return func(ctx context.Context, log *zap.Logger, _ tagsql.DB, tx tagsql.Tx) error {
+			attempts[version]++
+			if attempts[version] <= failures {
+				return retryableError{}
+			}
+			return nil
+		}
+	}
+
+	m := migrate.Migration{
+		Table:        dbName,
+		StepAttempts: 3,
+		Steps: []*migrate.Step{
+			{
+				DB:          &testDB,
+				Description: "Conflicting",
+				Version:     1,
+				Action:      failing(1, 2),
+				Idempotent:  true,
+			},
+			{
+				DB:          &testDB,
+				Description: "Always Conflicting",
+				Version:     2,
+				Action:      failing(2, 10),
+				Idempotent:  true,
+			},
+		},
+	}
+
+	err := m.Run(ctx, zap.NewNop())
+	require.Error(t, err)
+	require.ErrorAs(t, err, &retryableError{})
+	require.Equal(t, map[int]int{1: 3, 2: 3}, attempts)
+
+	// a Func step may have run statements outside of the transaction, so it
+	// only runs once unless it's marked as idempotent.
+	m.Steps[1] = &migrate.Step{
+		DB:          &testDB,
+		Description: "Conflicting Once",
+		Version:     2,
+		Action:      failing(3, 1),
+	}
+	err = m.Run(ctx, zap.NewNop())
+	require.ErrorAs(t, err, &retryableError{})
+	require.Equal(t, 1, attempts[3])
+
+	/* #nosec G202 */ // This is a test besides the dbName value is generated in
+	// a controlled way
+	rows, err := db.Query(ctx, `SELECT version FROM `+dbName)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, rows.Close()) }()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		require.NoError(t, rows.Scan(&version))
+		versions = append(versions, version)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []int{1}, versions)
+}
+
+func TestConfirmMigrationSqlite(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	db, err := tagsql.Open(ctx, "sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	confirmMigration(ctx, t, db, &sqliteDB{DB: db})
+}
+
+func TestConfirmMigrationPostgres(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	connstr := pgtest.PickPostgres(t)
+
+	db, err := tagsql.Open(ctx, "pgx", connstr)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	confirmMigration(ctx, t, db, &postgresDB{DB: db})
+}
+
+func confirmMigration(ctx context.Context, t *testing.T, db tagsql.DB, testDB tagsql.DB) {
+	dbName := strings.ToLower(`versions_` + t.Name())
+	defer func() { assert.NoError(t, dropTables(ctx, db, dbName, dbName+"_history", "users")) }()
+
+	m := migrate.Migration{
+		Table: dbName,
+		Steps: []*migrate.Step{
+			{
+				DB:          &testDB,
+				Description: "Initialize Table",
+				Version:     1,
+				Action:      migrate.SQL{`CREATE TABLE users (id int)`},
+			},
+			{
+				DB:                   &testDB,
+				Description:          "Delete Users",
+				Version:              2,
+				Action:               migrate.SQL{`DELETE FROM users`},
+				RequiresConfirmation: true,
+				ConfirmationReason:   "the users are lost",
+			},
+			{
+				DB:          &testDB,
+				Description: "Add Email",
+				Version:     3,
+				Action:      migrate.SQL{`ALTER TABLE users ADD COLUMN email text`},
+			},
+		},
+	}
+
+	require.NoError(t, m.TargetVersion(1).Run(ctx, zap.NewNop()))
+
+	var plan strings.Builder
+	require.NoError(t, m.Plan(ctx, zap.NewNop(), &plan))
+	require.Contains(t, plan.String(), "-- version 2: Delete Users\n-- requires confirmation: the users are lost\n")
+
+	// the migration stops before the step.
+	err := m.Run(ctx, zap.NewNop())
+	require.True(t, migrate.ErrConfirmationRequired.Has(err), err)
+	require.Contains(t, err.Error(), "step 2 (Delete Users) requires confirmation of version 2: the users are lost")
+
+	version, err := m.CurrentVersion(ctx, zap.NewNop(), testDB)
+	require.NoError(t, err)
+	require.Equal(t, 1, version)
+
+	// another process that confirmed the version continues.
+	m.ConfirmedVersions = []int{2}
+	require.NoError(t, m.Run(ctx, zap.NewNop()))
+
+	version, err = m.CurrentVersion(ctx, zap.NewNop(), testDB)
+	require.NoError(t, err)
+	require.Equal(t, 3, version)
+}
+
+func TestProgressMigrationPostgres(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	connstr := pgtest.PickPostgres(t)
+
+	db, err := tagsql.Open(ctx, "pgx", connstr)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	var testDB tagsql.DB = &postgresDB{DB: db}
+
+	dbName := strings.ToLower(`versions_` + t.Name())
+	defer func() { assert.NoError(t, dropTables(ctx, db, dbName, dbName+"_history")) }()
+
+	m := migrate.Migration{
+		Table:                  dbName,
+		SlowStatementThreshold: 50 * time.Millisecond,
+		Steps: []*migrate.Step{
+			{
+				DB:          &testDB,
+				Description: "Initialize",
+				Version:     1,
+				Action:      migrate.SQL{`SELECT 1`},
+			},
+			{
+				DB:          &testDB,
+				Description: "Slow Statement",
+				Version:     2,
+				Action:      migrate.SQL{`SELECT 1`, `SELECT pg_sleep(0.2)`},
+			},
+			{
+				DB:          &testDB,
+				Description: "Reporting Func",
+				Version:     3,
+				Action: migrate.Func(func(ctx context.Context, log *zap.Logger, _ tagsql.DB, tx tagsql.Tx) error {
+					progress := migrate.StepProgress(ctx)
+					progress.Report(1, 2)
+					progress.Report(2, 2)
+					return nil
+				}),
+			},
+		},
+	}
+
+	require.NoError(t, m.TargetVersion(1).Run(ctx, zap.NewNop()))
+
+	core, logs := observer.New(zap.DebugLevel)
+	require.NoError(t, m.Run(ctx, zap.New(core)))
+
+	// only the slow statement is logged, while it runs and when it's finished.
+	running := logs.FilterMessage("Statement running").AllUntimed()
+	require.NotEmpty(t, running)
+	for _, entry := range running {
+		require.EqualValues(t, 1, entry.ContextMap()["statement"])
+	}
+	finished := logs.FilterMessage("Statement finished").AllUntimed()
+	require.Len(t, finished, 1)
+	require.EqualValues(t, 1, finished[0].ContextMap()["statement"])
+	require.GreaterOrEqual(t, finished[0].ContextMap()["elapsed"], 200*time.Millisecond)
+
+	reports := logs.FilterMessage("Step progress").AllUntimed()
+	require.Len(t, reports, 2)
+	for i, entry := range reports {
+		require.Equal(t, "3", entry.LoggerName)
+		require.EqualValues(t, i+1, entry.ContextMap()["done"])
+		require.EqualValues(t, 2, entry.ContextMap()["total"])
+	}
+
+	applied := logs.FilterMessage("Applied").AllUntimed()
+	require.Len(t, applied, 2)
+	require.EqualValues(t, 2, applied[0].ContextMap()["statements"])
+	require.EqualValues(t, 0, applied[1].ContextMap()["statements"])
+}
+
+func TestDownMigrationSqlite(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	db, err := tagsql.Open(ctx, "sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	downMigration(ctx, t, db, &sqliteDB{DB: db})
+}
+
+func TestDownMigrationPostgres(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	connstr := pgtest.PickPostgres(t)
+
+	db, err := tagsql.Open(ctx, "pgx", connstr)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	downMigration(ctx, t, db, &postgresDB{DB: db})
+}
+
+func downMigration(ctx context.Context, t *testing.T, db tagsql.DB, testDB tagsql.DB) {
+	dbName := strings.ToLower(`versions_` + t.Name())
+	defer func() { assert.NoError(t, dropTables(ctx, db, dbName, dbName+"_history", "users")) }()
+
+	m := migrate.Migration{
+		Table: dbName,
+		Steps: []*migrate.Step{
+			{
+				DB:           &testDB,
+				Description:  "Initialize Table",
+				Version:      1,
+				Action:       migrate.SQL{`CREATE TABLE users (id int)`},
+				Irreversible: true,
+			},
+			{
+				DB:          &testDB,
+				Description: "Add Column",
+				Version:     2,
+				Action:      migrate.SQL{`ALTER TABLE users ADD COLUMN name text`},
+			},
+			{
+				DB:          &testDB,
+				Description: "Add Another Column",
+				Version:     3,
+				Action:      migrate.SQL{`ALTER TABLE users ADD COLUMN email text`},
+				Down:        migrate.SQL{`ALTER TABLE users DROP COLUMN email`},
+			},
+			{
+				DB:          &testDB,
+				Description: "Insert User",
+				Version:     4,
+				Action:      migrate.SQL{`INSERT INTO users (id, name, email) VALUES (1, 'alice', 'alice@mail.test')`},
+				Down: migrate.Func(func(ctx context.Context, log *zap.Logger, _ tagsql.DB, tx tagsql.Tx) error {
+					_, err := tx.Exec(ctx, `DELETE FROM users WHERE id = 1`)
+					return err
+				}),
+			},
+		},
+	}
+
+	require.NoError(t, m.Run(ctx, zap.NewNop()))
+
+	// undoing down to a version that is already applied does nothing.
+	require.NoError(t, m.RunDown(ctx, zap.NewNop(), 4))
+
+	// the step without a down action stops the migration before anything is undone.
+	err := m.RunDown(ctx, zap.NewNop(), 1)
+	require.True(t, migrate.ErrIrreversible.Has(err), err)
+	dbVersion, err := m.CurrentVersion(ctx, nil, testDB)
+	require.NoError(t, err)
+	require.Equal(t, 4, dbVersion)
+
+	require.NoError(t, m.RunDown(ctx, zap.NewNop(), 2))
+	dbVersion, err = m.CurrentVersion(ctx, nil, testDB)
+	require.NoError(t, err)
+	require.Equal(t, 2, dbVersion)
+
+	var count int
+	require.NoError(t, db.QueryRow(ctx, `SELECT COUNT(*) FROM users`).Scan(&count))
+	require.Zero(t, count)
+	_, err = db.Exec(ctx, `SELECT email FROM users`)
+	require.Error(t, err)
+
+	// migrating up again applies the undone steps.
+	require.NoError(t, m.Run(ctx, zap.NewNop()))
+	dbVersion, err = m.CurrentVersion(ctx, nil, testDB)
+	require.NoError(t, err)
+	require.Equal(t, 4, dbVersion)
+
+	var email string
+	require.NoError(t, db.QueryRow(ctx, `SELECT email FROM users WHERE id = 1`).Scan(&email))
+	require.Equal(t, "alice@mail.test", email)
+
+	// irreversible steps are refused with a clear message.
+	m.Steps[1].Down = migrate.SQL{`ALTER TABLE users DROP COLUMN name`}
+	err = m.RunDown(ctx, zap.NewNop(), 0)
+	require.True(t, migrate.ErrIrreversible.Has(err), err)
+	require.Contains(t, err.Error(), "Initialize Table")
+}
+
+func TestPlanMigrationSqlite(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	db, err := tagsql.Open(ctx, "sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	planMigration(ctx, t, db, &sqliteDB{DB: db})
+}
+
+func TestPlanMigrationPostgres(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	connstr := pgtest.PickPostgres(t)
+
+	db, err := tagsql.Open(ctx, "pgx", connstr)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	planMigration(ctx, t, db, &postgresDB{DB: db})
+}
+
+func planMigration(ctx context.Context, t *testing.T, db tagsql.DB, testDB tagsql.DB) {
+	dbName := strings.ToLower(`versions_` + t.Name())
+
+	m := migrate.Migration{
+		Table: dbName,
+		Steps: []*migrate.Step{
+			{
+				DB:          &testDB,
+				Description: "Initialize Table",
+				Version:     1,
+				Action: migrate.SQL{
+					`CREATE TABLE users (id int)`,
+					`INSERT INTO users (id) VALUES (1);`,
+				},
+			},
+			{
+				DB:          &testDB,
+				Description: "Move files",
+				Version:     2,
+				Action: migrate.Func(func(_ context.Context, log *zap.Logger, _ tagsql.DB, tx tagsql.Tx) error {
+					return nil
+				}),
+			},
+			{
+				DB:          &testDB,
+				Description: "Add Column",
+				Version:     3,
+				Action:      migrate.SQL{`ALTER TABLE users ADD COLUMN name text`},
+			},
+		},
+	}
+
+	var plan strings.Builder
+	require.NoError(t, m.Plan(ctx, zap.NewNop(), &plan))
+	require.Equal(t, ""+
+		"-- version 1: Initialize Table\n"+
+		"CREATE TABLE users (id int);\n"+
+		"INSERT INTO users (id) VALUES (1);\n"+
+		"\n"+
+		"-- version 2: Move files\n"+
+		"-- opaque action: Move files\n"+
+		"\n"+
+		"-- version 3: Add Column\n"+
+		"ALTER TABLE users ADD COLUMN name text;\n"+
+		"\n", plan.String())
+
+	// nothing was created, not even the versions table.
+	for _, table := range []string{dbName, dbName + "_history", "users"} {
+		_, err := db.Exec(ctx, `SELECT * FROM `+table)
+		require.Error(t, err, table)
+	}
+
+	// only the steps above the current version are pending.
+	require.NoError(t, m.TargetVersion(2).Run(ctx, zap.NewNop()))
+	defer func() { assert.NoError(t, dropTables(ctx, db, dbName, dbName+"_history", "users")) }()
+
+	plan.Reset()
+	require.NoError(t, m.Plan(ctx, zap.NewNop(), &plan))
+	require.Equal(t, ""+
+		"-- version 3: Add Column\n"+
+		"ALTER TABLE users ADD COLUMN name text;\n"+
+		"\n", plan.String())
+
+	require.NoError(t, m.Run(ctx, zap.NewNop()))
+	plan.Reset()
+	require.NoError(t, m.Plan(ctx, zap.NewNop(), &plan))
+	require.Equal(t, "-- no pending steps\n", plan.String())
+}
+
+func TestChecksumMigrationSqlite(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	db, err := tagsql.Open(ctx, "sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	checksumMigration(ctx, t, db, &sqliteDB{DB: db})
+}
+
+func TestChecksumMigrationPostgres(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	connstr := pgtest.PickPostgres(t)
+
+	db, err := tagsql.Open(ctx, "pgx", connstr)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	checksumMigration(ctx, t, db, &postgresDB{DB: db})
+}
+
+func checksumMigration(ctx context.Context, t *testing.T, db tagsql.DB, testDB tagsql.DB) {
+	dbName := strings.ToLower(`versions_` + t.Name())
+	defer func() { assert.NoError(t, dropTables(ctx, db, dbName, dbName+"_history", "users")) }()
+
+	m := migrate.Migration{
+		Table: dbName,
+		Steps: []*migrate.Step{
+			{
+				DB:          &testDB,
+				Description: "Initialize Table",
+				Version:     1,
+				Action: migrate.SQL{
+					`CREATE TABLE users (id int)`,
+					`INSERT INTO users (id) VALUES (1);`,
+				},
+			},
+			{
+				DB:          &testDB,
+				Description: "Move files",
+				Version:     2,
+				Action: migrate.Func(func(_ context.Context, log *zap.Logger, _ tagsql.DB, tx tagsql.Tx) error {
+					return nil
+				}),
+			},
+		},
+	}
+	require.NoError(t, m.Run(ctx, zap.NewNop()))
+
+	// withStep returns a copy of the migration with the step of the index replaced.
+	withStep := func(index int, change func(step *migrate.Step)) *migrate.Migration {
+		changed := m
+		changed.Steps = append([]*migrate.Step{}, m.Steps...)
+		step := *m.Steps[index]
+		change(&step)
+		changed.Steps[index] = &step
+		return &changed
+	}
+
+	// reformatting the SQL doesn't change the checksum.
+	reformatted := withStep(0, func(step *migrate.Step) {
+		step.Action = migrate.SQL{
+			"CREATE TABLE users (\n\tid int\n)",
+			`INSERT INTO users (id) VALUES (1)`,
+		}
+	})
+	require.NoError(t, reformatted.Run(ctx, zap.NewNop()))
+
+	// changing the SQL of an applied step is detected.
+	edited := withStep(0, func(step *migrate.Step) {
+		step.Action = migrate.SQL{
+			`CREATE TABLE users (id bigint)`,
+			`INSERT INTO users (id) VALUES (1);`,
+		}
+	})
+	err := edited.Run(ctx, zap.NewNop())
+	require.True(t, migrate.ErrChecksumMismatch.Has(err), err)
+	require.Contains(t, err.Error(), "1 (Initialize Table)")
+	require.NotContains(t, err.Error(), "Move files")
+
+	// functions are only verified by their description.
+	renamed := withStep(1, func(step *migrate.Step) {
+		step.Description = "Move more files"
+	})
+	err = renamed.Run(ctx, zap.NewNop())
+	require.True(t, migrate.ErrChecksumMismatch.Has(err), err)
+	require.Contains(t, err.Error(), "2 (Move more files)")
+
+	// the mismatch can be ignored, so that the pending steps are applied.
+	edited.IgnoreChecksumMismatch = true
+	edited.Steps = append(edited.Steps, &migrate.Step{
+		DB:          &testDB,
+		Description: "Add Column",
+		Version:     3,
+		Action:      migrate.SQL{`ALTER TABLE users ADD COLUMN name text`},
+	})
+	require.NoError(t, edited.Run(ctx, zap.NewNop()))
+
+	version, err := edited.CurrentVersion(ctx, nil, testDB)
+	require.NoError(t, err)
+	require.Equal(t, 3, version)
+}
+
+func TestSQLByDialect(t *testing.T) {
+	pgtest.Run(t, func(ctx *testcontext.Context, t *testing.T, connstr string) {
+		db, err := tempdb.OpenUnique(ctx, connstr, "dialect-")
+		require.NoError(t, err)
+		defer ctx.Check(db.Close)
+
+		var testDB tagsql.DB = &postgresDB{DB: db.DB}
+
+		m := migrate.Migration{
+			Table: "versions",
+			Steps: []*migrate.Step{
+				{
+					DB:          &testDB,
+					Description: "Initialize Table",
+					Version:     1,
+					Action: migrate.SQL{
+						`CREATE TABLE users (id int)`,
+						`INSERT INTO users (id) VALUES (1)`,
+					},
+				},
+				{
+					DB:          &testDB,
+					Description: "Add Dialect Column",
+					Version:     2,
+					Action: migrate.NewSQLByDialect(
+						migrate.SQL{`ALTER TABLE users ADD COLUMN dialect text NOT NULL DEFAULT 'postgres'`},
+						migrate.SQL{`ALTER TABLE users ADD COLUMN dialect text NOT NULL DEFAULT 'cockroach'`},
+					),
+				},
+			},
+		}
+
+		require.NoError(t, m.Run(ctx, zap.NewNop()))
+
+		expected := "postgres"
+		if db.Implementation == dbutil.Cockroach {
+			expected = "cockroach"
+		}
+		var dialect string
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT dialect FROM users WHERE id = 1`).Scan(&dialect))
+		require.Equal(t, expected, dialect)
+	})
+}
+
 func TestTargetVersion(t *testing.T) {
 	m := migrate.Migration{
 		Table: "test",
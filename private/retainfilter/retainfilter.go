@@ -0,0 +1,214 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package retainfilter implements retain filters that only cover a range of
+// the piece ID space.
+//
+// The bloom filter of a node storing a very large number of pieces can exceed
+// the maximum message size. The satellite then splits the pieces of the node
+// by the first bits of their IDs and sends a filter for every range in a
+// separate retain request, which the node applies to the pieces in that range
+// only.
+package retainfilter
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/common/bloomfilter"
+	"storj.io/common/storj"
+)
+
+var (
+	// Error is the error class for retain filters.
+	Error = errs.Class("retainfilter")
+	// ErrTooLarge is the error class of filters over the maximum size.
+	ErrTooLarge = errs.Class("retain filter too large")
+)
+
+// MaxBits is the maximum number of piece ID bits a range is selected by,
+// which splits the piece ID space into at most 256 ranges.
+const MaxBits = 8
+
+// rangedVersion marks the encoding of a filter with a range. It must differ
+// from every bloom filter version, so that nodes that do not support ranges
+// reject such filters instead of applying them to all their pieces.
+const rangedVersion = 0x80
+
+// flaggedVersion marks the encoding of a filter with a range and flags. Only
+// nodes that are new enough to understand the flags are sent such filters.
+const flaggedVersion = 0x81
+
+// Flags ask the node for more than applying the filter.
+type Flags uint8
+
+const (
+	// FlagReport asks the node to report the result of applying the filter
+	// back to the satellite.
+	FlagReport Flags = 1 << iota
+	// FlagRequireTrash asks the node to only move the pieces that are not
+	// retained to the trash, where they stay until the trash expires, and
+	// never to delete them directly.
+	FlagRequireTrash
+
+	// knownFlags are all flags that are understood. Filters with other flags
+	// are rejected, so that a node never ignores what it is asked for.
+	knownFlags = FlagReport | FlagRequireTrash
+)
+
+// Range is the range of piece IDs whose first Bits bits are equal to Index.
+// The zero value is the range of all piece IDs.
+type Range struct {
+	Bits  uint8
+	Index uint32
+}
+
+// RangeOf returns the range that is selected by bits and contains the piece ID.
+func RangeOf(bits uint8, pieceID storj.PieceID) Range {
+	if bits == 0 {
+		return Range{}
+	}
+	return Range{
+		Bits:  bits,
+		Index: binary.BigEndian.Uint32(pieceID[:4]) >> (32 - bits),
+	}
+}
+
+// Count returns the number of ranges that the same number of bits selects.
+func (r Range) Count() int { return 1 << r.Bits }
+
+// IsFull returns whether the range contains all piece IDs.
+func (r Range) IsFull() bool { return r.Bits == 0 }
+
+// Contains returns whether the piece ID is in the range.
+func (r Range) Contains(pieceID storj.PieceID) bool {
+	return RangeOf(r.Bits, pieceID) == r
+}
+
+// Encode returns the filter for the pieces in the range as it is sent in a
+// retain request. A filter for the full range is encoded as the plain bloom
+// filter, which every node understands.
+func Encode(r Range, filter *bloomfilter.Filter) []byte {
+	if r.IsFull() {
+		return filter.Bytes()
+	}
+	data := make([]byte, 0, 3+filter.Size())
+	data = append(data, rangedVersion, r.Bits, byte(r.Index))
+	return append(data, filter.Bytes()...)
+}
+
+// EncodeWithFlags returns the filter for the pieces in the range with the
+// flags as it is sent in a retain request. Without flags it is encoded the
+// same way as by Encode.
+func EncodeWithFlags(r Range, flags Flags, filter *bloomfilter.Filter) []byte {
+	if flags == 0 {
+		return Encode(r, filter)
+	}
+	data := make([]byte, 0, 4+filter.Size())
+	data = append(data, flaggedVersion, byte(flags), r.Bits, byte(r.Index))
+	return append(data, filter.Bytes()...)
+}
+
+// FlagsOf returns the flags of a filter encoded by EncodeWithFlags, without
+// decoding the filter.
+func FlagsOf(data []byte) Flags {
+	if len(data) < 2 || data[0] != flaggedVersion {
+		return 0
+	}
+	return Flags(data[1])
+}
+
+// Decode parses a filter encoded by Encode or EncodeWithFlags.
+//
+// Note: data will be referenced inside the filter.
+func Decode(data []byte) (Range, *bloomfilter.Filter, error) {
+	if len(data) == 0 || (data[0] != rangedVersion && data[0] != flaggedVersion) {
+		filter, err := bloomfilter.NewFromBytes(data)
+		return Range{}, filter, Error.Wrap(err)
+	}
+
+	// the flags only precede the range, which may be the full range.
+	flagged := data[0] == flaggedVersion
+	if flagged {
+		if len(data) < 2 {
+			return Range{}, nil, Error.New("not enough data")
+		}
+		if flags := Flags(data[1]); flags&^knownFlags != 0 {
+			return Range{}, nil, Error.New("unknown flags %#x", byte(flags&^knownFlags))
+		}
+		data = data[1:]
+	}
+
+	if len(data) < 3 {
+		return Range{}, nil, Error.New("not enough data")
+	}
+	r := Range{Bits: data[1], Index: uint32(data[2])}
+	if (r.Bits == 0 && !flagged) || r.Bits > MaxBits || int(r.Index) >= r.Count() {
+		return Range{}, nil, Error.New("invalid range %d/%d", r.Index, r.Bits)
+	}
+
+	filter, err := bloomfilter.NewFromBytes(data[3:])
+	if err != nil {
+		return Range{}, nil, Error.Wrap(err)
+	}
+	return r, filter, nil
+}
+
+// Read reads a filter encoded by Encode or EncodeWithFlags of the given size from r and decodes
+// it. The filter is read into a single buffer that the decoded filter
+// references, so it is never copied, and the buffer is returned as well. A
+// filter over maxSize is rejected before anything is read, and reading stops
+// as soon as r turns out to hold more than size bytes. A maxSize of 0 is
+// unlimited.
+func Read(r io.Reader, size, maxSize int64) (data []byte, _ Range, _ *bloomfilter.Filter, err error) {
+	if size < 0 {
+		return nil, Range{}, nil, Error.New("invalid size %d", size)
+	}
+	if maxSize > 0 && size > maxSize {
+		return nil, Range{}, nil, ErrTooLarge.New("%d bytes exceeds the maximum of %d bytes", size, maxSize)
+	}
+
+	data = make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, Range{}, nil, Error.Wrap(err)
+	}
+
+	var extra [1]byte
+	switch _, err := io.ReadFull(r, extra[:]); {
+	case err == nil:
+		return nil, Range{}, nil, Error.New("more than the expected %d bytes", size)
+	case !errs.Is(err, io.EOF):
+		return nil, Range{}, nil, Error.Wrap(err)
+	}
+
+	pieceRange, filter, err := Decode(data)
+	if err != nil {
+		return nil, Range{}, nil, err
+	}
+	return data, pieceRange, filter, nil
+}
+
+// Union returns a filter for all piece IDs that any of the filters contains.
+// The filters must have been created with the same parameters, as the parts
+// of a split filter are.
+func Union(filters []*bloomfilter.Filter) (*bloomfilter.Filter, error) {
+	if len(filters) == 0 {
+		return nil, Error.New("no filters")
+	}
+
+	union := filters[0].Bytes()
+	for _, filter := range filters[1:] {
+		data := filter.Bytes()
+		if len(data) != len(union) || data[1] != union[1] || data[2] != union[2] {
+			return nil, Error.New("filters have different parameters")
+		}
+		for i := 3; i < len(data); i++ {
+			union[i] |= data[i]
+		}
+	}
+
+	filter, err := bloomfilter.NewFromBytes(union)
+	return filter, Error.Wrap(err)
+}
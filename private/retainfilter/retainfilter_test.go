@@ -0,0 +1,251 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retainfilter_test
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/bloomfilter"
+	"storj.io/common/memory"
+	"storj.io/common/storj"
+	"storj.io/common/testrand"
+	"storj.io/storj/private/retainfilter"
+)
+
+func TestRange(t *testing.T) {
+	pieceID := storj.PieceID{0b1011_0110, 0xFF}
+
+	require.Equal(t, retainfilter.Range{}, retainfilter.RangeOf(0, pieceID))
+	require.True(t, retainfilter.RangeOf(0, pieceID).IsFull())
+	require.Equal(t, retainfilter.Range{Bits: 1, Index: 1}, retainfilter.RangeOf(1, pieceID))
+	require.Equal(t, retainfilter.Range{Bits: 4, Index: 0b1011}, retainfilter.RangeOf(4, pieceID))
+	require.Equal(t, retainfilter.Range{Bits: 8, Index: 0b1011_0110}, retainfilter.RangeOf(8, pieceID))
+
+	for bits := uint8(0); bits <= retainfilter.MaxBits; bits++ {
+		for i := 0; i < 100; i++ {
+			pieceID := testrand.PieceID()
+
+			contained := 0
+			for index := 0; index < 1<<bits; index++ {
+				if (retainfilter.Range{Bits: bits, Index: uint32(index)}).Contains(pieceID) {
+					contained++
+				}
+			}
+			require.Equal(t, 1, contained, "piece must be in exactly one range")
+		}
+	}
+}
+
+func TestSplitRoundTrip(t *testing.T) {
+	const bits = 3
+
+	pieces := make([]storj.PieceID, 1000)
+	for i := range pieces {
+		pieces[i] = testrand.PieceID()
+	}
+
+	template := bloomfilter.NewOptimal(len(pieces)>>bits, 0.1)
+	parts := make([]*bloomfilter.Filter, 1<<bits)
+	for i := range parts {
+		var err error
+		parts[i], err = bloomfilter.NewFromBytes(template.Bytes())
+		require.NoError(t, err)
+	}
+	for _, pieceID := range pieces {
+		parts[retainfilter.RangeOf(bits, pieceID).Index].Add(pieceID)
+	}
+
+	// every node applies the decoded filters to the pieces in their range.
+	for i, part := range parts {
+		r, filter, err := retainfilter.Decode(retainfilter.Encode(retainfilter.Range{Bits: bits, Index: uint32(i)}, part))
+		require.NoError(t, err)
+		require.Equal(t, retainfilter.Range{Bits: bits, Index: uint32(i)}, r)
+
+		for _, pieceID := range pieces {
+			if r.Contains(pieceID) {
+				require.True(t, filter.Contains(pieceID))
+			}
+		}
+	}
+
+	// nodes that don't support ranges get the union of the parts.
+	union, err := retainfilter.Union(parts)
+	require.NoError(t, err)
+	for _, pieceID := range pieces {
+		require.True(t, union.Contains(pieceID))
+	}
+
+	r, filter, err := retainfilter.Decode(retainfilter.Encode(retainfilter.Range{}, union))
+	require.NoError(t, err)
+	require.True(t, r.IsFull())
+	require.Equal(t, union.Bytes(), filter.Bytes())
+}
+
+func TestDecode(t *testing.T) {
+	filter := bloomfilter.NewOptimal(10, 0.1)
+	filter.Add(testrand.PieceID())
+
+	// plain bloom filters cover all pieces.
+	r, decoded, err := retainfilter.Decode(filter.Bytes())
+	require.NoError(t, err)
+	require.True(t, r.IsFull())
+	require.Equal(t, filter.Bytes(), decoded.Bytes())
+
+	// nodes that don't support ranges must reject ranged filters.
+	_, err = bloomfilter.NewFromBytes(retainfilter.Encode(retainfilter.Range{Bits: 1}, filter))
+	require.Error(t, err)
+
+	for _, invalid := range [][]byte{
+		nil,
+		{0x80},
+		{0x80, 0, 0},
+		{0x80, retainfilter.MaxBits + 1, 0},
+		{0x80, 2, 4},
+		append([]byte{0x80, 2, 3}, 0xFF),
+	} {
+		_, _, err := retainfilter.Decode(invalid)
+		require.Error(t, err, invalid)
+	}
+
+	_, err = retainfilter.Union([]*bloomfilter.Filter{filter, bloomfilter.NewOptimal(1000, 0.1)})
+	require.Error(t, err)
+}
+
+func TestFlags(t *testing.T) {
+	filter := bloomfilter.NewOptimal(10, 0.1)
+	filter.Add(testrand.PieceID())
+
+	for _, r := range []retainfilter.Range{{}, {Bits: 2, Index: 3}} {
+		// without flags the filter is encoded as before.
+		plain := retainfilter.EncodeWithFlags(r, 0, filter)
+		require.Equal(t, retainfilter.Encode(r, filter), plain)
+		require.Zero(t, retainfilter.FlagsOf(plain))
+
+		flagged := retainfilter.EncodeWithFlags(r, retainfilter.FlagReport, filter)
+		require.Equal(t, retainfilter.FlagReport, retainfilter.FlagsOf(flagged))
+
+		decoded, decodedFilter, err := retainfilter.Decode(flagged)
+		require.NoError(t, err)
+		require.Equal(t, r, decoded)
+		require.Equal(t, filter.Bytes(), decodedFilter.Bytes())
+
+		// nodes that don't support flags must reject flagged filters.
+		_, err = bloomfilter.NewFromBytes(flagged)
+		require.Error(t, err)
+	}
+
+	both := retainfilter.FlagReport | retainfilter.FlagRequireTrash
+	require.Equal(t, both, retainfilter.FlagsOf(retainfilter.EncodeWithFlags(retainfilter.Range{}, both, filter)))
+
+	for _, invalid := range [][]byte{
+		{0x81},
+		{0x81, 1},
+		{0x81, 1, retainfilter.MaxBits + 1, 0},
+		{0x81, 1, 0, 1},
+		// flags that are not understood must not be ignored.
+		append([]byte{0x81, 0x80, 0, 0}, filter.Bytes()...),
+	} {
+		_, _, err := retainfilter.Decode(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestRead(t *testing.T) {
+	const maxSize = 4 * memory.MiB
+	const slack = 64 * memory.KiB
+	r := retainfilter.Range{Bits: 2, Index: 1}
+
+	// the table of the filter takes all of the encoded size but the headers.
+	filter := bloomfilter.NewOptimalMaxSize(10000000, 0.1, maxSize-6)
+	pieceID := testrand.PieceID()
+	filter.Add(pieceID)
+	atLimit := retainfilter.Encode(r, filter)
+	require.Len(t, atLimit, maxSize.Int())
+
+	overLimit := retainfilter.Encode(r, bloomfilter.NewOptimalMaxSize(10000000, 0.1, maxSize-5))
+	require.Len(t, overLimit, maxSize.Int()+1)
+
+	t.Run("at limit", func(t *testing.T) {
+		reader := &countingReader{reader: bytes.NewReader(atLimit)}
+
+		var data []byte
+		var decodedRange retainfilter.Range
+		var decoded *bloomfilter.Filter
+		var err error
+		// the filter is read into its final buffer, which is not copied.
+		allocated := allocatedBytes(func() {
+			data, decodedRange, decoded, err = retainfilter.Read(reader, int64(len(atLimit)), maxSize.Int64())
+		})
+		require.NoError(t, err)
+		require.LessOrEqual(t, allocated, uint64(maxSize+slack))
+		require.Equal(t, atLimit, data)
+		require.Equal(t, r, decodedRange)
+		require.True(t, decoded.Contains(pieceID))
+		require.EqualValues(t, len(atLimit), reader.read)
+
+		allocs := testing.AllocsPerRun(10, func() {
+			_, _, _, err := retainfilter.Read(bytes.NewReader(atLimit), int64(len(atLimit)), maxSize.Int64())
+			require.NoError(t, err)
+		})
+		require.LessOrEqual(t, allocs, 5.0)
+	})
+
+	t.Run("over limit", func(t *testing.T) {
+		reader := &countingReader{reader: bytes.NewReader(overLimit)}
+
+		var err error
+		// the filter is rejected before it is received.
+		allocated := allocatedBytes(func() {
+			_, _, _, err = retainfilter.Read(reader, int64(len(overLimit)), maxSize.Int64())
+		})
+		require.True(t, retainfilter.ErrTooLarge.Has(err))
+		require.Less(t, allocated, uint64(slack))
+		require.Zero(t, reader.read)
+	})
+
+	t.Run("over expected size", func(t *testing.T) {
+		reader := &countingReader{reader: bytes.NewReader(overLimit)}
+
+		var err error
+		// reading stops as soon as the filter exceeds the size it claims.
+		allocated := allocatedBytes(func() {
+			_, _, _, err = retainfilter.Read(reader, int64(len(atLimit)), maxSize.Int64())
+		})
+		require.Error(t, err)
+		require.LessOrEqual(t, allocated, uint64(maxSize+slack))
+		require.EqualValues(t, len(overLimit), reader.read)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, _, _, err := retainfilter.Read(bytes.NewReader(atLimit[:100]), int64(len(atLimit)), maxSize.Int64())
+		require.Error(t, err)
+	})
+}
+
+// countingReader counts the bytes read from the reader.
+type countingReader struct {
+	reader io.Reader
+	read   int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.read += int64(n)
+	return n, err
+}
+
+// allocatedBytes returns the number of bytes that fn allocates on the heap.
+func allocatedBytes(fn func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
@@ -0,0 +1,9 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package retainpb contains protobuf definitions for storage nodes reporting
+// the results of retain requests back to the satellite, and for satellites
+// checking on the retain requests they sent to storage nodes.
+package retainpb
+
+//go:generate go run gen.go
@@ -0,0 +1,497 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: retain.proto
+
+package retainpb
+
+import (
+	fmt "fmt"
+	math "math"
+	time "time"
+
+	proto "github.com/gogo/protobuf/proto"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+var _ = time.Kitchen
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+type ReportRetainResultRequest struct {
+	// creation_date of the retain request, which identifies the garbage collection run.
+	CreationDate time.Time `protobuf:"bytes,1,opt,name=creation_date,json=creationDate,proto3,stdtime" json:"creation_date"`
+	// range_bits and range_index identify the part of a split filter, both are zero otherwise.
+	RangeBits            uint32   `protobuf:"varint,2,opt,name=range_bits,json=rangeBits,proto3" json:"range_bits,omitempty"`
+	RangeIndex           uint32   `protobuf:"varint,3,opt,name=range_index,json=rangeIndex,proto3" json:"range_index,omitempty"`
+	PiecesExamined       int64    `protobuf:"varint,4,opt,name=pieces_examined,json=piecesExamined,proto3" json:"pieces_examined,omitempty"`
+	PiecesTrashed        int64    `protobuf:"varint,5,opt,name=pieces_trashed,json=piecesTrashed,proto3" json:"pieces_trashed,omitempty"`
+	BytesReclaimed       int64    `protobuf:"varint,6,opt,name=bytes_reclaimed,json=bytesReclaimed,proto3" json:"bytes_reclaimed,omitempty"`
+	DurationMillis       int64    `protobuf:"varint,7,opt,name=duration_millis,json=durationMillis,proto3" json:"duration_millis,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReportRetainResultRequest) Reset()         { *m = ReportRetainResultRequest{} }
+func (m *ReportRetainResultRequest) String() string { return proto.CompactTextString(m) }
+func (*ReportRetainResultRequest) ProtoMessage()    {}
+func (*ReportRetainResultRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf3f3d64c6f8ebcc, []int{0}
+}
+func (m *ReportRetainResultRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReportRetainResultRequest.Unmarshal(m, b)
+}
+func (m *ReportRetainResultRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReportRetainResultRequest.Marshal(b, m, deterministic)
+}
+func (m *ReportRetainResultRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReportRetainResultRequest.Merge(m, src)
+}
+func (m *ReportRetainResultRequest) XXX_Size() int {
+	return xxx_messageInfo_ReportRetainResultRequest.Size(m)
+}
+func (m *ReportRetainResultRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReportRetainResultRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReportRetainResultRequest proto.InternalMessageInfo
+
+func (m *ReportRetainResultRequest) GetCreationDate() time.Time {
+	if m != nil {
+		return m.CreationDate
+	}
+	return time.Time{}
+}
+
+func (m *ReportRetainResultRequest) GetRangeBits() uint32 {
+	if m != nil {
+		return m.RangeBits
+	}
+	return 0
+}
+
+func (m *ReportRetainResultRequest) GetRangeIndex() uint32 {
+	if m != nil {
+		return m.RangeIndex
+	}
+	return 0
+}
+
+func (m *ReportRetainResultRequest) GetPiecesExamined() int64 {
+	if m != nil {
+		return m.PiecesExamined
+	}
+	return 0
+}
+
+func (m *ReportRetainResultRequest) GetPiecesTrashed() int64 {
+	if m != nil {
+		return m.PiecesTrashed
+	}
+	return 0
+}
+
+func (m *ReportRetainResultRequest) GetBytesReclaimed() int64 {
+	if m != nil {
+		return m.BytesReclaimed
+	}
+	return 0
+}
+
+func (m *ReportRetainResultRequest) GetDurationMillis() int64 {
+	if m != nil {
+		return m.DurationMillis
+	}
+	return 0
+}
+
+type ReportRetainResultResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReportRetainResultResponse) Reset()         { *m = ReportRetainResultResponse{} }
+func (m *ReportRetainResultResponse) String() string { return proto.CompactTextString(m) }
+func (*ReportRetainResultResponse) ProtoMessage()    {}
+func (*ReportRetainResultResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf3f3d64c6f8ebcc, []int{1}
+}
+func (m *ReportRetainResultResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReportRetainResultResponse.Unmarshal(m, b)
+}
+func (m *ReportRetainResultResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReportRetainResultResponse.Marshal(b, m, deterministic)
+}
+func (m *ReportRetainResultResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReportRetainResultResponse.Merge(m, src)
+}
+func (m *ReportRetainResultResponse) XXX_Size() int {
+	return xxx_messageInfo_ReportRetainResultResponse.Size(m)
+}
+func (m *ReportRetainResultResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReportRetainResultResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReportRetainResultResponse proto.InternalMessageInfo
+
+type GetRetainStatusRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetRetainStatusRequest) Reset()         { *m = GetRetainStatusRequest{} }
+func (m *GetRetainStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*GetRetainStatusRequest) ProtoMessage()    {}
+func (*GetRetainStatusRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf3f3d64c6f8ebcc, []int{2}
+}
+func (m *GetRetainStatusRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetRetainStatusRequest.Unmarshal(m, b)
+}
+func (m *GetRetainStatusRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetRetainStatusRequest.Marshal(b, m, deterministic)
+}
+func (m *GetRetainStatusRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetRetainStatusRequest.Merge(m, src)
+}
+func (m *GetRetainStatusRequest) XXX_Size() int {
+	return xxx_messageInfo_GetRetainStatusRequest.Size(m)
+}
+func (m *GetRetainStatusRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetRetainStatusRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetRetainStatusRequest proto.InternalMessageInfo
+
+type GetRetainStatusResponse struct {
+	// requests are the queued and active requests.
+	Requests []*RetainRequestStatus `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	// completed is the summary of the last processed request for every range.
+	Completed            []*RetainRequestSummary `protobuf:"bytes,2,rep,name=completed,proto3" json:"completed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                `json:"-"`
+	XXX_unrecognized     []byte                  `json:"-"`
+	XXX_sizecache        int32                   `json:"-"`
+}
+
+func (m *GetRetainStatusResponse) Reset()         { *m = GetRetainStatusResponse{} }
+func (m *GetRetainStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*GetRetainStatusResponse) ProtoMessage()    {}
+func (*GetRetainStatusResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf3f3d64c6f8ebcc, []int{3}
+}
+func (m *GetRetainStatusResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetRetainStatusResponse.Unmarshal(m, b)
+}
+func (m *GetRetainStatusResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetRetainStatusResponse.Marshal(b, m, deterministic)
+}
+func (m *GetRetainStatusResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetRetainStatusResponse.Merge(m, src)
+}
+func (m *GetRetainStatusResponse) XXX_Size() int {
+	return xxx_messageInfo_GetRetainStatusResponse.Size(m)
+}
+func (m *GetRetainStatusResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetRetainStatusResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetRetainStatusResponse proto.InternalMessageInfo
+
+func (m *GetRetainStatusResponse) GetRequests() []*RetainRequestStatus {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+func (m *GetRetainStatusResponse) GetCompleted() []*RetainRequestSummary {
+	if m != nil {
+		return m.Completed
+	}
+	return nil
+}
+
+type RetainRequestStatus struct {
+	CreationDate time.Time `protobuf:"bytes,1,opt,name=creation_date,json=creationDate,proto3,stdtime" json:"creation_date"`
+	RangeBits    uint32    `protobuf:"varint,2,opt,name=range_bits,json=rangeBits,proto3" json:"range_bits,omitempty"`
+	RangeIndex   uint32    `protobuf:"varint,3,opt,name=range_index,json=rangeIndex,proto3" json:"range_index,omitempty"`
+	Received     time.Time `protobuf:"bytes,4,opt,name=received,proto3,stdtime" json:"received"`
+	// active is set when the pieces are being walked, the fields below are only
+	// set for active requests.
+	Active               bool      `protobuf:"varint,5,opt,name=active,proto3" json:"active,omitempty"`
+	Started              time.Time `protobuf:"bytes,6,opt,name=started,proto3,stdtime" json:"started"`
+	PiecesExamined       int64     `protobuf:"varint,7,opt,name=pieces_examined,json=piecesExamined,proto3" json:"pieces_examined,omitempty"`
+	PiecesTrashed        int64     `protobuf:"varint,8,opt,name=pieces_trashed,json=piecesTrashed,proto3" json:"pieces_trashed,omitempty"`
+	PiecesFailed         int64     `protobuf:"varint,9,opt,name=pieces_failed,json=piecesFailed,proto3" json:"pieces_failed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *RetainRequestStatus) Reset()         { *m = RetainRequestStatus{} }
+func (m *RetainRequestStatus) String() string { return proto.CompactTextString(m) }
+func (*RetainRequestStatus) ProtoMessage()    {}
+func (*RetainRequestStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf3f3d64c6f8ebcc, []int{4}
+}
+func (m *RetainRequestStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RetainRequestStatus.Unmarshal(m, b)
+}
+func (m *RetainRequestStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RetainRequestStatus.Marshal(b, m, deterministic)
+}
+func (m *RetainRequestStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RetainRequestStatus.Merge(m, src)
+}
+func (m *RetainRequestStatus) XXX_Size() int {
+	return xxx_messageInfo_RetainRequestStatus.Size(m)
+}
+func (m *RetainRequestStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_RetainRequestStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RetainRequestStatus proto.InternalMessageInfo
+
+func (m *RetainRequestStatus) GetCreationDate() time.Time {
+	if m != nil {
+		return m.CreationDate
+	}
+	return time.Time{}
+}
+
+func (m *RetainRequestStatus) GetRangeBits() uint32 {
+	if m != nil {
+		return m.RangeBits
+	}
+	return 0
+}
+
+func (m *RetainRequestStatus) GetRangeIndex() uint32 {
+	if m != nil {
+		return m.RangeIndex
+	}
+	return 0
+}
+
+func (m *RetainRequestStatus) GetReceived() time.Time {
+	if m != nil {
+		return m.Received
+	}
+	return time.Time{}
+}
+
+func (m *RetainRequestStatus) GetActive() bool {
+	if m != nil {
+		return m.Active
+	}
+	return false
+}
+
+func (m *RetainRequestStatus) GetStarted() time.Time {
+	if m != nil {
+		return m.Started
+	}
+	return time.Time{}
+}
+
+func (m *RetainRequestStatus) GetPiecesExamined() int64 {
+	if m != nil {
+		return m.PiecesExamined
+	}
+	return 0
+}
+
+func (m *RetainRequestStatus) GetPiecesTrashed() int64 {
+	if m != nil {
+		return m.PiecesTrashed
+	}
+	return 0
+}
+
+func (m *RetainRequestStatus) GetPiecesFailed() int64 {
+	if m != nil {
+		return m.PiecesFailed
+	}
+	return 0
+}
+
+type RetainRequestSummary struct {
+	CreationDate           time.Time `protobuf:"bytes,1,opt,name=creation_date,json=creationDate,proto3,stdtime" json:"creation_date"`
+	RangeBits              uint32    `protobuf:"varint,2,opt,name=range_bits,json=rangeBits,proto3" json:"range_bits,omitempty"`
+	RangeIndex             uint32    `protobuf:"varint,3,opt,name=range_index,json=rangeIndex,proto3" json:"range_index,omitempty"`
+	Finished               time.Time `protobuf:"bytes,4,opt,name=finished,proto3,stdtime" json:"finished"`
+	PiecesExamined         int64     `protobuf:"varint,5,opt,name=pieces_examined,json=piecesExamined,proto3" json:"pieces_examined,omitempty"`
+	PiecesTrashed          int64     `protobuf:"varint,6,opt,name=pieces_trashed,json=piecesTrashed,proto3" json:"pieces_trashed,omitempty"`
+	PiecesFailed           int64     `protobuf:"varint,7,opt,name=pieces_failed,json=piecesFailed,proto3" json:"pieces_failed,omitempty"`
+	BytesReclaimed         int64     `protobuf:"varint,8,opt,name=bytes_reclaimed,json=bytesReclaimed,proto3" json:"bytes_reclaimed,omitempty"`
+	PiecesLeftToExpiration int64     `protobuf:"varint,9,opt,name=pieces_left_to_expiration,json=piecesLeftToExpiration,proto3" json:"pieces_left_to_expiration,omitempty"`
+	DurationMillis         int64     `protobuf:"varint,10,opt,name=duration_millis,json=durationMillis,proto3" json:"duration_millis,omitempty"`
+	// error is set when processing the request failed partway.
+	Error                string   `protobuf:"bytes,11,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RetainRequestSummary) Reset()         { *m = RetainRequestSummary{} }
+func (m *RetainRequestSummary) String() string { return proto.CompactTextString(m) }
+func (*RetainRequestSummary) ProtoMessage()    {}
+func (*RetainRequestSummary) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf3f3d64c6f8ebcc, []int{5}
+}
+func (m *RetainRequestSummary) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RetainRequestSummary.Unmarshal(m, b)
+}
+func (m *RetainRequestSummary) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RetainRequestSummary.Marshal(b, m, deterministic)
+}
+func (m *RetainRequestSummary) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RetainRequestSummary.Merge(m, src)
+}
+func (m *RetainRequestSummary) XXX_Size() int {
+	return xxx_messageInfo_RetainRequestSummary.Size(m)
+}
+func (m *RetainRequestSummary) XXX_DiscardUnknown() {
+	xxx_messageInfo_RetainRequestSummary.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RetainRequestSummary proto.InternalMessageInfo
+
+func (m *RetainRequestSummary) GetCreationDate() time.Time {
+	if m != nil {
+		return m.CreationDate
+	}
+	return time.Time{}
+}
+
+func (m *RetainRequestSummary) GetRangeBits() uint32 {
+	if m != nil {
+		return m.RangeBits
+	}
+	return 0
+}
+
+func (m *RetainRequestSummary) GetRangeIndex() uint32 {
+	if m != nil {
+		return m.RangeIndex
+	}
+	return 0
+}
+
+func (m *RetainRequestSummary) GetFinished() time.Time {
+	if m != nil {
+		return m.Finished
+	}
+	return time.Time{}
+}
+
+func (m *RetainRequestSummary) GetPiecesExamined() int64 {
+	if m != nil {
+		return m.PiecesExamined
+	}
+	return 0
+}
+
+func (m *RetainRequestSummary) GetPiecesTrashed() int64 {
+	if m != nil {
+		return m.PiecesTrashed
+	}
+	return 0
+}
+
+func (m *RetainRequestSummary) GetPiecesFailed() int64 {
+	if m != nil {
+		return m.PiecesFailed
+	}
+	return 0
+}
+
+func (m *RetainRequestSummary) GetBytesReclaimed() int64 {
+	if m != nil {
+		return m.BytesReclaimed
+	}
+	return 0
+}
+
+func (m *RetainRequestSummary) GetPiecesLeftToExpiration() int64 {
+	if m != nil {
+		return m.PiecesLeftToExpiration
+	}
+	return 0
+}
+
+func (m *RetainRequestSummary) GetDurationMillis() int64 {
+	if m != nil {
+		return m.DurationMillis
+	}
+	return 0
+}
+
+func (m *RetainRequestSummary) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*ReportRetainResultRequest)(nil), "retain.ReportRetainResultRequest")
+	proto.RegisterType((*ReportRetainResultResponse)(nil), "retain.ReportRetainResultResponse")
+	proto.RegisterType((*GetRetainStatusRequest)(nil), "retain.GetRetainStatusRequest")
+	proto.RegisterType((*GetRetainStatusResponse)(nil), "retain.GetRetainStatusResponse")
+	proto.RegisterType((*RetainRequestStatus)(nil), "retain.RetainRequestStatus")
+	proto.RegisterType((*RetainRequestSummary)(nil), "retain.RetainRequestSummary")
+}
+
+func init() { proto.RegisterFile("retain.proto", fileDescriptor_bf3f3d64c6f8ebcc) }
+
+var fileDescriptor_bf3f3d64c6f8ebcc = []byte{
+	// 622 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xcc, 0x54, 0xcd, 0x6e, 0xd3, 0x4c,
+	0x14, 0xad, 0x9b, 0xaf, 0xf9, 0xb9, 0x49, 0xbf, 0xea, 0x9b, 0xaf, 0x2a, 0x6e, 0x28, 0x24, 0x35,
+	0x42, 0x64, 0x95, 0x48, 0x61, 0x81, 0x60, 0x81, 0x50, 0xa1, 0x54, 0x95, 0x60, 0x63, 0xb2, 0x42,
+	0x42, 0xd6, 0xc4, 0xbe, 0x49, 0x07, 0xd9, 0x1e, 0x33, 0x73, 0x53, 0xb5, 0x2f, 0x81, 0x78, 0x13,
+	0x5e, 0xa3, 0x4f, 0x01, 0x0f, 0xc2, 0x06, 0x65, 0x66, 0xdc, 0x22, 0xea, 0xa2, 0xb0, 0xeb, 0x2e,
+	0x73, 0xee, 0x39, 0xc7, 0xd1, 0xbd, 0x47, 0x07, 0x3a, 0x0a, 0x89, 0x8b, 0x7c, 0x58, 0x28, 0x49,
+	0x92, 0xd5, 0xed, 0xab, 0x0b, 0x73, 0x39, 0x97, 0x16, 0xeb, 0xf6, 0xe6, 0x52, 0xce, 0x53, 0x1c,
+	0x99, 0xd7, 0x74, 0x31, 0x1b, 0x91, 0xc8, 0x50, 0x13, 0xcf, 0x0a, 0x4b, 0x08, 0x2e, 0xd6, 0x61,
+	0x37, 0xc4, 0x42, 0x2a, 0x0a, 0x8d, 0x3a, 0x44, 0xbd, 0x48, 0x29, 0xc4, 0x4f, 0x0b, 0xd4, 0xc4,
+	0x8e, 0x61, 0x33, 0x56, 0xc8, 0x49, 0xc8, 0x3c, 0x4a, 0x38, 0xa1, 0xef, 0xf5, 0xbd, 0x41, 0x7b,
+	0xdc, 0x1d, 0x5a, 0xdb, 0x61, 0x69, 0x3b, 0x9c, 0x94, 0xb6, 0x07, 0xcd, 0x8b, 0x6f, 0xbd, 0xb5,
+	0x2f, 0xdf, 0x7b, 0x5e, 0xd8, 0x29, 0xa5, 0xaf, 0x38, 0x21, 0xbb, 0x07, 0xa0, 0x78, 0x3e, 0xc7,
+	0x68, 0x2a, 0x48, 0xfb, 0xeb, 0x7d, 0x6f, 0xb0, 0x19, 0xb6, 0x0c, 0x72, 0x20, 0x48, 0xb3, 0x1e,
+	0xb4, 0xed, 0x58, 0xe4, 0x09, 0x9e, 0xf9, 0x35, 0x33, 0xb7, 0x8a, 0xe3, 0x25, 0xc2, 0x1e, 0xc1,
+	0x56, 0x21, 0x30, 0x46, 0x1d, 0xe1, 0x19, 0xcf, 0x44, 0x8e, 0x89, 0xff, 0x4f, 0xdf, 0x1b, 0xd4,
+	0xc2, 0x7f, 0x2d, 0x7c, 0xe8, 0x50, 0xf6, 0x10, 0x1c, 0x12, 0x91, 0xe2, 0xfa, 0x04, 0x13, 0x7f,
+	0xc3, 0xf0, 0x36, 0x2d, 0x3a, 0xb1, 0xe0, 0xd2, 0x6f, 0x7a, 0x4e, 0xa8, 0x23, 0x85, 0x71, 0xca,
+	0x45, 0x86, 0x89, 0x5f, 0xb7, 0x7e, 0x06, 0x0e, 0x4b, 0x74, 0x49, 0x4c, 0x16, 0xca, 0xee, 0x20,
+	0x13, 0x69, 0x2a, 0xb4, 0xdf, 0xb0, 0xc4, 0x12, 0x7e, 0x6b, 0xd0, 0x60, 0x0f, 0xba, 0x55, 0x9b,
+	0xd4, 0x85, 0xcc, 0x35, 0x06, 0x3e, 0xec, 0x1c, 0xa1, 0x1b, 0xbd, 0x23, 0x4e, 0x0b, 0xed, 0x96,
+	0x1c, 0x7c, 0xf6, 0xe0, 0xce, 0xb5, 0x91, 0x55, 0xb1, 0x27, 0xd0, 0x54, 0x96, 0xa6, 0x7d, 0xaf,
+	0x5f, 0x1b, 0xb4, 0xc7, 0x77, 0x87, 0xee, 0xe8, 0xe5, 0x57, 0xcc, 0xd4, 0xc9, 0x2e, 0xc9, 0xec,
+	0x19, 0xb4, 0x62, 0x99, 0x15, 0x29, 0x12, 0x26, 0xfe, 0xba, 0x51, 0xee, 0x55, 0x2b, 0x17, 0x59,
+	0xc6, 0xd5, 0x79, 0x78, 0x45, 0x0f, 0xbe, 0xd6, 0xe0, 0xff, 0x0a, 0xf7, 0xdb, 0x94, 0x86, 0x17,
+	0xcb, 0xbd, 0xc4, 0x28, 0x4e, 0x5d, 0x0c, 0x56, 0xfd, 0x17, 0x97, 0x2a, 0xb6, 0x03, 0x75, 0x1e,
+	0x93, 0x38, 0x45, 0x13, 0x8f, 0x66, 0xe8, 0x5e, 0xec, 0x39, 0x34, 0x34, 0x71, 0x45, 0x2e, 0x0f,
+	0xab, 0x1a, 0x97, 0xa2, 0xaa, 0x9c, 0x36, 0x56, 0xcc, 0x69, 0xb3, 0x2a, 0xa7, 0x0f, 0xc0, 0x01,
+	0xd1, 0x8c, 0x8b, 0x14, 0x13, 0xbf, 0x65, 0x58, 0x1d, 0x0b, 0xbe, 0x36, 0x58, 0xf0, 0xa3, 0x06,
+	0xdb, 0x55, 0x57, 0xbd, 0x65, 0x27, 0x9b, 0x89, 0x5c, 0xe8, 0x93, 0xbf, 0x3d, 0x59, 0xa9, 0xaa,
+	0x5a, 0xed, 0xc6, 0x8a, 0xab, 0xad, 0xaf, 0xb4, 0xda, 0xc6, 0xf5, 0xd5, 0x56, 0xf5, 0x44, 0xb3,
+	0xb2, 0x27, 0x9e, 0xc2, 0xae, 0x73, 0x4b, 0x71, 0x46, 0x11, 0xc9, 0x08, 0xcf, 0x0a, 0x61, 0x1b,
+	0xc2, 0x1d, 0x6d, 0xc7, 0x12, 0xde, 0xe0, 0x8c, 0x26, 0xf2, 0xf0, 0x72, 0x5a, 0x55, 0x31, 0x50,
+	0x55, 0x31, 0x6c, 0x1b, 0x36, 0x50, 0x29, 0xa9, 0xfc, 0x76, 0xdf, 0x1b, 0xb4, 0x42, 0xfb, 0x18,
+	0x2b, 0xf8, 0xef, 0x88, 0xab, 0x29, 0x9f, 0xe3, 0x4b, 0x99, 0xa6, 0x18, 0x1b, 0xcf, 0x0f, 0xc0,
+	0xae, 0xb7, 0x11, 0xdb, 0xbf, 0xea, 0x80, 0x1b, 0x3a, 0xbf, 0x1b, 0xfc, 0x89, 0xe2, 0xca, 0x6c,
+	0x6d, 0x9c, 0x40, 0xe7, 0xd7, 0xc2, 0x62, 0x13, 0xd8, 0xfa, 0xad, 0xc3, 0xd8, 0xfd, 0xd2, 0xa8,
+	0xba, 0xf7, 0xba, 0xbd, 0x1b, 0xe7, 0xe5, 0x57, 0x0e, 0xf6, 0xdf, 0xf7, 0x34, 0x49, 0xf5, 0x71,
+	0x28, 0xe4, 0xc8, 0xfc, 0x18, 0x15, 0x4a, 0x9c, 0x72, 0xc2, 0x91, 0x95, 0x16, 0xd3, 0x69, 0xdd,
+	0x84, 0xe7, 0xf1, 0xcf, 0x01, 0x00, 0x55, 0xa7, 0x22, 0xa9, 0x0c, 0x07, 0x00, 0x00,
+}
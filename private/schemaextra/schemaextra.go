@@ -0,0 +1,185 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package schemaextra captures the schema objects of postgres and cockroach
+// databases that storj.io/private/dbutil/dbschema doesn't: sequences and CHECK
+// constraints. Column defaults are part of dbschema already.
+//
+// pgutil.QuerySchema refuses tables with CHECK constraints, this package is a
+// stopgap until dbschema and pgutil capture them.
+package schemaextra
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/private/dbutil/dbschema"
+)
+
+// Error is the error class of the package.
+var Error = errs.Class("schemaextra")
+
+// Schema is the part of a database schema that dbschema doesn't capture.
+type Schema struct {
+	Sequences []*Sequence
+	Checks    []*Check
+}
+
+// Sequence is a sequence of the schema.
+type Sequence struct {
+	Name      string
+	Type      string
+	Start     string
+	Increment string
+}
+
+// Check is a CHECK constraint of a table.
+type Check struct {
+	Table string
+	Name  string
+	// Expression is the normalized expression of the constraint.
+	Expression string
+}
+
+// Query loads the sequences and CHECK constraints of the current schema.
+func Query(ctx context.Context, db dbschema.Queryer) (_ *Schema, err error) {
+	schema := &Schema{}
+
+	var version string
+	if err := db.QueryRowContext(ctx, `SELECT version()`).Scan(&version); err != nil {
+		return nil, Error.Wrap(err)
+	}
+
+	err = func() (err error) {
+		rows, err := db.QueryContext(ctx, `
+			SELECT sequence_name, data_type, start_value, increment
+			FROM information_schema.sequences
+			WHERE sequence_schema = CURRENT_SCHEMA
+		`)
+		if err != nil {
+			return err
+		}
+		defer func() { err = errs.Combine(err, rows.Close()) }()
+
+		for rows.Next() {
+			sequence := &Sequence{}
+			if err := rows.Scan(&sequence.Name, &sequence.Type, &sequence.Start, &sequence.Increment); err != nil {
+				return err
+			}
+			sequence.Type = normalizeType(sequence.Type)
+			schema.Sequences = append(schema.Sequences, sequence)
+		}
+		return rows.Err()
+	}()
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+
+	err = func() (err error) {
+		// cockroach has a .condef field and it's way faster than the function call
+		definitionClause := `pg_get_constraintdef(pg_constraint.oid)`
+		if strings.Contains(version, "CockroachDB") {
+			definitionClause = `pg_constraint.condef`
+		}
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT pg_class.relname, pg_constraint.conname, `+definitionClause+`
+			FROM pg_constraint
+				JOIN pg_class ON pg_class.oid = pg_constraint.conrelid
+				JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace
+			WHERE pg_namespace.nspname = CURRENT_SCHEMA AND pg_constraint.contype = 'c'
+		`)
+		if err != nil {
+			return err
+		}
+		defer func() { err = errs.Combine(err, rows.Close()) }()
+
+		for rows.Next() {
+			check := &Check{}
+			var definition string
+			if err := rows.Scan(&check.Table, &check.Name, &definition); err != nil {
+				return err
+			}
+			check.Expression = NormalizeCheck(definition)
+			schema.Checks = append(schema.Checks, check)
+		}
+		return rows.Err()
+	}()
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+
+	schema.Sort()
+	return schema, nil
+}
+
+// Sort sorts the sequences and the checks by their names.
+func (schema *Schema) Sort() {
+	sort.Slice(schema.Sequences, func(i, k int) bool {
+		return schema.Sequences[i].Name < schema.Sequences[k].Name
+	})
+	sort.Slice(schema.Checks, func(i, k int) bool {
+		if schema.Checks[i].Table != schema.Checks[k].Table {
+			return schema.Checks[i].Table < schema.Checks[k].Table
+		}
+		return schema.Checks[i].Name < schema.Checks[k].Name
+	})
+}
+
+var (
+	rxCast       = regexp.MustCompile(`:::?[A-Za-z0-9_]+(\[\])?`)
+	rxWhitespace = regexp.MustCompile(`\s+`)
+)
+
+// NormalizeCheck returns the expression of a CHECK constraint definition
+// without the rendering differences of postgres and cockroach: the CHECK
+// keyword, the enclosing parentheses and the type casts are removed.
+func NormalizeCheck(definition string) string {
+	expression := strings.TrimSpace(definition)
+	expression = strings.TrimSpace(strings.TrimPrefix(expression, "CHECK"))
+	expression = rxCast.ReplaceAllString(expression, "")
+	expression = rxWhitespace.ReplaceAllString(expression, " ")
+
+	for enclosed(expression) {
+		expression = strings.TrimSpace(expression[1 : len(expression)-1])
+	}
+	return expression
+}
+
+// enclosed returns whether the expression is enclosed by a pair of parentheses.
+func enclosed(expression string) bool {
+	if !strings.HasPrefix(expression, "(") || !strings.HasSuffix(expression, ")") {
+		return false
+	}
+	depth := 0
+	for i, r := range expression {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && i < len(expression)-1 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// normalizeType returns the postgres name of a cockroach integer type.
+func normalizeType(typ string) string {
+	switch strings.ToLower(typ) {
+	case "int8":
+		return "bigint"
+	case "int4":
+		return "integer"
+	case "int2":
+		return "smallint"
+	default:
+		return strings.ToLower(typ)
+	}
+}
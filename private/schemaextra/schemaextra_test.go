@@ -0,0 +1,76 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package schemaextra_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/testcontext"
+	"storj.io/private/dbutil/pgtest"
+	"storj.io/private/dbutil/tempdb"
+	"storj.io/storj/private/schemaextra"
+)
+
+func TestNormalizeCheck(t *testing.T) {
+	for _, tt := range []struct {
+		definition string
+		expected   string
+	}{
+		{definition: `CHECK ((amount > 0))`, expected: `amount > 0`},
+		{definition: `CHECK ((amount > 0:::INT8))`, expected: `amount > 0`},
+		{definition: `CHECK (((a > 0) AND (b > 0)))`, expected: `(a > 0) AND (b > 0)`},
+		{definition: `CHECK ((status = 'done'::text))`, expected: `status = 'done'`},
+		{definition: "CHECK ((a > 0)\n\tOR (b > 0))", expected: `(a > 0) OR (b > 0)`},
+	} {
+		require.Equal(t, tt.expected, schemaextra.NormalizeCheck(tt.definition), tt.definition)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	pgtest.Run(t, func(ctx *testcontext.Context, t *testing.T, connstr string) {
+		query := func(script string) *schemaextra.Schema {
+			db, err := tempdb.OpenUnique(ctx, connstr, "schemaextra")
+			require.NoError(t, err)
+			defer ctx.Check(db.Close)
+
+			_, err = db.ExecContext(ctx, script)
+			require.NoError(t, err)
+
+			schema, err := schemaextra.Query(ctx, db)
+			require.NoError(t, err)
+			return schema
+		}
+
+		expected := query(`
+			CREATE SEQUENCE counters INCREMENT BY 2;
+			CREATE TABLE payments (
+				id bigint NOT NULL,
+				amount bigint NOT NULL,
+				CONSTRAINT payments_amount_positive CHECK (amount > 0),
+				PRIMARY KEY ( id )
+			);
+		`)
+		require.Equal(t, []*schemaextra.Check{
+			{Table: "payments", Name: "payments_amount_positive", Expression: "amount > 0"},
+		}, expected.Checks)
+		require.Len(t, expected.Sequences, 1)
+		require.Equal(t, "counters", expected.Sequences[0].Name)
+		require.Equal(t, "2", expected.Sequences[0].Increment)
+
+		// a migration that forgets the check doesn't compare equal anymore.
+		actual := query(`
+			CREATE SEQUENCE counters INCREMENT BY 2;
+			CREATE TABLE payments (
+				id bigint NOT NULL,
+				amount bigint NOT NULL,
+				PRIMARY KEY ( id )
+			);
+		`)
+		require.Empty(t, actual.Checks)
+		require.NotEqual(t, expected, actual)
+		require.Equal(t, expected.Sequences, actual.Sequences)
+	})
+}
@@ -132,6 +132,22 @@ func (bad *BadBlobs) RestoreTrash(ctx context.Context, namespace []byte) ([][]by
 	return bad.blobs.RestoreTrash(ctx, namespace)
 }
 
+// WalkTrash executes walkFunc for each blob in the trash of the given namespace.
+func (bad *BadBlobs) WalkTrash(ctx context.Context, namespace []byte, walkFunc func(storage.BlobInfo) error) error {
+	if err := bad.err.Err(); err != nil {
+		return err
+	}
+	return bad.blobs.WalkTrash(ctx, namespace, walkFunc)
+}
+
+// RestoreTrashed restores a single trashed blob.
+func (bad *BadBlobs) RestoreTrashed(ctx context.Context, ref storage.BlobRef, formatVer storage.FormatVersion, verify func(storage.BlobReader) error) error {
+	if err := bad.err.Err(); err != nil {
+		return err
+	}
+	return bad.blobs.RestoreTrashed(ctx, ref, formatVer, verify)
+}
+
 // EmptyTrash empties the trash.
 func (bad *BadBlobs) EmptyTrash(ctx context.Context, namespace []byte, trashedBefore time.Time) (int64, [][]byte, error) {
 	if err := bad.err.Err(); err != nil {
@@ -192,6 +208,23 @@ func (bad *BadBlobs) WalkNamespace(ctx context.Context, namespace []byte, walkFu
 	return bad.blobs.WalkNamespace(ctx, namespace, walkFunc)
 }
 
+// ListKeyPrefixes returns the prefixes by which the keys in the namespace are grouped.
+func (bad *BadBlobs) ListKeyPrefixes(ctx context.Context, namespace []byte) ([]string, error) {
+	if err := bad.err.Err(); err != nil {
+		return nil, err
+	}
+	return bad.blobs.ListKeyPrefixes(ctx, namespace)
+}
+
+// WalkNamespacePrefix executes walkFunc for each locally stored blob in the given namespace,
+// whose key is grouped under keyPrefix.
+func (bad *BadBlobs) WalkNamespacePrefix(ctx context.Context, namespace []byte, keyPrefix string, walkFunc func(storage.BlobInfo) error) error {
+	if err := bad.err.Err(); err != nil {
+		return err
+	}
+	return bad.blobs.WalkNamespacePrefix(ctx, namespace, keyPrefix, walkFunc)
+}
+
 // ListNamespaces returns all namespaces that might be storing data.
 func (bad *BadBlobs) ListNamespaces(ctx context.Context) ([][]byte, error) {
 	if err := bad.err.Err(); err != nil {
@@ -107,6 +107,22 @@ func (slow *SlowBlobs) RestoreTrash(ctx context.Context, namespace []byte) ([][]
 	return slow.blobs.RestoreTrash(ctx, namespace)
 }
 
+// WalkTrash executes walkFunc for each blob in the trash of the given namespace.
+func (slow *SlowBlobs) WalkTrash(ctx context.Context, namespace []byte, walkFunc func(storage.BlobInfo) error) error {
+	if err := slow.sleep(ctx); err != nil {
+		return errs.Wrap(err)
+	}
+	return slow.blobs.WalkTrash(ctx, namespace, walkFunc)
+}
+
+// RestoreTrashed restores a single trashed blob.
+func (slow *SlowBlobs) RestoreTrashed(ctx context.Context, ref storage.BlobRef, formatVer storage.FormatVersion, verify func(storage.BlobReader) error) error {
+	if err := slow.sleep(ctx); err != nil {
+		return errs.Wrap(err)
+	}
+	return slow.blobs.RestoreTrashed(ctx, ref, formatVer, verify)
+}
+
 // EmptyTrash empties the trash.
 func (slow *SlowBlobs) EmptyTrash(ctx context.Context, namespace []byte, trashedBefore time.Time) (int64, [][]byte, error) {
 	if err := slow.sleep(ctx); err != nil {
@@ -167,6 +183,20 @@ func (slow *SlowBlobs) WalkNamespace(ctx context.Context, namespace []byte, walk
 	return slow.blobs.WalkNamespace(ctx, namespace, walkFunc)
 }
 
+// ListKeyPrefixes returns the prefixes by which the keys in the namespace are grouped.
+func (slow *SlowBlobs) ListKeyPrefixes(ctx context.Context, namespace []byte) ([]string, error) {
+	return slow.blobs.ListKeyPrefixes(ctx, namespace)
+}
+
+// WalkNamespacePrefix executes walkFunc for each locally stored blob in the given namespace,
+// whose key is grouped under keyPrefix.
+func (slow *SlowBlobs) WalkNamespacePrefix(ctx context.Context, namespace []byte, keyPrefix string, walkFunc func(storage.BlobInfo) error) error {
+	if err := slow.sleep(ctx); err != nil {
+		return errs.Wrap(err)
+	}
+	return slow.blobs.WalkNamespacePrefix(ctx, namespace, keyPrefix, walkFunc)
+}
+
 // ListNamespaces returns all namespaces that might be storing data.
 func (slow *SlowBlobs) ListNamespaces(ctx context.Context) ([][]byte, error) {
 	return slow.blobs.ListNamespaces(ctx)
@@ -137,7 +137,8 @@ type Satellite struct {
 	}
 
 	GarbageCollection struct {
-		Service *gc.Service
+		Service  *gc.Service
+		Endpoint *gc.Endpoint
 	}
 
 	ExpiredDeletion struct {
@@ -375,7 +376,7 @@ func (planet *Planet) newSatellite(ctx context.Context, prefix string, index int
 		return nil, err
 	}
 
-	db, err := satellitedbtest.CreateMasterDB(ctx, log.Named("db"), planet.config.Name, "S", index, databases.MasterDB)
+	db, err := satellitedbtest.CreateMigratedMasterDB(ctx, log.Named("db"), planet.config.Name, "S", index, databases.MasterDB)
 	if err != nil {
 		return nil, err
 	}
@@ -602,6 +603,7 @@ func createNewSystem(name string, log *zap.Logger, config satellite.Config, peer
 	system.Audit.Reporter = peer.Audit.Reporter
 
 	system.GarbageCollection.Service = gcPeer.GarbageCollection.Service
+	system.GarbageCollection.Endpoint = api.GarbageCollection.Endpoint
 
 	system.ExpiredDeletion.Chore = peer.ExpiredDeletion.Chore
 	system.ZombieDeletion.Chore = peer.ZombieDeletion.Chore
@@ -193,9 +193,12 @@ func (planet *Planet) newStorageNode(ctx context.Context, prefix string, index,
 		Pieces:    pieces.DefaultConfig,
 		Filestore: filestore.DefaultConfig,
 		Retain: retain.Config{
-			MaxTimeSkew: 10 * time.Second,
-			Status:      retain.Enabled,
-			Concurrency: 5,
+			MaxTimeSkew:   10 * time.Second,
+			Status:        retain.Enabled,
+			Concurrency:   5,
+			Path:          filepath.Join(storageDir, "retain"),
+			ReportResults: true,
+			HistoryPath:   filepath.Join(storageDir, "retain-history.log"),
 		},
 		Version: planet.NewVersionConfig(),
 		Bandwidth: bandwidth.Config{
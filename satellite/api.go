@@ -28,6 +28,7 @@ import (
 	"storj.io/storj/private/lifecycle"
 	"storj.io/storj/private/post"
 	"storj.io/storj/private/post/oauth2"
+	"storj.io/storj/private/retainpb"
 	"storj.io/storj/private/server"
 	"storj.io/storj/private/version/checker"
 	"storj.io/storj/satellite/accounting"
@@ -37,6 +38,7 @@ import (
 	"storj.io/storj/satellite/console/consoleauth"
 	"storj.io/storj/satellite/console/consoleweb"
 	"storj.io/storj/satellite/contact"
+	"storj.io/storj/satellite/gc"
 	"storj.io/storj/satellite/gracefulexit"
 	"storj.io/storj/satellite/inspector"
 	"storj.io/storj/satellite/internalpb"
@@ -77,8 +79,9 @@ type API struct {
 	}
 
 	Debug struct {
-		Listener net.Listener
-		Server   *debug.Server
+		Listener  net.Listener
+		Server    *debug.Server
+		Migration *MigrationStatus
 	}
 
 	Contact struct {
@@ -159,6 +162,10 @@ type API struct {
 		Endpoint *gracefulexit.Endpoint
 	}
 
+	GarbageCollection struct {
+		Endpoint *gc.Endpoint
+	}
+
 	Analytics struct {
 		Service *analytics.Service
 	}
@@ -204,6 +211,9 @@ func NewAPI(log *zap.Logger, full *identity.FullIdentity, db DB,
 			Run:   peer.Debug.Server.Run,
 			Close: peer.Debug.Server.Close,
 		})
+
+		peer.Debug.Migration = NewMigrationStatus(db)
+		peer.Debug.Server.Panel.Add(peer.Debug.Migration.debugGroup())
 	}
 
 	var err error
@@ -627,6 +637,16 @@ func NewAPI(log *zap.Logger, full *identity.FullIdentity, db DB,
 		}
 	}
 
+	{ // setup garbage collection endpoint
+		peer.GarbageCollection.Endpoint = gc.NewEndpoint(
+			peer.Log.Named("gc:endpoint"),
+			peer.DB.GarbageCollectionRuns(),
+		)
+		if err := retainpb.DRPCRegisterGarbageCollection(peer.Server.DRPC(), peer.GarbageCollection.Endpoint); err != nil {
+			return nil, errs.Combine(err, peer.Close())
+		}
+	}
+
 	{ // setup SnoPayout endpoint
 		peer.SNOPayouts.DB = peer.DB.SNOPayouts()
 		peer.SNOPayouts.Service = snopayouts.NewService(
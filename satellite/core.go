@@ -66,8 +66,9 @@ type Core struct {
 	}
 
 	Debug struct {
-		Listener net.Listener
-		Server   *debug.Server
+		Listener  net.Listener
+		Server    *debug.Server
+		Migration *MigrationStatus
 	}
 
 	// services and endpoints
@@ -177,6 +178,9 @@ func New(log *zap.Logger, full *identity.FullIdentity, db DB,
 			Run:   peer.Debug.Server.Run,
 			Close: peer.Debug.Server.Close,
 		})
+
+		peer.Debug.Migration = NewMigrationStatus(db)
+		peer.Debug.Server.Panel.Add(peer.Debug.Migration.debugGroup())
 	}
 
 	var err error
@@ -270,6 +274,7 @@ func New(log *zap.Logger, full *identity.FullIdentity, db DB,
 			Run:   peer.Metainfo.SegmentLoop.Run,
 			Close: peer.Metainfo.SegmentLoop.Close,
 		})
+		peer.Debug.Server.Panel.Add(peer.Metainfo.SegmentLoop.DebugGroup())
 	}
 
 	{ // setup datarepair
@@ -61,6 +61,9 @@ type GarbageCollection struct {
 
 	GarbageCollection struct {
 		Service *gc.Service
+
+		AdminListener net.Listener
+		AdminServer   *gc.AdminServer
 	}
 }
 
@@ -142,14 +145,22 @@ func NewGarbageCollection(log *zap.Logger, full *identity.FullIdentity, db DB,
 			Run:   peer.Metainfo.SegmentLoop.Run,
 			Close: peer.Metainfo.SegmentLoop.Close,
 		})
+		peer.Debug.Server.Panel.Add(peer.Metainfo.SegmentLoop.DebugGroup())
 	}
 
 	{ // setup garbage collection
+		if config.GarbageCollection.Enabled {
+			if err := config.GarbageCollection.Validate(); err != nil {
+				return nil, errs.Combine(err, peer.Close())
+			}
+		}
+
 		peer.GarbageCollection.Service = gc.NewService(
 			peer.Log.Named("garbage-collection"),
 			config.GarbageCollection,
 			peer.Dialer,
 			peer.Overlay.DB,
+			peer.DB.GarbageCollectionRuns(),
 			peer.Metainfo.SegmentLoop,
 		)
 		peer.Services.Add(lifecycle.Item{
@@ -158,6 +169,26 @@ func NewGarbageCollection(log *zap.Logger, full *identity.FullIdentity, db DB,
 		})
 		peer.Debug.Server.Panel.Add(
 			debug.Cycle("Garbage Collection", peer.GarbageCollection.Service.Loop))
+
+		if config.GarbageCollection.AdminAddress != "" {
+			var err error
+			peer.GarbageCollection.AdminListener, err = net.Listen("tcp", config.GarbageCollection.AdminAddress)
+			if err != nil {
+				return nil, errs.Combine(err, peer.Close())
+			}
+
+			peer.GarbageCollection.AdminServer = gc.NewAdminServer(
+				peer.Log.Named("garbage-collection:admin"),
+				peer.GarbageCollection.AdminListener,
+				peer.GarbageCollection.Service,
+				config.Admin.AuthorizationToken,
+			)
+			peer.Servers.Add(lifecycle.Item{
+				Name:  "garbage-collection:admin",
+				Run:   peer.GarbageCollection.AdminServer.Run,
+				Close: peer.GarbageCollection.AdminServer.Close,
+			})
+		}
 	}
 
 	return peer, nil
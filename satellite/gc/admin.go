@@ -0,0 +1,205 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"storj.io/common/errs2"
+)
+
+// AdminServer provides endpoints to manually run garbage collection.
+//
+// POST /api/gc/runs starts a run, or joins the one that is already running,
+// and responds with its information once it has finished. With ?async=true it
+// responds immediately, and with ?exclude=<node ids> the comma-separated nodes
+// are not sent filters in the run. GET /api/gc/runs/{id} responds with the
+// information about a run, and GET /api/gc/runs/latest with the record of the
+// most recent run in the history. GET /api/gc/status responds with the status
+// of the service for health checks.
+type AdminServer struct {
+	log *zap.Logger
+
+	listener net.Listener
+	server   http.Server
+
+	service *Service
+}
+
+// NewAdminServer returns a new AdminServer. Requests must be authorized with
+// the token, and are all forbidden when it is empty.
+func NewAdminServer(log *zap.Logger, listener net.Listener, service *Service, authorizationToken string) *AdminServer {
+	server := &AdminServer{
+		log:      log,
+		listener: listener,
+		service:  service,
+	}
+
+	root := mux.NewRouter()
+	api := root.PathPrefix("/api/gc/").Subrouter()
+	api.Use(allowedAuthorization(authorizationToken))
+	api.HandleFunc("/runs", server.triggerRun).Methods("POST")
+	api.HandleFunc("/runs/latest", server.getLatestRun).Methods("GET")
+	api.HandleFunc("/runs/{id}", server.getRun).Methods("GET")
+	api.HandleFunc("/status", server.getStatus).Methods("GET")
+
+	server.server.Handler = root
+	return server
+}
+
+// Handler returns the handler serving the endpoints.
+func (server *AdminServer) Handler() http.Handler {
+	return server.server.Handler
+}
+
+// Run starts the admin endpoint.
+func (server *AdminServer) Run(ctx context.Context) error {
+	if server.listener == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var group errgroup.Group
+	group.Go(func() error {
+		<-ctx.Done()
+		return Error.Wrap(server.server.Shutdown(context.Background()))
+	})
+	group.Go(func() error {
+		defer cancel()
+		err := server.server.Serve(server.listener)
+		if errs2.IsCanceled(err) || errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		return Error.Wrap(err)
+	})
+	return group.Wait()
+}
+
+// Close closes server and underlying listener.
+func (server *AdminServer) Close() error {
+	return Error.Wrap(server.server.Close())
+}
+
+func (server *AdminServer) triggerRun(w http.ResponseWriter, r *http.Request) {
+	var excluded NodeIDs
+	if err := excluded.Set(r.URL.Query().Get("exclude")); err != nil {
+		sendJSONError(w, "invalid excluded nodes", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, done, err := server.service.TriggerExcluding(excluded)
+	if err != nil {
+		sendJSONError(w, "unable to trigger garbage collection", err.Error(), http.StatusConflict)
+		return
+	}
+	server.log.Info("garbage collection run triggered", zap.Int64("run", id), zap.Stringer("excluded", excluded))
+
+	status := http.StatusAccepted
+	if r.URL.Query().Get("async") != "true" {
+		select {
+		case <-done:
+			status = http.StatusOK
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	info, ok := server.service.LookupRun(id)
+	if !ok {
+		sendJSONError(w, "run not found", "", http.StatusNotFound)
+		return
+	}
+	sendJSON(w, status, info)
+}
+
+func (server *AdminServer) getRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		sendJSONError(w, "invalid run id", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info, ok := server.service.LookupRun(id)
+	if !ok {
+		sendJSONError(w, "run not found", "", http.StatusNotFound)
+		return
+	}
+	sendJSON(w, http.StatusOK, info)
+}
+
+func (server *AdminServer) getLatestRun(w http.ResponseWriter, r *http.Request) {
+	if server.service.history == nil {
+		sendJSONError(w, "run history not available", "", http.StatusNotFound)
+		return
+	}
+
+	record, err := server.service.history.LatestGCRun(r.Context())
+	if ErrNoRuns.Has(err) {
+		sendJSONError(w, "no runs recorded", "", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendJSONError(w, "unable to get latest run", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, http.StatusOK, record)
+}
+
+func (server *AdminServer) getStatus(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, http.StatusOK, server.service.Status())
+}
+
+func allowedAuthorization(token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				sendJSONError(w, "Authorization not enabled.", "", http.StatusForbidden)
+				return
+			}
+
+			equality := subtle.ConstantTimeCompare(
+				[]byte(r.Header.Get("Authorization")),
+				[]byte(token),
+			)
+			if equality != 1 {
+				sendJSONError(w, "Forbidden", "", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func sendJSONError(w http.ResponseWriter, errMsg, detail string, statusCode int) {
+	sendJSON(w, statusCode, struct {
+		Error  string `json:"error"`
+		Detail string `json:"detail"`
+	}{
+		Error:  errMsg,
+		Detail: detail,
+	})
+}
+
+func sendJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(data) // any error here entitles a client side disconnect or similar, which we do not care about.
+}
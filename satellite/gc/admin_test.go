@@ -0,0 +1,181 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/rpc"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+)
+
+// newTestRunService returns a service whose runs block until a value is
+// sent on release.
+func newTestRunService(ctx *testcontext.Context, t *testing.T) (_ *Service, release chan struct{}) {
+	service := NewService(zaptest.NewLogger(t), Config{Enabled: true, Interval: time.Hour}, rpc.Dialer{}, nil, nil, nil)
+
+	release = make(chan struct{})
+	ctx.Go(func() error {
+		return service.Loop.Run(ctx, func(ctx context.Context) error {
+			run := service.startRun()
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			service.finishRun(run, RunStats{Segments: run.info.ID}, nil)
+			return nil
+		})
+	})
+	t.Cleanup(service.Loop.Close)
+
+	return service, release
+}
+
+func TestTrigger(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	_, _, err := NewService(zaptest.NewLogger(t), Config{}, rpc.Dialer{}, nil, nil, nil).Trigger()
+	require.Error(t, err)
+
+	service, release := newTestRunService(ctx, t)
+
+	// the first run starts immediately and triggering joins it
+	require.Eventually(t, func() bool {
+		info, ok := service.LookupRun(1)
+		return ok && !info.Started.IsZero()
+	}, 10*time.Second, time.Millisecond)
+
+	id, done, err := service.Trigger()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), id)
+
+	release <- struct{}{}
+	<-done
+
+	info, ok := service.LookupRun(1)
+	require.True(t, ok)
+	require.False(t, info.Finished.IsZero())
+	require.Equal(t, int64(1), info.Stats.Segments)
+
+	// concurrent triggers start a single run
+	first, firstDone, err := service.Trigger()
+	require.NoError(t, err)
+	second, secondDone, err := service.Trigger()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), first)
+	require.Equal(t, first, second)
+
+	release <- struct{}{}
+	<-firstDone
+	<-secondDone
+
+	info, ok = service.LookupRun(2)
+	require.True(t, ok)
+	require.Equal(t, int64(2), info.Stats.Segments)
+
+	_, ok = service.LookupRun(3)
+	require.False(t, ok)
+}
+
+func TestTriggerExcluding(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	service, release := newTestRunService(ctx, t)
+	require.Eventually(t, func() bool {
+		info, ok := service.LookupRun(1)
+		return ok && !info.Started.IsZero()
+	}, 10*time.Second, time.Millisecond)
+
+	// nodes can't be excluded from a run that already started.
+	_, _, err := service.TriggerExcluding(NodeIDs{testrand.NodeID()})
+	require.Error(t, err)
+
+	release <- struct{}{}
+	require.Eventually(t, func() bool {
+		info, ok := service.LookupRun(1)
+		return ok && !info.Finished.IsZero()
+	}, 10*time.Second, time.Millisecond)
+
+	excluded := testrand.NodeID()
+	id, done, err := service.TriggerExcluding(NodeIDs{excluded})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), id)
+
+	require.Eventually(t, func() bool {
+		service.runs.mu.Lock()
+		defer service.runs.mu.Unlock()
+		run := service.runs.active
+		return run != nil && run.info.ID == id
+	}, 10*time.Second, time.Millisecond)
+	service.runs.mu.Lock()
+	require.Contains(t, service.runs.active.excluded, excluded)
+	service.runs.mu.Unlock()
+
+	release <- struct{}{}
+	<-done
+}
+
+func TestAdminServer(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	service, release := newTestRunService(ctx, t)
+	server := httptest.NewServer(NewAdminServer(zaptest.NewLogger(t), nil, service, "secret").Handler())
+	defer server.Close()
+
+	request := func(method, path, token string) (status int, info RunInfo) {
+		req, err := http.NewRequestWithContext(ctx, method, server.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", token)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer ctx.Check(resp.Body.Close)
+
+		if resp.StatusCode < 300 {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+		}
+		return resp.StatusCode, info
+	}
+
+	status, _ := request("POST", "/api/gc/runs", "")
+	require.Equal(t, http.StatusForbidden, status)
+
+	// finish the run that is started when the loop starts
+	release <- struct{}{}
+
+	status, info := request("POST", "/api/gc/runs?async=true", "secret")
+	require.Equal(t, http.StatusAccepted, status)
+	require.True(t, info.Finished.IsZero())
+	id := info.ID
+
+	release <- struct{}{}
+	require.Eventually(t, func() bool {
+		status, info := request("GET", "/api/gc/runs/"+strconv.FormatInt(id, 10), "secret")
+		return status == http.StatusOK && !info.Finished.IsZero()
+	}, 10*time.Second, time.Millisecond)
+
+	go func() { release <- struct{}{} }()
+	status, info = request("POST", "/api/gc/runs", "secret")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, id+1, info.ID)
+	require.False(t, info.Finished.IsZero())
+
+	status, _ = request("POST", "/api/gc/runs?exclude=invalid", "secret")
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = request("GET", "/api/gc/runs/100", "secret")
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = request("GET", "/api/gc/runs/abc", "secret")
+	require.Equal(t, http.StatusBadRequest, status)
+}
@@ -0,0 +1,177 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/storj/private/retainfilter"
+	"storj.io/storj/satellite"
+	"storj.io/storj/satellite/gc"
+	"storj.io/storj/satellite/satellitedb/satellitedbtest"
+)
+
+func TestHistoryDB(t *testing.T) {
+	satellitedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db satellite.DB) {
+		history := db.GarbageCollectionRuns()
+
+		_, err := history.LatestGCRun(ctx)
+		require.True(t, gc.ErrNoRuns.Has(err))
+
+		started := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+		first, err := history.StartGCRun(ctx, started)
+		require.NoError(t, err)
+
+		latest, err := history.LatestGCRun(ctx)
+		require.NoError(t, err)
+		require.Equal(t, first, latest.ID)
+		require.Equal(t, gc.RunStatusRunning, latest.Status)
+		require.True(t, latest.Started.Equal(started))
+		require.True(t, latest.Finished.IsZero())
+
+		failed := gc.RunRecord{
+			ID:       first,
+			Started:  started,
+			Finished: started.Add(time.Minute),
+			Status:   gc.RunStatusFailed,
+			Segments: 10,
+			Error:    "gc: error generating retain filters",
+		}
+		require.NoError(t, history.FinishGCRun(ctx, failed))
+
+		latest, err = history.LatestGCRun(ctx)
+		require.NoError(t, err)
+		require.Equal(t, gc.RunStatusFailed, latest.Status)
+		require.Equal(t, failed.Error, latest.Error)
+		require.Equal(t, failed.Segments, latest.Segments)
+		require.True(t, latest.Finished.Equal(failed.Finished))
+
+		second, err := history.StartGCRun(ctx, started.Add(30*time.Minute))
+		require.NoError(t, err)
+		succeeded := gc.RunRecord{
+			ID:            second,
+			Started:       started.Add(30 * time.Minute),
+			Finished:      started.Add(40 * time.Minute),
+			Status:        gc.RunStatusSucceeded,
+			Segments:      100,
+			NodesTargeted: 5,
+			NodesSent:     4,
+			FilterBytes:   1024,
+		}
+		require.NoError(t, history.FinishGCRun(ctx, succeeded))
+
+		latest, err = history.LatestGCRun(ctx)
+		require.NoError(t, err)
+		require.Equal(t, second, latest.ID)
+		require.Equal(t, gc.RunStatusSucceeded, latest.Status)
+		require.Empty(t, latest.Error)
+		require.Equal(t, succeeded.NodesTargeted, latest.NodesTargeted)
+		require.Equal(t, succeeded.NodesSent, latest.NodesSent)
+		require.Equal(t, succeeded.FilterBytes, latest.FilterBytes)
+
+		require.Error(t, history.FinishGCRun(ctx, gc.RunRecord{ID: second + 100}))
+	})
+}
+
+func TestHistoryDBReports(t *testing.T) {
+	satellitedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db satellite.DB) {
+		history := db.GarbageCollectionRuns()
+
+		started := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+		creationDate := started.Add(-time.Minute)
+		id, err := history.StartGCRun(ctx, started)
+		require.NoError(t, err)
+
+		first, second := testrand.NodeID(), testrand.NodeID()
+		reports := []gc.RetainReport{
+			{NodeID: first, CreationDate: creationDate, PiecesExamined: 100, PiecesTrashed: 10, BytesReclaimed: 1000},
+			{NodeID: second, CreationDate: creationDate, Range: retainfilter.Range{Bits: 1, Index: 0}, PiecesExamined: 50, PiecesTrashed: 5, BytesReclaimed: 500},
+			{NodeID: second, CreationDate: creationDate, Range: retainfilter.Range{Bits: 1, Index: 1}, PiecesExamined: 60, PiecesTrashed: 6, BytesReclaimed: 600},
+			// reports of another run are not counted.
+			{NodeID: first, CreationDate: creationDate.Add(-time.Hour), PiecesExamined: 1, PiecesTrashed: 1, BytesReclaimed: 1},
+		}
+		for _, report := range reports {
+			report.Duration = time.Second
+			report.Reported = time.Now()
+			recorded, err := history.RecordRetainReport(ctx, report)
+			require.NoError(t, err)
+			require.True(t, recorded)
+		}
+
+		// a node that reports the same request again is only counted once.
+		recorded, err := history.RecordRetainReport(ctx, reports[0])
+		require.NoError(t, err)
+		require.False(t, recorded)
+
+		// the reports are only matched once the run recorded its creation date,
+		// but they may arrive before that.
+		latest, err := history.LatestGCRun(ctx)
+		require.NoError(t, err)
+		require.Zero(t, latest.Reports)
+
+		require.NoError(t, history.FinishGCRun(ctx, gc.RunRecord{
+			ID:             id,
+			Started:        started,
+			Finished:       started.Add(time.Minute),
+			Status:         gc.RunStatusSucceeded,
+			NodesSent:      3,
+			CreationDate:   creationDate,
+			NodesReporting: 2,
+		}))
+
+		latest, err = history.LatestGCRun(ctx)
+		require.NoError(t, err)
+		require.True(t, latest.CreationDate.Equal(creationDate))
+		require.Equal(t, 2, latest.NodesReporting)
+		require.Equal(t, gc.ReportTotals{
+			Nodes:          2,
+			PiecesExamined: 210,
+			PiecesTrashed:  21,
+			BytesReclaimed: 2100,
+		}, latest.Reports)
+	})
+}
+
+func TestHistoryDBSentFilters(t *testing.T) {
+	satellitedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db satellite.DB) {
+		history := db.GarbageCollectionRuns()
+
+		creationDate := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+		nodeID := testrand.NodeID()
+
+		_, err := history.GetSentFilter(ctx, nodeID, creationDate)
+		require.True(t, gc.ErrFilterNotSent.Has(err), err)
+
+		sent := gc.SentFilter{
+			NodeID:       nodeID,
+			CreationDate: creationDate,
+			RangeBits:    2,
+			Sent:         time.Now().Truncate(time.Microsecond),
+		}
+		require.NoError(t, history.RecordSentFilter(ctx, sent))
+
+		// sending the filter again, e.g. after a restart, replaces the record.
+		sent.RangeBits = 0
+		sent.Sent = sent.Sent.Add(time.Minute)
+		require.NoError(t, history.RecordSentFilter(ctx, sent))
+
+		got, err := history.GetSentFilter(ctx, nodeID, creationDate)
+		require.NoError(t, err)
+		require.Equal(t, sent.NodeID, got.NodeID)
+		require.True(t, sent.CreationDate.Equal(got.CreationDate))
+		require.Equal(t, sent.RangeBits, got.RangeBits)
+		require.True(t, sent.Sent.Equal(got.Sent))
+
+		// the filters of other runs and nodes are separate.
+		_, err = history.GetSentFilter(ctx, nodeID, creationDate.Add(-time.Hour))
+		require.True(t, gc.ErrFilterNotSent.Has(err), err)
+		_, err = history.GetSentFilter(ctx, testrand.NodeID(), creationDate)
+		require.True(t, gc.ErrFilterNotSent.Has(err), err)
+	})
+}
@@ -0,0 +1,60 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"github.com/spacemonkeygo/monkit/v3"
+	"go.uber.org/zap"
+
+	"storj.io/common/storj"
+)
+
+// expectedDeletions returns how many of the pieces that were counted for a
+// node in the previous iteration are not counted anymore, and which ratio of
+// the previous pieces that is. These pieces are expected to be deleted by the
+// filter, assuming the node still stores them.
+func expectedDeletions(previous, current int) (deletions int64, ratio float64) {
+	if previous <= current {
+		return 0, 0
+	}
+	deletions = int64(previous - current)
+	return deletions, float64(deletions) / float64(previous)
+}
+
+// checkDeletions estimates how many pieces the filters would delete and
+// returns the nodes whose filters would delete more than the maximum deletion
+// ratio. Such filters are likely caused by wrong accounting rather than by
+// garbage, so they need a manual review before being sent.
+func (service *Service) checkDeletions(infos map[storj.NodeID]*RetainInfo, lastPieceCounts map[storj.NodeID]int, stats *RunStats) (review []storj.NodeID) {
+	for id, info := range infos {
+		deletions, ratio := expectedDeletions(lastPieceCounts[id], info.Count)
+		stats.EstimatedDeletions += deletions
+		mon.IntVal("node_expected_deletions", monkit.NewSeriesTag("node_id", id.String())).Observe(deletions)
+
+		if service.config.DryRun {
+			service.log.Debug("DRY RUN: retain filter for node",
+				zap.Stringer("Node ID", id),
+				zap.Int("pieces", info.Count),
+				zap.Int64("filter bytes", info.Size()),
+				zap.Int64("estimated deletions", deletions))
+		}
+
+		switch {
+		case service.config.MaxDeletionRatio > 0 && ratio > service.config.MaxDeletionRatio:
+			service.log.Warn("retain filter would delete too many pieces, not sending it until reviewed",
+				zap.Stringer("Node ID", id),
+				zap.Int("previous pieces", lastPieceCounts[id]),
+				zap.Int("pieces", info.Count),
+				zap.Float64("deletion ratio", ratio))
+			review = append(review, id)
+		case service.config.DeletionRatioWarning > 0 && ratio > service.config.DeletionRatioWarning:
+			service.log.Warn("retain filter would delete many pieces",
+				zap.Stringer("Node ID", id),
+				zap.Int("previous pieces", lastPieceCounts[id]),
+				zap.Int("pieces", info.Count),
+				zap.Float64("deletion ratio", ratio))
+		}
+	}
+	return review
+}
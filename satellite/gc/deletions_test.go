@@ -0,0 +1,69 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/rpc"
+	"storj.io/common/storj"
+	"storj.io/common/testrand"
+)
+
+func TestExpectedDeletions(t *testing.T) {
+	for _, tt := range []struct {
+		previous, current int
+		deletions         int64
+		ratio             float64
+	}{
+		{previous: 0, current: 0},
+		{previous: 0, current: 100},
+		{previous: 100, current: 150},
+		{previous: 100, current: 100},
+		{previous: 100, current: 70, deletions: 30, ratio: 0.3},
+		{previous: 5000000, current: 1000000, deletions: 4000000, ratio: 0.8},
+	} {
+		deletions, ratio := expectedDeletions(tt.previous, tt.current)
+		require.Equal(t, tt.deletions, deletions, tt)
+		require.InDelta(t, tt.ratio, ratio, 1e-9, tt)
+	}
+}
+
+func TestCheckDeletions(t *testing.T) {
+	config := Config{
+		InitialPieces:        10,
+		FalsePositiveRate:    0.1,
+		DeletionRatioWarning: 0.3,
+		MaxDeletionRatio:     0.5,
+	}
+
+	infos := map[storj.NodeID]*RetainInfo{}
+	lastPieceCounts := map[storj.NodeID]int{}
+	addNode := func(previous, current int) storj.NodeID {
+		id := testrand.NodeID()
+		infos[id] = newRetainInfo(config, current)
+		infos[id].Count = current
+		lastPieceCounts[id] = previous
+		return id
+	}
+
+	addNode(100, 100)
+	addNode(100, 60)
+	suspicious := addNode(5000000, 1000000)
+	addNode(0, 100)
+
+	var stats RunStats
+	service := NewService(zaptest.NewLogger(t), config, rpc.Dialer{}, nil, nil, nil)
+	review := service.checkDeletions(infos, lastPieceCounts, &stats)
+	require.Equal(t, []storj.NodeID{suspicious}, review)
+	require.Equal(t, int64(40+4000000), stats.EstimatedDeletions)
+
+	// without a maximum ratio every filter is sent.
+	config.MaxDeletionRatio = 0
+	service = NewService(zaptest.NewLogger(t), config, rpc.Dialer{}, nil, nil, nil)
+	require.Empty(t, service.checkDeletions(infos, lastPieceCounts, &RunStats{}))
+}
@@ -11,10 +11,68 @@ in the metabase db.
 The gc.PieceTracker handling functions are used by the gc.Service to periodically
 account for all existing pieces on storage nodes and create "retain requests"
 which contain a bloom filter of all pieces that possibly exist on a storage node.
+Inline segments have no pieces on storage nodes, and the pieces of server-side
+copies, which share the root piece ID of their ancestor, are only counted once.
 
 The gc.Service will send that request to the storagenode after a full segments loop
 iteration, and the storage node will use that request to delete the "garbage" pieces
-that are not in the bloom filter.
+that are not in the bloom filter. The gc.Sender retries sending to nodes that
+could not be reached with exponential backoff until a retry window passes.
+Nodes that were sent a filter the longest time ago are sent theirs first.
+When the satellite shuts down while sending, the retain requests in flight get
+a grace period to finish, and the nodes that were not sent their filter are
+logged.
+
+Filters of nodes with so many pieces that they would exceed the maximum filter
+size can be split by the first bits of the piece IDs, see package retainfilter.
+Every part is then sent in a separate retain request to nodes that are new
+enough to apply it to its range of pieces, and the union of the parts to others.
+
+Before any filter is sent, a random sample of the pieces seen during the
+iteration is checked to be retained by the filters. A missing piece means the
+filters are broken, and the run fails instead of sending them.
+
+The number of pieces every filter is expected to delete is estimated from the
+piece counts of the previous iteration. Nodes whose filter would delete an
+unusually large ratio of their pieces are logged, and their filters can be held
+back for a manual review, since wrong accounting is more likely than that much
+garbage.
+
+Generating and sending filters can be split between separate processes by
+setting a persist directory: the generated filters are saved in a gc.Store
+and each filter is removed from it once it has been sent, so a restart
+between the two phases does not lose or resend completed work.
+
+The filters can be generated from a snapshot of the metabase, e.g. a restored
+backup or a follower, to keep the load off the metabase. They are then created
+as of the time of the snapshot, so that nodes keep the pieces uploaded since.
+Alternatively the segments can be processed at a limited rate, which spreads
+the load of the iteration over a longer run.
+
+Nodes can be excluded from being sent filters, e.g. while their storage is
+under maintenance, in the configuration or when triggering a run. Their filters
+stay in the gc.Store to be sent in a later run.
+
+Garbage collection can also be run for a list of target nodes only, e.g. to
+clean up after an incident on a few nodes. Only the filters of the target nodes
+are then created and sent, and the persisted filters of other nodes are kept.
+
+Runs can be started manually through the gc.AdminServer, which is served when
+an admin address is configured for garbage collection. It also serves the
+gc.Status of the service for health checks. Every run is recorded in the
+gc.HistoryDB when it starts and updated with its outcome when it ends.
+
+Storage nodes report how many pieces they examined and moved to the trash for
+every retain request they processed to the gc.Endpoint. Only nodes running at
+least the configured version are asked to report, with a flag in the encoded
+filter, and the filters sent to them are recorded. A report is only accepted
+once, and only for a filter that was sent to the node. The reports are recorded
+per node and filter, and summed up for the run that sent the filters.
+
+With RequireTrash, every filter also asks the node to only move the pieces that
+are not retained to the trash, so that they are only purged once the trash
+expires, and the reports are the acknowledgements of it. Nodes that are too old
+to be asked are skipped.
 
 See storj/docs/design/garbage-collection.md for more info.
 */
@@ -5,13 +5,21 @@ package gc_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/btcsuite/btcutil/base58"
+	"github.com/spacemonkeygo/monkit/v3"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"golang.org/x/sync/errgroup"
 
 	"storj.io/common/encryption"
 	"storj.io/common/memory"
@@ -20,12 +28,17 @@ import (
 	"storj.io/common/storj"
 	"storj.io/common/testcontext"
 	"storj.io/common/testrand"
+	"storj.io/storj/private/retainfilter"
 	"storj.io/storj/private/testplanet"
 	"storj.io/storj/satellite"
 	"storj.io/storj/satellite/gc"
 	"storj.io/storj/satellite/metabase"
+	"storj.io/storj/satellite/metabase/segmentloop"
+	"storj.io/storj/satellite/overlay"
 	"storj.io/storj/storage"
 	"storj.io/storj/storagenode"
+	"storj.io/storj/storagenode/retain"
+	"storj.io/uplink/private/piecestore"
 	"storj.io/uplink/private/testuplink"
 )
 
@@ -42,7 +55,7 @@ func TestGarbageCollection(t *testing.T) {
 		Reconfigure: testplanet.Reconfigure{
 			Satellite: func(log *zap.Logger, index int, config *satellite.Config) {
 				config.GarbageCollection.FalsePositiveRate = 0.000000001
-				config.GarbageCollection.Interval = 500 * time.Millisecond
+				config.GarbageCollection.Interval = time.Second
 			},
 			StorageNode: func(index int, config *storagenode.Config) {
 				config.Retain.MaxTimeSkew = 0
@@ -86,10 +99,15 @@ func TestGarbageCollection(t *testing.T) {
 		require.NotZero(t, keptPieceID)
 
 		// Delete one object from metainfo service on satellite
-		_, err = satellite.Metabase.DB.DeleteObjectsAllVersions(ctx, metabase.DeleteObjectsAllVersions{
+		result, err := satellite.Metabase.DB.DeleteObjectsAllVersions(ctx, metabase.DeleteObjectsAllVersions{
 			Locations: []metabase.ObjectLocation{objectLocationToDelete},
 		})
 		require.NoError(t, err)
+		require.Len(t, result.Deleted, 1)
+		require.Contains(t, result.Deleted[0].Pieces, metabase.DeletedPiece{
+			StorageNode: targetNode.ID(),
+			PieceID:     deletedPieceID,
+		})
 
 		// Check that piece of the deleted object is on the storagenode
 		pieceAccess, err := targetNode.DB.Pieces().Stat(ctx, storage.BlobRef{
@@ -106,25 +124,769 @@ func TestGarbageCollection(t *testing.T) {
 		// for a second.
 		time.Sleep(1 * time.Second)
 
+		summaries := make(chan retain.Summary, 1)
+		unsubscribe := targetNode.Storage2.RetainService.Subscribe(func(summary retain.Summary) {
+			select {
+			case summaries <- summary:
+			default:
+			}
+		})
+		defer unsubscribe()
+
 		// Wait for next iteration of garbage collection to finish
 		gcService.Loop.Restart()
 		gcService.Loop.TriggerWait()
 
-		// Wait for the storagenode's RetainService queue to be empty
-		targetNode.Storage2.RetainService.TestWaitUntilEmpty()
+		// Wait for the storagenode to process the retain request
+		var summary retain.Summary
+		select {
+		case summary = <-summaries:
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+		require.NoError(t, summary.Err)
+		require.Equal(t, satellite.ID(), summary.SatelliteID)
+		require.EqualValues(t, 1, summary.PiecesDeleted)
+		require.NotZero(t, summary.BytesDeleted)
+
+		// Check that piece of the deleted object is not on the storagenode,
+		// and that piece of the kept object is.
+		results, err := targetNode.Storage2.Store.StatMany(ctx, []storage.BlobRef{
+			{Namespace: satellite.ID().Bytes(), Key: deletedPieceID.Bytes()},
+			{Namespace: satellite.ID().Bytes(), Key: keptPieceID.Bytes()},
+		})
+		require.NoError(t, err)
+		require.True(t, results[0].NotFound)
+		require.False(t, results[1].NotFound)
+
+		// Check the metrics of the run, only the kept object is left
+		stats := monkit.Collect(monkit.ScopeNamed("storj.io/storj/satellite/gc"))
+		require.Equal(t, float64(1), stats["segments,scope=storj.io/storj/satellite/gc recent"])
+		require.Equal(t, float64(1), stats["pieces,scope=storj.io/storj/satellite/gc recent"])
+		require.Equal(t, float64(1), stats["nodes_with_filters,scope=storj.io/storj/satellite/gc recent"])
+		require.NotZero(t, stats["retain_filters_total_bytes,scope=storj.io/storj/satellite/gc recent"])
+		require.NotZero(t, stats["retain_send_duration,outcome=success,scope=storj.io/storj/satellite/gc count"])
+		require.NotZero(t, stats["generate_duration,scope=storj.io/storj/satellite/gc count"])
+		require.NotZero(t, stats["send_duration,scope=storj.io/storj/satellite/gc count"])
+	})
+}
+
+// TestGarbageCollection_Reports checks that the storage node reports the
+// result of the retain request and that it is added to the run.
+func TestGarbageCollection_Reports(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 1, UplinkCount: 1,
+		Reconfigure: testplanet.Reconfigure{
+			Satellite: func(log *zap.Logger, index int, config *satellite.Config) {
+				config.GarbageCollection.FalsePositiveRate = 0.000000001
+				config.GarbageCollection.Interval = time.Second
+				config.GarbageCollection.ReportMinimumVersion = "v0.0.1"
+			},
+			StorageNode: func(index int, config *storagenode.Config) {
+				config.Retain.MaxTimeSkew = 0
+			},
+		},
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		upl := planet.Uplinks[0]
+		targetNode := planet.StorageNodes[0]
+		gcService := satellite.GarbageCollection.Service
+		gcService.Loop.Pause()
+
+		uploadAndDeleteObject(ctx, t, satellite, upl, "test/path/1")
+
+		summaries := make(chan retain.Summary, 1)
+		unsubscribe := targetNode.Storage2.RetainService.Subscribe(func(summary retain.Summary) {
+			select {
+			case summaries <- summary:
+			default:
+			}
+		})
+		defer unsubscribe()
+
+		gcService.Loop.Restart()
+		gcService.Loop.TriggerWait()
+
+		var summary retain.Summary
+		select {
+		case summary = <-summaries:
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+		require.NoError(t, summary.Err)
+		require.EqualValues(t, 1, summary.PiecesDeleted)
+
+		// the report is sent in the background after the request is processed.
+		var record gc.RunRecord
+		require.Eventually(t, func() bool {
+			var err error
+			record, err = satellite.DB.GarbageCollectionRuns().LatestGCRun(ctx)
+			require.NoError(t, err)
+			return record.Reports.Nodes == 1
+		}, 10*time.Second, 50*time.Millisecond)
+
+		require.Equal(t, 1, record.NodesReporting)
+		// the database keeps microseconds only.
+		require.WithinDuration(t, summary.CreatedBefore, record.CreationDate, time.Millisecond)
+		require.Equal(t, summary.PiecesWalked, record.Reports.PiecesExamined)
+		require.Equal(t, summary.PiecesDeleted, record.Reports.PiecesTrashed)
+		require.Equal(t, summary.BytesDeleted, record.Reports.BytesReclaimed)
+	})
+}
+
+// TestGarbageCollection_RequireTrash checks that with trash required the
+// storage node only moves the piece of the deleted object to the trash, and
+// that the acknowledged counts match the pieces expected to be deleted.
+func TestGarbageCollection_RequireTrash(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 1, UplinkCount: 1,
+		Reconfigure: testplanet.Reconfigure{
+			Satellite: func(log *zap.Logger, index int, config *satellite.Config) {
+				config.GarbageCollection.FalsePositiveRate = 0.000000001
+				config.GarbageCollection.Interval = time.Second
+				config.GarbageCollection.ReportMinimumVersion = "v0.0.1"
+				config.GarbageCollection.RequireTrash = true
+			},
+			StorageNode: func(index int, config *storagenode.Config) {
+				config.Retain.MaxTimeSkew = 0
+			},
+		},
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		upl := planet.Uplinks[0]
+		targetNode := planet.StorageNodes[0]
+		gcService := satellite.GarbageCollection.Service
+		gcService.Loop.Pause()
+
+		err := upl.Upload(ctx, satellite, "testbucket", "test/path/2", testrand.Bytes(8*memory.KiB))
+		require.NoError(t, err)
+		_, deletedPieceIDs := uploadAndDeleteObject(ctx, t, satellite, upl, "test/path/1")
+
+		summaries := make(chan retain.Summary, 1)
+		unsubscribe := targetNode.Storage2.RetainService.Subscribe(func(summary retain.Summary) {
+			select {
+			case summaries <- summary:
+			default:
+			}
+		})
+		defer unsubscribe()
+
+		gcService.Loop.Restart()
+		gcService.Loop.TriggerWait()
+
+		var summary retain.Summary
+		select {
+		case summary = <-summaries:
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+		require.NoError(t, summary.Err)
+		require.Equal(t, retainfilter.FlagReport|retainfilter.FlagRequireTrash, summary.Flags)
+		require.EqualValues(t, 1, summary.PiecesDeleted)
+
+		// the acknowledgement is reported in the background.
+		var record gc.RunRecord
+		require.Eventually(t, func() bool {
+			record, err = satellite.DB.GarbageCollectionRuns().LatestGCRun(ctx)
+			require.NoError(t, err)
+			return record.Reports.Nodes == 1
+		}, 10*time.Second, 50*time.Millisecond)
+
+		require.Equal(t, 1, record.NodesSent)
+		require.Equal(t, 1, record.NodesReporting)
+		require.Equal(t, summary.PiecesWalked, record.Reports.PiecesExamined)
+		require.EqualValues(t, 1, record.Reports.PiecesTrashed)
+		require.Equal(t, summary.BytesDeleted, record.Reports.BytesReclaimed)
+
+		// the piece was only moved to the trash, so it can be restored.
+		pieceRef := storage.BlobRef{Namespace: satellite.ID().Bytes(), Key: deletedPieceIDs[targetNode.ID()].Bytes()}
+		results, err := targetNode.Storage2.Store.StatMany(ctx, []storage.BlobRef{pieceRef})
+		require.NoError(t, err)
+		require.True(t, results[0].NotFound)
+
+		require.NoError(t, targetNode.Storage2.Store.RestoreTrash(ctx, satellite.ID()))
+		results, err = targetNode.Storage2.Store.StatMany(ctx, []storage.BlobRef{pieceRef})
+		require.NoError(t, err)
+		require.False(t, results[0].NotFound)
+	})
+}
+
+// TestGarbageCollection_OfflineNode does the following:
+// * Set up a network with one storagenode
+// * Upload an object and delete it from the metainfo service on the satellite
+// * Make the storagenode unreachable and start garbage collection
+// * Make the storagenode reachable again by checking in with the satellite
+// * Check that the retried retain request deletes the piece on the storagenode.
+func TestGarbageCollection_OfflineNode(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 1, UplinkCount: 1,
+		Reconfigure: testplanet.Reconfigure{
+			Satellite: func(log *zap.Logger, index int, config *satellite.Config) {
+				config.GarbageCollection.FalsePositiveRate = 0.000000001
+				config.GarbageCollection.Interval = time.Second
+				config.GarbageCollection.RetryWindow = time.Minute
+				config.GarbageCollection.RetryBackoff = 100 * time.Millisecond
+				config.GarbageCollection.RetryMaxBackoff = 500 * time.Millisecond
+			},
+			StorageNode: func(index int, config *storagenode.Config) {
+				config.Retain.MaxTimeSkew = 0
+			},
+		},
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		upl := planet.Uplinks[0]
+		targetNode := planet.StorageNodes[0]
+		gcService := satellite.GarbageCollection.Service
+		gcService.Loop.Pause()
+		targetNode.Contact.Chore.Pause(ctx)
+
+		err := upl.Upload(ctx, satellite, "testbucket", "test/path/1", testrand.Bytes(8*memory.KiB))
+		require.NoError(t, err)
+
+		objectLocationToDelete, segmentToDelete := getSegment(ctx, t, satellite, upl, "testbucket", "test/path/1")
+
+		var deletedPieceID storj.PieceID
+		for _, p := range segmentToDelete.Pieces {
+			if p.StorageNode == targetNode.ID() {
+				deletedPieceID = segmentToDelete.RootPieceID.Derive(p.StorageNode, int32(p.Number))
+				break
+			}
+		}
+		require.NotZero(t, deletedPieceID)
+
+		_, err = satellite.Metabase.DB.DeleteObjectsAllVersions(ctx, metabase.DeleteObjectsAllVersions{
+			Locations: []metabase.ObjectLocation{objectLocationToDelete},
+		})
+		require.NoError(t, err)
+
+		// make the node unreachable for the satellite
+		err = satellite.Overlay.DB.UpdateCheckIn(ctx, overlay.NodeCheckInInfo{
+			NodeID:     targetNode.ID(),
+			Address:    &pb.NodeAddress{Address: "127.0.0.1:1"},
+			LastIPPort: "127.0.0.1:1",
+			IsUp:       true,
+			Version:    &pb.NodeVersion{Version: "v0.0.0"},
+		}, time.Now(), satellite.Config.Overlay.Node)
+		require.NoError(t, err)
+
+		// see TestGarbageCollection for why this is necessary.
+		time.Sleep(1 * time.Second)
+
+		gcService.Loop.Restart()
+		gcService.Loop.Trigger()
+
+		// give the first attempts time to fail
+		time.Sleep(1 * time.Second)
+
+		pieceAccess, err := targetNode.DB.Pieces().Stat(ctx, storage.BlobRef{
+			Namespace: satellite.ID().Bytes(),
+			Key:       deletedPieceID.Bytes(),
+		})
+		require.NoError(t, err)
+		require.NotNil(t, pieceAccess)
+
+		// the node comes back and checks in with its real address
+		require.NoError(t, targetNode.Contact.Service.PingSatellites(ctx, time.Second))
+
+		// wait for the retrying iteration to finish
+		gcService.Loop.TriggerWait()
+		require.NoError(t, targetNode.Storage2.RetainService.WaitIdle(ctx))
 
-		// Check that piece of the deleted object is not on the storagenode
 		pieceAccess, err = targetNode.DB.Pieces().Stat(ctx, storage.BlobRef{
 			Namespace: satellite.ID().Bytes(),
 			Key:       deletedPieceID.Bytes(),
 		})
 		require.Error(t, err)
 		require.Nil(t, pieceAccess)
+	})
+}
 
-		// Check that piece of the kept object is on the storagenode
-		pieceAccess, err = targetNode.DB.Pieces().Stat(ctx, storage.BlobRef{
+// TestGarbageCollection_DryRun does the same as TestGarbageCollection with
+// dry run enabled and checks that no pieces are deleted on the storagenode.
+func TestGarbageCollection_DryRun(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 1, UplinkCount: 1,
+		Reconfigure: testplanet.Reconfigure{
+			Satellite: func(log *zap.Logger, index int, config *satellite.Config) {
+				config.GarbageCollection.FalsePositiveRate = 0.000000001
+				config.GarbageCollection.Interval = time.Second
+				config.GarbageCollection.DryRun = true
+			},
+			StorageNode: func(index int, config *storagenode.Config) {
+				config.Retain.MaxTimeSkew = 0
+			},
+		},
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		upl := planet.Uplinks[0]
+		targetNode := planet.StorageNodes[0]
+		gcService := satellite.GarbageCollection.Service
+		gcService.Loop.Pause()
+
+		err := upl.Upload(ctx, satellite, "testbucket", "test/path/2", testrand.Bytes(8*memory.KiB))
+		require.NoError(t, err)
+		_, deletedPieceIDs := uploadAndDeleteObject(ctx, t, satellite, upl, "test/path/1")
+
+		gcService.Loop.Restart()
+		gcService.Loop.TriggerWait()
+		require.NoError(t, targetNode.Storage2.RetainService.WaitIdle(ctx))
+
+		// Check that piece of the deleted object is still on the storagenode
+		pieceAccess, err := targetNode.DB.Pieces().Stat(ctx, storage.BlobRef{
+			Namespace: satellite.ID().Bytes(),
+			Key:       deletedPieceIDs[targetNode.ID()].Bytes(),
+		})
+		require.NoError(t, err)
+		require.NotNil(t, pieceAccess)
+
+		// Check that the piece counts are updated for the next run
+		pieceCounts, err := satellite.Overlay.DB.AllPieceCounts(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, pieceCounts[targetNode.ID()])
+	})
+}
+
+// TestGarbageCollection_RetainPreview does the same as TestGarbageCollection,
+// but with the storage node previewing retain requests.
+func TestGarbageCollection_RetainPreview(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 1, UplinkCount: 1,
+		Reconfigure: testplanet.Reconfigure{
+			Satellite: func(log *zap.Logger, index int, config *satellite.Config) {
+				config.GarbageCollection.FalsePositiveRate = 0.000000001
+				config.GarbageCollection.Interval = time.Second
+			},
+			StorageNode: func(index int, config *storagenode.Config) {
+				config.Retain.MaxTimeSkew = 0
+				config.Retain.Status = retain.Preview
+			},
+		},
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		upl := planet.Uplinks[0]
+		targetNode := planet.StorageNodes[0]
+		gcService := satellite.GarbageCollection.Service
+		gcService.Loop.Pause()
+
+		err := upl.Upload(ctx, satellite, "testbucket", "test/path/2", testrand.Bytes(8*memory.KiB))
+		require.NoError(t, err)
+		_, deletedPieceIDs := uploadAndDeleteObject(ctx, t, satellite, upl, "test/path/1")
+
+		gcService.Loop.Restart()
+		gcService.Loop.TriggerWait()
+		require.NoError(t, targetNode.Storage2.RetainService.WaitIdle(ctx))
+
+		// Check that piece of the deleted object is still on the storagenode
+		pieceAccess, err := targetNode.DB.Pieces().Stat(ctx, storage.BlobRef{
+			Namespace: satellite.ID().Bytes(),
+			Key:       deletedPieceIDs[targetNode.ID()].Bytes(),
+		})
+		require.NoError(t, err)
+		require.NotNil(t, pieceAccess)
+
+		// Check that nothing was moved to the trash
+		trashed, err := targetNode.Storage2.Store.SpaceUsedForTrash(ctx)
+		require.NoError(t, err)
+		require.Zero(t, trashed)
+	})
+}
+
+// TestGarbageCollection_Trigger does the same as TestGarbageCollection, but
+// runs garbage collection through the admin endpoint instead of waiting.
+func TestGarbageCollection_Trigger(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 1, UplinkCount: 1,
+		Reconfigure: testplanet.Reconfigure{
+			Satellite: func(log *zap.Logger, index int, config *satellite.Config) {
+				config.GarbageCollection.FalsePositiveRate = 0.000000001
+				config.GarbageCollection.Interval = time.Hour
+			},
+			StorageNode: func(index int, config *storagenode.Config) {
+				config.Retain.MaxTimeSkew = 0
+			},
+		},
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		upl := planet.Uplinks[0]
+		targetNode := planet.StorageNodes[0]
+		gcService := satellite.GarbageCollection.Service
+
+		admin := httptest.NewServer(gc.NewAdminServer(zaptest.NewLogger(t), nil, gcService, "secret").Handler())
+		defer admin.Close()
+
+		_, deletedPieceIDs := uploadAndDeleteObject(ctx, t, satellite, upl, "test/path/1")
+
+		req, err := http.NewRequestWithContext(ctx, "POST", admin.URL+"/api/gc/runs", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer ctx.Check(resp.Body.Close)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var info gc.RunInfo
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+		require.NotZero(t, info.ID)
+		require.False(t, info.Finished.IsZero())
+		require.Empty(t, info.Error)
+		require.Equal(t, 1, info.Stats.Sent.Sent)
+
+		require.NoError(t, targetNode.Storage2.RetainService.WaitIdle(ctx))
+
+		pieceAccess, err := targetNode.DB.Pieces().Stat(ctx, storage.BlobRef{
+			Namespace: satellite.ID().Bytes(),
+			Key:       deletedPieceIDs[targetNode.ID()].Bytes(),
+		})
+		require.Error(t, err)
+		require.Nil(t, pieceAccess)
+	})
+}
+
+// TestGarbageCollection_TargetNodes does the following:
+// * Set up a network with two storagenodes
+// * Upload an object and delete it from the metainfo service on the satellite
+// * Run garbage collection for only one of the storagenodes
+// * Check that only the targeted storagenode deletes its piece.
+func TestGarbageCollection_TargetNodes(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 2, UplinkCount: 1,
+		Reconfigure: testplanet.Reconfigure{
+			Satellite: func(log *zap.Logger, index int, config *satellite.Config) {
+				config.Metainfo.RS.Min = 1
+				config.Metainfo.RS.Repair = 1
+				config.Metainfo.RS.Success = 2
+				config.Metainfo.RS.Total = 2
+			},
+			StorageNode: func(index int, config *storagenode.Config) {
+				config.Retain.MaxTimeSkew = 0
+			},
+		},
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		upl := planet.Uplinks[0]
+		targetNode, otherNode := planet.StorageNodes[0], planet.StorageNodes[1]
+		satellite.GarbageCollection.Service.Loop.Pause()
+
+		_, deletedPieceIDs := uploadAndDeleteObject(ctx, t, satellite, upl, "test/path/1")
+		require.Contains(t, deletedPieceIDs, targetNode.ID())
+		require.Contains(t, deletedPieceIDs, otherNode.ID())
+
+		config := satellite.Config.GarbageCollection
+		config.Enabled = true
+		config.Interval = time.Hour
+		config.FalsePositiveRate = 0.000000001
+		config.TargetNodesOnly = true
+		config.TargetNodes = gc.NodeIDs{targetNode.ID()}
+
+		gcService := gc.NewService(zaptest.NewLogger(t), config,
+			satellite.Dialer, satellite.Overlay.DB, nil, satellite.Metabase.SegmentLoop)
+		gcService.Loop.Pause()
+		ctx.Go(func() error { return gcService.Run(ctx) })
+		defer gcService.Loop.Close()
+
+		gcService.Loop.Restart()
+		gcService.Loop.TriggerWait()
+
+		require.NoError(t, targetNode.Storage2.RetainService.WaitIdle(ctx))
+		require.NoError(t, otherNode.Storage2.RetainService.WaitIdle(ctx))
+
+		// only the targeted storagenode deleted its piece.
+		_, err := targetNode.DB.Pieces().Stat(ctx, storage.BlobRef{
+			Namespace: satellite.ID().Bytes(),
+			Key:       deletedPieceIDs[targetNode.ID()].Bytes(),
+		})
+		require.Error(t, err)
+
+		pieceAccess, err := otherNode.DB.Pieces().Stat(ctx, storage.BlobRef{
 			Namespace: satellite.ID().Bytes(),
-			Key:       keptPieceID.Bytes(),
+			Key:       deletedPieceIDs[otherNode.ID()].Bytes(),
+		})
+		require.NoError(t, err)
+		require.NotNil(t, pieceAccess)
+	})
+}
+
+// TestGarbageCollection_ConcurrentSends checks that filters are sent to the
+// reachable nodes and that a node that never responds times out instead of
+// stalling the sending.
+func TestGarbageCollection_ConcurrentSends(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 4, UplinkCount: 1,
+		Reconfigure: testplanet.Reconfigure{
+			Satellite: func(log *zap.Logger, index int, config *satellite.Config) {
+				config.Metainfo.RS.Min = 1
+				config.Metainfo.RS.Repair = 2
+				config.Metainfo.RS.Success = 4
+				config.Metainfo.RS.Total = 4
+			},
+		},
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		upl := planet.Uplinks[0]
+		satellite.GarbageCollection.Service.Loop.Pause()
+
+		err := upl.Upload(ctx, satellite, "testbucket", "test/path/1", testrand.Bytes(8*memory.KiB))
+		require.NoError(t, err)
+
+		// the listener accepts connections but never responds.
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ctx.Check(listener.Close)
+		ctx.Go(func() error {
+			var conns []net.Conn
+			defer func() {
+				for _, conn := range conns {
+					_ = conn.Close()
+				}
+			}()
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return nil
+				}
+				conns = append(conns, conn)
+			}
+		})
+
+		hungNode := planet.StorageNodes[0]
+		for _, node := range planet.StorageNodes {
+			node.Contact.Chore.Pause(ctx)
+		}
+		err = satellite.Overlay.DB.UpdateCheckIn(ctx, overlay.NodeCheckInInfo{
+			NodeID:     hungNode.ID(),
+			Address:    &pb.NodeAddress{Address: listener.Addr().String(), Transport: pb.NodeTransport_TCP_TLS_GRPC},
+			LastIPPort: listener.Addr().String(),
+			LastNet:    "127.0.0",
+			Version:    &pb.NodeVersion{Version: "v1.0.0"},
+			Capacity:   &pb.NodeCapacity{},
+			IsUp:       true,
+		}, time.Now(), overlay.NodeSelectionConfig{})
+		require.NoError(t, err)
+
+		config := satellite.Config.GarbageCollection
+		config.Enabled = true
+		config.Interval = time.Hour
+		config.ConcurrentSends = 4
+		config.RetainSendTimeout = 2 * time.Second
+		config.RetryWindow = 0
+
+		gcService := gc.NewService(zaptest.NewLogger(t), config,
+			satellite.Dialer, satellite.Overlay.DB, nil, satellite.Metabase.SegmentLoop)
+		ctx.Go(func() error { return gcService.Run(ctx) })
+		defer gcService.Loop.Close()
+
+		start := time.Now()
+		id, done, err := gcService.Trigger()
+		require.NoError(t, err)
+		select {
+		case <-done:
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+		require.Less(t, time.Since(start), 30*time.Second)
+
+		info, ok := gcService.LookupRun(id)
+		require.True(t, ok)
+		require.Empty(t, info.Error)
+		require.Equal(t, gc.SendSummary{Sent: 3, GaveUp: 1}, info.Stats.Sent)
+	})
+}
+
+// countingObserver counts the remote segments of a segment loop iteration.
+type countingObserver struct {
+	segmentloop.NullObserver
+	remote int
+}
+
+func (observer *countingObserver) RemoteSegment(context.Context, *segmentloop.Segment) error {
+	observer.remote++
+	return nil
+}
+
+// TestGarbageCollection_ObserverFailure checks that a piece tracker that
+// fails during the segment loop iteration does not disrupt the other
+// observers of the same iteration.
+func TestGarbageCollection_ObserverFailure(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 1, UplinkCount: 1,
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		upl := planet.Uplinks[0]
+
+		for i := 0; i < 3; i++ {
+			err := upl.Upload(ctx, satellite, "testbucket", "test/path/"+strconv.Itoa(i), testrand.Bytes(8*memory.KiB))
+			require.NoError(t, err)
+		}
+
+		// the piece tracker exceeds its memory limit on the first piece.
+		config := satellite.Config.GarbageCollection
+		config.MaxMemory = 1
+		failing := gc.NewPieceTracker(zaptest.NewLogger(t), config, map[storj.NodeID]int{})
+		healthy := &countingObserver{}
+
+		var group errgroup.Group
+		group.Go(func() error { return satellite.Metabase.SegmentLoop.Join(ctx, failing) })
+		group.Go(func() error { return satellite.Metabase.SegmentLoop.Join(ctx, healthy) })
+
+		err := group.Wait()
+		require.Error(t, err)
+		require.True(t, gc.ErrMemoryLimit.Has(err))
+		require.Nil(t, failing.RetainInfos)
+
+		// the healthy observer still saw every segment.
+		require.Equal(t, 3, healthy.remote)
+	})
+}
+
+// blockOnLoopStart blocks the segment loop iteration until it is released.
+type blockOnLoopStart struct {
+	segmentloop.NullObserver
+	started chan struct{}
+	release chan struct{}
+}
+
+func (observer *blockOnLoopStart) LoopStarted(ctx context.Context, info segmentloop.LoopInfo) error {
+	close(observer.started)
+	select {
+	case <-observer.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TestGarbageCollection_Status checks the status of the service before,
+// during and after a run.
+func TestGarbageCollection_Status(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 1, UplinkCount: 1,
+		Reconfigure: testplanet.Reconfigure{
+			Satellite: func(log *zap.Logger, index int, config *satellite.Config) {
+				config.GarbageCollection.Interval = time.Hour
+			},
+		},
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		upl := planet.Uplinks[0]
+		gcService := satellite.GarbageCollection.Service
+		gcService.Loop.Pause()
+
+		err := upl.Upload(ctx, satellite, "testbucket", "test/path/1", testrand.Bytes(8*memory.KiB))
+		require.NoError(t, err)
+
+		require.Equal(t, gc.PhaseIdle, gcService.Status().Phase)
+
+		// the observer holds the iteration that garbage collection joins.
+		blocker := &blockOnLoopStart{started: make(chan struct{}), release: make(chan struct{})}
+		ctx.Go(func() error { return satellite.Metabase.SegmentLoop.Join(ctx, blocker) })
+
+		gcService.Loop.Restart()
+		triggered := make(chan struct{})
+		go func() {
+			defer close(triggered)
+			gcService.Loop.TriggerWait()
+		}()
+
+		<-blocker.started
+		require.Eventually(t, func() bool {
+			return gcService.Status().Phase == gc.PhaseIterating
+		}, 10*time.Second, time.Millisecond)
+		status := gcService.Status()
+		require.False(t, status.Started.IsZero())
+		require.Zero(t, status.Segments)
+
+		close(blocker.release)
+		<-triggered
+
+		status = gcService.Status()
+		require.Equal(t, gc.PhaseIdle, status.Phase)
+		require.True(t, status.Started.IsZero())
+		require.NotNil(t, status.Previous)
+		require.Empty(t, status.Previous.Error)
+		require.Equal(t, int64(1), status.Previous.Stats.Segments)
+		require.Equal(t, 1, status.Previous.Stats.Sent.Sent)
+	})
+}
+
+// uploadOnLoopStart uploads an object once the segment loop iteration started.
+type uploadOnLoopStart struct {
+	*gc.PieceTracker
+	upload func(ctx context.Context) error
+}
+
+func (observer *uploadOnLoopStart) LoopStarted(ctx context.Context, info segmentloop.LoopInfo) error {
+	if err := observer.PieceTracker.LoopStarted(ctx, info); err != nil {
+		return err
+	}
+	return observer.upload(ctx)
+}
+
+// TestGarbageCollection_UploadDuringIteration checks that a piece that is
+// uploaded after the segment loop iteration started, and is therefore missing
+// from the filter, is retained by the storagenode.
+func TestGarbageCollection_UploadDuringIteration(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 1, UplinkCount: 1,
+		Reconfigure: testplanet.Reconfigure{
+			StorageNode: func(index int, config *storagenode.Config) {
+				config.Retain.MaxTimeSkew = 0
+			},
+		},
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		upl := planet.Uplinks[0]
+		targetNode := planet.StorageNodes[0]
+
+		err := upl.Upload(ctx, satellite, "testbucket", "test/path/1", testrand.Bytes(8*memory.KiB))
+		require.NoError(t, err)
+
+		// see TestGarbageCollection for why this is necessary.
+		time.Sleep(1 * time.Second)
+
+		config := satellite.Config.GarbageCollection
+		config.FalsePositiveRate = 0.000000001
+		pieceTracker := gc.NewPieceTracker(zaptest.NewLogger(t), config, map[storj.NodeID]int{})
+
+		var uploaded time.Time
+		err = satellite.Metabase.SegmentLoop.Join(ctx, &uploadOnLoopStart{
+			PieceTracker: pieceTracker,
+			upload: func(ctx context.Context) error {
+				uploaded = time.Now()
+				return upl.Upload(ctx, satellite, "testbucket", "test/path/2", testrand.Bytes(8*memory.KiB))
+			},
+		})
+		require.NoError(t, err)
+
+		_, segment := getSegment(ctx, t, satellite, upl, "testbucket", "test/path/2")
+		var uploadedPieceID storj.PieceID
+		for _, p := range segment.Pieces {
+			if p.StorageNode == targetNode.ID() {
+				uploadedPieceID = segment.RootPieceID.Derive(p.StorageNode, int32(p.Number))
+				break
+			}
+		}
+		require.NotZero(t, uploadedPieceID)
+
+		info, ok := pieceTracker.RetainInfos[targetNode.ID()]
+		require.True(t, ok)
+		require.Equal(t, 1, info.Count)
+		require.False(t, info.Filter.Contains(uploadedPieceID))
+		require.False(t, info.CreationDate.After(uploaded))
+
+		client, err := piecestore.Dial(ctx, satellite.Dialer, targetNode.NodeURL(), piecestore.DefaultConfig)
+		require.NoError(t, err)
+		defer ctx.Check(client.Close)
+
+		err = client.Retain(ctx, &pb.RetainRequest{
+			CreationDate: info.CreationDate,
+			Filter:       info.Filter.Bytes(),
+		})
+		require.NoError(t, err)
+		require.NoError(t, targetNode.Storage2.RetainService.WaitIdle(ctx))
+
+		pieceAccess, err := targetNode.DB.Pieces().Stat(ctx, storage.BlobRef{
+			Namespace: satellite.ID().Bytes(),
+			Key:       uploadedPieceID.Bytes(),
 		})
 		require.NoError(t, err)
 		require.NotNil(t, pieceAccess)
@@ -158,6 +920,30 @@ func getSegment(ctx *testcontext.Context, t *testing.T, satellite *testplanet.Sa
 	return objectLocation, lastSegment
 }
 
+// uploadAndDeleteObject uploads an object and deletes it from the metabase of
+// the satellite, so that the next garbage collection run deletes its pieces.
+// It returns the location of the object and the IDs of its pieces per node.
+func uploadAndDeleteObject(ctx *testcontext.Context, t *testing.T, satellite *testplanet.Satellite, upl *testplanet.Uplink, path string) (_ metabase.ObjectLocation, pieceIDs map[storj.NodeID]storj.PieceID) {
+	err := upl.Upload(ctx, satellite, "testbucket", path, testrand.Bytes(8*memory.KiB))
+	require.NoError(t, err)
+
+	location, segment := getSegment(ctx, t, satellite, upl, "testbucket", path)
+	pieceIDs = make(map[storj.NodeID]storj.PieceID, len(segment.Pieces))
+	for _, p := range segment.Pieces {
+		pieceIDs[p.StorageNode] = segment.RootPieceID.Derive(p.StorageNode, int32(p.Number))
+	}
+
+	_, err = satellite.Metabase.DB.DeleteObjectsAllVersions(ctx, metabase.DeleteObjectsAllVersions{
+		Locations: []metabase.ObjectLocation{location},
+	})
+	require.NoError(t, err)
+
+	// see TestGarbageCollection for why this is necessary.
+	time.Sleep(1 * time.Second)
+
+	return location, pieceIDs
+}
+
 func encryptionAccess(access string) (*encryption.Store, error) {
 	data, version, err := base58.CheckDecode(access)
 	if err != nil || version != 0 {
@@ -188,7 +974,7 @@ func TestGarbageCollection_PendingObject(t *testing.T) {
 			Satellite: testplanet.Combine(
 				func(log *zap.Logger, index int, config *satellite.Config) {
 					config.GarbageCollection.FalsePositiveRate = 0.000000001
-					config.GarbageCollection.Interval = 500 * time.Millisecond
+					config.GarbageCollection.Interval = time.Second
 				},
 				testplanet.MaxSegmentSize(20*memory.KiB),
 			),
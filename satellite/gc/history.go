@@ -0,0 +1,150 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/storj"
+)
+
+// HistoryDB keeps a durable record of the garbage collection runs.
+//
+// architecture: Database
+type HistoryDB interface {
+	// StartGCRun records that a run has started and returns the ID of the record.
+	StartGCRun(ctx context.Context, started time.Time) (id int64, err error)
+	// FinishGCRun updates the record of a run with its outcome.
+	FinishGCRun(ctx context.Context, record RunRecord) error
+	// LatestGCRun returns the record of the most recently started run, or
+	// ErrNoRuns when there is none. The totals of the reports are summed
+	// up for the creation date of its filters.
+	LatestGCRun(ctx context.Context) (RunRecord, error)
+	// RecordSentFilter stores that a filter was sent to a node, which allows
+	// the node to report the result.
+	RecordSentFilter(ctx context.Context, filter SentFilter) error
+	// GetSentFilter returns the filter that was sent to the node with the
+	// creation date, or ErrFilterNotSent when there is none.
+	GetSentFilter(ctx context.Context, nodeID storj.NodeID, creationDate time.Time) (SentFilter, error)
+	// RecordRetainReport stores the report of a node. It returns false when
+	// the node already reported the request.
+	RecordRetainReport(ctx context.Context, report RetainReport) (recorded bool, err error)
+}
+
+// RunStatus is the status of a recorded garbage collection run.
+type RunStatus int
+
+const (
+	// RunStatusRunning is the status of a run that has not finished yet, or
+	// that was interrupted before it could record its outcome.
+	RunStatusRunning RunStatus = 0
+	// RunStatusSucceeded is the status of a run that finished without errors.
+	RunStatusSucceeded RunStatus = 1
+	// RunStatusFailed is the status of a run that finished with an error.
+	RunStatusFailed RunStatus = 2
+)
+
+// String returns the name of the status.
+func (status RunStatus) String() string {
+	switch status {
+	case RunStatusRunning:
+		return "running"
+	case RunStatusSucceeded:
+		return "succeeded"
+	case RunStatusFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (status RunStatus) MarshalText() ([]byte, error) {
+	return []byte(status.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (status *RunStatus) UnmarshalText(text []byte) error {
+	for _, known := range []RunStatus{RunStatusRunning, RunStatusSucceeded, RunStatusFailed} {
+		if string(text) == known.String() {
+			*status = known
+			return nil
+		}
+	}
+	return Error.New("unknown run status %q", text)
+}
+
+// RunRecord is the durable record of a garbage collection run.
+type RunRecord struct {
+	ID       int64     `json:"id"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Status   RunStatus `json:"status"`
+
+	Segments int64 `json:"segments"`
+	// NodesTargeted is the number of nodes the run tried to send a filter to,
+	// NodesSent the number of those that received it.
+	NodesTargeted int   `json:"nodesTargeted"`
+	NodesSent     int   `json:"nodesSent"`
+	FilterBytes   int64 `json:"filterBytes"`
+	// CreationDate is the creation date of the sent filters, which nodes
+	// report their results for. NodesReporting is the number of nodes that
+	// were sent a filter and are new enough to report, and Reports the totals
+	// of the reports that have been received so far.
+	CreationDate   time.Time    `json:"creationDate"`
+	NodesReporting int          `json:"nodesReporting"`
+	Reports        ReportTotals `json:"reports"`
+
+	// Error is the error the run failed with.
+	Error string `json:"error,omitempty"`
+}
+
+// newRunRecord returns the record of a finished run.
+func newRunRecord(id int64, info RunInfo) RunRecord {
+	record := RunRecord{
+		ID:            id,
+		Started:       info.Started,
+		Finished:      info.Finished,
+		Status:        RunStatusSucceeded,
+		Segments:      info.Stats.Segments,
+		NodesTargeted: info.Stats.Sent.Sent + info.Stats.Sent.Retrying + info.Stats.Sent.GaveUp,
+		NodesSent:     info.Stats.Sent.Sent,
+		FilterBytes:   info.Stats.FilterBytes,
+
+		CreationDate:   info.Stats.CreationDate,
+		NodesReporting: info.Stats.NodesReporting,
+	}
+	if info.Error != "" {
+		record.Status = RunStatusFailed
+		record.Error = info.Error
+	}
+	return record
+}
+
+// recordStart records the start of a run in the history and returns the ID of
+// the record, or zero when it was not recorded.
+func (service *Service) recordStart(ctx context.Context, started time.Time) int64 {
+	if service.history == nil {
+		return 0
+	}
+	id, err := service.history.StartGCRun(ctx, started)
+	if err != nil {
+		service.log.Error("error recording start of garbage collection run", zap.Error(err))
+		return 0
+	}
+	return id
+}
+
+// recordFinish records the outcome of a run in the history.
+func (service *Service) recordFinish(ctx context.Context, id int64, info RunInfo) {
+	if service.history == nil || id == 0 {
+		return
+	}
+	if err := service.history.FinishGCRun(ctx, newRunRecord(id, info)); err != nil {
+		service.log.Error("error recording end of garbage collection run", zap.Int64("record", id), zap.Error(err))
+	}
+}
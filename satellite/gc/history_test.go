@@ -0,0 +1,215 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/rpc"
+	"storj.io/common/storj"
+	"storj.io/common/testcontext"
+)
+
+// fakeHistoryDB keeps the run records, sent filters and reports in memory.
+type fakeHistoryDB struct {
+	mu      sync.Mutex
+	records []RunRecord
+	sent    []SentFilter
+	reports []RetainReport
+}
+
+func (db *fakeHistoryDB) StartGCRun(ctx context.Context, started time.Time) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	id := int64(len(db.records) + 1)
+	db.records = append(db.records, RunRecord{ID: id, Started: started, Status: RunStatusRunning})
+	return id, nil
+}
+
+func (db *fakeHistoryDB) FinishGCRun(ctx context.Context, record RunRecord) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.records[record.ID-1] = record
+	return nil
+}
+
+func (db *fakeHistoryDB) LatestGCRun(ctx context.Context) (RunRecord, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if len(db.records) == 0 {
+		return RunRecord{}, ErrNoRuns.New("")
+	}
+	record := db.records[len(db.records)-1]
+	nodes := map[storj.NodeID]struct{}{}
+	for _, report := range db.reports {
+		if record.CreationDate.IsZero() || !report.CreationDate.Equal(record.CreationDate) {
+			continue
+		}
+		nodes[report.NodeID] = struct{}{}
+		record.Reports.PiecesExamined += report.PiecesExamined
+		record.Reports.PiecesTrashed += report.PiecesTrashed
+		record.Reports.BytesReclaimed += report.BytesReclaimed
+	}
+	record.Reports.Nodes = len(nodes)
+	return record, nil
+}
+
+func (db *fakeHistoryDB) RecordSentFilter(ctx context.Context, filter SentFilter) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i, existing := range db.sent {
+		if existing.NodeID == filter.NodeID && existing.CreationDate.Equal(filter.CreationDate) {
+			db.sent[i] = filter
+			return nil
+		}
+	}
+	db.sent = append(db.sent, filter)
+	return nil
+}
+
+func (db *fakeHistoryDB) GetSentFilter(ctx context.Context, nodeID storj.NodeID, creationDate time.Time) (SentFilter, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, existing := range db.sent {
+		if existing.NodeID == nodeID && existing.CreationDate.Equal(creationDate) {
+			return existing, nil
+		}
+	}
+	return SentFilter{}, ErrFilterNotSent.New("%v", nodeID)
+}
+
+func (db *fakeHistoryDB) RecordRetainReport(ctx context.Context, report RetainReport) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, existing := range db.reports {
+		if existing.NodeID == report.NodeID && existing.CreationDate.Equal(report.CreationDate) && existing.Range == report.Range {
+			return false, nil
+		}
+	}
+	db.reports = append(db.reports, report)
+	return true, nil
+}
+
+func TestServiceHistory(t *testing.T) {
+	ctx := testcontext.New(t)
+	dir := ctx.Dir("gc")
+
+	config := Config{
+		Enabled:           true,
+		Interval:          time.Hour,
+		InitialPieces:     10,
+		FalsePositiveRate: 0.1,
+		ConcurrentSends:   1,
+		RetryBackoff:      time.Second,
+		RetryMaxBackoff:   time.Second,
+		SendFilters:       true,
+		PersistDir:        dir,
+	}
+
+	// recent piece counts keep the service from asking the overlay.
+	store := NewStore(dir)
+	require.NoError(t, store.SavePieceCounts(ctx, map[storj.NodeID]int{}, time.Now()))
+
+	// a filter that can't be loaded fails the run.
+	badFilter := filepath.Join(dir, pendingDir, "invalid"+filterExt)
+	require.NoError(t, os.MkdirAll(filepath.Dir(badFilter), 0700))
+	require.NoError(t, ioutil.WriteFile(badFilter, []byte("invalid"), 0600))
+
+	history := &fakeHistoryDB{}
+	service := NewService(zaptest.NewLogger(t), config, rpc.Dialer{}, nil, history, nil)
+	ctx.Go(func() error { return service.Run(ctx) })
+	t.Cleanup(service.Loop.Close)
+
+	var failed RunRecord
+	require.Eventually(t, func() bool {
+		var err error
+		failed, err = history.LatestGCRun(ctx)
+		return err == nil && failed.Status != RunStatusRunning
+	}, 10*time.Second, time.Millisecond)
+
+	require.Equal(t, int64(1), failed.ID)
+	require.Equal(t, RunStatusFailed, failed.Status)
+	require.Contains(t, failed.Error, "invalid filter file name")
+	require.False(t, failed.Started.IsZero())
+	require.False(t, failed.Finished.Before(failed.Started))
+
+	require.NoError(t, os.Remove(badFilter))
+
+	_, done, err := service.Trigger()
+	require.NoError(t, err)
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+
+	succeeded, err := history.LatestGCRun(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), succeeded.ID)
+	require.Equal(t, RunStatusSucceeded, succeeded.Status)
+	require.Empty(t, succeeded.Error)
+	require.Zero(t, succeeded.NodesTargeted)
+}
+
+func TestAdminServerLatestRun(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	request := func(service *Service) (status int, record RunRecord) {
+		server := httptest.NewServer(NewAdminServer(zaptest.NewLogger(t), nil, service, "secret").Handler())
+		defer server.Close()
+
+		req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/gc/runs/latest", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "secret")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer ctx.Check(resp.Body.Close)
+
+		if resp.StatusCode < 300 {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&record))
+		}
+		return resp.StatusCode, record
+	}
+
+	status, _ := request(NewService(zaptest.NewLogger(t), Config{}, rpc.Dialer{}, nil, nil, nil))
+	require.Equal(t, http.StatusNotFound, status)
+
+	history := &fakeHistoryDB{}
+	service := NewService(zaptest.NewLogger(t), Config{}, rpc.Dialer{}, nil, history, nil)
+	status, _ = request(service)
+	require.Equal(t, http.StatusNotFound, status)
+
+	id, err := history.StartGCRun(ctx, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, history.FinishGCRun(ctx, RunRecord{
+		ID:     id,
+		Status: RunStatusFailed,
+		Error:  "gc: error generating retain filters",
+	}))
+
+	status, record := request(service)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, id, record.ID)
+	require.Equal(t, RunStatusFailed, record.Status)
+	require.Equal(t, "gc: error generating retain filters", record.Error)
+}
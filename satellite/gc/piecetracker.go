@@ -5,14 +5,19 @@ package gc
 
 import (
 	"context"
+	"math"
+	"sync/atomic"
 	"time"
 
 	"github.com/zeebo/errs"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 
 	"storj.io/common/bloomfilter"
 	"storj.io/common/memory"
 	"storj.io/common/storj"
+	"storj.io/common/sync2"
+	"storj.io/storj/private/retainfilter"
 	"storj.io/storj/satellite/metabase/segmentloop"
 )
 
@@ -22,68 +27,353 @@ var _ segmentloop.Observer = (*PieceTracker)(nil)
 //
 // architecture: Observer
 type PieceTracker struct {
-	log          *zap.Logger
-	config       Config
+	log    *zap.Logger
+	config Config
+	// creationDate is when the loop iteration started. Pieces of segments
+	// committed after it may be missing from the filters, so nodes must only
+	// delete pieces that are older.
 	creationDate time.Time
 	// TODO: should we use int or int64 consistently for piece count (db type is int64)?
 	pieceCounts map[storj.NodeID]int
+	// targets are the only nodes that filters are created for, when set.
+	targets map[storj.NodeID]struct{}
+	// memoryUsage is the estimated number of bytes used by RetainInfos.
+	memoryUsage int64
+	// segments and pieces count what has been iterated. segments is updated
+	// atomically, as it is read for the status of the service.
+	segments int64
+	pieces   int64
+	// inlineSegments and copiedSegments count the segments that have no
+	// pieces of their own.
+	inlineSegments int64
+	copiedSegments int64
+	// roots are the root piece IDs of the remote segments seen, when copies
+	// are detected. A server-side copy shares the root piece ID and pieces
+	// of its ancestor.
+	roots map[storj.PieceID]struct{}
+	// samples are the pieces that the filters are verified with.
+	samples []pieceSample
+
+	// limiter limits the segments processed per second, when set.
+	limiter *rate.Limiter
+	// nowFn and sleep are fields so that tests can replace the clock of the
+	// limiter.
+	nowFn func() time.Time
+	sleep func(ctx context.Context, duration time.Duration) bool
 
 	RetainInfos map[storj.NodeID]*RetainInfo
 }
 
 // NewPieceTracker instantiates a new gc piece tracker to be subscribed to the metainfo loop.
 func NewPieceTracker(log *zap.Logger, config Config, pieceCounts map[storj.NodeID]int) *PieceTracker {
-	return &PieceTracker{
-		log:          log,
-		config:       config,
-		creationDate: time.Now().UTC(),
-		pieceCounts:  pieceCounts,
-
-		RetainInfos: make(map[storj.NodeID]*RetainInfo, len(pieceCounts)),
+	pieceTracker := &PieceTracker{
+		log:         log,
+		config:      config,
+		pieceCounts: pieceCounts,
+		nowFn:       time.Now,
+		sleep:       sync2.Sleep,
+	}
+	if config.RateLimit > 0 {
+		pieceTracker.limiter = rate.NewLimiter(rate.Limit(config.RateLimit), 1)
+	}
+	if config.DetectCopies {
+		pieceTracker.roots = make(map[storj.PieceID]struct{})
+	}
+	if config.TargetNodesOnly {
+		pieceTracker.targets = config.TargetNodes.set()
+		pieceTracker.RetainInfos = make(map[storj.NodeID]*RetainInfo, len(pieceTracker.targets))
+	} else {
+		pieceTracker.RetainInfos = make(map[storj.NodeID]*RetainInfo, len(pieceCounts))
 	}
+	return pieceTracker
 }
 
 // LoopStarted is called at each start of a loop.
 func (pieceTracker *PieceTracker) LoopStarted(ctx context.Context, info segmentloop.LoopInfo) (err error) {
-	if pieceTracker.creationDate.After(info.Started) {
-		return errs.New("Creation date after loop starting time.")
+	defer pieceTracker.detachOnFailure(&err)
+
+	if len(pieceTracker.RetainInfos) > 0 {
+		return errs.New("loop started after pieces were added")
+	}
+	pieceTracker.creationDate = info.Started.UTC()
+
+	// a snapshot may be much older than its database says it is now.
+	snapshotCreated, err := pieceTracker.config.snapshotCreated()
+	if err != nil {
+		return Error.New("invalid snapshot creation time: %w", err)
+	}
+	if !snapshotCreated.IsZero() {
+		pieceTracker.creationDate = snapshotCreated.UTC()
 	}
 	return nil
 }
 
 // RemoteSegment takes a remote segment found in metabase and adds pieces to bloom filters.
 func (pieceTracker *PieceTracker) RemoteSegment(ctx context.Context, segment *segmentloop.Segment) (err error) {
+	defer pieceTracker.detachOnFailure(&err)
 	defer mon.Task()(&ctx)(&err)
 
+	if err := pieceTracker.wait(ctx); err != nil {
+		return err
+	}
+
+	atomic.AddInt64(&pieceTracker.segments, 1)
+
+	copied, err := pieceTracker.isCopy(segment.RootPieceID)
+	if err != nil {
+		return err
+	}
+	if copied {
+		// the pieces are still added, in case the copy has been repaired
+		// separately, but were already counted with the ancestor unless
+		// their node has no filter yet.
+		pieceTracker.copiedSegments++
+		for _, piece := range segment.Pieces {
+			pieceID := segment.RootPieceID.Derive(piece.StorageNode, int32(piece.Number))
+			if info, ok := pieceTracker.RetainInfos[piece.StorageNode]; ok {
+				info.add(pieceID)
+				continue
+			}
+			if err := pieceTracker.add(piece.StorageNode, pieceID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	for _, piece := range segment.Pieces {
 		pieceID := segment.RootPieceID.Derive(piece.StorageNode, int32(piece.Number))
-		pieceTracker.add(piece.StorageNode, pieceID)
+		if err := pieceTracker.add(piece.StorageNode, pieceID); err != nil {
+			return err
+		}
+		if pieceTracker.tracks(piece.StorageNode) {
+			pieceTracker.sample(segment.RootPieceID, piece)
+		}
 	}
 
 	return nil
 }
 
-// InlineSegment returns nil because we're only doing gc for storage nodes for now.
+// rootOverhead is the estimated number of bytes used for remembering the root
+// piece ID of a segment to detect copies.
+const rootOverhead = 48
+
+// isCopy returns whether a segment with the root piece ID has been seen
+// before, which makes the segment a copy. It is always false when copies are
+// not detected.
+func (pieceTracker *PieceTracker) isCopy(rootPieceID storj.PieceID) (bool, error) {
+	if pieceTracker.roots == nil {
+		return false, nil
+	}
+	if _, ok := pieceTracker.roots[rootPieceID]; ok {
+		return true, nil
+	}
+
+	usage := pieceTracker.memoryUsage + rootOverhead
+	if limit := pieceTracker.config.MaxMemory; limit > 0 && usage > limit.Int64() {
+		return false, ErrMemoryLimit.New("root piece IDs of %d segments need more than %s; raise the limit or disable detecting copies",
+			len(pieceTracker.roots)+1, limit)
+	}
+	pieceTracker.memoryUsage = usage
+	pieceTracker.roots[rootPieceID] = struct{}{}
+	return false, nil
+}
+
+// InlineSegment only counts the segment, as inline segments are stored in the
+// metabase and have no pieces on storage nodes.
 func (pieceTracker *PieceTracker) InlineSegment(ctx context.Context, segment *segmentloop.Segment) (err error) {
+	if err := pieceTracker.wait(ctx); err != nil {
+		return err
+	}
+	atomic.AddInt64(&pieceTracker.segments, 1)
+	pieceTracker.inlineSegments++
 	return nil
 }
 
+// wait blocks until the rate limit allows processing another segment. It
+// returns early when the context is canceled, so that shutting down is not
+// delayed by the limit.
+func (pieceTracker *PieceTracker) wait(ctx context.Context) error {
+	if pieceTracker.limiter == nil {
+		return nil
+	}
+
+	now := pieceTracker.nowFn()
+	reservation := pieceTracker.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		if !pieceTracker.sleep(ctx, delay) {
+			reservation.CancelAt(pieceTracker.nowFn())
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// detachOnFailure turns a panic into an error and releases the filters when
+// the piece tracker fails. The segment loop detaches observers that return
+// an error, so a failing piece tracker only fails its own garbage collection
+// run while the iteration continues for the other observers.
+func (pieceTracker *PieceTracker) detachOnFailure(err *error) {
+	if r := recover(); r != nil {
+		*err = Error.New("piece tracker panicked: %v", r)
+	}
+	if *err != nil {
+		pieceTracker.RetainInfos = nil
+		pieceTracker.roots = nil
+	}
+}
+
+// MemoryUsage returns the estimated number of bytes used by the retain infos.
+// As filters are never released during a loop, this is also the high-water mark.
+func (pieceTracker *PieceTracker) MemoryUsage() memory.Size {
+	return memory.Size(pieceTracker.memoryUsage)
+}
+
+// retainInfoOverhead is the estimated number of bytes used for every node in
+// addition to its filter: the map entry, the RetainInfo and the Filter. A node
+// with a split filter uses it once more for every part.
+const retainInfoOverhead = 160
+
 // adds a pieceID to the relevant node's RetainInfo.
-func (pieceTracker *PieceTracker) add(nodeID storj.NodeID, pieceID storj.PieceID) {
+func (pieceTracker *PieceTracker) add(nodeID storj.NodeID, pieceID storj.PieceID) error {
+	if !pieceTracker.tracks(nodeID) {
+		return nil
+	}
 	if _, ok := pieceTracker.RetainInfos[nodeID]; !ok {
 		// If we know how many pieces a node should be storing, use that number. Otherwise use default.
 		numPieces := pieceTracker.config.InitialPieces
 		if pieceTracker.pieceCounts[nodeID] > 0 {
 			numPieces = pieceTracker.pieceCounts[nodeID]
 		}
-		// limit size of bloom filter to ensure we are under the limit for RPC
-		filter := bloomfilter.NewOptimalMaxSize(numPieces, pieceTracker.config.FalsePositiveRate, 2*memory.MiB)
-		pieceTracker.RetainInfos[nodeID] = &RetainInfo{
-			Filter:       filter,
-			CreationDate: pieceTracker.creationDate,
+		info := newRetainInfo(pieceTracker.config, numPieces)
+		info.CreationDate = pieceTracker.creationDate
+
+		usage := pieceTracker.memoryUsage + info.Size() + retainInfoOverhead*int64(len(info.Parts)+1)
+		if limit := pieceTracker.config.MaxMemory; limit > 0 && usage > limit.Int64() {
+			return ErrMemoryLimit.New("bloom filters for %d nodes need more than %s; raise the limit or lower the false positive rate",
+				len(pieceTracker.RetainInfos)+1, limit)
 		}
+		pieceTracker.memoryUsage = usage
+
+		pieceTracker.RetainInfos[nodeID] = info
 	}
 
-	pieceTracker.RetainInfos[nodeID].Filter.Add(pieceID)
+	pieceTracker.RetainInfos[nodeID].add(pieceID)
 	pieceTracker.RetainInfos[nodeID].Count++
+	pieceTracker.pieces++
+	return nil
+}
+
+// tracks returns whether filters are created for the node.
+func (pieceTracker *PieceTracker) tracks(nodeID storj.NodeID) bool {
+	if pieceTracker.targets == nil {
+		return true
+	}
+	_, ok := pieceTracker.targets[nodeID]
+	return ok
+}
+
+// minFalsePositiveRate is the lowest false positive rate a filter is created
+// with, which keeps the hash count of the filter within its limit.
+const minFalsePositiveRate = 1e-9
+
+// newRetainInfo creates the retain info for a node that is expected to hold
+// pieceCount pieces. When its filter would exceed the maximum filter size, and
+// splitting is enabled, the pieces are split across several filters instead.
+func newRetainInfo(config Config, pieceCount int) *RetainInfo {
+	bits := splitBits(config, pieceCount)
+	if bits == 0 {
+		return &RetainInfo{Filter: newFilter(config, pieceCount)}
+	}
+
+	// every part must contain the pieces of its range at the rate of the node.
+	partPieces := (pieceCount + 1<<bits - 1) >> bits
+	falsePositiveRate := capFalsePositiveRate(config, targetFalsePositiveRate(config, pieceCount), partPieces)
+	first := bloomfilter.NewOptimalMaxSize(partPieces, falsePositiveRate, config.MaxBloomFilterSize)
+
+	// the parts share their parameters, so that their union is a valid filter.
+	parts := make([]*bloomfilter.Filter, 1<<bits)
+	parts[0] = first
+	for i := 1; i < len(parts); i++ {
+		// the bytes of an empty filter are always valid.
+		parts[i], _ = bloomfilter.NewFromBytes(first.Bytes())
+	}
+	return &RetainInfo{Parts: parts}
+}
+
+// splitBits returns the number of piece ID bits that split the pieces of a node
+// that is expected to hold pieceCount pieces into ranges, whose filters do not
+// exceed the maximum filter size at the configured rate. It is zero when
+// splitting is disabled.
+func splitBits(config Config, pieceCount int) uint8 {
+	if config.SplitMinimumVersion == "" || config.MaxBloomFilterSize <= 0 || pieceCount <= 0 {
+		return 0
+	}
+
+	size := bitsPerPiece(targetFalsePositiveRate(config, pieceCount)) * float64(pieceCount) / 8
+	var bits uint8
+	for bits < retainfilter.MaxBits && size > config.MaxBloomFilterSize.Float64() {
+		bits++
+		size /= 2
+	}
+	return bits
+}
+
+// newFilter creates the bloom filter for a node that is expected to hold
+// pieceCount pieces.
+func newFilter(config Config, pieceCount int) *bloomfilter.Filter {
+	falsePositiveRate := filterFalsePositiveRate(config, pieceCount)
+	if config.MaxBloomFilterSize <= 0 {
+		return bloomfilter.NewOptimal(pieceCount, falsePositiveRate)
+	}
+	// limit size of bloom filter to ensure we are under the limit for RPC
+	return bloomfilter.NewOptimalMaxSize(pieceCount, falsePositiveRate, config.MaxBloomFilterSize)
+}
+
+// filterFalsePositiveRate returns the false positive rate to create the filter
+// for a node that is expected to hold pieceCount pieces with.
+//
+// Nodes holding fewer than the initial pieces get a proportionally lower rate,
+// so that their filters are never larger than the filter for a node holding
+// the initial pieces. Nodes holding so many pieces that the configured rate
+// would exceed the maximum filter size get the rate the maximum size allows.
+func filterFalsePositiveRate(config Config, pieceCount int) float64 {
+	return capFalsePositiveRate(config, targetFalsePositiveRate(config, pieceCount), pieceCount)
+}
+
+// targetFalsePositiveRate returns the false positive rate for the filter of a
+// node that is expected to hold pieceCount pieces, regardless of its size.
+func targetFalsePositiveRate(config Config, pieceCount int) float64 {
+	falsePositiveRate := config.FalsePositiveRate
+	if pieceCount <= 0 {
+		return falsePositiveRate
+	}
+
+	if pieceCount < config.InitialPieces {
+		falsePositiveRate *= float64(pieceCount) / float64(config.InitialPieces)
+	}
+	if falsePositiveRate < minFalsePositiveRate {
+		falsePositiveRate = minFalsePositiveRate
+	}
+	return falsePositiveRate
+}
+
+// capFalsePositiveRate returns the false positive rate, or the rate the
+// maximum filter size allows for pieceCount pieces when it is higher.
+func capFalsePositiveRate(config Config, falsePositiveRate float64, pieceCount int) float64 {
+	if config.MaxBloomFilterSize > 0 && pieceCount > 0 {
+		// see bloomfilter.NewOptimal for the relation between the rate and bits.
+		maxBitsPerPiece := 8 * config.MaxBloomFilterSize.Float64() / float64(pieceCount)
+		if bitsPerPiece(falsePositiveRate) > maxBitsPerPiece {
+			falsePositiveRate = math.Pow(2, -maxBitsPerPiece/1.44)
+		}
+	}
+
+	return falsePositiveRate
+}
+
+// bitsPerPiece returns the bits per piece that a filter with the false positive
+// rate needs.
+func bitsPerPiece(falsePositiveRate float64) float64 {
+	return -1.44 * math.Log2(falsePositiveRate)
 }
@@ -0,0 +1,463 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/bloomfilter"
+	"storj.io/common/memory"
+	"storj.io/common/rpc"
+	"storj.io/common/storj"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/private/cfgstruct"
+	"storj.io/storj/private/retainfilter"
+	"storj.io/storj/satellite/metabase"
+	"storj.io/storj/satellite/metabase/segmentloop"
+)
+
+func TestFilterParameters(t *testing.T) {
+	config := Config{
+		InitialPieces:      400000,
+		FalsePositiveRate:  0.1,
+		MaxBloomFilterSize: 2 * memory.MiB,
+	}
+	initial := newFilter(config, config.InitialPieces)
+	_, initialSize := initial.Parameters()
+
+	for _, tt := range []struct {
+		pieceCount int
+		rate       float64
+	}{
+		{pieceCount: 1, rate: 0.00000025},
+		{pieceCount: 10000, rate: 0.0025},
+		{pieceCount: 100000, rate: 0.025},
+		{pieceCount: 400000, rate: 0.1},
+		{pieceCount: 1000000, rate: 0.1},
+		{pieceCount: 5000000, rate: 0.1989},
+		{pieceCount: 20000000, rate: 0.6678},
+	} {
+		rate := filterFalsePositiveRate(config, tt.pieceCount)
+		require.InDelta(t, tt.rate, rate, tt.rate/1000, tt.pieceCount)
+
+		filter := newFilter(config, tt.pieceCount)
+		hashCount, size := filter.Parameters()
+		require.LessOrEqual(t, hashCount, 32, tt.pieceCount)
+		require.LessOrEqual(t, size, config.MaxBloomFilterSize.Int(), tt.pieceCount)
+		if tt.pieceCount < config.InitialPieces {
+			require.LessOrEqual(t, size, initialSize, tt.pieceCount)
+		}
+	}
+
+	// huge nodes use the whole filter
+	_, size := newFilter(config, 20000000).Parameters()
+	require.Equal(t, config.MaxBloomFilterSize.Int(), size)
+
+	// tiny nodes are limited to the minimum rate
+	require.Equal(t, minFalsePositiveRate, filterFalsePositiveRate(Config{
+		InitialPieces:     1000000000,
+		FalsePositiveRate: 0.1,
+	}, 1))
+
+	// without a maximum size the configured rate is used for huge nodes
+	config.MaxBloomFilterSize = 0
+	require.Equal(t, 0.1, filterFalsePositiveRate(config, 20000000))
+}
+
+func TestSplitFilters(t *testing.T) {
+	const pieceCount = 20000
+	config := Config{
+		InitialPieces:       1000,
+		FalsePositiveRate:   0.1,
+		MaxBloomFilterSize:  memory.KiB,
+		SplitMinimumVersion: "v1.50.0",
+	}
+
+	pieces := make([]storj.PieceID, pieceCount)
+	for i := range pieces {
+		pieces[i] = testrand.PieceID()
+	}
+
+	// without splitting the filter is capped at the maximum size.
+	unsplitConfig := config
+	unsplitConfig.SplitMinimumVersion = ""
+	unsplit := newRetainInfo(unsplitConfig, pieceCount)
+	require.Empty(t, unsplit.Parts)
+
+	info := newRetainInfo(config, pieceCount)
+	require.Nil(t, info.Filter)
+	require.Len(t, info.Parts, 16)
+	for _, part := range info.Parts {
+		hashCount, size := part.Parameters()
+		firstHashCount, firstSize := info.Parts[0].Parameters()
+		require.Equal(t, firstHashCount, hashCount)
+		require.Equal(t, firstSize, size)
+		require.LessOrEqual(t, size, config.MaxBloomFilterSize.Int())
+	}
+
+	for _, pieceID := range pieces {
+		unsplit.add(pieceID)
+		info.add(pieceID)
+	}
+
+	// nodes that support splitting get a request for every range.
+	requests, err := info.requests(true, 0)
+	require.NoError(t, err)
+	require.Len(t, requests, len(info.Parts))
+
+	covered := 0
+	for _, request := range requests {
+		pieceRange, filter, err := retainfilter.Decode(request.Filter)
+		require.NoError(t, err)
+		require.Equal(t, uint8(4), pieceRange.Bits)
+		for _, pieceID := range pieces {
+			if pieceRange.Contains(pieceID) {
+				require.True(t, filter.Contains(pieceID))
+				covered++
+			}
+		}
+	}
+	require.Equal(t, pieceCount, covered)
+
+	// the split filters keep the configured rate, the capped filter doesn't.
+	falsePositives := func(contains func(storj.PieceID) bool) float64 {
+		count := 0
+		for i := 0; i < 10000; i++ {
+			if contains(testrand.PieceID()) {
+				count++
+			}
+		}
+		return float64(count) / 10000
+	}
+	splitRate := falsePositives(func(pieceID storj.PieceID) bool {
+		return info.Parts[retainfilter.RangeOf(4, pieceID).Index].Contains(pieceID)
+	})
+	require.Less(t, splitRate, 0.15)
+	require.Greater(t, falsePositives(unsplit.Filter.Contains), 0.5)
+
+	// other nodes get a single request with the union of the parts.
+	requests, err = info.requests(false, 0)
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	union, err := bloomfilter.NewFromBytes(requests[0].Filter)
+	require.NoError(t, err)
+	for _, pieceID := range pieces {
+		require.True(t, union.Contains(pieceID))
+	}
+
+	// nodes that are asked to report get the flags with every request.
+	for _, splitSupported := range []bool{true, false} {
+		requests, err = info.requests(splitSupported, retainfilter.FlagReport)
+		require.NoError(t, err)
+		for _, request := range requests {
+			require.Equal(t, retainfilter.FlagReport, retainfilter.FlagsOf(request.Filter))
+			_, _, err := retainfilter.Decode(request.Filter)
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestSplitSupported(t *testing.T) {
+	service := NewService(zaptest.NewLogger(t), Config{SplitMinimumVersion: "v1.50.0"}, rpc.Dialer{}, nil, nil, nil)
+	require.True(t, service.splitSupported("v1.50.0"))
+	require.True(t, service.splitSupported("v1.51.2"))
+	require.True(t, service.splitSupported("v2.0.0"))
+	require.False(t, service.splitSupported("v1.49.9"))
+	require.False(t, service.splitSupported(""))
+	require.False(t, service.splitSupported("invalid"))
+
+	disabled := NewService(zaptest.NewLogger(t), Config{}, rpc.Dialer{}, nil, nil, nil)
+	require.False(t, disabled.splitSupported("v2.0.0"))
+}
+
+func TestPieceTrackerMemoryLimit(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	nodeCount, segmentCount := 5000, 200000
+	if testing.Short() {
+		nodeCount, segmentCount = 500, 20000
+	}
+	const piecesPerSegment = 10
+
+	nodes := make([]storj.NodeID, nodeCount)
+	for i := range nodes {
+		nodes[i] = testrand.NodeID()
+	}
+
+	segments := make([]segmentloop.Segment, segmentCount)
+	for i := range segments {
+		pieces := make(metabase.Pieces, piecesPerSegment)
+		for k := range pieces {
+			pieces[k] = metabase.Piece{
+				Number:      uint16(k),
+				StorageNode: nodes[(i*piecesPerSegment+k)%len(nodes)],
+			}
+		}
+		segments[i] = segmentloop.Segment{
+			RootPieceID: testrand.PieceID(),
+			Pieces:      pieces,
+		}
+	}
+
+	config := Config{
+		InitialPieces:      segmentCount * piecesPerSegment / nodeCount,
+		FalsePositiveRate:  0.1,
+		MaxBloomFilterSize: 2 * memory.MiB,
+	}
+
+	run := func(config Config) (*PieceTracker, error) {
+		pieceTracker := NewPieceTracker(zaptest.NewLogger(t), config, map[storj.NodeID]int{})
+		for i := range segments {
+			if err := pieceTracker.RemoteSegment(ctx, &segments[i]); err != nil {
+				return pieceTracker, err
+			}
+		}
+		return pieceTracker, nil
+	}
+
+	unlimited, err := run(config)
+	require.NoError(t, err)
+	require.Len(t, unlimited.RetainInfos, nodeCount)
+	require.EqualValues(t, segmentCount, unlimited.segments)
+	require.EqualValues(t, segmentCount*piecesPerSegment, unlimited.pieces)
+
+	var filterBytes int64
+	for _, info := range unlimited.RetainInfos {
+		filterBytes += info.Filter.Size()
+	}
+	require.Equal(t, filterBytes+int64(nodeCount)*retainInfoOverhead, unlimited.MemoryUsage().Int64())
+
+	// the run fits exactly into the limit
+	config.MaxMemory = unlimited.MemoryUsage()
+	_, err = run(config)
+	require.NoError(t, err)
+
+	// the run is aborted before exceeding the limit
+	config.MaxMemory = unlimited.MemoryUsage() / 2
+	limited, err := run(config)
+	require.Error(t, err)
+	require.True(t, ErrMemoryLimit.Has(err))
+	require.LessOrEqual(t, limited.MemoryUsage(), config.MaxMemory)
+	require.Less(t, len(limited.RetainInfos), nodeCount)
+}
+
+func TestPieceTrackerCreationDate(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	pieceTracker := NewPieceTracker(zaptest.NewLogger(t), Config{
+		InitialPieces:     10,
+		FalsePositiveRate: 0.1,
+	}, map[storj.NodeID]int{})
+
+	// the filters are created as of the start of the iteration rather than
+	// when they are sent, so pieces uploaded meanwhile are newer.
+	started := time.Now().Add(-time.Hour)
+	require.NoError(t, pieceTracker.LoopStarted(ctx, segmentloop.LoopInfo{Started: started}))
+
+	node := testrand.NodeID()
+	require.NoError(t, pieceTracker.RemoteSegment(ctx, &segmentloop.Segment{
+		RootPieceID: testrand.PieceID(),
+		Pieces:      metabase.Pieces{{Number: 0, StorageNode: node}},
+	}))
+	require.True(t, started.Equal(pieceTracker.RetainInfos[node].CreationDate))
+
+	require.Error(t, pieceTracker.LoopStarted(ctx, segmentloop.LoopInfo{Started: time.Now()}))
+
+	// filters generated from a snapshot are created as of the snapshot, as
+	// the pieces uploaded since then are missing.
+	snapshotCreated := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	pieceTracker = NewPieceTracker(zaptest.NewLogger(t), Config{
+		InitialPieces:       10,
+		FalsePositiveRate:   0.1,
+		SnapshotDatabaseURL: "postgres://snapshot",
+		SnapshotCreated:     snapshotCreated.Format(time.RFC3339),
+	}, map[storj.NodeID]int{})
+	require.NoError(t, pieceTracker.LoopStarted(ctx, segmentloop.LoopInfo{Started: time.Now()}))
+	require.NoError(t, pieceTracker.RemoteSegment(ctx, &segmentloop.Segment{
+		RootPieceID: testrand.PieceID(),
+		Pieces:      metabase.Pieces{{Number: 0, StorageNode: node}},
+	}))
+	require.True(t, snapshotCreated.Equal(pieceTracker.RetainInfos[node].CreationDate))
+}
+
+func TestPieceTrackerFailure(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	segment := &segmentloop.Segment{
+		RootPieceID: testrand.PieceID(),
+		Pieces:      metabase.Pieces{{Number: 0, StorageNode: testrand.NodeID()}},
+	}
+
+	// errors release the filters.
+	pieceTracker := NewPieceTracker(zaptest.NewLogger(t), Config{
+		InitialPieces:     10,
+		FalsePositiveRate: 0.1,
+		MaxMemory:         1,
+	}, map[storj.NodeID]int{})
+	err := pieceTracker.RemoteSegment(ctx, segment)
+	require.True(t, ErrMemoryLimit.Has(err))
+	require.Nil(t, pieceTracker.RetainInfos)
+
+	// panics are returned as errors instead of crashing the segment loop.
+	pieceTracker = NewPieceTracker(zaptest.NewLogger(t), Config{
+		InitialPieces:     10,
+		FalsePositiveRate: 0.1,
+	}, map[storj.NodeID]int{})
+	pieceTracker.RetainInfos = nil
+	err = pieceTracker.RemoteSegment(ctx, segment)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "piece tracker panicked")
+}
+
+func TestPieceTrackerRateLimit(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	pieceTracker := NewPieceTracker(zaptest.NewLogger(t), Config{
+		InitialPieces:     10,
+		FalsePositiveRate: 0.1,
+		RateLimit:         2,
+	}, map[storj.NodeID]int{})
+
+	now := time.Now()
+	pieceTracker.nowFn = func() time.Time { return now }
+	pieceTracker.sleep = func(ctx context.Context, duration time.Duration) bool {
+		now = now.Add(duration)
+		return true
+	}
+
+	start := now
+	processed := func() int64 { return pieceTracker.segments }
+	segment := &segmentloop.Segment{
+		RootPieceID: testrand.PieceID(),
+		Pieces:      metabase.Pieces{{Number: 0, StorageNode: testrand.NodeID()}},
+	}
+	for i := 0; i < 20; i++ {
+		require.NoError(t, pieceTracker.RemoteSegment(ctx, segment))
+		require.NoError(t, pieceTracker.InlineSegment(ctx, &segmentloop.Segment{}))
+	}
+
+	// the first segment is processed right away, every further one after
+	// half a second.
+	require.EqualValues(t, 40, processed())
+	require.Equal(t, 39*500*time.Millisecond, now.Sub(start))
+
+	// waiting for the limit stops when the context is canceled.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	pieceTracker.sleep = func(ctx context.Context, duration time.Duration) bool {
+		return ctx.Err() == nil
+	}
+	err := pieceTracker.RemoteSegment(canceled, segment)
+	require.ErrorIs(t, err, context.Canceled)
+	require.EqualValues(t, 40, processed())
+}
+
+func TestPieceTrackerSegmentKinds(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	nodes := []storj.NodeID{testrand.NodeID(), testrand.NodeID(), testrand.NodeID()}
+	original := segmentloop.Segment{
+		RootPieceID: testrand.PieceID(),
+		Pieces: metabase.Pieces{
+			{Number: 0, StorageNode: nodes[0]},
+			{Number: 1, StorageNode: nodes[1]},
+			{Number: 2, StorageNode: nodes[2]},
+		},
+	}
+	// a server-side copy shares the root piece ID and pieces of its ancestor.
+	copied := original
+	other := segmentloop.Segment{
+		RootPieceID: testrand.PieceID(),
+		Pieces:      metabase.Pieces{{Number: 0, StorageNode: nodes[0]}},
+	}
+	inline := segmentloop.Segment{EncryptedSize: 1024}
+
+	run := func(config Config) *PieceTracker {
+		pieceTracker := NewPieceTracker(zaptest.NewLogger(t), config, map[storj.NodeID]int{})
+		require.NoError(t, pieceTracker.RemoteSegment(ctx, &original))
+		require.NoError(t, pieceTracker.InlineSegment(ctx, &inline))
+		require.NoError(t, pieceTracker.RemoteSegment(ctx, &copied))
+		require.NoError(t, pieceTracker.RemoteSegment(ctx, &other))
+
+		require.EqualValues(t, 4, pieceTracker.segments)
+		require.EqualValues(t, 1, pieceTracker.inlineSegments)
+		require.Len(t, pieceTracker.RetainInfos, len(nodes))
+		for _, segment := range []segmentloop.Segment{original, other} {
+			for _, piece := range segment.Pieces {
+				pieceID := segment.RootPieceID.Derive(piece.StorageNode, int32(piece.Number))
+				require.True(t, pieceTracker.RetainInfos[piece.StorageNode].Contains(pieceID))
+			}
+		}
+		return pieceTracker
+	}
+
+	config := Config{
+		InitialPieces:     10,
+		FalsePositiveRate: 0.1,
+		DetectCopies:      true,
+	}
+	pieceTracker := run(config)
+	require.EqualValues(t, 1, pieceTracker.copiedSegments)
+	require.EqualValues(t, 4, pieceTracker.pieces)
+	require.Equal(t, 2, pieceTracker.RetainInfos[nodes[0]].Count)
+	require.Equal(t, 1, pieceTracker.RetainInfos[nodes[1]].Count)
+	require.Equal(t, 1, pieceTracker.RetainInfos[nodes[2]].Count)
+
+	var filterBytes int64
+	for _, info := range pieceTracker.RetainInfos {
+		filterBytes += info.Size()
+	}
+	require.Equal(t, filterBytes+int64(len(nodes))*retainInfoOverhead+2*rootOverhead, pieceTracker.MemoryUsage().Int64())
+
+	// without detecting copies, their pieces are counted again.
+	config.DetectCopies = false
+	pieceTracker = run(config)
+	require.Zero(t, pieceTracker.copiedSegments)
+	require.EqualValues(t, 7, pieceTracker.pieces)
+	require.Equal(t, 3, pieceTracker.RetainInfos[nodes[0]].Count)
+	require.Equal(t, 2, pieceTracker.RetainInfos[nodes[1]].Count)
+	require.Equal(t, 2, pieceTracker.RetainInfos[nodes[2]].Count)
+}
+
+func TestPieceTrackerDefaultMemory(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	var config Config
+	cfgstruct.Bind(&pflag.FlagSet{}, &config, cfgstruct.UseReleaseDefaults())
+	config.InitialPieces = 1000
+
+	node := testrand.NodeID()
+	segment := func() *segmentloop.Segment {
+		return &segmentloop.Segment{
+			RootPieceID: testrand.PieceID(),
+			Pieces:      metabase.Pieces{{Number: 0, StorageNode: node}},
+		}
+	}
+
+	// the limit only leaves room for the filter of the node.
+	pieceTracker := NewPieceTracker(zaptest.NewLogger(t), config, map[storj.NodeID]int{})
+	require.NoError(t, pieceTracker.RemoteSegment(ctx, segment()))
+	config.MaxMemory = pieceTracker.MemoryUsage()
+
+	// with the default config the memory used does not grow with the number
+	// of segments.
+	pieceTracker = NewPieceTracker(zaptest.NewLogger(t), config, map[storj.NodeID]int{})
+	for i := 0; i < 10000; i++ {
+		require.NoError(t, pieceTracker.RemoteSegment(ctx, segment()))
+	}
+	require.Equal(t, config.MaxMemory, pieceTracker.MemoryUsage())
+
+	// detecting copies remembers every root piece ID, which the limit stops.
+	config.DetectCopies = true
+	pieceTracker = NewPieceTracker(zaptest.NewLogger(t), config, map[storj.NodeID]int{})
+	err := pieceTracker.RemoteSegment(ctx, segment())
+	require.True(t, ErrMemoryLimit.Has(err))
+}
@@ -0,0 +1,153 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/identity"
+	"storj.io/common/rpc/rpcstatus"
+	"storj.io/common/storj"
+	"storj.io/storj/private/retainfilter"
+	"storj.io/storj/private/retainpb"
+)
+
+// RetainReport is what a storage node reports after it finished processing
+// a retain request.
+type RetainReport struct {
+	NodeID storj.NodeID
+	// CreationDate is the creation date of the retain request, which is the
+	// same for all filters of a run.
+	CreationDate time.Time
+	Range        retainfilter.Range
+
+	PiecesExamined int64
+	PiecesTrashed  int64
+	BytesReclaimed int64
+	Duration       time.Duration
+	Reported       time.Time
+}
+
+// SentFilter is a filter that was sent to a node, asking it to report the
+// result.
+type SentFilter struct {
+	NodeID       storj.NodeID
+	CreationDate time.Time
+	// RangeBits is the number of bits that the ranges of the parts of the
+	// filter are selected by, zero when it was sent in one piece.
+	RangeBits uint8
+	Sent      time.Time
+}
+
+// ReportTotals sums up the reports of the nodes for the filters of a run.
+type ReportTotals struct {
+	// Nodes is the number of nodes that reported at least one request.
+	Nodes          int   `json:"nodes"`
+	PiecesExamined int64 `json:"piecesExamined"`
+	PiecesTrashed  int64 `json:"piecesTrashed"`
+	BytesReclaimed int64 `json:"bytesReclaimed"`
+}
+
+// Endpoint receives the results of retain requests from storage nodes and
+// records them in the history. Only the results of filters that were sent to
+// the node asking for a report are accepted, once each. Nodes that don't
+// report anything, e.g. because they are too old, are simply not part of the
+// totals.
+//
+// architecture: Endpoint
+type Endpoint struct {
+	retainpb.DRPCGarbageCollectionUnimplementedServer
+
+	log     *zap.Logger
+	history HistoryDB
+}
+
+// NewEndpoint creates a new garbage collection endpoint.
+func NewEndpoint(log *zap.Logger, history HistoryDB) *Endpoint {
+	return &Endpoint{
+		log:     log,
+		history: history,
+	}
+}
+
+// ReportRetainResult records the result of a retain request a node processed.
+func (endpoint *Endpoint) ReportRetainResult(ctx context.Context, req *retainpb.ReportRetainResultRequest) (_ *retainpb.ReportRetainResultResponse, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	peer, err := identity.PeerIdentityFromContext(ctx)
+	if err != nil {
+		return nil, rpcstatus.Error(rpcstatus.Unauthenticated, err.Error())
+	}
+
+	if err := validateReport(req); err != nil {
+		return nil, rpcstatus.Error(rpcstatus.InvalidArgument, err.Error())
+	}
+
+	// only the filters that were sent to the node can be reported, and only
+	// in the ranges they were sent in.
+	sent, err := endpoint.history.GetSentFilter(ctx, peer.ID, req.CreationDate)
+	if err != nil {
+		if ErrFilterNotSent.Has(err) {
+			mon.Meter("retain_reports_unsent").Mark(1)
+			return nil, rpcstatus.Error(rpcstatus.PermissionDenied, "no retain filter was sent to the node for the creation date")
+		}
+		endpoint.log.Error("failed to look up sent retain filter", zap.Stringer("Node ID", peer.ID), zap.Error(err))
+		return nil, rpcstatus.Error(rpcstatus.Internal, err.Error())
+	}
+	if req.RangeBits != uint32(sent.RangeBits) {
+		return nil, rpcstatus.Errorf(rpcstatus.InvalidArgument, "range bits %d don't match the %d bits of the sent filter", req.RangeBits, sent.RangeBits)
+	}
+
+	report := RetainReport{
+		NodeID:       peer.ID,
+		CreationDate: req.CreationDate,
+		Range: retainfilter.Range{
+			Bits:  uint8(req.RangeBits),
+			Index: req.RangeIndex,
+		},
+		PiecesExamined: req.PiecesExamined,
+		PiecesTrashed:  req.PiecesTrashed,
+		BytesReclaimed: req.BytesReclaimed,
+		Duration:       time.Duration(req.DurationMillis) * time.Millisecond,
+		Reported:       time.Now(),
+	}
+	recorded, err := endpoint.history.RecordRetainReport(ctx, report)
+	if err != nil {
+		endpoint.log.Error("failed to record retain report", zap.Stringer("Node ID", peer.ID), zap.Error(err))
+		return nil, rpcstatus.Error(rpcstatus.Internal, err.Error())
+	}
+	if !recorded {
+		// the node reported the same request before, e.g. after a restart.
+		mon.Meter("retain_reports_duplicate").Mark(1)
+		return nil, rpcstatus.Error(rpcstatus.AlreadyExists, "the result was already reported")
+	}
+
+	mon.Meter("retain_reports_received").Mark(1)
+	mon.IntVal("retain_report_pieces_trashed").Observe(report.PiecesTrashed)
+	mon.IntVal("retain_report_bytes_reclaimed").Observe(report.BytesReclaimed)
+	mon.DurationVal("retain_report_duration").Observe(report.Duration)
+	return &retainpb.ReportRetainResultResponse{}, nil
+}
+
+// validateReport checks that the values of the report are plausible.
+func validateReport(req *retainpb.ReportRetainResultRequest) error {
+	switch {
+	case req.CreationDate.IsZero():
+		return Error.New("missing creation date")
+	case req.CreationDate.After(time.Now()):
+		return Error.New("creation date %v is in the future", req.CreationDate)
+	case req.RangeBits > retainfilter.MaxBits:
+		return Error.New("range bits %d exceed the maximum of %d", req.RangeBits, retainfilter.MaxBits)
+	case int64(req.RangeIndex) >= int64(1)<<req.RangeBits:
+		return Error.New("range index %d is out of range for %d bits", req.RangeIndex, req.RangeBits)
+	case req.PiecesExamined < 0 || req.PiecesTrashed < 0 || req.BytesReclaimed < 0 || req.DurationMillis < 0:
+		return Error.New("negative totals")
+	case req.PiecesTrashed > req.PiecesExamined:
+		return Error.New("trashed %d of only %d examined pieces", req.PiecesTrashed, req.PiecesExamined)
+	}
+	return nil
+}
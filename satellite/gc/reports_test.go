@@ -0,0 +1,79 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/errs2"
+	"storj.io/common/identity"
+	"storj.io/common/identity/testidentity"
+	"storj.io/common/rpc/rpcpeer"
+	"storj.io/common/rpc/rpcstatus"
+	"storj.io/common/testcontext"
+	"storj.io/storj/private/retainpb"
+)
+
+func TestEndpointReportRetainResult(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	history := &fakeHistoryDB{}
+	endpoint := NewEndpoint(zaptest.NewLogger(t), history)
+
+	peerContext := func(ident *identity.FullIdentity) context.Context {
+		return rpcpeer.NewContext(ctx, &rpcpeer.Peer{
+			State: tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{ident.Leaf, ident.CA},
+			},
+		})
+	}
+	sent, err := testidentity.NewTestIdentity(ctx)
+	require.NoError(t, err)
+	other, err := testidentity.NewTestIdentity(ctx)
+	require.NoError(t, err)
+
+	creationDate := time.Now().Add(-time.Hour)
+	require.NoError(t, history.RecordSentFilter(ctx, SentFilter{
+		NodeID:       sent.ID,
+		CreationDate: creationDate,
+		RangeBits:    1,
+		Sent:         time.Now(),
+	}))
+
+	report := func(ident *identity.FullIdentity, creationDate time.Time, rangeBits uint32) error {
+		_, err := endpoint.ReportRetainResult(peerContext(ident), &retainpb.ReportRetainResultRequest{
+			CreationDate:   creationDate,
+			RangeBits:      rangeBits,
+			PiecesExamined: 10,
+			PiecesTrashed:  1,
+			BytesReclaimed: 100,
+		})
+		return err
+	}
+
+	// nodes can only report the filters that were sent to them.
+	err = report(other, creationDate, 1)
+	require.True(t, errs2.IsRPC(err, rpcstatus.PermissionDenied), err)
+	err = report(sent, creationDate.Add(-time.Hour), 1)
+	require.True(t, errs2.IsRPC(err, rpcstatus.PermissionDenied), err)
+	err = report(sent, creationDate, 0)
+	require.True(t, errs2.IsRPC(err, rpcstatus.InvalidArgument), err)
+	require.Empty(t, history.reports)
+
+	require.NoError(t, report(sent, creationDate, 1))
+	require.Len(t, history.reports, 1)
+
+	// every filter is only reported once.
+	err = report(sent, creationDate, 1)
+	require.True(t, errs2.IsRPC(err, rpcstatus.AlreadyExists), err)
+	require.Len(t, history.reports, 1)
+}
@@ -0,0 +1,177 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"sync"
+	"time"
+
+	"storj.io/common/storj"
+)
+
+// maxFinishedRuns is the number of finished runs that are remembered.
+const maxFinishedRuns = 16
+
+// RunInfo describes a garbage collection run.
+type RunInfo struct {
+	ID int64 `json:"id"`
+	// Started and Finished are zero until the run has started or finished.
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Error    string    `json:"error,omitempty"`
+	Stats    RunStats  `json:"stats"`
+}
+
+// trackedRun is a run that has been requested or is running.
+type trackedRun struct {
+	info RunInfo
+	done chan struct{}
+	// excluded are the nodes that must not be sent filters in this run.
+	excluded map[storj.NodeID]struct{}
+}
+
+// runs keeps track of the runs of the service.
+type runs struct {
+	mu     sync.Mutex
+	lastID int64
+	// pending is the run that has been triggered but not started yet.
+	pending  *trackedRun
+	active   *trackedRun
+	finished []RunInfo
+
+	// phase, tracker and the node counts are the progress of the active
+	// run. nodesSent and nodesReporting are updated atomically while sending.
+	phase          Phase
+	tracker        *PieceTracker
+	nodesSent      int64
+	nodesTotal     int64
+	nodesReporting int64
+
+	// lastSuccess is when the last successful run finished, and
+	// consecutiveFailures the number of runs that failed since.
+	lastSuccess         time.Time
+	consecutiveFailures int
+}
+
+// next returns a new run with the next ID.
+func (runs *runs) next() *trackedRun {
+	runs.lastID++
+	return &trackedRun{
+		info:     RunInfo{ID: runs.lastID},
+		done:     make(chan struct{}),
+		excluded: map[storj.NodeID]struct{}{},
+	}
+}
+
+// Trigger starts a garbage collection run and returns its ID. The returned
+// channel is closed when the run has finished. When a run has already been
+// triggered or is running, that run is returned instead of starting another.
+func (service *Service) Trigger() (id int64, done <-chan struct{}, err error) {
+	return service.TriggerExcluding(nil)
+}
+
+// TriggerExcluding starts a garbage collection run like Trigger, which does
+// not send filters to the excluded nodes in addition to the configured ones.
+// Nodes can't be excluded from a run that is already running.
+func (service *Service) TriggerExcluding(excluded NodeIDs) (id int64, done <-chan struct{}, err error) {
+	if !service.config.Enabled {
+		return 0, nil, Error.New("garbage collection is disabled")
+	}
+
+	service.runs.mu.Lock()
+	defer service.runs.mu.Unlock()
+
+	if run := service.runs.active; run != nil {
+		if len(excluded) > 0 {
+			return 0, nil, Error.New("can't exclude nodes from run %d, it is already running", run.info.ID)
+		}
+		return run.info.ID, run.done, nil
+	}
+	if run := service.runs.pending; run != nil {
+		for _, id := range excluded {
+			run.excluded[id] = struct{}{}
+		}
+		return run.info.ID, run.done, nil
+	}
+
+	run := service.runs.next()
+	for _, id := range excluded {
+		run.excluded[id] = struct{}{}
+	}
+	service.runs.pending = run
+	// Trigger blocks until the loop picks it up.
+	go service.Loop.Trigger()
+
+	return run.info.ID, run.done, nil
+}
+
+// LookupRun returns information about a run that has been triggered, is
+// running or has recently finished.
+func (service *Service) LookupRun(id int64) (_ RunInfo, ok bool) {
+	service.runs.mu.Lock()
+	defer service.runs.mu.Unlock()
+
+	for _, run := range []*trackedRun{service.runs.pending, service.runs.active} {
+		if run != nil && run.info.ID == id {
+			return run.info, true
+		}
+	}
+	for _, info := range service.runs.finished {
+		if info.ID == id {
+			return info, true
+		}
+	}
+	return RunInfo{}, false
+}
+
+// startRun marks the triggered run, or a new one, as running.
+func (service *Service) startRun() *trackedRun {
+	service.runs.mu.Lock()
+	defer service.runs.mu.Unlock()
+
+	run := service.runs.pending
+	service.runs.pending = nil
+	if run == nil {
+		run = service.runs.next()
+	}
+	run.info.Started = time.Now()
+	for _, id := range service.config.ExcludedNodes {
+		run.excluded[id] = struct{}{}
+	}
+	service.runs.active = run
+	return run
+}
+
+// finishRun records the outcome of the running run and returns its information.
+func (service *Service) finishRun(run *trackedRun, stats RunStats, err error) RunInfo {
+	service.runs.mu.Lock()
+	defer service.runs.mu.Unlock()
+
+	run.info.Finished = time.Now()
+	run.info.Stats = stats
+	if err != nil {
+		run.info.Error = err.Error()
+		service.runs.consecutiveFailures++
+	} else {
+		service.runs.lastSuccess = run.info.Finished
+		service.runs.consecutiveFailures = 0
+		// alerts fire on the age of the last success, so it is only ever
+		// advanced by a run that generated and sent its filters.
+		mon.IntVal("last_success_unix").Observe(run.info.Finished.Unix()) //mon:locked
+	}
+	mon.IntVal("consecutive_failed_runs").Observe(int64(service.runs.consecutiveFailures)) //mon:locked
+
+	service.runs.finished = append(service.runs.finished, run.info)
+	if len(service.runs.finished) > maxFinishedRuns {
+		service.runs.finished = service.runs.finished[1:]
+	}
+	service.runs.active = nil
+	service.runs.phase = PhaseIdle
+	service.runs.tracker = nil
+	service.runs.nodesSent = 0
+	service.runs.nodesTotal = 0
+	service.runs.nodesReporting = 0
+	close(run.done)
+	return run.info
+}
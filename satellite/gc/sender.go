@@ -0,0 +1,359 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"go.uber.org/zap"
+
+	"storj.io/common/context2"
+	"storj.io/common/storj"
+	"storj.io/common/sync2"
+)
+
+// SendState is the progress of sending a retain filter to a node.
+type SendState struct {
+	Attempts     int       `json:"attempts"`
+	FirstAttempt time.Time `json:"first_attempt"`
+	NextAttempt  time.Time `json:"next_attempt"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// SendSummary counts the outcomes of sending retain filters to nodes.
+type SendSummary struct {
+	// Sent is the number of nodes that received their filter.
+	Sent int `json:"sent"`
+	// Retrying is the number of nodes that have not received their filter
+	// yet, because they could not be reached within this call or sending was
+	// interrupted. They are retried by the next call.
+	Retrying int `json:"retrying"`
+	// GaveUp is the number of nodes that could not be sent their filter
+	// within the retry window.
+	GaveUp int `json:"gaveUp"`
+	// OldestBefore and OldestAfter are how long ago the node that waited the
+	// longest for a filter was last sent one, before and after sending. Nodes
+	// that were never sent a filter are not included.
+	OldestBefore time.Duration `json:"oldestBefore"`
+	OldestAfter  time.Duration `json:"oldestAfter"`
+}
+
+// Sender sends retain filters to storage nodes, retrying with exponential
+// backoff over several calls when a node can not be reached.
+//
+// When the sender has a store, the progress for every node is persisted so
+// that retrying continues where it left off after a restart.
+type Sender struct {
+	log    *zap.Logger
+	config Config
+	store  *Store
+	send   func(ctx context.Context, id storj.NodeID, info *RetainInfo) error
+
+	// lastSent is when every node was last sent its filter. It is loaded
+	// from the store, if there is one, when sending the first time.
+	lastSent map[storj.NodeID]time.Time
+	// states is the progress of the nodes that are retried, when there is
+	// no store to persist it.
+	states map[storj.NodeID]SendState
+}
+
+// NewSender creates a new sender that uses send to send a filter to a node.
+// The store is optional.
+func NewSender(log *zap.Logger, config Config, store *Store, send func(ctx context.Context, id storj.NodeID, info *RetainInfo) error) *Sender {
+	return &Sender{
+		log:    log,
+		config: config,
+		store:  store,
+		send:   send,
+	}
+}
+
+// Send sends the retain filters to the nodes, making one attempt per node
+// that is due. Nodes that could not be reached are retried by later calls,
+// with exponential backoff, until the retry window for them has passed, so
+// that an unreachable node does not hold up sending to the others.
+//
+// When the context is canceled no further sends are started, and the sends
+// in flight get the shutdown grace period to finish. The nodes that were not
+// sent their filter are counted as retrying and logged.
+func (sender *Sender) Send(ctx context.Context, infos map[storj.NodeID]*RetainInfo) (summary SendSummary) {
+	defer mon.Task()(&ctx)(nil)
+
+	sendCtx, cancel := withGracePeriod(ctx, sender.config.ShutdownGracePeriod)
+	defer cancel()
+
+	states := sender.loadStates(ctx, infos)
+	sender.loadLastSent(ctx)
+	started := time.Now()
+	summary.OldestBefore = sender.oldest(infos, started)
+
+	// nodes that are still backing off after a failed attempt wait for a
+	// later call.
+	pending := make(map[storj.NodeID]*RetainInfo, len(infos))
+	var due []storj.NodeID
+	for id, info := range infos {
+		pending[id] = info
+		if !states[id].NextAttempt.After(started) {
+			due = append(due, id)
+		}
+	}
+
+	// the nodes that waited the longest are sent their filters first, so
+	// that interrupted runs don't miss the same nodes again.
+	sender.orderByStaleness(due)
+
+	var mu sync.Mutex
+	limiter := sync2.NewLimiter(sender.config.ConcurrentSends)
+	for _, id := range due {
+		id, info := id, infos[id]
+		limiter.Go(ctx, func() {
+			// the limiter may still start sends once the context is
+			// canceled, when a slot is free at the same time.
+			if ctx.Err() != nil {
+				return
+			}
+
+			start := time.Now()
+			err := sender.send(sendCtx, id, info)
+			mon.DurationVal("retain_send_duration", outcomeTag(err)).Observe(time.Since(start))
+
+			mu.Lock()
+			update := sender.record(ctx, id, err, states, pending, &summary)
+			mu.Unlock()
+
+			sender.persist(ctx, id, update)
+		})
+	}
+	limiter.Wait()
+
+	if sender.store == nil {
+		sender.states = states
+	}
+
+	summary.Retrying = len(pending)
+	summary.OldestAfter = sender.oldest(infos, started)
+	if sender.store != nil {
+		if err := sender.store.SaveLastSent(ctx, sender.lastSent); err != nil {
+			sender.log.Warn("error saving last sent times", zap.Error(err))
+		}
+	}
+	if ctx.Err() != nil && len(pending) > 0 {
+		unsent := make(NodeIDs, 0, len(pending))
+		for id := range pending {
+			unsent = append(unsent, id)
+		}
+		sender.log.Warn("stopped sending retain filters because of shutdown",
+			zap.Int("nodes", len(unsent)),
+			zap.Bool("persisted", sender.store != nil),
+			zap.Stringer("not sent", unsent))
+	}
+
+	mon.IntVal("retain_send_sent").Observe(int64(summary.Sent))
+	mon.IntVal("retain_send_retrying").Observe(int64(summary.Retrying))
+	mon.IntVal("retain_send_gave_up").Observe(int64(summary.GaveUp))
+
+	sender.log.Info("finished sending retain filters",
+		zap.Int("sent", summary.Sent),
+		zap.Int("retrying", summary.Retrying),
+		zap.Int("gave up", summary.GaveUp),
+		zap.Duration("oldest before", summary.OldestBefore),
+		zap.Duration("oldest after", summary.OldestAfter))
+
+	return summary
+}
+
+// loadStates returns the progress of sending to the nodes from earlier
+// calls. Without a store it is kept in memory, for the given nodes only.
+func (sender *Sender) loadStates(ctx context.Context, infos map[storj.NodeID]*RetainInfo) map[storj.NodeID]SendState {
+	states := map[storj.NodeID]SendState{}
+	if sender.store == nil {
+		for id, state := range sender.states {
+			if _, ok := infos[id]; ok {
+				states[id] = state
+			}
+		}
+		return states
+	}
+
+	loaded, err := sender.store.LoadStates(ctx)
+	if err != nil {
+		sender.log.Warn("error loading retain send states", zap.Error(err))
+		return states
+	}
+	return loaded
+}
+
+// stalenessBucket is the precision that the nodes are ordered by how long ago
+// they were last sent a filter with. Nodes within the same bucket are sent
+// their filters in random order, so that nodes that are close in the ID space
+// are not all busy at the same time.
+const stalenessBucket = time.Hour
+
+// orderByStaleness orders the nodes by when they were last sent a filter,
+// starting with the nodes that were never sent one.
+func (sender *Sender) orderByStaleness(ids []storj.NodeID) {
+	rand.Shuffle(len(ids), func(i, k int) { ids[i], ids[k] = ids[k], ids[i] })
+
+	bucket := func(id storj.NodeID) int64 {
+		lastSent, ok := sender.lastSent[id]
+		if !ok {
+			return math.MinInt64
+		}
+		return lastSent.Truncate(stalenessBucket).Unix()
+	}
+	sort.SliceStable(ids, func(i, k int) bool {
+		return bucket(ids[i]) < bucket(ids[k])
+	})
+}
+
+// oldest returns how long ago the node that was sent a filter the longest
+// time ago was sent it. Nodes that have been sent a filter since sending
+// started are not waiting for one.
+func (sender *Sender) oldest(infos map[storj.NodeID]*RetainInfo, started time.Time) time.Duration {
+	now := time.Now()
+	var oldest time.Duration
+	for id := range infos {
+		lastSent, ok := sender.lastSent[id]
+		if ok && lastSent.Before(started) && now.Sub(lastSent) > oldest {
+			oldest = now.Sub(lastSent)
+		}
+	}
+	return oldest
+}
+
+// loadLastSent loads when the nodes were last sent a filter from the store,
+// unless they have been loaded already.
+func (sender *Sender) loadLastSent(ctx context.Context) {
+	if sender.lastSent != nil {
+		return
+	}
+	sender.lastSent = map[storj.NodeID]time.Time{}
+	if sender.store == nil {
+		return
+	}
+
+	lastSent, err := sender.store.LoadLastSent(ctx)
+	if err != nil {
+		sender.log.Warn("error loading last sent times", zap.Error(err))
+		return
+	}
+	sender.lastSent = lastSent
+}
+
+// withGracePeriod returns a context with the values of ctx, which is only
+// canceled the grace period after ctx is canceled, or when the returned
+// cancel function is called.
+func withGracePeriod(ctx context.Context, gracePeriod time.Duration) (context.Context, func()) {
+	graceful, cancel := context.WithCancel(context2.WithoutCancellation(ctx))
+	go func() {
+		select {
+		case <-ctx.Done():
+			sync2.Sleep(graceful, gracePeriod)
+			cancel()
+		case <-graceful.Done():
+		}
+	}()
+	return graceful, cancel
+}
+
+// sendUpdate is the change to the persisted progress of a node after an
+// attempt to send its filter.
+type sendUpdate struct {
+	// remove is set when the node is done with, because it was sent its
+	// filter or given up on.
+	remove bool
+	// state is the new state of a node that will be retried.
+	state *SendState
+}
+
+// record updates the state of the node after an attempt to send its filter
+// and returns the change to persist. The caller must hold the lock for the
+// maps and the summary, but not while persisting.
+func (sender *Sender) record(ctx context.Context, id storj.NodeID, sendErr error, states map[storj.NodeID]SendState, pending map[storj.NodeID]*RetainInfo, summary *SendSummary) sendUpdate {
+	if sendErr == nil {
+		delete(pending, id)
+		delete(states, id)
+		sender.lastSent[id] = time.Now()
+		summary.Sent++
+		mon.Meter("retain_send_success").Mark(1)
+		return sendUpdate{remove: true}
+	}
+
+	// the attempt doesn't count when it failed because we are shutting down.
+	if ctx.Err() != nil {
+		return sendUpdate{}
+	}
+	mon.Meter("retain_send_failure").Mark(1)
+
+	now := time.Now()
+	state := states[id]
+	if state.FirstAttempt.IsZero() {
+		state.FirstAttempt = now
+	}
+	state.Attempts++
+	state.LastError = sendErr.Error()
+	state.NextAttempt = now.Add(sender.backoff(state.Attempts))
+
+	if !state.NextAttempt.Before(state.FirstAttempt.Add(sender.config.RetryWindow)) {
+		delete(pending, id)
+		delete(states, id)
+		summary.GaveUp++
+		sender.log.Warn("gave up sending retain info to node",
+			zap.Stringer("Node ID", id),
+			zap.Int("attempts", state.Attempts),
+			zap.Error(sendErr))
+		return sendUpdate{remove: true}
+	}
+
+	states[id] = state
+	sender.log.Debug("error sending retain info to node, retrying",
+		zap.Stringer("Node ID", id),
+		zap.Int("attempts", state.Attempts),
+		zap.Time("next attempt", state.NextAttempt),
+		zap.Error(sendErr))
+	return sendUpdate{state: &state}
+}
+
+// persist applies the update to the store, if there is one.
+func (sender *Sender) persist(ctx context.Context, id storj.NodeID, update sendUpdate) {
+	if sender.store == nil {
+		return
+	}
+	switch {
+	case update.remove:
+		if err := sender.store.Remove(ctx, id); err != nil {
+			sender.log.Warn("error removing retain info", zap.Stringer("Node ID", id), zap.Error(err))
+		}
+	case update.state != nil:
+		if err := sender.store.SaveState(ctx, id, *update.state); err != nil {
+			sender.log.Warn("error saving retain send state", zap.Stringer("Node ID", id), zap.Error(err))
+		}
+	}
+}
+
+// outcomeTag returns the tag for the outcome of sending a filter.
+func outcomeTag(err error) monkit.SeriesTag {
+	if err != nil {
+		return monkit.NewSeriesTag("outcome", "failure")
+	}
+	return monkit.NewSeriesTag("outcome", "success")
+}
+
+// backoff returns how long to wait after the given number of failed attempts.
+func (sender *Sender) backoff(attempts int) time.Duration {
+	backoff := sender.config.RetryBackoff
+	for i := 1; i < attempts && backoff < sender.config.RetryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > sender.config.RetryMaxBackoff {
+		backoff = sender.config.RetryMaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,390 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/storj"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+)
+
+func TestSenderBackoff(t *testing.T) {
+	sender := NewSender(zaptest.NewLogger(t), Config{
+		RetryBackoff:    time.Minute,
+		RetryMaxBackoff: 5 * time.Minute,
+	}, nil, nil)
+
+	for attempts, expected := range []time.Duration{
+		1:   time.Minute,
+		2:   2 * time.Minute,
+		3:   4 * time.Minute,
+		4:   5 * time.Minute,
+		100: 5 * time.Minute,
+	} {
+		if expected == 0 {
+			continue
+		}
+		require.Equal(t, expected, sender.backoff(attempts), attempts)
+	}
+}
+
+func TestSenderRetries(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	flaky, offline, online := testrand.NodeID(), testrand.NodeID(), testrand.NodeID()
+	store := NewStore(ctx.Dir("gc"))
+	require.NoError(t, store.Save(ctx, map[storj.NodeID]*RetainInfo{
+		flaky:   newTestRetainInfo(t, time.Now(), testrand.PieceID()),
+		offline: newTestRetainInfo(t, time.Now(), testrand.PieceID()),
+		online:  newTestRetainInfo(t, time.Now(), testrand.PieceID()),
+	}))
+
+	var mu sync.Mutex
+	attempts := map[storj.NodeID]int{}
+	sender := NewSender(zaptest.NewLogger(t), Config{
+		ConcurrentSends: 2,
+		RetryWindow:     time.Second,
+		RetryBackoff:    time.Millisecond,
+		RetryMaxBackoff: 50 * time.Millisecond,
+	}, store, func(ctx context.Context, id storj.NodeID, info *RetainInfo) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[id]++
+		switch {
+		case id == offline:
+			return Error.New("offline")
+		case id == flaky && attempts[id] < 3:
+			return Error.New("flaky")
+		}
+		return nil
+	})
+
+	before := monkit.Collect(monkit.ScopeNamed("storj.io/storj/satellite/gc"))
+
+	// a single call attempts every node once, and the nodes that failed are
+	// carried over.
+	infos, err := store.Load(ctx)
+	require.NoError(t, err)
+	summary := sender.Send(ctx, infos)
+	require.Equal(t, 1, summary.Sent)
+	require.Equal(t, 2, summary.Retrying)
+	require.Equal(t, map[storj.NodeID]int{flaky: 1, offline: 1, online: 1}, attempts)
+
+	states, err := store.LoadStates(ctx)
+	require.NoError(t, err)
+	require.Len(t, states, 2)
+	require.Equal(t, 1, states[offline].Attempts)
+
+	total := SendSummary{Sent: summary.Sent}
+	for calls := 1; ; calls++ {
+		require.Less(t, calls, 1000, "sending did not finish")
+
+		infos, err := store.Load(ctx)
+		require.NoError(t, err)
+		if len(infos) == 0 {
+			break
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		summary := sender.Send(ctx, infos)
+		total.Sent += summary.Sent
+		total.GaveUp += summary.GaveUp
+	}
+	require.Equal(t, SendSummary{Sent: 2, GaveUp: 1}, total)
+	require.Equal(t, 1, attempts[online])
+	require.Equal(t, 3, attempts[flaky])
+	require.Greater(t, attempts[offline], 3)
+
+	after := monkit.Collect(monkit.ScopeNamed("storj.io/storj/satellite/gc"))
+	const durations = "retain_send_duration,outcome=%s,scope=storj.io/storj/satellite/gc count"
+	success, failure := fmt.Sprintf(durations, "success"), fmt.Sprintf(durations, "failure")
+	require.Equal(t, float64(2), after[success]-before[success])
+	require.Equal(t, float64(attempts[offline]+2), after[failure]-before[failure])
+}
+
+func TestSenderRetriesInMemory(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	offline, online := testrand.NodeID(), testrand.NodeID()
+	infos := map[storj.NodeID]*RetainInfo{
+		offline: newTestRetainInfo(t, time.Now(), testrand.PieceID()),
+		online:  newTestRetainInfo(t, time.Now(), testrand.PieceID()),
+	}
+
+	attempts := map[storj.NodeID]int{}
+	sender := NewSender(zaptest.NewLogger(t), Config{
+		ConcurrentSends: 1,
+		RetryWindow:     time.Hour,
+		RetryBackoff:    time.Hour,
+		RetryMaxBackoff: time.Hour,
+	}, nil, func(ctx context.Context, id storj.NodeID, info *RetainInfo) error {
+		attempts[id]++
+		if id == offline {
+			return Error.New("offline")
+		}
+		return nil
+	})
+
+	// the unreachable node doesn't hold up the call.
+	summary := sender.Send(ctx, infos)
+	require.Equal(t, 1, summary.Sent)
+	require.Equal(t, 1, summary.Retrying)
+
+	// the node is still backing off in the next call, while the others are
+	// sent their new filters.
+	summary = sender.Send(ctx, infos)
+	require.Equal(t, 1, summary.Sent)
+	require.Equal(t, 1, summary.Retrying)
+	require.Zero(t, summary.GaveUp)
+	require.Equal(t, map[storj.NodeID]int{offline: 1, online: 2}, attempts)
+}
+
+func TestSenderNoRetryWindow(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	id := testrand.NodeID()
+	store := NewStore(ctx.Dir("gc"))
+	require.NoError(t, store.Save(ctx, map[storj.NodeID]*RetainInfo{
+		id: newTestRetainInfo(t, time.Now(), testrand.PieceID()),
+	}))
+
+	attempts := 0
+	sender := NewSender(zaptest.NewLogger(t), Config{
+		ConcurrentSends: 1,
+		RetryBackoff:    time.Hour,
+		RetryMaxBackoff: time.Hour,
+	}, store, func(ctx context.Context, id storj.NodeID, info *RetainInfo) error {
+		attempts++
+		return Error.New("offline")
+	})
+
+	infos, err := store.Load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, SendSummary{GaveUp: 1}, sender.Send(ctx, infos))
+	require.Equal(t, 1, attempts)
+
+	// the filter is not kept around after giving up
+	infos, err = store.Load(ctx)
+	require.NoError(t, err)
+	require.Empty(t, infos)
+}
+
+func TestSenderConcurrent(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	const concurrency = 4
+	infos := map[storj.NodeID]*RetainInfo{}
+	for i := 0; i < 3*concurrency; i++ {
+		infos[testrand.NodeID()] = newTestRetainInfo(t, time.Now(), testrand.PieceID())
+	}
+	hung := testrand.NodeID()
+	infos[hung] = newTestRetainInfo(t, time.Now(), testrand.PieceID())
+
+	// every send waits until the pool is full, so the sends only finish when
+	// they run concurrently. the hung node only returns once it times out.
+	arrived := make(chan struct{}, len(infos))
+	full := make(chan struct{})
+	var fullOnce sync.Once
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	sender := NewSender(zaptest.NewLogger(t), Config{
+		ConcurrentSends: concurrency,
+		RetryBackoff:    time.Hour,
+		RetryMaxBackoff: time.Hour,
+	}, nil, func(ctx context.Context, id storj.NodeID, info *RetainInfo) error {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		if inFlight == concurrency {
+			fullOnce.Do(func() { close(full) })
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		arrived <- struct{}{}
+		select {
+		case <-full:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if id == hung {
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	summary := sender.Send(ctx, infos)
+	require.Equal(t, SendSummary{Sent: len(infos) - 1, GaveUp: 1}, summary)
+	require.Equal(t, concurrency, maxInFlight)
+	require.Len(t, arrived, len(infos))
+}
+
+func TestSenderShutdown(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	failing := testrand.NodeID()
+	infos := map[storj.NodeID]*RetainInfo{
+		failing: newTestRetainInfo(t, time.Now(), testrand.PieceID()),
+	}
+	for i := 0; i < 4; i++ {
+		infos[testrand.NodeID()] = newTestRetainInfo(t, time.Now(), testrand.PieceID())
+	}
+
+	serviceCtx, shutdown := context.WithCancel(ctx)
+	defer shutdown()
+
+	// the first two nodes that don't fail are still being sent their filters
+	// when shutting down. one of them finishes within the grace period, the
+	// other one is canceled when it passes.
+	var mu sync.Mutex
+	var inFlight, finished, startedAfterShutdown int
+	canceled := make(chan error, 1)
+	sender := NewSender(zaptest.NewLogger(t), Config{
+		ConcurrentSends:     2,
+		RetryBackoff:        time.Hour,
+		RetryMaxBackoff:     time.Hour,
+		ShutdownGracePeriod: 100 * time.Millisecond,
+	}, nil, func(ctx context.Context, id storj.NodeID, info *RetainInfo) error {
+		if id == failing {
+			return Error.New("failing")
+		}
+
+		mu.Lock()
+		if serviceCtx.Err() != nil {
+			startedAfterShutdown++
+		}
+		inFlight++
+		if inFlight == 2 {
+			shutdown()
+		}
+		mu.Unlock()
+
+		<-serviceCtx.Done()
+
+		mu.Lock()
+		finished++
+		first := finished == 1
+		mu.Unlock()
+		if first {
+			return nil
+		}
+
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return ctx.Err()
+	})
+
+	summary := sender.Send(serviceCtx, infos)
+	require.Equal(t, 1, summary.Sent)
+	require.LessOrEqual(t, summary.GaveUp, 1)
+	require.Equal(t, len(infos), summary.Sent+summary.GaveUp+summary.Retrying)
+	require.Zero(t, startedAfterShutdown)
+	require.Equal(t, context.Canceled, <-canceled)
+}
+
+func TestSenderOrderByStaleness(t *testing.T) {
+	now := time.Now().Truncate(stalenessBucket)
+
+	neverSent := []storj.NodeID{testrand.NodeID(), testrand.NodeID()}
+	weekAgo := []storj.NodeID{testrand.NodeID(), testrand.NodeID(), testrand.NodeID()}
+	dayAgo := []storj.NodeID{testrand.NodeID()}
+	recent := []storj.NodeID{testrand.NodeID(), testrand.NodeID()}
+
+	sender := NewSender(zaptest.NewLogger(t), Config{}, nil, nil)
+	sender.lastSent = map[storj.NodeID]time.Time{}
+	for i, id := range weekAgo {
+		// nodes sent within the same bucket are equally stale.
+		sender.lastSent[id] = now.Add(-7*24*time.Hour + time.Duration(i)*time.Minute)
+	}
+	for _, id := range dayAgo {
+		sender.lastSent[id] = now.Add(-24 * time.Hour)
+	}
+	for _, id := range recent {
+		sender.lastSent[id] = now.Add(time.Minute)
+	}
+
+	var all []storj.NodeID
+	for _, group := range [][]storj.NodeID{recent, dayAgo, weekAgo, neverSent} {
+		all = append(all, group...)
+	}
+
+	orders := map[storj.NodeID]bool{}
+	for i := 0; i < 100; i++ {
+		ids := append([]storj.NodeID{}, all...)
+		sender.orderByStaleness(ids)
+
+		require.ElementsMatch(t, neverSent, ids[:2])
+		require.ElementsMatch(t, weekAgo, ids[2:5])
+		require.Equal(t, dayAgo, ids[5:6])
+		require.ElementsMatch(t, recent, ids[6:])
+
+		orders[ids[2]] = true
+	}
+	// the order within a bucket is random.
+	require.Len(t, orders, len(weekAgo))
+
+	// the summary reports the oldest node that waits for a filter.
+	infos := map[storj.NodeID]*RetainInfo{}
+	for _, id := range all {
+		infos[id] = newTestRetainInfo(t, time.Now(), testrand.PieceID())
+	}
+	require.InDelta(t, time.Since(sender.lastSent[weekAgo[0]]), sender.oldest(infos, time.Now()), float64(time.Minute))
+}
+
+func TestSenderOldestAfter(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	sent, failing := testrand.NodeID(), testrand.NodeID()
+	infos := map[storj.NodeID]*RetainInfo{
+		sent:    newTestRetainInfo(t, time.Now(), testrand.PieceID()),
+		failing: newTestRetainInfo(t, time.Now(), testrand.PieceID()),
+	}
+
+	store := NewStore(ctx.Dir("gc"))
+	require.NoError(t, store.SaveLastSent(ctx, map[storj.NodeID]time.Time{
+		sent:    time.Now().Add(-48 * time.Hour),
+		failing: time.Now().Add(-24 * time.Hour),
+	}))
+
+	sender := NewSender(zaptest.NewLogger(t), Config{
+		ConcurrentSends: 2,
+		RetryBackoff:    time.Hour,
+		RetryMaxBackoff: time.Hour,
+	}, store, func(ctx context.Context, id storj.NodeID, info *RetainInfo) error {
+		if id == failing {
+			return Error.New("failing")
+		}
+		return nil
+	})
+
+	summary := sender.Send(ctx, infos)
+	require.Equal(t, 1, summary.Sent)
+	require.InDelta(t, 48*time.Hour, summary.OldestBefore, float64(time.Minute))
+	require.InDelta(t, 24*time.Hour, summary.OldestAfter, float64(time.Minute))
+
+	// the time the node was sent its filter is persisted.
+	lastSent, err := store.LoadLastSent(ctx)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), lastSent[sent], time.Minute)
+}
@@ -5,6 +5,8 @@ package gc
 
 import (
 	"context"
+	"math/bits"
+	"sync/atomic"
 	"time"
 
 	"github.com/spacemonkeygo/monkit/v3"
@@ -12,10 +14,14 @@ import (
 	"go.uber.org/zap"
 
 	"storj.io/common/bloomfilter"
+	"storj.io/common/context2"
+	"storj.io/common/memory"
 	"storj.io/common/pb"
 	"storj.io/common/rpc"
 	"storj.io/common/storj"
 	"storj.io/common/sync2"
+	"storj.io/private/version"
+	"storj.io/storj/private/retainfilter"
 	"storj.io/storj/satellite/metabase/segmentloop"
 	"storj.io/storj/satellite/overlay"
 	"storj.io/uplink/private/piecestore"
@@ -24,7 +30,14 @@ import (
 var (
 	// Error defines the gc service errors class.
 	Error = errs.Class("gc")
-	mon   = monkit.Package()
+	// ErrMemoryLimit is returned when the bloom filters exceed the configured memory limit.
+	ErrMemoryLimit = errs.Class("gc memory limit")
+	// ErrNoRuns is returned when no garbage collection run has been recorded.
+	ErrNoRuns = errs.Class("no garbage collection runs")
+	// ErrFilterNotSent is returned when no retain filter was recorded as sent
+	// to a node.
+	ErrFilterNotSent = errs.Class("retain filter not sent")
+	mon              = monkit.Package()
 )
 
 // Config contains configurable values for garbage collection.
@@ -33,10 +46,155 @@ type Config struct {
 	Enabled  bool          `help:"set if garbage collection is enabled or not" releaseDefault:"true" devDefault:"true"`
 
 	// value for InitialPieces currently based on average pieces per node
-	InitialPieces     int           `help:"the initial number of pieces expected for a storage node to have, used for creating a filter" releaseDefault:"400000" devDefault:"10"`
-	FalsePositiveRate float64       `help:"the false positive rate used for creating a garbage collection bloom filter" releaseDefault:"0.1" devDefault:"0.1"`
-	ConcurrentSends   int           `help:"the number of nodes to concurrently send garbage collection bloom filters to" releaseDefault:"1" devDefault:"1"`
-	RetainSendTimeout time.Duration `help:"the amount of time to allow a node to handle a retain request" default:"1m"`
+	InitialPieces     int     `help:"the initial number of pieces expected for a storage node to have, used for creating a filter" releaseDefault:"400000" devDefault:"10"`
+	FalsePositiveRate float64 `help:"the false positive rate used for creating a garbage collection bloom filter" releaseDefault:"0.1" devDefault:"0.1"`
+	// MinimumPieces keeps sending filters to nodes with a handful of pieces,
+	// which is mostly overhead, from creating noise on test networks.
+	MinimumPieces int `help:"the minimum number of pieces counted for a storage node to send it a garbage collection bloom filter" default:"0"`
+	// MaxBloomFilterSize must stay under the message size limit for RPC.
+	MaxBloomFilterSize   memory.Size   `help:"the maximum size of a garbage collection bloom filter; nodes with fewer pieces get a lower false positive rate" default:"2.0 MiB"`
+	SplitMinimumVersion  string        `help:"the minimum storage node version that accepts bloom filters split across several retain requests. filters that would exceed the maximum size are split for such nodes, never when empty" default:""`
+	ReportMinimumVersion string        `help:"the minimum storage node version that is asked to report the results of retain requests back to the satellite. empty to ask no node" default:""`
+	RequireTrash         bool          `help:"set to require every node to only move the pieces that are not retained to the trash and to acknowledge it with a report. nodes below the report minimum version are skipped" default:"false"`
+	VerifySamplePieces   int           `help:"the number of randomly sampled pieces that the generated bloom filters are checked to retain before sending them, zero to skip the check" default:"1000"`
+	MaxMemory            memory.Size   `help:"the maximum memory the bloom filters of all nodes may use together, zero for no limit" default:"0 B"`
+	DetectCopies         bool          `help:"set to count the pieces of segments that share their root piece ID with another segment, i.e. server-side copies, only once. the root piece IDs of all remote segments are remembered, which grows with the number of segments and counts towards the maximum memory" default:"false"`
+	ConcurrentSends      int           `help:"the number of nodes to concurrently send garbage collection bloom filters to" default:"10"`
+	RetainSendTimeout    time.Duration `help:"the amount of time to allow dialing a node and sending it a retain request, zero for no limit" default:"1m"`
+	ShutdownGracePeriod  time.Duration `help:"the amount of time to let retain requests in flight finish when shutting down" default:"30s"`
+	RateLimit            float64       `help:"the maximum number of segments per second that are processed for garbage collection, to spread the database load over a longer run. zero for no limit" default:"0"`
+
+	GenerateFilters bool   `help:"set if garbage collection bloom filters should be generated from the metabase" default:"true"`
+	SendFilters     bool   `help:"set if garbage collection bloom filters should be sent to storage nodes" default:"true"`
+	PersistDir      string `help:"directory to keep generated bloom filters in until they are sent. required to only generate or only send filters" default:""`
+	// PieceCountsMaxAge only applies to piece counts in the persist directory.
+	PieceCountsMaxAge time.Duration `help:"the maximum age of the piece counts in the persist directory for sizing filters, zero for no limit" default:"720h"`
+	DryRun            bool          `help:"set to only generate bloom filters and log statistics about them, without sending them to storage nodes" default:"false"`
+	// SnapshotCreated is used as the creation date of the filters, so that
+	// nodes keep the pieces that were uploaded after the snapshot.
+	SnapshotDatabaseURL string `help:"the database connection string of a metabase snapshot, e.g. a restored backup or a follower, to generate bloom filters from instead of the metabase" default:""`
+	SnapshotCreated     string `help:"when the metabase snapshot was taken, in RFC3339 format. required when generating bloom filters from a snapshot" default:""`
+	// TargetNodesOnly runs leave the persisted filters of other nodes alone.
+	TargetNodesOnly bool    `help:"set to only generate and send bloom filters for the target nodes" default:"false"`
+	TargetNodes     NodeIDs `help:"comma-separated list of node IDs to run garbage collection for when only running for target nodes" default:""`
+	// ExcludedNodes keep their filters in the persist directory for later.
+	ExcludedNodes NodeIDs `help:"comma-separated list of node IDs to not send bloom filters to, e.g. while their storage is under maintenance" default:""`
+
+	RetryWindow     time.Duration `help:"how long to keep retrying to send a bloom filter to a node that could not be reached" releaseDefault:"48h" devDefault:"10m"`
+	RetryBackoff    time.Duration `help:"the time to wait before retrying to send a bloom filter to a node the first time, doubled for every further retry" releaseDefault:"5m" devDefault:"1s"`
+	RetryMaxBackoff time.Duration `help:"the maximum time to wait between retrying to send a bloom filter to a node" releaseDefault:"4h" devDefault:"1m"`
+	SkipOfflineFor  time.Duration `help:"skip sending bloom filters to nodes that have not been contacted successfully for this long, zero to never skip" default:"168h"`
+
+	DeletionRatioWarning float64 `help:"log nodes whose bloom filter would delete more than this ratio of the pieces counted in the previous iteration, zero to never log" default:"0.3"`
+	MaxDeletionRatio     float64 `help:"skip sending bloom filters to nodes whose filter would delete more than this ratio of the pieces counted in the previous iteration, zero to never skip. skipped nodes need a manual review" default:"0"`
+
+	AdminAddress string `help:"http listening address for manually triggering garbage collection, disabled when empty. requests are authorized with the admin authorization token" default:""`
+}
+
+// minInterval is the minimum time between garbage collection runs.
+const minInterval = time.Second
+
+// Validate checks that the configuration can be used for garbage collection.
+// The errors name the flags that need to be fixed.
+func (config *Config) Validate() error {
+	var errlist errs.Group
+	if config.Interval < minInterval {
+		errlist.Add(Error.New("garbage-collection.interval %v must be at least %v", config.Interval, minInterval))
+	}
+	if config.InitialPieces <= 0 {
+		errlist.Add(Error.New("garbage-collection.initial-pieces %d must be greater than 0", config.InitialPieces))
+	}
+	if config.MinimumPieces < 0 {
+		errlist.Add(Error.New("garbage-collection.minimum-pieces %d must not be negative", config.MinimumPieces))
+	}
+	if config.FalsePositiveRate <= 0 || config.FalsePositiveRate >= 1 {
+		errlist.Add(Error.New("garbage-collection.false-positive-rate %v must be between 0 and 1", config.FalsePositiveRate))
+	}
+	if config.MaxBloomFilterSize < 0 {
+		errlist.Add(Error.New("garbage-collection.max-bloom-filter-size %v must not be negative", config.MaxBloomFilterSize))
+	}
+	if config.VerifySamplePieces < 0 {
+		errlist.Add(Error.New("garbage-collection.verify-sample-pieces %d must not be negative", config.VerifySamplePieces))
+	}
+	if config.MaxMemory < 0 {
+		errlist.Add(Error.New("garbage-collection.max-memory %v must not be negative", config.MaxMemory))
+	}
+	if config.RateLimit < 0 {
+		errlist.Add(Error.New("garbage-collection.rate-limit %v must not be negative", config.RateLimit))
+	}
+	if config.ConcurrentSends <= 0 {
+		errlist.Add(Error.New("garbage-collection.concurrent-sends %d must be greater than 0", config.ConcurrentSends))
+	}
+	if config.RetainSendTimeout < 0 {
+		errlist.Add(Error.New("garbage-collection.retain-send-timeout %v must not be negative", config.RetainSendTimeout))
+	}
+	if config.ShutdownGracePeriod < 0 {
+		errlist.Add(Error.New("garbage-collection.shutdown-grace-period %v must not be negative", config.ShutdownGracePeriod))
+	}
+	if config.RetryBackoff <= 0 {
+		errlist.Add(Error.New("garbage-collection.retry-backoff %v must be greater than 0", config.RetryBackoff))
+	}
+	if config.RetryMaxBackoff < config.RetryBackoff {
+		errlist.Add(Error.New("garbage-collection.retry-max-backoff %v must be at least garbage-collection.retry-backoff %v", config.RetryMaxBackoff, config.RetryBackoff))
+	}
+	if config.DeletionRatioWarning < 0 || config.DeletionRatioWarning > 1 {
+		errlist.Add(Error.New("garbage-collection.deletion-ratio-warning %v must be between 0 and 1", config.DeletionRatioWarning))
+	}
+	if config.MaxDeletionRatio < 0 || config.MaxDeletionRatio > 1 {
+		errlist.Add(Error.New("garbage-collection.max-deletion-ratio %v must be between 0 and 1", config.MaxDeletionRatio))
+	}
+
+	if config.PersistDir == "" && !(config.GenerateFilters && config.SendFilters) {
+		errlist.Add(Error.New("only generating or only sending filters requires a persist directory, set garbage-collection.persist-dir"))
+	}
+	if config.DryRun && !config.GenerateFilters {
+		errlist.Add(Error.New("dry run requires generating filters, set garbage-collection.generate-filters"))
+	}
+	if config.TargetNodesOnly {
+		if len(config.TargetNodes) == 0 {
+			errlist.Add(Error.New("running for target nodes only requires target nodes, set garbage-collection.target-nodes"))
+		}
+		// the filters are not persisted, so they must be sent by this process.
+		if !(config.GenerateFilters && config.SendFilters) {
+			errlist.Add(Error.New("running for target nodes only requires generating and sending filters"))
+		}
+	}
+	if config.SplitMinimumVersion != "" {
+		if _, err := version.NewSemVer(config.SplitMinimumVersion); err != nil {
+			errlist.Add(Error.New("garbage-collection.split-minimum-version is invalid: %w", err))
+		}
+	}
+	if config.ReportMinimumVersion != "" {
+		if _, err := version.NewSemVer(config.ReportMinimumVersion); err != nil {
+			errlist.Add(Error.New("garbage-collection.report-minimum-version is invalid: %w", err))
+		}
+	}
+	if config.RequireTrash && config.ReportMinimumVersion == "" {
+		errlist.Add(Error.New("garbage-collection.require-trash requires garbage-collection.report-minimum-version"))
+	}
+	if config.SnapshotDatabaseURL != "" || config.SnapshotCreated != "" {
+		created, err := config.snapshotCreated()
+		switch {
+		case config.SnapshotDatabaseURL == "":
+			errlist.Add(Error.New("garbage-collection.snapshot-created requires garbage-collection.snapshot-database-url"))
+		case config.SnapshotCreated == "":
+			errlist.Add(Error.New("generating filters from a snapshot requires garbage-collection.snapshot-created"))
+		case err != nil:
+			errlist.Add(Error.New("garbage-collection.snapshot-created is invalid: %w", err))
+		case created.After(time.Now()):
+			errlist.Add(Error.New("garbage-collection.snapshot-created %v must not be in the future", created))
+		}
+	}
+	return errlist.Err()
+}
+
+// snapshotCreated returns when the metabase snapshot was taken, or the zero
+// time when the filters are not generated from a snapshot.
+func (config *Config) snapshotCreated() (time.Time, error) {
+	if config.SnapshotCreated == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, config.SnapshotCreated)
 }
 
 // Service implements the garbage collection service.
@@ -49,26 +207,124 @@ type Service struct {
 
 	dialer      rpc.Dialer
 	overlay     overlay.DB
+	history     HistoryDB
 	segmentLoop *segmentloop.Service
+	store       *Store
+	sender      *Sender
+	runs        runs
+
+	// dossiers are the nodes that were looked up before sending in the
+	// current run, so that sending doesn't look them up again.
+	dossiers map[storj.NodeID]*overlay.NodeDossier
+
+	// sendRetain sends the retain filter to the node. It is a field so that
+	// tests can replace it.
+	sendRetain func(ctx context.Context, id storj.NodeID, info *RetainInfo) error
 }
 
 // RetainInfo contains info needed for a storage node to retain important data and delete garbage data.
 type RetainInfo struct {
-	Filter       *bloomfilter.Filter
+	Filter *bloomfilter.Filter
+	// Parts are set instead of Filter when the pieces of the node are split
+	// by the first bits of their IDs, see retainfilter.Range. Parts[i] is the
+	// filter for the range with index i.
+	Parts        []*bloomfilter.Filter
 	CreationDate time.Time
 	Count        int
 }
 
-// NewService creates a new instance of the gc service.
-func NewService(log *zap.Logger, config Config, dialer rpc.Dialer, overlay overlay.DB, loop *segmentloop.Service) *Service {
-	return &Service{
+// Size returns the size of the filter, or of all parts, in bytes.
+func (info *RetainInfo) Size() int64 {
+	if len(info.Parts) == 0 {
+		return info.Filter.Size()
+	}
+	var size int64
+	for _, part := range info.Parts {
+		size += part.Size()
+	}
+	return size
+}
+
+// splitBits returns the number of piece ID bits that select the range of a
+// part, or zero when the filter is not split.
+func (info *RetainInfo) splitBits() uint8 {
+	return uint8(bits.TrailingZeros(uint(len(info.Parts))))
+}
+
+// add adds the piece to the filter that covers it.
+func (info *RetainInfo) add(pieceID storj.PieceID) {
+	if len(info.Parts) == 0 {
+		info.Filter.Add(pieceID)
+		return
+	}
+	info.Parts[retainfilter.RangeOf(info.splitBits(), pieceID).Index].Add(pieceID)
+}
+
+// Contains returns whether the filter that covers the piece contains it. As
+// with any bloom filter, it may return true for pieces that were never added.
+func (info *RetainInfo) Contains(pieceID storj.PieceID) bool {
+	if len(info.Parts) == 0 {
+		return info.Filter.Contains(pieceID)
+	}
+	return info.Parts[retainfilter.RangeOf(info.splitBits(), pieceID).Index].Contains(pieceID)
+}
+
+// requests returns the retain requests to send to a node. A split filter is
+// sent as a request for every part when the node supports it and as the union
+// of the parts otherwise.
+func (info *RetainInfo) requests(splitSupported bool, flags retainfilter.Flags) ([]*pb.RetainRequest, error) {
+	if len(info.Parts) == 0 {
+		return []*pb.RetainRequest{{
+			CreationDate: info.CreationDate,
+			Filter:       retainfilter.EncodeWithFlags(retainfilter.Range{}, flags, info.Filter),
+		}}, nil
+	}
+
+	if !splitSupported {
+		union, err := retainfilter.Union(info.Parts)
+		if err != nil {
+			return nil, err
+		}
+		return []*pb.RetainRequest{{
+			CreationDate: info.CreationDate,
+			Filter:       retainfilter.EncodeWithFlags(retainfilter.Range{}, flags, union),
+		}}, nil
+	}
+
+	requests := make([]*pb.RetainRequest, 0, len(info.Parts))
+	for index, part := range info.Parts {
+		requests = append(requests, &pb.RetainRequest{
+			CreationDate: info.CreationDate,
+			Filter:       retainfilter.EncodeWithFlags(retainfilter.Range{Bits: info.splitBits(), Index: uint32(index)}, flags, part),
+		})
+	}
+	return requests, nil
+}
+
+// NewService creates a new instance of the gc service. The history is optional.
+func NewService(log *zap.Logger, config Config, dialer rpc.Dialer, overlay overlay.DB, history HistoryDB, loop *segmentloop.Service) *Service {
+	service := &Service{
 		log:         log,
 		config:      config,
 		Loop:        sync2.NewCycle(config.Interval),
 		dialer:      dialer,
 		overlay:     overlay,
+		history:     history,
 		segmentLoop: loop,
 	}
+	if config.PersistDir != "" {
+		service.store = NewStore(config.PersistDir)
+	}
+	service.sendRetain = service.sendRetainRequest
+	service.sender = NewSender(log.Named("sender"), config, service.store,
+		func(ctx context.Context, id storj.NodeID, info *RetainInfo) error {
+			err := service.sendRetain(ctx, id, info)
+			if err == nil {
+				atomic.AddInt64(&service.runs.nodesSent, 1)
+			}
+			return err
+		})
+	return service
 }
 
 // Run starts the gc loop service.
@@ -79,6 +335,77 @@ func (service *Service) Run(ctx context.Context) (err error) {
 		return nil
 	}
 
+	if err := service.config.Validate(); err != nil {
+		return err
+	}
+
+	lastPieceCounts := service.loadPieceCounts(ctx)
+
+	return service.Loop.Run(ctx, func(ctx context.Context) (err error) {
+		defer mon.Task()(&ctx)(&err)
+
+		run := service.startRun()
+		recordID := service.recordStart(ctx, run.info.Started)
+		stats, err := service.runOnce(ctx, lastPieceCounts, run.excluded)
+		if err == nil && ctx.Err() != nil {
+			err = Error.New("interrupted by shutdown: %w", ctx.Err())
+		}
+		// the outcome of a run that is interrupted by shutdown is still recorded.
+		service.recordFinish(context2.WithoutCancellation(ctx), recordID, service.finishRun(run, stats, err))
+		if err != nil {
+			if ctx.Err() != nil {
+				service.log.Info("garbage collection run interrupted by shutdown", zap.Int64("run", run.info.ID), zap.Error(err))
+				return nil
+			}
+			service.log.Error("garbage collection run failed", zap.Int64("run", run.info.ID), zap.Error(err))
+			return nil
+		}
+
+		message := "garbage collection run finished"
+		if service.config.DryRun {
+			message = "DRY RUN: garbage collection run finished without sending retain filters"
+		}
+		service.log.Info(message,
+			zap.Int64("run", run.info.ID),
+			zap.Int64("segments", stats.Segments),
+			zap.Int64("inline segments", stats.InlineSegments),
+			zap.Int64("copied segments", stats.CopiedSegments),
+			zap.Int64("pieces", stats.Pieces),
+			zap.Int("nodes", stats.Nodes),
+			zap.Int("split nodes", stats.SplitNodes),
+			zap.Int64("filter bytes", stats.FilterBytes),
+			zap.Int64("estimated deletions", stats.EstimatedDeletions),
+			zap.Int("needs review", len(stats.NeedsReview)),
+			zap.Duration("generate duration", stats.GenerateDuration),
+			zap.Int("sent", stats.Sent.Sent),
+			zap.Int("retrying", stats.Sent.Retrying),
+			zap.Int("gave up", stats.Sent.GaveUp),
+			zap.Any("skipped", stats.Skipped),
+			zap.Stringer("excluded", NodeIDs(stats.Excluded)),
+			zap.Duration("send duration", stats.SendDuration))
+
+		return nil
+	})
+}
+
+// loadPieceCounts returns the piece counts of the previous generation. They
+// are loaded from the store, when there is one with piece counts that are not
+// too old, and otherwise from the overlay.
+func (service *Service) loadPieceCounts(ctx context.Context) map[storj.NodeID]int {
+	if service.store != nil {
+		counts, counted, err := service.store.LoadPieceCounts(ctx)
+		switch {
+		case err != nil:
+			service.log.Error("error loading last piece counts", zap.Error(err))
+		case counts == nil:
+		case service.config.PieceCountsMaxAge > 0 && time.Since(counted) > service.config.PieceCountsMaxAge:
+			service.log.Info("ignoring last piece counts, they are too old", zap.Time("counted", counted))
+			return make(map[storj.NodeID]int)
+		default:
+			return counts
+		}
+	}
+
 	// load last piece counts from overlay db
 	lastPieceCounts, err := service.overlay.AllPieceCounts(ctx)
 	if err != nil {
@@ -87,62 +414,230 @@ func (service *Service) Run(ctx context.Context) (err error) {
 	if lastPieceCounts == nil {
 		lastPieceCounts = make(map[storj.NodeID]int)
 	}
+	return lastPieceCounts
+}
 
-	return service.Loop.Run(ctx, func(ctx context.Context) (err error) {
-		defer mon.Task()(&ctx)(&err)
-
-		pieceTracker := NewPieceTracker(service.log.Named("gc observer"), service.config, lastPieceCounts)
+// runOnce generates and sends the retain filters as configured.
+func (service *Service) runOnce(ctx context.Context, lastPieceCounts map[storj.NodeID]int, excluded map[storj.NodeID]struct{}) (stats RunStats, err error) {
+	defer mon.Task()(&ctx)(&err)
 
-		// collect things to retain
-		err = service.segmentLoop.Join(ctx, pieceTracker)
+	var retainInfos map[storj.NodeID]*RetainInfo
+	if service.config.GenerateFilters {
+		start := time.Now()
+		retainInfos, err = service.generate(ctx, lastPieceCounts, &stats)
 		if err != nil {
-			service.log.Error("error joining metainfoloop", zap.Error(err))
-			return nil
+			return stats, Error.New("error generating retain filters: %w", err)
 		}
 
-		// save piece counts in memory for next iteration
-		for id := range lastPieceCounts {
-			delete(lastPieceCounts, id)
+		// in a dry run the filters must not be sent by anyone, and a run for
+		// target nodes sends its filters itself.
+		if service.store != nil && !service.config.DryRun && !service.config.TargetNodesOnly {
+			if err := service.store.Save(ctx, retainInfos); err != nil {
+				return stats, Error.New("error saving retain filters: %w", err)
+			}
+		}
+		stats.GenerateDuration = time.Since(start)
+		mon.DurationVal("generate_duration").Observe(stats.GenerateDuration)
+	}
+
+	if service.config.SendFilters && !service.config.DryRun {
+		start := time.Now()
+		// when only sending, the filters come from a previous generation.
+		if service.store != nil && !service.config.GenerateFilters {
+			retainInfos, err = service.store.Load(ctx)
+			if err != nil {
+				return stats, Error.New("error loading retain filters: %w", err)
+			}
+		}
+
+		// skipped nodes keep their persisted filter in case they return.
+		retainInfos, service.dossiers, stats.Skipped = skipUnreachable(ctx, service.log, service.overlay, service.config.SkipOfflineFor, retainInfos)
+		retainInfos, stats.Excluded = skipExcludedNodes(service.log, excluded, retainInfos)
+		if len(stats.Excluded) > 0 {
+			stats.Skipped[skipExcluded] = len(stats.Excluded)
+		}
+		var small int
+		retainInfos, small = skipSmallNodes(service.log, service.config.MinimumPieces, retainInfos)
+		if small > 0 {
+			stats.Skipped[skipSmall] = small
 		}
-		for id, info := range pieceTracker.RetainInfos {
-			lastPieceCounts[id] = info.Count
+		if service.config.RequireTrash {
+			var outdated int
+			retainInfos, outdated = skipOutdatedNodes(service.log, service.config.ReportMinimumVersion, service.dossiers, retainInfos)
+			if outdated > 0 {
+				stats.Skipped[skipOutdated] = outdated
+			}
 		}
 
-		// save piece counts to db for next satellite restart
-		err = service.overlay.UpdatePieceCounts(ctx, lastPieceCounts)
+		service.startSending(len(retainInfos))
+		for _, info := range retainInfos {
+			stats.CreationDate = info.CreationDate
+			break
+		}
+
+		stats.Sent = service.send(ctx, retainInfos)
+		stats.NodesReporting = int(atomic.LoadInt64(&service.runs.nodesReporting))
+		stats.SendDuration = time.Since(start)
+		mon.DurationVal("send_duration").Observe(stats.SendDuration)
+	}
+
+	return stats, nil
+}
+
+// RunStats summarizes a garbage collection run.
+type RunStats struct {
+	Segments int64 `json:"segments"`
+	// InlineSegments and CopiedSegments are the segments that have no pieces
+	// of their own to retain. They are included in Segments.
+	InlineSegments int64 `json:"inlineSegments"`
+	CopiedSegments int64 `json:"copiedSegments"`
+	Pieces         int64 `json:"pieces"`
+	Nodes          int   `json:"nodes"`
+	// SplitNodes is the number of nodes whose filter is split into parts.
+	SplitNodes  int   `json:"splitNodes"`
+	FilterBytes int64 `json:"filterBytes"`
+	// EstimatedDeletions is the number of pieces that were counted in the
+	// previous iteration but not in this one.
+	EstimatedDeletions int64 `json:"estimatedDeletions"`
+	// NeedsReview are the nodes whose filters were not sent because they
+	// would delete more than the maximum deletion ratio.
+	NeedsReview []storj.NodeID `json:"needsReview"`
+	// Excluded are the nodes that were not sent their filters because they
+	// were excluded from the run.
+	Excluded []storj.NodeID `json:"excluded"`
+	// CreationDate is the creation date of the sent filters, and
+	// NodesReporting the number of nodes that were sent a filter and are
+	// expected to report its results.
+	CreationDate   time.Time `json:"creationDate"`
+	NodesReporting int       `json:"nodesReporting"`
+
+	GenerateDuration time.Duration  `json:"generateDuration"`
+	SendDuration     time.Duration  `json:"sendDuration"`
+	Skipped          map[string]int `json:"skipped"`
+	Sent             SendSummary    `json:"sent"`
+}
+
+// generate iterates over every segment and returns the retain filters for
+// every node. The piece counts are updated for the next generation.
+func (service *Service) generate(ctx context.Context, lastPieceCounts map[storj.NodeID]int, stats *RunStats) (_ map[storj.NodeID]*RetainInfo, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	pieceTracker := NewPieceTracker(service.log.Named("gc observer"), service.config, lastPieceCounts)
+	service.startIterating(pieceTracker)
+
+	// collect things to retain. a rate limit slows down the whole segment
+	// loop, including its other observers.
+	start := time.Now()
+	err = service.segmentLoop.Join(ctx, pieceTracker)
+	mon.IntVal("piece_tracker_memory_bytes").Observe(pieceTracker.MemoryUsage().Int64())
+	if elapsed := time.Since(start); elapsed > 0 {
+		mon.FloatVal("segments_per_second").Observe(float64(pieceTracker.segments) / elapsed.Seconds())
+	}
+	if err != nil {
+		return nil, Error.New("error joining metainfoloop: %w", err)
+	}
+
+	if err := service.verifyFilters(pieceTracker.RetainInfos, pieceTracker.samples); err != nil {
+		return nil, err
+	}
+
+	// estimate how many pieces the filters would delete, assuming the nodes
+	// still store the pieces that were counted in the previous iteration.
+	stats.NeedsReview = service.checkDeletions(pieceTracker.RetainInfos, lastPieceCounts, stats)
+
+	// save piece counts in memory for next iteration.
+	service.updatePieceCounts(lastPieceCounts, pieceTracker.RetainInfos)
+
+	// save piece counts to db for next satellite restart
+	err = service.overlay.UpdatePieceCounts(ctx, lastPieceCounts)
+	if err != nil {
+		service.log.Error("error updating piece counts", zap.Error(err))
+	}
+	if service.store != nil {
+		err = service.store.SavePieceCounts(ctx, lastPieceCounts, pieceTracker.creationDate)
 		if err != nil {
-			service.log.Error("error updating piece counts", zap.Error(err))
+			service.log.Error("error saving piece counts", zap.Error(err))
 		}
+	}
 
-		// monitor information
-		for _, info := range pieceTracker.RetainInfos {
-			mon.IntVal("node_piece_count").Observe(int64(info.Count))
-			mon.IntVal("retain_filter_size_bytes").Observe(info.Filter.Size())
+	// monitor information
+	stats.Segments = pieceTracker.segments
+	stats.InlineSegments = pieceTracker.inlineSegments
+	stats.CopiedSegments = pieceTracker.copiedSegments
+	stats.Pieces = pieceTracker.pieces
+	stats.Nodes = len(pieceTracker.RetainInfos)
+	for _, info := range pieceTracker.RetainInfos {
+		mon.IntVal("node_piece_count").Observe(int64(info.Count))
+		mon.IntVal("retain_filter_size_bytes").Observe(info.Size())
+		if info.Count > 0 {
+			mon.FloatVal("retain_filter_bits_per_piece").Observe(float64(8*info.Size()) / float64(info.Count))
+		}
+		if len(info.Parts) > 0 {
+			mon.IntVal("retain_filter_parts").Observe(int64(len(info.Parts)))
+			stats.SplitNodes++
 		}
+		stats.FilterBytes += info.Size()
+	}
+	mon.IntVal("segments").Observe(stats.Segments)
+	mon.IntVal("inline_segments").Observe(stats.InlineSegments)
+	mon.IntVal("copied_segments").Observe(stats.CopiedSegments)
+	mon.IntVal("pieces").Observe(stats.Pieces)
+	mon.IntVal("nodes_with_filters").Observe(int64(stats.Nodes))
+	mon.IntVal("retain_filters_total_bytes").Observe(stats.FilterBytes)
+	mon.IntVal("estimated_deletions").Observe(stats.EstimatedDeletions)
+	mon.IntVal("nodes_needing_review").Observe(int64(len(stats.NeedsReview)))
+
+	// the piece counts of nodes that need a review are still updated, as
+	// they are what the metabase says the nodes should store.
+	for _, id := range stats.NeedsReview {
+		delete(pieceTracker.RetainInfos, id)
+	}
+
+	return pieceTracker.RetainInfos, nil
+}
 
-		// send retain requests
-		limiter := sync2.NewLimiter(service.config.ConcurrentSends)
-		for id, info := range pieceTracker.RetainInfos {
-			id, info := id, info
-			limiter.Go(ctx, func() {
-				err := service.sendRetainRequest(ctx, id, info)
-				if err != nil {
-					service.log.Warn("error sending retain info to node", zap.Stringer("Node ID", id), zap.Error(err))
-				}
-			})
+// updatePieceCounts replaces the piece counts with the ones of the retain
+// infos. Only the target nodes have been counted when running for them, so
+// the rest is kept. Nodes with fewer pieces than are needed to be sent a
+// filter keep their previous count, which their filters are sized by once
+// they have enough pieces again.
+func (service *Service) updatePieceCounts(lastPieceCounts map[storj.NodeID]int, infos map[storj.NodeID]*RetainInfo) {
+	small := map[storj.NodeID]int{}
+	for id, info := range infos {
+		if info.Count < service.config.MinimumPieces && lastPieceCounts[id] > 0 {
+			small[id] = lastPieceCounts[id]
 		}
-		limiter.Wait()
+	}
 
-		return nil
-	})
+	if !service.config.TargetNodesOnly {
+		for id := range lastPieceCounts {
+			delete(lastPieceCounts, id)
+		}
+	}
+	for id, info := range infos {
+		lastPieceCounts[id] = info.Count
+	}
+	for id, count := range small {
+		lastPieceCounts[id] = count
+	}
+}
+
+// send sends the retain filters to the nodes. The ones that could not be
+// reached are retried in later cycles. Filters that are sent or given up on
+// are removed from the store, if there is one.
+func (service *Service) send(ctx context.Context, retainInfos map[storj.NodeID]*RetainInfo) SendSummary {
+	return service.sender.Send(ctx, retainInfos)
 }
 
 func (service *Service) sendRetainRequest(ctx context.Context, id storj.NodeID, info *RetainInfo) (err error) {
 	defer mon.Task()(&ctx, id.String())(&err)
 
-	dossier, err := service.overlay.Get(ctx, id)
-	if err != nil {
-		return Error.Wrap(err)
+	dossier, ok := service.dossiers[id]
+	if !ok {
+		dossier, err = service.overlay.Get(ctx, id)
+		if err != nil {
+			return Error.Wrap(err)
+		}
 	}
 
 	if service.config.RetainSendTimeout > 0 {
@@ -164,9 +659,91 @@ func (service *Service) sendRetainRequest(ctx context.Context, id storj.NodeID,
 		err = errs.Combine(err, Error.Wrap(client.Close()))
 	}()
 
-	err = client.Retain(ctx, &pb.RetainRequest{
-		CreationDate: info.CreationDate,
-		Filter:       info.Filter.Bytes(),
+	splitSupported := len(info.Parts) > 0 && service.splitSupported(dossier.Version.Version)
+	if len(info.Parts) > 0 && !splitSupported {
+		service.log.Debug("node does not support split retain filters, sending their union",
+			zap.Stringer("Node ID", id), zap.String("version", dossier.Version.Version))
+	}
+
+	var rangeBits uint8
+	if splitSupported {
+		rangeBits = info.splitBits()
+	}
+	var flags retainfilter.Flags
+	if service.askReport(ctx, id, dossier.Version.Version, info.CreationDate, rangeBits) {
+		flags |= retainfilter.FlagReport
+		if service.config.RequireTrash {
+			flags |= retainfilter.FlagRequireTrash
+		}
+	}
+	if service.config.RequireTrash && flags&retainfilter.FlagRequireTrash == 0 {
+		// outdated nodes are usually skipped before sending, unless they
+		// could not be looked up then.
+		return Error.New("node %v can not be required to only trash pieces", id)
+	}
+
+	requests, err := info.requests(splitSupported, flags)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	for _, request := range requests {
+		// the node applies every part independently, so a part that fails
+		// is simply sent again with all others when retrying.
+		if err := client.Retain(ctx, request); err != nil {
+			return Error.Wrap(err)
+		}
+	}
+
+	if flags&retainfilter.FlagReport != 0 {
+		atomic.AddInt64(&service.runs.nodesReporting, 1)
+	}
+	return nil
+}
+
+// askReport returns whether the node is asked to report the result of the
+// filter. Nodes are only asked when they are new enough to report it, and
+// when the filter could be recorded as sent, because the reports of all
+// other nodes are rejected.
+func (service *Service) askReport(ctx context.Context, id storj.NodeID, nodeVersion string, creationDate time.Time, rangeBits uint8) bool {
+	if service.history == nil || !minimumVersion(service.config.ReportMinimumVersion, nodeVersion) {
+		return false
+	}
+
+	// the filter is recorded before it is sent, so that the report of a node
+	// that is quick to apply it is not rejected.
+	err := service.history.RecordSentFilter(ctx, SentFilter{
+		NodeID:       id,
+		CreationDate: creationDate,
+		RangeBits:    rangeBits,
+		Sent:         time.Now(),
 	})
-	return Error.Wrap(err)
+	if err != nil {
+		service.log.Warn("error recording sent retain filter, not asking for a report",
+			zap.Stringer("Node ID", id), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// splitSupported returns whether a node running the version accepts retain
+// filters that are split across several requests.
+func (service *Service) splitSupported(nodeVersion string) bool {
+	return minimumVersion(service.config.SplitMinimumVersion, nodeVersion)
+}
+
+// minimumVersion returns whether the node version is at least the configured
+// minimum, which is never the case when there is none.
+func minimumVersion(configured, nodeVersion string) bool {
+	if configured == "" {
+		return false
+	}
+	minimum, err := version.NewSemVer(configured)
+	if err != nil {
+		return false
+	}
+	current, err := version.NewSemVer(nodeVersion)
+	if err != nil {
+		return false
+	}
+	return current.Compare(minimum) >= 0
 }
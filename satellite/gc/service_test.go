@@ -0,0 +1,77 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/memory"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{
+		Interval:           time.Hour,
+		Enabled:            true,
+		InitialPieces:      400000,
+		FalsePositiveRate:  0.1,
+		MaxBloomFilterSize: 2 * memory.MiB,
+		ConcurrentSends:    1,
+		GenerateFilters:    true,
+		SendFilters:        true,
+		RetryBackoff:       time.Minute,
+		RetryMaxBackoff:    time.Hour,
+	}
+	require.NoError(t, valid.Validate())
+
+	snapshot := valid
+	snapshot.SnapshotDatabaseURL = "postgres://snapshot"
+	snapshot.SnapshotCreated = time.Now().Add(-time.Hour).Format(time.RFC3339)
+	require.NoError(t, snapshot.Validate())
+
+	for _, tt := range []struct {
+		flag   string
+		modify func(*Config)
+	}{
+		{"garbage-collection.interval", func(c *Config) { c.Interval = 0 }},
+		{"garbage-collection.interval", func(c *Config) { c.Interval = time.Millisecond }},
+		{"garbage-collection.initial-pieces", func(c *Config) { c.InitialPieces = 0 }},
+		{"garbage-collection.minimum-pieces", func(c *Config) { c.MinimumPieces = -1 }},
+		{"garbage-collection.false-positive-rate", func(c *Config) { c.FalsePositiveRate = 0 }},
+		{"garbage-collection.false-positive-rate", func(c *Config) { c.FalsePositiveRate = 1 }},
+		{"garbage-collection.false-positive-rate", func(c *Config) { c.FalsePositiveRate = -0.1 }},
+		{"garbage-collection.max-bloom-filter-size", func(c *Config) { c.MaxBloomFilterSize = -1 }},
+		{"garbage-collection.max-memory", func(c *Config) { c.MaxMemory = -1 }},
+		{"garbage-collection.rate-limit", func(c *Config) { c.RateLimit = -1 }},
+		{"garbage-collection.concurrent-sends", func(c *Config) { c.ConcurrentSends = 0 }},
+		{"garbage-collection.retain-send-timeout", func(c *Config) { c.RetainSendTimeout = -time.Second }},
+		{"garbage-collection.shutdown-grace-period", func(c *Config) { c.ShutdownGracePeriod = -time.Second }},
+		{"garbage-collection.retry-backoff", func(c *Config) { c.RetryBackoff = 0 }},
+		{"garbage-collection.retry-max-backoff", func(c *Config) { c.RetryMaxBackoff = time.Second }},
+		{"garbage-collection.deletion-ratio-warning", func(c *Config) { c.DeletionRatioWarning = 1.5 }},
+		{"garbage-collection.max-deletion-ratio", func(c *Config) { c.MaxDeletionRatio = -1 }},
+		{"garbage-collection.persist-dir", func(c *Config) { c.SendFilters = false }},
+		{"garbage-collection.generate-filters", func(c *Config) { c.GenerateFilters, c.PersistDir, c.DryRun = false, "gc", true }},
+		{"garbage-collection.target-nodes", func(c *Config) { c.TargetNodesOnly = true }},
+		{"garbage-collection.split-minimum-version", func(c *Config) { c.SplitMinimumVersion = "invalid" }},
+		{"garbage-collection.report-minimum-version", func(c *Config) { c.ReportMinimumVersion = "invalid" }},
+		{"garbage-collection.report-minimum-version", func(c *Config) { c.RequireTrash = true }},
+		{"garbage-collection.snapshot-created", func(c *Config) { c.SnapshotDatabaseURL = "postgres://snapshot" }},
+		{"garbage-collection.snapshot-created", func(c *Config) {
+			c.SnapshotDatabaseURL, c.SnapshotCreated = "postgres://snapshot", "yesterday"
+		}},
+		{"garbage-collection.snapshot-created", func(c *Config) {
+			c.SnapshotDatabaseURL, c.SnapshotCreated = "postgres://snapshot", time.Now().Add(time.Hour).Format(time.RFC3339)
+		}},
+		{"garbage-collection.snapshot-database-url", func(c *Config) { c.SnapshotCreated = time.Now().Format(time.RFC3339) }},
+	} {
+		config := valid
+		tt.modify(&config)
+		err := config.Validate()
+		require.Error(t, err, tt.flag)
+		require.Contains(t, err.Error(), tt.flag)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"go.uber.org/zap"
+
+	"storj.io/common/storj"
+	"storj.io/storj/satellite/overlay"
+)
+
+// Reasons for not sending a retain filter to a node.
+const (
+	skipDisqualified = "disqualified"
+	skipExcluded     = "excluded"
+	skipExited       = "exited"
+	skipOffline      = "offline"
+	skipOutdated     = "outdated"
+	skipSmall        = "small"
+	skipUnknown      = "unknown"
+)
+
+// nodeLookup looks up the nodes to send retain filters to.
+type nodeLookup interface {
+	GetNodes(ctx context.Context, nodeIDs storj.NodeIDList) (map[storj.NodeID]*overlay.NodeDossier, error)
+}
+
+// skipReason returns why the retain filter should not be sent to the node, or
+// an empty string when it should be sent. Nodes that have not been contacted
+// successfully within staleness are considered offline, unless staleness is
+// zero.
+func skipReason(dossier *overlay.NodeDossier, now time.Time, staleness time.Duration) string {
+	switch {
+	case dossier.Disqualified != nil:
+		return skipDisqualified
+	case dossier.ExitStatus.ExitFinishedAt != nil:
+		return skipExited
+	case staleness > 0 && dossier.Reputation.LastContactSuccess.Add(staleness).Before(now):
+		return skipOffline
+	}
+	return ""
+}
+
+// skipUnreachable returns the retain infos of the nodes that the filters
+// should be sent to, their dossiers, and how many nodes were skipped for every
+// reason. The nodes are looked up with a single query. When that fails, all
+// filters are sent without dossiers.
+func skipUnreachable(ctx context.Context, log *zap.Logger, nodes nodeLookup, staleness time.Duration, infos map[storj.NodeID]*RetainInfo) (_ map[storj.NodeID]*RetainInfo, dossiers map[storj.NodeID]*overlay.NodeDossier, skipped map[string]int) {
+	defer mon.Task()(&ctx)(nil)
+
+	skipped = map[string]int{}
+	if len(infos) == 0 {
+		return infos, nil, skipped
+	}
+
+	ids := make(storj.NodeIDList, 0, len(infos))
+	for id := range infos {
+		ids = append(ids, id)
+	}
+	dossiers, err := nodes.GetNodes(ctx, ids)
+	if err != nil {
+		// sending will fail and be retried if the nodes really can't be reached.
+		log.Warn("error looking up nodes for sending retain filters", zap.Int("nodes", len(ids)), zap.Error(err))
+		return infos, nil, skipped
+	}
+
+	now := time.Now()
+	sendable := make(map[storj.NodeID]*RetainInfo, len(infos))
+	for id, info := range infos {
+		reason := skipUnknown
+		if dossier, ok := dossiers[id]; ok {
+			reason = skipReason(dossier, now, staleness)
+		}
+
+		if reason == "" {
+			sendable[id] = info
+			continue
+		}
+		delete(dossiers, id)
+		skipped[reason]++
+		log.Debug("skipping sending retain filter", zap.Stringer("Node ID", id), zap.String("reason", reason))
+	}
+
+	for reason, count := range skipped {
+		mon.Meter("retain_send_skipped", monkit.NewSeriesTag("reason", reason)).Mark(count)
+	}
+
+	return sendable, dossiers, skipped
+}
+
+// skipExcludedNodes returns the retain infos of the nodes that are not excluded,
+// and the excluded nodes that have a filter. The filters of excluded nodes
+// are kept in the store, if there is one, to be sent in a later run.
+func skipExcludedNodes(log *zap.Logger, excluded map[storj.NodeID]struct{}, infos map[storj.NodeID]*RetainInfo) (_ map[storj.NodeID]*RetainInfo, skipped []storj.NodeID) {
+	if len(excluded) == 0 {
+		return infos, nil
+	}
+
+	sendable := make(map[storj.NodeID]*RetainInfo, len(infos))
+	for id, info := range infos {
+		if _, ok := excluded[id]; ok {
+			skipped = append(skipped, id)
+			log.Info("skipping sending retain filter to excluded node", zap.Stringer("Node ID", id))
+			continue
+		}
+		sendable[id] = info
+	}
+
+	mon.Meter("retain_send_skipped", monkit.NewSeriesTag("reason", skipExcluded)).Mark(len(skipped))
+	return sendable, skipped
+}
+
+// skipSmallNodes returns the retain infos of the nodes that have at least the
+// minimum number of pieces, and how many nodes have fewer.
+func skipSmallNodes(log *zap.Logger, minimum int, infos map[storj.NodeID]*RetainInfo) (_ map[storj.NodeID]*RetainInfo, skipped int) {
+	if minimum <= 0 {
+		return infos, 0
+	}
+
+	sendable := make(map[storj.NodeID]*RetainInfo, len(infos))
+	for id, info := range infos {
+		if info.Count < minimum {
+			skipped++
+			log.Debug("skipping sending retain filter to node with too few pieces",
+				zap.Stringer("Node ID", id), zap.Int("pieces", info.Count))
+			continue
+		}
+		sendable[id] = info
+	}
+
+	mon.IntVal("skipped_small_nodes").Observe(int64(skipped))
+	mon.Meter("retain_send_skipped", monkit.NewSeriesTag("reason", skipSmall)).Mark(skipped)
+	return sendable, skipped
+}
+
+// skipOutdatedNodes returns the retain infos of the nodes that are not known
+// to run a version below the minimum, and how many nodes do. Nodes without a
+// dossier are checked again when their filter is sent.
+func skipOutdatedNodes(log *zap.Logger, minimum string, dossiers map[storj.NodeID]*overlay.NodeDossier, infos map[storj.NodeID]*RetainInfo) (_ map[storj.NodeID]*RetainInfo, skipped int) {
+	sendable := make(map[storj.NodeID]*RetainInfo, len(infos))
+	for id, info := range infos {
+		if dossier, ok := dossiers[id]; ok && !minimumVersion(minimum, dossier.Version.Version) {
+			skipped++
+			log.Debug("skipping sending retain filter to outdated node",
+				zap.Stringer("Node ID", id), zap.String("version", dossier.Version.Version))
+			continue
+		}
+		sendable[id] = info
+	}
+
+	mon.Meter("retain_send_skipped", monkit.NewSeriesTag("reason", skipOutdated)).Mark(skipped)
+	return sendable, skipped
+}
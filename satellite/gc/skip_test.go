@@ -0,0 +1,235 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/pb"
+	"storj.io/common/rpc"
+	"storj.io/common/storj"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/storj/satellite/overlay"
+)
+
+type fakeNodeLookup map[storj.NodeID]*overlay.NodeDossier
+
+func (nodes fakeNodeLookup) GetNodes(ctx context.Context, nodeIDs storj.NodeIDList) (map[storj.NodeID]*overlay.NodeDossier, error) {
+	found := map[storj.NodeID]*overlay.NodeDossier{}
+	for _, id := range nodeIDs {
+		if dossier, ok := nodes[id]; ok {
+			found[id] = dossier
+		}
+	}
+	return found, nil
+}
+
+// failingNodeLookup fails every lookup.
+type failingNodeLookup struct{}
+
+func (failingNodeLookup) GetNodes(ctx context.Context, nodeIDs storj.NodeIDList) (map[storj.NodeID]*overlay.NodeDossier, error) {
+	return nil, Error.New("database is down")
+}
+
+func TestSkipReason(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	staleness := 24 * time.Hour
+
+	online := overlay.NodeStats{LastContactSuccess: now.Add(-time.Minute)}
+	stale := overlay.NodeStats{LastContactSuccess: now.Add(-25 * time.Hour), LastContactFailure: now}
+
+	for _, tt := range []struct {
+		name      string
+		dossier   overlay.NodeDossier
+		staleness time.Duration
+		reason    string
+	}{
+		{name: "online", dossier: overlay.NodeDossier{Reputation: online}, staleness: staleness, reason: ""},
+		{name: "disqualified", dossier: overlay.NodeDossier{Reputation: online, Disqualified: &past}, staleness: staleness, reason: skipDisqualified},
+		{name: "exiting", dossier: overlay.NodeDossier{Reputation: online, ExitStatus: overlay.ExitStatus{ExitInitiatedAt: &past}}, staleness: staleness, reason: ""},
+		{name: "exited", dossier: overlay.NodeDossier{Reputation: online, ExitStatus: overlay.ExitStatus{ExitInitiatedAt: &past, ExitFinishedAt: &past}}, staleness: staleness, reason: skipExited},
+		{name: "offline", dossier: overlay.NodeDossier{Reputation: stale}, staleness: staleness, reason: skipOffline},
+		{name: "offline without staleness", dossier: overlay.NodeDossier{Reputation: stale}, staleness: 0, reason: ""},
+		{name: "suspended", dossier: overlay.NodeDossier{Reputation: online, OfflineSuspended: &past}, staleness: staleness, reason: ""},
+	} {
+		require.Equal(t, tt.reason, skipReason(&tt.dossier, now, tt.staleness), tt.name)
+	}
+}
+
+func TestSkipUnreachable(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	now := time.Now()
+	online := overlay.NodeStats{LastContactSuccess: now}
+	offline := overlay.NodeStats{LastContactSuccess: now.Add(-48 * time.Hour)}
+
+	var (
+		onlineID       = testrand.NodeID()
+		disqualifiedID = testrand.NodeID()
+		exitedID       = testrand.NodeID()
+		offlineID      = testrand.NodeID()
+		unknownID      = testrand.NodeID()
+	)
+	nodes := fakeNodeLookup{
+		onlineID:       {Reputation: online},
+		disqualifiedID: {Reputation: online, Disqualified: &now},
+		exitedID:       {Reputation: online, ExitStatus: overlay.ExitStatus{ExitFinishedAt: &now}},
+		offlineID:      {Reputation: offline},
+	}
+
+	infos := map[storj.NodeID]*RetainInfo{}
+	for _, id := range []storj.NodeID{onlineID, disqualifiedID, exitedID, offlineID, unknownID} {
+		infos[id] = newTestRetainInfo(t, now, testrand.PieceID())
+	}
+
+	sendable, dossiers, skipped := skipUnreachable(ctx, zaptest.NewLogger(t), nodes, 24*time.Hour, infos)
+	require.Equal(t, map[storj.NodeID]*RetainInfo{
+		onlineID: infos[onlineID],
+	}, sendable)
+	require.Equal(t, map[storj.NodeID]*overlay.NodeDossier{
+		onlineID: nodes[onlineID],
+	}, dossiers)
+	require.Equal(t, map[string]int{
+		skipDisqualified: 1,
+		skipExited:       1,
+		skipOffline:      1,
+		skipUnknown:      1,
+	}, skipped)
+
+	// sending will fail and be retried if the nodes really can't be reached.
+	sendable, dossiers, skipped = skipUnreachable(ctx, zaptest.NewLogger(t), failingNodeLookup{}, 24*time.Hour, infos)
+	require.Equal(t, infos, sendable)
+	require.Nil(t, dossiers)
+	require.Empty(t, skipped)
+}
+
+// fakeOverlay looks up nodes in a fakeNodeLookup.
+type fakeOverlay struct {
+	overlay.DB
+	nodes   fakeNodeLookup
+	lookups int
+}
+
+func (db *fakeOverlay) GetNodes(ctx context.Context, nodeIDs storj.NodeIDList) (map[storj.NodeID]*overlay.NodeDossier, error) {
+	db.lookups++
+	return db.nodes.GetNodes(ctx, nodeIDs)
+}
+
+func TestServiceExcludedNodes(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	excluded, triggerExcluded, included := testrand.NodeID(), testrand.NodeID(), testrand.NodeID()
+	generated := map[storj.NodeID]*RetainInfo{}
+	nodes := fakeNodeLookup{}
+	for _, id := range []storj.NodeID{excluded, triggerExcluded, included} {
+		generated[id] = newTestRetainInfo(t, time.Now(), testrand.PieceID())
+		nodes[id] = &overlay.NodeDossier{}
+	}
+
+	config := Config{
+		Enabled:         true,
+		Interval:        time.Hour,
+		ConcurrentSends: 1,
+		SendFilters:     true,
+		PersistDir:      ctx.Dir("gc"),
+		RetryBackoff:    time.Hour,
+		RetryMaxBackoff: time.Hour,
+		ExcludedNodes:   NodeIDs{excluded},
+	}
+	overlayDB := &fakeOverlay{nodes: nodes}
+	service := NewService(zaptest.NewLogger(t), config, rpc.Dialer{}, overlayDB, nil, nil)
+	require.NoError(t, service.store.Save(ctx, generated))
+
+	var sent []storj.NodeID
+	service.sendRetain = func(ctx context.Context, id storj.NodeID, info *RetainInfo) error {
+		sent = append(sent, id)
+		return nil
+	}
+
+	// nodes can be excluded in the configuration and when triggering a run.
+	_, _, err := service.TriggerExcluding(NodeIDs{triggerExcluded})
+	require.NoError(t, err)
+	run := service.startRun()
+	stats, err := service.runOnce(ctx, map[storj.NodeID]int{}, run.excluded)
+	require.NoError(t, err)
+
+	require.Equal(t, []storj.NodeID{included}, sent)
+
+	// the nodes are looked up once, and their dossiers are kept for sending.
+	require.Equal(t, 1, overlayDB.lookups)
+	require.Contains(t, service.dossiers, included)
+	require.ElementsMatch(t, []storj.NodeID{excluded, triggerExcluded}, stats.Excluded)
+	require.Equal(t, 2, stats.Skipped[skipExcluded])
+
+	// the filters of excluded nodes are kept to be sent later.
+	remaining, err := service.store.Load(ctx)
+	require.NoError(t, err)
+	requireRetainInfosEqual(t, map[storj.NodeID]*RetainInfo{
+		excluded:        generated[excluded],
+		triggerExcluded: generated[triggerExcluded],
+	}, remaining)
+}
+
+func TestSkipSmallNodes(t *testing.T) {
+	below, at, above := testrand.NodeID(), testrand.NodeID(), testrand.NodeID()
+	infos := map[storj.NodeID]*RetainInfo{
+		below: {Count: 9},
+		at:    {Count: 10},
+		above: {Count: 11},
+	}
+
+	sendable, skipped := skipSmallNodes(zaptest.NewLogger(t), 10, infos)
+	require.Equal(t, 1, skipped)
+	require.Len(t, sendable, 2)
+	require.NotContains(t, sendable, below)
+	require.Contains(t, sendable, at)
+	require.Contains(t, sendable, above)
+
+	sendable, skipped = skipSmallNodes(zaptest.NewLogger(t), 0, infos)
+	require.Zero(t, skipped)
+	require.Len(t, sendable, 3)
+}
+
+func TestSkipOutdatedNodes(t *testing.T) {
+	outdated, current, unknown := testrand.NodeID(), testrand.NodeID(), testrand.NodeID()
+	infos := map[storj.NodeID]*RetainInfo{
+		outdated: {},
+		current:  {},
+		unknown:  {},
+	}
+	dossiers := map[storj.NodeID]*overlay.NodeDossier{
+		outdated: {Version: pb.NodeVersion{Version: "v1.49.9"}},
+		current:  {Version: pb.NodeVersion{Version: "v1.50.0"}},
+	}
+
+	sendable, skipped := skipOutdatedNodes(zaptest.NewLogger(t), "v1.50.0", dossiers, infos)
+	require.Equal(t, 1, skipped)
+	require.Len(t, sendable, 2)
+	require.NotContains(t, sendable, outdated)
+	require.Contains(t, sendable, current)
+	// nodes that could not be looked up are checked when sending.
+	require.Contains(t, sendable, unknown)
+}
+
+func TestUpdatePieceCountsKeepsSmallNodes(t *testing.T) {
+	service := NewService(zaptest.NewLogger(t), Config{MinimumPieces: 10}, rpc.Dialer{}, nil, nil, nil)
+
+	shrunk, grown, added, gone := testrand.NodeID(), testrand.NodeID(), testrand.NodeID(), testrand.NodeID()
+	lastPieceCounts := map[storj.NodeID]int{shrunk: 1000, grown: 5, gone: 100}
+	service.updatePieceCounts(lastPieceCounts, map[storj.NodeID]*RetainInfo{
+		shrunk: {Count: 9},
+		grown:  {Count: 10},
+		added:  {Count: 3},
+	})
+
+	// nodes below the minimum keep their previous count, if they had one.
+	require.Equal(t, map[storj.NodeID]int{shrunk: 1000, grown: 10, added: 3}, lastPieceCounts)
+}
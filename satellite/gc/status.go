@@ -0,0 +1,112 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/zeebo/errs"
+)
+
+// Phase is what the service is currently doing.
+type Phase int
+
+const (
+	// PhaseIdle is when no run is active.
+	PhaseIdle Phase = iota
+	// PhaseIterating is when the segments are iterated to generate filters.
+	PhaseIterating
+	// PhaseSending is when the filters are sent to the nodes.
+	PhaseSending
+)
+
+// String returns a string representation of the phase.
+func (phase Phase) String() string {
+	switch phase {
+	case PhaseIdle:
+		return "idle"
+	case PhaseIterating:
+		return "iterating"
+	case PhaseSending:
+		return "sending"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (phase Phase) MarshalText() ([]byte, error) {
+	if phase < PhaseIdle || phase > PhaseSending {
+		return nil, errs.New("invalid phase %d", int(phase))
+	}
+	return []byte(phase.String()), nil
+}
+
+// Status is a snapshot of what the service is doing, for health checks.
+type Status struct {
+	Phase Phase `json:"phase"`
+	// Started is when the active run started, zero when idle.
+	Started time.Time `json:"started"`
+	// Segments is the number of segments the active run has iterated so far.
+	Segments int64 `json:"segments"`
+	// NodesSent is the number of nodes that received their filter from the
+	// active run, out of NodesTotal that it sends filters to.
+	NodesSent  int64 `json:"nodesSent"`
+	NodesTotal int64 `json:"nodesTotal"`
+
+	// Previous is the last finished run, nil when no run has finished yet.
+	Previous *RunInfo `json:"previous,omitempty"`
+	// LastSuccess is when the last successful run finished, zero when no run
+	// has succeeded yet. ConsecutiveFailures is the number of runs that failed
+	// since.
+	LastSuccess         time.Time `json:"lastSuccess"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+}
+
+// Status returns the current status of the service. It is safe to call
+// while a run is active.
+func (service *Service) Status() Status {
+	service.runs.mu.Lock()
+	defer service.runs.mu.Unlock()
+
+	var status Status
+	if run := service.runs.active; run != nil {
+		status.Phase = service.runs.phase
+		status.Started = run.info.Started
+		if tracker := service.runs.tracker; tracker != nil {
+			status.Segments = atomic.LoadInt64(&tracker.segments)
+		}
+		status.NodesSent = atomic.LoadInt64(&service.runs.nodesSent)
+		status.NodesTotal = service.runs.nodesTotal
+	}
+	if n := len(service.runs.finished); n > 0 {
+		previous := service.runs.finished[n-1]
+		status.Previous = &previous
+	}
+	status.LastSuccess = service.runs.lastSuccess
+	status.ConsecutiveFailures = service.runs.consecutiveFailures
+	return status
+}
+
+// startIterating marks the active run as iterating the segments with the
+// piece tracker.
+func (service *Service) startIterating(tracker *PieceTracker) {
+	service.runs.mu.Lock()
+	defer service.runs.mu.Unlock()
+
+	service.runs.phase = PhaseIterating
+	service.runs.tracker = tracker
+}
+
+// startSending marks the active run as sending filters to the nodes.
+func (service *Service) startSending(nodes int) {
+	service.runs.mu.Lock()
+	defer service.runs.mu.Unlock()
+
+	service.runs.phase = PhaseSending
+	service.runs.nodesTotal = int64(nodes)
+	atomic.StoreInt64(&service.runs.nodesSent, 0)
+	atomic.StoreInt64(&service.runs.nodesReporting, 0)
+}
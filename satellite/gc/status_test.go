@@ -0,0 +1,128 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/rpc"
+	"storj.io/common/storj"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/storj/satellite/metabase"
+	"storj.io/storj/satellite/metabase/segmentloop"
+)
+
+func TestServiceStatus(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	config := Config{Enabled: true, InitialPieces: 10, FalsePositiveRate: 0.1}
+	service := NewService(zaptest.NewLogger(t), config, rpc.Dialer{}, nil, nil, nil)
+	service.sendRetain = func(ctx context.Context, id storj.NodeID, info *RetainInfo) error {
+		return nil
+	}
+
+	require.Equal(t, Status{}, service.Status())
+
+	run := service.startRun()
+	status := service.Status()
+	require.Equal(t, PhaseIdle, status.Phase)
+	require.Equal(t, run.info.Started, status.Started)
+
+	tracker := NewPieceTracker(zaptest.NewLogger(t), config, map[storj.NodeID]int{})
+	service.startIterating(tracker)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, tracker.RemoteSegment(ctx, &segmentloop.Segment{
+			RootPieceID: testrand.PieceID(),
+			Pieces:      metabase.Pieces{{Number: 0, StorageNode: testrand.NodeID()}},
+		}))
+	}
+	status = service.Status()
+	require.Equal(t, PhaseIterating, status.Phase)
+	require.Equal(t, int64(3), status.Segments)
+
+	service.startSending(3)
+	require.NoError(t, service.sender.send(ctx, testrand.NodeID(), nil))
+	status = service.Status()
+	require.Equal(t, PhaseSending, status.Phase)
+	require.Equal(t, int64(1), status.NodesSent)
+	require.Equal(t, int64(3), status.NodesTotal)
+	require.Nil(t, status.Previous)
+
+	service.finishRun(run, RunStats{Segments: 3}, nil)
+	status = service.Status()
+	require.Equal(t, PhaseIdle, status.Phase)
+	require.True(t, status.Started.IsZero())
+	require.Zero(t, status.NodesSent)
+	require.NotNil(t, status.Previous)
+	require.Equal(t, run.info.ID, status.Previous.ID)
+	require.Equal(t, int64(3), status.Previous.Stats.Segments)
+
+	// the status is served as JSON by the admin server.
+	server := httptest.NewServer(NewAdminServer(zaptest.NewLogger(t), nil, service, "secret").Handler())
+	defer server.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/gc/status", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer ctx.Check(resp.Body.Close)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Phase    string `json:"phase"`
+		Previous struct {
+			ID       int64     `json:"id"`
+			Finished time.Time `json:"finished"`
+		} `json:"previous"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "idle", body.Phase)
+	require.Equal(t, run.info.ID, body.Previous.ID)
+	require.False(t, body.Previous.Finished.IsZero())
+}
+
+func TestServiceLastSuccess(t *testing.T) {
+	service := NewService(zaptest.NewLogger(t), Config{Enabled: true}, rpc.Dialer{}, nil, nil, nil)
+
+	const lastSuccess = "last_success_unix,scope=storj.io/storj/satellite/gc recent"
+	const failures = "consecutive_failed_runs,scope=storj.io/storj/satellite/gc recent"
+	collect := func() map[string]float64 {
+		return monkit.Collect(monkit.ScopeNamed("storj.io/storj/satellite/gc"))
+	}
+	before := collect()
+
+	for i := 1; i <= 2; i++ {
+		service.finishRun(service.startRun(), RunStats{}, Error.New("failed"))
+		status := service.Status()
+		require.True(t, status.LastSuccess.IsZero())
+		require.Equal(t, i, status.ConsecutiveFailures)
+		require.Equal(t, float64(i), collect()[failures])
+		require.Equal(t, before[lastSuccess], collect()[lastSuccess])
+	}
+
+	info := service.finishRun(service.startRun(), RunStats{}, nil)
+	status := service.Status()
+	require.Equal(t, info.Finished, status.LastSuccess)
+	require.Zero(t, status.ConsecutiveFailures)
+	require.Equal(t, float64(info.Finished.Unix()), collect()[lastSuccess])
+	require.Zero(t, collect()[failures])
+
+	// a failure does not move the last success.
+	service.finishRun(service.startRun(), RunStats{}, Error.New("failed"))
+	status = service.Status()
+	require.Equal(t, info.Finished, status.LastSuccess)
+	require.Equal(t, 1, status.ConsecutiveFailures)
+	require.Equal(t, float64(info.Finished.Unix()), collect()[lastSuccess])
+}
@@ -0,0 +1,548 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/common/bloomfilter"
+	"storj.io/common/fpath"
+	"storj.io/common/pb"
+	"storj.io/common/storj"
+	"storj.io/storj/private/retainfilter"
+)
+
+const (
+	// pendingDir is the directory beneath the store directory that contains
+	// the filters that still need to be sent.
+	pendingDir = "pending"
+	// generatingPrefix is the prefix for directories that filters are
+	// written to before they replace the pending ones.
+	generatingPrefix = "generating-"
+	// completeMarker is written into a generating directory once every
+	// filter has been written to it.
+	completeMarker = "complete"
+	// filterExt is the extension of the file holding the filter for a node.
+	filterExt = ".retain"
+	// stateExt is the extension of the file holding the SendState for a node.
+	stateExt = ".state"
+	// pieceCountsFile is the file that contains the piece counts of the
+	// latest generation.
+	pieceCountsFile = "piece-counts"
+	// lastSentFile is the file that contains when every node was last sent
+	// its filter.
+	lastSentFile = "last-sent"
+)
+
+// Store persists generated retain filters so that sending them can happen
+// separately from generating them and survive satellite restarts.
+//
+// The filters of the latest generation live in a pending directory with one
+// file per node, next to the state of sending the filter to the node. A node's
+// files are removed once its filter has been sent or sending was given up.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a Store that keeps filters in the directory.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save persists the filters for every node, replacing any that were
+// previously saved and not yet sent.
+func (store *Store) Save(ctx context.Context, infos map[storj.NodeID]*RetainInfo) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if err := os.MkdirAll(store.dir, 0700); err != nil {
+		return Error.Wrap(err)
+	}
+	if err := store.removeGenerating(); err != nil {
+		return err
+	}
+
+	generating := filepath.Join(store.dir, generatingPrefix+strconv.FormatInt(time.Now().UnixNano(), 10))
+	if err := os.Mkdir(generating, 0700); err != nil {
+		return Error.Wrap(err)
+	}
+
+	for id, info := range infos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		data, err := MarshalRetainInfo(info)
+		if err != nil {
+			return err
+		}
+		if err := fpath.AtomicWriteFile(filepath.Join(generating, id.String()+filterExt), data, 0600); err != nil {
+			return Error.Wrap(err)
+		}
+	}
+
+	if err := fpath.AtomicWriteFile(filepath.Join(generating, completeMarker), nil, 0600); err != nil {
+		return Error.Wrap(err)
+	}
+
+	return store.promote(generating)
+}
+
+// promote replaces the pending directory with the completely written
+// generating directory.
+func (store *Store) promote(generating string) error {
+	pending := filepath.Join(store.dir, pendingDir)
+	if err := os.RemoveAll(pending); err != nil {
+		return Error.Wrap(err)
+	}
+	return Error.Wrap(os.Rename(generating, pending))
+}
+
+// removeGenerating removes any partially written generating directories. If a
+// completely written one is found and there are no pending filters, which
+// happens if the process stopped while promoting it, it is promoted instead.
+func (store *Store) removeGenerating() error {
+	entries, err := ioutil.ReadDir(store.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return Error.Wrap(err)
+	}
+
+	pendingExists := false
+	for _, entry := range entries {
+		if entry.Name() == pendingDir {
+			pendingExists = true
+		}
+	}
+
+	var group errs.Group
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), generatingPrefix) {
+			continue
+		}
+
+		generating := filepath.Join(store.dir, entry.Name())
+		if !pendingExists {
+			if _, err := os.Stat(filepath.Join(generating, completeMarker)); err == nil {
+				group.Add(store.promote(generating))
+				pendingExists = true
+				continue
+			}
+		}
+		group.Add(Error.Wrap(os.RemoveAll(generating)))
+	}
+	return group.Err()
+}
+
+// Load returns the filters that have been saved and not yet sent.
+func (store *Store) Load(ctx context.Context) (_ map[storj.NodeID]*RetainInfo, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if err := store.removeGenerating(); err != nil {
+		return nil, err
+	}
+
+	pending := filepath.Join(store.dir, pendingDir)
+	entries, err := ioutil.ReadDir(pending)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[storj.NodeID]*RetainInfo{}, nil
+		}
+		return nil, Error.Wrap(err)
+	}
+
+	infos := make(map[storj.NodeID]*RetainInfo, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, filterExt) {
+			continue
+		}
+
+		id, err := storj.NodeIDFromString(strings.TrimSuffix(name, filterExt))
+		if err != nil {
+			return nil, Error.New("invalid filter file name %q: %w", name, err)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(pending, name))
+		if err != nil {
+			return nil, Error.Wrap(err)
+		}
+
+		info, err := UnmarshalRetainInfo(data)
+		if err != nil {
+			return nil, Error.New("invalid filter for node %s: %w", id, err)
+		}
+		infos[id] = info
+	}
+	return infos, nil
+}
+
+// Remove removes the saved filter and send state for the node because it no
+// longer needs to be sent. It is not an error if there is nothing saved for
+// the node.
+func (store *Store) Remove(ctx context.Context, id storj.NodeID) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	var group errs.Group
+	for _, ext := range []string{filterExt, stateExt} {
+		err := os.Remove(filepath.Join(store.dir, pendingDir, id.String()+ext))
+		if err != nil && !os.IsNotExist(err) {
+			group.Add(Error.Wrap(err))
+		}
+	}
+	return group.Err()
+}
+
+// SaveState persists the state of sending the saved filter to the node.
+func (store *Store) SaveState(ctx context.Context, id storj.NodeID, state SendState) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	return Error.Wrap(fpath.AtomicWriteFile(filepath.Join(store.dir, pendingDir, id.String()+stateExt), data, 0600))
+}
+
+// LoadStates returns the send states that have been saved for the pending
+// filters.
+func (store *Store) LoadStates(ctx context.Context) (_ map[storj.NodeID]SendState, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	pending := filepath.Join(store.dir, pendingDir)
+	entries, err := ioutil.ReadDir(pending)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[storj.NodeID]SendState{}, nil
+		}
+		return nil, Error.Wrap(err)
+	}
+
+	states := make(map[storj.NodeID]SendState)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, stateExt) {
+			continue
+		}
+
+		id, err := storj.NodeIDFromString(strings.TrimSuffix(name, stateExt))
+		if err != nil {
+			return nil, Error.New("invalid state file name %q: %w", name, err)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(pending, name))
+		if err != nil {
+			return nil, Error.Wrap(err)
+		}
+
+		var state SendState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, Error.New("invalid state for node %s: %w", id, err)
+		}
+		states[id] = state
+	}
+	return states, nil
+}
+
+// SavePieceCounts persists the piece counts of every node, which were counted
+// by the iteration started at counted.
+func (store *Store) SavePieceCounts(ctx context.Context, counts map[storj.NodeID]int, counted time.Time) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if err := os.MkdirAll(store.dir, 0700); err != nil {
+		return Error.Wrap(err)
+	}
+
+	data := make([]byte, 0, len(pieceCountsMagic)+1+2*binary.MaxVarintLen64+len(counts)*(len(storj.NodeID{})+binary.MaxVarintLen64))
+	data = append(data, pieceCountsMagic...)
+	data = append(data, pieceCountsVersion)
+	data = appendUvarint(data, uint64(counted.UnixNano()))
+	data = appendUvarint(data, uint64(len(counts)))
+	for id, count := range counts {
+		data = append(data, id.Bytes()...)
+		data = appendUvarint(data, uint64(count))
+	}
+
+	return Error.Wrap(fpath.AtomicWriteFile(filepath.Join(store.dir, pieceCountsFile), data, 0600))
+}
+
+// LoadPieceCounts returns the saved piece counts and when they were counted.
+// The counts are nil when none have been saved.
+func (store *Store) LoadPieceCounts(ctx context.Context) (counts map[storj.NodeID]int, counted time.Time, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	data, err := ioutil.ReadFile(filepath.Join(store.dir, pieceCountsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, time.Time{}, nil
+		}
+		return nil, time.Time{}, Error.Wrap(err)
+	}
+
+	invalid := func(msg string) (map[storj.NodeID]int, time.Time, error) {
+		return nil, time.Time{}, Error.New("invalid piece counts: %s", msg)
+	}
+
+	if !bytes.HasPrefix(data, pieceCountsMagic) {
+		return invalid("missing header")
+	}
+	data = data[len(pieceCountsMagic):]
+
+	if len(data) < 1 || data[0] != pieceCountsVersion {
+		return invalid("unsupported version")
+	}
+	data = data[1:]
+
+	nanos, n := binary.Uvarint(data)
+	if n <= 0 {
+		return invalid("invalid time")
+	}
+	data = data[n:]
+
+	length, n := binary.Uvarint(data)
+	if n <= 0 {
+		return invalid("invalid length")
+	}
+	data = data[n:]
+
+	counts = make(map[storj.NodeID]int, length)
+	for i := uint64(0); i < length; i++ {
+		if len(data) < len(storj.NodeID{}) {
+			return invalid("truncated")
+		}
+		id, err := storj.NodeIDFromBytes(data[:len(storj.NodeID{})])
+		if err != nil {
+			return invalid(err.Error())
+		}
+		data = data[len(storj.NodeID{}):]
+
+		count, n := binary.Uvarint(data)
+		if n <= 0 {
+			return invalid("invalid count")
+		}
+		data = data[n:]
+
+		counts[id] = int(count)
+	}
+
+	return counts, time.Unix(0, int64(nanos)), nil
+}
+
+// SaveLastSent persists when every node was last sent its filter.
+func (store *Store) SaveLastSent(ctx context.Context, lastSent map[storj.NodeID]time.Time) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if err := os.MkdirAll(store.dir, 0700); err != nil {
+		return Error.Wrap(err)
+	}
+
+	data := make([]byte, 0, len(lastSentMagic)+1+binary.MaxVarintLen64+len(lastSent)*(len(storj.NodeID{})+binary.MaxVarintLen64))
+	data = append(data, lastSentMagic...)
+	data = append(data, lastSentVersion)
+	data = appendUvarint(data, uint64(len(lastSent)))
+	for id, sent := range lastSent {
+		data = append(data, id.Bytes()...)
+		data = appendUvarint(data, uint64(sent.UnixNano()))
+	}
+
+	return Error.Wrap(fpath.AtomicWriteFile(filepath.Join(store.dir, lastSentFile), data, 0600))
+}
+
+// LoadLastSent returns when every node was last sent its filter. It is empty
+// when nothing has been saved.
+func (store *Store) LoadLastSent(ctx context.Context) (_ map[storj.NodeID]time.Time, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	data, err := ioutil.ReadFile(filepath.Join(store.dir, lastSentFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[storj.NodeID]time.Time{}, nil
+		}
+		return nil, Error.Wrap(err)
+	}
+
+	if !bytes.HasPrefix(data, lastSentMagic) {
+		return nil, Error.New("invalid last sent times: missing header")
+	}
+	data = data[len(lastSentMagic):]
+
+	if len(data) < 1 || data[0] != lastSentVersion {
+		return nil, Error.New("invalid last sent times: unsupported version")
+	}
+	data = data[1:]
+
+	length, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, Error.New("invalid last sent times: invalid length")
+	}
+	data = data[n:]
+
+	lastSent := make(map[storj.NodeID]time.Time, length)
+	for i := uint64(0); i < length; i++ {
+		if len(data) < len(storj.NodeID{}) {
+			return nil, Error.New("invalid last sent times: truncated")
+		}
+		id, err := storj.NodeIDFromBytes(data[:len(storj.NodeID{})])
+		if err != nil {
+			return nil, Error.New("invalid last sent times: %w", err)
+		}
+		data = data[len(storj.NodeID{}):]
+
+		nanos, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, Error.New("invalid last sent times: invalid time")
+		}
+		data = data[n:]
+
+		lastSent[id] = time.Unix(0, int64(nanos))
+	}
+	return lastSent, nil
+}
+
+// lastSentMagic begins the serialized last sent times.
+var lastSentMagic = []byte("gcsent")
+
+// lastSentVersion is the version of the serialized last sent times format.
+const lastSentVersion = 1
+
+// pieceCountsMagic begins the serialized piece counts.
+var pieceCountsMagic = []byte("gccounts")
+
+// pieceCountsVersion is the version of the serialized piece counts format.
+const pieceCountsVersion = 1
+
+// retainInfoMagic begins every serialized RetainInfo.
+var retainInfoMagic = []byte("gcretain")
+
+// retainInfoVersion is the version of the serialized RetainInfo format.
+// Version 1 contained a single filter.
+const retainInfoVersion = 2
+
+// MarshalRetainInfo serializes the RetainInfo as a header containing the piece
+// count and the number of filters, followed by the retain request for every
+// filter, each prefixed with its length. It is the format filters are
+// persisted in, so that tools can inspect them.
+func MarshalRetainInfo(info *RetainInfo) (_ []byte, err error) {
+	defer func() { err = Error.Wrap(err) }()
+
+	filters := info.Parts
+	if len(filters) == 0 {
+		filters = []*bloomfilter.Filter{info.Filter}
+	}
+
+	data := make([]byte, 0, len(retainInfoMagic)+1+2*binary.MaxVarintLen64)
+	data = append(data, retainInfoMagic...)
+	data = append(data, retainInfoVersion)
+	data = appendUvarint(data, uint64(info.Count))
+	data = appendUvarint(data, uint64(len(filters)))
+	for _, filter := range filters {
+		request, err := pb.Marshal(&pb.RetainRequest{
+			CreationDate: info.CreationDate,
+			Filter:       filter.Bytes(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		data = appendUvarint(data, uint64(len(request)))
+		data = append(data, request...)
+	}
+	return data, nil
+}
+
+// UnmarshalRetainInfo parses a RetainInfo serialized by MarshalRetainInfo.
+func UnmarshalRetainInfo(data []byte) (_ *RetainInfo, err error) {
+	defer func() { err = Error.Wrap(err) }()
+
+	if !bytes.HasPrefix(data, retainInfoMagic) {
+		return nil, errs.New("missing header")
+	}
+	data = data[len(retainInfoMagic):]
+
+	if len(data) < 1 || (data[0] != 1 && data[0] != retainInfoVersion) {
+		return nil, errs.New("unsupported version")
+	}
+	legacy := data[0] == 1
+	data = data[1:]
+
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, errs.New("invalid piece count")
+	}
+	data = data[n:]
+
+	info := &RetainInfo{Count: int(count)}
+	if legacy {
+		filter, creationDate, err := unmarshalRetainRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		info.Filter, info.CreationDate = filter, creationDate
+		return info, nil
+	}
+
+	filterCount, n := binary.Uvarint(data)
+	if n <= 0 || filterCount == 0 || filterCount > 1<<retainfilter.MaxBits || filterCount&(filterCount-1) != 0 {
+		return nil, errs.New("invalid filter count")
+	}
+	data = data[n:]
+
+	filters := make([]*bloomfilter.Filter, 0, filterCount)
+	for i := uint64(0); i < filterCount; i++ {
+		length, n := binary.Uvarint(data)
+		if n <= 0 || length > uint64(len(data)-n) {
+			return nil, errs.New("invalid filter length")
+		}
+		data = data[n:]
+
+		filter, creationDate, err := unmarshalRetainRequest(data[:length])
+		if err != nil {
+			return nil, err
+		}
+		data = data[length:]
+
+		filters = append(filters, filter)
+		info.CreationDate = creationDate
+	}
+	if len(data) > 0 {
+		return nil, errs.New("unexpected data after filters")
+	}
+
+	if len(filters) == 1 {
+		info.Filter = filters[0]
+	} else {
+		info.Parts = filters
+	}
+	return info, nil
+}
+
+// unmarshalRetainRequest parses a serialized retain request.
+func unmarshalRetainRequest(data []byte) (*bloomfilter.Filter, time.Time, error) {
+	var request pb.RetainRequest
+	if err := pb.Unmarshal(data, &request); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	filter, err := bloomfilter.NewFromBytes(request.Filter)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return filter, request.CreationDate, nil
+}
+
+func appendUvarint(data []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(data, buf[:binary.PutUvarint(buf[:], v)]...)
+}
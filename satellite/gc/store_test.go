@@ -0,0 +1,411 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/bloomfilter"
+	"storj.io/common/memory"
+	"storj.io/common/pb"
+	"storj.io/common/rpc"
+	"storj.io/common/storj"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/storj/satellite/metabase"
+	"storj.io/storj/satellite/metabase/segmentloop"
+)
+
+func newTestRetainInfo(t *testing.T, creationDate time.Time, pieces ...storj.PieceID) *RetainInfo {
+	filter := bloomfilter.NewOptimal(len(pieces)+1, 0.000000001)
+	for _, piece := range pieces {
+		filter.Add(piece)
+	}
+	return &RetainInfo{
+		Filter:       filter,
+		CreationDate: creationDate,
+		Count:        len(pieces),
+	}
+}
+
+func requireRetainInfosEqual(t *testing.T, expected, actual map[storj.NodeID]*RetainInfo) {
+	require.Len(t, actual, len(expected))
+	for id, info := range expected {
+		got, ok := actual[id]
+		require.True(t, ok, "missing node %s", id)
+		require.True(t, info.CreationDate.Equal(got.CreationDate))
+		require.Equal(t, info.Count, got.Count)
+		require.Len(t, got.Parts, len(info.Parts))
+		if len(info.Parts) == 0 {
+			require.Equal(t, info.Filter.Bytes(), got.Filter.Bytes())
+		}
+		for i, part := range info.Parts {
+			require.Equal(t, part.Bytes(), got.Parts[i].Bytes())
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := testcontext.New(t)
+	store := NewStore(ctx.Dir("gc"))
+
+	// nothing is loaded before anything is saved
+	infos, err := store.Load(ctx)
+	require.NoError(t, err)
+	require.Empty(t, infos)
+
+	creationDate := time.Now().Add(-time.Hour)
+	first := map[storj.NodeID]*RetainInfo{
+		testrand.NodeID(): newTestRetainInfo(t, creationDate, testrand.PieceID(), testrand.PieceID()),
+		testrand.NodeID(): newTestRetainInfo(t, creationDate, testrand.PieceID()),
+	}
+	require.NoError(t, store.Save(ctx, first))
+
+	infos, err = store.Load(ctx)
+	require.NoError(t, err)
+	requireRetainInfosEqual(t, first, infos)
+
+	// removing a node leaves the rest
+	for id := range first {
+		require.NoError(t, store.Remove(ctx, id))
+		require.NoError(t, store.Remove(ctx, id))
+		delete(first, id)
+		break
+	}
+	infos, err = store.Load(ctx)
+	require.NoError(t, err)
+	requireRetainInfosEqual(t, first, infos)
+
+	// saving again replaces everything that was not sent
+	second := map[storj.NodeID]*RetainInfo{
+		testrand.NodeID(): newTestRetainInfo(t, time.Now(), testrand.PieceID()),
+	}
+	require.NoError(t, store.Save(ctx, second))
+
+	infos, err = store.Load(ctx)
+	require.NoError(t, err)
+	requireRetainInfosEqual(t, second, infos)
+}
+
+func TestStoreInterruptedSave(t *testing.T) {
+	ctx := testcontext.New(t)
+	dir := ctx.Dir("gc")
+	store := NewStore(dir)
+
+	saved := map[storj.NodeID]*RetainInfo{
+		testrand.NodeID(): newTestRetainInfo(t, time.Now(), testrand.PieceID()),
+	}
+	require.NoError(t, store.Save(ctx, saved))
+
+	// a partially written generation is ignored
+	partial := filepath.Join(dir, generatingPrefix+"1")
+	require.NoError(t, os.Mkdir(partial, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(partial, testrand.NodeID().String()+filterExt), []byte("garbage"), 0600))
+
+	infos, err := store.Load(ctx)
+	require.NoError(t, err)
+	requireRetainInfosEqual(t, saved, infos)
+	_, err = os.Stat(partial)
+	require.True(t, os.IsNotExist(err))
+
+	// a completely written generation whose promotion was interrupted after
+	// the old pending filters were removed is promoted.
+	require.NoError(t, os.Rename(filepath.Join(dir, pendingDir), filepath.Join(dir, generatingPrefix+"2")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, generatingPrefix+"2", completeMarker), nil, 0600))
+
+	infos, err = store.Load(ctx)
+	require.NoError(t, err)
+	requireRetainInfosEqual(t, saved, infos)
+}
+
+func TestRetainInfoMarshal(t *testing.T) {
+	info := newTestRetainInfo(t, time.Now(), testrand.PieceID(), testrand.PieceID(), testrand.PieceID())
+
+	data, err := MarshalRetainInfo(info)
+	require.NoError(t, err)
+
+	got, err := UnmarshalRetainInfo(data)
+	require.NoError(t, err)
+	requireRetainInfosEqual(t,
+		map[storj.NodeID]*RetainInfo{{}: info},
+		map[storj.NodeID]*RetainInfo{{}: got})
+
+	split := newRetainInfo(Config{
+		FalsePositiveRate:   0.1,
+		MaxBloomFilterSize:  memory.KiB,
+		SplitMinimumVersion: "v1.0.0",
+	}, 10000)
+	split.CreationDate = time.Now()
+	for i := 0; i < 1000; i++ {
+		split.add(testrand.PieceID())
+		split.Count++
+	}
+	require.NotEmpty(t, split.Parts)
+
+	splitData, err := MarshalRetainInfo(split)
+	require.NoError(t, err)
+	got, err = UnmarshalRetainInfo(splitData)
+	require.NoError(t, err)
+	requireRetainInfosEqual(t,
+		map[storj.NodeID]*RetainInfo{{}: split},
+		map[storj.NodeID]*RetainInfo{{}: got})
+
+	// filters persisted by a previous version can still be sent.
+	request, err := pb.Marshal(&pb.RetainRequest{CreationDate: info.CreationDate, Filter: info.Filter.Bytes()})
+	require.NoError(t, err)
+	legacy := append(append([]byte{}, retainInfoMagic...), 1)
+	legacy = appendUvarint(legacy, uint64(info.Count))
+	legacy = append(legacy, request...)
+	got, err = UnmarshalRetainInfo(legacy)
+	require.NoError(t, err)
+	requireRetainInfosEqual(t,
+		map[storj.NodeID]*RetainInfo{{}: info},
+		map[storj.NodeID]*RetainInfo{{}: got})
+
+	for _, invalid := range [][]byte{
+		nil,
+		data[:len(retainInfoMagic)],
+		data[:len(retainInfoMagic)+1],
+		data[:len(data)-1],
+		append(append([]byte{}, data...), 0),
+		splitData[:len(splitData)-1],
+		append(append([]byte{}, retainInfoMagic...), 3),
+	} {
+		_, err := UnmarshalRetainInfo(invalid)
+		require.Error(t, err)
+	}
+}
+
+func TestRetainInfoContains(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config Config
+		pieces int
+	}{
+		{
+			name:   "single filter",
+			config: Config{FalsePositiveRate: 0.1, InitialPieces: 1000},
+			pieces: 1000,
+		},
+		{
+			name:   "filter at the size cap",
+			config: Config{FalsePositiveRate: 0.001, InitialPieces: 1000, MaxBloomFilterSize: memory.KiB},
+			pieces: 3000,
+		},
+		{
+			name:   "split filter at the size cap",
+			config: Config{FalsePositiveRate: 0.1, InitialPieces: 1000, MaxBloomFilterSize: memory.KiB, SplitMinimumVersion: "v1.0.0"},
+			pieces: 10000,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			info := newRetainInfo(tt.config, tt.pieces)
+			info.CreationDate = time.Now()
+			added := make([]storj.PieceID, tt.pieces)
+			for i := range added {
+				added[i] = testrand.PieceID()
+				info.add(added[i])
+				info.Count++
+			}
+			if tt.config.MaxBloomFilterSize > 0 {
+				for _, filter := range append(info.Parts, info.Filter) {
+					if filter != nil {
+						require.LessOrEqual(t, filter.Size(), tt.config.MaxBloomFilterSize.Int64())
+					}
+				}
+			}
+
+			data, err := MarshalRetainInfo(info)
+			require.NoError(t, err)
+			got, err := UnmarshalRetainInfo(data)
+			require.NoError(t, err)
+			requireRetainInfosEqual(t,
+				map[storj.NodeID]*RetainInfo{{}: info},
+				map[storj.NodeID]*RetainInfo{{}: got})
+
+			for _, pieceID := range added {
+				require.True(t, got.Contains(pieceID))
+			}
+
+			// a filter that is full to its cap still rejects most other pieces.
+			var falsePositives int
+			for i := 0; i < 1000; i++ {
+				if got.Contains(testrand.PieceID()) {
+					falsePositives++
+				}
+			}
+			require.Less(t, falsePositives, 500)
+		})
+	}
+}
+
+func TestServiceSendPersisted(t *testing.T) {
+	ctx := testcontext.New(t)
+	log := zaptest.NewLogger(t)
+
+	config := Config{
+		ConcurrentSends: 2,
+		GenerateFilters: true,
+		SendFilters:     true,
+		PersistDir:      ctx.Dir("gc"),
+		RetryWindow:     2 * time.Hour,
+		RetryBackoff:    time.Hour,
+		RetryMaxBackoff: time.Hour,
+	}
+
+	generated := map[storj.NodeID]*RetainInfo{}
+	for i := 0; i < 5; i++ {
+		generated[testrand.NodeID()] = newTestRetainInfo(t, time.Now(), testrand.PieceID())
+	}
+	var failing storj.NodeID
+	for id := range generated {
+		failing = id
+		break
+	}
+
+	// the generating service saves the filters and then stops
+	require.NoError(t, NewService(log, config, rpc.Dialer{}, nil, nil, nil).store.Save(ctx, generated))
+
+	// a new sending service sends them all, but fails to reach one node
+	var mu sync.Mutex
+	sent := map[storj.NodeID]*RetainInfo{}
+	sender := NewService(log, config, rpc.Dialer{}, nil, nil, nil)
+	sender.sendRetain = func(ctx context.Context, id storj.NodeID, info *RetainInfo) error {
+		if id == failing {
+			return Error.New("offline")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		sent[id] = info
+		return nil
+	}
+
+	infos, err := sender.store.Load(ctx)
+	require.NoError(t, err)
+
+	summary := sender.send(ctx, infos)
+	require.Equal(t, len(generated)-1, summary.Sent)
+	require.Equal(t, 1, summary.Retrying)
+
+	mu.Lock()
+	require.Len(t, sent, len(generated)-1)
+	require.NotContains(t, sent, failing)
+	mu.Unlock()
+
+	// the unsent filter is still persisted for another sending service
+	remaining, err := sender.store.Load(ctx)
+	require.NoError(t, err)
+	requireRetainInfosEqual(t, map[storj.NodeID]*RetainInfo{failing: generated[failing]}, remaining)
+
+	states, err := sender.store.LoadStates(ctx)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	require.Equal(t, "gc: offline", states[failing].LastError)
+
+	sender = NewService(log, config, rpc.Dialer{}, nil, nil, nil)
+	sender.sendRetain = func(ctx context.Context, id storj.NodeID, info *RetainInfo) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent[id] = info
+		return nil
+	}
+
+	// the new service waits for the persisted backoff before retrying
+	nextAttempt := time.Now().Add(100 * time.Millisecond)
+	require.NoError(t, sender.store.SaveState(ctx, failing, SendState{
+		Attempts:     1,
+		FirstAttempt: time.Now(),
+		NextAttempt:  nextAttempt,
+	}))
+	summary = sender.send(ctx, remaining)
+	require.Equal(t, SendSummary{Retrying: 1}, summary)
+	require.NotContains(t, sent, failing)
+
+	time.Sleep(time.Until(nextAttempt))
+	summary = sender.send(ctx, remaining)
+	require.Equal(t, 1, summary.Sent)
+	requireRetainInfosEqual(t, generated, sent)
+
+	remaining, err = sender.store.Load(ctx)
+	require.NoError(t, err)
+	require.Empty(t, remaining)
+	states, err = sender.store.LoadStates(ctx)
+	require.NoError(t, err)
+	require.Empty(t, states)
+}
+
+func TestStorePieceCounts(t *testing.T) {
+	ctx := testcontext.New(t)
+	store := NewStore(ctx.Dir("gc"))
+
+	counts, counted, err := store.LoadPieceCounts(ctx)
+	require.NoError(t, err)
+	require.Nil(t, counts)
+	require.True(t, counted.IsZero())
+
+	saved := map[storj.NodeID]int{
+		testrand.NodeID(): 1,
+		testrand.NodeID(): 400000,
+		testrand.NodeID(): 20000000,
+	}
+	now := time.Now()
+	require.NoError(t, store.SavePieceCounts(ctx, saved, now))
+
+	counts, counted, err = store.LoadPieceCounts(ctx)
+	require.NoError(t, err)
+	require.Equal(t, saved, counts)
+	require.True(t, now.Equal(counted))
+
+	require.NoError(t, os.WriteFile(filepath.Join(ctx.Dir("gc"), pieceCountsFile), []byte("gccounts\x01"), 0600))
+	_, _, err = store.LoadPieceCounts(ctx)
+	require.Error(t, err)
+}
+
+func TestServicePieceCountsRestart(t *testing.T) {
+	ctx := testcontext.New(t)
+	log := zaptest.NewLogger(t)
+
+	config := Config{
+		InitialPieces:      10,
+		FalsePositiveRate:  0.1,
+		MaxBloomFilterSize: 2 * memory.MiB,
+		PersistDir:         ctx.Dir("gc"),
+		PieceCountsMaxAge:  24 * time.Hour,
+	}
+
+	node := testrand.NodeID()
+	counts := map[storj.NodeID]int{node: 100000}
+
+	// the first run saves the counts and then the satellite restarts
+	first := NewService(log, config, rpc.Dialer{}, nil, nil, nil)
+	require.NoError(t, first.store.SavePieceCounts(ctx, counts, time.Now()))
+
+	second := NewService(log, config, rpc.Dialer{}, nil, nil, nil)
+	loaded := second.loadPieceCounts(ctx)
+	require.Equal(t, counts, loaded)
+
+	// the filter of the second run is sized using the persisted counts
+	pieceTracker := NewPieceTracker(log, config, loaded)
+	require.NoError(t, pieceTracker.RemoteSegment(ctx, &segmentloop.Segment{
+		RootPieceID: testrand.PieceID(),
+		Pieces:      metabase.Pieces{{Number: 0, StorageNode: node}},
+	}))
+	_, expectedSize := newFilter(config, counts[node]).Parameters()
+	_, initialSize := newFilter(config, config.InitialPieces).Parameters()
+	_, size := pieceTracker.RetainInfos[node].Filter.Parameters()
+	require.Equal(t, expectedSize, size)
+	require.NotEqual(t, initialSize, size)
+
+	// counts that are too old are ignored
+	require.NoError(t, first.store.SavePieceCounts(ctx, counts, time.Now().Add(-48*time.Hour)))
+	require.Empty(t, NewService(log, config, rpc.Dialer{}, nil, nil, nil).loadPieceCounts(ctx))
+}
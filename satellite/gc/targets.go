@@ -0,0 +1,52 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"strings"
+
+	"storj.io/common/storj"
+)
+
+// NodeIDs is a list of node IDs that can be configured as a comma-separated
+// string.
+type NodeIDs []storj.NodeID
+
+// Set implements pflag.Value.
+func (ids *NodeIDs) Set(s string) error {
+	*ids = nil
+	for _, value := range strings.Split(s, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		id, err := storj.NodeIDFromString(value)
+		if err != nil {
+			return Error.New("invalid node ID %q: %w", value, err)
+		}
+		*ids = append(*ids, id)
+	}
+	return nil
+}
+
+// Type implements pflag.Value.
+func (*NodeIDs) Type() string { return "gc.NodeIDs" }
+
+// String implements pflag.Value.
+func (ids NodeIDs) String() string {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id.String())
+	}
+	return strings.Join(values, ",")
+}
+
+// set returns the node IDs as a set.
+func (ids NodeIDs) set() map[storj.NodeID]struct{} {
+	set := make(map[storj.NodeID]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/rpc"
+	"storj.io/common/storj"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/storj/satellite/metabase"
+	"storj.io/storj/satellite/metabase/segmentloop"
+)
+
+func TestNodeIDs(t *testing.T) {
+	a, b := testrand.NodeID(), testrand.NodeID()
+
+	var ids NodeIDs
+	require.NoError(t, ids.Set(""))
+	require.Empty(t, ids)
+	require.Equal(t, "", ids.String())
+
+	require.NoError(t, ids.Set(" "+a.String()+", "+b.String()+",,"))
+	require.Equal(t, NodeIDs{a, b}, ids)
+	require.Equal(t, a.String()+","+b.String(), ids.String())
+
+	require.Error(t, ids.Set(a.String()+",invalid"))
+}
+
+func TestPieceTrackerTargets(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	target, other := testrand.NodeID(), testrand.NodeID()
+	pieceTracker := NewPieceTracker(zaptest.NewLogger(t), Config{
+		InitialPieces:     10,
+		FalsePositiveRate: 0.1,
+		TargetNodesOnly:   true,
+		TargetNodes:       NodeIDs{target},
+	}, map[storj.NodeID]int{})
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, pieceTracker.RemoteSegment(ctx, &segmentloop.Segment{
+			RootPieceID: testrand.PieceID(),
+			Pieces: metabase.Pieces{
+				{Number: 0, StorageNode: target},
+				{Number: 1, StorageNode: other},
+			},
+		}))
+	}
+
+	require.Len(t, pieceTracker.RetainInfos, 1)
+	require.Equal(t, 5, pieceTracker.RetainInfos[target].Count)
+	require.NotContains(t, pieceTracker.RetainInfos, other)
+}
+
+func TestServiceTargetsValidation(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	for _, config := range []Config{
+		{Enabled: true, GenerateFilters: true, SendFilters: true, TargetNodesOnly: true},
+		{Enabled: true, GenerateFilters: true, PersistDir: ctx.Dir("gc"), TargetNodesOnly: true, TargetNodes: NodeIDs{testrand.NodeID()}},
+	} {
+		service := NewService(zaptest.NewLogger(t), config, rpc.Dialer{}, nil, nil, nil)
+		err := service.Run(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "target nodes")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"math/rand"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/storj"
+	"storj.io/storj/satellite/metabase"
+)
+
+// pieceSample is a piece that must be retained by the filter of its node.
+type pieceSample struct {
+	rootPieceID storj.PieceID
+	piece       metabase.Piece
+}
+
+// sample adds the piece to the samples. The pieces are reservoir sampled, so
+// that every piece is equally likely to be verified.
+func (pieceTracker *PieceTracker) sample(rootPieceID storj.PieceID, piece metabase.Piece) {
+	limit := pieceTracker.config.VerifySamplePieces
+	if limit <= 0 {
+		return
+	}
+	if len(pieceTracker.samples) < limit {
+		pieceTracker.samples = append(pieceTracker.samples, pieceSample{rootPieceID, piece})
+		return
+	}
+	// pieces has already been incremented for this piece.
+	if i := rand.Int63n(pieceTracker.pieces); i < int64(limit) {
+		pieceTracker.samples[i] = pieceSample{rootPieceID, piece}
+	}
+}
+
+// verifyFilters checks that the filters retain the sampled pieces, whose IDs
+// are derived again for the check. A missing piece means that the filters are
+// broken and would make nodes delete pieces that are not garbage, so they
+// must not be sent.
+func (service *Service) verifyFilters(infos map[storj.NodeID]*RetainInfo, samples []pieceSample) error {
+	var missing int
+	for _, sample := range samples {
+		pieceID := sample.rootPieceID.Derive(sample.piece.StorageNode, int32(sample.piece.Number))
+		info, ok := infos[sample.piece.StorageNode]
+		if ok && info.Contains(pieceID) {
+			continue
+		}
+		missing++
+		service.log.Error("sampled piece is missing from the retain filter",
+			zap.Stringer("Node ID", sample.piece.StorageNode),
+			zap.Stringer("Piece ID", pieceID))
+	}
+
+	mon.IntVal("retain_filter_verified_pieces").Observe(int64(len(samples)))
+	if missing > 0 {
+		mon.Meter("retain_filter_verification_failures").Mark(missing)
+		return Error.New("%d of %d sampled pieces are missing from the retain filters", missing, len(samples))
+	}
+	return nil
+}
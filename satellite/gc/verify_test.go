@@ -0,0 +1,79 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gc
+
+import (
+	"testing"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/memory"
+	"storj.io/common/rpc"
+	"storj.io/common/storj"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/storj/satellite/metabase"
+	"storj.io/storj/satellite/metabase/segmentloop"
+)
+
+func TestVerifyFilters(t *testing.T) {
+	ctx := testcontext.New(t)
+
+	const samples = 100
+	nodes := []storj.NodeID{testrand.NodeID(), testrand.NodeID(), testrand.NodeID()}
+
+	track := func(config Config) *PieceTracker {
+		pieceTracker := NewPieceTracker(zaptest.NewLogger(t), config, map[storj.NodeID]int{})
+		for i := 0; i < 1000; i++ {
+			pieces := make(metabase.Pieces, len(nodes))
+			for k, node := range nodes {
+				pieces[k] = metabase.Piece{Number: uint16(k), StorageNode: node}
+			}
+			require.NoError(t, pieceTracker.RemoteSegment(ctx, &segmentloop.Segment{
+				RootPieceID: testrand.PieceID(),
+				Pieces:      pieces,
+			}))
+		}
+		return pieceTracker
+	}
+
+	config := Config{
+		InitialPieces:      1000,
+		FalsePositiveRate:  0.1,
+		VerifySamplePieces: samples,
+	}
+	service := NewService(zaptest.NewLogger(t), config, rpc.Dialer{}, nil, nil, nil)
+
+	pieceTracker := track(config)
+	require.Len(t, pieceTracker.samples, samples)
+	require.NoError(t, service.verifyFilters(pieceTracker.RetainInfos, pieceTracker.samples))
+
+	// split filters are verified by the part that covers the piece.
+	splitConfig := config
+	splitConfig.MaxBloomFilterSize = 64 * memory.B
+	splitConfig.SplitMinimumVersion = "v1.50.0"
+	split := track(splitConfig)
+	require.NotEmpty(t, split.RetainInfos[nodes[0]].Parts)
+	require.NoError(t, service.verifyFilters(split.RetainInfos, split.samples))
+
+	// a broken filter would delete pieces that are not garbage.
+	before := monkit.Collect(monkit.ScopeNamed("storj.io/storj/satellite/gc"))
+	pieceTracker.RetainInfos[nodes[1]].Filter = newFilter(config, config.InitialPieces)
+	err := service.verifyFilters(pieceTracker.RetainInfos, pieceTracker.samples)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "sampled pieces are missing")
+	after := monkit.Collect(monkit.ScopeNamed("storj.io/storj/satellite/gc"))
+	const failures = "retain_filter_verification_failures,scope=storj.io/storj/satellite/gc total"
+	require.Greater(t, after[failures], before[failures])
+
+	// a missing filter is just as broken.
+	delete(pieceTracker.RetainInfos, nodes[2])
+	require.Error(t, service.verifyFilters(pieceTracker.RetainInfos, pieceTracker.samples))
+
+	// without samples nothing is verified.
+	config.VerifySamplePieces = 0
+	require.Empty(t, track(config).samples)
+}
@@ -5,7 +5,9 @@ package metabase_test
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -17,6 +19,7 @@ import (
 	"storj.io/common/testcontext"
 	"storj.io/common/testrand"
 	"storj.io/common/uuid"
+	"storj.io/private/tagsql"
 	"storj.io/storj/satellite/metabase"
 	"storj.io/storj/satellite/metabase/metabasetest"
 )
@@ -347,6 +350,91 @@ func (s *scenario) run(ctx *testcontext.Context, b *testing.B, db *metabase.DB)
 	})
 }
 
+// BenchmarkGetLatestObjectsLastSegments compares the last segments of many
+// objects fetched one by one and in a single query.
+func BenchmarkGetLatestObjectsLastSegments(b *testing.B) {
+	objectCount := 1000
+	if testing.Short() {
+		objectCount = 10
+	}
+
+	metabasetest.Bench(b, func(ctx *testcontext.Context, b *testing.B, db *metabase.DB) {
+		counter := &queryCounter{}
+		db.TestingWrapTagSQL(func(db tagsql.DB) tagsql.DB {
+			counter.DB = db
+			return counter
+		})
+
+		projectID := testrand.UUID()
+		bucketName := testrand.BucketName()
+
+		locations := make([]metabase.ObjectLocation, objectCount)
+		for i := range locations {
+			obj := metabasetest.RandObjectStream()
+			obj.ProjectID = projectID
+			obj.BucketName = bucketName
+			metabasetest.CreateTestObject{}.Run(ctx, b, db, obj, 2)
+			locations[i] = obj.Location()
+		}
+		b.ResetTimer()
+
+		b.Run("GetLatestObjectLastSegment", func(b *testing.B) {
+			counter.Reset()
+			for i := 0; i < b.N; i++ {
+				for _, location := range locations {
+					_, err := db.GetLatestObjectLastSegment(ctx, metabase.GetLatestObjectLastSegment{
+						ObjectLocation: location,
+					})
+					require.NoError(b, err)
+				}
+			}
+			counter.Report(b)
+		})
+
+		b.Run("GetLatestObjectsLastSegments", func(b *testing.B) {
+			counter.Reset()
+			for i := 0; i < b.N; i++ {
+				results, err := db.GetLatestObjectsLastSegments(ctx, metabase.GetLatestObjectsLastSegments{
+					Locations: locations,
+				})
+				require.NoError(b, err)
+				require.Len(b, results, len(locations))
+			}
+			counter.Report(b)
+		})
+	})
+}
+
+// queryCounter counts the queries that run on the database outside of
+// transactions.
+type queryCounter struct {
+	tagsql.DB
+	queries int64
+}
+
+func (counter *queryCounter) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	atomic.AddInt64(&counter.queries, 1)
+	return counter.DB.ExecContext(ctx, query, args...)
+}
+
+func (counter *queryCounter) QueryContext(ctx context.Context, query string, args ...interface{}) (tagsql.Rows, error) {
+	atomic.AddInt64(&counter.queries, 1)
+	return counter.DB.QueryContext(ctx, query, args...)
+}
+
+func (counter *queryCounter) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	atomic.AddInt64(&counter.queries, 1)
+	return counter.DB.QueryRowContext(ctx, query, args...)
+}
+
+// Reset starts counting from zero.
+func (counter *queryCounter) Reset() { atomic.StoreInt64(&counter.queries, 0) }
+
+// Report reports the queries per operation of the benchmark.
+func (counter *queryCounter) Report(b *testing.B) {
+	b.ReportMetric(float64(atomic.LoadInt64(&counter.queries))/float64(b.N), "queries/op")
+}
+
 // Metrics records a set of time.Durations.
 type Metrics []time.Duration
 
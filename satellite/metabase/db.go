@@ -7,6 +7,7 @@ package metabase
 import (
 	"context"
 	"fmt"
+	"io"
 	"strconv"
 	"time"
 
@@ -31,6 +32,18 @@ var (
 type Config struct {
 	MinPartSize      memory.Size
 	MaxNumberOfParts int
+
+	// MigrationNoWait makes MigrateToLatest fail instead of waiting, when
+	// another process is migrating the database.
+	MigrationNoWait bool
+	// MigrationIgnoreChecksumMismatch makes MigrateToLatest only log the
+	// applied migration steps that were changed since, instead of failing.
+	MigrationIgnoreChecksumMismatch bool
+
+	// TestingAllLimit limits how many objects or segments the testing
+	// helpers that load all of them into memory accept, they fail beyond it.
+	// Zero uses DefaultTestingAllLimit.
+	TestingAllLimit int
 }
 
 // DB implements a database for storing objects and segments.
@@ -96,6 +109,12 @@ func (db *DB) Ping(ctx context.Context) error {
 	return Error.Wrap(db.db.PingContext(ctx))
 }
 
+// TestingWrapTagSQL replaces the connection the queries run on with a wrapper
+// of it, e.g. to count the queries in benchmarks.
+func (db *DB) TestingWrapTagSQL(wrap func(tagsql.DB) tagsql.DB) {
+	db.db = wrap(db.db)
+}
+
 // TestingSetCleanup is used to set the callback for cleaning up test database.
 func (db *DB) TestingSetCleanup(cleanup func() error) {
 	db.testCleanup = cleanup
@@ -155,6 +174,8 @@ func (db *DB) MigrateToLatest(ctx context.Context) error {
 	}
 
 	migration := db.PostgresMigration()
+	migration.NoWait = db.config.MigrationNoWait
+	migration.IgnoreChecksumMismatch = db.config.MigrationIgnoreChecksumMismatch
 	return migration.Run(ctx, db.log.Named("migrate"))
 }
 
@@ -164,6 +185,39 @@ func (db *DB) CheckVersion(ctx context.Context) error {
 	return migration.ValidateVersions(ctx, db.log)
 }
 
+// PlanMigration writes the pending migration steps to w without executing them.
+func (db *DB) PlanMigration(ctx context.Context, w io.Writer) error {
+	migration := db.PostgresMigration()
+	return migration.Plan(ctx, db.log, w)
+}
+
+// PlanMigrationJSON returns the pending migration steps as JSON without
+// executing them, see migrate.Migration.PlanJSON.
+func (db *DB) PlanMigrationJSON(ctx context.Context) ([]byte, error) {
+	migration := db.PostgresMigration()
+	status, err := migration.Status(ctx, db.log)
+	if err != nil {
+		return nil, err
+	}
+	return migration.PlanJSON(status.Current)
+}
+
+// MigrationStatus returns the state of the migration of the database, without changing it.
+func (db *DB) MigrationStatus(ctx context.Context) (migrate.Status, error) {
+	migration := db.PostgresMigration()
+	return migration.Status(ctx, db.log)
+}
+
+// MigrationTestingComponents returns the migratable components of the
+// metabase for testing their migrations.
+func (db *DB) MigrationTestingComponents() []migrate.Component {
+	return []migrate.Component{{
+		Name:      "metabase",
+		Migration: db.PostgresMigration(),
+		DB:        db.db,
+	}}
+}
+
 // PostgresMigration returns steps needed for migrating postgres database.
 func (db *DB) PostgresMigration() *migrate.Migration {
 	// TODO: merge this with satellite migration code or a way to keep them in sync.
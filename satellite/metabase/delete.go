@@ -11,6 +11,7 @@ import (
 	"github.com/zeebo/errs"
 
 	"storj.io/common/storj"
+	"storj.io/common/uuid"
 	"storj.io/private/dbutil"
 	"storj.io/private/dbutil/pgutil"
 	"storj.io/private/tagsql"
@@ -20,6 +21,9 @@ import (
 type DeleteObjectExactVersion struct {
 	Version Version
 	ObjectLocation
+
+	// SkipPieces skips collecting the pieces of the deleted segments.
+	SkipPieces bool
 }
 
 // Verify delete object fields.
@@ -37,6 +41,9 @@ func (obj *DeleteObjectExactVersion) Verify() error {
 type DeleteObjectResult struct {
 	Objects  []Object
 	Segments []DeletedSegmentInfo
+	// Deleted is the info about the deleted segments of every deleted
+	// object, in the order of Objects.
+	Deleted []DeletedObjectInfo
 }
 
 // DeletedSegmentInfo info about deleted segment.
@@ -45,14 +52,38 @@ type DeletedSegmentInfo struct {
 	Pieces      Pieces
 }
 
+// DeletedObjectInfo info about the deleted segments of a deleted object.
+type DeletedObjectInfo struct {
+	StreamID uuid.UUID
+	// SegmentCount is the number of deleted segments.
+	SegmentCount int
+	// EncryptedSize is the total encrypted size of the deleted segments.
+	EncryptedSize int64
+	// Pieces are the remote pieces of the deleted segments, they are not
+	// collected when the deletion skips the pieces.
+	Pieces []DeletedPiece
+}
+
+// DeletedPiece is a remote piece of a deleted segment.
+type DeletedPiece struct {
+	StorageNode storj.NodeID
+	PieceID     storj.PieceID
+}
+
 // DeleteObjectAnyStatusAllVersions contains arguments necessary for deleting all object versions.
 type DeleteObjectAnyStatusAllVersions struct {
 	ObjectLocation
+
+	// SkipPieces skips collecting the pieces of the deleted segments.
+	SkipPieces bool
 }
 
 // DeleteObjectsAllVersions contains arguments necessary for deleting all versions of multiple objects from the same bucket.
 type DeleteObjectsAllVersions struct {
 	Locations []ObjectLocation
+
+	// SkipPieces skips collecting the pieces of the deleted segments.
+	SkipPieces bool
 }
 
 // Verify delete objects fields.
@@ -89,6 +120,9 @@ func (delete *DeleteObjectsAllVersions) Verify() error {
 // DeleteObjectLatestVersion contains arguments necessary for deleting latest object version.
 type DeleteObjectLatestVersion struct {
 	ObjectLocation
+
+	// SkipPieces skips collecting the pieces of the deleted segments.
+	SkipPieces bool
 }
 
 // DeleteObjectExactVersion deletes an exact object version.
@@ -117,7 +151,7 @@ func (db *DB) DeleteObjectExactVersion(ctx context.Context, opts DeleteObjectExa
 			), deleted_segments AS (
 				DELETE FROM segments
 				WHERE segments.stream_id in (SELECT deleted_objects.stream_id FROM deleted_objects)
-				RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces
+				RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces, segments.encrypted_size
 			)
 			SELECT
 				deleted_objects.version, deleted_objects.stream_id,
@@ -126,11 +160,11 @@ func (db *DB) DeleteObjectExactVersion(ctx context.Context, opts DeleteObjectExa
 				deleted_objects.encrypted_metadata_nonce, deleted_objects.encrypted_metadata, deleted_objects.encrypted_metadata_encrypted_key,
 				deleted_objects.total_plain_size, deleted_objects.total_encrypted_size, deleted_objects.fixed_segment_size,
 				deleted_objects.encryption,
-				deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces
+				deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces, deleted_segments.encrypted_size
 			FROM deleted_objects
 			LEFT JOIN deleted_segments ON deleted_objects.stream_id = deleted_segments.stream_id
 		`, opts.ProjectID, []byte(opts.BucketName), opts.ObjectKey, opts.Version))(func(rows tagsql.Rows) error {
-		result.Objects, result.Segments, err = db.scanObjectDeletion(ctx, opts.ObjectLocation, rows)
+		result, err = db.scanObjectDeletion(ctx, opts.ObjectLocation, opts.SkipPieces, rows)
 		return err
 	})
 	if err != nil {
@@ -150,6 +184,9 @@ func (db *DB) DeleteObjectExactVersion(ctx context.Context, opts DeleteObjectExa
 // DeletePendingObject contains arguments necessary for deleting a pending object.
 type DeletePendingObject struct {
 	ObjectStream
+
+	// SkipPieces skips collecting the pieces of the deleted segments.
+	SkipPieces bool
 }
 
 // Verify verifies delete pending object fields validity.
@@ -188,7 +225,7 @@ func (db *DB) DeletePendingObject(ctx context.Context, opts DeletePendingObject)
 			), deleted_segments AS (
 				DELETE FROM segments
 				WHERE segments.stream_id in (SELECT deleted_objects.stream_id FROM deleted_objects)
-				RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces
+				RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces, segments.encrypted_size
 			)
 			SELECT
 				deleted_objects.version, deleted_objects.stream_id,
@@ -197,11 +234,11 @@ func (db *DB) DeletePendingObject(ctx context.Context, opts DeletePendingObject)
 				deleted_objects.encrypted_metadata_nonce, deleted_objects.encrypted_metadata, deleted_objects.encrypted_metadata_encrypted_key,
 				deleted_objects.total_plain_size, deleted_objects.total_encrypted_size, deleted_objects.fixed_segment_size,
 				deleted_objects.encryption,
-				deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces
+				deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces, deleted_segments.encrypted_size
 			FROM deleted_objects
 			LEFT JOIN deleted_segments ON deleted_objects.stream_id = deleted_segments.stream_id
 		`, opts.ProjectID, []byte(opts.BucketName), opts.ObjectKey, opts.Version, opts.StreamID))(func(rows tagsql.Rows) error {
-		result.Objects, result.Segments, err = db.scanObjectDeletion(ctx, opts.Location(), rows)
+		result, err = db.scanObjectDeletion(ctx, opts.Location(), opts.SkipPieces, rows)
 		return err
 	})
 
@@ -250,7 +287,7 @@ func (db *DB) DeleteObjectLatestVersion(ctx context.Context, opts DeleteObjectLa
 			), deleted_segments AS (
 				DELETE FROM segments
 				WHERE segments.stream_id in (SELECT deleted_objects.stream_id FROM deleted_objects)
-				RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces
+				RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces, segments.encrypted_size
 			)
 			SELECT
 				deleted_objects.version, deleted_objects.stream_id,
@@ -259,7 +296,7 @@ func (db *DB) DeleteObjectLatestVersion(ctx context.Context, opts DeleteObjectLa
 				deleted_objects.encrypted_metadata_nonce, deleted_objects.encrypted_metadata, deleted_objects.encrypted_metadata_encrypted_key,
 				deleted_objects.total_plain_size, deleted_objects.total_encrypted_size, deleted_objects.fixed_segment_size,
 				deleted_objects.encryption,
-				deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces
+				deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces, deleted_segments.encrypted_size
 			FROM deleted_objects
 			LEFT JOIN deleted_segments ON deleted_objects.stream_id = deleted_segments.stream_id
 		`
@@ -291,7 +328,7 @@ func (db *DB) DeleteObjectLatestVersion(ctx context.Context, opts DeleteObjectLa
 			), deleted_segments AS (
 				DELETE FROM segments
 				WHERE segments.stream_id in (SELECT deleted_objects.stream_id FROM deleted_objects)
-				RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces
+				RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces, segments.encrypted_size
 			)
 			SELECT
 				deleted_objects.version, deleted_objects.stream_id,
@@ -300,7 +337,7 @@ func (db *DB) DeleteObjectLatestVersion(ctx context.Context, opts DeleteObjectLa
 				deleted_objects.encrypted_metadata_nonce, deleted_objects.encrypted_metadata, deleted_objects.encrypted_metadata_encrypted_key,
 				deleted_objects.total_plain_size, deleted_objects.total_encrypted_size, deleted_objects.fixed_segment_size,
 				deleted_objects.encryption,
-				deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces
+				deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces, deleted_segments.encrypted_size
 			FROM deleted_objects
 			LEFT JOIN deleted_segments ON deleted_objects.stream_id = deleted_segments.stream_id
 		`
@@ -308,7 +345,7 @@ func (db *DB) DeleteObjectLatestVersion(ctx context.Context, opts DeleteObjectLa
 		return DeleteObjectResult{}, Error.New("unhandled database: %v", db.impl)
 	}
 	err = withRows(db.db.QueryContext(ctx, query, opts.ProjectID, []byte(opts.BucketName), opts.ObjectKey))(func(rows tagsql.Rows) error {
-		result.Objects, result.Segments, err = db.scanObjectDeletion(ctx, opts.ObjectLocation, rows)
+		result, err = db.scanObjectDeletion(ctx, opts.ObjectLocation, opts.SkipPieces, rows)
 		return err
 	})
 
@@ -351,7 +388,7 @@ func (db *DB) DeleteObjectAnyStatusAllVersions(ctx context.Context, opts DeleteO
 			), deleted_segments AS (
 				DELETE FROM segments
 				WHERE segments.stream_id in (SELECT deleted_objects.stream_id FROM deleted_objects)
-				RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces
+				RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces, segments.encrypted_size
 			)
 			SELECT
 				deleted_objects.version, deleted_objects.stream_id,
@@ -360,11 +397,11 @@ func (db *DB) DeleteObjectAnyStatusAllVersions(ctx context.Context, opts DeleteO
 				deleted_objects.encrypted_metadata_nonce, deleted_objects.encrypted_metadata, deleted_objects.encrypted_metadata_encrypted_key,
 				deleted_objects.total_plain_size, deleted_objects.total_encrypted_size, deleted_objects.fixed_segment_size,
 				deleted_objects.encryption,
-				deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces
+				deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces, deleted_segments.encrypted_size
 			FROM deleted_objects
 			LEFT JOIN deleted_segments ON deleted_objects.stream_id = deleted_segments.stream_id
 		`, opts.ProjectID, []byte(opts.BucketName), opts.ObjectKey))(func(rows tagsql.Rows) error {
-		result.Objects, result.Segments, err = db.scanObjectDeletion(ctx, opts.ObjectLocation, rows)
+		result, err = db.scanObjectDeletion(ctx, opts.ObjectLocation, opts.SkipPieces, rows)
 		return err
 	})
 
@@ -428,7 +465,7 @@ func (db *DB) DeleteObjectsAllVersions(ctx context.Context, opts DeleteObjectsAl
 				), deleted_segments AS (
 					DELETE FROM segments
 					WHERE segments.stream_id in (SELECT deleted_objects.stream_id FROM deleted_objects)
-					RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces
+					RETURNING segments.stream_id,segments.root_piece_id, segments.remote_alias_pieces, segments.encrypted_size
 				)
 				SELECT
 					deleted_objects.project_id, deleted_objects.bucket_name,
@@ -438,11 +475,11 @@ func (db *DB) DeleteObjectsAllVersions(ctx context.Context, opts DeleteObjectsAl
 					deleted_objects.encrypted_metadata_nonce, deleted_objects.encrypted_metadata, deleted_objects.encrypted_metadata_encrypted_key,
 					deleted_objects.total_plain_size, deleted_objects.total_encrypted_size, deleted_objects.fixed_segment_size,
 					deleted_objects.encryption,
-					deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces
+					deleted_segments.root_piece_id, deleted_segments.remote_alias_pieces, deleted_segments.encrypted_size
 				FROM deleted_objects
 				LEFT JOIN deleted_segments ON deleted_objects.stream_id = deleted_segments.stream_id
 			`, projectID, []byte(bucketName), pgutil.ByteaArray(objectKeys)))(func(rows tagsql.Rows) error {
-		result.Objects, result.Segments, err = db.scanMultipleObjectsDeletion(ctx, rows)
+		result, err = db.scanMultipleObjectsDeletion(ctx, opts.SkipPieces, rows)
 		return err
 	})
 
@@ -456,16 +493,16 @@ func (db *DB) DeleteObjectsAllVersions(ctx context.Context, opts DeleteObjectsAl
 	return result, nil
 }
 
-func (db *DB) scanObjectDeletion(ctx context.Context, location ObjectLocation, rows tagsql.Rows) (objects []Object, segments []DeletedSegmentInfo, err error) {
+func (db *DB) scanObjectDeletion(ctx context.Context, location ObjectLocation, skipPieces bool, rows tagsql.Rows) (result DeleteObjectResult, err error) {
 	defer mon.Task()(&ctx)(&err)
 	defer func() { err = errs.Combine(err, rows.Close()) }()
 
-	objects = make([]Object, 0, 10)
-	segments = make([]DeletedSegmentInfo, 0, 10)
+	result.Objects = make([]Object, 0, 10)
+	result.Segments = make([]DeletedSegmentInfo, 0, 10)
 
 	var rootPieceID *storj.PieceID
+	var encryptedSize *int32
 	var object Object
-	var segment DeletedSegmentInfo
 	var aliasPieces AliasPieces
 
 	for rows.Next() {
@@ -479,45 +516,37 @@ func (db *DB) scanObjectDeletion(ctx context.Context, location ObjectLocation, r
 			&object.Status, &object.SegmentCount,
 			&object.EncryptedMetadataNonce, &object.EncryptedMetadata, &object.EncryptedMetadataEncryptedKey,
 			&object.TotalPlainSize, &object.TotalEncryptedSize, &object.FixedSegmentSize,
-			encryptionParameters{&object.Encryption}, &rootPieceID, &aliasPieces)
+			encryptionParameters{&object.Encryption}, &rootPieceID, &aliasPieces, &encryptedSize)
 		if err != nil {
-			return nil, nil, Error.New("unable to delete object: %w", err)
+			return DeleteObjectResult{}, Error.New("unable to delete object: %w", err)
 		}
-		if len(objects) == 0 || objects[len(objects)-1].StreamID != object.StreamID {
-			objects = append(objects, object)
-		}
-		if rootPieceID != nil {
-			segment.RootPieceID = *rootPieceID
-			segment.Pieces, err = db.aliasCache.ConvertAliasesToPieces(ctx, aliasPieces)
-			if err != nil {
-				return nil, nil, Error.Wrap(err)
-			}
-			if len(segment.Pieces) > 0 {
-				segments = append(segments, segment)
-			}
+
+		err = db.addDeletion(ctx, &result, object, rootPieceID, aliasPieces, encryptedSize, skipPieces)
+		if err != nil {
+			return DeleteObjectResult{}, err
 		}
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, nil, Error.New("unable to delete object: %w", err)
+		return DeleteObjectResult{}, Error.New("unable to delete object: %w", err)
 	}
 
-	if len(segments) == 0 {
-		return objects, nil, nil
+	if len(result.Segments) == 0 {
+		result.Segments = nil
 	}
-	return objects, segments, nil
+	return result, nil
 }
 
-func (db *DB) scanMultipleObjectsDeletion(ctx context.Context, rows tagsql.Rows) (objects []Object, segments []DeletedSegmentInfo, err error) {
+func (db *DB) scanMultipleObjectsDeletion(ctx context.Context, skipPieces bool, rows tagsql.Rows) (result DeleteObjectResult, err error) {
 	defer mon.Task()(&ctx)(&err)
 	defer func() { err = errs.Combine(err, rows.Close()) }()
 
-	objects = make([]Object, 0, 10)
-	segments = make([]DeletedSegmentInfo, 0, 10)
+	result.Objects = make([]Object, 0, 10)
+	result.Segments = make([]DeletedSegmentInfo, 0, 10)
 
 	var rootPieceID *storj.PieceID
+	var encryptedSize *int32
 	var object Object
-	var segment DeletedSegmentInfo
 	var aliasPieces AliasPieces
 
 	for rows.Next() {
@@ -527,36 +556,67 @@ func (db *DB) scanMultipleObjectsDeletion(ctx context.Context, rows tagsql.Rows)
 			&object.Status, &object.SegmentCount,
 			&object.EncryptedMetadataNonce, &object.EncryptedMetadata, &object.EncryptedMetadataEncryptedKey,
 			&object.TotalPlainSize, &object.TotalEncryptedSize, &object.FixedSegmentSize,
-			encryptionParameters{&object.Encryption}, &rootPieceID, &aliasPieces)
+			encryptionParameters{&object.Encryption}, &rootPieceID, &aliasPieces, &encryptedSize)
 		if err != nil {
-			return nil, nil, Error.New("unable to delete object: %w", err)
+			return DeleteObjectResult{}, Error.New("unable to delete object: %w", err)
 		}
 
-		if len(objects) == 0 || objects[len(objects)-1].StreamID != object.StreamID {
-			objects = append(objects, object)
-		}
-		if rootPieceID != nil {
-			segment.RootPieceID = *rootPieceID
-			segment.Pieces, err = db.aliasCache.ConvertAliasesToPieces(ctx, aliasPieces)
-			if err != nil {
-				return nil, nil, Error.Wrap(err)
-			}
-			if len(segment.Pieces) > 0 {
-				segments = append(segments, segment)
-			}
+		err = db.addDeletion(ctx, &result, object, rootPieceID, aliasPieces, encryptedSize, skipPieces)
+		if err != nil {
+			return DeleteObjectResult{}, err
 		}
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, nil, Error.New("unable to delete object: %w", err)
+		return DeleteObjectResult{}, Error.New("unable to delete object: %w", err)
+	}
+
+	if len(result.Objects) == 0 {
+		result.Objects = nil
+	}
+	if len(result.Segments) == 0 {
+		result.Segments = nil
 	}
 
-	if len(objects) == 0 {
-		objects = nil
+	return result, nil
+}
+
+// addDeletion adds a row of a deletion query to the result, the rows of an
+// object are consecutive. rootPieceID is nil for an object without segments.
+func (db *DB) addDeletion(ctx context.Context, result *DeleteObjectResult, object Object, rootPieceID *storj.PieceID, aliasPieces AliasPieces, encryptedSize *int32, skipPieces bool) error {
+	if len(result.Objects) == 0 || result.Objects[len(result.Objects)-1].StreamID != object.StreamID {
+		result.Objects = append(result.Objects, object)
+		result.Deleted = append(result.Deleted, DeletedObjectInfo{StreamID: object.StreamID})
 	}
-	if len(segments) == 0 {
-		return objects, nil, nil
+	if rootPieceID == nil {
+		return nil
+	}
+
+	deleted := &result.Deleted[len(result.Deleted)-1]
+	deleted.SegmentCount++
+	if encryptedSize != nil {
+		deleted.EncryptedSize += int64(*encryptedSize)
 	}
 
-	return objects, segments, nil
+	pieces, err := db.aliasCache.ConvertAliasesToPieces(ctx, aliasPieces)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	if len(pieces) == 0 {
+		return nil
+	}
+	result.Segments = append(result.Segments, DeletedSegmentInfo{
+		RootPieceID: *rootPieceID,
+		Pieces:      pieces,
+	})
+
+	if !skipPieces {
+		for _, piece := range pieces {
+			deleted.Pieces = append(deleted.Pieces, DeletedPiece{
+				StorageNode: piece.StorageNode,
+				PieceID:     rootPieceID.Derive(piece.StorageNode, int32(piece.Number)),
+			})
+		}
+	}
+	return nil
 }
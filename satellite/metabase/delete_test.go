@@ -7,6 +7,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/require"
+
 	"storj.io/common/storj"
 	"storj.io/common/testcontext"
 	"storj.io/common/testrand"
@@ -1066,6 +1068,63 @@ func TestDeleteObjectsAllVersions(t *testing.T) {
 			metabasetest.Verify{}.Check(ctx, t, db)
 		})
 
+		t.Run("Delete objects returns the deleted pieces", func(t *testing.T) {
+			defer metabasetest.DeleteAll{}.Check(ctx, t, db)
+
+			obj2 := metabasetest.RandObjectStream()
+			obj2.ProjectID = obj.ProjectID
+			obj2.BucketName = obj.BucketName
+
+			for _, skipPieces := range []bool{false, true} {
+				object1 := metabasetest.CreateObject(ctx, t, db, obj, 1)
+				object2 := metabasetest.CreateObject(ctx, t, db, obj2, 2)
+
+				segments, err := db.TestingAllSegments(ctx)
+				require.NoError(t, err)
+
+				var expectedDeleted []metabase.DeletedObjectInfo
+				for _, object := range []metabase.Object{object1, object2} {
+					deleted := metabase.DeletedObjectInfo{StreamID: object.StreamID}
+					for _, segment := range segments {
+						if segment.StreamID != object.StreamID {
+							continue
+						}
+						deleted.SegmentCount++
+						deleted.EncryptedSize += int64(segment.EncryptedSize)
+						if skipPieces {
+							continue
+						}
+						for _, piece := range segment.Pieces {
+							deleted.Pieces = append(deleted.Pieces, metabase.DeletedPiece{
+								StorageNode: piece.StorageNode,
+								PieceID:     segment.RootPieceID.Derive(piece.StorageNode, int32(piece.Number)),
+							})
+						}
+					}
+					expectedDeleted = append(expectedDeleted, deleted)
+				}
+
+				expectedSegmentInfo := metabase.DeletedSegmentInfo{
+					RootPieceID: storj.PieceID{1},
+					Pieces:      metabase.Pieces{{Number: 0, StorageNode: storj.NodeID{2}}},
+				}
+
+				metabasetest.DeleteObjectsAllVersions{
+					Opts: metabase.DeleteObjectsAllVersions{
+						Locations:  []metabase.ObjectLocation{location, obj2.Location()},
+						SkipPieces: skipPieces,
+					},
+					Result: metabase.DeleteObjectResult{
+						Objects:  []metabase.Object{object1, object2},
+						Segments: []metabase.DeletedSegmentInfo{expectedSegmentInfo, expectedSegmentInfo, expectedSegmentInfo},
+						Deleted:  expectedDeleted,
+					},
+				}.Check(ctx, t, db)
+
+				metabasetest.Verify{}.Check(ctx, t, db)
+			}
+		})
+
 		t.Run("Delete object with inline segment", func(t *testing.T) {
 			defer metabasetest.DeleteAll{}.Check(ctx, t, db)
 
@@ -13,6 +13,8 @@ import (
 
 	"storj.io/common/storj"
 	"storj.io/common/uuid"
+	"storj.io/private/dbutil/pgutil"
+	"storj.io/private/tagsql"
 )
 
 // ErrSegmentNotFound is an error class for non-existing segment.
@@ -246,53 +248,158 @@ func (db *DB) GetLatestObjectLastSegment(ctx context.Context, opts GetLatestObje
 		return Segment{}, err
 	}
 
-	var aliasPieces AliasPieces
-	err = db.db.QueryRowContext(ctx, `
-		SELECT
-			stream_id, position,
-			created_at, repaired_at,
-			root_piece_id, encrypted_key_nonce, encrypted_key,
-			encrypted_size, plain_offset, plain_size,
-			encrypted_etag,
-			redundancy,
-			inline_data, remote_alias_pieces,
-			placement
-		FROM segments
-		WHERE
-			stream_id IN (SELECT stream_id FROM objects WHERE
-				project_id   = $1 AND
-				bucket_name  = $2 AND
-				object_key   = $3 AND
-				status       = `+committedStatus+`
-				ORDER BY version DESC
-				LIMIT 1
-			)
-		ORDER BY position DESC
-		LIMIT 1
-	`, opts.ProjectID, []byte(opts.BucketName), opts.ObjectKey).
-		Scan(
-			&segment.StreamID, &segment.Position,
-			&segment.CreatedAt, &segment.RepairedAt,
-			&segment.RootPieceID, &segment.EncryptedKeyNonce, &segment.EncryptedKey,
-			&segment.EncryptedSize, &segment.PlainOffset, &segment.PlainSize,
-			&segment.EncryptedETag,
-			redundancyScheme{&segment.Redundancy},
-			&segment.InlineData, &aliasPieces,
-			&segment.Placement,
-		)
+	results, err := db.GetLatestObjectsLastSegments(ctx, GetLatestObjectsLastSegments{
+		Locations: []ObjectLocation{opts.ObjectLocation},
+	})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return Segment{}, storj.ErrObjectNotFound.Wrap(Error.New("object or segment missing"))
+		return Segment{}, err
+	}
+
+	result := results[opts.ObjectLocation]
+	if !result.Found {
+		return Segment{}, storj.ErrObjectNotFound.Wrap(Error.New("object or segment missing"))
+	}
+	return result.Segment, nil
+}
+
+// GetLatestObjectsLastSegments contains arguments necessary for fetching the
+// last segments of multiple objects from the same bucket.
+type GetLatestObjectsLastSegments struct {
+	Locations []ObjectLocation
+}
+
+// Verify verifies get latest objects last segments fields.
+func (opts *GetLatestObjectsLastSegments) Verify() error {
+	if len(opts.Locations) == 0 {
+		return nil
+	}
+
+	if len(opts.Locations) > 1000 {
+		return ErrInvalidRequest.New("cannot get more than 1000 segments in a single request")
+	}
+
+	var errGroup errs.Group
+	for _, location := range opts.Locations {
+		errGroup.Add(location.Verify())
+	}
+
+	err := errGroup.Err()
+	if err != nil {
+		return err
+	}
+
+	// Verify if all locations are in the same bucket
+	first := opts.Locations[0]
+	for _, item := range opts.Locations[1:] {
+		if first.ProjectID != item.ProjectID || first.BucketName != item.BucketName {
+			return ErrInvalidRequest.New("all objects must be in the same bucket")
 		}
-		return Segment{}, Error.New("unable to query segment: %w", err)
 	}
 
-	segment.Pieces, err = db.aliasCache.ConvertAliasesToPieces(ctx, aliasPieces)
+	return nil
+}
+
+// LatestObjectLastSegment is the last segment of the latest committed object
+// of a location.
+type LatestObjectLastSegment struct {
+	Segment Segment
+	// Found is false when the location has no committed object or the
+	// object has no segments.
+	Found bool
+}
+
+// GetLatestObjectsLastSegments returns the last segments of the latest
+// committed objects of multiple locations from the same bucket, with a single
+// query. The result has an entry for every location, also for the locations
+// without a committed object or segment. Duplicate locations are allowed.
+func (db *DB) GetLatestObjectsLastSegments(ctx context.Context, opts GetLatestObjectsLastSegments) (results map[ObjectLocation]LatestObjectLastSegment, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if err := opts.Verify(); err != nil {
+		return nil, err
+	}
+
+	results = make(map[ObjectLocation]LatestObjectLastSegment, len(opts.Locations))
+	if len(opts.Locations) == 0 {
+		return results, nil
+	}
+
+	// It is already verified that all object locations are in the same bucket
+	projectID := opts.Locations[0].ProjectID
+	bucketName := opts.Locations[0].BucketName
+
+	objectKeys := make([][]byte, 0, len(opts.Locations))
+	for _, location := range opts.Locations {
+		if _, ok := results[location]; ok {
+			continue
+		}
+		results[location] = LatestObjectLastSegment{}
+		objectKeys = append(objectKeys, []byte(location.ObjectKey))
+	}
+
+	err = withRows(db.db.QueryContext(ctx, `
+		WITH latest_objects AS (
+			SELECT DISTINCT ON (objects.object_key)
+				objects.object_key, objects.stream_id
+			FROM unnest($3::BYTEA[]) AS locations(object_key)
+			JOIN objects ON
+				objects.project_id  = $1 AND
+				objects.bucket_name = $2 AND
+				objects.object_key  = locations.object_key AND
+				objects.status      = `+committedStatus+`
+			ORDER BY objects.object_key, objects.version DESC
+		)
+		SELECT DISTINCT ON (latest_objects.object_key)
+			latest_objects.object_key,
+			segments.stream_id, segments.position,
+			segments.created_at, segments.repaired_at,
+			segments.root_piece_id, segments.encrypted_key_nonce, segments.encrypted_key,
+			segments.encrypted_size, segments.plain_offset, segments.plain_size,
+			segments.encrypted_etag,
+			segments.redundancy,
+			segments.inline_data, segments.remote_alias_pieces,
+			segments.placement
+		FROM latest_objects
+		JOIN segments ON segments.stream_id = latest_objects.stream_id
+		ORDER BY latest_objects.object_key, segments.position DESC
+	`, projectID, []byte(bucketName), pgutil.ByteaArray(objectKeys)))(func(rows tagsql.Rows) error {
+		for rows.Next() {
+			var objectKey ObjectKey
+			var segment Segment
+			var aliasPieces AliasPieces
+			err := rows.Scan(
+				&objectKey,
+				&segment.StreamID, &segment.Position,
+				&segment.CreatedAt, &segment.RepairedAt,
+				&segment.RootPieceID, &segment.EncryptedKeyNonce, &segment.EncryptedKey,
+				&segment.EncryptedSize, &segment.PlainOffset, &segment.PlainSize,
+				&segment.EncryptedETag,
+				redundancyScheme{&segment.Redundancy},
+				&segment.InlineData, &aliasPieces,
+				&segment.Placement,
+			)
+			if err != nil {
+				return err
+			}
+
+			segment.Pieces, err = db.aliasCache.ConvertAliasesToPieces(ctx, aliasPieces)
+			if err != nil {
+				return errs.New("unable to convert aliases to pieces: %w", err)
+			}
+
+			results[ObjectLocation{
+				ProjectID:  projectID,
+				BucketName: bucketName,
+				ObjectKey:  objectKey,
+			}] = LatestObjectLastSegment{Segment: segment, Found: true}
+		}
+		return nil
+	})
 	if err != nil {
-		return Segment{}, Error.New("unable to convert aliases to pieces: %w", err)
+		return nil, Error.New("unable to query segments: %w", err)
 	}
 
-	return segment, nil
+	return results, nil
 }
 
 // GetSegmentByOffset contains arguments necessary for fetching a segment information.
@@ -545,6 +545,125 @@ func TestGetLatestObjectLastSegment(t *testing.T) {
 	})
 }
 
+func TestGetLatestObjectsLastSegments(t *testing.T) {
+	metabasetest.Run(t, func(ctx *testcontext.Context, t *testing.T, db *metabase.DB) {
+		obj := metabasetest.RandObjectStream()
+		location := obj.Location()
+		now := time.Now()
+
+		lastSegment := func(obj metabase.ObjectStream, numberOfSegments byte) metabase.Segment {
+			return metabase.Segment{
+				StreamID: obj.StreamID,
+				Position: metabase.SegmentPosition{
+					Index: uint32(numberOfSegments - 1),
+				},
+				CreatedAt:         now,
+				RootPieceID:       storj.PieceID{1},
+				EncryptedKey:      []byte{3},
+				EncryptedKeyNonce: []byte{4},
+				EncryptedETag:     []byte{5},
+				EncryptedSize:     1024,
+				PlainSize:         512,
+				PlainOffset:       512 * int64(numberOfSegments-1),
+				Pieces:            metabase.Pieces{{Number: 0, StorageNode: storj.NodeID{2}}},
+				Redundancy:        metabasetest.DefaultRedundancy,
+			}
+		}
+
+		for _, test := range metabasetest.InvalidObjectLocations(location) {
+			test := test
+			t.Run(test.Name, func(t *testing.T) {
+				defer metabasetest.DeleteAll{}.Check(ctx, t, db)
+				metabasetest.GetLatestObjectsLastSegments{
+					Opts: metabase.GetLatestObjectsLastSegments{
+						Locations: []metabase.ObjectLocation{test.ObjectLocation},
+					},
+					ErrClass: test.ErrClass,
+					ErrText:  test.ErrText,
+				}.Check(ctx, t, db)
+
+				metabasetest.Verify{}.Check(ctx, t, db)
+			})
+		}
+
+		t.Run("Different buckets", func(t *testing.T) {
+			defer metabasetest.DeleteAll{}.Check(ctx, t, db)
+
+			metabasetest.GetLatestObjectsLastSegments{
+				Opts: metabase.GetLatestObjectsLastSegments{
+					Locations: []metabase.ObjectLocation{location, metabasetest.RandObjectStream().Location()},
+				},
+				ErrClass: &metabase.ErrInvalidRequest,
+				ErrText:  "all objects must be in the same bucket",
+			}.Check(ctx, t, db)
+		})
+
+		t.Run("Empty locations", func(t *testing.T) {
+			defer metabasetest.DeleteAll{}.Check(ctx, t, db)
+
+			metabasetest.GetLatestObjectsLastSegments{}.Check(ctx, t, db)
+		})
+
+		t.Run("Get last segments", func(t *testing.T) {
+			defer metabasetest.DeleteAll{}.Check(ctx, t, db)
+
+			sameBucket := func() metabase.ObjectStream {
+				stream := metabasetest.RandObjectStream()
+				stream.ProjectID = obj.ProjectID
+				stream.BucketName = obj.BucketName
+				return stream
+			}
+
+			// a committed object with segments and a newer version.
+			metabasetest.CreateObject(ctx, t, db, obj, 2)
+			newer := obj
+			newer.Version++
+			newer.StreamID = testrand.UUID()
+			metabasetest.CreateObject(ctx, t, db, newer, 3)
+
+			// a committed object without segments.
+			empty := sameBucket()
+			metabasetest.CreateObject(ctx, t, db, empty, 0)
+
+			// a pending object with segments.
+			pending := sameBucket()
+			metabasetest.CreatePendingObject(ctx, t, db, pending, 2)
+
+			missing := sameBucket()
+
+			metabasetest.GetLatestObjectsLastSegments{
+				Opts: metabase.GetLatestObjectsLastSegments{
+					Locations: []metabase.ObjectLocation{
+						location, empty.Location(), pending.Location(), missing.Location(),
+						location, // duplicate
+					},
+				},
+				Result: map[metabase.ObjectLocation]metabase.LatestObjectLastSegment{
+					location:           {Segment: lastSegment(newer, 3), Found: true},
+					empty.Location():   {},
+					pending.Location(): {},
+					missing.Location(): {},
+				},
+			}.Check(ctx, t, db)
+
+			// the single location variant agrees.
+			metabasetest.GetLatestObjectLastSegment{
+				Opts: metabase.GetLatestObjectLastSegment{
+					ObjectLocation: location,
+				},
+				Result: lastSegment(newer, 3),
+			}.Check(ctx, t, db)
+			metabasetest.GetLatestObjectLastSegment{
+				Opts: metabase.GetLatestObjectLastSegment{
+					ObjectLocation: pending.Location(),
+				},
+				ErrClass: &storj.ErrObjectNotFound,
+				ErrText:  "metabase: object or segment missing",
+			}.Check(ctx, t, db)
+		})
+	})
+}
+
 func TestGetSegmentByOffset(t *testing.T) {
 	metabasetest.Run(t, func(ctx *testcontext.Context, t *testing.T, db *metabase.DB) {
 		obj := metabasetest.RandObjectStream()
@@ -69,6 +69,40 @@ func sortDeletedSegments(segments []metabase.DeletedSegmentInfo) {
 	})
 }
 
+func sortDeletedObjects(deleted []metabase.DeletedObjectInfo) {
+	sort.Slice(deleted, func(i, j int) bool {
+		return bytes.Compare(deleted[i].StreamID[:], deleted[j].StreamID[:]) < 0
+	})
+	for _, object := range deleted {
+		sortDeletedPieces(object.Pieces)
+	}
+}
+
+func sortDeletedPieces(pieces []metabase.DeletedPiece) {
+	sort.Slice(pieces, func(i, j int) bool {
+		return bytes.Compare(pieces[i].PieceID[:], pieces[j].PieceID[:]) < 0
+	})
+}
+
+// diffDeleteResult returns the differences of the results of a deletion. The
+// info about the deleted segments of the objects is only compared when the
+// expected result has it.
+func diffDeleteResult(expected, actual metabase.DeleteObjectResult) string {
+	sortObjects(actual.Objects)
+	sortObjects(expected.Objects)
+
+	sortDeletedSegments(actual.Segments)
+	sortDeletedSegments(expected.Segments)
+
+	if expected.Deleted == nil {
+		actual.Deleted = nil
+	}
+	sortDeletedObjects(actual.Deleted)
+	sortDeletedObjects(expected.Deleted)
+
+	return cmp.Diff(expected, actual, cmpopts.EquateApproxTime(5*time.Second))
+}
+
 func checkError(t testing.TB, err error, errClass *errs.Class, errText string) {
 	if errClass != nil {
 		require.True(t, errClass.Has(err), "expected an error %v got %v", *errClass, err)
@@ -248,6 +248,23 @@ func (step GetLatestObjectLastSegment) Check(ctx *testcontext.Context, t testing
 	require.Zero(t, diff)
 }
 
+// GetLatestObjectsLastSegments is for testing metabase.GetLatestObjectsLastSegments.
+type GetLatestObjectsLastSegments struct {
+	Opts     metabase.GetLatestObjectsLastSegments
+	Result   map[metabase.ObjectLocation]metabase.LatestObjectLastSegment
+	ErrClass *errs.Class
+	ErrText  string
+}
+
+// Check runs the test.
+func (step GetLatestObjectsLastSegments) Check(ctx *testcontext.Context, t testing.TB, db *metabase.DB) {
+	result, err := db.GetLatestObjectsLastSegments(ctx, step.Opts)
+	checkError(t, err, step.ErrClass, step.ErrText)
+
+	diff := cmp.Diff(step.Result, result, cmpopts.EquateApproxTime(5*time.Second), cmpopts.EquateEmpty())
+	require.Zero(t, diff)
+}
+
 // GetSegmentByOffset is for testing metabase.GetSegmentByOffset.
 type GetSegmentByOffset struct {
 	Opts     metabase.GetSegmentByOffset
@@ -377,13 +394,7 @@ func (step DeleteObjectExactVersion) Check(ctx *testcontext.Context, t testing.T
 	result, err := db.DeleteObjectExactVersion(ctx, step.Opts)
 	checkError(t, err, step.ErrClass, step.ErrText)
 
-	sortObjects(result.Objects)
-	sortObjects(step.Result.Objects)
-
-	sortDeletedSegments(result.Segments)
-	sortDeletedSegments(step.Result.Segments)
-
-	diff := cmp.Diff(step.Result, result, cmpopts.EquateApproxTime(5*time.Second))
+	diff := diffDeleteResult(step.Result, result)
 	require.Zero(t, diff)
 }
 
@@ -400,13 +411,7 @@ func (step DeletePendingObject) Check(ctx *testcontext.Context, t testing.TB, db
 	result, err := db.DeletePendingObject(ctx, step.Opts)
 	checkError(t, err, step.ErrClass, step.ErrText)
 
-	sortObjects(result.Objects)
-	sortObjects(step.Result.Objects)
-
-	sortDeletedSegments(result.Segments)
-	sortDeletedSegments(step.Result.Segments)
-
-	diff := cmp.Diff(step.Result, result, cmpopts.EquateApproxTime(5*time.Second))
+	diff := diffDeleteResult(step.Result, result)
 	require.Zero(t, diff)
 }
 
@@ -423,13 +428,7 @@ func (step DeleteObjectLatestVersion) Check(ctx *testcontext.Context, t testing.
 	result, err := db.DeleteObjectLatestVersion(ctx, step.Opts)
 	checkError(t, err, step.ErrClass, step.ErrText)
 
-	sortObjects(result.Objects)
-	sortObjects(step.Result.Objects)
-
-	sortDeletedSegments(result.Segments)
-	sortDeletedSegments(step.Result.Segments)
-
-	diff := cmp.Diff(step.Result, result, cmpopts.EquateApproxTime(5*time.Second))
+	diff := diffDeleteResult(step.Result, result)
 	require.Zero(t, diff)
 }
 
@@ -446,13 +445,7 @@ func (step DeleteObjectAnyStatusAllVersions) Check(ctx *testcontext.Context, t t
 	result, err := db.DeleteObjectAnyStatusAllVersions(ctx, step.Opts)
 	checkError(t, err, step.ErrClass, step.ErrText)
 
-	sortObjects(result.Objects)
-	sortObjects(step.Result.Objects)
-
-	sortDeletedSegments(result.Segments)
-	sortDeletedSegments(step.Result.Segments)
-
-	diff := cmp.Diff(step.Result, result, cmpopts.EquateApproxTime(5*time.Second))
+	diff := diffDeleteResult(step.Result, result)
 	require.Zero(t, diff)
 }
 
@@ -469,13 +462,7 @@ func (step DeleteObjectsAllVersions) Check(ctx *testcontext.Context, t testing.T
 	result, err := db.DeleteObjectsAllVersions(ctx, step.Opts)
 	checkError(t, err, step.ErrClass, step.ErrText)
 
-	sortObjects(result.Objects)
-	sortObjects(step.Result.Objects)
-
-	sortDeletedSegments(result.Segments)
-	sortDeletedSegments(step.Result.Segments)
-
-	diff := cmp.Diff(step.Result, result, cmpopts.EquateApproxTime(5*time.Second))
+	diff := diffDeleteResult(step.Result, result)
 	require.Zero(t, diff)
 }
 
@@ -104,8 +104,158 @@ func (db *DB) TestingDeleteAll(ctx context.Context) (err error) {
 	return Error.Wrap(err)
 }
 
+// DefaultTestingAllLimit is how many objects or segments the testing helpers
+// that load all of them into memory accept, unless Config.TestingAllLimit is
+// set.
+const DefaultTestingAllLimit = 100000
+
+// testingPageSize is the page size of the testing helpers that load all
+// objects or segments.
+const testingPageSize = 1000
+
+// TestingObjectsCursor is the position after the last listed object.
+type TestingObjectsCursor struct {
+	ProjectID  uuid.UUID
+	BucketName string
+	ObjectKey  ObjectKey
+	Version    Version
+}
+
+// TestingSegmentsCursor is the position after the last listed segment.
+type TestingSegmentsCursor struct {
+	StreamID uuid.UUID
+	Position SegmentPosition
+}
+
+// testingAllLimit returns the limit of the helpers that load all objects or
+// segments into memory.
+func (db *DB) testingAllLimit() int {
+	if db.config.TestingAllLimit > 0 {
+		return db.config.TestingAllLimit
+	}
+	return DefaultTestingAllLimit
+}
+
+// TestingListObjects lists a page of at most limit objects after the cursor,
+// ordered by their location and version. A nil cursor lists from the start.
+// The returned cursor is nil after the last page.
+// Use only for testing purposes.
+func (db *DB) TestingListObjects(ctx context.Context, cursor *TestingObjectsCursor, limit int) (objects []Object, next *TestingObjectsCursor, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	rawObjects, next, err := db.testingListRawObjects(ctx, cursor, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, o := range rawObjects {
+		objects = append(objects, Object(o))
+	}
+	return objects, next, nil
+}
+
+// TestingIterateObjects calls fn for every object, ordered by their location
+// and version. The objects are loaded in pages of pageSize.
+// Use only for testing purposes.
+func (db *DB) TestingIterateObjects(ctx context.Context, pageSize int, fn func(context.Context, Object) error) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	var cursor *TestingObjectsCursor
+	for {
+		objects, next, err := db.TestingListObjects(ctx, cursor, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			if err := fn(ctx, object); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// TestingListSegments lists a page of at most limit segments after the
+// cursor, ordered by their stream ID and position. A nil cursor lists from the
+// start. The returned cursor is nil after the last page.
+// Use only for testing purposes.
+func (db *DB) TestingListSegments(ctx context.Context, cursor *TestingSegmentsCursor, limit int) (segments []Segment, next *TestingSegmentsCursor, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	rawSegments, next, err := db.testingListRawSegments(ctx, cursor, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, s := range rawSegments {
+		segments = append(segments, Segment(s))
+	}
+	return segments, next, nil
+}
+
+// TestingIterateSegments calls fn for every segment, ordered by their stream ID
+// and position. The segments are loaded in pages of pageSize.
+// Use only for testing purposes.
+func (db *DB) TestingIterateSegments(ctx context.Context, pageSize int, fn func(context.Context, Segment) error) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	var cursor *TestingSegmentsCursor
+	for {
+		segments, next, err := db.TestingListSegments(ctx, cursor, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, segment := range segments {
+			if err := fn(ctx, segment); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		cursor = next
+	}
+}
+
 // testingGetAllObjects returns the state of the database.
 func (db *DB) testingGetAllObjects(ctx context.Context) (_ []RawObject, err error) {
+	limit := db.testingAllLimit()
+
+	var objs []RawObject
+	var cursor *TestingObjectsCursor
+	for {
+		page, next, err := db.testingListRawObjects(ctx, cursor, testingPageSize)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, page...)
+		if len(objs) > limit {
+			return nil, Error.New("testingGetAllObjects: more than %d objects, use TestingIterateObjects", limit)
+		}
+		if next == nil {
+			return objs, nil
+		}
+		cursor = next
+	}
+}
+
+// testingListRawObjects lists a page of objects after the cursor.
+func (db *DB) testingListRawObjects(ctx context.Context, cursor *TestingObjectsCursor, limit int) (_ []RawObject, next *TestingObjectsCursor, err error) {
+	if limit <= 0 {
+		return nil, nil, ErrInvalidRequest.New("Invalid limit: %d", limit)
+	}
+
+	// the first object is at or after the zero cursor.
+	condition := `(project_id, bucket_name, object_key, version) >= ($1, $2, $3, $4)`
+	if cursor == nil {
+		cursor = &TestingObjectsCursor{}
+	} else {
+		condition = `(project_id, bucket_name, object_key, version) > ($1, $2, $3, $4)`
+	}
+
 	objs := []RawObject{}
 
 	rows, err := db.db.QueryContext(ctx, `
@@ -118,10 +268,12 @@ func (db *DB) testingGetAllObjects(ctx context.Context) (_ []RawObject, err erro
 			encryption,
 			zombie_deletion_deadline
 		FROM objects
+		WHERE `+condition+`
 		ORDER BY project_id ASC, bucket_name ASC, object_key ASC, version ASC
-	`)
+		LIMIT $5
+	`, cursor.ProjectID, []byte(cursor.BucketName), []byte(cursor.ObjectKey), cursor.Version, limit)
 	if err != nil {
-		return nil, Error.New("testingGetAllObjects query: %w", err)
+		return nil, nil, Error.New("testingGetAllObjects query: %w", err)
 	}
 	defer func() { err = errs.Combine(err, rows.Close()) }()
 	for rows.Next() {
@@ -151,22 +303,65 @@ func (db *DB) testingGetAllObjects(ctx context.Context) (_ []RawObject, err erro
 			&obj.ZombieDeletionDeadline,
 		)
 		if err != nil {
-			return nil, Error.New("testingGetAllObjects scan failed: %w", err)
+			return nil, nil, Error.New("testingGetAllObjects scan failed: %w", err)
 		}
 		objs = append(objs, obj)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, Error.New("testingGetAllObjects scan failed: %w", err)
+		return nil, nil, Error.New("testingGetAllObjects scan failed: %w", err)
 	}
 
 	if len(objs) == 0 {
-		return nil, nil
+		return nil, nil, nil
 	}
-	return objs, nil
+	if len(objs) == limit {
+		last := objs[len(objs)-1]
+		next = &TestingObjectsCursor{
+			ProjectID:  last.ProjectID,
+			BucketName: last.BucketName,
+			ObjectKey:  last.ObjectKey,
+			Version:    last.Version,
+		}
+	}
+	return objs, next, nil
 }
 
 // testingGetAllSegments returns the state of the database.
 func (db *DB) testingGetAllSegments(ctx context.Context) (_ []RawSegment, err error) {
+	limit := db.testingAllLimit()
+
+	var segs []RawSegment
+	var cursor *TestingSegmentsCursor
+	for {
+		page, next, err := db.testingListRawSegments(ctx, cursor, testingPageSize)
+		if err != nil {
+			return nil, err
+		}
+		segs = append(segs, page...)
+		if len(segs) > limit {
+			return nil, Error.New("testingGetAllSegments: more than %d segments, use TestingIterateSegments", limit)
+		}
+		if next == nil {
+			return segs, nil
+		}
+		cursor = next
+	}
+}
+
+// testingListRawSegments lists a page of segments after the cursor.
+func (db *DB) testingListRawSegments(ctx context.Context, cursor *TestingSegmentsCursor, limit int) (_ []RawSegment, next *TestingSegmentsCursor, err error) {
+	if limit <= 0 {
+		return nil, nil, ErrInvalidRequest.New("Invalid limit: %d", limit)
+	}
+
+	// the first segment is at or after the zero cursor.
+	condition := `(stream_id, position) >= ($1, $2)`
+	if cursor == nil {
+		cursor = &TestingSegmentsCursor{}
+	} else {
+		condition = `(stream_id, position) > ($1, $2)`
+	}
+
 	segs := []RawSegment{}
 
 	rows, err := db.db.QueryContext(ctx, `
@@ -178,13 +373,15 @@ func (db *DB) testingGetAllSegments(ctx context.Context) (_ []RawSegment, err er
 			plain_offset, plain_size,
 			encrypted_etag,
 			redundancy,
-			inline_data, remote_alias_pieces, 
+			inline_data, remote_alias_pieces,
 			placement
 		FROM segments
+		WHERE `+condition+`
 		ORDER BY stream_id ASC, position ASC
-	`)
+		LIMIT $3
+	`, cursor.StreamID, cursor.Position, limit)
 	if err != nil {
-		return nil, Error.New("testingGetAllSegments query: %w", err)
+		return nil, nil, Error.New("testingGetAllSegments query: %w", err)
 	}
 	defer func() { err = errs.Combine(err, rows.Close()) }()
 	for rows.Next() {
@@ -214,22 +411,29 @@ func (db *DB) testingGetAllSegments(ctx context.Context) (_ []RawSegment, err er
 			&seg.Placement,
 		)
 		if err != nil {
-			return nil, Error.New("testingGetAllSegments scan failed: %w", err)
+			return nil, nil, Error.New("testingGetAllSegments scan failed: %w", err)
 		}
 
 		seg.Pieces, err = db.aliasCache.ConvertAliasesToPieces(ctx, aliasPieces)
 		if err != nil {
-			return nil, Error.New("testingGetAllSegments convert aliases to pieces failed: %w", err)
+			return nil, nil, Error.New("testingGetAllSegments convert aliases to pieces failed: %w", err)
 		}
 
 		segs = append(segs, seg)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, Error.New("testingGetAllSegments scan failed: %w", err)
+		return nil, nil, Error.New("testingGetAllSegments scan failed: %w", err)
 	}
 
 	if len(segs) == 0 {
-		return nil, nil
+		return nil, nil, nil
+	}
+	if len(segs) == limit {
+		last := segs[len(segs)-1]
+		next = &TestingSegmentsCursor{
+			StreamID: last.StreamID,
+			Position: last.Position,
+		}
 	}
-	return segs, nil
+	return segs, next, nil
 }
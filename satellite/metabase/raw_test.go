@@ -0,0 +1,129 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metabase_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/testcontext"
+	"storj.io/storj/satellite/metabase"
+	"storj.io/storj/satellite/metabase/metabasetest"
+)
+
+func TestTestingListSegments(t *testing.T) {
+	metabasetest.Run(t, func(ctx *testcontext.Context, t *testing.T, db *metabase.DB) {
+		const pageSize = 7
+
+		t.Run("invalid limit", func(t *testing.T) {
+			defer metabasetest.DeleteAll{}.Check(ctx, t, db)
+
+			_, _, err := db.TestingListSegments(ctx, nil, 0)
+			require.True(t, metabase.ErrInvalidRequest.Has(err))
+
+			_, _, err = db.TestingListObjects(ctx, nil, -1)
+			require.True(t, metabase.ErrInvalidRequest.Has(err))
+		})
+
+		t.Run("empty", func(t *testing.T) {
+			defer metabasetest.DeleteAll{}.Check(ctx, t, db)
+
+			segments, next, err := db.TestingListSegments(ctx, nil, pageSize)
+			require.NoError(t, err)
+			require.Empty(t, segments)
+			require.Nil(t, next)
+
+			objects, nextObject, err := db.TestingListObjects(ctx, nil, pageSize)
+			require.NoError(t, err)
+			require.Empty(t, objects)
+			require.Nil(t, nextObject)
+		})
+
+		t.Run("more than one page", func(t *testing.T) {
+			defer metabasetest.DeleteAll{}.Check(ctx, t, db)
+
+			for i := 0; i < 10; i++ {
+				metabasetest.CreateObject(ctx, t, db, metabasetest.RandObjectStream(), 5)
+			}
+
+			expectedSegments, err := db.TestingAllSegments(ctx)
+			require.NoError(t, err)
+			require.Len(t, expectedSegments, 50)
+
+			expectedObjects, err := db.TestingAllObjects(ctx)
+			require.NoError(t, err)
+			require.Len(t, expectedObjects, 10)
+
+			var pages []metabase.Segment
+			var cursor *metabase.TestingSegmentsCursor
+			for {
+				segments, next, err := db.TestingListSegments(ctx, cursor, pageSize)
+				require.NoError(t, err)
+				require.LessOrEqual(t, len(segments), pageSize)
+				pages = append(pages, segments...)
+				if next == nil {
+					break
+				}
+				cursor = next
+			}
+			require.Equal(t, expectedSegments, pages)
+
+			var iterated []metabase.Segment
+			err = db.TestingIterateSegments(ctx, pageSize, func(ctx context.Context, segment metabase.Segment) error {
+				iterated = append(iterated, segment)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, expectedSegments, iterated)
+
+			seen := map[metabase.TestingSegmentsCursor]bool{}
+			for i, segment := range iterated {
+				key := metabase.TestingSegmentsCursor{StreamID: segment.StreamID, Position: segment.Position}
+				require.False(t, seen[key], "segment %v listed twice", key)
+				seen[key] = true
+
+				if i > 0 {
+					previous := iterated[i-1]
+					require.True(t, previous.StreamID.Less(segment.StreamID) ||
+						previous.StreamID == segment.StreamID && previous.Position.Less(segment.Position),
+						"segments are not ordered")
+				}
+			}
+
+			var objects []metabase.Object
+			err = db.TestingIterateObjects(ctx, 3, func(ctx context.Context, object metabase.Object) error {
+				objects = append(objects, object)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, expectedObjects, objects)
+		})
+	})
+}
+
+func TestTestingAllLimit(t *testing.T) {
+	metabasetest.RunWithConfig(t, metabase.Config{TestingAllLimit: 3}, func(ctx *testcontext.Context, t *testing.T, db *metabase.DB) {
+		obj := metabasetest.RandObjectStream()
+		metabasetest.CreateObject(ctx, t, db, obj, 4)
+
+		_, err := db.TestingAllSegments(ctx)
+		require.Error(t, err)
+
+		objects, err := db.TestingAllObjects(ctx)
+		require.NoError(t, err)
+		require.Len(t, objects, 1)
+
+		count := 0
+		err = db.TestingIterateSegments(ctx, 2, func(ctx context.Context, segment metabase.Segment) error {
+			count++
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 4, count)
+
+		require.NoError(t, db.TestingDeleteAll(ctx))
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/spacemonkeygo/monkit/v3"
@@ -86,6 +87,14 @@ type observerContext struct {
 
 	remote *monkit.DurationDist
 	inline *monkit.DurationDist
+
+	// batch and batchSegments are the processing time and the number of
+	// segments of the current batch, they're only used by the loop.
+	batch         time.Duration
+	batchSegments int64
+
+	mu    sync.Mutex
+	stats ObserverStats
 }
 
 func newObserverContext(ctx context.Context, obs Observer) *observerContext {
@@ -100,23 +109,63 @@ func newObserverContext(ctx context.Context, obs Observer) *observerContext {
 
 		inline: monkit.NewDurationDist(key.WithTag("pointer_type", "inline")),
 		remote: monkit.NewDurationDist(key.WithTag("pointer_type", "remote")),
+
+		stats: ObserverStats{Name: name},
 	}
 }
 
 func (observer *observerContext) RemoteSegment(ctx context.Context, segment *Segment) error {
 	start := time.Now()
-	defer func() { observer.remote.Insert(time.Since(start)) }()
+	defer func() { observer.observe(observer.remote, time.Since(start)) }()
 
 	return observer.observer.RemoteSegment(ctx, segment)
 }
 
 func (observer *observerContext) InlineSegment(ctx context.Context, segment *Segment) error {
 	start := time.Now()
-	defer func() { observer.inline.Insert(time.Since(start)) }()
+	defer func() { observer.observe(observer.inline, time.Since(start)) }()
 
 	return observer.observer.InlineSegment(ctx, segment)
 }
 
+func (observer *observerContext) observe(dist *monkit.DurationDist, duration time.Duration) {
+	dist.Insert(duration)
+	observer.batch += duration
+	observer.batchSegments++
+}
+
+// finishBatch adds the current batch to the stats of the observer and
+// returns them. slow tells whether the observer took too much of the time of
+// the batch.
+func (observer *observerContext) finishBatch(slow bool) ObserverStats {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+
+	observer.flushBatchLocked()
+	if slow {
+		observer.stats.SlowBatches++
+	} else {
+		observer.stats.SlowBatches = 0
+	}
+	return observer.stats
+}
+
+func (observer *observerContext) flushBatchLocked() {
+	observer.stats.Segments += observer.batchSegments
+	observer.stats.Duration += observer.batch
+	if observer.batch > observer.stats.MaxBatchDuration {
+		observer.stats.MaxBatchDuration = observer.batch
+	}
+	observer.batch, observer.batchSegments = 0, 0
+}
+
+// Stats returns the stats of the observer up to the last finished batch.
+func (observer *observerContext) Stats() ObserverStats {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	return observer.stats
+}
+
 func (observer *observerContext) HandleError(err error) bool {
 	if err != nil {
 		observer.done <- err
@@ -129,8 +178,11 @@ func (observer *observerContext) HandleError(err error) bool {
 func (observer *observerContext) Finish() {
 	close(observer.done)
 
-	name := fmt.Sprintf("%T", observer.observer)
-	stats := allObserverStatsCollectors.GetStats(name)
+	observer.mu.Lock()
+	observer.flushBatchLocked()
+	observer.mu.Unlock()
+
+	stats := allObserverStatsCollectors.GetStats(observer.stats.Name)
 	stats.Observe(observer)
 }
 
@@ -147,6 +199,9 @@ type Config struct {
 	AsOfSystemInterval time.Duration `help:"as of system interval" releaseDefault:"-5m" devDefault:"-1us" testDefault:"-1us"`
 
 	SuspiciousProcessedRatio float64 `help:"ratio where to consider processed count as supicious" default:"0.03"`
+
+	SlowObserverShare   float64 `help:"share of the processing time of a batch, above which an observer is considered slow" default:"0.8"`
+	SlowObserverBatches int     `help:"how many consecutive batches an observer has to be slow, before it's logged" default:"3"`
 }
 
 // MetabaseDB contains iterators for the metabase data.
@@ -169,6 +224,10 @@ type Service struct {
 	metabaseDB MetabaseDB
 	join       chan *observerContext
 	done       chan struct{}
+
+	mu sync.Mutex
+	// observers are the observers of the current or the last iteration.
+	observers []*observerContext
 }
 
 // New creates a new segments loop service.
@@ -459,6 +518,12 @@ func (loop *Service) iterateSegments(ctx context.Context, observers []*observerC
 		return processed, observers, errNoObservers
 	}
 
+	loop.mu.Lock()
+	loop.observers = append([]*observerContext(nil), observers...)
+	loop.mu.Unlock()
+
+	var batchSegments int
+
 	err = loop.metabaseDB.IterateLoopSegments(ctx, metabase.IterateLoopSegments{
 		BatchSize:          limit,
 		AsOfSystemTime:     startingTime,
@@ -492,13 +557,53 @@ func (loop *Service) iterateSegments(ctx context.Context, observers []*observerC
 
 			processed.segments++
 			mon.IntVal("segmentsProcessed").Observe(processed.segments) //mon:locked
+
+			batchSegments++
+			if batchSegments >= limit {
+				loop.finishBatch(observers)
+				batchSegments = 0
+			}
 		}
 		return nil
 	})
+	if err == nil && batchSegments > 0 {
+		loop.finishBatch(observers)
+	}
 
 	return processed, observers, err
 }
 
+// finishBatch updates the stats of the observers with the current batch and
+// warns about the observers that take most of the time of the batches.
+func (loop *Service) finishBatch(observers []*observerContext) {
+	var total time.Duration
+	for _, observer := range observers {
+		total += observer.batch
+	}
+
+	for _, observer := range observers {
+		batch := observer.batch
+
+		var share float64
+		if total > 0 {
+			share = float64(batch) / float64(total)
+		}
+		// a single observer always takes the whole batch.
+		slow := len(observers) > 1 && share > loop.config.SlowObserverShare
+
+		stats := observer.finishBatch(slow)
+		allObserverStatsCollectors.GetStats(stats.Name).Update(stats)
+
+		if slow && stats.SlowBatches == loop.config.SlowObserverBatches {
+			loop.log.Warn("segment loop observer is slow",
+				zap.String("observer", stats.Name),
+				zap.Float64("share", share),
+				zap.Duration("batch duration", batch),
+				zap.Int("consecutive batches", stats.SlowBatches))
+		}
+	}
+}
+
 func withObservers(ctx context.Context, observers []*observerContext, handleObserver func(ctx context.Context, observer *observerContext) bool) []*observerContext {
 	defer mon.Task()(&ctx)(nil)
 	nextObservers := observers[:0]
@@ -16,6 +16,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 	"golang.org/x/sync/errgroup"
 
 	"storj.io/common/errs2"
@@ -377,6 +378,92 @@ type testObserver struct {
 	onSegment      func(context.Context) error // if set, run this during RemoteSegment()
 }
 
+// TestSegmentsLoop_ObserverStats joins a slow and a fast observer and
+// expects the stats and the warning to blame the slow one.
+func TestSegmentsLoop_ObserverStats(t *testing.T) {
+	segmentSize := 8 * memory.KiB
+
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount:   1,
+		StorageNodeCount: 4,
+		UplinkCount:      1,
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		ul := planet.Uplinks[0]
+		satellite := planet.Satellites[0]
+
+		// upload 4 remote files with 1 segment
+		for i := 0; i < 4; i++ {
+			testData := testrand.Bytes(segmentSize)
+			path := "/some/remote/path/" + strconv.Itoa(i)
+			err := ul.Upload(ctx, satellite, "bucket", path, testData)
+			require.NoError(t, err)
+		}
+
+		core, logs := observer.New(zap.WarnLevel)
+		loop := segmentloop.New(zap.New(core), segmentloop.Config{
+			CoalesceDuration:    1 * time.Second,
+			ListLimit:           2,
+			AsOfSystemInterval:  -time.Microsecond,
+			SlowObserverShare:   0.8,
+			SlowObserverBatches: 2,
+		}, satellite.Metabase.DB)
+
+		slow := &slowObserver{delay: 20 * time.Millisecond}
+		fast := segmentloop.NullObserver{}
+
+		var group errgroup.Group
+		group.Go(func() error {
+			return loop.RunOnce(ctx)
+		})
+		group.Go(func() error {
+			return loop.Join(ctx, slow)
+		})
+		group.Go(func() error {
+			return loop.Join(ctx, fast)
+		})
+		require.NoError(t, group.Wait())
+
+		stats := map[string]segmentloop.ObserverStats{}
+		for _, observerStats := range loop.ObserverStats() {
+			stats[observerStats.Name] = observerStats
+		}
+		require.Len(t, stats, 2)
+
+		slowStats := stats["*segmentloop_test.slowObserver"]
+		fastStats := stats["segmentloop.NullObserver"]
+
+		assert.EqualValues(t, 4, slowStats.Segments)
+		assert.EqualValues(t, 4, fastStats.Segments)
+
+		assert.GreaterOrEqual(t, slowStats.Duration, 4*slow.delay)
+		assert.GreaterOrEqual(t, slowStats.MaxBatchDuration, 2*slow.delay)
+		assert.Less(t, fastStats.Duration, slowStats.Duration)
+		assert.Less(t, fastStats.MaxBatchDuration, slowStats.MaxBatchDuration)
+
+		assert.Equal(t, 2, slowStats.SlowBatches)
+		assert.Equal(t, 0, fastStats.SlowBatches)
+
+		warnings := logs.FilterMessage("segment loop observer is slow").All()
+		require.Len(t, warnings, 1)
+		assert.Equal(t, slowStats.Name, warnings[0].ContextMap()["observer"])
+	})
+}
+
+type slowObserver struct {
+	segmentloop.NullObserver
+	delay time.Duration
+}
+
+func (obs *slowObserver) RemoteSegment(ctx context.Context, segment *segmentloop.Segment) error {
+	time.Sleep(obs.delay)
+	return nil
+}
+
+func (obs *slowObserver) InlineSegment(ctx context.Context, segment *segmentloop.Segment) error {
+	time.Sleep(obs.delay)
+	return nil
+}
+
 func newTestObserver(onSegment func(context.Context) error) *testObserver {
 	return &testObserver{
 		remoteSegCount: 0,
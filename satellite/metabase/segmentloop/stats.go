@@ -4,12 +4,63 @@
 package segmentloop
 
 import (
+	"encoding/json"
+	"io"
 	"sync"
 	"time"
 
 	"github.com/spacemonkeygo/monkit/v3"
+
+	"storj.io/private/debug"
 )
 
+// ObserverStats are the execution stats of an observer in an iteration of
+// the loop.
+type ObserverStats struct {
+	// Name is the type of the observer.
+	Name string `json:"name"`
+	// Segments is the number of the handled segments.
+	Segments int64 `json:"segments"`
+	// Duration is the cumulative time spent in the observer.
+	Duration time.Duration `json:"duration"`
+	// MaxBatchDuration is the longest time spent in the observer for a single
+	// batch.
+	MaxBatchDuration time.Duration `json:"max_batch_duration"`
+	// SlowBatches is the number of the consecutive batches, up to the last
+	// one, in which the observer took more than Config.SlowObserverShare of
+	// the time of the batch.
+	SlowBatches int `json:"slow_batches"`
+}
+
+// ObserverStats returns the stats of the observers of the current iteration
+// of the loop, or of the last one when the loop isn't iterating.
+// Safe to be called concurrently.
+func (loop *Service) ObserverStats() []ObserverStats {
+	loop.mu.Lock()
+	observers := loop.observers
+	loop.mu.Unlock()
+
+	stats := make([]ObserverStats, 0, len(observers))
+	for _, observer := range observers {
+		stats = append(stats, observer.Stats())
+	}
+	return stats
+}
+
+// DebugGroup returns the control panel group, which serves the stats of the
+// observers on /control/segment-loop/observers.
+func (loop *Service) DebugGroup() *debug.ButtonGroup {
+	return &debug.ButtonGroup{
+		Name: "Segment Loop",
+		Buttons: []*debug.Button{{
+			Name: "Observers",
+			Call: func(w io.Writer) error {
+				return json.NewEncoder(w).Encode(loop.ObserverStats())
+			},
+		}},
+	}
+}
+
 var allObserverStatsCollectors = newObserverStatsCollectors()
 
 type observerStatsCollectors struct {
@@ -41,7 +92,7 @@ type observerStats struct {
 	mu sync.Mutex
 
 	key    monkit.SeriesKey
-	total  time.Duration
+	stats  ObserverStats
 	inline *monkit.DurationDist
 	remote *monkit.DurationDist
 }
@@ -49,26 +100,38 @@ type observerStats struct {
 func newObserverStats(name string) *observerStats {
 	return &observerStats{
 		key:    monkit.NewSeriesKey("segment-observer").WithTag("name", name),
-		total:  0,
+		stats:  ObserverStats{Name: name},
 		inline: nil,
 		remote: nil,
 	}
 }
 
+// Observe updates the stats with a finished observer.
 func (stats *observerStats) Observe(observer *observerContext) {
 	stats.mu.Lock()
 	defer stats.mu.Unlock()
 
-	stats.total = observer.inline.Sum + observer.remote.Sum
+	stats.stats = observer.Stats()
 	stats.inline = observer.inline
 	stats.remote = observer.remote
 }
 
+// Update updates the stats after a batch of a running observer.
+func (stats *observerStats) Update(observer ObserverStats) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	stats.stats = observer
+}
+
 func (stats *observerStats) Stats(cb func(key monkit.SeriesKey, field string, val float64)) {
 	stats.mu.Lock()
 	defer stats.mu.Unlock()
 
-	cb(stats.key, "sum", stats.total.Seconds())
+	cb(stats.key, "sum", stats.stats.Duration.Seconds())
+	cb(stats.key, "segments", float64(stats.stats.Segments))
+	cb(stats.key, "max_batch_duration", stats.stats.MaxBatchDuration.Seconds())
+	cb(stats.key, "slow_batches", float64(stats.stats.SlowBatches))
 
 	if stats.inline != nil {
 		stats.inline.Stats(cb)
@@ -0,0 +1,68 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package satellite
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"storj.io/private/debug"
+)
+
+// MigrationStatus is the migration state of the satellite database. It's
+// served on the debug endpoints, so that deployments can gate the readiness of
+// a peer on the finished migration.
+type MigrationStatus struct {
+	mu            sync.Mutex
+	schemaVersion int
+	migrated      bool
+}
+
+// NewMigrationStatus returns the status of a database that isn't migrated yet
+// and registers it for the completion of the migration.
+func NewMigrationStatus(db DB) *MigrationStatus {
+	status := &MigrationStatus{schemaVersion: -1}
+	db.OnMigrationComplete(status.complete)
+	return status
+}
+
+func (status *MigrationStatus) complete(version int) {
+	status.mu.Lock()
+	status.schemaVersion = version
+	status.migrated = true
+	status.mu.Unlock()
+
+	mon.IntVal("schema_version").Observe(int64(version))
+}
+
+// Migrated returns the schema version and whether the migration completed.
+func (status *MigrationStatus) Migrated() (schemaVersion int, migrated bool) {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	return status.schemaVersion, status.migrated
+}
+
+// MarshalJSON implements json.Marshaler.
+func (status *MigrationStatus) MarshalJSON() ([]byte, error) {
+	schemaVersion, migrated := status.Migrated()
+	return json.Marshal(struct {
+		SchemaVersion int  `json:"schema_version"`
+		Migrated      bool `json:"migrated"`
+	}{schemaVersion, migrated})
+}
+
+// debugGroup returns the control panel group, which serves the status on
+// /control/migration/status.
+func (status *MigrationStatus) debugGroup() *debug.ButtonGroup {
+	return &debug.ButtonGroup{
+		Name: "Migration",
+		Buttons: []*debug.Button{{
+			Name: "Status",
+			Call: func(w io.Writer) error {
+				return json.NewEncoder(w).Encode(status)
+			},
+		}},
+	}
+}
@@ -52,6 +52,8 @@ type DB interface {
 
 	// Get looks up the node by nodeID
 	Get(ctx context.Context, nodeID storj.NodeID) (*NodeDossier, error)
+	// GetNodes looks up the known nodes among nodeIDs with a single query.
+	GetNodes(ctx context.Context, nodeIDs storj.NodeIDList) (map[storj.NodeID]*NodeDossier, error)
 	// KnownOffline filters a set of nodes to offline nodes
 	KnownOffline(context.Context, *NodeCriteria, storj.NodeIDList) (storj.NodeIDList, error)
 	// KnownUnreliableOrOffline filters a set of nodes to unhealth or offlines node, independent of new
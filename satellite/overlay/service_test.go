@@ -380,6 +380,40 @@ func TestGetOnlineNodesForGetDelete(t *testing.T) {
 	})
 }
 
+func TestDBGetNodes(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 2, UplinkCount: 0,
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		db := planet.Satellites[0].Overlay.DB
+
+		nodes, err := db.GetNodes(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, nodes)
+
+		// unknown nodes are left out.
+		nodeIDs := storj.NodeIDList{testrand.NodeID()}
+		for _, node := range planet.StorageNodes {
+			nodeIDs = append(nodeIDs, node.ID())
+		}
+		nodes, err = db.GetNodes(ctx, nodeIDs)
+		require.NoError(t, err)
+		require.Len(t, nodes, len(planet.StorageNodes))
+
+		for _, node := range planet.StorageNodes {
+			expected, err := db.Get(ctx, node.ID())
+			require.NoError(t, err)
+
+			actual := nodes[node.ID()]
+			require.NotNil(t, actual)
+			require.Equal(t, expected.Address, actual.Address)
+			require.Equal(t, expected.Version, actual.Version)
+			require.Equal(t, expected.Reputation, actual.Reputation)
+			require.Equal(t, expected.ExitStatus, actual.ExitStatus)
+			require.Equal(t, expected.Disqualified, actual.Disqualified)
+		}
+	})
+}
+
 func TestKnownReliable(t *testing.T) {
 	testplanet.Run(t, testplanet.Config{
 		SatelliteCount: 1, StorageNodeCount: 6, UplinkCount: 1,
@@ -5,12 +5,15 @@ package satellite
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 
 	hw "github.com/jtolds/monkit-hw/v2"
 	"github.com/spacemonkeygo/monkit/v3"
 
 	"storj.io/common/identity"
 	"storj.io/private/debug"
+	"storj.io/storj/private/migrate"
 	"storj.io/storj/private/server"
 	version_checker "storj.io/storj/private/version/checker"
 	"storj.io/storj/satellite/accounting"
@@ -61,8 +64,22 @@ func init() {
 type DB interface {
 	// MigrateToLatest initializes the database
 	MigrateToLatest(ctx context.Context) error
+	// MigrateToVersion migrates the database up to the given version
+	MigrateToVersion(ctx context.Context, version int) error
 	// CheckVersion checks the database is the correct version
 	CheckVersion(ctx context.Context) error
+	// OnMigrationComplete registers a callback for when the database is at the latest version
+	OnMigrationComplete(fn func(version int))
+	// VerifySchema compares the schema of the database with the expected schema
+	VerifySchema(ctx context.Context, scratchDatabaseURL string) error
+	// BackfillJobs returns the long running data backfills of the database
+	BackfillJobs() *migrate.Backfills
+	// PlanMigration writes the pending migration steps without executing them
+	PlanMigration(ctx context.Context, w io.Writer) error
+	// PlanMigrationJSON returns the pending migration steps of the databases as JSON by their names
+	PlanMigrationJSON(ctx context.Context) (map[string]json.RawMessage, error)
+	// MigrationStatus returns the migration state of the databases by their names
+	MigrationStatus(ctx context.Context) (map[string]migrate.Status, error)
 	// Close closes the database
 	Close() error
 
@@ -103,6 +120,8 @@ type DB interface {
 	Revocation() revocation.DB
 	// NodeAPIVersion tracks nodes observed api usage
 	NodeAPIVersion() nodeapiversion.DB
+	// GarbageCollectionRuns records the garbage collection runs
+	GarbageCollectionRuns() gc.HistoryDB
 }
 
 // Config is the global config satellite.
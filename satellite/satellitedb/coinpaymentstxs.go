@@ -864,6 +864,9 @@ func monetaryAmountFromGobEncodedBigFloat(encoded []byte, currency *monetary.Cur
 // This is for testing purposes only, to ensure that no data is lost and that
 // code still works after the transition.
 func (db *coinPaymentsTransactions) DebugPerformBigFloatTransition(ctx context.Context) error {
+	if db.db.opts.ReadOnly {
+		return ErrReadOnly.New("refusing to change the schema of a database opened read-only")
+	}
 	_, err := db.db.DB.ExecContext(ctx, `
 		ALTER TABLE coinpayments_transactions ALTER COLUMN amount DROP NOT NULL;
 		ALTER TABLE coinpayments_transactions ALTER COLUMN received DROP NOT NULL;
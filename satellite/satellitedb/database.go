@@ -5,7 +5,13 @@ package satellitedb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/zeebo/errs"
 	"go.uber.org/zap"
@@ -22,6 +28,7 @@ import (
 	"storj.io/storj/satellite/buckets"
 	"storj.io/storj/satellite/compensation"
 	"storj.io/storj/satellite/console"
+	"storj.io/storj/satellite/gc"
 	"storj.io/storj/satellite/gracefulexit"
 	"storj.io/storj/satellite/nodeapiversion"
 	"storj.io/storj/satellite/orders"
@@ -39,6 +46,46 @@ var Error = errs.Class("satellitedb")
 
 type satelliteDBCollection struct {
 	dbs map[string]*satelliteDB
+
+	migrationComplete migrationComplete
+}
+
+// migrationComplete keeps the callbacks of OnMigrationComplete until the
+// migration completes.
+type migrationComplete struct {
+	mu        sync.Mutex
+	completed bool
+	version   int
+	callbacks []func(version int)
+}
+
+// register calls fn once the migration completes, or right away when it
+// completed already.
+func (complete *migrationComplete) register(fn func(version int)) {
+	complete.mu.Lock()
+	if !complete.completed {
+		complete.callbacks = append(complete.callbacks, fn)
+		complete.mu.Unlock()
+		return
+	}
+	version := complete.version
+	complete.mu.Unlock()
+
+	fn(version)
+}
+
+// complete calls the registered callbacks with the version, each only once.
+func (complete *migrationComplete) complete(version int) {
+	complete.mu.Lock()
+	complete.completed = true
+	complete.version = version
+	callbacks := complete.callbacks
+	complete.callbacks = nil
+	complete.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(version)
+	}
 }
 
 // satelliteDB combines access to different database tables with a record
@@ -70,6 +117,26 @@ type Options struct {
 	// How many storage node rollups to save/read in one batch.
 	SaveRollupBatchSize int
 	ReadRollupBatchSize int
+
+	// MigrationNoWait makes MigrateToLatest fail instead of waiting, when
+	// another process is migrating the database.
+	MigrationNoWait bool
+	// MigrationIgnoreChecksumMismatch makes MigrateToLatest only log the
+	// applied migration steps that were changed since, instead of failing.
+	MigrationIgnoreChecksumMismatch bool
+	// MigrationConfirmedVersions are the versions of the migration steps that
+	// require confirmation, which MigrateToLatest may apply.
+	MigrationConfirmedVersions []int
+	// MigrationStatementTimeout and MigrationLockTimeout limit how long a
+	// statement of MigrateToLatest and TestingMigrateToLatest may run and
+	// wait on a lock, zero means no limit. Other queries are not affected.
+	MigrationStatementTimeout time.Duration
+	MigrationLockTimeout      time.Duration
+
+	// ReadOnly opens the database with read-only transactions by default
+	// and makes the methods that change the schema fail with ErrReadOnly,
+	// e.g. for reporting jobs that may run against a replica.
+	ReadOnly bool
 }
 
 var _ dbx.DBMethods = &satelliteDB{}
@@ -120,6 +187,10 @@ func open(ctx context.Context, log *zap.Logger, databaseURL string, opts Options
 		return nil, err
 	}
 
+	if opts.ReadOnly {
+		source = withReadOnlyTransactions(source)
+	}
+
 	dbxDB, err := dbx.Open(driver, source)
 	if err != nil {
 		return nil, Error.New("failed opening database via DBX at %q: %v",
@@ -148,6 +219,19 @@ func open(ctx context.Context, log *zap.Logger, databaseURL string, opts Options
 	return core, nil
 }
 
+// withReadOnlyTransactions sets the transactions of the connections of the
+// source read-only by default. Postgres and cockroach take the session
+// variable as a connection parameter.
+func withReadOnlyTransactions(source string) string {
+	if strings.Contains(source, "default_transaction_read_only") {
+		return source
+	}
+	if !strings.Contains(source, "?") {
+		return source + "?default_transaction_read_only=on"
+	}
+	return source + "&default_transaction_read_only=on"
+}
+
 func (dbc *satelliteDBCollection) getByName(name string) *satelliteDB {
 	if safelyPartitionableDBs[name] {
 		if db, exists := dbc.dbs[name]; exists {
@@ -171,6 +255,20 @@ func (dbc *satelliteDBCollection) MigrationTestingDefaultDB() interface {
 	return dbc.getByName("")
 }
 
+// MigrationTestingComponents returns the migratable components of the
+// database for testing their migrations. The partitioned databases share the
+// migration of the default database, so it's the only component.
+func (dbc *satelliteDBCollection) MigrationTestingComponents() []migrate.Component {
+	db := dbc.getByName("")
+	return []migrate.Component{{
+		Name:          "satellitedb",
+		Schema:        db.DB.Schema(),
+		Migration:     db.PostgresMigration(),
+		TestMigration: db.TestPostgresMigration(),
+		DB:            db.DB,
+	}}
+}
+
 // PeerIdentities returns a storage for peer identities.
 func (dbc *satelliteDBCollection) PeerIdentities() overlay.PeerIdentities {
 	return &peerIdentities{db: dbc.getByName("peeridentities")}
@@ -272,6 +370,11 @@ func (dbc *satelliteDBCollection) NodeAPIVersion() nodeapiversion.DB {
 	return &nodeAPIVersionDB{db: dbc.getByName("nodeapiversion")}
 }
 
+// GarbageCollectionRuns returns database for the history of garbage collection runs.
+func (dbc *satelliteDBCollection) GarbageCollectionRuns() gc.HistoryDB {
+	return &gcRunsDB{db: dbc.getByName("gcruns")}
+}
+
 // Buckets returns database for interacting with buckets.
 func (dbc *satelliteDBCollection) Buckets() buckets.DB {
 	return &bucketsDB{db: dbc.getByName("buckets")}
@@ -283,7 +386,10 @@ func (dbc *satelliteDBCollection) CheckVersion(ctx context.Context) error {
 	for _, db := range dbc.dbs {
 		eg.Add(db.CheckVersion(ctx))
 	}
-	return eg.Err()
+	if err := eg.Err(); err != nil {
+		return err
+	}
+	return dbc.completeMigration(ctx)
 }
 
 // MigrateToLatest migrates all databases to the latest version.
@@ -292,16 +398,118 @@ func (dbc *satelliteDBCollection) MigrateToLatest(ctx context.Context) error {
 	for _, db := range dbc.dbs {
 		eg.Add(db.MigrateToLatest(ctx))
 	}
+	if err := eg.Err(); err != nil {
+		return err
+	}
+	return dbc.completeMigration(ctx)
+}
+
+// BackfillJobs returns the backfill jobs of the default database.
+func (dbc *satelliteDBCollection) BackfillJobs() *migrate.Backfills {
+	db := dbc.getByName("")
+	return migrate.NewBackfills(db.log.Named("backfill"), db.migrationDB)
+}
+
+// VerifySchema compares the schemas of all databases with the dbx schema.
+func (dbc *satelliteDBCollection) VerifySchema(ctx context.Context, scratchDatabaseURL string) error {
+	var eg errs.Group
+	for name, db := range dbc.dbs {
+		if err := db.VerifySchema(ctx, scratchDatabaseURL); err != nil {
+			if name != "" {
+				err = fmt.Errorf("database %s: %w", name, err)
+			}
+			eg.Add(err)
+		}
+	}
+	return eg.Err()
+}
+
+// MigrateToVersion migrates all databases up to the given version.
+func (dbc *satelliteDBCollection) MigrateToVersion(ctx context.Context, version int) error {
+	var eg errs.Group
+	for _, db := range dbc.dbs {
+		eg.Add(db.MigrateToVersion(ctx, version))
+	}
 	return eg.Err()
 }
 
+// PlanMigration writes the pending migration steps of all databases to w,
+// in the order of their names.
+func (dbc *satelliteDBCollection) PlanMigration(ctx context.Context, w io.Writer) error {
+	names := make([]string, 0, len(dbc.dbs))
+	for name := range dbc.dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name != "" {
+			if _, err := fmt.Fprintf(w, "-- database %s\n", name); err != nil {
+				return Error.Wrap(err)
+			}
+		}
+		if err := dbc.dbs[name].PlanMigration(ctx, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PlanMigrationJSON returns the pending migration steps of all databases as
+// JSON by their names.
+func (dbc *satelliteDBCollection) PlanMigrationJSON(ctx context.Context) (map[string]json.RawMessage, error) {
+	plans := make(map[string]json.RawMessage, len(dbc.dbs))
+	for name, db := range dbc.dbs {
+		plan, err := db.PlanMigrationJSON(ctx)
+		if err != nil {
+			return nil, err
+		}
+		plans[name] = plan
+	}
+	return plans, nil
+}
+
+// MigrationStatus returns the state of the migration of all databases by their names.
+func (dbc *satelliteDBCollection) MigrationStatus(ctx context.Context) (map[string]migrate.Status, error) {
+	statuses := make(map[string]migrate.Status, len(dbc.dbs))
+	for name, db := range dbc.dbs {
+		status, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		statuses[name] = status
+	}
+	return statuses, nil
+}
+
 // TestingMigrateToLatest is a method for creating all tables for all database for testing.
 func (dbc *satelliteDBCollection) TestingMigrateToLatest(ctx context.Context) error {
 	var eg errs.Group
 	for _, db := range dbc.dbs {
 		eg.Add(db.TestingMigrateToLatest(ctx))
 	}
-	return eg.Err()
+	if err := eg.Err(); err != nil {
+		return err
+	}
+	return dbc.completeMigration(ctx)
+}
+
+// OnMigrationComplete registers fn to be called with the schema version of the
+// default database, once the databases are migrated to the latest version or
+// CheckVersion finds them at it. fn is called right away when that happened
+// already.
+func (dbc *satelliteDBCollection) OnMigrationComplete(fn func(version int)) {
+	dbc.migrationComplete.register(fn)
+}
+
+// completeMigration calls the callbacks of OnMigrationComplete.
+func (dbc *satelliteDBCollection) completeMigration(ctx context.Context) error {
+	version, err := dbc.getByName("").schemaVersion(ctx)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	dbc.migrationComplete.complete(version)
+	return nil
 }
 
 // Close closes all satellite dbs.
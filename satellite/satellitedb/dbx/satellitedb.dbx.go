@@ -415,6 +415,39 @@ CREATE TABLE coupon_usages (
 	period timestamp with time zone NOT NULL,
 	PRIMARY KEY ( coupon_id, period )
 );
+CREATE TABLE garbage_collection_filters (
+	creation_date timestamp with time zone NOT NULL,
+	node_id bytea NOT NULL,
+	range_bits integer NOT NULL,
+	sent_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( creation_date, node_id )
+);
+CREATE TABLE garbage_collection_reports (
+	creation_date timestamp with time zone NOT NULL,
+	node_id bytea NOT NULL,
+	range_bits integer NOT NULL,
+	range_index integer NOT NULL,
+	pieces_examined bigint NOT NULL,
+	pieces_trashed bigint NOT NULL,
+	bytes_reclaimed bigint NOT NULL,
+	duration_ms bigint NOT NULL,
+	reported_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( creation_date, node_id, range_bits, range_index )
+);
+CREATE TABLE garbage_collection_runs (
+	id bigserial NOT NULL,
+	started_at timestamp with time zone NOT NULL,
+	finished_at timestamp with time zone,
+	status integer NOT NULL,
+	segments bigint NOT NULL,
+	nodes_targeted integer NOT NULL,
+	nodes_sent integer NOT NULL,
+	filter_bytes bigint NOT NULL,
+	error text,
+	creation_date timestamp with time zone,
+	nodes_reporting integer NOT NULL DEFAULT 0,
+	PRIMARY KEY ( id )
+);
 CREATE TABLE graceful_exit_progress (
 	node_id bytea NOT NULL,
 	bytes_transferred bigint NOT NULL,
@@ -1000,6 +1033,39 @@ CREATE TABLE coupon_usages (
 	period timestamp with time zone NOT NULL,
 	PRIMARY KEY ( coupon_id, period )
 );
+CREATE TABLE garbage_collection_filters (
+	creation_date timestamp with time zone NOT NULL,
+	node_id bytea NOT NULL,
+	range_bits integer NOT NULL,
+	sent_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( creation_date, node_id )
+);
+CREATE TABLE garbage_collection_reports (
+	creation_date timestamp with time zone NOT NULL,
+	node_id bytea NOT NULL,
+	range_bits integer NOT NULL,
+	range_index integer NOT NULL,
+	pieces_examined bigint NOT NULL,
+	pieces_trashed bigint NOT NULL,
+	bytes_reclaimed bigint NOT NULL,
+	duration_ms bigint NOT NULL,
+	reported_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( creation_date, node_id, range_bits, range_index )
+);
+CREATE TABLE garbage_collection_runs (
+	id bigserial NOT NULL,
+	started_at timestamp with time zone NOT NULL,
+	finished_at timestamp with time zone,
+	status integer NOT NULL,
+	segments bigint NOT NULL,
+	nodes_targeted integer NOT NULL,
+	nodes_sent integer NOT NULL,
+	filter_bytes bigint NOT NULL,
+	error text,
+	creation_date timestamp with time zone,
+	nodes_reporting integer NOT NULL DEFAULT 0,
+	PRIMARY KEY ( id )
+);
 CREATE TABLE graceful_exit_progress (
 	node_id bytea NOT NULL,
 	bytes_transferred bigint NOT NULL,
@@ -2930,6 +2996,569 @@ func (f CouponUsage_Period_Field) value() interface{} {
 
 func (CouponUsage_Period_Field) _Column() string { return "period" }
 
+type GarbageCollectionFilter struct {
+	CreationDate time.Time
+	NodeId       []byte
+	RangeBits    int
+	SentAt       time.Time
+}
+
+func (GarbageCollectionFilter) _Table() string { return "garbage_collection_filters" }
+
+type GarbageCollectionFilter_Update_Fields struct {
+}
+
+type GarbageCollectionFilter_CreationDate_Field struct {
+	_set   bool
+	_null  bool
+	_value time.Time
+}
+
+func GarbageCollectionFilter_CreationDate(v time.Time) GarbageCollectionFilter_CreationDate_Field {
+	return GarbageCollectionFilter_CreationDate_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionFilter_CreationDate_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionFilter_CreationDate_Field) _Column() string { return "creation_date" }
+
+type GarbageCollectionFilter_NodeId_Field struct {
+	_set   bool
+	_null  bool
+	_value []byte
+}
+
+func GarbageCollectionFilter_NodeId(v []byte) GarbageCollectionFilter_NodeId_Field {
+	return GarbageCollectionFilter_NodeId_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionFilter_NodeId_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionFilter_NodeId_Field) _Column() string { return "node_id" }
+
+type GarbageCollectionFilter_RangeBits_Field struct {
+	_set   bool
+	_null  bool
+	_value int
+}
+
+func GarbageCollectionFilter_RangeBits(v int) GarbageCollectionFilter_RangeBits_Field {
+	return GarbageCollectionFilter_RangeBits_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionFilter_RangeBits_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionFilter_RangeBits_Field) _Column() string { return "range_bits" }
+
+type GarbageCollectionFilter_SentAt_Field struct {
+	_set   bool
+	_null  bool
+	_value time.Time
+}
+
+func GarbageCollectionFilter_SentAt(v time.Time) GarbageCollectionFilter_SentAt_Field {
+	return GarbageCollectionFilter_SentAt_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionFilter_SentAt_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionFilter_SentAt_Field) _Column() string { return "sent_at" }
+
+type GarbageCollectionReport struct {
+	CreationDate   time.Time
+	NodeId         []byte
+	RangeBits      int
+	RangeIndex     int
+	PiecesExamined int64
+	PiecesTrashed  int64
+	BytesReclaimed int64
+	DurationMs     int64
+	ReportedAt     time.Time
+}
+
+func (GarbageCollectionReport) _Table() string { return "garbage_collection_reports" }
+
+type GarbageCollectionReport_Update_Fields struct {
+}
+
+type GarbageCollectionReport_CreationDate_Field struct {
+	_set   bool
+	_null  bool
+	_value time.Time
+}
+
+func GarbageCollectionReport_CreationDate(v time.Time) GarbageCollectionReport_CreationDate_Field {
+	return GarbageCollectionReport_CreationDate_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionReport_CreationDate_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionReport_CreationDate_Field) _Column() string { return "creation_date" }
+
+type GarbageCollectionReport_NodeId_Field struct {
+	_set   bool
+	_null  bool
+	_value []byte
+}
+
+func GarbageCollectionReport_NodeId(v []byte) GarbageCollectionReport_NodeId_Field {
+	return GarbageCollectionReport_NodeId_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionReport_NodeId_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionReport_NodeId_Field) _Column() string { return "node_id" }
+
+type GarbageCollectionReport_RangeBits_Field struct {
+	_set   bool
+	_null  bool
+	_value int
+}
+
+func GarbageCollectionReport_RangeBits(v int) GarbageCollectionReport_RangeBits_Field {
+	return GarbageCollectionReport_RangeBits_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionReport_RangeBits_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionReport_RangeBits_Field) _Column() string { return "range_bits" }
+
+type GarbageCollectionReport_RangeIndex_Field struct {
+	_set   bool
+	_null  bool
+	_value int
+}
+
+func GarbageCollectionReport_RangeIndex(v int) GarbageCollectionReport_RangeIndex_Field {
+	return GarbageCollectionReport_RangeIndex_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionReport_RangeIndex_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionReport_RangeIndex_Field) _Column() string { return "range_index" }
+
+type GarbageCollectionReport_PiecesExamined_Field struct {
+	_set   bool
+	_null  bool
+	_value int64
+}
+
+func GarbageCollectionReport_PiecesExamined(v int64) GarbageCollectionReport_PiecesExamined_Field {
+	return GarbageCollectionReport_PiecesExamined_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionReport_PiecesExamined_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionReport_PiecesExamined_Field) _Column() string { return "pieces_examined" }
+
+type GarbageCollectionReport_PiecesTrashed_Field struct {
+	_set   bool
+	_null  bool
+	_value int64
+}
+
+func GarbageCollectionReport_PiecesTrashed(v int64) GarbageCollectionReport_PiecesTrashed_Field {
+	return GarbageCollectionReport_PiecesTrashed_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionReport_PiecesTrashed_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionReport_PiecesTrashed_Field) _Column() string { return "pieces_trashed" }
+
+type GarbageCollectionReport_BytesReclaimed_Field struct {
+	_set   bool
+	_null  bool
+	_value int64
+}
+
+func GarbageCollectionReport_BytesReclaimed(v int64) GarbageCollectionReport_BytesReclaimed_Field {
+	return GarbageCollectionReport_BytesReclaimed_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionReport_BytesReclaimed_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionReport_BytesReclaimed_Field) _Column() string { return "bytes_reclaimed" }
+
+type GarbageCollectionReport_DurationMs_Field struct {
+	_set   bool
+	_null  bool
+	_value int64
+}
+
+func GarbageCollectionReport_DurationMs(v int64) GarbageCollectionReport_DurationMs_Field {
+	return GarbageCollectionReport_DurationMs_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionReport_DurationMs_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionReport_DurationMs_Field) _Column() string { return "duration_ms" }
+
+type GarbageCollectionReport_ReportedAt_Field struct {
+	_set   bool
+	_null  bool
+	_value time.Time
+}
+
+func GarbageCollectionReport_ReportedAt(v time.Time) GarbageCollectionReport_ReportedAt_Field {
+	return GarbageCollectionReport_ReportedAt_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionReport_ReportedAt_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionReport_ReportedAt_Field) _Column() string { return "reported_at" }
+
+type GarbageCollectionRun struct {
+	Id             int64
+	StartedAt      time.Time
+	FinishedAt     *time.Time
+	Status         int
+	Segments       int64
+	NodesTargeted  int
+	NodesSent      int
+	FilterBytes    int64
+	Error          *string
+	CreationDate   *time.Time
+	NodesReporting int
+}
+
+func (GarbageCollectionRun) _Table() string { return "garbage_collection_runs" }
+
+type GarbageCollectionRun_Create_Fields struct {
+	FinishedAt     GarbageCollectionRun_FinishedAt_Field
+	Error          GarbageCollectionRun_Error_Field
+	CreationDate   GarbageCollectionRun_CreationDate_Field
+	NodesReporting GarbageCollectionRun_NodesReporting_Field
+}
+
+type GarbageCollectionRun_Update_Fields struct {
+	FinishedAt     GarbageCollectionRun_FinishedAt_Field
+	Status         GarbageCollectionRun_Status_Field
+	Segments       GarbageCollectionRun_Segments_Field
+	NodesTargeted  GarbageCollectionRun_NodesTargeted_Field
+	NodesSent      GarbageCollectionRun_NodesSent_Field
+	FilterBytes    GarbageCollectionRun_FilterBytes_Field
+	Error          GarbageCollectionRun_Error_Field
+	CreationDate   GarbageCollectionRun_CreationDate_Field
+	NodesReporting GarbageCollectionRun_NodesReporting_Field
+}
+
+type GarbageCollectionRun_Id_Field struct {
+	_set   bool
+	_null  bool
+	_value int64
+}
+
+func GarbageCollectionRun_Id(v int64) GarbageCollectionRun_Id_Field {
+	return GarbageCollectionRun_Id_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionRun_Id_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionRun_Id_Field) _Column() string { return "id" }
+
+type GarbageCollectionRun_StartedAt_Field struct {
+	_set   bool
+	_null  bool
+	_value time.Time
+}
+
+func GarbageCollectionRun_StartedAt(v time.Time) GarbageCollectionRun_StartedAt_Field {
+	return GarbageCollectionRun_StartedAt_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionRun_StartedAt_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionRun_StartedAt_Field) _Column() string { return "started_at" }
+
+type GarbageCollectionRun_FinishedAt_Field struct {
+	_set   bool
+	_null  bool
+	_value *time.Time
+}
+
+func GarbageCollectionRun_FinishedAt(v time.Time) GarbageCollectionRun_FinishedAt_Field {
+	return GarbageCollectionRun_FinishedAt_Field{_set: true, _value: &v}
+}
+
+func GarbageCollectionRun_FinishedAt_Raw(v *time.Time) GarbageCollectionRun_FinishedAt_Field {
+	if v == nil {
+		return GarbageCollectionRun_FinishedAt_Null()
+	}
+	return GarbageCollectionRun_FinishedAt(*v)
+}
+
+func GarbageCollectionRun_FinishedAt_Null() GarbageCollectionRun_FinishedAt_Field {
+	return GarbageCollectionRun_FinishedAt_Field{_set: true, _null: true}
+}
+
+func (f GarbageCollectionRun_FinishedAt_Field) isnull() bool {
+	return !f._set || f._null || f._value == nil
+}
+
+func (f GarbageCollectionRun_FinishedAt_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionRun_FinishedAt_Field) _Column() string { return "finished_at" }
+
+type GarbageCollectionRun_Status_Field struct {
+	_set   bool
+	_null  bool
+	_value int
+}
+
+func GarbageCollectionRun_Status(v int) GarbageCollectionRun_Status_Field {
+	return GarbageCollectionRun_Status_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionRun_Status_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionRun_Status_Field) _Column() string { return "status" }
+
+type GarbageCollectionRun_Segments_Field struct {
+	_set   bool
+	_null  bool
+	_value int64
+}
+
+func GarbageCollectionRun_Segments(v int64) GarbageCollectionRun_Segments_Field {
+	return GarbageCollectionRun_Segments_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionRun_Segments_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionRun_Segments_Field) _Column() string { return "segments" }
+
+type GarbageCollectionRun_NodesTargeted_Field struct {
+	_set   bool
+	_null  bool
+	_value int
+}
+
+func GarbageCollectionRun_NodesTargeted(v int) GarbageCollectionRun_NodesTargeted_Field {
+	return GarbageCollectionRun_NodesTargeted_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionRun_NodesTargeted_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionRun_NodesTargeted_Field) _Column() string { return "nodes_targeted" }
+
+type GarbageCollectionRun_NodesSent_Field struct {
+	_set   bool
+	_null  bool
+	_value int
+}
+
+func GarbageCollectionRun_NodesSent(v int) GarbageCollectionRun_NodesSent_Field {
+	return GarbageCollectionRun_NodesSent_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionRun_NodesSent_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionRun_NodesSent_Field) _Column() string { return "nodes_sent" }
+
+type GarbageCollectionRun_FilterBytes_Field struct {
+	_set   bool
+	_null  bool
+	_value int64
+}
+
+func GarbageCollectionRun_FilterBytes(v int64) GarbageCollectionRun_FilterBytes_Field {
+	return GarbageCollectionRun_FilterBytes_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionRun_FilterBytes_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionRun_FilterBytes_Field) _Column() string { return "filter_bytes" }
+
+type GarbageCollectionRun_Error_Field struct {
+	_set   bool
+	_null  bool
+	_value *string
+}
+
+func GarbageCollectionRun_Error(v string) GarbageCollectionRun_Error_Field {
+	return GarbageCollectionRun_Error_Field{_set: true, _value: &v}
+}
+
+func GarbageCollectionRun_Error_Raw(v *string) GarbageCollectionRun_Error_Field {
+	if v == nil {
+		return GarbageCollectionRun_Error_Null()
+	}
+	return GarbageCollectionRun_Error(*v)
+}
+
+func GarbageCollectionRun_Error_Null() GarbageCollectionRun_Error_Field {
+	return GarbageCollectionRun_Error_Field{_set: true, _null: true}
+}
+
+func (f GarbageCollectionRun_Error_Field) isnull() bool { return !f._set || f._null || f._value == nil }
+
+func (f GarbageCollectionRun_Error_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionRun_Error_Field) _Column() string { return "error" }
+
+type GarbageCollectionRun_CreationDate_Field struct {
+	_set   bool
+	_null  bool
+	_value *time.Time
+}
+
+func GarbageCollectionRun_CreationDate(v time.Time) GarbageCollectionRun_CreationDate_Field {
+	return GarbageCollectionRun_CreationDate_Field{_set: true, _value: &v}
+}
+
+func GarbageCollectionRun_CreationDate_Raw(v *time.Time) GarbageCollectionRun_CreationDate_Field {
+	if v == nil {
+		return GarbageCollectionRun_CreationDate_Null()
+	}
+	return GarbageCollectionRun_CreationDate(*v)
+}
+
+func GarbageCollectionRun_CreationDate_Null() GarbageCollectionRun_CreationDate_Field {
+	return GarbageCollectionRun_CreationDate_Field{_set: true, _null: true}
+}
+
+func (f GarbageCollectionRun_CreationDate_Field) isnull() bool {
+	return !f._set || f._null || f._value == nil
+}
+
+func (f GarbageCollectionRun_CreationDate_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionRun_CreationDate_Field) _Column() string { return "creation_date" }
+
+type GarbageCollectionRun_NodesReporting_Field struct {
+	_set   bool
+	_null  bool
+	_value int
+}
+
+func GarbageCollectionRun_NodesReporting(v int) GarbageCollectionRun_NodesReporting_Field {
+	return GarbageCollectionRun_NodesReporting_Field{_set: true, _value: v}
+}
+
+func (f GarbageCollectionRun_NodesReporting_Field) value() interface{} {
+	if !f._set || f._null {
+		return nil
+	}
+	return f._value
+}
+
+func (GarbageCollectionRun_NodesReporting_Field) _Column() string { return "nodes_reporting" }
+
 type GracefulExitProgress struct {
 	NodeId            []byte
 	BytesTransferred  int64
@@ -16235,6 +16864,36 @@ func (obj *pgxImpl) deleteAll(ctx context.Context) (count int64, err error) {
 		return 0, obj.makeErr(err)
 	}
 
+	__count, err = __res.RowsAffected()
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+	count += __count
+	__res, err = obj.driver.ExecContext(ctx, "DELETE FROM garbage_collection_runs;")
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+
+	__count, err = __res.RowsAffected()
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+	count += __count
+	__res, err = obj.driver.ExecContext(ctx, "DELETE FROM garbage_collection_reports;")
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+
+	__count, err = __res.RowsAffected()
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+	count += __count
+	__res, err = obj.driver.ExecContext(ctx, "DELETE FROM garbage_collection_filters;")
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+
 	__count, err = __res.RowsAffected()
 	if err != nil {
 		return 0, obj.makeErr(err)
@@ -22260,6 +22919,36 @@ func (obj *pgxcockroachImpl) deleteAll(ctx context.Context) (count int64, err er
 		return 0, obj.makeErr(err)
 	}
 
+	__count, err = __res.RowsAffected()
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+	count += __count
+	__res, err = obj.driver.ExecContext(ctx, "DELETE FROM garbage_collection_runs;")
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+
+	__count, err = __res.RowsAffected()
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+	count += __count
+	__res, err = obj.driver.ExecContext(ctx, "DELETE FROM garbage_collection_reports;")
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+
+	__count, err = __res.RowsAffected()
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+	count += __count
+	__res, err = obj.driver.ExecContext(ctx, "DELETE FROM garbage_collection_filters;")
+	if err != nil {
+		return 0, obj.makeErr(err)
+	}
+
 	__count, err = __res.RowsAffected()
 	if err != nil {
 		return 0, obj.makeErr(err)
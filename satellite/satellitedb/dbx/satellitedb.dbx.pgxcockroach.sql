@@ -95,6 +95,39 @@ CREATE TABLE coupon_usages (
 	period timestamp with time zone NOT NULL,
 	PRIMARY KEY ( coupon_id, period )
 );
+CREATE TABLE garbage_collection_filters (
+	creation_date timestamp with time zone NOT NULL,
+	node_id bytea NOT NULL,
+	range_bits integer NOT NULL,
+	sent_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( creation_date, node_id )
+);
+CREATE TABLE garbage_collection_reports (
+	creation_date timestamp with time zone NOT NULL,
+	node_id bytea NOT NULL,
+	range_bits integer NOT NULL,
+	range_index integer NOT NULL,
+	pieces_examined bigint NOT NULL,
+	pieces_trashed bigint NOT NULL,
+	bytes_reclaimed bigint NOT NULL,
+	duration_ms bigint NOT NULL,
+	reported_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( creation_date, node_id, range_bits, range_index )
+);
+CREATE TABLE garbage_collection_runs (
+	id bigserial NOT NULL,
+	started_at timestamp with time zone NOT NULL,
+	finished_at timestamp with time zone,
+	status integer NOT NULL,
+	segments bigint NOT NULL,
+	nodes_targeted integer NOT NULL,
+	nodes_sent integer NOT NULL,
+	filter_bytes bigint NOT NULL,
+	error text,
+	creation_date timestamp with time zone,
+	nodes_reporting integer NOT NULL DEFAULT 0,
+	PRIMARY KEY ( id )
+);
 CREATE TABLE graceful_exit_progress (
 	node_id bytea NOT NULL,
 	bytes_transferred bigint NOT NULL,
@@ -0,0 +1,196 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package satellitedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"storj.io/common/storj"
+	"storj.io/storj/satellite/gc"
+)
+
+// ensure that gcRunsDB implements gc.HistoryDB.
+var _ gc.HistoryDB = (*gcRunsDB)(nil)
+
+type gcRunsDB struct {
+	db *satelliteDB
+}
+
+// StartGCRun records that a run has started and returns the ID of the record.
+func (db *gcRunsDB) StartGCRun(ctx context.Context, started time.Time) (id int64, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	err = db.db.QueryRowContext(ctx, `
+		INSERT INTO garbage_collection_runs (
+			started_at, status, segments, nodes_targeted, nodes_sent, filter_bytes
+		) VALUES (
+			$1, $2, 0, 0, 0, 0
+		)
+		RETURNING id
+	`, started.UTC(), int(gc.RunStatusRunning)).Scan(&id)
+	return id, Error.Wrap(err)
+}
+
+// FinishGCRun updates the record of a run with its outcome.
+func (db *gcRunsDB) FinishGCRun(ctx context.Context, record gc.RunRecord) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	var runError *string
+	if record.Error != "" {
+		runError = &record.Error
+	}
+	var creationDate *time.Time
+	if !record.CreationDate.IsZero() {
+		utc := record.CreationDate.UTC()
+		creationDate = &utc
+	}
+
+	result, err := db.db.ExecContext(ctx, `
+		UPDATE garbage_collection_runs SET
+			finished_at = $2, status = $3, segments = $4,
+			nodes_targeted = $5, nodes_sent = $6, filter_bytes = $7, error = $8,
+			creation_date = $9, nodes_reporting = $10
+		WHERE id = $1
+	`, record.ID, record.Finished.UTC(), int(record.Status), record.Segments,
+		record.NodesTargeted, record.NodesSent, record.FilterBytes, runError,
+		creationDate, record.NodesReporting)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	if affected == 0 {
+		return Error.New("garbage collection run %d not found", record.ID)
+	}
+	return nil
+}
+
+// LatestGCRun returns the record of the most recently started run, or
+// gc.ErrNoRuns when there is none. The totals of the reports are summed up
+// for the creation date of its filters.
+func (db *gcRunsDB) LatestGCRun(ctx context.Context) (record gc.RunRecord, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	var finished, creationDate *time.Time
+	var runError *string
+	var status int
+	err = db.db.QueryRowContext(ctx, `
+		SELECT id, started_at, finished_at, status, segments,
+			nodes_targeted, nodes_sent, filter_bytes, error,
+			creation_date, nodes_reporting
+		FROM garbage_collection_runs
+		ORDER BY started_at DESC, id DESC
+		LIMIT 1
+	`).Scan(&record.ID, &record.Started, &finished, &status, &record.Segments,
+		&record.NodesTargeted, &record.NodesSent, &record.FilterBytes, &runError,
+		&creationDate, &record.NodesReporting)
+	if errors.Is(err, sql.ErrNoRows) {
+		return gc.RunRecord{}, gc.ErrNoRuns.New("")
+	}
+	if err != nil {
+		return gc.RunRecord{}, Error.Wrap(err)
+	}
+
+	record.Status = gc.RunStatus(status)
+	if finished != nil {
+		record.Finished = *finished
+	}
+	if runError != nil {
+		record.Error = *runError
+	}
+	if creationDate == nil {
+		return record, nil
+	}
+
+	record.CreationDate = *creationDate
+	err = db.db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT node_id), COALESCE(SUM(pieces_examined), 0),
+			COALESCE(SUM(pieces_trashed), 0), COALESCE(SUM(bytes_reclaimed), 0)
+		FROM garbage_collection_reports
+		WHERE creation_date = $1
+	`, creationDate.UTC()).Scan(&record.Reports.Nodes, &record.Reports.PiecesExamined,
+		&record.Reports.PiecesTrashed, &record.Reports.BytesReclaimed)
+	if err != nil {
+		return gc.RunRecord{}, Error.Wrap(err)
+	}
+	return record, nil
+}
+
+// RecordSentFilter stores that a filter was sent to a node, which allows the
+// node to report the result. Sending the filter of the same run again replaces
+// the record.
+func (db *gcRunsDB) RecordSentFilter(ctx context.Context, filter gc.SentFilter) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	_, err = db.db.ExecContext(ctx, `
+		INSERT INTO garbage_collection_filters (
+			creation_date, node_id, range_bits, sent_at
+		) VALUES (
+			$1, $2, $3, $4
+		)
+		ON CONFLICT ( creation_date, node_id ) DO UPDATE SET
+			range_bits = EXCLUDED.range_bits,
+			sent_at = EXCLUDED.sent_at
+	`, filter.CreationDate.UTC(), filter.NodeID, int(filter.RangeBits), filter.Sent.UTC())
+	return Error.Wrap(err)
+}
+
+// GetSentFilter returns the filter that was sent to the node with the creation
+// date, or gc.ErrFilterNotSent when there is none.
+func (db *gcRunsDB) GetSentFilter(ctx context.Context, nodeID storj.NodeID, creationDate time.Time) (_ gc.SentFilter, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	var rangeBits int
+	var sent time.Time
+	err = db.db.QueryRowContext(ctx, `
+		SELECT range_bits, sent_at
+		FROM garbage_collection_filters
+		WHERE creation_date = $1 AND node_id = $2
+	`, creationDate.UTC(), nodeID).Scan(&rangeBits, &sent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return gc.SentFilter{}, gc.ErrFilterNotSent.New("%v", nodeID)
+	}
+	if err != nil {
+		return gc.SentFilter{}, Error.Wrap(err)
+	}
+	return gc.SentFilter{
+		NodeID:       nodeID,
+		CreationDate: creationDate,
+		RangeBits:    uint8(rangeBits),
+		Sent:         sent,
+	}, nil
+}
+
+// RecordRetainReport stores the report of a node. It returns false when the
+// node already reported the request.
+func (db *gcRunsDB) RecordRetainReport(ctx context.Context, report gc.RetainReport) (recorded bool, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	result, err := db.db.ExecContext(ctx, `
+		INSERT INTO garbage_collection_reports (
+			creation_date, node_id, range_bits, range_index,
+			pieces_examined, pieces_trashed, bytes_reclaimed, duration_ms, reported_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9
+		)
+		ON CONFLICT DO NOTHING
+	`, report.CreationDate.UTC(), report.NodeID, int(report.Range.Bits), int(report.Range.Index),
+		report.PiecesExamined, report.PiecesTrashed, report.BytesReclaimed,
+		report.Duration.Milliseconds(), report.Reported.UTC())
+	if err != nil {
+		return false, Error.Wrap(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, Error.Wrap(err)
+	}
+	return affected > 0, nil
+}
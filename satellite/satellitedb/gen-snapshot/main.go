@@ -0,0 +1,358 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// gen-snapshot generates the testdata snapshot of a satellitedb migration
+// version. It migrates a temporary postgres database up to the version, dumps
+// its schema and carries forward the data sections of the previous snapshot.
+// The NEW DATA section is left empty for the data of the new tables and
+// columns.
+//
+// Usage:
+//
+//	go run ./satellite/satellitedb/gen-snapshot -testdata satellite/satellitedb/testdata/satellitedb -version N
+//
+// The database defaults to STORJ_TEST_POSTGRES and the version to the latest
+// migration step.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/private/dbutil"
+	"storj.io/private/dbutil/dbschema"
+	"storj.io/private/dbutil/pgutil"
+	"storj.io/private/dbutil/tempdb"
+	"storj.io/private/tagsql"
+	"storj.io/storj/satellite/satellitedb"
+)
+
+func main() {
+	database := flag.String("database", os.Getenv("STORJ_TEST_POSTGRES"), "postgres database to migrate a temporary schema in")
+	testdata := flag.String("testdata", "testdata/satellitedb", "directory of the snapshots")
+	version := flag.Int("version", -1, "migration version to generate the snapshot of, the latest by default")
+	force := flag.Bool("force", false, "overwrite an existing snapshot")
+	flag.Parse()
+
+	if err := run(context.Background(), *database, *testdata, *version, *force); err != nil {
+		fmt.Fprintf(os.Stderr, "%+v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, connstr, testdata string, version int, force bool) (err error) {
+	if connstr == "" {
+		return errs.New("database is not set, use -database or STORJ_TEST_POSTGRES")
+	}
+
+	script, version, err := generate(ctx, zap.NewNop(), connstr, testdata, version)
+	if err != nil {
+		return err
+	}
+
+	path := snapshotPath(testdata, version)
+	if _, err := os.Stat(path); err == nil && !force {
+		return errs.New("%s exists already, use -force to overwrite it", path)
+	}
+	if err := ioutil.WriteFile(path, []byte(script), 0644); err != nil {
+		return errs.Wrap(err)
+	}
+
+	fmt.Println("generated", path)
+	return nil
+}
+
+// generate returns the snapshot script of the version. A negative version
+// is the latest migration step.
+func generate(ctx context.Context, log *zap.Logger, connstr, testdata string, version int) (_ string, _ int, err error) {
+	if _, _, impl, err := dbutil.SplitConnStr(connstr); err != nil || impl != dbutil.Postgres {
+		return "", 0, errs.New("snapshots are generated with postgres: %q", connstr)
+	}
+
+	tempDB, err := tempdb.OpenUnique(ctx, connstr, "gen-snapshot")
+	if err != nil {
+		return "", 0, errs.Wrap(err)
+	}
+	defer func() { err = errs.Combine(err, tempDB.Close()) }()
+
+	db, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{ApplicationName: "satellite-gen-snapshot"})
+	if err != nil {
+		return "", 0, errs.Wrap(err)
+	}
+	defer func() { err = errs.Combine(err, db.Close()) }()
+
+	if version < 0 {
+		statuses, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return "", 0, errs.Wrap(err)
+		}
+		version = statuses[""].Latest
+	}
+
+	previous, err := previousSnapshot(testdata, version)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := db.MigrateToVersion(ctx, version); err != nil {
+		return "", 0, errs.Wrap(err)
+	}
+
+	schema, err := pgutil.QuerySchema(ctx, tempDB)
+	if err != nil {
+		return "", 0, errs.Wrap(err)
+	}
+	schema.DropTable("versions")
+	schema.DropTable("versions_history")
+	schema.DropTable("versions_lock")
+
+	columnOrder, err := queryColumnOrder(ctx, tempDB)
+	if err != nil {
+		return "", 0, errs.Wrap(err)
+	}
+
+	var script strings.Builder
+	script.WriteString("-- AUTOGENERATED BY gen-snapshot\n")
+	writeSchema(&script, schema, columnOrder)
+
+	// the data of the main section, e.g. the default offers, is carried
+	// forward like the data sections.
+	for _, line := range strings.Split(previous.LookupSection(dbschema.Main), "\n") {
+		if strings.HasPrefix(line, "INSERT INTO ") {
+			script.WriteString("\n")
+			script.WriteString(line)
+			script.WriteString("\n")
+		}
+	}
+
+	script.WriteString("\n")
+	script.WriteString(previous.LookupSection(dbschema.MainData))
+	if newData := previous.LookupSection(dbschema.NewData); newData != "" {
+		// drop the section header, the data is part of the main data now.
+		if i := strings.IndexByte(newData, '\n'); i >= 0 {
+			script.WriteString(newData[i+1:])
+		}
+	}
+	script.WriteString("\n-- NEW DATA --\n")
+
+	return script.String(), version, nil
+}
+
+// previousSnapshot loads the sections of the latest snapshot below the version.
+func previousSnapshot(testdata string, version int) (dbschema.Sections, error) {
+	matches, err := filepath.Glob(filepath.Join(testdata, "postgres.v*.sql"))
+	if err != nil {
+		return dbschema.Sections{}, errs.Wrap(err)
+	}
+
+	previous := -1
+	for _, match := range matches {
+		v := parseSnapshotVersion(match)
+		if v < version && v > previous {
+			previous = v
+		}
+	}
+	if previous < 0 {
+		return dbschema.Sections{}, errs.New("no snapshot below version %d in %s", version, testdata)
+	}
+
+	data, err := ioutil.ReadFile(snapshotPath(testdata, previous))
+	if err != nil {
+		return dbschema.Sections{}, errs.Wrap(err)
+	}
+	return dbschema.NewSections(string(data)), nil
+}
+
+func snapshotPath(testdata string, version int) string {
+	return filepath.Join(testdata, fmt.Sprintf("postgres.v%d.sql", version))
+}
+
+func parseSnapshotVersion(path string) int {
+	name := strings.ToLower(filepath.Base(path))
+	name = strings.TrimPrefix(name, "postgres.v")
+	name = strings.TrimSuffix(name, ".sql")
+
+	v, err := strconv.Atoi(name)
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+// queryColumnOrder returns the positions of the columns by table, so that the
+// tables are created with the columns in their order. Inserts without column
+// names depend on it.
+func queryColumnOrder(ctx context.Context, db tagsql.DB) (_ map[string]map[string]int, err error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT table_name, column_name, ordinal_position
+		FROM information_schema.columns
+		WHERE table_schema = CURRENT_SCHEMA
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errs.Combine(err, rows.Close()) }()
+
+	order := make(map[string]map[string]int)
+	for rows.Next() {
+		var table, column string
+		var position int
+		if err := rows.Scan(&table, &column, &position); err != nil {
+			return nil, err
+		}
+		if order[table] == nil {
+			order[table] = make(map[string]int)
+		}
+		order[table][column] = position
+	}
+	return order, rows.Err()
+}
+
+// writeSchema writes the statements that create the schema. The tables are
+// created in the order of their references, the indexes of the primary keys and
+// the unique constraints are named by the constraints.
+func writeSchema(w *strings.Builder, schema *dbschema.Schema, columnOrder map[string]map[string]int) {
+	tables := make(map[string]*dbschema.Table)
+	for _, table := range schema.Tables {
+		tables[table.Name] = table
+	}
+
+	created := make(map[string]bool)
+	var create func(table *dbschema.Table)
+	create = func(table *dbschema.Table) {
+		if created[table.Name] {
+			return
+		}
+		created[table.Name] = true
+
+		for _, column := range table.Columns {
+			if column.Reference != nil && tables[column.Reference.Table] != nil {
+				create(tables[column.Reference.Table])
+			}
+		}
+		writeTable(w, table, schema.Indexes, columnOrder[table.Name])
+	}
+	for _, table := range schema.Tables {
+		create(table)
+	}
+
+	for _, index := range schema.Indexes {
+		if constraintIndex(tables[index.Table], index) {
+			continue
+		}
+
+		unique := ""
+		if index.Unique {
+			unique = "UNIQUE "
+		}
+		fmt.Fprintf(w, "CREATE %sINDEX %s ON %s ( %s )", unique, index.Name, index.Table, strings.Join(index.Columns, ", "))
+		if index.Partial != "" {
+			fmt.Fprintf(w, " WHERE %s", index.Partial)
+		}
+		w.WriteString(" ;\n")
+	}
+}
+
+func writeTable(w *strings.Builder, table *dbschema.Table, indexes []*dbschema.Index, columnOrder map[string]int) {
+	columns := append([]*dbschema.Column{}, table.Columns...)
+	sort.SliceStable(columns, func(i, k int) bool {
+		return columnOrder[columns[i].Name] < columnOrder[columns[k].Name]
+	})
+
+	var lines []string
+	for _, column := range columns {
+		lines = append(lines, columnDefinition(column))
+	}
+	if len(table.PrimaryKey) > 0 {
+		lines = append(lines, constraintDefinition(table, indexes, "PRIMARY KEY", table.PrimaryKey))
+	}
+	for _, unique := range table.Unique {
+		lines = append(lines, constraintDefinition(table, indexes, "UNIQUE", unique))
+	}
+
+	fmt.Fprintf(w, "CREATE TABLE %s (\n\t%s\n);\n", table.Name, strings.Join(lines, ",\n\t"))
+}
+
+func columnDefinition(column *dbschema.Column) string {
+	typ := column.Type
+	defaultValue := column.Default
+	// sequences are created with the columns.
+	if strings.HasPrefix(defaultValue, "nextval(") {
+		switch typ {
+		case "integer":
+			typ = "serial"
+		case "bigint":
+			typ = "bigserial"
+		}
+		defaultValue = ""
+	}
+
+	definition := column.Name + " " + typ
+	if !column.IsNullable {
+		definition += " NOT NULL"
+	}
+	if defaultValue != "" {
+		definition += " DEFAULT " + defaultValue
+	}
+	if ref := column.Reference; ref != nil {
+		definition += fmt.Sprintf(" REFERENCES %s( %s )", ref.Table, ref.Column)
+		if ref.OnDelete != "" {
+			definition += " ON DELETE " + ref.OnDelete
+		}
+		if ref.OnUpdate != "" {
+			definition += " ON UPDATE " + ref.OnUpdate
+		}
+	}
+	return definition
+}
+
+// constraintDefinition returns the definition of a primary key or unique
+// constraint, named like its index.
+func constraintDefinition(table *dbschema.Table, indexes []*dbschema.Index, kind string, columns []string) string {
+	definition := fmt.Sprintf("%s ( %s )", kind, strings.Join(columns, ", "))
+	for _, index := range indexes {
+		if index.Table == table.Name && index.Unique && index.Partial == "" && equalColumns(index.Columns, columns) {
+			return "CONSTRAINT " + index.Name + " " + definition
+		}
+	}
+	return definition
+}
+
+// constraintIndex returns whether the index is created by a primary key or a
+// unique constraint of the table.
+func constraintIndex(table *dbschema.Table, index *dbschema.Index) bool {
+	if table == nil || !index.Unique || index.Partial != "" {
+		return false
+	}
+	if equalColumns(index.Columns, table.PrimaryKey) {
+		return true
+	}
+	for _, unique := range table.Unique {
+		if equalColumns(index.Columns, unique) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/private/dbutil/dbschema"
+	"storj.io/private/dbutil/pgtest"
+	"storj.io/private/dbutil/pgutil"
+	"storj.io/private/dbutil/tempdb"
+)
+
+func TestGenerate(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	connstr := pgtest.PickPostgres(t)
+
+	const testdata = "../testdata/satellitedb"
+	matches, err := filepath.Glob(filepath.Join(testdata, "postgres.v*.sql"))
+	require.NoError(t, err)
+	latest := -1
+	for _, match := range matches {
+		if v := parseSnapshotVersion(match); v > latest {
+			latest = v
+		}
+	}
+	require.NotEqual(t, -1, latest)
+
+	// regenerate the latest snapshot.
+	script, version, err := generate(ctx, zaptest.NewLogger(t), connstr, testdata, latest)
+	require.NoError(t, err)
+	require.Equal(t, latest, version)
+
+	checkedIn, err := ioutil.ReadFile(snapshotPath(testdata, latest))
+	require.NoError(t, err)
+
+	generated := dbschema.NewSections(script)
+	require.Equal(t, "-- NEW DATA --\n", generated.LookupSection(dbschema.NewData))
+
+	// the generated snapshot has the schema of the checked-in one and the
+	// data before its new data.
+	expected := loadSnapshot(ctx, t, connstr, dbschema.NewSections(string(checkedIn)))
+	actual := loadSnapshot(ctx, t, connstr, generated)
+	require.Equal(t, expected.Schema, actual.Schema)
+	require.Equal(t, expected.Data, actual.Data)
+}
+
+// loadSnapshot loads the main and the main data sections into a temporary
+// database and returns its snapshot.
+func loadSnapshot(ctx context.Context, t *testing.T, connstr string, sections dbschema.Sections) *dbschema.Snapshot {
+	db, err := tempdb.OpenUnique(ctx, connstr, "gen-snapshot-test")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	_, err = db.ExecContext(ctx, sections.LookupSection(dbschema.Main))
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, sections.LookupSection(dbschema.MainData))
+	require.NoError(t, err)
+
+	snapshot, err := pgutil.QuerySnapshot(ctx, db)
+	require.NoError(t, err)
+	return snapshot
+}
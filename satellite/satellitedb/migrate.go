@@ -6,6 +6,7 @@ package satellitedb
 import (
 	"context"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/zeebo/errs"
@@ -18,17 +19,44 @@ import (
 	"storj.io/storj/private/migrate"
 )
 
-//go:generate go run migrate_gen.go
+//go:generate go test -run TestMigratez -generate-migratez
 
 var (
 	// ErrMigrate is for tracking migration errors.
 	ErrMigrate = errs.Class("migrate")
 	// ErrMigrateMinVersion is for migration min version errors.
 	ErrMigrateMinVersion = errs.Class("migrate min version")
+	// ErrReadOnly is for schema changes on a database opened read-only.
+	ErrReadOnly = errs.Class("read-only")
 )
 
 // MigrateToLatest migrates the database to the latest version.
 func (db *satelliteDB) MigrateToLatest(ctx context.Context) error {
+	return db.migrateTo(ctx, -1)
+}
+
+// MigrateToVersion migrates the database up to the given version and stops.
+// The version must be one of the steps and not below the current version.
+func (db *satelliteDB) MigrateToVersion(ctx context.Context, version int) error {
+	if version < 0 {
+		return ErrMigrate.New("invalid target version %d", version)
+	}
+	return db.migrateTo(ctx, version)
+}
+
+// migrateTo migrates the database to the target version, or to the latest
+// version when the target is negative.
+func (db *satelliteDB) migrateTo(ctx context.Context, target int) (err error) {
+	if db.opts.ReadOnly {
+		return ErrReadOnly.New("refusing to migrate a database opened read-only")
+	}
+
+	finish, err := db.useMigrationTimeouts(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
 	// First handle the idiosyncrasies of postgres and cockroach migrations. Postgres
 	// will need to create any schemas specified in the search path, and cockroach
 	// will need to create the database it was told to connect to. These things should
@@ -42,7 +70,7 @@ func (db *satelliteDB) MigrateToLatest(ctx context.Context) error {
 		}
 
 		if schema != "" {
-			err = pgutil.CreateSchema(ctx, db, schema)
+			err = pgutil.CreateSchema(ctx, db.migrationDB, schema)
 			if err != nil {
 				return errs.New("error creating schema: %+v", err)
 			}
@@ -50,11 +78,11 @@ func (db *satelliteDB) MigrateToLatest(ctx context.Context) error {
 
 	case dbutil.Cockroach:
 		var dbName string
-		if err := db.QueryRow(ctx, `SELECT current_database();`).Scan(&dbName); err != nil {
+		if err := db.migrationDB.QueryRow(ctx, `SELECT current_database();`).Scan(&dbName); err != nil {
 			return errs.New("error querying current database: %+v", err)
 		}
 
-		_, err := db.Exec(ctx, fmt.Sprintf(`CREATE DATABASE IF NOT EXISTS %s;`,
+		_, err := db.migrationDB.Exec(ctx, fmt.Sprintf(`CREATE DATABASE IF NOT EXISTS %s;`,
 			pgutil.QuoteIdentifier(dbName)))
 		if err != nil {
 			return errs.Wrap(err)
@@ -67,7 +95,7 @@ func (db *satelliteDB) MigrateToLatest(ctx context.Context) error {
 		// since we merged migration steps 0-69, the current db version should never be
 		// less than 69 unless the migration hasn't run yet
 		const minDBVersion = 69
-		dbVersion, err := migration.CurrentVersion(ctx, db.log, db.DB)
+		dbVersion, err := migration.CurrentVersion(ctx, db.log, db.migrationDB)
 		if err != nil {
 			return errs.New("error current version: %+v", err)
 		}
@@ -77,14 +105,42 @@ func (db *satelliteDB) MigrateToLatest(ctx context.Context) error {
 			)
 		}
 
+		if target >= 0 {
+			if !hasStep(migration, target) {
+				return ErrMigrate.New("unknown target version %d", target)
+			}
+			if dbVersion > target {
+				return ErrMigrate.New("target version %d is below the current version %d, steps can only be undone by a down migration",
+					target, dbVersion,
+				)
+			}
+			migration = migration.TargetVersion(target)
+		}
+
+		migration.NoWait = db.opts.MigrationNoWait
+		migration.IgnoreChecksumMismatch = db.opts.MigrationIgnoreChecksumMismatch
+		migration.ConfirmedVersions = db.opts.MigrationConfirmedVersions
 		return migration.Run(ctx, db.log.Named("migrate"))
 	default:
+		if target >= 0 {
+			return ErrMigrate.New("migrating to a target version is not supported by %s", db.impl)
+		}
 		return migrate.Create(ctx, "database", db.DB)
 	}
 }
 
 // TestingMigrateToLatest is a method for creating all tables for database for testing.
-func (db *satelliteDB) TestingMigrateToLatest(ctx context.Context) error {
+func (db *satelliteDB) TestingMigrateToLatest(ctx context.Context) (err error) {
+	if db.opts.ReadOnly {
+		return ErrReadOnly.New("refusing to migrate a database opened read-only")
+	}
+
+	finish, err := db.useMigrationTimeouts(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
 	switch db.impl {
 	case dbutil.Postgres:
 		schema, err := pgutil.ParseSchemaFromConnstr(db.source)
@@ -93,7 +149,7 @@ func (db *satelliteDB) TestingMigrateToLatest(ctx context.Context) error {
 		}
 
 		if schema != "" {
-			err = pgutil.CreateSchema(ctx, db, schema)
+			err = pgutil.CreateSchema(ctx, db.migrationDB, schema)
 			if err != nil {
 				return ErrMigrateMinVersion.New("error creating schema: %+v", err)
 			}
@@ -101,11 +157,11 @@ func (db *satelliteDB) TestingMigrateToLatest(ctx context.Context) error {
 
 	case dbutil.Cockroach:
 		var dbName string
-		if err := db.QueryRow(ctx, `SELECT current_database();`).Scan(&dbName); err != nil {
+		if err := db.migrationDB.QueryRow(ctx, `SELECT current_database();`).Scan(&dbName); err != nil {
 			return ErrMigrateMinVersion.New("error querying current database: %+v", err)
 		}
 
-		_, err := db.Exec(ctx, fmt.Sprintf(`CREATE DATABASE IF NOT EXISTS %s;`, pgutil.QuoteIdentifier(dbName)))
+		_, err := db.migrationDB.Exec(ctx, fmt.Sprintf(`CREATE DATABASE IF NOT EXISTS %s;`, pgutil.QuoteIdentifier(dbName)))
 		if err != nil {
 			return ErrMigrateMinVersion.Wrap(err)
 		}
@@ -115,15 +171,19 @@ func (db *satelliteDB) TestingMigrateToLatest(ctx context.Context) error {
 	case dbutil.Postgres, dbutil.Cockroach:
 		migration := db.PostgresMigration()
 
-		dbVersion, err := migration.CurrentVersion(ctx, db.log, db.DB)
+		dbVersion, err := migration.CurrentVersion(ctx, db.log, db.migrationDB)
 		if err != nil {
 			return ErrMigrateMinVersion.Wrap(err)
 		}
+		testMigration := db.TestPostgresMigration()
 		if dbVersion > -1 {
+			// a database that is cloned from a migrated template is up to date.
+			if dbVersion == testMigration.Steps[len(testMigration.Steps)-1].Version {
+				return nil
+			}
 			return ErrMigrateMinVersion.New("the database must be empty, got version %d", dbVersion)
 		}
 
-		testMigration := db.TestPostgresMigration()
 		return testMigration.Run(ctx, db.log.Named("migrate"))
 	default:
 		return migrate.Create(ctx, "database", db.DB)
@@ -142,6 +202,70 @@ func (db *satelliteDB) CheckVersion(ctx context.Context) error {
 	}
 }
 
+// schemaVersion returns the current version of the database schema.
+func (db *satelliteDB) schemaVersion(ctx context.Context) (int, error) {
+	switch db.impl {
+	case dbutil.Postgres, dbutil.Cockroach:
+		migration := db.PostgresMigration()
+		status, err := migration.Status(ctx, db.log)
+		return status.Current, err
+
+	default:
+		return -1, nil
+	}
+}
+
+// PlanMigration writes the pending migration steps to w without executing them.
+func (db *satelliteDB) PlanMigration(ctx context.Context, w io.Writer) error {
+	switch db.impl {
+	case dbutil.Postgres, dbutil.Cockroach:
+		migration := db.PostgresMigration()
+		return migration.Plan(ctx, db.log, w)
+
+	default:
+		return ErrMigrate.New("planning is not supported for %s", db.impl)
+	}
+}
+
+// PlanMigrationJSON returns the pending migration steps as JSON without
+// executing them, see migrate.Migration.PlanJSON.
+func (db *satelliteDB) PlanMigrationJSON(ctx context.Context) ([]byte, error) {
+	switch db.impl {
+	case dbutil.Postgres, dbutil.Cockroach:
+		migration := db.PostgresMigration()
+		status, err := migration.Status(ctx, db.log)
+		if err != nil {
+			return nil, err
+		}
+		return migration.PlanJSON(status.Current)
+
+	default:
+		return nil, ErrMigrate.New("planning is not supported for %s", db.impl)
+	}
+}
+
+// MigrationStatus returns the state of the migration of the database, without changing it.
+func (db *satelliteDB) MigrationStatus(ctx context.Context) (migrate.Status, error) {
+	switch db.impl {
+	case dbutil.Postgres, dbutil.Cockroach:
+		migration := db.PostgresMigration()
+		return migration.Status(ctx, db.log)
+
+	default:
+		return migrate.Status{}, ErrMigrate.New("status is not supported for %s", db.impl)
+	}
+}
+
+// hasStep returns whether the migration has a step with the version.
+func hasStep(migration *migrate.Migration, version int) bool {
+	for _, step := range migration.Steps {
+		if step.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
 // TestPostgresMigration returns steps needed for migrating test postgres database.
 func (db *satelliteDB) TestPostgresMigration() *migrate.Migration {
 	return db.testMigration()
@@ -1711,6 +1835,8 @@ func (db *satelliteDB) PostgresMigration() *migrate.Migration {
 				Description: "add project_bandwidth_limit and project_storage_limit to the user table",
 				Version:     181,
 				SeparateTx:  true,
+				// the limits that were overwritten are not known anymore.
+				Irreversible: true,
 				Action: migrate.SQL{
 					`UPDATE users SET project_bandwidth_limit = 50000000000, project_storage_limit = 50000000000
                                          WHERE (project_bandwidth_limit = 0 AND project_storage_limit = 0 AND paid_tier = false);`,
@@ -1726,6 +1852,9 @@ func (db *satelliteDB) PostgresMigration() *migrate.Migration {
 				Action: migrate.SQL{
 					`ALTER TABLE projects ADD COLUMN segment_limit bigint DEFAULT 1000000`,
 				},
+				Down: migrate.SQL{
+					`ALTER TABLE projects DROP COLUMN segment_limit`,
+				},
 			},
 			{
 				DB:          &db.migrationDB,
@@ -1734,6 +1863,9 @@ func (db *satelliteDB) PostgresMigration() *migrate.Migration {
 				Action: migrate.SQL{
 					`ALTER TABLE users ADD COLUMN project_segment_limit bigint NOT NULL DEFAULT 0`,
 				},
+				Down: migrate.SQL{
+					`ALTER TABLE users DROP COLUMN project_segment_limit`,
+				},
 			},
 			{
 				DB:          &db.migrationDB,
@@ -1742,9 +1874,68 @@ func (db *satelliteDB) PostgresMigration() *migrate.Migration {
 				Action: migrate.SQL{
 					`ALTER TABLE users ADD COLUMN last_verification_reminder timestamp with time zone`,
 				},
+				Down: migrate.SQL{
+					`ALTER TABLE users DROP COLUMN last_verification_reminder`,
+				},
+			},
+			{
+				DB:          &db.migrationDB,
+				Description: "add garbage_collection_runs table",
+				Version:     185,
+				Action: migrate.SQL{
+					`CREATE TABLE garbage_collection_runs (
+						id bigserial NOT NULL,
+						started_at timestamp with time zone NOT NULL,
+						finished_at timestamp with time zone,
+						status integer NOT NULL,
+						segments bigint NOT NULL,
+						nodes_targeted integer NOT NULL,
+						nodes_sent integer NOT NULL,
+						filter_bytes bigint NOT NULL,
+						error text,
+						PRIMARY KEY ( id )
+					)`,
+				},
+				Down: migrate.SQL{
+					`DROP TABLE garbage_collection_runs`,
+				},
+			},
+			{
+				DB:          &db.migrationDB,
+				Description: "add garbage_collection_reports and garbage_collection_filters tables and report columns to garbage_collection_runs",
+				Version:     186,
+				Action: migrate.SQL{
+					`ALTER TABLE garbage_collection_runs ADD COLUMN creation_date timestamp with time zone`,
+					`ALTER TABLE garbage_collection_runs ADD COLUMN nodes_reporting integer NOT NULL DEFAULT 0`,
+					`CREATE TABLE garbage_collection_reports (
+						creation_date timestamp with time zone NOT NULL,
+						node_id bytea NOT NULL,
+						range_bits integer NOT NULL,
+						range_index integer NOT NULL,
+						pieces_examined bigint NOT NULL,
+						pieces_trashed bigint NOT NULL,
+						bytes_reclaimed bigint NOT NULL,
+						duration_ms bigint NOT NULL,
+						reported_at timestamp with time zone NOT NULL,
+						PRIMARY KEY ( creation_date, node_id, range_bits, range_index )
+					)`,
+					`CREATE TABLE garbage_collection_filters (
+						creation_date timestamp with time zone NOT NULL,
+						node_id bytea NOT NULL,
+						range_bits integer NOT NULL,
+						sent_at timestamp with time zone NOT NULL,
+						PRIMARY KEY ( creation_date, node_id )
+					)`,
+				},
+				Down: migrate.SQL{
+					`DROP TABLE garbage_collection_filters`,
+					`DROP TABLE garbage_collection_reports`,
+					`ALTER TABLE garbage_collection_runs DROP COLUMN nodes_reporting`,
+					`ALTER TABLE garbage_collection_runs DROP COLUMN creation_date`,
+				},
 			},
 
-			// NB: after updating testdata in `testdata`, run
+			// NB: after updating testdata in `testdata/satellitedb`, run
 			//     `go generate` to update `migratez.go`.
 		},
 	}
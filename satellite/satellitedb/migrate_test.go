@@ -8,7 +8,9 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -25,43 +27,92 @@ import (
 
 	"storj.io/common/sync2"
 	"storj.io/common/testcontext"
+	"storj.io/private/dbutil"
 	"storj.io/private/dbutil/dbschema"
 	"storj.io/private/dbutil/pgtest"
 	"storj.io/private/dbutil/pgutil"
 	"storj.io/private/dbutil/tempdb"
 	"storj.io/storj/private/migrate"
+	"storj.io/storj/private/schemaextra"
+	"storj.io/storj/private/testplanet"
+	"storj.io/storj/satellite"
+	"storj.io/storj/satellite/metabase"
 	"storj.io/storj/satellite/satellitedb"
 	"storj.io/storj/satellite/satellitedb/dbx"
+	"storj.io/storj/satellite/satellitedb/satellitedbtest"
 )
 
-// loadSnapshots loads all the dbschemas from `testdata/postgres.*`.
-func loadSnapshots(ctx context.Context, connstr, dbxscript string) (*dbschema.Snapshots, *dbschema.Schema, error) {
+// Environment variables that override how the snapshots are loaded.
+const (
+	snapshotLimitEnv       = "STORJ_MIGRATION_SNAPSHOT_LIMIT"
+	snapshotConcurrencyEnv = "STORJ_MIGRATION_SNAPSHOT_CONCURRENCY"
+)
+
+// snapshotLoading configures how loadSnapshots loads the snapshots.
+type snapshotLoading struct {
+	// Limit is the number of the latest snapshots that are loaded, 0 loads all of them.
+	Limit int
+	// Concurrency is the number of snapshots that are loaded at once.
+	Concurrency int
+}
+
+// snapshotLoadingConfig returns how the snapshots are loaded into the database.
+// Cockroach only loads the latest snapshots, because the database creation is
+// not as fast. The defaults can be overridden with environment variables, e.g.
+// to verify the full history on cockroach.
+func snapshotLoadingConfig(connstr string, getenv func(string) string) (snapshotLoading, error) {
+	config := snapshotLoading{Limit: 0, Concurrency: 16}
+	if strings.Contains(connstr, "cockroach") {
+		config = snapshotLoading{Limit: 10, Concurrency: 4}
+	}
+
+	if value := getenv(snapshotLimitEnv); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return snapshotLoading{}, errs.New("invalid %s %q, expected a number of snapshots or 0 for all of them", snapshotLimitEnv, value)
+		}
+		config.Limit = limit
+	}
+
+	if value := getenv(snapshotConcurrencyEnv); value != "" {
+		concurrency, err := strconv.Atoi(value)
+		if err != nil || concurrency < 1 {
+			return snapshotLoading{}, errs.New("invalid %s %q, expected a positive number", snapshotConcurrencyEnv, value)
+		}
+		config.Concurrency = concurrency
+	}
+
+	return config, nil
+}
+
+// loadSnapshots loads all the dbschemas from `postgres.*` of the testdata
+// directory, with the sequences and check constraints of the snapshots by
+// version. The dbx schema is nil when dbxscript is empty.
+func loadSnapshots(ctx context.Context, t *testing.T, connstr, testdata, dbxscript string) (*dbschema.Snapshots, map[int]*schemaextra.Schema, *dbschema.Schema, error) {
 	snapshots := &dbschema.Snapshots{}
 
+	config, err := snapshotLoadingConfig(connstr, os.Getenv)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	t.Logf("loading snapshots: limit %d (0 is all), concurrency %d", config.Limit, config.Concurrency)
+
 	// find all postgres sql files
-	matches, err := filepath.Glob("testdata/postgres.*")
+	matches, err := filepath.Glob(filepath.Join(testdata, "postgres.*"))
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	sort.Strings(matches)
 
-	// Limit the number of snapshots we are checking for Cockroach
-	// because the database creation is not as fast.
-	if strings.Contains(connstr, "cockroach") {
-		const cockroachSnapshotLimit = 10
-		if len(matches) > cockroachSnapshotLimit {
-			matches = matches[len(matches)-cockroachSnapshotLimit:]
-		}
+	if config.Limit > 0 && len(matches) > config.Limit {
+		matches = matches[len(matches)-config.Limit:]
 	}
 
 	snapshots.List = make([]*dbschema.Snapshot, len(matches))
+	extras := make([]*schemaextra.Schema, len(matches))
 
 	var sem sync2.Semaphore
-	if strings.Contains(connstr, "cockroach") {
-		sem.Init(4)
-	} else {
-		sem.Init(16)
-	}
+	sem.Init(config.Concurrency)
 
 	var group errgroup.Group
 	for i, match := range matches {
@@ -72,7 +123,7 @@ func loadSnapshots(ctx context.Context, connstr, dbxscript string) (*dbschema.Sn
 
 			version := parseTestdataVersion(match)
 			if version < 0 {
-				return errs.New("invalid testdata file %q: %v", match, err)
+				return errs.New("invalid testdata file %q", match)
 			}
 
 			scriptData, err := ioutil.ReadFile(match)
@@ -80,7 +131,7 @@ func loadSnapshots(ctx context.Context, connstr, dbxscript string) (*dbschema.Sn
 				return errs.New("could not read testdata file for version %d: %v", version, err)
 			}
 
-			snapshot, err := loadSnapshotFromSQL(ctx, connstr, string(scriptData))
+			snapshot, extra, err := loadSnapshotFromSQL(ctx, connstr, string(scriptData))
 			if err != nil {
 				var pgErr *pgconn.PgError
 				if errors.As(err, &pgErr) {
@@ -91,27 +142,78 @@ func loadSnapshots(ctx context.Context, connstr, dbxscript string) (*dbschema.Sn
 			snapshot.Version = version
 
 			snapshots.List[i] = snapshot
+			extras[i] = extra
 			return nil
 		})
 	}
 	var dbschema *dbschema.Schema
-	group.Go(func() error {
-		var err error
-		dbschema, err = loadSchemaFromSQL(ctx, connstr, dbxscript)
-		return err
-	})
+	if dbxscript != "" {
+		group.Go(func() error {
+			var err error
+			dbschema, err = loadSchemaFromSQL(ctx, connstr, dbxscript)
+			return err
+		})
+	}
 	if err := group.Wait(); err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+
+	extrasByVersion := make(map[int]*schemaextra.Schema, len(extras))
+	for i, snapshot := range snapshots.List {
+		extrasByVersion[snapshot.Version] = extras[i]
 	}
 
 	snapshots.Sort()
 
-	return snapshots, dbschema, nil
+	return snapshots, extrasByVersion, dbschema, nil
+}
+
+func TestSnapshotLoadingConfig(t *testing.T) {
+	const postgres = "postgres://localhost/test"
+	const cockroach = "cockroach://localhost/test"
+
+	for _, tt := range []struct {
+		name     string
+		connstr  string
+		env      map[string]string
+		expected snapshotLoading
+		invalid  bool
+	}{
+		{name: "postgres defaults", connstr: postgres, expected: snapshotLoading{Limit: 0, Concurrency: 16}},
+		{name: "cockroach defaults", connstr: cockroach, expected: snapshotLoading{Limit: 10, Concurrency: 4}},
+		{
+			name:     "cockroach full history",
+			connstr:  cockroach,
+			env:      map[string]string{snapshotLimitEnv: "0"},
+			expected: snapshotLoading{Limit: 0, Concurrency: 4},
+		},
+		{
+			name:     "overrides",
+			connstr:  postgres,
+			env:      map[string]string{snapshotLimitEnv: "5", snapshotConcurrencyEnv: "32"},
+			expected: snapshotLoading{Limit: 5, Concurrency: 32},
+		},
+		{name: "negative limit", connstr: cockroach, env: map[string]string{snapshotLimitEnv: "-1"}, invalid: true},
+		{name: "invalid limit", connstr: cockroach, env: map[string]string{snapshotLimitEnv: "all"}, invalid: true},
+		{name: "zero concurrency", connstr: postgres, env: map[string]string{snapshotConcurrencyEnv: "0"}, invalid: true},
+		{name: "invalid concurrency", connstr: postgres, env: map[string]string{snapshotConcurrencyEnv: "many"}, invalid: true},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := snapshotLoadingConfig(tt.connstr, func(key string) string { return tt.env[key] })
+			if tt.invalid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, config)
+		})
+	}
 }
 
 func parseTestdataVersion(path string) int {
-	path = filepath.ToSlash(strings.ToLower(path))
-	path = strings.TrimPrefix(path, "testdata/postgres.v")
+	path = strings.ToLower(filepath.Base(path))
+	path = strings.TrimPrefix(path, "postgres.v")
 	path = strings.TrimSuffix(path, ".sql")
 
 	v, err := strconv.Atoi(path)
@@ -121,11 +223,12 @@ func parseTestdataVersion(path string) int {
 	return v
 }
 
-// loadSnapshotFromSQL inserts script into connstr and loads schema.
-func loadSnapshotFromSQL(ctx context.Context, connstr, script string) (_ *dbschema.Snapshot, err error) {
+// loadSnapshotFromSQL inserts script into connstr and loads schema, with the
+// sequences and check constraints.
+func loadSnapshotFromSQL(ctx context.Context, connstr, script string) (_ *dbschema.Snapshot, _ *schemaextra.Schema, err error) {
 	db, err := tempdb.OpenUnique(ctx, connstr, "load-schema")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer func() { err = errs.Combine(err, db.Close()) }()
 
@@ -133,27 +236,32 @@ func loadSnapshotFromSQL(ctx context.Context, connstr, script string) (_ *dbsche
 
 	_, err = db.ExecContext(ctx, sections.LookupSection(dbschema.Main))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	_, err = db.ExecContext(ctx, sections.LookupSection(dbschema.MainData))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	_, err = db.ExecContext(ctx, sections.LookupSection(dbschema.NewData))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	snapshot, err := pgutil.QuerySnapshot(ctx, db)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	snapshot.Sections = sections
 
-	return snapshot, nil
+	extra, err := schemaextra.Query(ctx, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return snapshot, extra, nil
 }
 
 // loadSchemaFromSQL inserts script into connstr and loads schema.
@@ -177,6 +285,16 @@ func TestMigratePostgres(t *testing.T) {
 	connstr := pgtest.PickPostgres(t)
 	t.Run("Versions", func(t *testing.T) { migrateTest(t, connstr) })
 	t.Run("Generated", func(t *testing.T) { migrateGeneratedTest(t, connstr, connstr) })
+	t.Run("Down", func(t *testing.T) { migrateDownTest(t, connstr) })
+	t.Run("Status", func(t *testing.T) { migrateStatusTest(t, connstr) })
+	t.Run("Target", func(t *testing.T) { migrateTargetTest(t, connstr) })
+	t.Run("Baseline", func(t *testing.T) { migrateBaselineTest(t, connstr) })
+	t.Run("Components", func(t *testing.T) { migrateComponentsTest(t, connstr) })
+	t.Run("Complete", func(t *testing.T) { migrateCompleteTest(t, connstr) })
+	t.Run("ReadOnly", func(t *testing.T) { migrateReadOnlyTest(t, connstr) })
+	t.Run("VerifySchema", func(t *testing.T) { migrateVerifySchemaTest(t, connstr) })
+	t.Run("Timeout", func(t *testing.T) { migrateTimeoutTest(t, connstr) })
+	t.Run("Dialect", func(t *testing.T) { migrateDialectTest(t, connstr) })
 }
 
 func TestMigrateCockroach(t *testing.T) {
@@ -184,8 +302,128 @@ func TestMigrateCockroach(t *testing.T) {
 	connstr := pgtest.PickCockroachAlt(t)
 	t.Run("Versions", func(t *testing.T) { migrateTest(t, connstr) })
 	t.Run("Generated", func(t *testing.T) { migrateGeneratedTest(t, connstr, connstr) })
+	t.Run("Down", func(t *testing.T) { migrateDownTest(t, connstr) })
+	t.Run("Status", func(t *testing.T) { migrateStatusTest(t, connstr) })
+	t.Run("Target", func(t *testing.T) { migrateTargetTest(t, connstr) })
+	t.Run("Baseline", func(t *testing.T) { migrateBaselineTest(t, connstr) })
+	t.Run("Complete", func(t *testing.T) { migrateCompleteTest(t, connstr) })
+	t.Run("ReadOnly", func(t *testing.T) { migrateReadOnlyTest(t, connstr) })
+	t.Run("VerifySchema", func(t *testing.T) { migrateVerifySchemaTest(t, connstr) })
+	t.Run("Timeout", func(t *testing.T) { migrateTimeoutTest(t, connstr) })
+	t.Run("Dialect", func(t *testing.T) { migrateDialectTest(t, connstr) })
+}
+
+// validateSnapshotVersions checks that the snapshot versions are strictly
+// increasing, that every snapshot has a migration step and that every step
+// from the first snapshot on has a snapshot. Orphan snapshots are usually
+// left behind by a botched rebase that renumbered the steps.
+func validateSnapshotVersions(snapshotVersions, stepVersions []int) error {
+	var problems []string
+
+	for i := 1; i < len(snapshotVersions); i++ {
+		if snapshotVersions[i] <= snapshotVersions[i-1] {
+			problems = append(problems, fmt.Sprintf("snapshot v%d follows v%d", snapshotVersions[i], snapshotVersions[i-1]))
+		}
+	}
+
+	if len(snapshotVersions) == 0 {
+		problems = append(problems, "no snapshots")
+		return errs.New("invalid snapshots: %s", strings.Join(problems, "; "))
+	}
+
+	hasStep := map[int]bool{}
+	for _, version := range stepVersions {
+		hasStep[version] = true
+	}
+	hasSnapshot := map[int]bool{}
+	first := snapshotVersions[0]
+	var orphans []string
+	for _, version := range snapshotVersions {
+		if version < first {
+			first = version
+		}
+		if !hasStep[version] && !hasSnapshot[version] {
+			orphans = append(orphans, fmt.Sprintf("v%d", version))
+		}
+		hasSnapshot[version] = true
+	}
+
+	var missing []string
+	for _, version := range stepVersions {
+		if version >= first && !hasSnapshot[version] {
+			missing = append(missing, fmt.Sprintf("v%d", version))
+		}
+	}
+
+	if len(orphans) > 0 {
+		problems = append(problems, "snapshots without a migration step: "+strings.Join(orphans, ", "))
+	}
+	if len(missing) > 0 {
+		problems = append(problems, "migration steps without a snapshot: "+strings.Join(missing, ", "))
+	}
+	if len(problems) > 0 {
+		return errs.New("invalid snapshots: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func TestValidateSnapshotVersions(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		snapshots []int
+		steps     []int
+		expected  string
+	}{
+		{name: "matching", snapshots: []int{3, 4, 5}, steps: []int{1, 2, 3, 4, 5}},
+		{name: "no snapshots", steps: []int{1, 2}, expected: "no snapshots"},
+		{
+			name:      "orphan",
+			snapshots: []int{3, 4, 5, 6},
+			steps:     []int{1, 2, 3, 4, 5},
+			expected:  "snapshots without a migration step: v6",
+		},
+		{
+			name:      "missing",
+			snapshots: []int{3, 5},
+			steps:     []int{1, 2, 3, 4, 5},
+			expected:  "migration steps without a snapshot: v4",
+		},
+		{
+			name:      "renumbered",
+			snapshots: []int{3, 4, 6},
+			steps:     []int{1, 2, 3, 4, 5},
+			expected:  "snapshots without a migration step: v6; migration steps without a snapshot: v5",
+		},
+		{
+			name:      "duplicate",
+			snapshots: []int{3, 4, 4, 5},
+			steps:     []int{1, 2, 3, 4, 5},
+			expected:  "snapshot v4 follows v4",
+		},
+		{
+			name:      "unordered",
+			snapshots: []int{3, 5, 4},
+			steps:     []int{1, 2, 3, 4, 5},
+			expected:  "snapshot v4 follows v5",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSnapshotVersions(tt.snapshots, tt.steps)
+			if tt.expected == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expected)
+		})
+	}
 }
 
+// satellitedbTestdata is the directory of the snapshots of the satellite
+// database.
+var satellitedbTestdata = filepath.Join("testdata", "satellitedb")
+
 type migrationTestingAccess interface {
 	// MigrationTestingDefaultDB assists in testing migrations themselves
 	// against the default database.
@@ -194,32 +432,149 @@ type migrationTestingAccess interface {
 		TestPostgresMigration() *migrate.Migration
 		PostgresMigration() *migrate.Migration
 	}
+	migrationComponentsAccess
+}
+
+// migrationComponentsAccess is implemented by the databases that have
+// migratable components.
+type migrationComponentsAccess interface {
+	// MigrationTestingComponents returns the migratable components of the
+	// database.
+	MigrationTestingComponents() []migrate.Component
 }
 
+// openMigrationDatabases opens the databases of the satellite in a new
+// temporary database. The databases are closed at the end of the test.
+func openMigrationDatabases(ctx *testcontext.Context, t *testing.T, log *zap.Logger, connStr string) []interface{} {
+	tempDB, err := tempdb.OpenUnique(ctx, connStr, "migrate")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tempDB.Close()) })
+
+	db, err := satellitedb.Open(ctx, log.Named("satellitedb"), tempDB.ConnStr, satellitedb.Options{ApplicationName: "satellite-migration-test"})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+
+	metabaseDB, err := metabase.Open(ctx, log.Named("metabase"), tempDB.ConnStr, metabase.Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, metabaseDB.Close()) })
+
+	return []interface{}{db, metabaseDB}
+}
+
+// openMigrationComponents opens the databases of the satellite in a new
+// temporary database and returns their migratable components. The databases
+// are closed at the end of the test.
+func openMigrationComponents(ctx *testcontext.Context, t *testing.T, log *zap.Logger, connStr string) []migrate.Component {
+	var components []migrate.Component
+	for _, db := range openMigrationDatabases(ctx, t, log, connStr) {
+		access, ok := db.(migrationComponentsAccess)
+		require.True(t, ok, "%T has no migration testing components", db)
+		components = append(components, access.MigrationTestingComponents()...)
+	}
+	return components
+}
+
+// migrateComponentsTest checks that every database that the satellite
+// migrates is opened by openMigrationComponents, so that a new database
+// can't be added without testing its migration.
+func migrateComponentsTest(t *testing.T, connStr string) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	databases := openMigrationDatabases(ctx, t, zaptest.NewLogger(t), connStr)
+
+	names := map[string]bool{}
+	for _, db := range databases {
+		access, ok := db.(migrationComponentsAccess)
+		require.True(t, ok, "%T has no migration testing components", db)
+		for _, component := range access.MigrationTestingComponents() {
+			require.False(t, names[component.Name], "component %q is returned twice", component.Name)
+			names[component.Name] = true
+		}
+	}
+
+	migratable := migratableFields(reflect.TypeOf(testplanet.Satellite{}), "Satellite")
+	require.NotEmpty(t, migratable)
+	for field, fieldType := range migratable {
+		opened := false
+		for _, db := range databases {
+			opened = opened || reflect.TypeOf(db).AssignableTo(fieldType)
+		}
+		require.True(t, opened, "the database of %s (%v) is not opened by openMigrationComponents", field, fieldType)
+	}
+}
+
+// migratableFields returns the fields of the struct, and of the structs it
+// contains, that hold a database with a migration.
+func migratableFields(structType reflect.Type, path string) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		fieldPath := path + "." + field.Name
+		if _, ok := field.Type.MethodByName("MigrateToLatest"); ok {
+			fields[fieldPath] = field.Type
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			for nested, nestedType := range migratableFields(field.Type, fieldPath) {
+				fields[nested] = nestedType
+			}
+		}
+	}
+	return fields
+}
+
+// migrateTest migrates every component of the satellite in its own database,
+// with the snapshots of the component.
 func migrateTest(t *testing.T, connStr string) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	components := openMigrationComponents(ctx, t, zaptest.NewLogger(t), connStr)
+	require.NotEmpty(t, components)
+
+	for _, component := range components {
+		name := component.Name
+		t.Run(name, func(t *testing.T) { migrateComponentTest(t, connStr, name) })
+	}
+}
+
+// migrateComponentTest migrates the component step by step and compares the
+// database with the snapshots of the component after every step.
+func migrateComponentTest(t *testing.T, connStr string, name string) {
 	ctx := testcontext.NewWithTimeout(t, 8*time.Minute)
 	defer ctx.Cleanup()
 
 	log := zaptest.NewLogger(t)
 
-	// create tempDB
-	tempDB, err := tempdb.OpenUnique(ctx, connStr, "migrate")
-	require.NoError(t, err)
-	defer func() { require.NoError(t, tempDB.Close()) }()
-
-	// create a new satellitedb connection
-	db, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{ApplicationName: "satellite-migration-test"})
-	require.NoError(t, err)
-	defer func() { require.NoError(t, db.Close()) }()
+	var component migrate.Component
+	for _, c := range openMigrationComponents(ctx, t, log, connStr) {
+		if c.Name == name {
+			component = c
+		}
+	}
+	require.Equal(t, name, component.Name, "component %q not found", name)
 
 	// we need raw database access unfortunately
-	rawdb := db.(migrationTestingAccess).MigrationTestingDefaultDB().TestDBAccess()
+	rawdb := component.DB
 
-	snapshots, dbxschema, err := loadSnapshots(ctx, connStr, rawdb.Schema())
+	testdata := filepath.Join("testdata", component.Name)
+	snapshots, extras, dbxschema, err := loadSnapshots(ctx, t, connStr, testdata, component.Schema)
 	require.NoError(t, err)
+	require.NotEmpty(t, snapshots.List, "Missing snapshots of component %q. Add the snapshot of its latest migration version to %s/postgres.vN.sql.", component.Name, testdata)
 
 	// get migration for this database
-	migrations := db.(migrationTestingAccess).MigrationTestingDefaultDB().PostgresMigration()
+	migrations := component.Migration
+
+	// the snapshots must match the steps, before anything is migrated
+	var snapshotVersions, stepVersions []int
+	for _, snapshot := range snapshots.List {
+		snapshotVersions = append(snapshotVersions, snapshot.Version)
+	}
+	for _, step := range migrations.Steps {
+		stepVersions = append(stepVersions, step.Version)
+	}
+	require.NoError(t, validateSnapshotVersions(snapshotVersions, stepVersions))
 
 	// find the first matching migration step for the snapshots
 	firstSnapshot := snapshots.List[0]
@@ -240,14 +595,30 @@ func migrateTest(t *testing.T, connStr string) {
 	_, err = rawdb.ExecContext(ctx, firstSnapshot.LookupSection(dbschema.NewData))
 	require.NoError(t, err)
 
+	// querySchema loads the schema without the tables of the migration
+	querySchema := func(tag string) *dbschema.Schema {
+		schema, err := pgutil.QuerySchema(ctx, rawdb)
+		require.NoError(t, err, tag)
+
+		// we don't care changes in versions tables
+		for _, table := range component.Tables() {
+			schema.DropTable(table)
+		}
+		return schema
+	}
+
 	// test rest of the steps with snapshots
-	var finalSchema *dbschema.Schema
+	finalSchema := querySchema("initial")
 	for i, step := range migrations.Steps[stepIndex+1:] {
-		tag := fmt.Sprintf("#%d - v%d", i, step.Version)
+		tag := fmt.Sprintf("%s #%d - v%d", component.Name, i, step.Version)
 
 		// find the matching expected version
 		expected, ok := snapshots.FindVersion(step.Version)
-		require.True(t, ok, "Missing snapshot v%d. Did you forget to add a snapshot for the new migration?", step.Version)
+		hint := fmt.Sprintf("Did you forget to add %s/postgres.v%d.sql for the new migration?", testdata, step.Version)
+		if component.Name == "satellitedb" {
+			hint += fmt.Sprintf(" It can be generated with: go run ./satellite/satellitedb/gen-snapshot -testdata satellite/satellitedb/testdata/satellitedb -version %d", step.Version)
+		}
+		require.True(t, ok, "Missing snapshot v%d. %s", step.Version, hint)
 
 		// run any queries that should happen before the migration
 		if oldData := expected.LookupSection(dbschema.OldData); oldData != "" {
@@ -266,11 +637,7 @@ func migrateTest(t *testing.T, connStr string) {
 		}
 
 		// load schema from database
-		currentSchema, err := pgutil.QuerySchema(ctx, rawdb)
-		require.NoError(t, err, tag)
-
-		// we don't care changes in versions table
-		currentSchema.DropTable("versions")
+		currentSchema := querySchema(tag)
 
 		// load data from database
 		currentData, err := pgutil.QueryData(ctx, rawdb, currentSchema)
@@ -280,10 +647,19 @@ func migrateTest(t *testing.T, connStr string) {
 		require.Equal(t, expected.Schema, currentSchema, tag)
 		require.Equal(t, expected.Data, currentData, tag)
 
+		// verify the sequences and check constraints
+		currentExtra, err := schemaextra.Query(ctx, rawdb)
+		require.NoError(t, err, tag)
+		require.Equal(t, extras[step.Version], currentExtra, tag)
+
 		// keep the last version around
 		finalSchema = currentSchema
 	}
 
+	if dbxschema == nil {
+		return
+	}
+
 	// TODO(cam): remove this check with the migration step to drop the columns
 	nodes, ok := finalSchema.FindTable("nodes")
 	if ok {
@@ -298,6 +674,445 @@ func migrateTest(t *testing.T, connStr string) {
 	require.Equal(t, dbxschema, finalSchema, "result of all migration scripts did not match dbx schema")
 }
 
+// migrateDownTest verifies that undoing the reversible migration steps results
+// in the schemas of the snapshots, and that they can be applied again.
+func migrateDownTest(t *testing.T, connStr string) {
+	ctx := testcontext.NewWithTimeout(t, 8*time.Minute)
+	defer ctx.Cleanup()
+
+	log := zaptest.NewLogger(t)
+
+	tempDB, err := tempdb.OpenUnique(ctx, connStr, "migrate")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tempDB.Close()) }()
+
+	db, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{ApplicationName: "satellite-migration-test"})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	rawdb := db.(migrationTestingAccess).MigrationTestingDefaultDB().TestDBAccess()
+
+	snapshots, _, _, err := loadSnapshots(ctx, t, connStr, satellitedbTestdata, rawdb.Schema())
+	require.NoError(t, err)
+
+	migrations := db.(migrationTestingAccess).MigrationTestingDefaultDB().PostgresMigration()
+	require.NoError(t, migrations.Run(ctx, log.Named("migrate")))
+
+	requireSchema := func(version int) {
+		tag := fmt.Sprintf("v%d", version)
+
+		expected, ok := snapshots.FindVersion(version)
+		require.True(t, ok, "Missing snapshot %s", tag)
+
+		currentSchema, err := pgutil.QuerySchema(ctx, rawdb)
+		require.NoError(t, err, tag)
+		currentSchema.DropTable("versions")
+		currentSchema.DropTable("versions_history")
+		currentSchema.DropTable("versions_lock")
+
+		require.Equal(t, expected.Schema, currentSchema, tag)
+	}
+
+	// undo the reversible steps one by one, down to the irreversible one.
+	var irreversible int
+	for i := len(migrations.Steps) - 1; i > 0; i-- {
+		step := migrations.Steps[i]
+		if step.Irreversible || step.Down == nil {
+			irreversible = step.Version
+			break
+		}
+
+		previous := migrations.Steps[i-1].Version
+		require.NoError(t, migrations.RunDown(ctx, log.Named("down"), previous))
+		requireSchema(previous)
+	}
+	require.NotZero(t, irreversible)
+
+	err = migrations.RunDown(ctx, log.Named("down"), irreversible-1)
+	require.True(t, migrate.ErrIrreversible.Has(err), err)
+	requireSchema(irreversible)
+
+	// and apply them again.
+	require.NoError(t, migrations.Run(ctx, log.Named("migrate")))
+	requireSchema(migrations.Steps[len(migrations.Steps)-1].Version)
+}
+
+// migrateBaselineTest verifies that a database created from a baseline of the
+// first loaded snapshot and the steps after it matches a database migrated with
+// all the steps, so that the steps up to a snapshot can be squashed into it.
+func migrateBaselineTest(t *testing.T, connStr string) {
+	ctx := testcontext.NewWithTimeout(t, 8*time.Minute)
+	defer ctx.Cleanup()
+
+	log := zaptest.NewLogger(t)
+
+	open := func(prefix string) (satellite.DB, *dbx.DB) {
+		tempDB, err := tempdb.OpenUnique(ctx, connStr, prefix)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, tempDB.Close()) })
+
+		db, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{ApplicationName: "satellite-migration-test"})
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, db.Close()) })
+
+		return db, db.(migrationTestingAccess).MigrationTestingDefaultDB().TestDBAccess()
+	}
+
+	fullDB, fullRaw := open("migrate-full")
+	squashedDB, squashedRaw := open("migrate-squashed")
+
+	snapshots, _, _, err := loadSnapshots(ctx, t, connStr, satellitedbTestdata, fullRaw.Schema())
+	require.NoError(t, err)
+	baseline := snapshots.List[0]
+
+	full := fullDB.(migrationTestingAccess).MigrationTestingDefaultDB().PostgresMigration()
+	require.NoError(t, full.Run(ctx, log.Named("full")))
+
+	chain := squashedDB.(migrationTestingAccess).MigrationTestingDefaultDB().PostgresMigration()
+	squashed := *chain
+	squashed.Baseline = &migrate.Step{
+		DB:          chain.Steps[0].DB,
+		Description: fmt.Sprintf("Baseline of v%d", baseline.Version),
+		Version:     baseline.Version,
+		Action:      migrate.SQL{baseline.LookupSection(dbschema.Main)},
+	}
+	squashed.Steps = nil
+	for _, step := range chain.Steps {
+		if step.Version > baseline.Version {
+			squashed.Steps = append(squashed.Steps, step)
+		}
+	}
+	require.NoError(t, squashed.Run(ctx, log.Named("squashed")))
+
+	snapshot := func(rawdb *dbx.DB) (*dbschema.Schema, *dbschema.Data) {
+		schema, err := pgutil.QuerySchema(ctx, rawdb)
+		require.NoError(t, err)
+		schema.DropTable("versions")
+		schema.DropTable("versions_history")
+		schema.DropTable("versions_lock")
+
+		data, err := pgutil.QueryData(ctx, rawdb, schema)
+		require.NoError(t, err)
+		return schema, data
+	}
+
+	fullSchema, fullData := snapshot(fullRaw)
+	squashedSchema, squashedData := snapshot(squashedRaw)
+	require.Equal(t, fullSchema, squashedSchema)
+	require.Equal(t, fullData, squashedData)
+}
+
+// migrateTargetTest verifies that migrating to a target version stops at the
+// schema of its snapshot, and that invalid targets are refused.
+func migrateCompleteTest(t *testing.T, connStr string) {
+	ctx := testcontext.NewWithTimeout(t, 8*time.Minute)
+	defer ctx.Cleanup()
+
+	log := zaptest.NewLogger(t)
+
+	tempDB, err := tempdb.OpenUnique(ctx, connStr, "migrate")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tempDB.Close()) }()
+
+	db, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{ApplicationName: "satellite-migration-test"})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	migrations := db.(migrationTestingAccess).MigrationTestingDefaultDB().PostgresMigration()
+	latest := migrations.Steps[len(migrations.Steps)-1].Version
+
+	var versions []int
+	db.OnMigrationComplete(func(version int) {
+		versions = append(versions, version)
+	})
+
+	require.NoError(t, db.MigrateToLatest(ctx))
+	require.Equal(t, []int{latest}, versions)
+
+	// the callback only fires once.
+	require.NoError(t, db.CheckVersion(ctx))
+	require.Equal(t, []int{latest}, versions)
+
+	// callbacks registered afterwards fire right away.
+	db.OnMigrationComplete(func(version int) {
+		versions = append(versions, version)
+	})
+	require.Equal(t, []int{latest, latest}, versions)
+}
+
+func migrateReadOnlyTest(t *testing.T, connStr string) {
+	ctx := testcontext.NewWithTimeout(t, 8*time.Minute)
+	defer ctx.Cleanup()
+
+	log := zaptest.NewLogger(t)
+
+	tempDB, err := tempdb.OpenUnique(ctx, connStr, "migrate")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tempDB.Close()) }()
+
+	readOnly, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{
+		ApplicationName: "satellite-migration-test",
+		ReadOnly:        true,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, readOnly.Close()) }()
+
+	err = readOnly.MigrateToLatest(ctx)
+	require.True(t, satellitedb.ErrReadOnly.Has(err), err)
+	err = readOnly.TestingMigrateToLatest(ctx)
+	require.True(t, satellitedb.ErrReadOnly.Has(err), err)
+
+	db, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{ApplicationName: "satellite-migration-test"})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	require.NoError(t, db.MigrateToLatest(ctx))
+
+	// read-only consumers can verify the version and read.
+	require.NoError(t, readOnly.CheckVersion(ctx))
+
+	projects, err := readOnly.Console().Projects().GetAll(ctx)
+	require.NoError(t, err)
+	require.Empty(t, projects)
+
+	// the connections refuse to change the schema or the data.
+	rawdb := readOnly.(migrationTestingAccess).MigrationTestingDefaultDB().TestDBAccess()
+	_, err = rawdb.ExecContext(ctx, `CREATE TABLE read_only_test (id int)`)
+	require.Error(t, err)
+}
+
+func migrateTimeoutTest(t *testing.T, connStr string) {
+	ctx := testcontext.NewWithTimeout(t, 8*time.Minute)
+	defer ctx.Cleanup()
+
+	log := zaptest.NewLogger(t)
+
+	tempDB, err := tempdb.OpenUnique(ctx, connStr, "migrate")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tempDB.Close()) }()
+
+	const lockTimeout = 500 * time.Millisecond
+	db, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{
+		ApplicationName:           "satellite-migration-test",
+		MigrationStatementTimeout: time.Minute,
+		MigrationLockTimeout:      lockTimeout,
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	migrations := db.(migrationTestingAccess).MigrationTestingDefaultDB().PostgresMigration()
+	last := migrations.Steps[len(migrations.Steps)-1]
+	require.NoError(t, db.MigrateToVersion(ctx, migrations.Steps[len(migrations.Steps)-2].Version))
+
+	// another connection holds a lock that conflicts with reading the versions.
+	tx, err := tempDB.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	if tempDB.Implementation == dbutil.Cockroach {
+		_, err = tx.ExecContext(ctx, `UPDATE versions SET commited_at = commited_at`) //nolint:misspell
+	} else {
+		_, err = tx.ExecContext(ctx, `LOCK TABLE versions IN ACCESS EXCLUSIVE MODE`)
+	}
+	require.NoError(t, err)
+
+	started := time.Now()
+	err = db.MigrateToLatest(ctx)
+	require.Error(t, err)
+	require.Less(t, time.Since(started), time.Minute)
+	require.Contains(t, err.Error(), fmt.Sprintf("step %d (%s) exceeded the lock timeout of %v", last.Version, last.Description, lockTimeout))
+
+	// the migration continues once the lock is released.
+	require.NoError(t, tx.Rollback())
+	require.NoError(t, db.MigrateToLatest(ctx))
+}
+
+func migrateVerifySchemaTest(t *testing.T, connStr string) {
+	ctx := testcontext.NewWithTimeout(t, 8*time.Minute)
+	defer ctx.Cleanup()
+
+	log := zaptest.NewLogger(t)
+
+	tempDB, err := tempdb.OpenUnique(ctx, connStr, "migrate")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tempDB.Close()) }()
+
+	db, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{ApplicationName: "satellite-migration-test"})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	require.NoError(t, db.MigrateToLatest(ctx))
+	require.NoError(t, db.VerifySchema(ctx, connStr))
+
+	rawdb := db.(migrationTestingAccess).MigrationTestingDefaultDB().TestDBAccess()
+	_, err = rawdb.ExecContext(ctx, `ALTER TABLE nodes ADD COLUMN drift integer`)
+	require.NoError(t, err)
+	_, err = rawdb.ExecContext(ctx, `DROP INDEX storagenode_paystubs_node_id_index`)
+	require.NoError(t, err)
+
+	err = db.VerifySchema(ctx, connStr)
+	require.True(t, satellitedb.ErrSchemaDrift.Has(err), err)
+	require.Contains(t, err.Error(), "extra column nodes.drift")
+	require.Contains(t, err.Error(), "missing index storagenode_paystubs_node_id_index on storagenode_paystubs")
+}
+
+func migrateTargetTest(t *testing.T, connStr string) {
+	ctx := testcontext.NewWithTimeout(t, 8*time.Minute)
+	defer ctx.Cleanup()
+
+	log := zaptest.NewLogger(t)
+
+	tempDB, err := tempdb.OpenUnique(ctx, connStr, "migrate")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tempDB.Close()) }()
+
+	db, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{ApplicationName: "satellite-migration-test"})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	rawdb := db.(migrationTestingAccess).MigrationTestingDefaultDB().TestDBAccess()
+
+	snapshots, _, _, err := loadSnapshots(ctx, t, connStr, satellitedbTestdata, rawdb.Schema())
+	require.NoError(t, err)
+
+	migrations := db.(migrationTestingAccess).MigrationTestingDefaultDB().PostgresMigration()
+	latest := migrations.Steps[len(migrations.Steps)-1].Version
+	target := migrations.Steps[len(migrations.Steps)-3].Version
+
+	// versions without a step are refused before anything is migrated.
+	err = db.MigrateToVersion(ctx, latest+1)
+	require.True(t, satellitedb.ErrMigrate.Has(err), err)
+
+	require.NoError(t, db.MigrateToVersion(ctx, target))
+
+	version, err := migrations.CurrentVersion(ctx, log, rawdb)
+	require.NoError(t, err)
+	require.Equal(t, target, version)
+
+	expected, ok := snapshots.FindVersion(target)
+	require.True(t, ok, "Missing snapshot v%d", target)
+
+	currentSchema, err := pgutil.QuerySchema(ctx, rawdb)
+	require.NoError(t, err)
+	currentSchema.DropTable("versions")
+	currentSchema.DropTable("versions_history")
+	currentSchema.DropTable("versions_lock")
+	require.Equal(t, expected.Schema, currentSchema)
+
+	// migrating to the current version again doesn't change anything.
+	require.NoError(t, db.MigrateToVersion(ctx, target))
+
+	// versions below the current one are refused.
+	err = db.MigrateToVersion(ctx, migrations.Steps[len(migrations.Steps)-4].Version)
+	require.True(t, satellitedb.ErrMigrate.Has(err), err)
+
+	version, err = migrations.CurrentVersion(ctx, log, rawdb)
+	require.NoError(t, err)
+	require.Equal(t, target, version)
+
+	require.NoError(t, db.MigrateToLatest(ctx))
+	version, err = migrations.CurrentVersion(ctx, log, rawdb)
+	require.NoError(t, err)
+	require.Equal(t, latest, version)
+}
+
+// migrateStatusTest verifies that the status of a partially migrated database
+// lists the pending steps, without changing the database.
+func migrateStatusTest(t *testing.T, connStr string) {
+	ctx := testcontext.NewWithTimeout(t, 8*time.Minute)
+	defer ctx.Cleanup()
+
+	log := zaptest.NewLogger(t)
+
+	tempDB, err := tempdb.OpenUnique(ctx, connStr, "migrate")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tempDB.Close()) }()
+
+	db, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{ApplicationName: "satellite-migration-test"})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	migrations := db.(migrationTestingAccess).MigrationTestingDefaultDB().PostgresMigration()
+	latest := migrations.Steps[len(migrations.Steps)-1].Version
+
+	// a database that isn't migrated at all.
+	statuses, err := db.MigrationStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, -1, statuses[""].Current)
+	require.Equal(t, latest, statuses[""].Latest)
+	require.Len(t, statuses[""].Pending, len(migrations.Steps))
+
+	rawdb := db.(migrationTestingAccess).MigrationTestingDefaultDB().TestDBAccess()
+	schema, err := pgutil.QuerySchema(ctx, rawdb)
+	require.NoError(t, err)
+	_, ok := schema.FindTable("versions")
+	require.False(t, ok, "status must not create the versions table")
+
+	// a partially migrated database.
+	current := migrations.Steps[len(migrations.Steps)-3].Version
+	require.NoError(t, migrations.TargetVersion(current).Run(ctx, log.Named("migrate")))
+
+	statuses, err = db.MigrationStatus(ctx)
+	require.NoError(t, err)
+	status := statuses[""]
+	require.Equal(t, current, status.Current)
+	require.Equal(t, latest, status.Latest)
+	require.False(t, status.UpToDate())
+	require.Len(t, status.Pending, 2)
+	for i, step := range migrations.Steps[len(migrations.Steps)-2:] {
+		require.Equal(t, step.Version, status.Pending[i].Version)
+		require.Equal(t, step.Description, status.Pending[i].Description)
+	}
+
+	// an up to date database.
+	require.NoError(t, migrations.Run(ctx, log.Named("migrate")))
+	statuses, err = db.MigrationStatus(ctx)
+	require.NoError(t, err)
+	require.Equal(t, latest, statuses[""].Current)
+	require.True(t, statuses[""].UpToDate())
+}
+
+// migrateDialectTest verifies that a step with SQLByDialect runs the statements
+// of the dialect of the satellite database.
+func migrateDialectTest(t *testing.T, connStr string) {
+	ctx := testcontext.NewWithTimeout(t, 8*time.Minute)
+	defer ctx.Cleanup()
+
+	log := zaptest.NewLogger(t)
+
+	tempDB, err := tempdb.OpenUnique(ctx, connStr, "migrate")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tempDB.Close()) }()
+
+	db, err := satellitedb.Open(ctx, log, tempDB.ConnStr, satellitedb.Options{ApplicationName: "satellite-migration-test"})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	access := db.(migrationTestingAccess).MigrationTestingDefaultDB()
+	migrations := access.TestPostgresMigration()
+	last := migrations.Steps[len(migrations.Steps)-1]
+	migrations.Steps = append(migrations.Steps, &migrate.Step{
+		DB:          last.DB,
+		Description: "add dialect to nodes",
+		Version:     last.Version + 1,
+		Action: migrate.NewSQLByDialect(
+			migrate.SQL{`ALTER TABLE nodes ADD COLUMN dialect text NOT NULL DEFAULT 'postgres'`},
+			migrate.SQL{`ALTER TABLE nodes ADD COLUMN dialect text NOT NULL DEFAULT 'cockroach'`},
+		),
+	})
+	require.NoError(t, migrations.Run(ctx, log.Named("migrate")))
+
+	expected := "postgres"
+	if tempDB.Implementation == dbutil.Cockroach {
+		expected = "cockroach"
+	}
+
+	var defaultValue string
+	require.NoError(t, access.TestDBAccess().QueryRowContext(ctx, `
+		SELECT column_default FROM information_schema.columns
+		WHERE table_name = 'nodes' AND column_name = 'dialect'
+	`).Scan(&defaultValue))
+	require.Contains(t, defaultValue, expected)
+}
+
 // migrateGeneratedTest verifies whether the generated code in `migratez.go` is on par with migrate.go.
 func migrateGeneratedTest(t *testing.T, connStrProd, connStrTest string) {
 	ctx := testcontext.NewWithTimeout(t, 8*time.Minute)
@@ -314,7 +1129,11 @@ func migrateGeneratedTest(t *testing.T, connStrProd, connStrTest string) {
 	assert.Equal(t, prodVersion, testVersion, "migratez version does not match migration. Run `go generate` to update.")
 
 	prodSnapshot.DropTable("versions")
+	prodSnapshot.DropTable("versions_history")
+	prodSnapshot.DropTable("versions_lock")
 	testSnapshot.DropTable("versions")
+	testSnapshot.DropTable("versions_history")
+	testSnapshot.DropTable("versions_lock")
 
 	require.Equal(t, prodSnapshot.Schema, testSnapshot.Schema, "migratez schema does not match migration. Run `go generate` to update.")
 	require.Equal(t, prodSnapshot.Data, testSnapshot.Data, "migratez data does not match migration. Run `go generate` to update.")
@@ -355,6 +1174,9 @@ func BenchmarkSetup_Postgres(b *testing.B) {
 	b.Run("separate", func(b *testing.B) {
 		benchmarkSetup(b, connstr, false)
 	})
+	b.Run("template", func(b *testing.B) {
+		benchmarkTemplateSetup(b, connstr)
+	})
 }
 
 func BenchmarkSetup_Cockroach(b *testing.B) {
@@ -367,6 +1189,25 @@ func BenchmarkSetup_Cockroach(b *testing.B) {
 	})
 }
 
+// benchmarkTemplateSetup clones the databases from a migrated template, the
+// template is created before the timer starts.
+func benchmarkTemplateSetup(b *testing.B, connStr string) {
+	ctx := context.Background()
+	log := zap.NewNop()
+	dbInfo := satellitedbtest.Database{Name: "Postgres", URL: connStr}
+
+	db, err := satellitedbtest.CreateMigratedMasterDB(ctx, log, b.Name(), "B", 0, dbInfo)
+	require.NoError(b, err)
+	require.NoError(b, db.Close())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db, err := satellitedbtest.CreateMigratedMasterDB(ctx, log, b.Name(), "B", 0, dbInfo)
+		require.NoError(b, err)
+		require.NoError(b, db.Close())
+	}
+}
+
 func benchmarkSetup(b *testing.B, connStr string, merged bool) {
 	for i := 0; i < b.N; i++ {
 		func() {
@@ -0,0 +1,86 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package satellitedb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	pgxerrcode "github.com/jackc/pgerrcode"
+	"github.com/zeebo/errs"
+
+	"storj.io/private/dbutil/pgutil/pgerrcode"
+	"storj.io/private/tagsql"
+)
+
+// useMigrationTimeouts switches the migration to a separate connection with
+// the statement and lock timeouts of the options, so that a migration that
+// waits on a lock fails instead of stalling the deploy. The other queries of
+// the database are not affected. The returned func switches back and
+// explains the timeout errors of the migration.
+func (db *satelliteDB) useMigrationTimeouts(ctx context.Context) (finish func(err error) error, err error) {
+	if db.opts.MigrationStatementTimeout <= 0 && db.opts.MigrationLockTimeout <= 0 {
+		return func(err error) error { return err }, nil
+	}
+
+	source := db.source
+	if timeout := db.opts.MigrationStatementTimeout; timeout > 0 {
+		source = withSessionParameter(source, "statement_timeout", fmt.Sprintf("%dms", timeout.Milliseconds()))
+	}
+	if timeout := db.opts.MigrationLockTimeout; timeout > 0 {
+		source = withSessionParameter(source, "lock_timeout", fmt.Sprintf("%dms", timeout.Milliseconds()))
+	}
+
+	migrationDB, err := tagsql.Open(ctx, db.driver, source)
+	if err != nil {
+		return nil, Error.New("failed opening migration connection: %w", err)
+	}
+	db.migrationDB = migrationDB
+
+	return func(err error) error {
+		db.migrationDB = db
+		err = errs.Combine(db.migrationTimeoutError(ctx, err), Error.Wrap(migrationDB.Close()))
+		return err
+	}, nil
+}
+
+// withSessionParameter sets a session variable of the connections of the
+// source. Postgres and cockroach take it as a connection parameter.
+func withSessionParameter(source, name, value string) string {
+	if strings.Contains(source, name+"=") {
+		return source
+	}
+	if !strings.Contains(source, "?") {
+		return source + "?" + name + "=" + value
+	}
+	return source + "&" + name + "=" + value
+}
+
+// migrationTimeoutError names the step and the limit of a migration that ran
+// into the statement or lock timeout. The step that failed is the first one
+// that is pending.
+func (db *satelliteDB) migrationTimeoutError(ctx context.Context, err error) error {
+	var kind string
+	var limit time.Duration
+	switch pgerrcode.FromError(err) {
+	case pgxerrcode.QueryCanceled:
+		kind, limit = "statement timeout", db.opts.MigrationStatementTimeout
+	case pgxerrcode.LockNotAvailable:
+		kind, limit = "lock timeout", db.opts.MigrationLockTimeout
+	default:
+		return err
+	}
+	if limit <= 0 {
+		return err
+	}
+
+	status, statusErr := db.MigrationStatus(ctx)
+	if statusErr != nil || len(status.Pending) == 0 {
+		return ErrMigrate.New("migration exceeded the %s of %v: %w", kind, limit, err)
+	}
+	step := status.Pending[0]
+	return ErrMigrate.New("step %d (%s) exceeded the %s of %v: %w", step.Version, step.Description, kind, limit, err)
+}
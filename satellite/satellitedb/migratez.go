@@ -1,4 +1,4 @@
-// AUTOGENERATED BY migrategen.go
+// AUTOGENERATED BY migratez_test.go
 // DO NOT EDIT.
 
 package satellitedb
@@ -13,8 +13,9 @@ func (db *satelliteDB) testMigration() *migrate.Migration {
 			{
 				DB:          &db.migrationDB,
 				Description: "Testing setup",
-				Version:     184,
-				Action: migrate.SQL{`-- AUTOGENERATED BY storj.io/dbx
+				Version:     186,
+				Action: migrate.SQL{
+					`-- AUTOGENERATED BY storj.io/dbx
 -- DO NOT EDIT
 CREATE TABLE accounting_rollups (
 	node_id bytea NOT NULL,
@@ -111,6 +112,39 @@ CREATE TABLE coupon_usages (
 	period timestamp with time zone NOT NULL,
 	PRIMARY KEY ( coupon_id, period )
 );
+CREATE TABLE garbage_collection_filters (
+	creation_date timestamp with time zone NOT NULL,
+	node_id bytea NOT NULL,
+	range_bits integer NOT NULL,
+	sent_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( creation_date, node_id )
+);
+CREATE TABLE garbage_collection_reports (
+	creation_date timestamp with time zone NOT NULL,
+	node_id bytea NOT NULL,
+	range_bits integer NOT NULL,
+	range_index integer NOT NULL,
+	pieces_examined bigint NOT NULL,
+	pieces_trashed bigint NOT NULL,
+	bytes_reclaimed bigint NOT NULL,
+	duration_ms bigint NOT NULL,
+	reported_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( creation_date, node_id, range_bits, range_index )
+);
+CREATE TABLE garbage_collection_runs (
+	id bigserial NOT NULL,
+	started_at timestamp with time zone NOT NULL,
+	finished_at timestamp with time zone,
+	status integer NOT NULL,
+	segments bigint NOT NULL,
+	nodes_targeted integer NOT NULL,
+	nodes_sent integer NOT NULL,
+	filter_bytes bigint NOT NULL,
+	error text,
+	creation_date timestamp with time zone,
+	nodes_reporting integer NOT NULL DEFAULT 0,
+	PRIMARY KEY ( id )
+);
 CREATE TABLE graceful_exit_progress (
 	node_id bytea NOT NULL,
 	bytes_transferred bigint NOT NULL,
@@ -508,7 +542,8 @@ CREATE UNIQUE INDEX credits_earned_user_id_offer_id ON user_credits ( id, offer_
 INSERT INTO "offers" ("id", "name", "description", "award_credit_in_cents", "invitee_credit_in_cents", "expires_at", "created_at", "status", "type", "award_credit_duration_days", "invitee_credit_duration_days") VALUES (1, 'Default referral offer', 'Is active when no other active referral offer', 300, 600, '2119-03-14 08:28:24.636949+00', '2019-07-14 08:28:24.636949+00', 1, 2, 365, 14);
 INSERT INTO "offers" ("id", "name", "description", "award_credit_in_cents", "invitee_credit_in_cents", "expires_at", "created_at", "status", "type", "award_credit_duration_days", "invitee_credit_duration_days") VALUES (2, 'Default free credit offer', 'Is active when no active free credit offer', 0, 300, '2119-03-14 08:28:24.636949+00', '2019-07-14 08:28:24.636949+00', 1, 1, NULL, 14);
 
-`},
+`,
+				},
 			},
 		},
 	}
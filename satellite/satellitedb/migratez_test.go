@@ -0,0 +1,111 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package satellitedb
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
+
+	"storj.io/private/dbutil/dbschema"
+	"storj.io/storj/private/migrate"
+	"storj.io/storj/private/migrate/migrategen"
+)
+
+var generateMigratez = flag.Bool("generate-migratez", false, "regenerate migratez.go")
+
+// TestMigratez verifies that migratez.go is generated from the current
+// migration. `go generate` runs it with -generate-migratez to regenerate it.
+func TestMigratez(t *testing.T) {
+	source, err := generateTestMigration("testdata/satellitedb")
+	require.NoError(t, err)
+
+	if *generateMigratez {
+		require.NoError(t, ioutil.WriteFile("migratez.go", source, 0644))
+		return
+	}
+
+	current, err := ioutil.ReadFile("migratez.go")
+	require.NoError(t, err)
+	require.Equal(t, string(source), string(current), "migratez.go is outdated. Run `go generate` to update.")
+}
+
+// generateTestMigration returns the source of testMigration, the production
+// migration merged into a single step. The older steps run go code, which
+// can't be merged, so the schema of the latest snapshot stands in for the
+// steps up to it and the steps after it are merged on top.
+func generateTestMigration(testdata string) ([]byte, error) {
+	db := &satelliteDB{}
+	production := db.PostgresMigration()
+	latest := production.Steps[len(production.Steps)-1].Version
+
+	version, sections, err := latestSnapshot(testdata, latest)
+	if err != nil {
+		return nil, err
+	}
+
+	migration := &migrate.Migration{
+		Table: production.Table,
+		Baseline: &migrate.Step{
+			DB:          &db.migrationDB,
+			Description: "Snapshot",
+			Version:     version,
+			Action:      migrate.SQL{sections.LookupSection(dbschema.Main)},
+		},
+		Steps: production.Steps,
+	}
+
+	step, err := migration.Merge("Testing setup")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrategen.Generate(migrategen.Config{
+		Generator: "migratez_test.go",
+		Package:   "satellitedb",
+		Receiver:  "db *satelliteDB",
+		Func:      "testMigration",
+		DB:        "&db.migrationDB",
+		Table:     production.Table,
+	}, step)
+}
+
+// latestSnapshot loads the sections of the latest snapshot up to the version.
+func latestSnapshot(testdata string, version int) (int, dbschema.Sections, error) {
+	matches, err := filepath.Glob(filepath.Join(testdata, "postgres.v*.sql"))
+	if err != nil {
+		return 0, dbschema.Sections{}, errs.Wrap(err)
+	}
+
+	latest := -1
+	for _, match := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), "postgres.v"), ".sql")
+		v, err := strconv.Atoi(name)
+		if err != nil {
+			return 0, dbschema.Sections{}, errs.New("invalid testdata path %q", match)
+		}
+		if v > version {
+			return 0, dbschema.Sections{}, errs.New("snapshot %q is above the latest migration version %d", match, version)
+		}
+		if v > latest {
+			latest = v
+		}
+	}
+	if latest < 0 {
+		return 0, dbschema.Sections{}, errs.New("no snapshots in %s", testdata)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(testdata, fmt.Sprintf("postgres.v%d.sql", latest)))
+	if err != nil {
+		return 0, dbschema.Sections{}, errs.Wrap(err)
+	}
+	return latest, dbschema.NewSections(string(data)), nil
+}
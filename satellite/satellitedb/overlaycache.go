@@ -234,6 +234,65 @@ func (cache *overlaycache) Get(ctx context.Context, id storj.NodeID) (dossier *o
 	return convertDBNode(ctx, node)
 }
 
+// GetNodes looks up the known nodes among nodeIDs with a single query. The
+// operator details of the nodes are not included.
+func (cache *overlaycache) GetNodes(ctx context.Context, nodeIDs storj.NodeIDList) (nodes map[storj.NodeID]*overlay.NodeDossier, err error) {
+	for {
+		nodes, err = cache.getNodes(ctx, nodeIDs)
+		if err != nil {
+			if cockroachutil.NeedsRetry(err) {
+				continue
+			}
+			return nodes, err
+		}
+		break
+	}
+
+	return nodes, err
+}
+
+func (cache *overlaycache) getNodes(ctx context.Context, nodeIDs storj.NodeIDList) (_ map[storj.NodeID]*overlay.NodeDossier, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	nodes := make(map[storj.NodeID]*overlay.NodeDossier, len(nodeIDs))
+	if len(nodeIDs) == 0 {
+		return nodes, nil
+	}
+
+	rows, err := cache.db.Query(ctx, cache.db.Rebind(`
+		SELECT id, address, last_net, last_ip_port, country_code, protocol, type,
+			free_disk, piece_count, major, minor, patch, hash, timestamp, release,
+			latency_90, vetted_at, created_at, last_contact_success, last_contact_failure,
+			disqualified, unknown_audit_suspended, offline_suspended, under_review,
+			exit_initiated_at, exit_loop_completed_at, exit_finished_at, exit_success
+			FROM nodes
+			WHERE id = any($1::bytea[])
+		`), pgutil.NodeIDArray(nodeIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errs.Combine(err, rows.Close()) }()
+
+	for rows.Next() {
+		row := &dbx.Node{}
+		err = rows.Scan(&row.Id, &row.Address, &row.LastNet, &row.LastIpPort, &row.CountryCode, &row.Protocol, &row.Type,
+			&row.FreeDisk, &row.PieceCount, &row.Major, &row.Minor, &row.Patch, &row.Hash, &row.Timestamp, &row.Release,
+			&row.Latency90, &row.VettedAt, &row.CreatedAt, &row.LastContactSuccess, &row.LastContactFailure,
+			&row.Disqualified, &row.UnknownAuditSuspended, &row.OfflineSuspended, &row.UnderReview,
+			&row.ExitInitiatedAt, &row.ExitLoopCompletedAt, &row.ExitFinishedAt, &row.ExitSuccess)
+		if err != nil {
+			return nil, err
+		}
+		node, err := convertDBNode(ctx, row)
+		if err != nil {
+			return nil, err
+		}
+		nodes[node.Id] = node
+	}
+	return nodes, Error.Wrap(rows.Err())
+}
+
 // GetOnlineNodesForGetDelete returns a map of nodes for the supplied nodeIDs.
 func (cache *overlaycache) GetOnlineNodesForGetDelete(ctx context.Context, nodeIDs []storj.NodeID, onlineWindow time.Duration) (nodes map[storj.NodeID]*overlay.SelectedNode, err error) {
 	for {
@@ -182,7 +182,7 @@ func Run(t *testing.T, test func(ctx *testcontext.Context, t *testing.T, db sate
 				t.Skipf("Database %s connection string not provided. %s", dbInfo.MasterDB.Name, dbInfo.MasterDB.Message)
 			}
 
-			db, err := CreateMasterDB(ctx, zaptest.NewLogger(t), t.Name(), "T", 0, dbInfo.MasterDB)
+			db, err := CreateMigratedMasterDB(ctx, zaptest.NewLogger(t), t.Name(), "T", 0, dbInfo.MasterDB)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -193,11 +193,6 @@ func Run(t *testing.T, test func(ctx *testcontext.Context, t *testing.T, db sate
 				}
 			}()
 
-			err = db.TestingMigrateToLatest(ctx)
-			if err != nil {
-				t.Fatal(err)
-			}
-
 			test(ctx, t, db)
 		})
 	}
@@ -6,7 +6,13 @@ package satellitedbtest_test
 import (
 	"testing"
 
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
 	"storj.io/common/testcontext"
+	"storj.io/private/dbutil/pgtest"
+	"storj.io/private/dbutil/pgutil"
+	"storj.io/private/tagsql"
 	"storj.io/storj/satellite"
 	"storj.io/storj/satellite/satellitedb/satellitedbtest"
 )
@@ -15,3 +21,40 @@ func TestDatabase(t *testing.T) {
 	satellitedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db satellite.DB) {
 	})
 }
+
+func TestTemplate(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	log := zaptest.NewLogger(t)
+	dbInfo := satellitedbtest.Database{Name: "Postgres", URL: pgtest.PickPostgres(t)}
+
+	type dbHandle interface {
+		DebugGetDBHandle() tagsql.DB
+	}
+
+	cloned, err := satellitedbtest.CreateMigratedMasterDB(ctx, log, t.Name(), "C", 0, dbInfo)
+	require.NoError(t, err)
+	defer ctx.Check(cloned.Close)
+
+	// a second database is cloned from the same template.
+	second, err := satellitedbtest.CreateMigratedMasterDB(ctx, log, t.Name(), "C", 1, dbInfo)
+	require.NoError(t, err)
+	defer ctx.Check(second.Close)
+
+	fresh, err := satellitedbtest.CreateMasterDB(ctx, log, t.Name(), "F", 0, dbInfo)
+	require.NoError(t, err)
+	defer ctx.Check(fresh.Close)
+	require.NoError(t, fresh.TestingMigrateToLatest(ctx))
+
+	clonedSnapshot, err := pgutil.QuerySnapshot(ctx, cloned.(dbHandle).DebugGetDBHandle())
+	require.NoError(t, err)
+	freshSnapshot, err := pgutil.QuerySnapshot(ctx, fresh.(dbHandle).DebugGetDBHandle())
+	require.NoError(t, err)
+
+	require.Equal(t, freshSnapshot.Schema, clonedSnapshot.Schema)
+	require.Equal(t, freshSnapshot.Data, clonedSnapshot.Data)
+
+	// a cloned database is migrated already.
+	require.NoError(t, cloned.TestingMigrateToLatest(ctx))
+}
@@ -0,0 +1,245 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package satellitedbtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/context2"
+	"storj.io/private/dbutil"
+	"storj.io/private/dbutil/pgutil"
+	"storj.io/private/tagsql"
+	"storj.io/storj/private/migrate"
+	"storj.io/storj/satellite"
+	"storj.io/storj/satellite/satellitedb"
+)
+
+// templates are the names of the template databases that were ensured by this
+// process, by the connection string and the schema hash.
+var templates = struct {
+	mu    sync.Mutex
+	names map[string]string
+}{names: map[string]string{}}
+
+// CreateMigratedMasterDB creates a new satellite database for testing that is
+// migrated with TestingMigrateToLatest. On postgres the database is cloned
+// from a migrated template database, which is created once for every schema,
+// instead of migrating every database. Other databases are migrated.
+func CreateMigratedMasterDB(ctx context.Context, log *zap.Logger, name string, category string, index int, dbInfo Database) (db satellite.DB, err error) {
+	if dbInfo.URL == "" {
+		return nil, fmt.Errorf("Database %s connection string not provided. %s", dbInfo.Name, dbInfo.Message)
+	}
+
+	_, _, impl, err := dbutil.SplitConnStr(dbInfo.URL)
+	if err != nil {
+		return nil, err
+	}
+	if impl != dbutil.Postgres {
+		db, err := CreateMasterDB(ctx, log, name, category, index, dbInfo)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.TestingMigrateToLatest(ctx); err != nil {
+			return nil, errs.Combine(err, db.Close())
+		}
+		return db, nil
+	}
+
+	template, err := ensureTemplate(ctx, log, dbInfo.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	tempDB, err := cloneTemplate(ctx, dbInfo.URL, template, SchemaName(name, category, index, SchemaSuffix()))
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateMasterDBOnTopOf(ctx, log, tempDB)
+}
+
+// schemaHash returns the hash of the testing migration of the satellite database.
+func schemaHash(ctx context.Context, log *zap.Logger, connstr string) (_ string, err error) {
+	db, err := satellitedb.Open(ctx, log.Named("db"), connstr, satellitedb.Options{ApplicationName: "satellite-satellitdb-test"})
+	if err != nil {
+		return "", err
+	}
+	defer func() { err = errs.Combine(err, db.Close()) }()
+
+	access, ok := db.(interface {
+		MigrationTestingDefaultDB() interface {
+			TestPostgresMigration() *migrate.Migration
+		}
+	})
+	if !ok {
+		return "", errs.New("database doesn't provide the testing migration")
+	}
+
+	hash := sha256.New()
+	for _, step := range access.MigrationTestingDefaultDB().TestPostgresMigration().Steps {
+		_, _ = fmt.Fprintf(hash, "%d:%s\n", step.Version, step.Checksum())
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ensureTemplate returns the name of the template database of the current
+// schema, after creating it when it doesn't exist. The template databases are
+// kept and shared by the test processes, they are created under an advisory
+// lock and renamed into place once they are migrated.
+func ensureTemplate(ctx context.Context, log *zap.Logger, connstr string) (_ string, err error) {
+	hash, err := schemaHash(ctx, log, connstr)
+	if err != nil {
+		return "", err
+	}
+
+	templates.mu.Lock()
+	defer templates.mu.Unlock()
+
+	key := connstr + "#" + hash
+	if name, ok := templates.names[key]; ok {
+		return name, nil
+	}
+
+	name := "satellite_template_" + hash[:16]
+
+	db, err := tagsql.Open(ctx, "pgx", connstr)
+	if err != nil {
+		return "", errs.Wrap(err)
+	}
+	defer func() { err = errs.Combine(err, db.Close()) }()
+
+	// the advisory lock is held by the session, it needs a single connection.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return "", errs.Wrap(err)
+	}
+	defer func() { err = errs.Combine(err, conn.Close()) }()
+
+	lockKey := int64(binary.BigEndian.Uint64([]byte(hash[:8])))
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return "", errs.Wrap(err)
+	}
+	defer func() {
+		_, unlockErr := conn.ExecContext(context2.WithoutCancellation(ctx), `SELECT pg_advisory_unlock($1)`, lockKey)
+		err = errs.Combine(err, errs.Wrap(unlockErr))
+	}()
+
+	var exists bool
+	err = conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)`, name).Scan(&exists)
+	if err != nil {
+		return "", errs.Wrap(err)
+	}
+
+	if !exists {
+		// a template that is not renamed into place is left over by a
+		// process that didn't finish migrating it.
+		building := name + "_building"
+		if _, err := conn.ExecContext(ctx, `DROP DATABASE IF EXISTS `+pgutil.QuoteIdentifier(building)); err != nil {
+			return "", errs.Wrap(err)
+		}
+		if _, err := conn.ExecContext(ctx, `CREATE DATABASE `+pgutil.QuoteIdentifier(building)); err != nil {
+			return "", errs.Wrap(err)
+		}
+		if err := migrateTemplate(ctx, log, connstr, building); err != nil {
+			return "", err
+		}
+		_, err = conn.ExecContext(ctx, `ALTER DATABASE `+pgutil.QuoteIdentifier(building)+` RENAME TO `+pgutil.QuoteIdentifier(name))
+		if err != nil {
+			return "", errs.Wrap(err)
+		}
+		log.Debug("created template database", zap.String("name", name))
+	}
+
+	templates.names[key] = name
+	return name, nil
+}
+
+// migrateTemplate migrates the template database with TestingMigrateToLatest
+// and closes all connections to it, so that it can be cloned.
+func migrateTemplate(ctx context.Context, log *zap.Logger, connstr, template string) (err error) {
+	templateConnstr, err := withDatabase(connstr, template)
+	if err != nil {
+		return err
+	}
+
+	db, err := satellitedb.Open(ctx, log.Named("template"), templateConnstr, satellitedb.Options{ApplicationName: "satellite-satellitdb-test"})
+	if err != nil {
+		return err
+	}
+	defer func() { err = errs.Combine(err, db.Close()) }()
+
+	return db.TestingMigrateToLatest(ctx)
+}
+
+// cloneTemplate creates a database from the template database, which is
+// dropped when the returned database is closed.
+func cloneTemplate(ctx context.Context, connstr, template, name string) (_ *dbutil.TempDatabase, err error) {
+	name += "-" + pgutil.CreateRandomTestingSchemaName(8)
+
+	masterDB, err := tagsql.Open(ctx, "pgx", connstr)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+	defer func() { err = errs.Combine(err, masterDB.Close()) }()
+
+	_, err = masterDB.ExecContext(ctx, `CREATE DATABASE `+pgutil.QuoteIdentifier(name)+` TEMPLATE `+pgutil.QuoteIdentifier(template))
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+
+	// the database can't be dropped while it's connected to, the cleanup
+	// closes it and drops it from another connection.
+	cleanup := func(cleanupDB tagsql.DB) error {
+		ctx, cancel := context.WithTimeout(context2.WithoutCancellation(ctx), 15*time.Second)
+		defer cancel()
+
+		closeErr := cleanupDB.Close()
+
+		masterDB, err := tagsql.Open(ctx, "pgx", connstr)
+		if err != nil {
+			return errs.Combine(closeErr, errs.Wrap(err))
+		}
+		_, err = masterDB.ExecContext(ctx, `DROP DATABASE IF EXISTS `+pgutil.QuoteIdentifier(name))
+		return errs.Combine(closeErr, errs.Wrap(err), errs.Wrap(masterDB.Close()))
+	}
+
+	cloneConnstr, err := withDatabase(connstr, name)
+	if err != nil {
+		return nil, errs.Combine(err, cleanup(masterDB))
+	}
+
+	db, err := tagsql.Open(ctx, "pgx", cloneConnstr)
+	if err != nil {
+		return nil, errs.Combine(errs.Wrap(err), cleanup(masterDB))
+	}
+
+	return &dbutil.TempDatabase{
+		DB:             db,
+		ConnStr:        cloneConnstr,
+		Schema:         name,
+		Driver:         "pgx",
+		Implementation: dbutil.Postgres,
+		Cleanup:        cleanup,
+	}, nil
+}
+
+// withDatabase returns the connection string to another database of the server.
+func withDatabase(connstr, database string) (string, error) {
+	connURL, err := url.Parse(connstr)
+	if err != nil {
+		return "", errs.Wrap(err)
+	}
+	connURL.Path = database
+	return connURL.String(), nil
+}
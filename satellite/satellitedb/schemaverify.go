@@ -0,0 +1,177 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package satellitedb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/private/dbutil/dbschema"
+	"storj.io/private/dbutil/pgutil"
+	"storj.io/private/dbutil/tempdb"
+	"storj.io/storj/private/migrate"
+)
+
+// ErrSchemaDrift is when the schema of the database differs from the dbx schema.
+var ErrSchemaDrift = errs.Class("schema drift")
+
+// VerifySchema compares the schema of the database with the schema of the dbx
+// script and returns the differences as ErrSchemaDrift. The dbx script is
+// created in a temporary schema of the scratch database, which is dropped
+// afterwards. The database itself is only read.
+func (db *satelliteDB) VerifySchema(ctx context.Context, scratchDatabaseURL string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	scratch, err := tempdb.OpenUnique(ctx, scratchDatabaseURL, "verify-schema")
+	if err != nil {
+		return Error.New("opening scratch database failed: %w", err)
+	}
+	defer func() { err = errs.Combine(err, scratch.Close()) }()
+
+	if _, err := scratch.ExecContext(ctx, db.DB.Schema()); err != nil {
+		return Error.New("creating dbx schema failed: %w", err)
+	}
+
+	expected, err := pgutil.QuerySchema(ctx, scratch)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+
+	actual, err := pgutil.QuerySchema(ctx, db.DB)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	normalizeSchema(actual)
+
+	if diff := schemaDiff(expected, actual); len(diff) > 0 {
+		return ErrSchemaDrift.New("%d differences from the dbx schema:\n\t%s", len(diff), strings.Join(diff, "\n\t"))
+	}
+	return nil
+}
+
+// normalizeSchema removes the parts of a migrated schema that the dbx schema
+// doesn't have, the tables of the migration and of the backfill jobs.
+func normalizeSchema(schema *dbschema.Schema) {
+	schema.DropTable("versions")
+	schema.DropTable("versions_history")
+	schema.DropTable("versions_lock")
+	schema.DropTable(migrate.BackfillTable)
+
+	// TODO(cam): remove this with the migration step to drop the columns.
+	if nodes, ok := schema.FindTable("nodes"); ok {
+		nodes.RemoveColumn("contained")
+	}
+	if reputations, ok := schema.FindTable("reputations"); ok {
+		reputations.RemoveColumn("contained")
+	}
+}
+
+// schemaDiff returns the missing, extra and changed tables, columns and
+// indexes of the actual schema, sorted.
+func schemaDiff(expected, actual *dbschema.Schema) []string {
+	var diff []string
+
+	for _, expectedTable := range expected.Tables {
+		actualTable, ok := actual.FindTable(expectedTable.Name)
+		if !ok {
+			diff = append(diff, "missing table "+expectedTable.Name)
+			continue
+		}
+		diff = append(diff, tableDiff(expectedTable, actualTable)...)
+	}
+	for _, actualTable := range actual.Tables {
+		if _, ok := expected.FindTable(actualTable.Name); !ok {
+			diff = append(diff, "extra table "+actualTable.Name)
+		}
+	}
+
+	for _, expectedIndex := range expected.Indexes {
+		actualIndex, ok := actual.FindIndex(expectedIndex.Name)
+		switch {
+		case !ok:
+			diff = append(diff, fmt.Sprintf("missing index %s on %s", expectedIndex.Name, expectedIndex.Table))
+		case expectedIndex.String() != actualIndex.String():
+			diff = append(diff, fmt.Sprintf("changed index %s: expected %s, got %s", expectedIndex.Name, expectedIndex, actualIndex))
+		}
+	}
+	for _, actualIndex := range actual.Indexes {
+		if _, ok := expected.FindIndex(actualIndex.Name); !ok {
+			diff = append(diff, fmt.Sprintf("extra index %s on %s", actualIndex.Name, actualIndex.Table))
+		}
+	}
+
+	sort.Strings(diff)
+	return diff
+}
+
+// tableDiff returns the differences of the columns and constraints of a table.
+func tableDiff(expected, actual *dbschema.Table) []string {
+	var diff []string
+
+	for _, expectedColumn := range expected.Columns {
+		actualColumn, ok := actual.FindColumn(expectedColumn.Name)
+		switch {
+		case !ok:
+			diff = append(diff, fmt.Sprintf("missing column %s.%s", expected.Name, expectedColumn.Name))
+		case columnDefinition(expectedColumn) != columnDefinition(actualColumn):
+			diff = append(diff, fmt.Sprintf("changed column %s.%s: expected %q, got %q",
+				expected.Name, expectedColumn.Name, columnDefinition(expectedColumn), columnDefinition(actualColumn)))
+		}
+	}
+	for _, actualColumn := range actual.Columns {
+		if _, ok := expected.FindColumn(actualColumn.Name); !ok {
+			diff = append(diff, fmt.Sprintf("extra column %s.%s", actual.Name, actualColumn.Name))
+		}
+	}
+
+	if expectedKey, actualKey := strings.Join(expected.PrimaryKey, ", "), strings.Join(actual.PrimaryKey, ", "); expectedKey != actualKey {
+		diff = append(diff, fmt.Sprintf("changed primary key of %s: expected (%s), got (%s)", expected.Name, expectedKey, actualKey))
+	}
+
+	uniques := func(table *dbschema.Table) map[string]bool {
+		set := map[string]bool{}
+		for _, unique := range table.Unique {
+			set[strings.Join(unique, ", ")] = true
+		}
+		return set
+	}
+	expectedUniques, actualUniques := uniques(expected), uniques(actual)
+	for unique := range expectedUniques {
+		if !actualUniques[unique] {
+			diff = append(diff, fmt.Sprintf("missing unique constraint %s (%s)", expected.Name, unique))
+		}
+	}
+	for unique := range actualUniques {
+		if !expectedUniques[unique] {
+			diff = append(diff, fmt.Sprintf("extra unique constraint %s (%s)", actual.Name, unique))
+		}
+	}
+
+	return diff
+}
+
+// columnDefinition returns the definition of a column in SQL-like form.
+func columnDefinition(column *dbschema.Column) string {
+	definition := column.Type
+	if !column.IsNullable {
+		definition += " NOT NULL"
+	}
+	if column.Default != "" {
+		definition += " DEFAULT " + column.Default
+	}
+	if ref := column.Reference; ref != nil {
+		definition += fmt.Sprintf(" REFERENCES %s( %s )", ref.Table, ref.Column)
+		if ref.OnDelete != "" {
+			definition += " ON DELETE " + ref.OnDelete
+		}
+		if ref.OnUpdate != "" {
+			definition += " ON UPDATE " + ref.OnUpdate
+		}
+	}
+	return definition
+}
@@ -0,0 +1,59 @@
+CREATE TABLE objects (
+	project_id BYTEA NOT NULL,
+	bucket_name BYTEA NOT NULL,
+	object_key BYTEA NOT NULL,
+	version INT4 NOT NULL,
+	stream_id BYTEA NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL default now(),
+	expires_at TIMESTAMPTZ,
+	status INT2 NOT NULL default 1,
+	segment_count INT4 NOT NULL default 0,
+	encrypted_metadata_nonce BYTEA default NULL,
+	encrypted_metadata BYTEA default NULL,
+	encrypted_metadata_encrypted_key BYTEA default NULL,
+	total_plain_size INT8 NOT NULL default 0,
+	total_encrypted_size INT8 NOT NULL default 0,
+	fixed_segment_size INT4 NOT NULL default 0,
+	encryption INT8 NOT NULL default 0,
+	zombie_deletion_deadline TIMESTAMPTZ default now() + '1 day',
+	PRIMARY KEY ( project_id, bucket_name, object_key, version )
+);
+CREATE TABLE segments (
+	stream_id BYTEA NOT NULL,
+	position INT8 NOT NULL,
+	root_piece_id BYTEA NOT NULL,
+	encrypted_key_nonce BYTEA NOT NULL,
+	encrypted_key BYTEA NOT NULL,
+	encrypted_size INT4 NOT NULL,
+	plain_offset INT8 NOT NULL,
+	plain_size INT4 NOT NULL,
+	redundancy INT8 NOT NULL default 0,
+	inline_data BYTEA DEFAULT NULL,
+	remote_alias_pieces BYTEA,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	repaired_at TIMESTAMPTZ,
+	encrypted_etag BYTEA default NULL,
+	expires_at TIMESTAMPTZ,
+	placement integer,
+	PRIMARY KEY ( stream_id, position )
+);
+CREATE SEQUENCE node_alias_seq
+	INCREMENT BY 1
+	MINVALUE 1 MAXVALUE 2147483647
+	START WITH 1;
+CREATE TABLE node_aliases (
+	node_id BYTEA NOT NULL UNIQUE,
+	node_alias INT4 NOT NULL UNIQUE default nextval('node_alias_seq')
+);
+
+-- MAIN DATA --
+
+INSERT INTO "objects"("project_id", "bucket_name", "object_key", "version", "stream_id", "created_at", "expires_at", "status", "segment_count", "encrypted_metadata_nonce", "encrypted_metadata", "encrypted_metadata_encrypted_key", "total_plain_size", "total_encrypted_size", "fixed_segment_size", "encryption", "zombie_deletion_deadline") VALUES (E'\\363\\311\\033w\\222\\303Ci\\265\\342U\\303\\312\\204'::bytea, E'testbucket'::bytea, E'testobject'::bytea, 1, E'\\011\\275\\004\\207\\344\\225Aq\\237\\363\\221\\305\\233\\230\\347\\331'::bytea, '2021-11-04 08:27:56.614594+00', NULL, 3, 1, NULL, NULL, NULL, 256, 512, 512, 1, NULL);
+
+INSERT INTO "segments"("stream_id", "position", "root_piece_id", "encrypted_key_nonce", "encrypted_key", "encrypted_size", "plain_offset", "plain_size", "redundancy", "inline_data", "remote_alias_pieces", "created_at", "repaired_at", "encrypted_etag", "expires_at", "placement") VALUES (E'\\011\\275\\004\\207\\344\\225Aq\\237\\363\\221\\305\\233\\230\\347\\331'::bytea, 0, E'\\001\\002\\003'::bytea, E'\\004\\005\\006'::bytea, E'\\007\\010\\011'::bytea, 512, 0, 256, 1, NULL, E'\\001\\001'::bytea, '2021-11-04 08:27:56.614594+00', NULL, NULL, NULL, NULL);
+
+INSERT INTO "node_aliases"("node_id", "node_alias") VALUES (E'\\153\\313\\233\\074\\327\\177\\136\\070\\346\\002'::bytea, 1);
+
+-- NEW DATA --
+
+INSERT INTO "segments"("stream_id", "position", "root_piece_id", "encrypted_key_nonce", "encrypted_key", "encrypted_size", "plain_offset", "plain_size", "redundancy", "inline_data", "remote_alias_pieces", "created_at", "repaired_at", "encrypted_etag", "expires_at", "placement") VALUES (E'\\011\\275\\004\\207\\344\\225Aq\\237\\363\\221\\305\\233\\230\\347\\331'::bytea, 1, E'\\001\\002\\004'::bytea, E'\\004\\005\\007'::bytea, E'\\007\\010\\012'::bytea, 512, 256, 256, 1, NULL, E'\\001\\001'::bytea, '2021-11-04 08:27:56.614594+00', NULL, NULL, NULL, 1);
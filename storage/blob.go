@@ -87,6 +87,12 @@ type Blobs interface {
 	Trash(ctx context.Context, ref BlobRef) error
 	// RestoreTrash restores all files in the trash for a given namespace and returns the keys restored.
 	RestoreTrash(ctx context.Context, namespace []byte) ([][]byte, error)
+	// WalkTrash executes walkFunc for each blob in the trash of the given namespace.
+	WalkTrash(ctx context.Context, namespace []byte, walkFunc func(BlobInfo) error) error
+	// RestoreTrashed restores a single trashed blob once verify accepted it, and keeps the
+	// trash copy otherwise. It returns an error for which os.IsExist is true when the blob
+	// exists outside of the trash already.
+	RestoreTrashed(ctx context.Context, ref BlobRef, formatVer FormatVersion, verify func(BlobReader) error) error
 	// EmptyTrash removes all files in trash that were moved to trash prior to trashedBefore and returns the total bytes emptied and keys deleted.
 	EmptyTrash(ctx context.Context, namespace []byte, trashedBefore time.Time) (int64, [][]byte, error)
 	// Stat looks up disk metadata on the blob file.
@@ -112,6 +118,13 @@ type Blobs interface {
 	// error, WalkNamespace will stop iterating and return the error immediately. The ctx
 	// parameter is intended to allow canceling iteration early.
 	WalkNamespace(ctx context.Context, namespace []byte, walkFunc func(BlobInfo) error) error
+	// ListKeyPrefixes returns the sorted prefixes by which the keys of the blobs in
+	// the given namespace are grouped. Walking every prefix with WalkNamespacePrefix
+	// walks the same blobs as WalkNamespace.
+	ListKeyPrefixes(ctx context.Context, namespace []byte) ([]string, error)
+	// WalkNamespacePrefix executes walkFunc like WalkNamespace, but only for the blobs
+	// whose keys are grouped under the given prefix returned by ListKeyPrefixes.
+	WalkNamespacePrefix(ctx context.Context, namespace []byte, keyPrefix string, walkFunc func(BlobInfo) error) error
 	// CreateVerificationFile creates a file to be used for storage directory verification.
 	CreateVerificationFile(ctx context.Context, id storj.NodeID) error
 	// VerifyStorageDir verifies that the storage directory is correct by checking for the existence and validity
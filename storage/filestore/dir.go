@@ -13,6 +13,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -458,6 +459,53 @@ func (dir *Dir) RestoreTrash(ctx context.Context, namespace []byte) (keysRestore
 	return keysRestored, err
 }
 
+// WalkTrash executes walkFunc for each blob in the trash of the given namespace.
+func (dir *Dir) WalkTrash(ctx context.Context, namespace []byte, walkFunc func(storage.BlobInfo) error) (err error) {
+	defer mon.Task()(&ctx)(&err)
+	return dir.walkNamespaceInPath(ctx, namespace, dir.trashdir(), walkFunc)
+}
+
+// LinkTrashedWithStorageFormat makes the trashed blob specified by ref available in
+// blobsdir again, while the trash copy is kept. It returns an error for which
+// os.IsExist is true when the blob exists in blobsdir with any format version.
+func (dir *Dir) LinkTrashedWithStorageFormat(ctx context.Context, ref storage.BlobRef, formatVer storage.FormatVersion) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if _, err := dir.Stat(ctx, ref); err == nil {
+		return os.ErrExist
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	blobsBasePath, err := dir.blobToBasePath(ref)
+	if err != nil {
+		return err
+	}
+	blobsVerPath := blobPathForFormatVersion(blobsBasePath, formatVer)
+
+	trashBasePath, err := dir.refToDirPath(ref, dir.trashdir())
+	if err != nil {
+		return err
+	}
+	trashVerPath := blobPathForFormatVersion(trashBasePath, formatVer)
+
+	// ensure the dirs exist for blobs path
+	err = os.MkdirAll(filepath.Dir(blobsVerPath), dirPermission)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	// linking fails when the blob was created concurrently, unlike a rename.
+	return os.Link(trashVerPath, blobsVerPath)
+}
+
+// DeleteTrashedWithStorageFormat deletes the trashed blob specified by ref for
+// one specific format version.
+func (dir *Dir) DeleteTrashedWithStorageFormat(ctx context.Context, ref storage.BlobRef, formatVer storage.FormatVersion) (err error) {
+	defer mon.Task()(&ctx)(&err)
+	return dir.deleteWithStorageFormatInPath(ctx, dir.trashdir(), ref, formatVer)
+}
+
 // EmptyTrash walks the trash files for the given namespace and deletes any
 // file whose mtime is older than trashedBefore. The mtime is modified when
 // Trash is called.
@@ -730,6 +778,61 @@ func (dir *Dir) walkNamespaceInPath(ctx context.Context, namespace []byte, path
 	}
 }
 
+// ListKeyPrefixes returns the sorted prefixes of the encoded keys of the blobs in the given
+// namespace. Every prefix is a directory that holds the blobs whose keys begin with it.
+func (dir *Dir) ListKeyPrefixes(ctx context.Context, namespace []byte) (prefixes []string, err error) {
+	defer mon.Task()(&ctx)(&err)
+	nsDir := filepath.Join(dir.blobsdir(), pathEncoding.EncodeToString(namespace))
+	openDir, err := os.Open(nsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { err = errs.Combine(err, openDir.Close()) }()
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		subdirNames, err := openDir.Readdirnames(nameBatchSize)
+		if err != nil {
+			if errors.Is(err, io.EOF) || os.IsNotExist(err) {
+				break
+			}
+			return nil, err
+		}
+		if len(subdirNames) == 0 {
+			break
+		}
+		for _, keyPrefix := range subdirNames {
+			if len(keyPrefix) == 2 {
+				prefixes = append(prefixes, keyPrefix)
+			}
+		}
+	}
+	sort.Strings(prefixes)
+	return prefixes, nil
+}
+
+// WalkNamespacePrefix executes walkFunc for each locally stored blob, stored with storage
+// format V1 or greater, in the given namespace whose encoded key begins with keyPrefix.
+// Only the directory of the prefix is read, so walking the prefixes one after another
+// keeps a single directory open at a time.
+func (dir *Dir) WalkNamespacePrefix(ctx context.Context, namespace []byte, keyPrefix string, walkFunc func(storage.BlobInfo) error) (err error) {
+	defer mon.Task()(&ctx)(&err)
+	if len(keyPrefix) != 2 {
+		return Error.New("invalid key prefix %q", keyPrefix)
+	}
+	nsDir := filepath.Join(dir.blobsdir(), pathEncoding.EncodeToString(namespace))
+	err = walkNamespaceWithPrefix(ctx, dir.log, namespace, nsDir, keyPrefix, walkFunc)
+	if os.IsNotExist(err) {
+		// the prefix was emptied and removed since it was listed.
+		return nil
+	}
+	return err
+}
+
 func decodeBlobInfo(namespace []byte, keyPrefix, keyDir string, keyInfo os.FileInfo) (info storage.BlobInfo, ok bool) {
 	blobFileName := keyInfo.Name()
 	encodedKey := keyPrefix + blobFileName
@@ -147,6 +147,37 @@ func (store *blobStore) RestoreTrash(ctx context.Context, namespace []byte) (key
 	return keysRestored, Error.Wrap(err)
 }
 
+// WalkTrash executes walkFunc for each blob in the trash of the given namespace.
+func (store *blobStore) WalkTrash(ctx context.Context, namespace []byte, walkFunc func(storage.BlobInfo) error) (err error) {
+	return store.dir.WalkTrash(ctx, namespace, walkFunc)
+}
+
+// RestoreTrashed moves the trashed blob back into the regular location, once
+// verify accepted the restored blob. The trash copy is kept when the blob could
+// not be restored or verify failed. It returns an error for which os.IsExist is
+// true when the blob exists in the regular location already.
+func (store *blobStore) RestoreTrashed(ctx context.Context, ref storage.BlobRef, formatVer storage.FormatVersion, verify func(storage.BlobReader) error) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	err = store.dir.LinkTrashedWithStorageFormat(ctx, ref, formatVer)
+	if err != nil {
+		if os.IsExist(err) {
+			return err
+		}
+		return Error.Wrap(err)
+	}
+
+	reader, err := store.OpenWithStorageFormat(ctx, ref, formatVer)
+	if err == nil {
+		err = verify(reader)
+		err = errs.Combine(err, reader.Close())
+	}
+	if err != nil {
+		return Error.Wrap(errs.Combine(err, store.dir.DeleteWithStorageFormat(ctx, ref, formatVer)))
+	}
+	return Error.Wrap(store.dir.DeleteTrashedWithStorageFormat(ctx, ref, formatVer))
+}
+
 // // EmptyTrash removes all files in trash that have been there longer than trashExpiryDur.
 func (store *blobStore) EmptyTrash(ctx context.Context, namespace []byte, trashedBefore time.Time) (bytesEmptied int64, keys [][]byte, err error) {
 	defer mon.Task()(&ctx)(&err)
@@ -284,6 +315,20 @@ func (store *blobStore) WalkNamespace(ctx context.Context, namespace []byte, wal
 	return store.dir.WalkNamespace(ctx, namespace, walkFunc)
 }
 
+// ListKeyPrefixes returns the sorted prefixes by which the keys of the blobs in the given
+// namespace are grouped.
+func (store *blobStore) ListKeyPrefixes(ctx context.Context, namespace []byte) (_ []string, err error) {
+	prefixes, err := store.dir.ListKeyPrefixes(ctx, namespace)
+	return prefixes, Error.Wrap(err)
+}
+
+// WalkNamespacePrefix executes walkFunc for each locally stored blob in the given namespace,
+// whose key is grouped under keyPrefix. If walkFunc returns a non-nil error,
+// WalkNamespacePrefix will stop iterating and return the error immediately.
+func (store *blobStore) WalkNamespacePrefix(ctx context.Context, namespace []byte, keyPrefix string, walkFunc func(storage.BlobInfo) error) (err error) {
+	return store.dir.WalkNamespacePrefix(ctx, namespace, keyPrefix, walkFunc)
+}
+
 // TestCreateV0 creates a new V0 blob that can be written. This is ONLY appropriate in test situations.
 func (store *blobStore) TestCreateV0(ctx context.Context, ref storage.BlobRef) (_ storage.BlobWriter, err error) {
 	defer mon.Task()(&ctx)(&err)
@@ -533,6 +533,65 @@ func TestStoreTraversals(t *testing.T) {
 	assert.Equal(t, 2, iterations)
 }
 
+// Check that walking every key prefix yields the same blobs as WalkNamespace.
+func TestStoreKeyPrefixTraversal(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	store, err := filestore.NewAt(zaptest.NewLogger(t), ctx.Dir("store"), filestore.DefaultConfig)
+	require.NoError(t, err)
+	defer ctx.Check(store.Close)
+
+	namespace := testrand.Bytes(namespaceSize)
+
+	prefixes, err := store.ListKeyPrefixes(ctx, namespace)
+	require.NoError(t, err)
+	require.Empty(t, prefixes)
+
+	const numBlobs = 100
+	for i := 0; i < numBlobs; i++ {
+		blobWriter, err := store.Create(ctx, storage.BlobRef{
+			Namespace: namespace,
+			Key:       testrand.Bytes(keySize),
+		}, 0)
+		require.NoError(t, err)
+		require.NoError(t, blobWriter.Commit(ctx))
+	}
+
+	var walked [][]byte
+	err = store.WalkNamespace(ctx, namespace, func(info storage.BlobInfo) error {
+		walked = append(walked, info.BlobRef().Key)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, walked, numBlobs)
+
+	prefixes, err = store.ListKeyPrefixes(ctx, namespace)
+	require.NoError(t, err)
+	require.True(t, sort.StringsAreSorted(prefixes))
+
+	var walkedByPrefix [][]byte
+	for _, prefix := range prefixes {
+		err := store.WalkNamespacePrefix(ctx, namespace, prefix, func(info storage.BlobInfo) error {
+			path, err := info.FullPath(ctx)
+			require.NoError(t, err)
+			require.Equal(t, prefix, filepath.Base(filepath.Dir(path)))
+
+			walkedByPrefix = append(walkedByPrefix, info.BlobRef().Key)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	require.ElementsMatch(t, walked, walkedByPrefix)
+
+	// a prefix without blobs is walked without error.
+	err = store.WalkNamespacePrefix(ctx, testrand.Bytes(namespaceSize), prefixes[0], func(storage.BlobInfo) error {
+		t.Fatal("this should not have been called")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
 func TestEmptyTrash(t *testing.T) {
 	ctx := testcontext.New(t)
 	defer ctx.Cleanup()
@@ -6,6 +6,7 @@ package consoleapi
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -155,6 +156,95 @@ func (dashboard *StorageNode) EstimatedPayout(w http.ResponseWriter, r *http.Req
 	}
 }
 
+// GarbageCollection handles garbage collection progress API requests.
+func (dashboard *StorageNode) GarbageCollection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var err error
+	defer mon.Task()(&ctx)(&err)
+
+	w.Header().Set(contentType, applicationJSON)
+
+	data, err := dashboard.service.GetGarbageCollection(ctx)
+	if err != nil {
+		dashboard.serveJSONError(w, http.StatusInternalServerError, ErrStorageNodeAPI.Wrap(err))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		dashboard.log.Error("failed to encode json response", zap.Error(ErrStorageNodeAPI.Wrap(err)))
+		return
+	}
+}
+
+// defaultGarbageCollectionHistoryLimit is the number of history entries
+// returned when no limit is requested.
+const defaultGarbageCollectionHistoryLimit = 10
+
+// GarbageCollectionHistory handles garbage collection history API requests.
+func (dashboard *StorageNode) GarbageCollectionHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var err error
+	defer mon.Task()(&ctx)(&err)
+
+	w.Header().Set(contentType, applicationJSON)
+
+	limit := uint64(defaultGarbageCollectionHistoryLimit)
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			dashboard.serveJSONError(w, http.StatusBadRequest, ErrStorageNodeAPI.Wrap(err))
+			return
+		}
+	}
+
+	data, err := dashboard.service.GetGarbageCollectionHistory(ctx, int(limit))
+	if err != nil {
+		dashboard.serveJSONError(w, http.StatusInternalServerError, ErrStorageNodeAPI.Wrap(err))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		dashboard.log.Error("failed to encode json response", zap.Error(ErrStorageNodeAPI.Wrap(err)))
+		return
+	}
+}
+
+// OverrideGarbageCollection handles API requests to process the halted garbage collection requests
+// of a satellite without the cap on the delete ratio.
+func (dashboard *StorageNode) OverrideGarbageCollection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var err error
+	defer mon.Task()(&ctx)(&err)
+
+	w.Header().Set(contentType, applicationJSON)
+
+	satelliteID, err := storj.NodeIDFromString(mux.Vars(r)["id"])
+	if err != nil {
+		dashboard.serveJSONError(w, http.StatusBadRequest, ErrStorageNodeAPI.Wrap(err))
+		return
+	}
+
+	if err = dashboard.service.VerifySatelliteID(ctx, satelliteID); err != nil {
+		dashboard.serveJSONError(w, http.StatusNotFound, ErrStorageNodeAPI.Wrap(err))
+		return
+	}
+
+	overridden, err := dashboard.service.OverrideGarbageCollection(ctx, satelliteID)
+	if err != nil {
+		dashboard.serveJSONError(w, http.StatusInternalServerError, ErrStorageNodeAPI.Wrap(err))
+		return
+	}
+
+	var response struct {
+		Overridden int `json:"overridden"`
+	}
+	response.Overridden = overridden
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		dashboard.log.Error("failed to encode json response", zap.Error(ErrStorageNodeAPI.Wrap(err)))
+		return
+	}
+}
+
 // serveJSONError writes JSON error to response output stream.
 func (dashboard *StorageNode) serveJSONError(w http.ResponseWriter, status int, err error) {
 	w.WriteHeader(status)
@@ -69,6 +69,9 @@ func NewServer(logger *zap.Logger, assets http.FileSystem, notifications *notifi
 	storageNodeRouter.HandleFunc("/satellites", storageNodeController.Satellites).Methods(http.MethodGet)
 	storageNodeRouter.HandleFunc("/satellite/{id}", storageNodeController.Satellite).Methods(http.MethodGet)
 	storageNodeRouter.HandleFunc("/estimated-payout", storageNodeController.EstimatedPayout).Methods(http.MethodGet)
+	storageNodeRouter.HandleFunc("/garbage-collection", storageNodeController.GarbageCollection).Methods(http.MethodGet)
+	storageNodeRouter.HandleFunc("/garbage-collection/history", storageNodeController.GarbageCollectionHistory).Methods(http.MethodGet)
+	storageNodeRouter.HandleFunc("/garbage-collection/{id}/override", storageNodeController.OverrideGarbageCollection).Methods(http.MethodPost)
 
 	notificationController := consoleapi.NewNotifications(server.log, server.notifications)
 	notificationRouter := router.PathPrefix("/api/notifications").Subrouter()
@@ -3,10 +3,15 @@
 
 package console
 
+import "time"
+
 // DiskSpaceInfo stores all info about storagenode disk space usage.
 type DiskSpaceInfo struct {
 	Used      int64 `json:"used"`
 	Available int64 `json:"available"`
 	Trash     int64 `json:"trash"`
 	Overused  int64 `json:"overused"`
+	// LastRecalculated is when the used space was last recalculated from the
+	// pieces on disk, it is updated incrementally in between.
+	LastRecalculated time.Time `json:"lastRecalculated"`
 }
@@ -24,6 +24,7 @@ import (
 	"storj.io/storj/storagenode/pieces"
 	"storj.io/storj/storagenode/pricing"
 	"storj.io/storj/storagenode/reputation"
+	"storj.io/storj/storagenode/retain"
 	"storj.io/storj/storagenode/satellites"
 	"storj.io/storj/storagenode/storageusage"
 	"storj.io/storj/storagenode/trust"
@@ -50,6 +51,7 @@ type Service struct {
 	satelliteDB    satellites.DB
 	pieceStore     *pieces.Store
 	contact        *contact.Service
+	retain         *retain.Service
 
 	estimation *estimatedpayouts.Service
 	version    *checker.Service
@@ -71,7 +73,7 @@ func NewService(log *zap.Logger, bandwidth bandwidth.DB, pieceStore *pieces.Stor
 	allocatedDiskSpace memory.Size, walletAddress string, versionInfo version.Info, trust *trust.Pool,
 	reputationDB reputation.DB, storageUsageDB storageusage.DB, pricingDB pricing.DB, satelliteDB satellites.DB,
 	pingStats *contact.PingStats, contact *contact.Service, estimation *estimatedpayouts.Service, usageCache *pieces.BlobsUsageCache,
-	walletFeatures operator.WalletFeatures, port string, quicEnabled bool) (*Service, error) {
+	retain *retain.Service, walletFeatures operator.WalletFeatures, port string, quicEnabled bool) (*Service, error) {
 	if log == nil {
 		return nil, errs.New("log can't be nil")
 	}
@@ -104,6 +106,10 @@ func NewService(log *zap.Logger, bandwidth bandwidth.DB, pieceStore *pieces.Stor
 		return nil, errs.New("estimation service can't be nil")
 	}
 
+	if retain == nil {
+		return nil, errs.New("retain service can't be nil")
+	}
+
 	return &Service{
 		log:                log,
 		trust:              trust,
@@ -119,6 +125,7 @@ func NewService(log *zap.Logger, bandwidth bandwidth.DB, pieceStore *pieces.Stor
 		allocatedDiskSpace: allocatedDiskSpace,
 		contact:            contact,
 		estimation:         estimation,
+		retain:             retain,
 		walletAddress:      walletAddress,
 		startedAt:          time.Now(),
 		versionInfo:        versionInfo,
@@ -223,9 +230,10 @@ func (s *Service) GetDashboardData(ctx context.Context) (_ *Dashboard, err error
 	}
 
 	data.DiskSpace = DiskSpaceInfo{
-		Used:      pieceTotal,
-		Available: s.allocatedDiskSpace.Int64(),
-		Trash:     trash,
+		Used:             pieceTotal,
+		Available:        s.allocatedDiskSpace.Int64(),
+		Trash:            trash,
+		LastRecalculated: s.usageCache.LastRecalculated(),
 	}
 
 	overused := s.allocatedDiskSpace.Int64() - pieceTotal - trash
@@ -465,6 +473,32 @@ func (s *Service) GetAllSatellitesEstimatedPayout(ctx context.Context, now time.
 	return estimatedPayout, nil
 }
 
+// GetGarbageCollection returns the progress of the queued and active garbage collection requests.
+func (s *Service) GetGarbageCollection(ctx context.Context) (_ []retain.Progress, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	return s.retain.Progress(), nil
+}
+
+// GetGarbageCollectionHistory returns up to limit of the most recently processed garbage collection requests.
+func (s *Service) GetGarbageCollectionHistory(ctx context.Context, limit int) (_ []retain.HistoryEntry, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	history, err := s.retain.History(limit)
+	if err != nil {
+		return nil, SNOServiceErr.Wrap(err)
+	}
+	return history, nil
+}
+
+// OverrideGarbageCollection queues the garbage collection requests of the satellite that were halted
+// because they exceeded the maximum delete ratio again, without the cap. It returns the number of queued requests.
+func (s *Service) OverrideGarbageCollection(ctx context.Context, satelliteID storj.NodeID) (_ int, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	return s.retain.Override(satelliteID), nil
+}
+
 // VerifySatelliteID verifies if the satellite belongs to the trust pool.
 func (s *Service) VerifySatelliteID(ctx context.Context, satelliteID storj.NodeID) (err error) {
 	defer mon.Task()(&ctx)(&err)
@@ -28,6 +28,7 @@ import (
 	"storj.io/private/version"
 	"storj.io/storj/private/lifecycle"
 	"storj.io/storj/private/multinodepb"
+	"storj.io/storj/private/retainpb"
 	"storj.io/storj/private/server"
 	"storj.io/storj/private/version/checker"
 	"storj.io/storj/storage"
@@ -151,6 +152,11 @@ func (config *Config) Verify(log *zap.Logger) error {
 		return err
 	}
 
+	err = config.Retain.Verify()
+	if err != nil {
+		return err
+	}
+
 	if config.Contact.ExternalAddress != "" {
 		err := isAddressValid(config.Contact.ExternalAddress)
 		if err != nil {
@@ -232,17 +238,19 @@ type Peer struct {
 
 	Storage2 struct {
 		// TODO: lift things outside of it to organize better
-		Trust         *trust.Pool
-		Store         *pieces.Store
-		TrashChore    *pieces.TrashChore
-		BlobsCache    *pieces.BlobsUsageCache
-		CacheService  *pieces.CacheService
-		RetainService *retain.Service
-		PieceDeleter  *pieces.Deleter
-		Endpoint      *piecestore.Endpoint
-		Inspector     *inspector.Endpoint
-		Monitor       *monitor.Service
-		Orders        *orders.Service
+		Trust          *trust.Pool
+		Store          *pieces.Store
+		TrashChore     *pieces.TrashChore
+		BlobsCache     *pieces.BlobsUsageCache
+		CacheService   *pieces.CacheService
+		RetainService  *retain.Service
+		RetainReporter *retain.Reporter
+		RetainEndpoint *retain.Endpoint
+		PieceDeleter   *pieces.Deleter
+		Endpoint       *piecestore.Endpoint
+		Inspector      *inspector.Endpoint
+		Monitor        *monitor.Service
+		Orders         *orders.Service
 	}
 
 	Collector *collector.Service
@@ -492,14 +500,39 @@ func New(log *zap.Logger, full *identity.FullIdentity, db DB, revocationDB exten
 		peer.Storage2.RetainService = retain.NewService(
 			peer.Log.Named("retain"),
 			peer.Storage2.Store,
+			peer.Storage2.CacheService,
 			config.Retain,
 		)
+		mon.Chain(peer.Storage2.RetainService)
 		peer.Services.Add(lifecycle.Item{
 			Name:  "retain",
 			Run:   peer.Storage2.RetainService.Run,
 			Close: peer.Storage2.RetainService.Close,
 		})
 
+		if config.Retain.ReportResults {
+			peer.Storage2.RetainReporter = retain.NewReporter(
+				peer.Log.Named("retain:reporter"),
+				peer.Dialer,
+				peer.Storage2.Trust,
+				peer.Storage2.RetainService,
+			)
+			peer.Services.Add(lifecycle.Item{
+				Name:  "retain:reporter",
+				Run:   peer.Storage2.RetainReporter.Run,
+				Close: peer.Storage2.RetainReporter.Close,
+			})
+		}
+
+		peer.Storage2.RetainEndpoint = retain.NewEndpoint(
+			peer.Log.Named("retain:endpoint"),
+			peer.Storage2.Trust,
+			peer.Storage2.RetainService,
+		)
+		if err := retainpb.DRPCRegisterRetainStatus(peer.Server.DRPC(), peer.Storage2.RetainEndpoint); err != nil {
+			return nil, errs.Combine(err, peer.Close())
+		}
+
 		peer.UsedSerials = usedserials.NewTable(config.Storage2.MaxUsedSerialsSize)
 
 		peer.OrdersStore, err = orders.NewFileStore(
@@ -653,6 +686,7 @@ func New(log *zap.Logger, full *identity.FullIdentity, db DB, revocationDB exten
 			peer.Contact.Service,
 			peer.Estimation.Service,
 			peer.Storage2.BlobsCache,
+			peer.Storage2.RetainService,
 			config.Operator.WalletFeatures,
 			port,
 			peer.Server.IsQUICEnabled(),
@@ -159,6 +159,9 @@ type BlobsUsageCache struct {
 	piecesContentSize    int64
 	trashTotal           int64
 	spaceUsedBySatellite map[storj.NodeID]SatelliteUsage
+	// lastRecalculated is when the totals were last recalculated from
+	// iterating over all the pieces on disk.
+	lastRecalculated time.Time
 }
 
 // NewBlobsUsageCache creates a new disk blob store with a space used cache.
@@ -344,6 +347,25 @@ func (blobs *BlobsUsageCache) RestoreTrash(ctx context.Context, namespace []byte
 	return keysRestored, err
 }
 
+// RestoreTrashed restores a single trashed blob and updates the cache.
+func (blobs *BlobsUsageCache) RestoreTrashed(ctx context.Context, ref storage.BlobRef, formatVer storage.FormatVersion, verify func(storage.BlobReader) error) error {
+	satelliteID, err := storj.NodeIDFromBytes(ref.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if err := blobs.Blobs.RestoreTrashed(ctx, ref, formatVer, verify); err != nil {
+		return err
+	}
+
+	pieceTotal, pieceContentSize, err := blobs.pieceSizes(ctx, ref)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	blobs.Update(ctx, satelliteID, pieceTotal, pieceContentSize, -pieceTotal)
+	return nil
+}
+
 func (blobs *BlobsUsageCache) copyCacheTotals() BlobsUsageCache {
 	blobs.mu.Lock()
 	defer blobs.mu.Unlock()
@@ -448,9 +470,19 @@ func (blobs *BlobsUsageCache) Recalculate(
 	blobs.piecesContentSize = estimatedPiecesContentSize
 	blobs.trashTotal = estimatedTotalTrash
 	blobs.spaceUsedBySatellite = estimatedTotalsBySatellite
+	blobs.lastRecalculated = time.Now()
 	blobs.mu.Unlock()
 }
 
+// LastRecalculated returns when the totals were last recalculated from the
+// pieces on disk. It is zero when they were not recalculated since startup.
+// In between, the totals are only updated incrementally.
+func (blobs *BlobsUsageCache) LastRecalculated() time.Time {
+	blobs.mu.Lock()
+	defer blobs.mu.Unlock()
+	return blobs.lastRecalculated
+}
+
 func estimate(newSpaceUsedTotal, totalAtIterationStart, totalAtIterationEnd int64) int64 {
 	if newSpaceUsedTotal == totalAtIterationEnd {
 		if newSpaceUsedTotal < 0 {
@@ -4,9 +4,12 @@
 package pieces
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"os"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/spacemonkeygo/monkit/v3"
@@ -16,6 +19,7 @@ import (
 	"storj.io/common/memory"
 	"storj.io/common/pb"
 	"storj.io/common/storj"
+	"storj.io/common/sync2"
 	"storj.io/storj/storage"
 	"storj.io/storj/storage/filestore"
 )
@@ -56,6 +60,9 @@ type ExpiredInfo struct {
 type PieceExpirationDB interface {
 	// GetExpired gets piece IDs that expire or have expired before the given time
 	GetExpired(ctx context.Context, expiresBefore time.Time, limit int64) ([]ExpiredInfo, error)
+	// GetExpiringBySatellite gets the IDs of the pieces of the satellite that are not in
+	// the trash and expire or have expired before the given time
+	GetExpiringBySatellite(ctx context.Context, satellite storj.NodeID, expiresBefore time.Time) ([]storj.PieceID, error)
 	// SetExpiration sets an expiration time for the given piece ID on the given satellite
 	SetExpiration(ctx context.Context, satellite storj.NodeID, pieceID storj.PieceID, expiresAt time.Time) error
 	// DeleteExpiration removes an expiration record for the given piece ID on the given satellite
@@ -391,6 +398,76 @@ func (store *Store) RestoreTrash(ctx context.Context, satelliteID storj.NodeID)
 	return Error.Wrap(store.expirationInfo.RestoreTrash(ctx, satelliteID))
 }
 
+// RestoreProgress are the totals of restoring the trash of a satellite piece
+// by piece.
+type RestoreProgress struct {
+	PiecesRestored int64
+	BytesRestored  int64
+	// Collisions are the trashed pieces that were kept in the trash, because
+	// a piece with the same ID exists outside of it.
+	Collisions int64
+	// PiecesFailed are the trashed pieces that were kept in the trash,
+	// because they could not be restored or their header is not readable.
+	PiecesFailed int64
+}
+
+// RestoreTrashVerified restores the pieces in the trash of the satellite one
+// by one. Every restored piece is checked to have a readable header before its
+// trash copy is removed, and pieces that exist outside of the trash are kept
+// as they are. progress, when set, is called after every piece.
+func (store *Store) RestoreTrashVerified(ctx context.Context, satelliteID storj.NodeID, progress func(RestoreProgress)) (totals RestoreProgress, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	err = store.blobs.WalkTrash(ctx, satelliteID.Bytes(), func(info storage.BlobInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var size int64
+		if stat, err := info.Stat(ctx); err == nil {
+			size = stat.Size()
+		}
+
+		err := store.blobs.RestoreTrashed(ctx, info.BlobRef(), info.StorageFormatVersion(), verifyPieceHeader)
+		switch {
+		case err == nil:
+			totals.PiecesRestored++
+			totals.BytesRestored += size
+		case errs.IsFunc(err, os.IsExist):
+			totals.Collisions++
+		default:
+			totals.PiecesFailed++
+			store.log.Warn("failed to restore piece from trash",
+				zap.Stringer("Satellite ID", satelliteID),
+				zap.Binary("Key", info.BlobRef().Key),
+				zap.Error(err))
+		}
+
+		if progress != nil {
+			progress(totals)
+		}
+		return nil
+	})
+	if err != nil {
+		return totals, Error.Wrap(err)
+	}
+	return totals, Error.Wrap(store.expirationInfo.RestoreTrash(ctx, satelliteID))
+}
+
+// verifyPieceHeader checks that the header of the piece in the blob is
+// readable. Pieces stored with format V0 don't have a header.
+func verifyPieceHeader(blob storage.BlobReader) error {
+	reader, err := NewReader(blob)
+	if err != nil {
+		return err
+	}
+	if reader.StorageFormatVersion() < filestore.FormatV1 {
+		return nil
+	}
+	_, err = reader.GetPieceHeader()
+	return err
+}
+
 // MigrateV0ToV1 will migrate a piece stored with storage format v0 to storage
 // format v1. If the piece is not stored as a v0 piece it will return an error.
 // The follow failures are possible:
@@ -494,9 +571,43 @@ func (store *Store) GetHashAndLimit(ctx context.Context, satellite storj.NodeID,
 func (store *Store) WalkSatellitePieces(ctx context.Context, satellite storj.NodeID, walkFunc func(StoredPieceAccess) error) (err error) {
 	defer mon.Task()(&ctx)(&err)
 	// first iterate over all in V1 storage, then all in V0
-	err = store.blobs.WalkNamespace(ctx, satellite.Bytes(), func(blobInfo storage.BlobInfo) error {
+	err = store.blobs.WalkNamespace(ctx, satellite.Bytes(), store.v1PieceWalker(walkFunc))
+	if err == nil {
+		err = store.WalkSatelliteV0Pieces(ctx, satellite, walkFunc)
+	}
+	return err
+}
+
+// SatelliteKeyPrefixes returns the sorted prefixes by which the keys of the V1 pieces of the
+// satellite are grouped. Together with WalkSatellitePiecesPrefix and WalkSatelliteV0Pieces it
+// allows walking the pieces of a satellite in chunks, e.g. to record progress in between.
+func (store *Store) SatelliteKeyPrefixes(ctx context.Context, satellite storj.NodeID) (_ []string, err error) {
+	defer mon.Task()(&ctx)(&err)
+	return store.blobs.ListKeyPrefixes(ctx, satellite.Bytes())
+}
+
+// WalkSatellitePiecesPrefix executes walkFunc for each V1 piece of the satellite whose key is
+// grouped under keyPrefix, as returned by SatelliteKeyPrefixes.
+func (store *Store) WalkSatellitePiecesPrefix(ctx context.Context, satellite storj.NodeID, keyPrefix string, walkFunc func(StoredPieceAccess) error) (err error) {
+	defer mon.Task()(&ctx)(&err)
+	return store.blobs.WalkNamespacePrefix(ctx, satellite.Bytes(), keyPrefix, store.v1PieceWalker(walkFunc))
+}
+
+// WalkSatelliteV0Pieces executes walkFunc for each piece of the satellite stored with
+// storage format V0.
+func (store *Store) WalkSatelliteV0Pieces(ctx context.Context, satellite storj.NodeID, walkFunc func(StoredPieceAccess) error) (err error) {
+	defer mon.Task()(&ctx)(&err)
+	if store.v0PieceInfo == nil {
+		return nil
+	}
+	return store.v0PieceInfo.WalkSatelliteV0Pieces(ctx, store.blobs, satellite, walkFunc)
+}
+
+// v1PieceWalker adapts walkFunc to walk the V1 pieces of a blob namespace.
+func (store *Store) v1PieceWalker(walkFunc func(StoredPieceAccess) error) func(storage.BlobInfo) error {
+	return func(blobInfo storage.BlobInfo) error {
 		if blobInfo.StorageFormatVersion() < filestore.FormatV1 {
-			// we'll address this piece while iterating over the V0 pieces below.
+			// we'll address this piece while iterating over the V0 pieces.
 			return nil
 		}
 		pieceAccess, err := newStoredPieceAccess(store, blobInfo)
@@ -507,11 +618,69 @@ func (store *Store) WalkSatellitePieces(ctx context.Context, satellite storj.Nod
 			return nil //nolint: nilerr // we ignore other files
 		}
 		return walkFunc(pieceAccess)
+	}
+}
+
+// StatResult is the result of a single stat of StatMany.
+type StatResult struct {
+	Info storage.BlobInfo
+	// NotFound is set when the blob does not exist, Info is nil in that case.
+	NotFound bool
+}
+
+// statManyConcurrency is the number of concurrent stats of StatMany. Multiple
+// outstanding requests allow the disk to reorder them.
+const statManyConcurrency = 8
+
+// StatMany looks up the disk metadata of the blobs. The results are in the
+// order of refs, and missing blobs are marked as not found instead of failing
+// the whole batch. The stats are done in the order of the blob keys, so that
+// blobs in the same directory are looked up together.
+func (store *Store) StatMany(ctx context.Context, refs []storage.BlobRef) (_ []StatResult, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	order := make([]int, len(refs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, k int) bool {
+		a, b := refs[order[i]], refs[order[k]]
+		if c := bytes.Compare(a.Namespace, b.Namespace); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(a.Key, b.Key) < 0
 	})
-	if err == nil && store.v0PieceInfo != nil {
-		err = store.v0PieceInfo.WalkSatelliteV0Pieces(ctx, store.blobs, satellite, walkFunc)
+
+	results := make([]StatResult, len(refs))
+	limiter := sync2.NewLimiter(statManyConcurrency)
+
+	var mu sync.Mutex
+	var errlist errs.Group
+	for _, index := range order {
+		index := index
+		started := limiter.Go(ctx, func() {
+			info, err := store.blobs.Stat(ctx, refs[index])
+			switch {
+			case err == nil:
+				results[index].Info = info
+			case errs.IsFunc(err, os.IsNotExist):
+				results[index].NotFound = true
+			default:
+				mu.Lock()
+				errlist.Add(err)
+				mu.Unlock()
+			}
+		})
+		if !started {
+			break
+		}
 	}
-	return err
+	limiter.Wait()
+
+	if err := errs.Combine(errlist.Err(), ctx.Err()); err != nil {
+		return nil, Error.Wrap(err)
+	}
+	return results, nil
 }
 
 // GetExpired gets piece IDs that are expired and were created before the given time.
@@ -532,6 +701,15 @@ func (store *Store) GetExpired(ctx context.Context, expiredAt time.Time, limit i
 	return expired, nil
 }
 
+// GetExpiringBySatellite gets the IDs of the V1 pieces of the satellite that expire or have
+// expired before the given time.
+func (store *Store) GetExpiringBySatellite(ctx context.Context, satellite storj.NodeID, expiresBefore time.Time) (_ []storj.PieceID, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	pieceIDs, err := store.expirationInfo.GetExpiringBySatellite(ctx, satellite, expiresBefore)
+	return pieceIDs, Error.Wrap(err)
+}
+
 // SetExpiration records an expiration time for the specified piece ID owned by the specified satellite.
 func (store *Store) SetExpiration(ctx context.Context, satellite storj.NodeID, pieceID storj.PieceID, expiresAt time.Time) (err error) {
 	return store.expirationInfo.SetExpiration(ctx, satellite, pieceID, expiresAt)
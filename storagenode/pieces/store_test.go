@@ -16,8 +16,11 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 
+	"storj.io/common/bloomfilter"
 	"storj.io/common/identity/testidentity"
 	"storj.io/common/memory"
 	"storj.io/common/pb"
@@ -27,10 +30,12 @@ import (
 	"storj.io/common/storj"
 	"storj.io/common/testcontext"
 	"storj.io/common/testrand"
+	"storj.io/storj/private/testplanet"
 	"storj.io/storj/storage"
 	"storj.io/storj/storage/filestore"
 	"storj.io/storj/storagenode"
 	"storj.io/storj/storagenode/pieces"
+	"storj.io/storj/storagenode/retain"
 	"storj.io/storj/storagenode/storagenodedb/storagenodedbtest"
 	"storj.io/storj/storagenode/trust"
 )
@@ -877,3 +882,190 @@ func TestOverwriteV0WithV1(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestStatMany(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	dir, err := filestore.NewDir(zaptest.NewLogger(t), ctx.Dir("pieces"))
+	require.NoError(t, err)
+
+	blobs := filestore.New(zaptest.NewLogger(t), dir, filestore.DefaultConfig)
+	defer ctx.Check(blobs.Close)
+
+	store := pieces.NewStore(zaptest.NewLogger(t), blobs, nil, nil, nil, pieces.DefaultConfig)
+
+	satelliteID := testrand.NodeID()
+	const numPieces = 20
+
+	// every other piece is missing.
+	var refs []storage.BlobRef
+	for i := 0; i < numPieces; i++ {
+		pieceID := testrand.PieceID()
+		if i%2 == 0 {
+			writeAPiece(ctx, t, store, satelliteID, pieceID, testrand.Bytes(memory.KiB), time.Now(), nil, filestore.FormatV1)
+		}
+		refs = append(refs, storage.BlobRef{
+			Namespace: satelliteID.Bytes(),
+			Key:       pieceID.Bytes(),
+		})
+	}
+
+	results, err := store.StatMany(ctx, refs)
+	require.NoError(t, err)
+	require.Len(t, results, numPieces)
+	for i, result := range results {
+		if i%2 == 0 {
+			require.False(t, result.NotFound)
+			require.Equal(t, refs[i], result.Info.BlobRef())
+		} else {
+			require.True(t, result.NotFound)
+			require.Nil(t, result.Info)
+		}
+	}
+
+	results, err = store.StatMany(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func BenchmarkStatMany(b *testing.B) {
+	ctx := testcontext.New(b)
+	defer ctx.Cleanup()
+
+	dir, err := filestore.NewDir(zap.NewNop(), ctx.Dir("pieces"))
+	require.NoError(b, err)
+	blobs := filestore.New(zap.NewNop(), dir, filestore.DefaultConfig)
+	defer ctx.Check(blobs.Close)
+
+	store := pieces.NewStore(zap.NewNop(), blobs, nil, nil, nil, pieces.DefaultConfig)
+
+	satelliteID := testrand.NodeID()
+	const numPieces = 10000
+	refs := make([]storage.BlobRef, numPieces)
+	for i := range refs {
+		pieceID := testrand.PieceID()
+		writeAPiece(ctx, b, store, satelliteID, pieceID, testrand.Bytes(memory.B), time.Now(), nil, filestore.FormatV1)
+		refs[i] = storage.BlobRef{
+			Namespace: satelliteID.Bytes(),
+			Key:       pieceID.Bytes(),
+		}
+	}
+	b.ResetTimer()
+
+	b.Run("Stat", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, ref := range refs {
+				_, err := blobs.Stat(ctx, ref)
+				require.NoError(b, err)
+			}
+		}
+	})
+
+	b.Run("StatMany", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := store.StatMany(ctx, refs)
+			require.NoError(b, err)
+		}
+	})
+}
+
+func TestRestoreTrashVerified(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 1, UplinkCount: 1,
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		satellite := planet.Satellites[0]
+		node := planet.StorageNodes[0]
+		upl := planet.Uplinks[0]
+
+		data := testrand.Bytes(8 * memory.KiB)
+		require.NoError(t, upl.Upload(ctx, satellite, "testbucket", "test/path", data))
+
+		// a retain request that keeps nothing moves all pieces to the trash.
+		summaries := make(chan retain.Summary, 1)
+		unsubscribe := node.Storage2.RetainService.Subscribe(func(summary retain.Summary) {
+			summaries <- summary
+		})
+		defer unsubscribe()
+		require.True(t, node.Storage2.RetainService.Queue(retain.Request{
+			SatelliteID:   satellite.ID(),
+			CreatedBefore: time.Now().Add(time.Hour),
+			Filter:        bloomfilter.NewOptimal(1, 0.1),
+		}))
+		summary := <-summaries
+		require.NoError(t, summary.Err)
+		require.NotZero(t, summary.PiecesDeleted)
+
+		_, err := upl.Download(ctx, satellite, "testbucket", "test/path")
+		require.Error(t, err)
+
+		var calls int64
+		totals, err := node.Storage2.Store.RestoreTrashVerified(ctx, satellite.ID(), func(progress pieces.RestoreProgress) {
+			calls++
+			require.Equal(t, calls, progress.PiecesRestored)
+		})
+		require.NoError(t, err)
+		require.Equal(t, summary.PiecesDeleted, totals.PiecesRestored)
+		require.Equal(t, calls, totals.PiecesRestored)
+		require.NotZero(t, totals.BytesRestored)
+		require.Zero(t, totals.Collisions)
+		require.Zero(t, totals.PiecesFailed)
+
+		downloaded, err := upl.Download(ctx, satellite, "testbucket", "test/path")
+		require.NoError(t, err)
+		require.Equal(t, data, downloaded)
+
+		// the trash copies are removed.
+		err = node.DB.Pieces().WalkTrash(ctx, satellite.ID().Bytes(), func(storage.BlobInfo) error {
+			return errs.New("trash is not empty")
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestRestoreTrashVerifiedCollision(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		dir, err := filestore.NewDir(zaptest.NewLogger(t), ctx.Dir("pieces"))
+		require.NoError(t, err)
+		blobs := filestore.New(zaptest.NewLogger(t), dir, filestore.DefaultConfig)
+		defer ctx.Check(blobs.Close)
+
+		store := pieces.NewStore(zaptest.NewLogger(t), blobs, nil, db.PieceExpirationDB(), nil, pieces.DefaultConfig)
+
+		satelliteID := testrand.NodeID()
+		trashed, collided := testrand.PieceID(), testrand.PieceID()
+		writePiece := func(pieceID storj.PieceID, data []byte) {
+			w, err := store.Writer(ctx, satelliteID, pieceID)
+			require.NoError(t, err)
+			_, err = w.Write(data)
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{}))
+		}
+		for _, pieceID := range []storj.PieceID{trashed, collided} {
+			writePiece(pieceID, testrand.Bytes(memory.KiB))
+			require.NoError(t, store.Trash(ctx, satelliteID, pieceID))
+		}
+
+		// the collided piece is uploaded again while it is in the trash.
+		live := testrand.Bytes(2 * memory.KiB)
+		writePiece(collided, live)
+
+		totals, err := store.RestoreTrashVerified(ctx, satelliteID, nil)
+		require.NoError(t, err)
+		require.Equal(t, pieces.RestoreProgress{
+			PiecesRestored: 1,
+			BytesRestored:  memory.KiB.Int64() + pieces.V1PieceHeaderReservedArea,
+			Collisions:     1,
+		}, totals)
+
+		// the live piece is kept.
+		r, err := store.Reader(ctx, satelliteID, collided)
+		require.NoError(t, err)
+		defer ctx.Check(r.Close)
+		require.Equal(t, int64(len(live)), r.Size())
+
+		r2, err := store.Reader(ctx, satelliteID, trashed)
+		require.NoError(t, err)
+		require.NoError(t, r2.Close())
+	})
+}
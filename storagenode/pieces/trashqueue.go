@@ -0,0 +1,193 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package pieces
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/fpath"
+	"storj.io/common/storj"
+	"storj.io/common/sync2"
+)
+
+// maxTrashFailures is the number of the most recent failures that are kept in
+// the failure list.
+const maxTrashFailures = 1000
+
+// TrashQueueConfig configures a TrashQueue.
+type TrashQueueConfig struct {
+	// Workers is the number of pieces that are moved to the trash concurrently.
+	Workers int
+	// Attempts is how often moving a piece is attempted before it fails.
+	Attempts int
+	// Backoff is the delay before the first retry, it is doubled for every
+	// further retry.
+	Backoff time.Duration
+	// FailuresPath is the file of the failure list, when set.
+	FailuresPath string
+}
+
+// TrashFailure is a piece that could not be moved to the trash.
+type TrashFailure struct {
+	SatelliteID storj.NodeID  `json:"satelliteID"`
+	PieceID     storj.PieceID `json:"pieceID"`
+	Failed      time.Time     `json:"failed"`
+	Attempts    int           `json:"attempts"`
+	Error       string        `json:"error"`
+}
+
+// TrashQueue moves pieces to the trash in the background. Moves that fail are
+// retried with backoff, and pieces that fail every attempt are recorded in a
+// failure list that the operator can inspect.
+//
+// architecture: Worker
+type TrashQueue struct {
+	log    *zap.Logger
+	store  *Store
+	config TrashQueueConfig
+
+	limiter *sync2.Limiter
+
+	failuresMu sync.Mutex
+	failures   []TrashFailure
+}
+
+// NewTrashQueue creates a new trash queue and loads the failure list.
+func NewTrashQueue(log *zap.Logger, store *Store, config TrashQueueConfig) *TrashQueue {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.Attempts < 1 {
+		config.Attempts = 1
+	}
+
+	queue := &TrashQueue{
+		log:     log,
+		store:   store,
+		config:  config,
+		limiter: sync2.NewLimiter(config.Workers),
+	}
+	if err := queue.loadFailures(); err != nil {
+		log.Warn("failed to load trash failure list", zap.String("path", config.FailuresPath), zap.Error(err))
+	}
+	return queue
+}
+
+// Enqueue schedules the piece to be moved to the trash. done is called with
+// nil once it is moved, or with the last error when every attempt failed or
+// ctx was canceled in between. Enqueue blocks while all workers are busy and
+// returns false without calling done when ctx is canceled before the piece is
+// scheduled.
+func (queue *TrashQueue) Enqueue(ctx context.Context, satelliteID storj.NodeID, pieceID storj.PieceID, done func(error)) bool {
+	return queue.limiter.Go(ctx, func() {
+		done(queue.trash(ctx, satelliteID, pieceID))
+	})
+}
+
+// Wait waits for all scheduled pieces to be processed.
+func (queue *TrashQueue) Wait() {
+	queue.limiter.Wait()
+}
+
+// trash moves the piece to the trash with retries.
+func (queue *TrashQueue) trash(ctx context.Context, satelliteID storj.NodeID, pieceID storj.PieceID) (err error) {
+	defer mon.Task()(&ctx, satelliteID)(&err)
+
+	backoff := queue.config.Backoff
+	for attempt := 1; ; attempt++ {
+		err = queue.store.Trash(ctx, satelliteID, pieceID)
+		if err == nil {
+			return nil
+		}
+		// a piece that disappeared in the meantime won't reappear.
+		if errs.IsFunc(err, os.IsNotExist) {
+			mon.Counter("trash_queue_missing").Inc(1)
+			return err
+		}
+		if attempt >= queue.config.Attempts {
+			mon.Counter("trash_queue_failed").Inc(1)
+			queue.log.Error("failed to move piece to trash",
+				zap.Stringer("Satellite ID", satelliteID),
+				zap.Stringer("Piece ID", pieceID),
+				zap.Int("Attempts", attempt),
+				zap.Error(err))
+			queue.recordFailure(TrashFailure{
+				SatelliteID: satelliteID,
+				PieceID:     pieceID,
+				Failed:      time.Now(),
+				Attempts:    attempt,
+				Error:       err.Error(),
+			})
+			return err
+		}
+
+		mon.Counter("trash_queue_retries").Inc(1)
+		queue.log.Debug("retrying to move piece to trash",
+			zap.Stringer("Satellite ID", satelliteID),
+			zap.Stringer("Piece ID", pieceID),
+			zap.Int("Attempt", attempt),
+			zap.Error(err))
+		if !sync2.Sleep(ctx, backoff) {
+			return errs.Combine(err, ctx.Err())
+		}
+		backoff *= 2
+	}
+}
+
+// Failures returns the most recent pieces that could not be moved to the
+// trash, oldest first.
+func (queue *TrashQueue) Failures() []TrashFailure {
+	queue.failuresMu.Lock()
+	defer queue.failuresMu.Unlock()
+	return append([]TrashFailure(nil), queue.failures...)
+}
+
+// recordFailure adds the failure to the list and persists the list.
+func (queue *TrashQueue) recordFailure(failure TrashFailure) {
+	queue.failuresMu.Lock()
+	defer queue.failuresMu.Unlock()
+
+	queue.failures = append(queue.failures, failure)
+	if len(queue.failures) > maxTrashFailures {
+		queue.failures = append([]TrashFailure(nil), queue.failures[len(queue.failures)-maxTrashFailures:]...)
+	}
+
+	if queue.config.FailuresPath == "" {
+		return
+	}
+	data, err := json.MarshalIndent(queue.failures, "", "\t")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(queue.config.FailuresPath), 0700)
+	}
+	if err == nil {
+		err = fpath.AtomicWriteFile(queue.config.FailuresPath, data, 0600)
+	}
+	if err != nil {
+		queue.log.Warn("failed to persist trash failure list", zap.String("path", queue.config.FailuresPath), zap.Error(err))
+	}
+}
+
+// loadFailures reads the failure list, if there is one.
+func (queue *TrashQueue) loadFailures() error {
+	if queue.config.FailuresPath == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(queue.config.FailuresPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return Error.Wrap(err)
+	}
+	return Error.Wrap(json.Unmarshal(data, &queue.failures))
+}
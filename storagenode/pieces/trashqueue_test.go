@@ -0,0 +1,153 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package pieces_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/memory"
+	"storj.io/common/pb"
+	"storj.io/common/storj"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/storj/storage"
+	"storj.io/storj/storage/filestore"
+	"storj.io/storj/storagenode"
+	"storj.io/storj/storagenode/pieces"
+	"storj.io/storj/storagenode/storagenodedb/storagenodedbtest"
+)
+
+func TestTrashQueue(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		dir, err := filestore.NewDir(zaptest.NewLogger(t), ctx.Dir("pieces"))
+		require.NoError(t, err)
+
+		blobs := &flakyBlobs{
+			Blobs:    filestore.New(zaptest.NewLogger(t), dir, filestore.DefaultConfig),
+			failures: map[storj.PieceID]int{},
+		}
+		defer ctx.Check(blobs.Close)
+
+		store := pieces.NewStore(zaptest.NewLogger(t), blobs, nil, db.PieceExpirationDB(), nil, pieces.DefaultConfig)
+
+		satelliteID := testrand.NodeID()
+		healthy, flaky, broken := testrand.PieceID(), testrand.PieceID(), testrand.PieceID()
+		for _, pieceID := range []storj.PieceID{healthy, flaky, broken} {
+			w, err := store.Writer(ctx, satelliteID, pieceID)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(memory.KB))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{}))
+		}
+
+		const attempts = 3
+		blobs.failures[flaky] = attempts - 1
+		blobs.failures[broken] = attempts
+
+		failuresPath := filepath.Join(ctx.Dir("retain"), "trash-failures.json")
+		config := pieces.TrashQueueConfig{
+			Workers:      2,
+			Attempts:     attempts,
+			Backoff:      time.Millisecond,
+			FailuresPath: failuresPath,
+		}
+		queue := pieces.NewTrashQueue(zaptest.NewLogger(t), store, config)
+
+		var mu sync.Mutex
+		results := map[storj.PieceID]error{}
+		for _, pieceID := range []storj.PieceID{healthy, flaky, broken} {
+			pieceID := pieceID
+			require.True(t, queue.Enqueue(ctx, satelliteID, pieceID, func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				results[pieceID] = err
+			}))
+		}
+		queue.Wait()
+
+		require.Len(t, results, 3)
+		require.NoError(t, results[healthy])
+		require.NoError(t, results[flaky])
+		require.Error(t, results[broken])
+
+		// the healthy and the flaky piece are eventually moved to the trash.
+		for _, pieceID := range []storj.PieceID{healthy, flaky} {
+			_, err := store.Reader(ctx, satelliteID, pieceID)
+			require.Error(t, err)
+		}
+		r, err := store.Reader(ctx, satelliteID, broken)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+
+		failures := queue.Failures()
+		require.Len(t, failures, 1)
+		require.Equal(t, satelliteID, failures[0].SatelliteID)
+		require.Equal(t, broken, failures[0].PieceID)
+		require.Equal(t, attempts, failures[0].Attempts)
+		require.Contains(t, failures[0].Error, "injected failure")
+
+		// the failure list is persisted for the operator and loaded again.
+		data, err := ioutil.ReadFile(failuresPath)
+		require.NoError(t, err)
+		var persisted []pieces.TrashFailure
+		require.NoError(t, json.Unmarshal(data, &persisted))
+		require.Len(t, persisted, 1)
+		require.Equal(t, broken, persisted[0].PieceID)
+
+		reloaded := pieces.NewTrashQueue(zaptest.NewLogger(t), store, config)
+		require.Len(t, reloaded.Failures(), 1)
+		require.Equal(t, broken, reloaded.Failures()[0].PieceID)
+
+		// a canceled context stops the retries.
+		blobs.failures[broken] = attempts
+		canceled, cancel := context.WithCancel(ctx)
+		config.Backoff = time.Hour
+		slow := pieces.NewTrashQueue(zaptest.NewLogger(t), store, config)
+		done := make(chan error, 1)
+		require.True(t, slow.Enqueue(canceled, satelliteID, broken, func(err error) {
+			done <- err
+		}))
+		cancel()
+		require.Error(t, <-done)
+		slow.Wait()
+		require.Len(t, slow.Failures(), 1)
+	})
+}
+
+// flakyBlobs fails to move blobs to the trash a configured number of times.
+type flakyBlobs struct {
+	storage.Blobs
+
+	mu       sync.Mutex
+	failures map[storj.PieceID]int
+}
+
+func (blobs *flakyBlobs) Trash(ctx context.Context, ref storage.BlobRef) error {
+	pieceID, err := storj.PieceIDFromBytes(ref.Key)
+	if err != nil {
+		return err
+	}
+
+	blobs.mu.Lock()
+	remaining := blobs.failures[pieceID]
+	if remaining > 0 {
+		blobs.failures[pieceID] = remaining - 1
+	}
+	blobs.mu.Unlock()
+
+	if remaining > 0 {
+		return errs.New("injected failure")
+	}
+	return blobs.Blobs.Trash(ctx, ref)
+}
@@ -0,0 +1,191 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package pieces
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"storj.io/common/memory"
+	"storj.io/common/pkcrypto"
+	"storj.io/common/storj"
+	"storj.io/storj/storage"
+)
+
+// verifyChunkSize is the maximum number of bytes read from a piece at once
+// while the pieces are verified.
+const verifyChunkSize = 32 * memory.KiB
+
+// verifyConfidenceZ is the z-score of the 95% confidence interval of the
+// estimated corruption rate.
+const verifyConfidenceZ = 1.96
+
+// VerifyConfig defines how the pieces of a satellite are sampled and verified.
+type VerifyConfig struct {
+	// Sample is the number of pieces that are verified.
+	Sample int
+	// DeepFraction is the fraction of the sampled pieces whose content is
+	// verified against the hash stored with them.
+	DeepFraction float64
+	// RateLimit is the maximum number of bytes per second that are read from
+	// the sampled pieces, 0 is unlimited.
+	RateLimit memory.Size
+}
+
+// VerifyResult are the totals of verifying a sample of the pieces of a
+// satellite.
+type VerifyResult struct {
+	// Pieces is the number of pieces the sample was taken from.
+	Pieces int64
+	// Verified are the sampled pieces that were verified so far.
+	Verified int64
+	// DeepVerified are the verified pieces whose content was checked.
+	DeepVerified int64
+	// Corrupt are the verified pieces that could not be opened, whose header
+	// is not readable or whose content doesn't match the stored hash.
+	Corrupt int64
+}
+
+// CorruptionRate returns the estimated fraction of corrupt pieces with the
+// bounds of its 95% confidence interval, which is the Wilson score interval
+// of the verified pieces. Pieces with corrupt content are only found by deep
+// verification, so the rate is an underestimate when not all pieces are
+// verified deeply.
+func (result VerifyResult) CorruptionRate() (rate, lower, upper float64) {
+	if result.Verified == 0 {
+		return 0, 0, 1
+	}
+	n := float64(result.Verified)
+	rate = float64(result.Corrupt) / n
+
+	z2 := verifyConfidenceZ * verifyConfidenceZ
+	denominator := 1 + z2/n
+	center := (rate + z2/(2*n)) / denominator
+	spread := verifyConfidenceZ * math.Sqrt(rate*(1-rate)/n+z2/(4*n*n)) / denominator
+	return rate, math.Max(0, center-spread), math.Min(1, center+spread)
+}
+
+// sampledPiece is a piece that was selected for verification.
+type sampledPiece struct {
+	pieceID storj.PieceID
+	format  storage.FormatVersion
+}
+
+// VerifySample verifies a uniform sample of the pieces of the satellite.
+// Every sampled piece is checked to open and to have a readable header, and
+// the content of the configured fraction of them is checked against the hash
+// stored with the piece. progress, when set, is called after every piece.
+func (store *Store) VerifySample(ctx context.Context, satelliteID storj.NodeID, config VerifyConfig, progress func(VerifyResult)) (result VerifyResult, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// reservoir sampling selects every piece with the same probability in a
+	// single walk, without knowing the number of pieces in advance.
+	sample := make([]sampledPiece, 0, config.Sample)
+	err = store.WalkSatellitePieces(ctx, satelliteID, func(access StoredPieceAccess) error {
+		piece := sampledPiece{pieceID: access.PieceID(), format: access.StorageFormatVersion()}
+		result.Pieces++
+		if len(sample) < config.Sample {
+			sample = append(sample, piece)
+		} else if i := random.Int63n(result.Pieces); i < int64(config.Sample) {
+			sample[i] = piece
+		}
+		return nil
+	})
+	if err != nil {
+		return result, Error.Wrap(err)
+	}
+
+	limiter := rate.NewLimiter(rate.Inf, verifyChunkSize.Int())
+	if config.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(config.RateLimit), verifyChunkSize.Int())
+	}
+
+	for _, piece := range sample {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		deep := random.Float64() < config.DeepFraction
+		err := store.verifyPiece(ctx, satelliteID, piece, deep, limiter)
+		result.Verified++
+		if deep {
+			result.DeepVerified++
+		}
+		if err != nil {
+			if errs.Is(err, context.Canceled) {
+				return result, err
+			}
+			result.Corrupt++
+			store.log.Warn("corrupt piece",
+				zap.Stringer("Satellite ID", satelliteID),
+				zap.Stringer("Piece ID", piece.pieceID),
+				zap.Error(err))
+		}
+
+		if progress != nil {
+			progress(result)
+		}
+	}
+	return result, nil
+}
+
+// verifyPiece checks that the piece opens and that its header is readable,
+// and when deep that its content matches the stored hash.
+func (store *Store) verifyPiece(ctx context.Context, satelliteID storj.NodeID, piece sampledPiece, deep bool, limiter *rate.Limiter) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	reader, err := store.ReaderWithStorageFormat(ctx, satelliteID, piece.pieceID, piece.format)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errs.Combine(err, reader.Close()) }()
+
+	if err := limiter.WaitN(ctx, V1PieceHeaderReservedArea); err != nil {
+		return err
+	}
+	hash, _, err := store.GetHashAndLimit(ctx, satelliteID, piece.pieceID, reader)
+	if err != nil {
+		return err
+	}
+	if !deep {
+		return nil
+	}
+
+	content := pkcrypto.NewHash()
+	if _, err := io.Copy(content, &limitedReader{ctx: ctx, reader: reader, limiter: limiter}); err != nil {
+		return err
+	}
+	if !bytes.Equal(content.Sum(nil), hash.Hash) {
+		return Error.New("content doesn't match the stored hash")
+	}
+	return nil
+}
+
+// limitedReader reads at most as many bytes per second as the limiter allows.
+type limitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *rate.Limiter
+}
+
+// Read reads at most one chunk after waiting for the limiter.
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+	if err := r.limiter.WaitN(r.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
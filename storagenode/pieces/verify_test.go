@@ -0,0 +1,121 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package pieces_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/memory"
+	"storj.io/common/pb"
+	"storj.io/common/storj"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/storj/storage"
+	"storj.io/storj/storage/filestore"
+	"storj.io/storj/storagenode"
+	"storj.io/storj/storagenode/pieces"
+	"storj.io/storj/storagenode/storagenodedb/storagenodedbtest"
+)
+
+func TestVerifySample(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		dir, err := filestore.NewDir(zaptest.NewLogger(t), ctx.Dir("pieces"))
+		require.NoError(t, err)
+		blobs := filestore.New(zaptest.NewLogger(t), dir, filestore.DefaultConfig)
+		defer ctx.Check(blobs.Close)
+
+		store := pieces.NewStore(zaptest.NewLogger(t), blobs, db.V0PieceInfo(), db.PieceExpirationDB(), nil, pieces.DefaultConfig)
+
+		const (
+			numPieces     = 1000
+			corruptHeader = 50
+			corruptData   = 50
+		)
+
+		satelliteID := testrand.NodeID()
+		pieceIDs := make([]storj.PieceID, numPieces)
+		for i := range pieceIDs {
+			pieceIDs[i] = testrand.PieceID()
+			w, err := store.Writer(ctx, satelliteID, pieceIDs[i])
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(256 * memory.B))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{Hash: w.Hash()}))
+		}
+
+		// corrupt overwrites the piece at the offset.
+		corrupt := func(pieceID storj.PieceID, offset int64, data []byte) {
+			info, err := blobs.Stat(ctx, storage.BlobRef{Namespace: satelliteID.Bytes(), Key: pieceID.Bytes()})
+			require.NoError(t, err)
+			path, err := info.FullPath(ctx)
+			require.NoError(t, err)
+
+			file, err := os.OpenFile(path, os.O_WRONLY, 0)
+			require.NoError(t, err)
+			_, err = file.WriteAt(data, offset)
+			require.NoError(t, err)
+			require.NoError(t, file.Close())
+		}
+		for _, pieceID := range pieceIDs[:corruptHeader] {
+			// the header framing claims an impossible size.
+			corrupt(pieceID, 0, []byte{0xFF, 0xFF})
+		}
+		for _, pieceID := range pieceIDs[corruptHeader : corruptHeader+corruptData] {
+			corrupt(pieceID, pieces.V1PieceHeaderReservedArea, testrand.Bytes(16))
+		}
+
+		for _, tt := range []struct {
+			deep     float64
+			expected float64
+		}{
+			// corrupt content is only found by deep verification.
+			{deep: 0, expected: float64(corruptHeader) / numPieces},
+			{deep: 1, expected: float64(corruptHeader+corruptData) / numPieces},
+		} {
+			var calls int
+			result, err := store.VerifySample(ctx, satelliteID, pieces.VerifyConfig{
+				Sample:       numPieces / 2,
+				DeepFraction: tt.deep,
+				RateLimit:    10 * memory.MiB,
+			}, func(pieces.VerifyResult) { calls++ })
+			require.NoError(t, err)
+			require.EqualValues(t, numPieces, result.Pieces)
+			require.EqualValues(t, numPieces/2, result.Verified)
+			require.EqualValues(t, tt.deep*numPieces/2, result.DeepVerified)
+			require.Equal(t, numPieces/2, calls)
+
+			rate, lower, upper := result.CorruptionRate()
+			require.InDelta(t, tt.expected, rate, 0.05, "deep %v", tt.deep)
+			require.Less(t, lower, rate)
+			require.Greater(t, upper, rate)
+		}
+
+		// the sample is capped at the number of pieces.
+		result, err := store.VerifySample(ctx, satelliteID, pieces.VerifyConfig{Sample: 2 * numPieces}, nil)
+		require.NoError(t, err)
+		require.EqualValues(t, numPieces, result.Verified)
+		require.EqualValues(t, corruptHeader, result.Corrupt)
+	})
+}
+
+func TestVerifyResultCorruptionRate(t *testing.T) {
+	rate, lower, upper := pieces.VerifyResult{}.CorruptionRate()
+	require.Zero(t, rate)
+	require.Zero(t, lower)
+	require.Equal(t, 1.0, upper)
+
+	rate, lower, upper = pieces.VerifyResult{Verified: 10000}.CorruptionRate()
+	require.Zero(t, rate)
+	require.InDelta(t, 0, lower, 1e-9)
+	require.InDelta(t, 0.0004, upper, 0.0001)
+
+	rate, lower, upper = pieces.VerifyResult{Verified: 1000, Corrupt: 100}.CorruptionRate()
+	require.Equal(t, 0.1, rate)
+	require.InDelta(t, 0.083, lower, 0.001)
+	require.InDelta(t, 0.120, upper, 0.001)
+}
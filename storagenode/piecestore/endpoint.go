@@ -16,7 +16,6 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 
-	"storj.io/common/bloomfilter"
 	"storj.io/common/context2"
 	"storj.io/common/errs2"
 	"storj.io/common/identity"
@@ -763,29 +762,41 @@ func (endpoint *Endpoint) Retain(ctx context.Context, retainReq *pb.RetainReques
 
 	peer, err := identity.PeerIdentityFromContext(ctx)
 	if err != nil {
+		mon.Meter("retain_rejected", monkit.NewSeriesTag("reason", "unauthenticated")).Mark(1)
 		return nil, rpcstatus.Wrap(rpcstatus.Unauthenticated, err)
 	}
 
 	err = endpoint.trust.VerifySatelliteID(ctx, peer.ID)
 	if err != nil {
-		return nil, rpcstatus.Errorf(rpcstatus.PermissionDenied, "retain called with untrusted ID")
+		endpoint.rejectRetain(peer.ID, "untrusted", err)
+		return nil, rpcstatus.Errorf(rpcstatus.PermissionDenied, "retain called with untrusted ID %s", peer.ID)
 	}
 
-	filter, err := bloomfilter.NewFromBytes(retainReq.GetFilter())
+	// the size is checked before decoding, so that oversized filters are
+	// never allocated.
+	err = endpoint.retain.Check(retainReq.GetCreationDate(), len(retainReq.GetFilter()))
 	if err != nil {
+		reason := "future_creation"
+		if retain.ErrFilterTooLarge.Has(err) {
+			reason = "too_large"
+		}
+		endpoint.rejectRetain(peer.ID, reason, err)
+		return nil, rpcstatus.Wrap(rpcstatus.InvalidArgument, err)
+	}
+
+	// the request references the received filter, which is never copied.
+	req, err := retain.DecodeRequest(peer.ID, retainReq.GetCreationDate(), retainReq.GetFilter())
+	if err != nil {
+		endpoint.rejectRetain(peer.ID, "invalid_filter", err)
 		return nil, rpcstatus.Wrap(rpcstatus.InvalidArgument, err)
 	}
-	filterHashCount, _ := filter.Parameters()
-	mon.IntVal("retain_filter_size").Observe(filter.Size())
+	filterHashCount, _ := req.Filter.Parameters()
+	mon.IntVal("retain_filter_size").Observe(req.Filter.Size())
 	mon.IntVal("retain_filter_hash_count").Observe(int64(filterHashCount))
 	mon.IntVal("retain_creation_date").Observe(retainReq.CreationDate.Unix())
 
 	// the queue function will update the created before time based on the configurable retain buffer
-	queued := endpoint.retain.Queue(retain.Request{
-		SatelliteID:   peer.ID,
-		CreatedBefore: retainReq.GetCreationDate(),
-		Filter:        filter,
-	})
+	queued := endpoint.retain.Queue(req)
 	if !queued {
 		endpoint.log.Debug("Retain job not queued for satellite", zap.Stringer("Satellite ID", peer.ID))
 	}
@@ -793,6 +804,15 @@ func (endpoint *Endpoint) Retain(ctx context.Context, retainReq *pb.RetainReques
 	return &pb.RetainResponse{}, nil
 }
 
+// rejectRetain counts and logs a retain request that is not queued.
+func (endpoint *Endpoint) rejectRetain(satelliteID storj.NodeID, reason string, err error) {
+	mon.Meter("retain_rejected", monkit.NewSeriesTag("reason", reason)).Mark(1)
+	endpoint.log.Warn("retain request rejected",
+		zap.Stringer("Satellite ID", satelliteID),
+		zap.String("Reason", reason),
+		zap.Error(err))
+}
+
 // TestLiveRequestCount returns the current number of live requests.
 func (endpoint *Endpoint) TestLiveRequestCount() int32 {
 	return atomic.LoadInt32(&endpoint.liveRequests)
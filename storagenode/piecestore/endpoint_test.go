@@ -16,10 +16,12 @@ import (
 	"github.com/zeebo/errs"
 	"go.uber.org/zap"
 
+	"storj.io/common/bloomfilter"
 	"storj.io/common/errs2"
 	"storj.io/common/memory"
 	"storj.io/common/pb"
 	"storj.io/common/pkcrypto"
+	"storj.io/common/rpc"
 	"storj.io/common/rpc/rpcstatus"
 	"storj.io/common/signing"
 	"storj.io/common/storj"
@@ -784,3 +786,70 @@ func downloadPiece(
 	n, err := downloader.Read(buffer)
 	return buffer[:n], err
 }
+
+func TestRetainRejected(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 1, StorageNodeCount: 1, UplinkCount: 1,
+		Reconfigure: testplanet.Reconfigure{
+			StorageNode: func(index int, config *storagenode.Config) {
+				config.Retain.MaxFilterSize = 1 * memory.KiB
+				config.Retain.MaxFutureCreation = time.Hour
+			},
+		},
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		node := planet.StorageNodes[0]
+
+		retainWith := func(dialer rpc.Dialer, req *pb.RetainRequest) error {
+			conn, err := dialer.DialNodeURL(ctx, node.NodeURL())
+			require.NoError(t, err)
+			defer ctx.Check(conn.Close)
+
+			_, err = pb.NewDRPCPiecestoreClient(conn).Retain(ctx, req)
+			return err
+		}
+
+		small := bloomfilter.NewOptimal(10, 0.1).Bytes()
+		large := bloomfilter.NewOptimal(10000, 0.1).Bytes()
+		require.Less(t, len(small), 1*memory.KiB.Int())
+		require.Greater(t, len(large), 1*memory.KiB.Int())
+
+		t.Run("untrusted", func(t *testing.T) {
+			err := retainWith(planet.Uplinks[0].Dialer, &pb.RetainRequest{
+				CreationDate: time.Now(),
+				Filter:       small,
+			})
+			require.Error(t, err)
+			require.Equal(t, rpcstatus.PermissionDenied, rpcstatus.Code(err))
+		})
+
+		t.Run("future creation", func(t *testing.T) {
+			err := retainWith(planet.Satellites[0].Dialer, &pb.RetainRequest{
+				CreationDate: time.Now().Add(2 * time.Hour),
+				Filter:       small,
+			})
+			require.Error(t, err)
+			require.Equal(t, rpcstatus.InvalidArgument, rpcstatus.Code(err))
+		})
+
+		t.Run("too large", func(t *testing.T) {
+			err := retainWith(planet.Satellites[0].Dialer, &pb.RetainRequest{
+				CreationDate: time.Now(),
+				Filter:       large,
+			})
+			require.Error(t, err)
+			require.Equal(t, rpcstatus.InvalidArgument, rpcstatus.Code(err))
+		})
+
+		// none of the rejected requests were queued.
+		require.Empty(t, node.Storage2.RetainService.Progress())
+
+		t.Run("accepted", func(t *testing.T) {
+			err := retainWith(planet.Satellites[0].Dialer, &pb.RetainRequest{
+				CreationDate: time.Now(),
+				Filter:       small,
+			})
+			require.NoError(t, err)
+			require.NoError(t, node.Storage2.RetainService.WaitIdle(ctx))
+		})
+	})
+}
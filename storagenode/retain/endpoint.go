@@ -0,0 +1,89 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retain
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/identity"
+	"storj.io/common/rpc/rpcstatus"
+	"storj.io/storj/private/retainpb"
+	"storj.io/storj/storagenode/trust"
+)
+
+// Endpoint serves the status of the retain requests to the trusted satellites
+// that sent them. Every satellite only sees its own requests.
+//
+// architecture: Endpoint
+type Endpoint struct {
+	retainpb.DRPCRetainStatusUnimplementedServer
+
+	log     *zap.Logger
+	trust   *trust.Pool
+	service *Service
+}
+
+// NewEndpoint creates a new retain status endpoint.
+func NewEndpoint(log *zap.Logger, trust *trust.Pool, service *Service) *Endpoint {
+	return &Endpoint{
+		log:     log,
+		trust:   trust,
+		service: service,
+	}
+}
+
+// GetRetainStatus returns the queued and active retain requests of the calling
+// satellite, and the summaries of its last processed requests.
+func (endpoint *Endpoint) GetRetainStatus(ctx context.Context, req *retainpb.GetRetainStatusRequest) (_ *retainpb.GetRetainStatusResponse, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	peer, err := identity.PeerIdentityFromContext(ctx)
+	if err != nil {
+		return nil, rpcstatus.Wrap(rpcstatus.Unauthenticated, err)
+	}
+	if err := endpoint.trust.VerifySatelliteID(ctx, peer.ID); err != nil {
+		endpoint.log.Debug("retain status requested by untrusted peer", zap.Stringer("Node ID", peer.ID))
+		return nil, rpcstatus.Errorf(rpcstatus.PermissionDenied, "retain status requested with untrusted ID %s", peer.ID)
+	}
+
+	resp := &retainpb.GetRetainStatusResponse{}
+	for _, progress := range endpoint.service.Progress() {
+		if progress.SatelliteID != peer.ID {
+			continue
+		}
+		resp.Requests = append(resp.Requests, &retainpb.RetainRequestStatus{
+			CreationDate:   progress.CreatedBefore,
+			RangeBits:      uint32(progress.Range.Bits),
+			RangeIndex:     progress.Range.Index,
+			Received:       progress.Received,
+			Active:         progress.Active,
+			Started:        progress.Started,
+			PiecesExamined: progress.PiecesWalked,
+			PiecesTrashed:  progress.PiecesDeleted,
+			PiecesFailed:   progress.PiecesFailed,
+		})
+	}
+
+	for _, summary := range endpoint.service.Completed(peer.ID) {
+		completed := &retainpb.RetainRequestSummary{
+			CreationDate:           summary.CreatedBefore,
+			RangeBits:              uint32(summary.Range.Bits),
+			RangeIndex:             summary.Range.Index,
+			Finished:               summary.Finished,
+			PiecesExamined:         summary.PiecesWalked,
+			PiecesTrashed:          summary.PiecesDeleted,
+			PiecesFailed:           summary.PiecesFailed,
+			BytesReclaimed:         summary.BytesDeleted,
+			PiecesLeftToExpiration: summary.PiecesLeftToExpiration,
+			DurationMillis:         summary.Duration.Milliseconds(),
+		}
+		if summary.Err != nil {
+			completed.Error = summary.Err.Error()
+		}
+		resp.Completed = append(resp.Completed, completed)
+	}
+	return resp, nil
+}
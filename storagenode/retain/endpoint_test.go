@@ -0,0 +1,74 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/bloomfilter"
+	"storj.io/common/rpc"
+	"storj.io/common/rpc/rpcstatus"
+	"storj.io/common/testcontext"
+	"storj.io/storj/private/retainpb"
+	"storj.io/storj/private/testplanet"
+	"storj.io/storj/storagenode/retain"
+)
+
+func TestEndpointGetRetainStatus(t *testing.T) {
+	testplanet.Run(t, testplanet.Config{
+		SatelliteCount: 2, StorageNodeCount: 1, UplinkCount: 1,
+	}, func(t *testing.T, ctx *testcontext.Context, planet *testplanet.Planet) {
+		node := planet.StorageNodes[0]
+		satellite, other := planet.Satellites[0], planet.Satellites[1]
+		for _, satellite := range planet.Satellites {
+			satellite.GarbageCollection.Service.Loop.Pause()
+		}
+
+		summaries := make(chan retain.Summary, 1)
+		unsubscribe := node.Storage2.RetainService.Subscribe(func(summary retain.Summary) {
+			summaries <- summary
+		})
+		defer unsubscribe()
+
+		createdBefore := time.Now().Add(-time.Hour)
+		require.True(t, node.Storage2.RetainService.Queue(retain.Request{
+			SatelliteID:   satellite.ID(),
+			CreatedBefore: createdBefore,
+			Filter:        bloomfilter.NewOptimal(10, 0.1),
+		}))
+		summary := <-summaries
+		require.NoError(t, summary.Err)
+
+		getStatus := func(dialer rpc.Dialer) (*retainpb.GetRetainStatusResponse, error) {
+			conn, err := dialer.DialNodeURL(ctx, node.NodeURL())
+			require.NoError(t, err)
+			defer ctx.Check(conn.Close)
+			return retainpb.NewDRPCRetainStatusClient(conn).GetRetainStatus(ctx, &retainpb.GetRetainStatusRequest{})
+		}
+
+		// the satellite sees its processed request.
+		status, err := getStatus(satellite.Dialer)
+		require.NoError(t, err)
+		require.Empty(t, status.Requests)
+		require.Len(t, status.Completed, 1)
+		require.True(t, status.Completed[0].CreationDate.Equal(createdBefore))
+		require.Equal(t, summary.PiecesWalked, status.Completed[0].PiecesExamined)
+		require.False(t, status.Completed[0].Finished.IsZero())
+		require.Empty(t, status.Completed[0].Error)
+
+		// another satellite can't see the requests of the satellite.
+		status, err = getStatus(other.Dialer)
+		require.NoError(t, err)
+		require.Empty(t, status.Requests)
+		require.Empty(t, status.Completed)
+
+		// and untrusted peers can't see anything.
+		_, err = getStatus(planet.Uplinks[0].Dialer)
+		require.Error(t, err)
+		require.Equal(t, rpcstatus.PermissionDenied, rpcstatus.Code(err))
+	})
+}
@@ -0,0 +1,156 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retain
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/storj"
+)
+
+// historyBackupExt is appended to the path of the history to name the file
+// the history is rotated to when it exceeds the maximum size.
+const historyBackupExt = ".1"
+
+// HistoryEntry is the record of a processed retain request in the history.
+type HistoryEntry struct {
+	SatelliteID        storj.NodeID `json:"satelliteID"`
+	RangeBits          uint8        `json:"rangeBits"`
+	RangeIndex         uint32       `json:"rangeIndex"`
+	FilterCreationDate time.Time    `json:"filterCreationDate"`
+	Started            time.Time    `json:"started"`
+	Finished           time.Time    `json:"finished"`
+	PiecesExamined     int64        `json:"piecesExamined"`
+	// PiecesRetained are the examined pieces that the filter contains.
+	PiecesRetained int64 `json:"piecesRetained"`
+	PiecesTrashed  int64 `json:"piecesTrashed"`
+	// PiecesGuarded are the trashed pieces within the guard window.
+	PiecesGuarded  int64  `json:"piecesGuarded"`
+	BytesReclaimed int64  `json:"bytesReclaimed"`
+	Error          string `json:"error,omitempty"`
+}
+
+// history appends an entry for every processed retain request to a file of
+// JSON lines. The file is rotated to a single backup once it exceeds the
+// maximum size, so at most twice the maximum size is kept on disk.
+type history struct {
+	log     *zap.Logger
+	path    string
+	maxSize int64
+
+	mu sync.Mutex
+}
+
+// newHistoryEntry returns the entry for the summary of a processed request.
+func newHistoryEntry(summary Summary) HistoryEntry {
+	entry := HistoryEntry{
+		SatelliteID:        summary.SatelliteID,
+		RangeBits:          summary.Range.Bits,
+		RangeIndex:         summary.Range.Index,
+		FilterCreationDate: summary.CreatedBefore,
+		Started:            summary.Finished.Add(-summary.Duration),
+		Finished:           summary.Finished,
+		PiecesExamined:     summary.PiecesWalked,
+		PiecesRetained:     summary.PiecesWalked - summary.PiecesDeleted - summary.PiecesFailed,
+		PiecesTrashed:      summary.PiecesDeleted,
+		PiecesGuarded:      summary.PiecesGuarded,
+		BytesReclaimed:     summary.BytesDeleted,
+	}
+	if summary.Err != nil {
+		entry.Error = summary.Err.Error()
+	}
+	return entry
+}
+
+// record appends the summary to the history, when the request was processed.
+// Requests that were replaced or dropped before they started are not recorded.
+func (h *history) record(summary Summary) {
+	if errors.Is(summary.Err, ErrReplaced) || errors.Is(summary.Err, ErrShutdown) || errors.Is(summary.Err, ErrExpired) {
+		return
+	}
+	if err := h.append(newHistoryEntry(summary)); err != nil {
+		h.log.Warn("failed to record retain history",
+			zap.Stringer("Satellite ID", summary.SatelliteID),
+			zap.String("path", h.path),
+			zap.Error(err))
+	}
+}
+
+// append writes the entry as a line to the file, which is rotated first when
+// the line would make it exceed the maximum size.
+func (h *history) append(entry HistoryEntry) (err error) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	line = append(line, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if info, err := os.Stat(h.path); err == nil && h.maxSize > 0 && info.Size()+int64(len(line)) > h.maxSize {
+		if err := os.Rename(h.path, h.path+historyBackupExt); err != nil {
+			return Error.Wrap(err)
+		}
+	}
+
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	_, err = file.Write(line)
+	return Error.Wrap(errs.Combine(err, file.Close()))
+}
+
+// last returns up to n of the most recent entries, oldest first. Lines that
+// can not be parsed are skipped.
+func (h *history) last(n int) ([]HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var entries []HistoryEntry
+	for _, path := range []string{h.path + historyBackupExt, h.path} {
+		read, err := h.read(path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, read...)
+	}
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return entries, nil
+}
+
+// read returns the entries in the file at path, which may not exist.
+func (h *history) read(path string) (_ []HistoryEntry, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, Error.Wrap(err)
+	}
+	defer func() { err = errs.Combine(err, file.Close()) }()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			h.log.Debug("skipping corrupt retain history entry", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, Error.Wrap(scanner.Err())
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retain_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"golang.org/x/sync/errgroup"
+
+	"storj.io/common/bloomfilter"
+	"storj.io/common/errs2"
+	"storj.io/common/identity/testidentity"
+	"storj.io/common/memory"
+	"storj.io/common/pb"
+	"storj.io/common/storj"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/storj/storage/filestore"
+	"storj.io/storj/storagenode"
+	"storj.io/storj/storagenode/pieces"
+	"storj.io/storj/storagenode/retain"
+	"storj.io/storj/storagenode/storagenodedb/storagenodedbtest"
+)
+
+func TestRetainHistory(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satelliteA := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+		satelliteB := testidentity.MustPregeneratedSignedIdentity(1, storj.LatestIDVersion())
+
+		const numPieces = 10
+		const numKept = 4
+		pieceIDs := map[storj.NodeID][]storj.PieceID{}
+		for _, satellite := range []storj.NodeID{satelliteA.ID, satelliteB.ID} {
+			pieceIDs[satellite] = generateTestIDs(numPieces)
+			for _, id := range pieceIDs[satellite] {
+				w, err := testStore.WriterForFormatVersion(ctx, satellite, id, filestore.FormatV1)
+				require.NoError(t, err)
+				_, err = w.Write(testrand.Bytes(100 * memory.B))
+				require.NoError(t, err)
+				require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+			}
+		}
+
+		config := retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+			HistoryPath: ctx.File("retain-history.log"),
+		}
+
+		// process runs the requests to completion.
+		process := func(config retain.Config, requests ...retain.Request) *retain.Service {
+			service := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+			for _, req := range requests {
+				require.True(t, service.Queue(req))
+			}
+
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			var group errgroup.Group
+			group.Go(func() error {
+				return service.Run(runCtx)
+			})
+			require.NoError(t, service.WaitIdle(ctx))
+
+			// the history is written once the workers have exited.
+			cancel()
+			err := group.Wait()
+			require.True(t, errs2.IsCanceled(err))
+			return service
+		}
+
+		keep := bloomfilter.NewOptimal(numPieces, 0.000000001)
+		for _, id := range pieceIDs[satelliteA.ID][:numKept] {
+			keep.Add(id)
+		}
+		createdBefore := time.Now().Add(time.Hour)
+		service := process(config,
+			// the replaced request is not recorded.
+			retain.Request{
+				SatelliteID:   satelliteA.ID,
+				CreatedBefore: createdBefore.Add(-time.Minute),
+				Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+			},
+			retain.Request{
+				SatelliteID:   satelliteA.ID,
+				CreatedBefore: createdBefore,
+				Filter:        keep,
+			},
+			retain.Request{
+				SatelliteID:   satelliteB.ID,
+				CreatedBefore: createdBefore,
+				Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+			},
+		)
+
+		file, err := os.Open(config.HistoryPath)
+		require.NoError(t, err)
+		defer ctx.Check(file.Close)
+
+		entries := map[storj.NodeID]retain.HistoryEntry{}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &fields))
+			for _, field := range []string{"satelliteID", "filterCreationDate", "started", "finished", "piecesExamined", "piecesRetained", "piecesTrashed", "bytesReclaimed"} {
+				require.Contains(t, fields, field)
+			}
+			require.NotContains(t, fields, "error")
+
+			var entry retain.HistoryEntry
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+			entries[entry.SatelliteID] = entry
+		}
+		require.NoError(t, scanner.Err())
+		require.Len(t, entries, 2)
+
+		for satellite, kept := range map[storj.NodeID]int64{satelliteA.ID: numKept, satelliteB.ID: 0} {
+			entry := entries[satellite]
+			require.True(t, entry.FilterCreationDate.Equal(createdBefore))
+			require.False(t, entry.Started.After(entry.Finished))
+			require.EqualValues(t, numPieces, entry.PiecesExamined)
+			require.EqualValues(t, kept, entry.PiecesRetained)
+			require.EqualValues(t, numPieces-kept, entry.PiecesTrashed)
+			require.EqualValues(t, (numPieces-kept)*(100+pieces.V1PieceHeaderReservedArea), entry.BytesReclaimed)
+		}
+
+		history, err := service.History(10)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+
+		// a history over the maximum size is rotated, and both files are read.
+		config.HistoryMaxSize = 1
+		service = process(config, retain.Request{
+			SatelliteID:   satelliteA.ID,
+			CreatedBefore: createdBefore.Add(time.Minute),
+			Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+		})
+		require.FileExists(t, config.HistoryPath+".1")
+
+		history, err = service.History(2)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		require.True(t, history[1].FilterCreationDate.Equal(createdBefore.Add(time.Minute)))
+		require.EqualValues(t, numKept, history[1].PiecesTrashed)
+	})
+}
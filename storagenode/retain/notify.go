@@ -0,0 +1,128 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retain
+
+import (
+	"context"
+	"time"
+
+	"storj.io/common/storj"
+	"storj.io/storj/private/retainfilter"
+)
+
+var (
+	// ErrReplaced is the error of a request that was replaced by a newer
+	// request for the same satellite and range before it was processed.
+	ErrReplaced = Error.New("replaced by a newer request")
+	// ErrShutdown is the error of a request that was still queued when the
+	// service shut down. Persisted requests are processed after a restart.
+	ErrShutdown = Error.New("service shut down")
+	// ErrExpired is the error of a request whose filter would have been older
+	// than the maximum age when it was started.
+	ErrExpired = Error.New("filter too old by its projected start")
+)
+
+// Summary describes a retain request that left the service.
+type Summary struct {
+	SatelliteID   storj.NodeID
+	Range         retainfilter.Range
+	Flags         retainfilter.Flags
+	CreatedBefore time.Time
+
+	// PiecesWalked and the other totals only include the pieces since the
+	// checkpoint, when an interrupted request was resumed.
+	PiecesWalked  int64
+	PiecesDeleted int64
+	// PiecesFailed are the pieces that were not retained, but could not be
+	// moved to the trash.
+	PiecesFailed int64
+	BytesDeleted int64
+	// PiecesLeftToExpiration are the pieces that were not evaluated, because
+	// they expired or expire soon and are deleted by the collector anyway.
+	PiecesLeftToExpiration int64
+	// PiecesGuarded are the trashed pieces that were modified within the
+	// guard window before the creation date of the filter.
+	PiecesGuarded int64
+	Duration      time.Duration
+	// Finished is when the request left the service.
+	Finished time.Time
+
+	// Err is set when processing the request failed partway, or when the
+	// request was not processed at all.
+	Err error
+}
+
+// Subscribe registers fn to be called with the summary of every request that
+// is processed, replaced, or dropped at shutdown. fn is called exactly once
+// per request, without any locks held. The returned function unsubscribes.
+func (s *Service) Subscribe(fn func(Summary)) (unsubscribe func()) {
+	s.subscribersMu.Lock()
+	defer s.subscribersMu.Unlock()
+
+	id := s.nextSubscriber
+	s.nextSubscriber++
+	s.subscribers[id] = fn
+
+	return func() {
+		s.subscribersMu.Lock()
+		defer s.subscribersMu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// notify calls the subscribers with the summary, requires mutex to not be held.
+func (s *Service) notify(summary Summary) {
+	s.subscribersMu.Lock()
+	subscribers := make([]func(Summary), 0, len(s.subscribers))
+	for _, fn := range s.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	s.subscribersMu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(summary)
+	}
+}
+
+// summarize returns the summary of a request that was not processed.
+func summarize(request Request, err error) Summary {
+	return Summary{
+		SatelliteID:   request.SatelliteID,
+		Range:         request.Range,
+		Flags:         request.Flags,
+		CreatedBefore: request.CreatedBefore,
+		Finished:      time.Now(),
+		Err:           err,
+	}
+}
+
+// WaitIdle blocks until no requests are queued or being processed, or the
+// context is canceled.
+func (s *Service) WaitIdle(ctx context.Context) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// acquiring the lock ensures that the waiter is waiting.
+			s.cond.L.Lock()
+			s.cond.Broadcast()
+			s.cond.L.Unlock()
+		case <-stop:
+		}
+	}()
+
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+
+	for len(s.queued) > 0 || len(s.working) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.cond.Wait()
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/fpath"
+	"storj.io/common/storj"
+	"storj.io/storj/private/retainfilter"
+)
+
+// requestExt is the extension of the files that queued requests are persisted in.
+const requestExt = ".retain"
+
+// checkpointExt is appended to the path of a persisted request to name the file
+// with the key prefix of the last chunk of pieces that was walked for it.
+const checkpointExt = ".checkpoint"
+
+// tempExt is the extension of the temporary files that requests are written
+// to before they are moved in place.
+const tempExt = ".tmp"
+
+// requestMagic begins every persisted request.
+var requestMagic = []byte("retainq")
+
+// requestVersion is the version of the persisted request format.
+const requestVersion = 1
+
+// persist writes the request to a new file in the configured directory, so
+// that it is processed after a restart. It sets the path of the request, which
+// stays empty when requests are not persisted.
+func (s *Service) persist(req *Request) error {
+	if s.config.Path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(s.config.Path, 0700); err != nil {
+		return Error.Wrap(err)
+	}
+
+	// the name is unique, so that a request that replaces a queued one never
+	// overwrites the file of a request that is being processed.
+	name := fmt.Sprintf("%s-%d-%d-%d%s", req.SatelliteID, req.Range.Bits, req.Range.Index, time.Now().UnixNano(), requestExt)
+	path := filepath.Join(s.config.Path, name)
+	if err := writeRequest(path, *req); err != nil {
+		return Error.Wrap(err)
+	}
+	req.path = path
+	return nil
+}
+
+// unpersist removes the file and the checkpoint of the request, if it was
+// persisted.
+func (s *Service) unpersist(req Request) {
+	if req.path == "" {
+		return
+	}
+	if err := os.Remove(req.path); err != nil && !os.IsNotExist(err) {
+		s.log.Warn("failed to remove persisted retain request", zap.String("path", req.path), zap.Error(err))
+	}
+	s.removeCheckpoint(req)
+}
+
+// saveCheckpoint records that the pieces with keys up to and including the
+// key prefix were walked for the request, if it was persisted.
+func (s *Service) saveCheckpoint(req Request, keyPrefix string) error {
+	if req.path == "" {
+		return nil
+	}
+	return Error.Wrap(fpath.AtomicWriteFile(req.path+checkpointExt, []byte(keyPrefix), 0600))
+}
+
+// loadCheckpoint returns the key prefix of the checkpoint of the persisted
+// request, or an empty string when the request has none. A checkpoint belongs
+// to the file of a single request, so a newer request that replaces it always
+// starts from the beginning.
+func (s *Service) loadCheckpoint(req Request) string {
+	data, err := ioutil.ReadFile(req.path + checkpointExt)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.log.Warn("failed to load retain checkpoint", zap.String("path", req.path+checkpointExt), zap.Error(err))
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// removeCheckpoint removes the checkpoint of the request, if there is one.
+func (s *Service) removeCheckpoint(req Request) {
+	if req.path == "" {
+		return
+	}
+	if err := os.Remove(req.path + checkpointExt); err != nil && !os.IsNotExist(err) {
+		s.log.Warn("failed to remove retain checkpoint", zap.String("path", req.path+checkpointExt), zap.Error(err))
+	}
+}
+
+// loadPersisted returns the requests that were persisted and not processed
+// before the last shutdown. Files that can not be parsed are removed.
+func (s *Service) loadPersisted() ([]Request, error) {
+	if s.config.Path == "" {
+		return nil, nil
+	}
+
+	entries, err := ioutil.ReadDir(s.config.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, Error.Wrap(err)
+	}
+
+	var requests []Request
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), tempExt) {
+			// a request that was being written when the node stopped.
+			s.removeTemporary(filepath.Join(s.config.Path, entry.Name()))
+			continue
+		}
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), requestExt+checkpointExt) {
+			s.removeOrphanedCheckpoint(filepath.Join(s.config.Path, entry.Name()))
+			continue
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), requestExt) {
+			continue
+		}
+		path := filepath.Join(s.config.Path, entry.Name())
+
+		req, err := s.loadRequest(path, entry.Size())
+		if err != nil {
+			if retainfilter.ErrTooLarge.Has(err) {
+				s.log.Warn("discarding persisted retain request over the maximum filter size", zap.String("path", path), zap.Error(err))
+			} else {
+				s.log.Warn("discarding corrupt persisted retain request", zap.String("path", path), zap.Error(err))
+			}
+			s.unpersist(Request{path: path})
+			continue
+		}
+		req.path = path
+		req.received = entry.ModTime()
+		req.checkpoint = s.loadCheckpoint(req)
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// loadRequest reads the request persisted at path, whose file has the size.
+func (s *Service) loadRequest(path string, size int64) (_ Request, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Request{}, err
+	}
+	defer func() { err = errs.Combine(err, file.Close()) }()
+
+	return readRequest(file, size, s.config.MaxFilterSize.Int64())
+}
+
+// removeTemporary removes a temporary file that a request was written to.
+func (s *Service) removeTemporary(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		s.log.Warn("failed to remove temporary retain request", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// removeOrphanedCheckpoint removes the checkpoint at path when the file of its
+// request was removed, e.g. because the node crashed in between.
+func (s *Service) removeOrphanedCheckpoint(path string) {
+	_, err := os.Stat(strings.TrimSuffix(path, checkpointExt))
+	if !os.IsNotExist(err) {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		s.log.Warn("failed to remove retain checkpoint", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// writeRequest atomically writes the request to path as a header with the
+// satellite ID and creation date, followed by the filter as it was received.
+// The filter is written from the buffer it references, without copying it.
+func writeRequest(path string, req Request) (err error) {
+	filter := req.encoded
+	if filter == nil {
+		filter = retainfilter.EncodeWithFlags(req.Range, req.Flags, req.Filter)
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*"+tempExt)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errs.Combine(err, os.Remove(file.Name()))
+		}
+	}()
+
+	_, err = file.Write(marshalHeader(req))
+	if err == nil {
+		_, err = file.Write(filter)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err = errs.Combine(err, file.Close()); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// marshalHeader serializes the header of a persisted request.
+func marshalHeader(req Request) []byte {
+	data := make([]byte, 0, maxHeaderSize())
+	data = append(data, requestMagic...)
+	data = append(data, requestVersion)
+	data = append(data, req.SatelliteID.Bytes()...)
+
+	var buf [binary.MaxVarintLen64]byte
+	return append(data, buf[:binary.PutUvarint(buf[:], uint64(req.CreatedBefore.UnixNano()))]...)
+}
+
+// maxHeaderSize returns the maximum size of the header of a persisted request.
+func maxHeaderSize() int {
+	return len(requestMagic) + 1 + len(storj.NodeID{}) + binary.MaxVarintLen64
+}
+
+// readRequest reads a request written by writeRequest from r, which contains
+// size bytes. The filter is read directly into the buffer that it references,
+// and a filter over maxSize is rejected before it is read.
+func readRequest(r io.Reader, size, maxSize int64) (Request, error) {
+	header := make([]byte, maxHeaderSize())
+	if size < int64(len(header)) {
+		header = header[:size]
+	}
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Request{}, err
+	}
+
+	req, headerSize, err := unmarshalHeader(header)
+	if err != nil {
+		return Request{}, err
+	}
+
+	// the header may have been followed by the beginning of the filter.
+	filter := io.MultiReader(bytes.NewReader(header[headerSize:]), r)
+	req.encoded, req.Range, req.Filter, err = retainfilter.Read(filter, size-int64(headerSize), maxSize)
+	if err != nil {
+		return Request{}, err
+	}
+	req.Flags = retainfilter.FlagsOf(req.encoded)
+	return req, nil
+}
+
+// unmarshalHeader parses a header serialized by marshalHeader and returns the
+// request without its filter, and the size of the header.
+func unmarshalHeader(data []byte) (_ Request, size int, err error) {
+	if !bytes.HasPrefix(data, requestMagic) {
+		return Request{}, 0, errs.New("missing header")
+	}
+	size += len(requestMagic)
+
+	if len(data) <= size || data[size] != requestVersion {
+		return Request{}, 0, errs.New("unsupported version")
+	}
+	size++
+
+	if len(data) < size+len(storj.NodeID{}) {
+		return Request{}, 0, errs.New("truncated")
+	}
+	satelliteID, err := storj.NodeIDFromBytes(data[size : size+len(storj.NodeID{})])
+	if err != nil {
+		return Request{}, 0, err
+	}
+	size += len(storj.NodeID{})
+
+	nanos, n := binary.Uvarint(data[size:])
+	if n <= 0 {
+		return Request{}, 0, errs.New("invalid creation date")
+	}
+	size += n
+
+	return Request{
+		SatelliteID:   satelliteID,
+		CreatedBefore: time.Unix(0, int64(nanos)),
+	}, size, nil
+}
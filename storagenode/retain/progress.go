@@ -0,0 +1,176 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retain
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+
+	"storj.io/common/storj"
+	"storj.io/storj/private/retainfilter"
+)
+
+// Progress is the state of a queued, active or halted retain request.
+type Progress struct {
+	SatelliteID   storj.NodeID       `json:"satelliteID"`
+	Range         retainfilter.Range `json:"range"`
+	Received      time.Time          `json:"received"`
+	CreatedBefore time.Time          `json:"createdBefore"`
+	// Halted is set when the request exceeded the maximum delete ratio and
+	// waits for the operator to override it.
+	Halted bool `json:"halted"`
+
+	// Active is set when the pieces are being walked, the fields below are
+	// only set for active requests.
+	Active        bool      `json:"active"`
+	Started       time.Time `json:"started"`
+	PiecesWalked  int64     `json:"piecesWalked"`
+	PiecesDeleted int64     `json:"piecesDeleted"`
+	PiecesFailed  int64     `json:"piecesFailed"`
+	// EstimatedCompletion is based on the walk rate and the number of pieces
+	// walked by the previous request for the same range. It is zero when no
+	// request for the range finished since the node started.
+	EstimatedCompletion time.Time `json:"estimatedCompletion"`
+}
+
+// activeRequest is a request that is being processed.
+type activeRequest struct {
+	// walked, deleted, failed, bytes, expiring and guarded are updated
+	// atomically by the worker, they are first to be 64-bit aligned on 32-bit
+	// platforms.
+	walked   int64
+	deleted  int64
+	failed   int64
+	bytes    int64
+	expiring int64
+	guarded  int64
+
+	request Request
+	started time.Time
+}
+
+// start marks the request as active, requires mutex to be held.
+func (s *Service) start(request Request) *activeRequest {
+	active := &activeRequest{
+		request: request,
+		started: time.Now(),
+	}
+	s.active[queueKey{satelliteID: request.SatelliteID, pieceRange: request.Range}] = active
+	return active
+}
+
+// Progress returns the state of the queued, active and halted retain requests,
+// ordered by the time they were received.
+func (s *Service) Progress() []Progress {
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+
+	now := time.Now()
+	progress := make([]Progress, 0, len(s.queued)+len(s.active)+len(s.halted))
+	for _, request := range s.queued {
+		progress = append(progress, Progress{
+			SatelliteID:   request.SatelliteID,
+			Range:         request.Range,
+			Received:      request.received,
+			CreatedBefore: request.CreatedBefore,
+		})
+	}
+	for _, request := range s.halted {
+		progress = append(progress, Progress{
+			SatelliteID:   request.SatelliteID,
+			Range:         request.Range,
+			Received:      request.received,
+			CreatedBefore: request.CreatedBefore,
+			Halted:        true,
+		})
+	}
+	for key, active := range s.active {
+		walked := atomic.LoadInt64(&active.walked)
+		progress = append(progress, Progress{
+			SatelliteID:   active.request.SatelliteID,
+			Range:         active.request.Range,
+			Received:      active.request.received,
+			CreatedBefore: active.request.CreatedBefore,
+
+			Active:              true,
+			Started:             active.started,
+			PiecesWalked:        walked,
+			PiecesDeleted:       atomic.LoadInt64(&active.deleted),
+			PiecesFailed:        atomic.LoadInt64(&active.failed),
+			EstimatedCompletion: estimateCompletion(now, active.started, walked, s.walked[key]),
+		})
+	}
+
+	sort.Slice(progress, func(i, k int) bool {
+		return progress[i].Received.Before(progress[k].Received)
+	})
+	return progress
+}
+
+// Completed returns the summaries of the last processed request of the
+// satellite for every range, in the order of the ranges. Requests that were
+// interrupted by a shutdown are not completed.
+func (s *Service) Completed(satelliteID storj.NodeID) []Summary {
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+
+	var completed []Summary
+	for key, summary := range s.completed {
+		if key.satelliteID == satelliteID {
+			completed = append(completed, summary)
+		}
+	}
+
+	sort.Slice(completed, func(i, k int) bool {
+		if completed[i].Range.Bits != completed[k].Range.Bits {
+			return completed[i].Range.Bits < completed[k].Range.Bits
+		}
+		return completed[i].Range.Index < completed[k].Range.Index
+	})
+	return completed
+}
+
+// estimateCompletion extrapolates the walk rate since started to the expected
+// number of pieces. It returns zero when the estimate is not possible.
+func estimateCompletion(now, started time.Time, walked, expected int64) time.Time {
+	if walked <= 0 || expected <= 0 {
+		return time.Time{}
+	}
+	if walked >= expected {
+		return now
+	}
+	elapsed := now.Sub(started)
+	remaining := time.Duration(float64(elapsed) * float64(expected-walked) / float64(walked))
+	return now.Add(remaining)
+}
+
+// Stats implements monkit.StatSource.
+func (s *Service) Stats(cb func(key monkit.SeriesKey, field string, val float64)) {
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+
+	var walked, deleted int64
+	for _, active := range s.active {
+		walked += atomic.LoadInt64(&active.walked)
+		deleted += atomic.LoadInt64(&active.deleted)
+	}
+
+	key := monkit.NewSeriesKey("retain_progress")
+	cb(key, "queued", float64(len(s.queued)))
+	cb(key, "active", float64(len(s.active)))
+	cb(key, "pieces_walked", float64(walked))
+	cb(key, "pieces_deleted", float64(deleted))
+}
+
+// History returns up to n of the most recently processed requests from the
+// history, oldest first. It returns nothing when the history is disabled.
+func (s *Service) History(n int) ([]HistoryEntry, error) {
+	if s.history == nil {
+		return nil, nil
+	}
+	return s.history.last(n)
+}
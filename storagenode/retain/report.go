@@ -0,0 +1,152 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retain
+
+import (
+	"context"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/errs2"
+	"storj.io/common/rpc"
+	"storj.io/common/rpc/rpcstatus"
+	"storj.io/storj/private/retainfilter"
+	"storj.io/storj/private/retainpb"
+	"storj.io/storj/storagenode/trust"
+)
+
+// reportQueueSize is the number of summaries that wait to be reported, any
+// further are dropped.
+const reportQueueSize = 64
+
+// reportTimeout is how long dialing a satellite and reporting a summary to it
+// may take.
+const reportTimeout = time.Minute
+
+// Reporter reports the results of processed retain requests back to the
+// satellites that sent them. Only the requests that ask for a report with
+// retainfilter.FlagReport are reported, which satellites only send when they
+// support reports and know that the node does. Reports are best effort:
+// satellites that can't be reached simply don't receive them.
+//
+// architecture: Chore
+type Reporter struct {
+	log     *zap.Logger
+	dialer  rpc.Dialer
+	trust   *trust.Pool
+	service *Service
+
+	summaries   chan Summary
+	unsubscribe func()
+}
+
+// NewReporter creates a new reporter for the requests the service processes.
+func NewReporter(log *zap.Logger, dialer rpc.Dialer, trust *trust.Pool, service *Service) *Reporter {
+	reporter := &Reporter{
+		log:       log,
+		dialer:    dialer,
+		trust:     trust,
+		service:   service,
+		summaries: make(chan Summary, reportQueueSize),
+	}
+	reporter.unsubscribe = service.Subscribe(reporter.enqueue)
+	return reporter
+}
+
+// enqueue queues the summary to be reported, when it is worth reporting.
+func (reporter *Reporter) enqueue(summary Summary) {
+	// satellites that didn't ask for a report may not be able to receive it.
+	if summary.Flags&retainfilter.FlagReport == 0 {
+		return
+	}
+	// requests that failed, were replaced or dropped didn't apply the filter,
+	// and in debug or preview mode no pieces are moved to the trash.
+	if summary.Err != nil || reporter.service.Status() != Enabled {
+		return
+	}
+
+	select {
+	case reporter.summaries <- summary:
+	default:
+		mon.Meter("retain_reports_dropped").Mark(1)
+		reporter.log.Warn("too many retain results waiting to be reported, dropping",
+			zap.Stringer("Satellite ID", summary.SatelliteID),
+			zap.Time("Created Before", summary.CreatedBefore))
+	}
+}
+
+// Run reports the queued summaries until ctx is canceled.
+func (reporter *Reporter) Run(ctx context.Context) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case summary := <-reporter.summaries:
+			reporter.report(ctx, summary)
+		}
+	}
+}
+
+// report sends the summary to the satellite.
+func (reporter *Reporter) report(ctx context.Context, summary Summary) {
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+
+	satellite := monkit.NewSeriesTag("satellite", summary.SatelliteID.String())
+	err := reporter.send(ctx, summary)
+	if errs2.IsRPC(err, rpcstatus.AlreadyExists) {
+		// the request was processed again, e.g. after a restart.
+		reporter.log.Debug("retain result was already reported",
+			zap.Stringer("Satellite ID", summary.SatelliteID),
+			zap.Time("Created Before", summary.CreatedBefore))
+		return
+	}
+	if err != nil {
+		mon.Meter("retain_reports_failed", satellite).Mark(1)
+		reporter.log.Warn("failed to report retain result",
+			zap.Stringer("Satellite ID", summary.SatelliteID),
+			zap.Time("Created Before", summary.CreatedBefore),
+			zap.Error(err))
+		return
+	}
+	mon.Meter("retain_reports_sent", satellite).Mark(1)
+}
+
+// send dials the satellite and sends it the summary.
+func (reporter *Reporter) send(ctx context.Context, summary Summary) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	nodeurl, err := reporter.trust.GetNodeURL(ctx, summary.SatelliteID)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+
+	conn, err := reporter.dialer.DialNodeURL(ctx, nodeurl)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+	defer func() { err = errs.Combine(err, Error.Wrap(conn.Close())) }()
+
+	_, err = retainpb.NewDRPCGarbageCollectionClient(conn).ReportRetainResult(ctx, &retainpb.ReportRetainResultRequest{
+		CreationDate:   summary.CreatedBefore,
+		RangeBits:      uint32(summary.Range.Bits),
+		RangeIndex:     summary.Range.Index,
+		PiecesExamined: summary.PiecesWalked,
+		PiecesTrashed:  summary.PiecesDeleted,
+		BytesReclaimed: summary.BytesDeleted,
+		DurationMillis: summary.Duration.Milliseconds(),
+	})
+	return Error.Wrap(err)
+}
+
+// Close stops queueing the summaries of processed requests.
+func (reporter *Reporter) Close() error {
+	reporter.unsubscribe()
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testrand"
+	"storj.io/storj/private/retainfilter"
+)
+
+func TestReporterEnqueue(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		status   Status
+		summary  Summary
+		reported bool
+	}{
+		{name: "requested", status: Enabled, summary: Summary{Flags: retainfilter.FlagReport}, reported: true},
+		// satellites that didn't ask for a report are never sent one.
+		{name: "not requested", status: Enabled, summary: Summary{}},
+		{name: "failed", status: Enabled, summary: Summary{Flags: retainfilter.FlagReport, Err: errors.New("failed")}},
+		{name: "preview", status: Preview, summary: Summary{Flags: retainfilter.FlagReport}},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			reporter := &Reporter{
+				log:       zaptest.NewLogger(t),
+				service:   &Service{config: Config{Status: tt.status}},
+				summaries: make(chan Summary, reportQueueSize),
+			}
+
+			tt.summary.SatelliteID = testrand.NodeID()
+			tt.summary.CreatedBefore = time.Now()
+			reporter.enqueue(tt.summary)
+
+			if tt.reported {
+				require.Len(t, reporter.summaries, 1)
+				require.Equal(t, tt.summary.SatelliteID, (<-reporter.summaries).SatelliteID)
+			} else {
+				require.Empty(t, reporter.summaries)
+			}
+		})
+	}
+}
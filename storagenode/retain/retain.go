@@ -5,17 +5,26 @@ package retain
 
 import (
 	"context"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spacemonkeygo/monkit/v3"
 	"github.com/zeebo/errs"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 
 	"storj.io/common/bloomfilter"
+	"storj.io/common/errs2"
+	"storj.io/common/memory"
 	"storj.io/common/storj"
+	"storj.io/common/sync2"
+	"storj.io/storj/private/retainfilter"
 	"storj.io/storj/storagenode/pieces"
 )
 
@@ -24,13 +33,126 @@ var (
 
 	// Error is the default error class for retain errors.
 	Error = errs.Class("retain")
+	// ErrFilterTooLarge is the error class of filters over the maximum size.
+	ErrFilterTooLarge = errs.Class("retain filter too large")
+	// ErrDeleteRatioExceeded is the error class of requests that were halted,
+	// because they would have moved too many of the pieces to the trash.
+	ErrDeleteRatioExceeded = errs.Class("retain delete ratio exceeded")
+	// ErrFutureCreation is the error class of filters created too far in the future.
+	ErrFutureCreation = errs.Class("retain filter created in the future")
 )
 
 // Config defines parameters for the retain service.
 type Config struct {
-	MaxTimeSkew time.Duration `help:"allows for small differences in the satellite and storagenode clocks" default:"72h0m0s"`
-	Status      Status        `help:"allows configuration to enable, disable, or test retain requests from the satellite. Options: (disabled/enabled/debug)" default:"enabled"`
-	Concurrency int           `help:"how many concurrent retain requests can be processed at the same time." default:"5"`
+	MaxTimeSkew          time.Duration `help:"allows for small differences in the satellite and storagenode clocks" default:"72h0m0s"`
+	MaxTimeSkewOverrides SkewOverrides `help:"comma separated list of satellite ID and time skew pairs that override max-time-skew, e.g. 12rA...@:48h" default:""`
+	Status               Status        `help:"allows configuration to enable, disable, or test retain requests from the satellite. Options: (disabled/enabled/debug/preview)" default:"enabled"`
+	Concurrency          int           `help:"how many concurrent retain requests can be processed at the same time. Every request holds its bloom filter of a few megabytes in memory, and requests of the same satellite are never processed concurrently." default:"5"`
+	Path                 string        `help:"path to persist queued retain requests in until they are processed" default:"$CONFDIR/retain"`
+	ReportResults        bool          `help:"report the results of retain requests back to the satellites that ask for it" default:"true"`
+	RateLimit            float64       `help:"maximum number of piece stat and delete operations per second of all retain requests, 0 is unlimited" default:"0"`
+	BatchSleep           time.Duration `help:"how long to pause after every batch of walked pieces, 0 is no pause" default:"0s"`
+	MaxFilterSize        memory.Size   `help:"maximum size of accepted retain filters, 0 is unlimited" default:"4.0 MiB"`
+	MaxFutureCreation    time.Duration `help:"how far the creation date of accepted retain filters may be ahead of the node clock" default:"1h0m0s"`
+	TrashWorkers         int           `help:"how many pieces are moved to the trash concurrently, at least one" default:"2"`
+	TrashAttempts        int           `help:"how often moving a piece to the trash is attempted before it is recorded in the failure list, at least one" default:"5"`
+	TrashBackoff         time.Duration `help:"how long to wait before retrying to move a piece to the trash, doubled for every further retry" default:"1s"`
+	ExpirationWindow     time.Duration `help:"pieces that expired or expire within this duration are left to the expiration collector instead of being evaluated" default:"24h0m0s"`
+	MaxWait              time.Duration `help:"how long a queued retain request may wait before it is processed ahead of requests with older filters, 0 is unlimited" default:"24h0m0s"`
+	MaxAge               time.Duration `help:"retain requests whose filter would be older than this when they are started are dropped, 0 is unlimited" default:"168h0m0s"`
+	HistoryPath          string        `help:"path of the file a JSON line is appended to for every processed retain request, empty disables the history" default:"$CONFDIR/retain-history.log"`
+	HistoryMaxSize       memory.Size   `help:"size after which the retain history is rotated, 0 is unlimited" default:"10.0 MiB"`
+	MaxDeleteRatio       float64       `help:"maximum fraction of the examined pieces a retain request may move to the trash before it is halted until the operator overrides it, 0 disables the cap" default:"0.5"`
+	DeleteRatioSample    int64         `help:"number of examined pieces after which the maximum delete ratio is enforced, no piece is moved to the trash before" default:"10000"`
+	GuardWindow          time.Duration `help:"pieces modified within this duration before the creation date of a filter, less the max time skew, are guarded: they are always moved to the trash, so that a wrongly dated filter can be recovered from with restore-trash, and they are counted separately" default:"72h0m0s"`
+}
+
+// trashFailuresFile is the name of the failure list of the trash queue in the
+// configured directory.
+const trashFailuresFile = "trash-failures.json"
+
+// flushInterval is how often the space used cache is flushed while pieces
+// are moved to the trash.
+const flushInterval = time.Minute
+
+// heldPiece is a condemned piece that is held back until enough pieces were
+// examined to enforce the maximum delete ratio.
+type heldPiece struct {
+	pieceID storj.PieceID
+	size    int64
+	guarded bool
+}
+
+// SpaceUsedFlusher persists the totals of the space used cache. The cache is
+// updated for every piece that is moved to the trash, flushing makes sure
+// that a crash does not lose these updates.
+type SpaceUsedFlusher interface {
+	PersistCacheTotals(ctx context.Context) error
+}
+
+// batchSize is the number of walked pieces after which retain pauses for the
+// configured BatchSleep.
+const batchSize = 1000
+
+// Verify verifies whether the retain config is valid.
+func (config Config) Verify() error {
+	if config.Concurrency < 1 {
+		return Error.New("concurrency must be at least 1, got %d", config.Concurrency)
+	}
+	if config.RateLimit < 0 {
+		return Error.New("rate limit must not be negative, got %v", config.RateLimit)
+	}
+	if config.BatchSleep < 0 {
+		return Error.New("batch sleep must not be negative, got %v", config.BatchSleep)
+	}
+	if config.MaxTimeSkew < 0 {
+		return Error.New("max time skew must not be negative, got %v", config.MaxTimeSkew)
+	}
+	if config.MaxFilterSize < 0 {
+		return Error.New("max filter size must not be negative, got %v", config.MaxFilterSize)
+	}
+	if config.MaxFutureCreation < 0 {
+		return Error.New("max future creation must not be negative, got %v", config.MaxFutureCreation)
+	}
+	if config.TrashWorkers < 0 {
+		return Error.New("trash workers must not be negative, got %d", config.TrashWorkers)
+	}
+	if config.TrashAttempts < 0 {
+		return Error.New("trash attempts must not be negative, got %d", config.TrashAttempts)
+	}
+	if config.TrashBackoff < 0 {
+		return Error.New("trash backoff must not be negative, got %v", config.TrashBackoff)
+	}
+	if config.ExpirationWindow < 0 {
+		return Error.New("expiration window must not be negative, got %v", config.ExpirationWindow)
+	}
+	if config.MaxWait < 0 {
+		return Error.New("max wait must not be negative, got %v", config.MaxWait)
+	}
+	if config.MaxAge < 0 {
+		return Error.New("max age must not be negative, got %v", config.MaxAge)
+	}
+	if config.HistoryMaxSize < 0 {
+		return Error.New("history max size must not be negative, got %v", config.HistoryMaxSize)
+	}
+	if config.MaxDeleteRatio < 0 || config.MaxDeleteRatio > 1 {
+		return Error.New("max delete ratio must be between 0 and 1, got %v", config.MaxDeleteRatio)
+	}
+	if config.DeleteRatioSample < 0 {
+		return Error.New("delete ratio sample must not be negative, got %d", config.DeleteRatioSample)
+	}
+	if config.GuardWindow < 0 {
+		return Error.New("guard window must not be negative, got %v", config.GuardWindow)
+	}
+	return nil
+}
+
+// TimeSkew returns the time skew allowed for the satellite.
+func (config Config) TimeSkew(satelliteID storj.NodeID) time.Duration {
+	if skew, ok := config.MaxTimeSkewOverrides[satelliteID]; ok {
+		return skew
+	}
+	return config.MaxTimeSkew
 }
 
 // Request contains all the info necessary to process a retain request.
@@ -38,6 +160,51 @@ type Request struct {
 	SatelliteID   storj.NodeID
 	CreatedBefore time.Time
 	Filter        *bloomfilter.Filter
+	// Range is the range of piece IDs the filter applies to. Pieces outside
+	// of it are left alone.
+	Range retainfilter.Range
+	// Flags are what the satellite asked for besides applying the filter.
+	// Pieces that are not retained are always moved to the trash, so
+	// retainfilter.FlagRequireTrash is honored as is.
+	Flags retainfilter.Flags
+
+	// encoded is the filter as it was received, which Filter references. It
+	// is persisted as is, so that the filter is never copied.
+	encoded []byte
+	// path is the file the request is persisted in, if any.
+	path string
+	// checkpoint is the key prefix up to which the pieces were walked for
+	// the persisted request before the last shutdown, if any.
+	checkpoint string
+	// received is when the request was queued.
+	received time.Time
+	// override is set when the operator allowed the halted request to move
+	// more than the maximum delete ratio of the pieces to the trash.
+	override bool
+}
+
+// DecodeRequest returns the request of the satellite with the filter encoded
+// as it is sent in retain requests. The filter references data instead of
+// copying it, so data must not be modified afterwards.
+func DecodeRequest(satelliteID storj.NodeID, createdBefore time.Time, data []byte) (Request, error) {
+	pieceRange, filter, err := retainfilter.Decode(data)
+	if err != nil {
+		return Request{}, err
+	}
+	return Request{
+		SatelliteID:   satelliteID,
+		CreatedBefore: createdBefore,
+		Filter:        filter,
+		Range:         pieceRange,
+		Flags:         retainfilter.FlagsOf(data),
+		encoded:       data,
+	}, nil
+}
+
+// queueKey identifies the queued request of a satellite for a range.
+type queueKey struct {
+	satelliteID storj.NodeID
+	pieceRange  retainfilter.Range
 }
 
 // Status is a type defining the enabled/disabled status of retain requests.
@@ -50,6 +217,8 @@ const (
 	Enabled
 	// Debug means we partially enable retain requests, and print out pieces we should delete, without actually deleting them.
 	Debug
+	// Preview means we evaluate retain requests as if they were enabled, and only report the totals of the pieces we would delete.
+	Preview
 )
 
 // Set implements pflag.Value.
@@ -61,6 +230,8 @@ func (v *Status) Set(s string) error {
 		*v = Enabled
 	case "debug":
 		*v = Debug
+	case "preview":
+		*v = Preview
 	default:
 		return Error.New("invalid status %q", s)
 	}
@@ -79,11 +250,67 @@ func (v *Status) String() string {
 		return "enabled"
 	case Debug:
 		return "debug"
+	case Preview:
+		return "preview"
 	default:
 		return "invalid"
 	}
 }
 
+// SkewOverrides maps satellite IDs to the time skew allowed for them.
+type SkewOverrides map[storj.NodeID]time.Duration
+
+// Set implements pflag.Value. The format is a comma separated list of
+// satellite ID and duration pairs, separated by a colon. The satellite ID may
+// end with an "@", e.g. "12rA...@:48h".
+func (v *SkewOverrides) Set(s string) error {
+	overrides := SkewOverrides{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		separator := strings.LastIndex(entry, ":")
+		if separator < 0 {
+			return Error.New("invalid time skew override %q: missing duration", entry)
+		}
+
+		satelliteID, err := storj.NodeIDFromString(strings.TrimSuffix(entry[:separator], "@"))
+		if err != nil {
+			return Error.New("invalid time skew override %q: %v", entry, err)
+		}
+		if _, ok := overrides[satelliteID]; ok {
+			return Error.New("invalid time skew override %q: duplicate satellite", entry)
+		}
+
+		skew, err := time.ParseDuration(entry[separator+1:])
+		if err != nil {
+			return Error.New("invalid time skew override %q: %v", entry, err)
+		}
+		if skew < 0 {
+			return Error.New("invalid time skew override %q: negative duration", entry)
+		}
+
+		overrides[satelliteID] = skew
+	}
+	*v = overrides
+	return nil
+}
+
+// Type implements pflag.Value.
+func (*SkewOverrides) Type() string { return "retain.SkewOverrides" }
+
+// String implements pflag.Value.
+func (v *SkewOverrides) String() string {
+	entries := make([]string, 0, len(*v))
+	for satelliteID, skew := range *v {
+		entries = append(entries, satelliteID.String()+"@:"+skew.String())
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
 // Service queues and processes retain requests from satellites.
 //
 // architecture: Worker
@@ -92,51 +319,205 @@ type Service struct {
 	config Config
 
 	cond    sync.Cond
-	queued  map[storj.NodeID]Request
+	queued  map[queueKey]Request
 	working map[storj.NodeID]struct{}
+	active  map[queueKey]*activeRequest
 	group   errgroup.Group
 
+	// scheduler orders the queued requests.
+	scheduler scheduler
+
+	// walked is the number of pieces walked by the last finished request
+	// for a range.
+	walked map[queueKey]int64
+	// completed is the summary of the last processed request for a range.
+	completed map[queueKey]Summary
+	// halted are the requests that exceeded the maximum delete ratio and
+	// wait for the operator to override it.
+	halted map[queueKey]Request
+	// previewed are the persisted requests that were walked in preview
+	// mode, and are kept to be processed once retain is enabled.
+	previewed map[queueKey]Request
+
+	// limiter limits the IO operations of all workers, when set.
+	limiter *rate.Limiter
+
+	subscribersMu  sync.Mutex
+	subscribers    map[int]func(Summary)
+	nextSubscriber int
+
 	closedOnce sync.Once
 	closed     chan struct{}
 	started    bool
 
 	store *pieces.Store
+	// trashQueue moves the pieces that are not retained to the trash.
+	trashQueue *pieces.TrashQueue
+	// spaceUsed is flushed after pieces are moved to the trash, when set.
+	spaceUsed SpaceUsedFlusher
+	// history records the processed requests, when configured.
+	history *history
 }
 
-// NewService creates a new retain service.
-func NewService(log *zap.Logger, store *pieces.Store, config Config) *Service {
-	return &Service{
+// NewService creates a new retain service. Requests that were persisted and
+// not processed before the last shutdown are queued again. spaceUsed is
+// optional.
+func NewService(log *zap.Logger, store *pieces.Store, spaceUsed SpaceUsedFlusher, config Config) *Service {
+	s := &Service{
 		log:    log,
 		config: config,
 
 		cond:    *sync.NewCond(&sync.Mutex{}),
-		queued:  make(map[storj.NodeID]Request),
+		queued:  make(map[queueKey]Request),
 		working: make(map[storj.NodeID]struct{}),
+		active:  make(map[queueKey]*activeRequest),
 		closed:  make(chan struct{}),
+		walked:  make(map[queueKey]int64),
+
+		scheduler: scheduler{maxWait: config.MaxWait, maxAge: config.MaxAge},
 
-		store: store,
+		completed: make(map[queueKey]Summary),
+		halted:    make(map[queueKey]Request),
+		previewed: make(map[queueKey]Request),
+
+		subscribers: make(map[int]func(Summary)),
+
+		store:     store,
+		spaceUsed: spaceUsed,
+	}
+	if config.RateLimit > 0 {
+		s.limiter = rate.NewLimiter(rate.Limit(config.RateLimit), 1)
+	}
+
+	trashConfig := pieces.TrashQueueConfig{
+		Workers:  config.TrashWorkers,
+		Attempts: config.TrashAttempts,
+		Backoff:  config.TrashBackoff,
+	}
+	if config.Path != "" {
+		trashConfig.FailuresPath = filepath.Join(config.Path, trashFailuresFile)
+	}
+	s.trashQueue = pieces.NewTrashQueue(log.Named("trash"), store, trashConfig)
+
+	if config.HistoryPath != "" {
+		s.history = &history{
+			log:     log.Named("history"),
+			path:    config.HistoryPath,
+			maxSize: config.HistoryMaxSize.Int64(),
+		}
+		s.Subscribe(s.history.record)
+	}
+
+	requests, err := s.loadPersisted()
+	if err != nil {
+		log.Error("failed to load persisted retain requests", zap.Error(err))
+	}
+	for _, req := range requests {
+		s.enqueue(req)
+	}
+	if len(requests) > 0 {
+		log.Info("loaded persisted retain requests", zap.Int("count", len(requests)))
+	}
+
+	return s
+}
+
+// Check verifies that a retain request with the creation date and the size of
+// the encoded filter may be queued.
+func (s *Service) Check(creationDate time.Time, filterSize int) error {
+	if s.config.MaxFilterSize > 0 && int64(filterSize) > s.config.MaxFilterSize.Int64() {
+		return ErrFilterTooLarge.New("%d bytes exceeds the maximum of %d bytes", filterSize, s.config.MaxFilterSize.Int64())
 	}
+	now := time.Now()
+	if creationDate.After(now.Add(s.config.MaxFutureCreation)) {
+		return ErrFutureCreation.New("%v is more than %v ahead of the node clock %v", creationDate, s.config.MaxFutureCreation, now)
+	}
+	return nil
 }
 
-// Queue adds a retain request to the queue.
-// It discards a request for a satellite that already has a queued request.
-// true is returned if the request is queued and false is returned if it is discarded.
+// Queue adds a retain request to the queue. At most one request per satellite
+// and range is held besides the one that is being processed: a request with a
+// newer creation date replaces the queued one, and a request that is not newer
+// than the queued or active one is dropped. It returns whether the request was
+// queued.
 func (s *Service) Queue(req Request) bool {
 	s.cond.L.Lock()
-	defer s.cond.L.Unlock()
 
 	select {
 	case <-s.closed:
+		s.cond.L.Unlock()
 		return false
 	default:
 	}
 
-	s.queued[req.SatelliteID] = req
+	key := queueKey{satelliteID: req.SatelliteID, pieceRange: req.Range}
+	if newest, ok := s.newest(key); ok && !req.CreatedBefore.After(newest) {
+		s.cond.L.Unlock()
+
+		mon.Meter("retain_requests_dropped", monkit.NewSeriesTag("satellite", req.SatelliteID.String())).Mark(1)
+		s.log.Info("dropping retain request that is not newer than the pending one",
+			zap.Stringer("Satellite ID", req.SatelliteID),
+			zap.Time("Created Before", req.CreatedBefore),
+			zap.Time("Pending Created Before", newest))
+		return false
+	}
+
+	req.received = time.Now()
+	if err := s.persist(&req); err != nil {
+		s.log.Warn("failed to persist retain request",
+			zap.Stringer("Satellite ID", req.SatelliteID),
+			zap.Error(err))
+	}
+
+	queued, replaced := s.queued[key]
+	if replaced {
+		s.unpersist(queued)
+	}
+	// a halted request is superseded by the newer filter.
+	if halted, ok := s.halted[key]; ok {
+		delete(s.halted, key)
+		s.unpersist(halted)
+	}
+	s.queued[key] = req
 	s.cond.Broadcast()
+	s.cond.L.Unlock()
 
+	if replaced {
+		mon.Meter("retain_requests_replaced", monkit.NewSeriesTag("satellite", req.SatelliteID.String())).Mark(1)
+		s.notify(summarize(queued, ErrReplaced))
+	}
 	return true
 }
 
+// newest returns the latest creation date of the queued, active and halted
+// requests for the key, requires mutex to be held.
+func (s *Service) newest(key queueKey) (createdBefore time.Time, ok bool) {
+	if queued, found := s.queued[key]; found {
+		createdBefore, ok = queued.CreatedBefore, true
+	}
+	if active, found := s.active[key]; found && (!ok || active.request.CreatedBefore.After(createdBefore)) {
+		createdBefore, ok = active.request.CreatedBefore, true
+	}
+	if halted, found := s.halted[key]; found && (!ok || halted.CreatedBefore.After(createdBefore)) {
+		createdBefore, ok = halted.CreatedBefore, true
+	}
+	return createdBefore, ok
+}
+
+// enqueue adds a persisted request to the queue, unless a request of the
+// satellite for the same range that is not older is queued already.
+func (s *Service) enqueue(req Request) {
+	key := queueKey{satelliteID: req.SatelliteID, pieceRange: req.Range}
+	if queued, ok := s.queued[key]; ok {
+		if !req.CreatedBefore.After(queued.CreatedBefore) {
+			s.unpersist(req)
+			return
+		}
+		s.unpersist(queued)
+	}
+	s.queued[key] = req
+}
+
 // Run listens for queued retain requests and processes them as they come in.
 func (s *Service) Run(ctx context.Context) (err error) {
 	defer mon.Task()(&ctx)(&err)
@@ -154,6 +535,10 @@ func (s *Service) Run(ctx context.Context) (err error) {
 	}
 	s.started = true
 
+	if err := s.config.Verify(); err != nil {
+		return err
+	}
+
 	// Ensure Run doesn't start after it's closed. Then we may leak some
 	// workers.
 	select {
@@ -202,6 +587,18 @@ func (s *Service) Run(ctx context.Context) (err error) {
 				default:
 				}
 
+				// Drop the requests that would be too old by the time
+				// they start, and notify without the lock held.
+				if expired := s.dropExpired(); len(expired) > 0 {
+					s.cond.Broadcast()
+					s.cond.L.Unlock()
+					for _, request := range expired {
+						s.notify(summarize(request, ErrExpired))
+					}
+					s.cond.L.Lock()
+					continue
+				}
+
 				// Grab next item from queue.
 				request, ok := s.next()
 				if !ok {
@@ -211,6 +608,8 @@ func (s *Service) Run(ctx context.Context) (err error) {
 					continue
 				}
 
+				active := s.start(request)
+
 				// Temporarily Unlock so others can work on the queue while
 				// we're working on the retain request.
 				s.cond.L.Unlock()
@@ -219,16 +618,42 @@ func (s *Service) Run(ctx context.Context) (err error) {
 				s.cond.Broadcast()
 
 				// Run retaining process.
-				err := s.retainPieces(ctx, request)
+				err := s.retainPieces(ctx, request, active)
 				if err != nil {
 					s.log.Error("retain pieces failed", zap.Error(err))
 				}
 
-				// Mark the request as finished. Relock to maintain that
-				// at the top of the for loop the lock is held.
+				summary := Summary{
+					SatelliteID:   request.SatelliteID,
+					Range:         request.Range,
+					Flags:         request.Flags,
+					CreatedBefore: request.CreatedBefore,
+					PiecesWalked:  atomic.LoadInt64(&active.walked),
+					PiecesDeleted: atomic.LoadInt64(&active.deleted),
+					PiecesFailed:  atomic.LoadInt64(&active.failed),
+					BytesDeleted:  atomic.LoadInt64(&active.bytes),
+
+					PiecesLeftToExpiration: atomic.LoadInt64(&active.expiring),
+					PiecesGuarded:          atomic.LoadInt64(&active.guarded),
+
+					Duration: time.Since(active.started),
+					Finished: time.Now(),
+					Err:      err,
+				}
+
+				// Mark the request as finished and notify the subscribers
+				// without the lock held. Relock to maintain that at the top
+				// of the for loop the lock is held.
 				s.cond.L.Lock()
-				s.finish(request)
+				s.finish(request, err)
+				if !errs2.IsCanceled(err) {
+					s.completed[queueKey{satelliteID: request.SatelliteID, pieceRange: request.Range}] = summary
+				}
 				s.cond.Broadcast()
+				s.cond.L.Unlock()
+
+				s.notify(summary)
+				s.cond.L.Lock()
 			}
 		})
 	}
@@ -241,31 +666,161 @@ func (s *Service) Run(ctx context.Context) (err error) {
 	// Clear the queue after Wait has exited. We're sure no more entries
 	// can be added after we acquire the mutex because wait spawned a
 	// worker that ensures the closed channel is closed before it exits.
+	dropped := s.queued
 	s.queued = nil
 	s.cond.Broadcast()
 
+	s.cond.L.Unlock()
+	for _, request := range dropped {
+		s.notify(summarize(request, ErrShutdown))
+	}
+	s.cond.L.Lock()
+
 	return err
 }
 
-// next returns next item from queue, requires mutex to be held.
+// next returns next item from queue as ordered by the scheduler, requires
+// mutex to be held.
 func (s *Service) next() (Request, bool) {
-	for id, request := range s.queued {
-		// Check whether a worker is retaining this satellite,
-		// if, yes, then try to get something else from the queue.
-		if _, ok := s.working[request.SatelliteID]; ok {
-			continue
-		}
-		delete(s.queued, id)
-		// Mark this satellite as being worked on.
-		s.working[request.SatelliteID] = struct{}{}
-		return request, true
+	now := time.Now()
+	key, ok := s.scheduler.next(now, s.queued, s.busy(now))
+	if !ok {
+		return Request{}, false
+	}
+	request := s.queued[key]
+	delete(s.queued, key)
+	// Mark this satellite as being worked on.
+	s.working[request.SatelliteID] = struct{}{}
+	return request, true
+}
+
+// dropExpired removes the queued requests whose filter would be older than the
+// maximum age by their projected start and returns them, requires mutex to be
+// held.
+func (s *Service) dropExpired() []Request {
+	now := time.Now()
+	var expired []Request
+	for _, key := range s.scheduler.expired(now, s.queued, s.busy(now)) {
+		request := s.queued[key]
+		delete(s.queued, key)
+		s.unpersist(request)
+
+		mon.Meter("retain_requests_expired", monkit.NewSeriesTag("satellite", request.SatelliteID.String())).Mark(1)
+		s.log.Warn("dropping retain request whose filter would be too old when started",
+			zap.Stringer("Satellite ID", request.SatelliteID),
+			zap.Time("Created Before", request.CreatedBefore),
+			zap.Duration("Max Age", s.config.MaxAge))
+		expired = append(expired, request)
+	}
+	return expired
+}
+
+// busy returns the satellites that are being worked on with the projected
+// completion of their active request, which is zero when it can't be
+// estimated, requires mutex to be held.
+func (s *Service) busy(now time.Time) map[storj.NodeID]time.Time {
+	busy := make(map[storj.NodeID]time.Time, len(s.working))
+	for satelliteID := range s.working {
+		busy[satelliteID] = time.Time{}
+	}
+	for key, active := range s.active {
+		busy[key.satelliteID] = estimateCompletion(now, active.started, atomic.LoadInt64(&active.walked), s.walked[key])
 	}
-	return Request{}, false
+	return busy
 }
 
 // finish marks the request as finished, requires mutex to be held.
-func (s *Service) finish(request Request) {
+// The persisted request is kept when it was interrupted by a shutdown, so that
+// it is processed again after a restart. It is also kept in preview mode, so
+// that the request is processed from scratch when retain is enabled.
+func (s *Service) finish(request Request, err error) {
+	key := queueKey{satelliteID: request.SatelliteID, pieceRange: request.Range}
 	delete(s.working, request.SatelliteID)
+	if active, ok := s.active[key]; ok {
+		if err == nil {
+			s.walked[key] = atomic.LoadInt64(&active.walked)
+		}
+		delete(s.active, key)
+	}
+
+	if errs2.IsCanceled(err) {
+		return
+	}
+	if ErrDeleteRatioExceeded.Has(err) {
+		// the request is kept until the operator overrides the cap, and is
+		// walked from the beginning then, as the pieces that were held back
+		// before the checkpoint were not moved to the trash.
+		s.removeCheckpoint(request)
+		request.checkpoint = ""
+		s.halted[key] = request
+		return
+	}
+	if s.config.Status == Preview {
+		// the request is walked from the beginning once retain is enabled.
+		if err == nil {
+			s.removeCheckpoint(request)
+			s.keepPreviewed(key, request)
+		}
+		return
+	}
+	select {
+	case <-s.closed:
+		if err != nil {
+			return
+		}
+	default:
+	}
+	s.unpersist(request)
+}
+
+// keepPreviewed keeps the persisted file of a request that was walked in
+// preview mode, so that only the newest file of every satellite and range is
+// kept. Requires mutex to be held.
+func (s *Service) keepPreviewed(key queueKey, request Request) {
+	if request.path == "" {
+		return
+	}
+	if previous, ok := s.previewed[key]; ok && previous.path != request.path {
+		if previous.CreatedBefore.After(request.CreatedBefore) {
+			s.unpersist(request)
+			return
+		}
+		s.unpersist(previous)
+	}
+	// the filter isn't needed to remove the file later.
+	s.previewed[key] = Request{CreatedBefore: request.CreatedBefore, path: request.path}
+}
+
+// Override queues the halted requests of the satellite again, to be processed
+// without the cap on the delete ratio. It returns the number of requests that
+// were queued.
+func (s *Service) Override(satelliteID storj.NodeID) int {
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+
+	select {
+	case <-s.closed:
+		return 0
+	default:
+	}
+
+	count := 0
+	for key, request := range s.halted {
+		if key.satelliteID != satelliteID {
+			continue
+		}
+		delete(s.halted, key)
+
+		request.override = true
+		s.queued[key] = request
+		count++
+
+		s.log.Info("retain request overridden by the operator",
+			zap.Stringer("Satellite ID", request.SatelliteID),
+			zap.Time("Created Before", request.CreatedBefore))
+	}
+	s.cond.Broadcast()
+	return count
 }
 
 // Close causes any pending Run to exit and waits for any retain requests to
@@ -343,7 +898,7 @@ func (s *Service) Status() Status {
 // nontrivial amount, mtimes on existing blobs should also be adjusted (by the same interval,
 // ideally, but just running "touch" on all blobs is sufficient to avoid incorrect deletion of
 // data).
-func (s *Service) retainPieces(ctx context.Context, req Request) (err error) {
+func (s *Service) retainPieces(ctx context.Context, req Request, active *activeRequest) (err error) {
 	// if retain status is disabled, return immediately
 	if s.config.Status == Disabled {
 		return nil
@@ -354,33 +909,159 @@ func (s *Service) retainPieces(ctx context.Context, req Request) (err error) {
 	var piecesCount int64
 	var piecesSkipped int64
 	var piecesToDeleteCount int64
+	var piecesToDeleteSize int64
+	var operations int64
+	// pending are the pieces that were handed to the trash queue, but were
+	// not moved yet.
+	var pending sync.WaitGroup
+	lastFlush := time.Now()
 	numDeleted := 0
 	satelliteID := req.SatelliteID
 	filter := req.Filter
 
+	// the delete ratio is only capped when pieces are moved to the trash. The
+	// condemned pieces are held back until enough pieces were examined for the
+	// ratio to be meaningful, so that a halted request didn't trash anything.
+	capped := s.config.Status == Enabled && s.config.MaxDeleteRatio > 0 && !req.override
+	var piecesExamined, piecesCondemned int64
+	var held []heldPiece
+
 	// subtract some time to leave room for clock difference between the satellite and storage node
-	createdBefore := req.CreatedBefore.Add(-s.config.MaxTimeSkew)
+	createdBefore := req.CreatedBefore.Add(-s.config.TimeSkew(satelliteID))
 	started := time.Now().UTC()
 	filterHashCount, _ := req.Filter.Parameters()
 	mon.IntVal("garbage_collection_created_before").Observe(createdBefore.Unix())
 	mon.IntVal("garbage_collection_filter_hash_count").Observe(int64(filterHashCount))
 	mon.IntVal("garbage_collection_filter_size").Observe(filter.Size())
 	mon.IntVal("garbage_collection_started").Observe(started.Unix())
+	mon.FloatVal("garbage_collection_rate_limit").Observe(s.config.RateLimit)
 
 	s.log.Debug("Prepared to run a Retain request.",
 		zap.Time("Created Before", createdBefore),
 		zap.Int64("Filter Size", filter.Size()),
+		zap.Uint8("Range Bits", req.Range.Bits),
+		zap.Uint32("Range Index", req.Range.Index),
 		zap.Stringer("Satellite ID", satelliteID))
 
-	err = s.store.WalkSatellitePieces(ctx, satelliteID, func(access pieces.StoredPieceAccess) (err error) {
+	expiring, err := s.expiringPieces(ctx, req)
+	if err != nil {
+		// the pieces are evaluated as usual.
+		s.log.Warn("failed to load expiring pieces",
+			zap.Stringer("Satellite ID", satelliteID),
+			zap.Error(err))
+	}
+
+	if req.checkpoint != "" {
+		mon.Meter("retain_requests_resumed").Mark(1)
+		s.log.Info("resuming interrupted retain request",
+			zap.Stringer("Satellite ID", satelliteID),
+			zap.Time("Created Before", req.CreatedBefore),
+			zap.String("Key Prefix", req.checkpoint))
+	}
+
+	// trash hands the piece to the trash queue. Retain only ever moves pieces
+	// to the trash, and guarded pieces in particular must stay restorable.
+	trash := func(pieceID storj.PieceID, size int64, guarded bool) error {
+		if err := s.limit(ctx); err != nil {
+			return err
+		}
+		operations++
+
+		pending.Add(1)
+		queued := s.trashQueue.Enqueue(ctx, satelliteID, pieceID, func(err error) {
+			defer pending.Done()
+			if err != nil {
+				atomic.AddInt64(&active.failed, 1)
+				s.log.Warn("failed to delete piece",
+					zap.Stringer("Satellite ID", satelliteID),
+					zap.Stringer("Piece ID", pieceID),
+					zap.Error(err))
+				return
+			}
+			atomic.AddInt64(&active.deleted, 1)
+			atomic.AddInt64(&active.bytes, size)
+			if guarded {
+				atomic.AddInt64(&active.guarded, 1)
+			}
+		})
+		if !queued {
+			pending.Done()
+			return ctx.Err()
+		}
+
+		if time.Since(lastFlush) >= flushInterval {
+			s.flushSpaceUsed(ctx)
+			lastFlush = time.Now()
+		}
+		return nil
+	}
+
+	// release moves the held back pieces to the trash.
+	release := func() error {
+		for _, piece := range held {
+			if err := trash(piece.pieceID, piece.size, piece.guarded); err != nil {
+				return err
+			}
+		}
+		held = nil
+		return nil
+	}
+
+	// checkRatio halts the request when it condemned more than the maximum
+	// ratio of the examined pieces, and releases the held back pieces once
+	// enough pieces were examined otherwise.
+	checkRatio := func() error {
+		if !capped || piecesExamined < s.config.DeleteRatioSample {
+			return nil
+		}
+		ratio := float64(piecesCondemned) / float64(piecesExamined)
+		if ratio > s.config.MaxDeleteRatio {
+			mon.Meter("retain_requests_halted", monkit.NewSeriesTag("satellite", satelliteID.String())).Mark(1)
+			s.log.Error("retain request would move too many pieces to the trash, halting it until the operator overrides it",
+				zap.Stringer("Satellite ID", satelliteID),
+				zap.Time("Created Before", req.CreatedBefore),
+				zap.Int64("Pieces Examined", piecesExamined),
+				zap.Int64("Pieces Condemned", piecesCondemned),
+				zap.Int64("Pieces Held Back", int64(len(held))),
+				zap.Float64("Delete Ratio", ratio),
+				zap.Float64("Max Delete Ratio", s.config.MaxDeleteRatio))
+			return ErrDeleteRatioExceeded.New("%d of %d examined pieces would be trashed, which exceeds the maximum ratio of %v", piecesCondemned, piecesExamined, s.config.MaxDeleteRatio)
+		}
+		return release()
+	}
+
+	err = s.walkChunks(ctx, req, pending.Wait, func(access pieces.StoredPieceAccess) (err error) {
 		defer mon.Task()(&ctx)(&err)
+
+		// pieces outside of the range are covered by other requests.
+		if !req.Range.Contains(access.PieceID()) {
+			return nil
+		}
 		piecesCount++
+		atomic.AddInt64(&active.walked, 1)
+
+		// the collector deletes expired pieces anyway, there is no need to
+		// evaluate them.
+		if _, ok := expiring[access.PieceID()]; ok {
+			atomic.AddInt64(&active.expiring, 1)
+			return nil
+		}
+
+		if s.config.BatchSleep > 0 && piecesCount%batchSize == 0 {
+			if !sync2.Sleep(ctx, s.config.BatchSleep) {
+				return ctx.Err()
+			}
+		}
 
 		// We call Gosched() when done because the GC process is expected to be long and we want to keep it at low priority,
 		// so other goroutines can continue serving requests.
 		defer runtime.Gosched()
 		// See the comment above the retainPieces() function for a discussion on the correctness
 		// of using ModTime in place of the more precise CreationTime.
+		if err := s.limit(ctx); err != nil {
+			return err
+		}
+		operations++
 		mTime, err := access.ModTime(ctx)
 		if err != nil {
 			piecesSkipped++
@@ -389,11 +1070,17 @@ func (s *Service) retainPieces(ctx context.Context, req Request) (err error) {
 			return nil
 		}
 
+		piecesExamined++
 		if !mTime.Before(createdBefore) {
-			return nil
+			return checkRatio()
 		}
 		pieceID := access.PieceID()
 		if !filter.Contains(pieceID) {
+			piecesCondemned++
+			if err := checkRatio(); err != nil {
+				return err
+			}
+
 			s.log.Debug("About to move piece to trash",
 				zap.Stringer("Satellite ID", satelliteID),
 				zap.Stringer("Piece ID", pieceID),
@@ -401,14 +1088,30 @@ func (s *Service) retainPieces(ctx context.Context, req Request) (err error) {
 
 			piecesToDeleteCount++
 
+			// the size is reported as the space that is, or in preview mode
+			// would be, reclaimed.
+			var size int64
+			if s.config.Status == Enabled || s.config.Status == Preview {
+				size, _, err = access.Size(ctx)
+				if err != nil {
+					s.log.Warn("failed to determine size of blob", zap.Stringer("Piece ID", pieceID), zap.Error(err))
+				}
+				piecesToDeleteSize += size
+			}
+
+			guarded := s.guarded(mTime, createdBefore)
+
 			// if retain status is enabled, delete pieceid
 			if s.config.Status == Enabled {
-				if err = s.trash(ctx, satelliteID, pieceID); err != nil {
-					s.log.Warn("failed to delete piece",
-						zap.Stringer("Satellite ID", satelliteID),
-						zap.Stringer("Piece ID", pieceID),
-						zap.Error(err))
-					return nil
+				if capped && piecesExamined < s.config.DeleteRatioSample {
+					held = append(held, heldPiece{pieceID: pieceID, size: size, guarded: guarded})
+				} else if err := trash(pieceID, size, guarded); err != nil {
+					return err
+				}
+			} else {
+				atomic.AddInt64(&active.deleted, 1)
+				if guarded {
+					atomic.AddInt64(&active.guarded, 1)
 				}
 			}
 			numDeleted++
@@ -420,8 +1123,27 @@ func (s *Service) retainPieces(ctx context.Context, req Request) (err error) {
 		default:
 		}
 
-		return nil
+		return checkRatio()
 	})
+	// in namespaces smaller than the sample the ratio is too noisy to be
+	// enforced, and the held back pieces are moved to the trash as usual.
+	if err == nil {
+		err = release()
+	}
+	// the walk is done when the trash queue processed all of its pieces.
+	pending.Wait()
+
+	piecesExpiring := atomic.LoadInt64(&active.expiring)
+	piecesGuarded := atomic.LoadInt64(&active.guarded)
+	var piecesTrashed, piecesFailed, bytesTrashed int64
+	if s.config.Status == Enabled {
+		piecesTrashed = atomic.LoadInt64(&active.deleted)
+		piecesFailed = atomic.LoadInt64(&active.failed)
+		bytesTrashed = atomic.LoadInt64(&active.bytes)
+	}
+	if piecesTrashed > 0 {
+		s.flushSpaceUsed(ctx)
+	}
 	if err != nil {
 		return Error.Wrap(err)
 	}
@@ -429,14 +1151,142 @@ func (s *Service) retainPieces(ctx context.Context, req Request) (err error) {
 	mon.IntVal("garbage_collection_pieces_skipped").Observe(piecesSkipped)
 	mon.IntVal("garbage_collection_pieces_to_delete_count").Observe(piecesToDeleteCount)
 	mon.IntVal("garbage_collection_pieces_deleted").Observe(int64(numDeleted))
-	mon.DurationVal("garbage_collection_loop_duration").Observe(time.Now().UTC().Sub(started))
-	s.log.Debug("Moved pieces to trash during retain", zap.Int("num deleted", numDeleted), zap.String("Retain Status", s.config.Status.String()))
+	duration := time.Now().UTC().Sub(started)
+	mon.DurationVal("garbage_collection_loop_duration").Observe(duration)
+	if duration > 0 {
+		mon.FloatVal("garbage_collection_operations_per_second").Observe(float64(operations) / duration.Seconds())
+	}
+
+	filterAge := started.Sub(req.CreatedBefore)
+	satelliteTag := monkit.NewSeriesTag("satellite", satelliteID.String())
+	mon.IntVal("retain_pieces_examined", satelliteTag).Observe(piecesCount)
+	mon.IntVal("retain_pieces_retained", satelliteTag).Observe(piecesCount - piecesTrashed)
+	mon.IntVal("retain_pieces_trashed", satelliteTag).Observe(piecesTrashed)
+	mon.IntVal("retain_pieces_failed", satelliteTag).Observe(piecesFailed)
+	mon.IntVal("retain_pieces_left_to_expiration", satelliteTag).Observe(piecesExpiring)
+	mon.IntVal("retain_pieces_guarded", satelliteTag).Observe(piecesGuarded)
+	mon.IntVal("retain_bytes_reclaimed", satelliteTag).Observe(bytesTrashed)
+	mon.IntVal("retain_filter_size", satelliteTag).Observe(filter.Size())
+	mon.DurationVal("retain_filter_age", satelliteTag).Observe(filterAge)
+	mon.DurationVal("retain_duration", satelliteTag).Observe(duration)
+
+	if s.config.Status == Preview {
+		mon.IntVal("garbage_collection_preview_pieces").Observe(piecesToDeleteCount)
+		mon.IntVal("garbage_collection_preview_bytes").Observe(piecesToDeleteSize)
+		s.log.Info("PREVIEW: retain would move pieces to trash, nothing was deleted",
+			zap.Stringer("Satellite ID", satelliteID),
+			zap.Int64("Pieces", piecesToDeleteCount),
+			zap.Int64("Bytes", piecesToDeleteSize),
+			zap.Int64("Pieces Walked", piecesCount))
+		return nil
+	}
+	s.log.Info("Moved pieces to trash during retain",
+		zap.Stringer("Satellite ID", satelliteID),
+		zap.String("Retain Status", s.config.Status.String()),
+		zap.Int64("Pieces Examined", piecesCount),
+		zap.Int64("Pieces Retained", piecesCount-piecesTrashed),
+		zap.Int64("Pieces Trashed", piecesTrashed),
+		zap.Int64("Pieces Failed", piecesFailed),
+		zap.Int64("Pieces Left To Expiration", piecesExpiring),
+		zap.Int64("Pieces Guarded", piecesGuarded),
+		zap.Int64("Bytes Reclaimed", bytesTrashed),
+		zap.Int64("Filter Size", filter.Size()),
+		zap.Duration("Filter Age", filterAge),
+		zap.Duration("Duration", duration))
 
 	return nil
 }
 
-// trash wraps retains piece deletion to monitor moving retained piece to trash error during garbage collection.
-func (s *Service) trash(ctx context.Context, satelliteID storj.NodeID, pieceID storj.PieceID) (err error) {
-	defer mon.Task()(&ctx, satelliteID)(&err)
-	return s.store.Trash(ctx, satelliteID, pieceID)
+// walkChunks walks the pieces of the request's satellite one key prefix at a
+// time, so that only a single directory is listed at once, and records a
+// checkpoint after every prefix. The prefixes up to the checkpoint of an
+// interrupted request are skipped, since their pieces were walked before.
+// settle waits for the pieces of a prefix to be moved to the trash before its
+// checkpoint is recorded. The V0 pieces are walked last.
+//
+// Checkpoints are only recorded while retain is enabled, so that pieces that
+// were only evaluated in another mode are walked again once it is enabled.
+func (s *Service) walkChunks(ctx context.Context, req Request, settle func(), walkFunc func(pieces.StoredPieceAccess) error) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	prefixes, err := s.store.SatelliteKeyPrefixes(ctx, req.SatelliteID)
+	if err != nil {
+		return err
+	}
+	for _, prefix := range prefixes {
+		if req.checkpoint != "" && prefix <= req.checkpoint {
+			continue
+		}
+		if err := s.store.WalkSatellitePiecesPrefix(ctx, req.SatelliteID, prefix, walkFunc); err != nil {
+			return err
+		}
+		if s.config.Status != Enabled {
+			continue
+		}
+		settle()
+		if err := ctx.Err(); err != nil {
+			// the moves of the last pieces may have been canceled.
+			return err
+		}
+		if err := s.saveCheckpoint(req, prefix); err != nil {
+			s.log.Warn("failed to persist retain checkpoint",
+				zap.Stringer("Satellite ID", req.SatelliteID),
+				zap.String("Key Prefix", prefix),
+				zap.Error(err))
+		}
+	}
+	return s.store.WalkSatelliteV0Pieces(ctx, req.SatelliteID, walkFunc)
+}
+
+// guarded returns whether a piece modified at modTime is within the guard
+// window before createdBefore, the creation date of the filter less the time
+// skew, which no condemned piece is modified after. Such pieces may have been
+// uploaded while the filter was created, or the filter may be wrongly dated.
+func (s *Service) guarded(modTime, createdBefore time.Time) bool {
+	return s.config.GuardWindow > 0 && !modTime.Before(createdBefore.Add(-s.config.GuardWindow))
+}
+
+// expiringPieces returns the pieces of the request's range that expired or
+// expire within the configured window. The expirations are loaded when the
+// walk starts, so that a piece whose expiration was removed or extended since
+// is evaluated as usual.
+func (s *Service) expiringPieces(ctx context.Context, req Request) (_ map[storj.PieceID]struct{}, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	pieceIDs, err := s.store.GetExpiringBySatellite(ctx, req.SatelliteID, time.Now().Add(s.config.ExpirationWindow))
+	if err != nil {
+		return nil, err
+	}
+	expiring := make(map[storj.PieceID]struct{})
+	for _, pieceID := range pieceIDs {
+		if req.Range.Contains(pieceID) {
+			expiring[pieceID] = struct{}{}
+		}
+	}
+	return expiring, nil
+}
+
+// flushSpaceUsed persists the space used cache, if there is one.
+func (s *Service) flushSpaceUsed(ctx context.Context) {
+	if s.spaceUsed == nil {
+		return
+	}
+	if err := s.spaceUsed.PersistCacheTotals(ctx); err != nil {
+		s.log.Warn("failed to persist space used cache", zap.Error(err))
+	}
+}
+
+// limit waits until the rate limit allows another IO operation of the walk.
+// It does not apply to any other piece operations of the node.
+func (s *Service) limit(ctx context.Context) error {
+	if s.limiter == nil {
+		return nil
+	}
+	return s.limiter.Wait(ctx)
+}
+
+// TrashFailures returns the most recent pieces that could not be moved to the
+// trash, oldest first.
+func (s *Service) TrashFailures() []pieces.TrashFailure {
+	return s.trashQueue.Failures()
 }
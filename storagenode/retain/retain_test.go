@@ -5,10 +5,21 @@ package retain_test
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
 	"go.uber.org/zap/zaptest"
 	"golang.org/x/sync/errgroup"
 
@@ -21,6 +32,8 @@ import (
 	"storj.io/common/storj"
 	"storj.io/common/testcontext"
 	"storj.io/common/testrand"
+	"storj.io/private/cfgstruct"
+	"storj.io/storj/private/retainfilter"
 	"storj.io/storj/storage"
 	"storj.io/storj/storage/filestore"
 	"storj.io/storj/storagenode"
@@ -104,19 +117,19 @@ func TestRetainPieces(t *testing.T) {
 			}
 		}
 
-		retainEnabled := retain.NewService(zaptest.NewLogger(t), store, retain.Config{
+		retainEnabled := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
 			Status:      retain.Enabled,
 			Concurrency: 1,
 			MaxTimeSkew: 0,
 		})
 
-		retainDisabled := retain.NewService(zaptest.NewLogger(t), store, retain.Config{
+		retainDisabled := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
 			Status:      retain.Disabled,
 			Concurrency: 1,
 			MaxTimeSkew: 0,
 		})
 
-		retainDebug := retain.NewService(zaptest.NewLogger(t), store, retain.Config{
+		retainDebug := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
 			Status:      retain.Debug,
 			Concurrency: 1,
 			MaxTimeSkew: 0,
@@ -194,19 +207,1664 @@ func TestRetainPieces(t *testing.T) {
 	})
 }
 
+func TestRetainPiecesRange(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		const numPieces = 100
+		pieceIDs := generateTestIDs(numPieces)
+		for _, id := range pieceIDs {
+			w, err := testStore.WriterForFormatVersion(ctx, satellite.ID, id, filestore.FormatV1)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(100 * memory.B))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+		}
+
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+		})
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+
+		// the pieces are split in two ranges, the first range keeps every
+		// piece and the second range keeps none.
+		keep := bloomfilter.NewOptimal(numPieces, 0.000000001)
+		for _, id := range pieceIDs {
+			keep.Add(id)
+		}
+		first := retainfilter.Range{Bits: 1, Index: 0}
+		second := retainfilter.Range{Bits: 1, Index: 1}
+
+		// requests for different ranges of the same satellite must not replace each other.
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: time.Now().Add(time.Hour),
+			Filter:        keep,
+			Range:         first,
+		}))
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: time.Now().Add(time.Hour),
+			Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+			Range:         second,
+		}))
+		service.TestWaitUntilEmpty()
+
+		remaining, err := getAllPieceIDs(ctx, store, satellite.ID)
+		require.NoError(t, err)
+		for _, id := range pieceIDs {
+			if first.Contains(id) {
+				require.Contains(t, remaining, id, "piece in the first range should have been kept")
+			} else {
+				require.NotContains(t, remaining, id, "piece in the second range should have been deleted")
+			}
+		}
+
+		cancel()
+		err = group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+	})
+}
+
+func TestRetainPersisted(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		const numPieces = 100
+		pieceIDs := generateTestIDs(numPieces)
+		for _, id := range pieceIDs {
+			w, err := testStore.WriterForFormatVersion(ctx, satellite.ID, id, filestore.FormatV1)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(100 * memory.B))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+		}
+
+		config := retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+			Path:        ctx.Dir("retain"),
+		}
+
+		// queue a request that keeps nothing and restart before it is processed.
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: time.Now().Add(time.Hour),
+			Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+		}))
+		require.NoError(t, service.Close())
+
+		// a corrupt request must be discarded.
+		require.NoError(t, ioutil.WriteFile(filepath.Join(config.Path, "corrupt.retain"), []byte("corrupt"), 0600))
+
+		service = retain.NewService(zaptest.NewLogger(t), store, nil, config)
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+		service.TestWaitUntilEmpty()
+
+		remaining, err := getAllPieceIDs(ctx, store, satellite.ID)
+		require.NoError(t, err)
+		require.Empty(t, remaining)
+
+		// processed and corrupt requests are removed.
+		entries, err := ioutil.ReadDir(config.Path)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+
+		cancel()
+		err = group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+	})
+}
+
+func TestRetainPersistedFilterSize(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		config := retain.Config{
+			Status:        retain.Enabled,
+			Concurrency:   1,
+			Path:          ctx.Dir("retain"),
+			MaxFilterSize: memory.KiB,
+		}
+
+		// the filter takes exactly the maximum size with its header.
+		filter := bloomfilter.NewOptimalMaxSize(10000, 0.1, memory.KiB-3).Bytes()
+		require.Len(t, filter, memory.KiB.Int())
+
+		createdBefore := time.Now().Add(-time.Hour)
+		req, err := retain.DecodeRequest(satellite.ID, createdBefore, filter)
+		require.NoError(t, err)
+
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+		require.True(t, service.Queue(req))
+		require.NoError(t, service.Close())
+
+		// a filter at the limit is loaded after a restart.
+		service = retain.NewService(zaptest.NewLogger(t), store, nil, config)
+		progress := service.Progress()
+		require.Len(t, progress, 1)
+		require.True(t, progress[0].CreatedBefore.Equal(createdBefore))
+		require.NoError(t, service.Close())
+
+		// a filter over the limit, e.g. because it was lowered, is discarded
+		// without being read.
+		config.MaxFilterSize = memory.KiB - 1
+		service = retain.NewService(zaptest.NewLogger(t), store, nil, config)
+		require.Empty(t, service.Progress())
+		require.NoError(t, service.Close())
+
+		entries, err := ioutil.ReadDir(config.Path)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+}
+
+func TestRetainProgress(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		const numPieces = 300
+		pieceIDs := generateTestIDs(numPieces)
+		for _, id := range pieceIDs {
+			w, err := testStore.WriterForFormatVersion(ctx, satellite.ID, id, filestore.FormatV1)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(100 * memory.B))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+		}
+
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+		})
+		require.Empty(t, service.Progress())
+
+		createdBefore := time.Now().Add(time.Hour)
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: createdBefore,
+			Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+		}))
+
+		progress := service.Progress()
+		require.Len(t, progress, 1)
+		require.Equal(t, satellite.ID, progress[0].SatelliteID)
+		require.True(t, progress[0].CreatedBefore.Equal(createdBefore))
+		require.False(t, progress[0].Received.IsZero())
+		require.False(t, progress[0].Active)
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+
+		// poll until the request is done, the counters must never go backwards.
+		var walked, deleted int64
+		for {
+			progress := service.Progress()
+			if len(progress) == 0 {
+				break
+			}
+			require.Len(t, progress, 1)
+			if progress[0].Active {
+				require.GreaterOrEqual(t, progress[0].PiecesWalked, walked)
+				require.GreaterOrEqual(t, progress[0].PiecesDeleted, deleted)
+				require.LessOrEqual(t, progress[0].PiecesDeleted, progress[0].PiecesWalked)
+				require.LessOrEqual(t, progress[0].PiecesWalked, int64(numPieces))
+				walked, deleted = progress[0].PiecesWalked, progress[0].PiecesDeleted
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		remaining, err := getAllPieceIDs(ctx, store, satellite.ID)
+		require.NoError(t, err)
+		require.Empty(t, remaining)
+
+		cancel()
+		err = group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+	})
+}
+
+func TestRetainConcurrency(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellites := []storj.NodeID{testrand.NodeID(), testrand.NodeID()}
+
+		const numPieces = 200
+		for _, satelliteID := range satellites {
+			for _, id := range generateTestIDs(numPieces) {
+				w, err := testStore.WriterForFormatVersion(ctx, satelliteID, id, filestore.FormatV1)
+				require.NoError(t, err)
+				_, err = w.Write(testrand.Bytes(100 * memory.B))
+				require.NoError(t, err)
+				require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+			}
+		}
+
+		config := retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 2,
+		}
+		require.NoError(t, config.Verify())
+		require.Error(t, retain.Config{Status: retain.Enabled}.Verify())
+
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+
+		// every satellite gets a request for each of two ranges, which may
+		// not be processed at the same time.
+		for _, satelliteID := range satellites {
+			for index := uint32(0); index < 2; index++ {
+				require.True(t, service.Queue(retain.Request{
+					SatelliteID:   satelliteID,
+					CreatedBefore: time.Now().Add(time.Hour),
+					Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+					Range:         retainfilter.Range{Bits: 1, Index: index},
+				}))
+			}
+		}
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+
+		for {
+			progress := service.Progress()
+			if len(progress) == 0 {
+				break
+			}
+
+			active := map[storj.NodeID]int{}
+			for _, p := range progress {
+				if p.Active {
+					active[p.SatelliteID]++
+				}
+			}
+			require.LessOrEqual(t, len(active), config.Concurrency)
+			for satelliteID, count := range active {
+				require.Equal(t, 1, count, "satellite %s has concurrent requests", satelliteID)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		for _, satelliteID := range satellites {
+			remaining, err := getAllPieceIDs(ctx, store, satelliteID)
+			require.NoError(t, err)
+			require.Empty(t, remaining)
+		}
+
+		cancel()
+		err := group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+	})
+}
+
+func TestRetainPreview(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		const numPieces = 100
+		for _, id := range generateTestIDs(numPieces) {
+			w, err := testStore.WriterForFormatVersion(ctx, satellite.ID, id, filestore.FormatV1)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(100 * memory.B))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+		}
+
+		config := retain.Config{
+			Status:      retain.Preview,
+			Concurrency: 1,
+			Path:        ctx.Dir("retain"),
+		}
+
+		run := func(config retain.Config, queue bool) {
+			service := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+			if queue {
+				require.True(t, service.Queue(retain.Request{
+					SatelliteID:   satellite.ID,
+					CreatedBefore: time.Now().Add(time.Hour),
+					Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+				}))
+			}
+
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			var group errgroup.Group
+			group.Go(func() error {
+				return service.Run(runCtx)
+			})
+			service.TestWaitUntilEmpty()
+
+			cancel()
+			err := group.Wait()
+			require.True(t, errs2.IsCanceled(err))
+		}
+
+		// a preview must not delete anything.
+		run(config, true)
+		remaining, err := getAllPieceIDs(ctx, store, satellite.ID)
+		require.NoError(t, err)
+		require.Len(t, remaining, numPieces)
+
+		// after enabling retain, the same request is processed again.
+		config.Status = retain.Enabled
+		run(config, false)
+		remaining, err = getAllPieceIDs(ctx, store, satellite.ID)
+		require.NoError(t, err)
+		require.Empty(t, remaining)
+	})
+}
+
+func TestRetainPreviewKeepsNewest(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		config := retain.Config{
+			Status:      retain.Preview,
+			Concurrency: 1,
+			Path:        ctx.Dir("retain"),
+		}
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+
+		// every previewed request replaces the file of the one before.
+		createdBefore := time.Now().Add(time.Hour)
+		for i := 0; i < 3; i++ {
+			require.True(t, service.Queue(retain.Request{
+				SatelliteID:   satellite.ID,
+				CreatedBefore: createdBefore.Add(time.Duration(i) * time.Minute),
+				Filter:        bloomfilter.NewOptimal(10, 0.1),
+			}))
+			service.TestWaitUntilEmpty()
+		}
+
+		var persisted []string
+		entries, err := ioutil.ReadDir(config.Path)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			if filepath.Ext(entry.Name()) == ".retain" {
+				persisted = append(persisted, entry.Name())
+			}
+		}
+		require.Len(t, persisted, 1)
+
+		cancel()
+		err = group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+
+		// the newest request is the one that is kept, so a restarted service
+		// queues it again.
+		restarted := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+		progress := restarted.Progress()
+		require.Len(t, progress, 1)
+		require.True(t, progress[0].CreatedBefore.Equal(createdBefore.Add(2*time.Minute)))
+		require.NoError(t, restarted.Close())
+	})
+}
+
+func TestRetainRateLimit(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		const numPieces = 50
+		keep := bloomfilter.NewOptimal(numPieces, 0.000000001)
+		for _, id := range generateTestIDs(numPieces) {
+			keep.Add(id)
+			w, err := testStore.WriterForFormatVersion(ctx, satellite.ID, id, filestore.FormatV1)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(100 * memory.B))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+		}
+
+		// every walked piece is one operation, so the walk takes at least
+		// half a second at 100 operations per second.
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+			RateLimit:   100,
+		})
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+
+		start := time.Now()
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: time.Now().Add(time.Hour),
+			Filter:        keep,
+		}))
+		service.TestWaitUntilEmpty()
+		require.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+
+		remaining, err := getAllPieceIDs(ctx, store, satellite.ID)
+		require.NoError(t, err)
+		require.Len(t, remaining, numPieces)
+
+		cancel()
+		err = group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+	})
+}
+
+func TestRetainMetrics(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		const numLive, numGarbage = 20, 10
+		const size = 100 * memory.B
+		keep := bloomfilter.NewOptimal(numLive, 0.000000001)
+		for i, id := range generateTestIDs(numLive + numGarbage) {
+			if i < numLive {
+				keep.Add(id)
+			}
+			w, err := testStore.WriterForFormatVersion(ctx, satellite.ID, id, filestore.FormatV1)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(size))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+		}
+
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+		})
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: time.Now().Add(time.Hour),
+			Filter:        keep,
+		}))
+		service.TestWaitUntilEmpty()
+
+		stats := monkit.Collect(monkit.ScopeNamed("storj.io/storj/storagenode/retain"))
+		key := func(name, field string) string {
+			return name + ",satellite=" + satellite.ID.String() + ",scope=storj.io/storj/storagenode/retain " + field
+		}
+		require.Equal(t, float64(numLive+numGarbage), stats[key("retain_pieces_examined", "recent")])
+		require.Equal(t, float64(numLive), stats[key("retain_pieces_retained", "recent")])
+		require.Equal(t, float64(numGarbage), stats[key("retain_pieces_trashed", "recent")])
+		// the reclaimed bytes include the piece headers.
+		require.GreaterOrEqual(t, stats[key("retain_bytes_reclaimed", "recent")], float64(numGarbage*size.Int64()))
+		require.Equal(t, float64(keep.Size()), stats[key("retain_filter_size", "recent")])
+		require.NotZero(t, stats[key("retain_duration", "count")])
+
+		cancel()
+		err := group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+	})
+}
+
+func TestSkewOverrides(t *testing.T) {
+	satellite0, satellite1 := testrand.NodeID(), testrand.NodeID()
+
+	var overrides retain.SkewOverrides
+	require.NoError(t, overrides.Set(""))
+	require.Empty(t, overrides)
+
+	require.NoError(t, overrides.Set(satellite0.String()+"@:48h, "+satellite1.String()+":30m"))
+	require.Equal(t, retain.SkewOverrides{
+		satellite0: 48 * time.Hour,
+		satellite1: 30 * time.Minute,
+	}, overrides)
+
+	// the string form can be parsed again.
+	var parsed retain.SkewOverrides
+	require.NoError(t, parsed.Set(overrides.String()))
+	require.Equal(t, overrides, parsed)
+
+	for _, invalid := range []string{
+		satellite0.String(),
+		satellite0.String() + "@:",
+		satellite0.String() + "@:forever",
+		satellite0.String() + "@:-1h",
+		"invalid@:1h",
+		satellite0.String() + "@:1h," + satellite0.String() + "@:2h",
+	} {
+		require.Error(t, parsed.Set(invalid), invalid)
+	}
+
+	config := retain.Config{
+		MaxTimeSkew:          time.Hour,
+		MaxTimeSkewOverrides: retain.SkewOverrides{satellite0: 0},
+	}
+	require.Equal(t, time.Duration(0), config.TimeSkew(satellite0))
+	require.Equal(t, time.Hour, config.TimeSkew(satellite1))
+}
+
+func TestRetainSkewOverride(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		// the pieces of both satellites were written an hour before the
+		// filter was created, only the override of satellite1 allows for a
+		// larger skew than that.
+		satellite0, satellite1 := testrand.NodeID(), testrand.NodeID()
+		const numPieces = 10
+		for _, satelliteID := range []storj.NodeID{satellite0, satellite1} {
+			for _, id := range generateTestIDs(numPieces) {
+				w, err := testStore.WriterForFormatVersion(ctx, satelliteID, id, filestore.FormatV1)
+				require.NoError(t, err)
+				_, err = w.Write(testrand.Bytes(100 * memory.B))
+				require.NoError(t, err)
+				require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+			}
+		}
+
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
+			Status:               retain.Enabled,
+			Concurrency:          1,
+			MaxTimeSkew:          0,
+			MaxTimeSkewOverrides: retain.SkewOverrides{satellite1: 2 * time.Hour},
+		})
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+
+		for _, satelliteID := range []storj.NodeID{satellite0, satellite1} {
+			require.True(t, service.Queue(retain.Request{
+				SatelliteID:   satelliteID,
+				CreatedBefore: time.Now().Add(time.Hour),
+				Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+			}))
+		}
+		service.TestWaitUntilEmpty()
+
+		remaining, err := getAllPieceIDs(ctx, store, satellite0)
+		require.NoError(t, err)
+		require.Empty(t, remaining)
+
+		remaining, err = getAllPieceIDs(ctx, store, satellite1)
+		require.NoError(t, err)
+		require.Len(t, remaining, numPieces)
+
+		cancel()
+		err = group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+	})
+}
+
+func TestRetainSubscribe(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		const numPieces = 10
+		for _, id := range generateTestIDs(numPieces) {
+			w, err := testStore.WriterForFormatVersion(ctx, satellite.ID, id, filestore.FormatV1)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(100 * memory.B))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+		}
+
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+		})
+
+		var mu sync.Mutex
+		var summaries []retain.Summary
+		unsubscribe := service.Subscribe(func(summary retain.Summary) {
+			mu.Lock()
+			defer mu.Unlock()
+			summaries = append(summaries, summary)
+		})
+		defer unsubscribe()
+
+		// the first request is replaced before it is processed.
+		replaced := time.Now().Add(time.Hour)
+		processed := replaced.Add(time.Minute)
+		for _, createdBefore := range []time.Time{replaced, processed} {
+			require.True(t, service.Queue(retain.Request{
+				SatelliteID:   satellite.ID,
+				CreatedBefore: createdBefore,
+				Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+			}))
+		}
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+		require.NoError(t, service.WaitIdle(ctx))
+
+		cancel()
+		err := group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, summaries, 2)
+
+		require.True(t, summaries[0].CreatedBefore.Equal(replaced))
+		require.ErrorIs(t, summaries[0].Err, retain.ErrReplaced)
+
+		require.True(t, summaries[1].CreatedBefore.Equal(processed))
+		require.NoError(t, summaries[1].Err)
+		require.Equal(t, satellite.ID, summaries[1].SatelliteID)
+		require.EqualValues(t, numPieces, summaries[1].PiecesWalked)
+		require.EqualValues(t, numPieces, summaries[1].PiecesDeleted)
+		require.NotZero(t, summaries[1].BytesDeleted)
+	})
+}
+
+func TestRetainExpiring(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		const window = time.Hour
+		now := time.Now()
+		expired, expiring, later, unlimited, removed := testrand.PieceID(), testrand.PieceID(), testrand.PieceID(), testrand.PieceID(), testrand.PieceID()
+		expirations := map[storj.PieceID]time.Time{
+			expired:  now.Add(-time.Hour),
+			expiring: now.Add(window / 2),
+			later:    now.Add(2 * window),
+			removed:  now.Add(-time.Hour),
+		}
+		for _, id := range []storj.PieceID{expired, expiring, later, unlimited, removed} {
+			w, err := testStore.WriterForFormatVersion(ctx, satellite.ID, id, filestore.FormatV1)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(100 * memory.B))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: now}))
+
+			if expiration, ok := expirations[id]; ok {
+				require.NoError(t, store.SetExpiration(ctx, satellite.ID, id, expiration))
+			}
+		}
+		// the expiration of a piece that was removed doesn't apply anymore.
+		found, err := db.PieceExpirationDB().DeleteExpiration(ctx, satellite.ID, removed)
+		require.NoError(t, err)
+		require.True(t, found)
+
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
+			Status:           retain.Enabled,
+			Concurrency:      1,
+			ExpirationWindow: window,
+		})
+
+		summaries := make(chan retain.Summary, 1)
+		unsubscribe := service.Subscribe(func(summary retain.Summary) {
+			summaries <- summary
+		})
+		defer unsubscribe()
+
+		// the request keeps nothing.
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: now.Add(time.Hour),
+			Filter:        bloomfilter.NewOptimal(10, 0.000000001),
+		}))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+		summary := <-summaries
+		cancel()
+		require.True(t, errs2.IsCanceled(group.Wait()))
+
+		require.NoError(t, summary.Err)
+		require.EqualValues(t, 5, summary.PiecesWalked)
+		require.EqualValues(t, 2, summary.PiecesLeftToExpiration)
+		require.EqualValues(t, 3, summary.PiecesDeleted)
+
+		// the expired and soon expiring pieces are left to the collector.
+		remaining, err := getAllPieceIDs(ctx, store, satellite.ID)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []storj.PieceID{expired, expiring}, remaining)
+	})
+}
+
+func TestRetainSpaceUsed(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		log := zaptest.NewLogger(t)
+		store := pieces.NewStore(log, db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		// half of the pieces are garbage.
+		const numPieces = 20
+		keep := bloomfilter.NewOptimal(numPieces, 0.000000001)
+		for i, id := range generateTestIDs(numPieces) {
+			if i%2 == 0 {
+				keep.Add(id)
+			}
+			w, err := testStore.WriterForFormatVersion(ctx, satellite.ID, id, filestore.FormatV1)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(100 * memory.B))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+		}
+
+		// the cache starts with the totals of a full recalculation, and is only
+		// updated incrementally by retain.
+		piecesTotal, piecesContentSize, totalsBySatellite, err := store.SpaceUsedTotalAndBySatellite(ctx)
+		require.NoError(t, err)
+		cache := pieces.NewBlobsUsageCacheTest(log, db.Pieces(), piecesTotal, piecesContentSize, 0, totalsBySatellite)
+		cachedStore := pieces.NewStore(log, cache, db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		cacheService := pieces.NewService(log, cache, cachedStore, time.Hour)
+		require.NoError(t, db.PieceSpaceUsedDB().Init(ctx))
+
+		service := retain.NewService(log, cachedStore, cacheService, retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+		})
+		var summary retain.Summary
+		unsubscribe := service.Subscribe(func(s retain.Summary) { summary = s })
+		defer unsubscribe()
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: time.Now().Add(time.Hour),
+			Filter:        keep,
+		}))
+		require.NoError(t, service.WaitIdle(ctx))
+
+		cancel()
+		err = group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+
+		require.NoError(t, summary.Err)
+		require.EqualValues(t, numPieces/2, summary.PiecesDeleted)
+		reclaimed := summary.BytesDeleted
+		require.NotZero(t, reclaimed)
+
+		// the cache reflects the reclaimed bytes.
+		cachedTotal, _, err := cache.SpaceUsedBySatellite(ctx, satellite.ID)
+		require.NoError(t, err)
+		require.Equal(t, totalsBySatellite[satellite.ID].Total-reclaimed, cachedTotal)
+		trashTotal, err := cache.SpaceUsedForTrash(ctx)
+		require.NoError(t, err)
+		require.Equal(t, reclaimed, trashTotal)
+		require.True(t, cache.LastRecalculated().IsZero())
+
+		// and it was flushed to the database.
+		persisted, err := db.PieceSpaceUsedDB().GetPieceTotalsForAllSatellites(ctx)
+		require.NoError(t, err)
+		require.Equal(t, cachedTotal, persisted[satellite.ID].Total)
+	})
+}
+
 func getAllPieceIDs(ctx context.Context, store *pieces.Store, satellite storj.NodeID) (pieceIDs []storj.PieceID, err error) {
 	err = store.WalkSatellitePieces(ctx, satellite, func(pieceAccess pieces.StoredPieceAccess) error {
 		pieceIDs = append(pieceIDs, pieceAccess.PieceID())
 		return nil
 	})
-	return pieceIDs, err
+	return pieceIDs, err
+}
+
+// generateTestIDs generates n piece ids.
+func generateTestIDs(n int) []storj.PieceID {
+	ids := make([]storj.PieceID, n)
+	for i := range ids {
+		ids[i] = testrand.PieceID()
+	}
+	return ids
+}
+
+func TestRetainDeduplicate(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		const numPieces = 10
+		for _, id := range generateTestIDs(numPieces) {
+			w, err := testStore.WriterForFormatVersion(ctx, satellite.ID, id, filestore.FormatV1)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(100 * memory.B))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+		}
+
+		meter := func(name string) float64 {
+			stats := monkit.Collect(monkit.ScopeNamed("storj.io/storj/storagenode/retain"))
+			return stats[name+",satellite="+satellite.ID.String()+",scope=storj.io/storj/storagenode/retain total"]
+		}
+
+		request := func(createdBefore time.Time) retain.Request {
+			return retain.Request{
+				SatelliteID:   satellite.ID,
+				CreatedBefore: createdBefore,
+				Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+			}
+		}
+
+		// process runs the service until it is idle, with wait called while it
+		// is running, and returns the summaries of the requests.
+		process := func(t *testing.T, service *retain.Service, wait func()) []retain.Summary {
+			var mu sync.Mutex
+			var summaries []retain.Summary
+			unsubscribe := service.Subscribe(func(summary retain.Summary) {
+				mu.Lock()
+				defer mu.Unlock()
+				summaries = append(summaries, summary)
+			})
+			defer unsubscribe()
+
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			var group errgroup.Group
+			group.Go(func() error {
+				return service.Run(runCtx)
+			})
+			wait()
+			require.NoError(t, service.WaitIdle(ctx))
+
+			cancel()
+			err := group.Wait()
+			require.True(t, errs2.IsCanceled(err))
+
+			mu.Lock()
+			defer mu.Unlock()
+			return summaries
+		}
+
+		config := retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+		}
+		// the pieces are newer than the requests, so every request walks all
+		// of them without moving any to the trash.
+		created := time.Now().Add(-time.Hour)
+
+		t.Run("newer replaces queued", func(t *testing.T) {
+			service := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+			replacedBefore := meter("retain_requests_replaced")
+
+			require.True(t, service.Queue(request(created)))
+			require.True(t, service.Queue(request(created.Add(time.Minute))))
+			require.Len(t, service.Progress(), 1)
+			require.Equal(t, replacedBefore+1, meter("retain_requests_replaced"))
+
+			summaries := process(t, service, func() {})
+			require.Len(t, summaries, 1)
+			require.True(t, summaries[0].CreatedBefore.Equal(created.Add(time.Minute)))
+			require.NoError(t, summaries[0].Err)
+		})
+
+		t.Run("older or identical is dropped", func(t *testing.T) {
+			service := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+			droppedBefore := meter("retain_requests_dropped")
+
+			require.True(t, service.Queue(request(created)))
+			require.False(t, service.Queue(request(created.Add(-time.Minute))))
+			require.False(t, service.Queue(request(created)))
+			require.Equal(t, droppedBefore+2, meter("retain_requests_dropped"))
+
+			progress := service.Progress()
+			require.Len(t, progress, 1)
+			require.True(t, progress[0].CreatedBefore.Equal(created))
+
+			summaries := process(t, service, func() {})
+			require.Len(t, summaries, 1)
+			require.True(t, summaries[0].CreatedBefore.Equal(created))
+			require.NoError(t, summaries[0].Err)
+		})
+
+		t.Run("next while active", func(t *testing.T) {
+			// the rate limit keeps the first request active for a while.
+			config := config
+			config.RateLimit = 20
+			service := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+			droppedBefore := meter("retain_requests_dropped")
+			replacedBefore := meter("retain_requests_replaced")
+
+			require.True(t, service.Queue(request(created)))
+			summaries := process(t, service, func() {
+				require.Eventually(t, func() bool {
+					progress := service.Progress()
+					return len(progress) == 1 && progress[0].Active
+				}, 10*time.Second, time.Millisecond)
+
+				// not newer than the active request.
+				require.False(t, service.Queue(request(created)))
+				// the next slot is taken and then replaced.
+				require.True(t, service.Queue(request(created.Add(time.Minute))))
+				require.True(t, service.Queue(request(created.Add(2*time.Minute))))
+
+				require.Len(t, service.Progress(), 2)
+			})
+			require.Equal(t, droppedBefore+1, meter("retain_requests_dropped"))
+			require.Equal(t, replacedBefore+1, meter("retain_requests_replaced"))
+
+			require.Len(t, summaries, 3)
+			require.True(t, summaries[0].CreatedBefore.Equal(created.Add(time.Minute)))
+			require.ErrorIs(t, summaries[0].Err, retain.ErrReplaced)
+			require.True(t, summaries[1].CreatedBefore.Equal(created))
+			require.NoError(t, summaries[1].Err)
+			require.True(t, summaries[2].CreatedBefore.Equal(created.Add(2*time.Minute)))
+			require.NoError(t, summaries[2].Err)
+		})
+	})
 }
 
-// generateTestIDs generates n piece ids.
-func generateTestIDs(n int) []storj.PieceID {
-	ids := make([]storj.PieceID, n)
-	for i := range ids {
-		ids[i] = testrand.PieceID()
+func TestRetainChunked(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		// about a million pieces, which are generated while they are walked.
+		numPrefixes, piecesPerPrefix := 1024, 1024
+		if testing.Short() {
+			numPrefixes, piecesPerPrefix = 64, 64
+		}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+		dir := ctx.Dir("retain")
+
+		blobs := &syntheticBlobs{
+			piecesPerPrefix: piecesPerPrefix,
+			modTime:         time.Now(),
+		}
+		for i := 0; i < numPrefixes; i++ {
+			blobs.prefixes = append(blobs.prefixes, fmt.Sprintf("%04x", i))
+		}
+
+		var heapBaseline, heapMax uint64
+		blobs.beforeChunk = func(index int) {
+			// the checkpoint of the previous chunk is persisted before the
+			// next chunk is walked.
+			checkpoints, err := filepath.Glob(filepath.Join(dir, "*.checkpoint"))
+			require.NoError(t, err)
+			if index == 0 {
+				require.Empty(t, checkpoints)
+			} else {
+				require.Len(t, checkpoints, 1)
+				checkpoint, err := ioutil.ReadFile(checkpoints[0])
+				require.NoError(t, err)
+				require.Equal(t, blobs.prefixes[index-1], string(checkpoint))
+			}
+
+			// the live heap is sampled every few chunks, after the first
+			// chunks warmed up the caches.
+			if index%16 != 0 {
+				return
+			}
+			runtime.GC()
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			if index == 16 {
+				heapBaseline = stats.HeapAlloc
+			}
+			if stats.HeapAlloc > heapMax {
+				heapMax = stats.HeapAlloc
+			}
+		}
+
+		store := pieces.NewStore(zaptest.NewLogger(t), blobs, nil, db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+			Path:        dir,
+		})
+
+		var mu sync.Mutex
+		var summaries []retain.Summary
+		unsubscribe := service.Subscribe(func(summary retain.Summary) {
+			mu.Lock()
+			defer mu.Unlock()
+			summaries = append(summaries, summary)
+		})
+		defer unsubscribe()
+
+		// the pieces are newer than the request, so none are trashed.
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: time.Now().Add(-time.Hour),
+			Filter:        bloomfilter.NewOptimal(10, 0.1),
+		}))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+		require.NoError(t, service.WaitIdle(ctx))
+
+		cancel()
+		err := group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, summaries, 1)
+		require.NoError(t, summaries[0].Err)
+		require.EqualValues(t, numPrefixes*piecesPerPrefix, summaries[0].PiecesWalked)
+		require.Zero(t, summaries[0].PiecesDeleted)
+
+		// the checkpoint is removed with the finished request.
+		checkpoints, err := filepath.Glob(filepath.Join(dir, "*.checkpoint"))
+		require.NoError(t, err)
+		require.Empty(t, checkpoints)
+
+		if !testing.Short() {
+			require.Less(t, heapMax-heapBaseline, uint64(8*memory.MiB), "heap grew while walking")
+		}
+	})
+}
+
+func TestRetainResume(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		const numPieces = 200
+		pieceIDs := generateTestIDs(numPieces)
+		filter := bloomfilter.NewOptimal(numPieces, 0.000000001)
+		var kept []storj.PieceID
+		for _, id := range pieceIDs[:numPieces/2] {
+			filter.Add(id)
+			kept = append(kept, id)
+		}
+		request := retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: time.Now().Add(time.Hour),
+			Filter:        filter,
+		}
+
+		// newStore creates a store with the same pieces in a new directory.
+		newStore := func(name string) (*pieces.Store, *countingBlobs) {
+			dir, err := filestore.NewDir(zaptest.NewLogger(t), ctx.Dir(name, "pieces"))
+			require.NoError(t, err)
+			blobs := &countingBlobs{
+				Blobs:  filestore.New(zaptest.NewLogger(t), dir, filestore.DefaultConfig),
+				walked: map[storj.PieceID]int{},
+			}
+
+			store := pieces.NewStore(zaptest.NewLogger(t), blobs, nil, db.PieceExpirationDB(), nil, pieces.DefaultConfig)
+			for _, id := range pieceIDs {
+				w, err := store.Writer(ctx, satellite.ID, id)
+				require.NoError(t, err)
+				_, err = w.Write(testrand.Bytes(100 * memory.B))
+				require.NoError(t, err)
+				require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+			}
+			return store, blobs
+		}
+
+		// run processes the queued requests, until the run is interrupted or
+		// the queue is empty.
+		run := func(service *retain.Service, blobs *countingBlobs) error {
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			blobs.cancel = cancel
+
+			var group errgroup.Group
+			group.Go(func() error {
+				return service.Run(runCtx)
+			})
+			_ = service.WaitIdle(runCtx)
+			cancel()
+			err := group.Wait()
+			require.NoError(t, service.Close())
+			return err
+		}
+
+		config := retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+		}
+
+		// the reference run isn't interrupted.
+		uninterrupted, uninterruptedBlobs := newStore("uninterrupted")
+		defer ctx.Check(uninterruptedBlobs.Close)
+		config.Path = ctx.Dir("uninterrupted", "retain")
+		service := retain.NewService(zaptest.NewLogger(t), uninterrupted, nil, config)
+		require.True(t, service.Queue(request))
+		require.True(t, errs2.IsCanceled(run(service, uninterruptedBlobs)))
+
+		// the other run is interrupted twice between prefixes.
+		interrupted, blobs := newStore("interrupted")
+		defer ctx.Check(blobs.Close)
+		config.Path = ctx.Dir("interrupted", "retain")
+		service = retain.NewService(zaptest.NewLogger(t), interrupted, nil, config)
+		require.True(t, service.Queue(request))
+
+		blobs.interruptAt = 3
+		require.True(t, errs2.IsCanceled(run(service, blobs)))
+		checkpoints, err := filepath.Glob(filepath.Join(config.Path, "*.checkpoint"))
+		require.NoError(t, err)
+		require.Len(t, checkpoints, 1)
+		require.Equal(t, 3, blobs.prefixes)
+
+		blobs.interruptAt = 6
+		service = retain.NewService(zaptest.NewLogger(t), interrupted, nil, config)
+		require.True(t, errs2.IsCanceled(run(service, blobs)))
+		require.Equal(t, 6, blobs.prefixes)
+
+		blobs.interruptAt = 0
+		service = retain.NewService(zaptest.NewLogger(t), interrupted, nil, config)
+		require.True(t, errs2.IsCanceled(run(service, blobs)))
+
+		// no piece was evaluated twice.
+		require.Len(t, blobs.walked, numPieces)
+		for pieceID, count := range blobs.walked {
+			require.Equal(t, 1, count, pieceID)
+		}
+		require.Equal(t, uninterruptedBlobs.prefixes, blobs.prefixes)
+
+		// and the same pieces were kept as without the interruptions.
+		expected, err := getAllPieceIDs(ctx, uninterrupted, satellite.ID)
+		require.NoError(t, err)
+		remaining, err := getAllPieceIDs(ctx, interrupted, satellite.ID)
+		require.NoError(t, err)
+		require.ElementsMatch(t, kept, expected)
+		require.ElementsMatch(t, expected, remaining)
+
+		entries, err := ioutil.ReadDir(config.Path)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			require.NotEqual(t, ".checkpoint", filepath.Ext(entry.Name()))
+			require.NotEqual(t, ".retain", filepath.Ext(entry.Name()))
+		}
+	})
+}
+
+func TestRetainResumeReplaced(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+
+		dir, err := filestore.NewDir(zaptest.NewLogger(t), ctx.Dir("pieces"))
+		require.NoError(t, err)
+		blobs := &countingBlobs{
+			Blobs:  filestore.New(zaptest.NewLogger(t), dir, filestore.DefaultConfig),
+			walked: map[storj.PieceID]int{},
+		}
+		defer ctx.Check(blobs.Close)
+
+		store := pieces.NewStore(zaptest.NewLogger(t), blobs, nil, db.PieceExpirationDB(), nil, pieces.DefaultConfig)
+		const numPieces = 100
+		pieceIDs := generateTestIDs(numPieces)
+		filter := bloomfilter.NewOptimal(numPieces, 0.000000001)
+		for _, id := range pieceIDs {
+			filter.Add(id)
+			w, err := store.Writer(ctx, satellite.ID, id)
+			require.NoError(t, err)
+			_, err = w.Write(testrand.Bytes(100 * memory.B))
+			require.NoError(t, err)
+			require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+		}
+
+		config := retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+			Path:        ctx.Dir("retain"),
+		}
+		created := time.Now().Add(time.Hour)
+
+		// interrupt the request after a few prefixes.
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: created,
+			Filter:        filter,
+		}))
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		blobs.cancel = cancel
+		blobs.interruptAt = 3
+		err = service.Run(runCtx)
+		require.True(t, errs2.IsCanceled(err))
+		require.NoError(t, service.Close())
+
+		// a newer request replaces the interrupted one after the restart, so
+		// its checkpoint must not apply.
+		blobs.interruptAt = 0
+		blobs.walked = map[storj.PieceID]int{}
+		service = retain.NewService(zaptest.NewLogger(t), store, nil, config)
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: created.Add(time.Minute),
+			Filter:        filter,
+		}))
+
+		runCtx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+		require.NoError(t, service.WaitIdle(ctx))
+		cancel()
+		require.True(t, errs2.IsCanceled(group.Wait()))
+
+		require.Len(t, blobs.walked, numPieces)
+
+		checkpoints, err := filepath.Glob(filepath.Join(config.Path, "*.checkpoint"))
+		require.NoError(t, err)
+		require.Empty(t, checkpoints)
+	})
+}
+
+// countingBlobs counts how often every piece is walked by prefix, and cancels
+// the walk before the prefix at interruptAt, when it is set.
+type countingBlobs struct {
+	storage.Blobs
+
+	mu          sync.Mutex
+	walked      map[storj.PieceID]int
+	prefixes    int
+	interruptAt int
+	cancel      func()
+}
+
+func (blobs *countingBlobs) WalkNamespacePrefix(ctx context.Context, namespace []byte, keyPrefix string, walkFunc func(storage.BlobInfo) error) error {
+	blobs.mu.Lock()
+	if blobs.interruptAt > 0 && blobs.prefixes == blobs.interruptAt {
+		blobs.mu.Unlock()
+		blobs.cancel()
+		return ctx.Err()
 	}
-	return ids
+	blobs.prefixes++
+	blobs.mu.Unlock()
+
+	return blobs.Blobs.WalkNamespacePrefix(ctx, namespace, keyPrefix, func(info storage.BlobInfo) error {
+		pieceID, err := storj.PieceIDFromBytes(info.BlobRef().Key)
+		if err != nil {
+			return err
+		}
+		blobs.mu.Lock()
+		blobs.walked[pieceID]++
+		blobs.mu.Unlock()
+		return walkFunc(info)
+	})
+}
+
+// syntheticBlobs is a blob store that generates the V1 blobs of every key
+// prefix while they are walked, so that no blobs are held in memory.
+type syntheticBlobs struct {
+	storage.Blobs
+
+	prefixes        []string
+	piecesPerPrefix int
+	modTime         time.Time
+
+	// beforeChunk is called before the blobs of the prefix at index are walked.
+	beforeChunk func(index int)
+	walking     int32
+}
+
+func (blobs *syntheticBlobs) ListKeyPrefixes(ctx context.Context, namespace []byte) ([]string, error) {
+	return blobs.prefixes, nil
+}
+
+func (blobs *syntheticBlobs) WalkNamespacePrefix(ctx context.Context, namespace []byte, keyPrefix string, walkFunc func(storage.BlobInfo) error) error {
+	if atomic.AddInt32(&blobs.walking, 1) != 1 {
+		return errs.New("concurrent walk of %q", keyPrefix)
+	}
+	defer atomic.AddInt32(&blobs.walking, -1)
+
+	index := sort.SearchStrings(blobs.prefixes, keyPrefix)
+	if blobs.beforeChunk != nil {
+		blobs.beforeChunk(index)
+	}
+
+	for i := 0; i < blobs.piecesPerPrefix; i++ {
+		pieceID := testrand.PieceID()
+		err := walkFunc(syntheticBlobInfo{
+			ref:     storage.BlobRef{Namespace: namespace, Key: pieceID.Bytes()},
+			modTime: blobs.modTime,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syntheticBlobInfo is a V1 blob of syntheticBlobs.
+type syntheticBlobInfo struct {
+	ref     storage.BlobRef
+	modTime time.Time
+}
+
+func (info syntheticBlobInfo) BlobRef() storage.BlobRef { return info.ref }
+
+func (info syntheticBlobInfo) StorageFormatVersion() storage.FormatVersion {
+	return filestore.FormatV1
+}
+
+func (info syntheticBlobInfo) FullPath(ctx context.Context) (string, error) {
+	return "", errs.New("synthetic blobs have no path")
+}
+
+func (info syntheticBlobInfo) Stat(ctx context.Context) (os.FileInfo, error) {
+	return syntheticFileInfo(info), nil
+}
+
+// syntheticFileInfo describes a syntheticBlobInfo.
+type syntheticFileInfo struct {
+	ref     storage.BlobRef
+	modTime time.Time
+}
+
+func (info syntheticFileInfo) Name() string       { return string(info.ref.Key) }
+func (info syntheticFileInfo) Size() int64        { return 0 }
+func (info syntheticFileInfo) Mode() os.FileMode  { return 0600 }
+func (info syntheticFileInfo) ModTime() time.Time { return info.modTime }
+func (info syntheticFileInfo) IsDir() bool        { return false }
+func (info syntheticFileInfo) Sys() interface{}   { return nil }
+
+func TestRetainDeleteRatio(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		large := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+		small := testidentity.MustPregeneratedSignedIdentity(1, storj.LatestIDVersion())
+
+		const numPieces = 100
+		const numKept = 10
+		const sample = 50
+		pieceIDs := map[storj.NodeID][]storj.PieceID{
+			large.ID: generateTestIDs(numPieces),
+			// the ratio of namespaces smaller than the sample is not capped.
+			small.ID: generateTestIDs(sample - 1),
+		}
+		for satellite, ids := range pieceIDs {
+			for _, id := range ids {
+				w, err := testStore.WriterForFormatVersion(ctx, satellite, id, filestore.FormatV1)
+				require.NoError(t, err)
+				_, err = w.Write(testrand.Bytes(100 * memory.B))
+				require.NoError(t, err)
+				require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: time.Now()}))
+			}
+		}
+
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
+			Status:            retain.Enabled,
+			Concurrency:       2,
+			MaxDeleteRatio:    0.5,
+			DeleteRatioSample: sample,
+		})
+
+		summaries := make(chan retain.Summary, 3)
+		unsubscribe := service.Subscribe(func(summary retain.Summary) {
+			summaries <- summary
+		})
+		defer unsubscribe()
+
+		// the filter of the large namespace condemns 90% of the pieces.
+		keep := bloomfilter.NewOptimal(numPieces, 0.000000001)
+		for _, id := range pieceIDs[large.ID][:numKept] {
+			keep.Add(id)
+		}
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   large.ID,
+			CreatedBefore: time.Now().Add(time.Hour),
+			Filter:        keep,
+		}))
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   small.ID,
+			CreatedBefore: time.Now().Add(time.Hour),
+			Filter:        bloomfilter.NewOptimal(numPieces, 0.000000001),
+		}))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+
+		results := map[storj.NodeID]retain.Summary{}
+		for i := 0; i < 2; i++ {
+			summary := <-summaries
+			results[summary.SatelliteID] = summary
+		}
+
+		// the large request is halted without trashing anything.
+		require.True(t, retain.ErrDeleteRatioExceeded.Has(results[large.ID].Err))
+		require.Zero(t, results[large.ID].PiecesDeleted)
+		remaining, err := getAllPieceIDs(ctx, store, large.ID)
+		require.NoError(t, err)
+		require.Len(t, remaining, numPieces)
+
+		progress := service.Progress()
+		require.Len(t, progress, 1)
+		require.Equal(t, large.ID, progress[0].SatelliteID)
+		require.True(t, progress[0].Halted)
+
+		// the small request is processed as usual.
+		require.NoError(t, results[small.ID].Err)
+		remaining, err = getAllPieceIDs(ctx, store, small.ID)
+		require.NoError(t, err)
+		require.Empty(t, remaining)
+
+		// the operator overrides the cap.
+		require.Zero(t, service.Override(small.ID))
+		require.Equal(t, 1, service.Override(large.ID))
+
+		summary := <-summaries
+		require.Equal(t, large.ID, summary.SatelliteID)
+		require.NoError(t, summary.Err)
+		require.EqualValues(t, numPieces-numKept, summary.PiecesDeleted)
+		require.Empty(t, service.Progress())
+
+		remaining, err = getAllPieceIDs(ctx, store, large.ID)
+		require.NoError(t, err)
+		require.ElementsMatch(t, pieceIDs[large.ID][:numKept], remaining)
+
+		cancel()
+		err = group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+	})
+}
+
+func TestRetainGuardWindow(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+		createdBefore := time.Now().Truncate(time.Second)
+
+		// the modification times around the boundaries of the guard window,
+		// which starts from the creation date less the time skew, and of the
+		// time skew.
+		modTimes := []time.Time{
+			createdBefore.Add(-25*time.Hour - time.Minute),
+			createdBefore.Add(-25 * time.Hour),
+			createdBefore.Add(-25*time.Hour + time.Minute),
+			createdBefore.Add(-time.Hour - time.Minute),
+			createdBefore.Add(-time.Hour + time.Minute),
+		}
+		pieceIDs := writeModifiedPieces(ctx, t, db, testStore, satellite.ID, modTimes)
+
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, retain.Config{
+			Status:      retain.Enabled,
+			Concurrency: 1,
+			MaxTimeSkew: time.Hour,
+			GuardWindow: 24 * time.Hour,
+		})
+
+		summaries := make(chan retain.Summary, 1)
+		unsubscribe := service.Subscribe(func(summary retain.Summary) {
+			summaries <- summary
+		})
+		defer unsubscribe()
+
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: createdBefore,
+			Filter:        bloomfilter.NewOptimal(len(pieceIDs), 0.000000001),
+		}))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+
+		// the pieces before the time skew are trashed, and those from the start
+		// of the guard window on are counted as guarded.
+		summary := <-summaries
+		require.NoError(t, summary.Err)
+		require.EqualValues(t, 4, summary.PiecesDeleted)
+		require.EqualValues(t, 3, summary.PiecesGuarded)
+
+		remaining, err := getAllPieceIDs(ctx, store, satellite.ID)
+		require.NoError(t, err)
+		require.Equal(t, pieceIDs[4:], remaining)
+
+		// the guarded pieces can be recovered from the trash.
+		require.NoError(t, store.RestoreTrash(ctx, satellite.ID))
+		remaining, err = getAllPieceIDs(ctx, store, satellite.ID)
+		require.NoError(t, err)
+		require.ElementsMatch(t, pieceIDs, remaining)
+
+		cancel()
+		err = group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+	})
+}
+
+func TestRetainGuardWindowDefaults(t *testing.T) {
+	storagenodedbtest.Run(t, func(ctx *testcontext.Context, t *testing.T, db storagenode.DB) {
+		store := pieces.NewStore(zaptest.NewLogger(t), db.Pieces(), db.V0PieceInfo(), db.PieceExpirationDB(), db.PieceSpaceUsedDB(), pieces.DefaultConfig)
+		testStore := pieces.StoreForTest{Store: store}
+
+		var config retain.Config
+		cfgstruct.Bind(&pflag.FlagSet{}, &config, cfgstruct.UseReleaseDefaults(), cfgstruct.ConfDir(ctx.Dir("config")))
+
+		satellite := testidentity.MustPregeneratedSignedIdentity(0, storj.LatestIDVersion())
+		createdBefore := time.Now().Truncate(time.Second)
+		cutoff := createdBefore.Add(-config.MaxTimeSkew)
+
+		// with the default config, condemned pieces modified within the guard
+		// window before the cutoff are guarded.
+		modTimes := []time.Time{
+			cutoff.Add(-config.GuardWindow - time.Hour),
+			cutoff.Add(-time.Hour),
+			createdBefore.Add(-time.Hour),
+		}
+		pieceIDs := writeModifiedPieces(ctx, t, db, testStore, satellite.ID, modTimes)
+
+		service := retain.NewService(zaptest.NewLogger(t), store, nil, config)
+
+		summaries := make(chan retain.Summary, 1)
+		unsubscribe := service.Subscribe(func(summary retain.Summary) {
+			summaries <- summary
+		})
+		defer unsubscribe()
+
+		require.True(t, service.Queue(retain.Request{
+			SatelliteID:   satellite.ID,
+			CreatedBefore: createdBefore,
+			Filter:        bloomfilter.NewOptimal(len(pieceIDs), 0.000000001),
+		}))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var group errgroup.Group
+		group.Go(func() error {
+			return service.Run(runCtx)
+		})
+
+		summary := <-summaries
+		require.NoError(t, summary.Err)
+		require.EqualValues(t, 2, summary.PiecesDeleted)
+		require.EqualValues(t, 1, summary.PiecesGuarded)
+
+		cancel()
+		err := group.Wait()
+		require.True(t, errs2.IsCanceled(err))
+	})
+}
+
+// writeModifiedPieces writes a piece of the satellite for every modification
+// time, and returns their IDs.
+func writeModifiedPieces(ctx *testcontext.Context, t *testing.T, db storagenode.DB, testStore pieces.StoreForTest, satelliteID storj.NodeID, modTimes []time.Time) []storj.PieceID {
+	pieceIDs := generateTestIDs(len(modTimes))
+	for i, id := range pieceIDs {
+		w, err := testStore.WriterForFormatVersion(ctx, satelliteID, id, filestore.FormatV1)
+		require.NoError(t, err)
+		_, err = w.Write(testrand.Bytes(100 * memory.B))
+		require.NoError(t, err)
+		require.NoError(t, w.Commit(ctx, &pb.PieceHeader{CreationTime: modTimes[i]}))
+
+		info, err := db.Pieces().Stat(ctx, storage.BlobRef{Namespace: satelliteID.Bytes(), Key: id.Bytes()})
+		require.NoError(t, err)
+		path, err := info.FullPath(ctx)
+		require.NoError(t, err)
+		require.NoError(t, os.Chtimes(path, modTimes[i], modTimes[i]))
+	}
+	return pieceIDs
 }
@@ -0,0 +1,83 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retain
+
+import (
+	"time"
+
+	"storj.io/common/storj"
+)
+
+// scheduler decides which of the queued requests is processed next. Requests
+// are processed oldest filter first across all satellites, but a request that
+// waited for longer than maxWait goes first, so that a satellite that sends
+// newer filters than the others is not starved. Requests whose filter would be
+// older than maxAge when they are started are dropped instead of being applied
+// dangerously late.
+type scheduler struct {
+	maxWait time.Duration
+	maxAge  time.Duration
+}
+
+// expired returns the keys of the queued requests whose filter would be older
+// than the maximum age by their projected start. busy holds the projected
+// completion of the active request of every satellite that has one, which may
+// be zero when it can't be estimated yet. Requests of other satellites are
+// projected to start right away.
+func (sched scheduler) expired(now time.Time, queued map[queueKey]Request, busy map[storj.NodeID]time.Time) []queueKey {
+	if sched.maxAge <= 0 {
+		return nil
+	}
+
+	var expired []queueKey
+	for key, request := range queued {
+		start := now
+		if completion := busy[key.satelliteID]; completion.After(start) {
+			start = completion
+		}
+		if start.Sub(request.CreatedBefore) > sched.maxAge {
+			expired = append(expired, key)
+		}
+	}
+	return expired
+}
+
+// next returns the key of the queued request that is processed next. Requests
+// of satellites in busy are skipped, as the requests of a satellite are never
+// processed concurrently.
+func (sched scheduler) next(now time.Time, queued map[queueKey]Request, busy map[storj.NodeID]time.Time) (_ queueKey, ok bool) {
+	var next queueKey
+	var nextStarving bool
+	for key, request := range queued {
+		if _, ok := busy[key.satelliteID]; ok {
+			continue
+		}
+		starving := sched.maxWait > 0 && now.Sub(request.received) >= sched.maxWait
+		if !ok || sched.before(request, starving, queued[next], nextStarving) {
+			next, nextStarving, ok = key, starving, true
+		}
+	}
+	return next, ok
+}
+
+// before returns whether the request a is processed before the request b.
+// Starving requests go first in the order they were received, all others
+// by the creation date of their filter. Ties are broken by the satellite and
+// the range, so that the order is deterministic.
+func (sched scheduler) before(a Request, aStarving bool, b Request, bStarving bool) bool {
+	switch {
+	case aStarving != bStarving:
+		return aStarving
+	case aStarving && !a.received.Equal(b.received):
+		return a.received.Before(b.received)
+	case !a.CreatedBefore.Equal(b.CreatedBefore):
+		return a.CreatedBefore.Before(b.CreatedBefore)
+	case a.SatelliteID != b.SatelliteID:
+		return a.SatelliteID.Less(b.SatelliteID)
+	case a.Range.Bits != b.Range.Bits:
+		return a.Range.Bits < b.Range.Bits
+	default:
+		return a.Range.Index < b.Range.Index
+	}
+}
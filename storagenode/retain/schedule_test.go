@@ -0,0 +1,115 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/storj"
+	"storj.io/common/testrand"
+	"storj.io/storj/private/retainfilter"
+)
+
+func TestSchedulerNext(t *testing.T) {
+	now := time.Now()
+	a, b, c := testrand.NodeID(), testrand.NodeID(), testrand.NodeID()
+
+	queue := func(requests ...Request) map[queueKey]Request {
+		queued := make(map[queueKey]Request)
+		for _, request := range requests {
+			queued[queueKey{satelliteID: request.SatelliteID, pieceRange: request.Range}] = request
+		}
+		return queued
+	}
+	// order returns the satellites in the order their requests are scheduled,
+	// as if every request finished before the next one is picked.
+	order := func(sched scheduler, queued map[queueKey]Request, busy map[storj.NodeID]time.Time) []storj.NodeID {
+		var order []storj.NodeID
+		for {
+			key, ok := sched.next(now, queued, busy)
+			if !ok {
+				return order
+			}
+			order = append(order, key.satelliteID)
+			delete(queued, key)
+		}
+	}
+
+	t.Run("oldest filter first", func(t *testing.T) {
+		queued := queue(
+			Request{SatelliteID: a, CreatedBefore: now.Add(-1 * time.Hour), received: now.Add(-3 * time.Hour)},
+			Request{SatelliteID: b, CreatedBefore: now.Add(-3 * time.Hour), received: now.Add(-1 * time.Hour)},
+			Request{SatelliteID: c, CreatedBefore: now.Add(-2 * time.Hour), received: now.Add(-2 * time.Hour)},
+		)
+		require.Equal(t, []storj.NodeID{b, c, a}, order(scheduler{}, queued, nil))
+	})
+
+	t.Run("busy satellites are skipped", func(t *testing.T) {
+		queued := queue(
+			Request{SatelliteID: a, CreatedBefore: now.Add(-3 * time.Hour)},
+			Request{SatelliteID: b, CreatedBefore: now.Add(-2 * time.Hour)},
+			Request{SatelliteID: c, CreatedBefore: now.Add(-1 * time.Hour)},
+		)
+		busy := map[storj.NodeID]time.Time{a: {}, c: now.Add(time.Hour)}
+		require.Equal(t, []storj.NodeID{b}, order(scheduler{}, queued, busy))
+
+		busy = map[storj.NodeID]time.Time{a: {}, b: {}, c: {}}
+		_, ok := scheduler{}.next(now, queued, busy)
+		require.False(t, ok)
+	})
+
+	t.Run("starving requests first", func(t *testing.T) {
+		sched := scheduler{maxWait: 12 * time.Hour}
+		queued := queue(
+			Request{SatelliteID: a, CreatedBefore: now.Add(-48 * time.Hour), received: now.Add(-time.Hour)},
+			Request{SatelliteID: b, CreatedBefore: now.Add(-1 * time.Hour), received: now.Add(-13 * time.Hour)},
+			Request{SatelliteID: c, CreatedBefore: now.Add(-2 * time.Hour), received: now.Add(-24 * time.Hour)},
+		)
+		// the request that waited longest goes first, then the other starving
+		// one, and only then the oldest filter.
+		require.Equal(t, []storj.NodeID{c, b, a}, order(sched, queued, nil))
+	})
+
+	t.Run("ranges", func(t *testing.T) {
+		queued := queue(
+			Request{SatelliteID: a, Range: retainfilter.Range{Bits: 1, Index: 1}, CreatedBefore: now},
+			Request{SatelliteID: a, Range: retainfilter.Range{Bits: 1, Index: 0}, CreatedBefore: now},
+		)
+		key, ok := scheduler{}.next(now, queued, nil)
+		require.True(t, ok)
+		require.Equal(t, retainfilter.Range{Bits: 1, Index: 0}, key.pieceRange)
+	})
+}
+
+func TestSchedulerExpired(t *testing.T) {
+	now := time.Now()
+	a, b := testrand.NodeID(), testrand.NodeID()
+
+	fresh := queueKey{satelliteID: a, pieceRange: retainfilter.Range{Bits: 1, Index: 0}}
+	old := queueKey{satelliteID: a, pieceRange: retainfilter.Range{Bits: 1, Index: 1}}
+	other := queueKey{satelliteID: b}
+	queued := map[queueKey]Request{
+		fresh: {SatelliteID: a, Range: fresh.pieceRange, CreatedBefore: now.Add(-1 * time.Hour)},
+		old:   {SatelliteID: a, Range: old.pieceRange, CreatedBefore: now.Add(-47 * time.Hour)},
+		other: {SatelliteID: b, CreatedBefore: now.Add(-47 * time.Hour)},
+	}
+
+	// without a maximum age nothing expires.
+	require.Empty(t, scheduler{}.expired(now, queued, map[storj.NodeID]time.Time{a: now.Add(100 * time.Hour)}))
+
+	sched := scheduler{maxAge: 48 * time.Hour}
+	require.Empty(t, sched.expired(now, queued, nil))
+
+	// requests of a satellite whose active request takes long start late.
+	require.Equal(t, []queueKey{old}, sched.expired(now, queued, map[storj.NodeID]time.Time{a: now.Add(2 * time.Hour)}))
+
+	// requests of satellites whose completion can't be estimated start now.
+	require.Empty(t, sched.expired(now, queued, map[storj.NodeID]time.Time{a: {}, b: {}}))
+
+	// time passing expires requests of all satellites.
+	require.ElementsMatch(t, []queueKey{old, other}, sched.expired(now.Add(2*time.Hour), queued, nil))
+}
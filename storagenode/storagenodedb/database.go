@@ -364,8 +364,9 @@ func (db *DB) preflight(ctx context.Context, dbName string, dbContainer DBContai
 	if err != nil {
 		return ErrPreflight.New("database %q: schema check failed: %v", dbName, err)
 	}
-	// we don't care about changes in versions table
+	// we don't care about changes in versions tables
 	schema.DropTable("versions")
+	schema.DropTable("versions_history")
 	// if there was a previous pre-flight failure, test_table might still be in the schema
 	schema.DropTable("test_table")
 
@@ -79,8 +79,9 @@ func getSchemas(ctx context.Context, rawDBs map[string]storagenodedb.DBContainer
 			return nil, err
 		}
 
-		// we don't care changes in versions table
+		// we don't care changes in versions tables
 		schema.DropTable("versions")
+		schema.DropTable("versions_history")
 
 		schemas[dbName] = schema
 	}
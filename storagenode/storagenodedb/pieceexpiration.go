@@ -56,6 +56,33 @@ func (db *pieceExpirationDB) GetExpired(ctx context.Context, expiresBefore time.
 	return expiredPieceIDs, rows.Err()
 }
 
+// GetExpiringBySatellite gets the IDs of the pieces of the satellite that are not in the trash
+// and expire or have expired before the given time.
+func (db *pieceExpirationDB) GetExpiringBySatellite(ctx context.Context, satelliteID storj.NodeID, expiresBefore time.Time) (pieceIDs []storj.PieceID, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT piece_id
+			FROM piece_expirations
+			WHERE satellite_id = ?
+				AND piece_expiration < ?
+				AND trash = 0
+	`, satelliteID, expiresBefore.UTC())
+	if err != nil {
+		return nil, ErrPieceExpiration.Wrap(err)
+	}
+	defer func() { err = errs.Combine(err, rows.Close()) }()
+
+	for rows.Next() {
+		var pieceID storj.PieceID
+		if err := rows.Scan(&pieceID); err != nil {
+			return nil, ErrPieceExpiration.Wrap(err)
+		}
+		pieceIDs = append(pieceIDs, pieceID)
+	}
+	return pieceIDs, ErrPieceExpiration.Wrap(rows.Err())
+}
+
 // SetExpiration sets an expiration time for the given piece ID on the given satellite.
 func (db *pieceExpirationDB) SetExpiration(ctx context.Context, satellite storj.NodeID, pieceID storj.PieceID, expiresAt time.Time) (err error) {
 	defer mon.Task()(&ctx)(&err)
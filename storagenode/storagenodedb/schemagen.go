@@ -79,8 +79,9 @@ func runSchemaGen(ctx context.Context, log *zap.Logger) (err error) {
 		if err != nil {
 			return errs.New("Error getting schema for db: %+w", err)
 		}
-		// we don't care about changes in versions table
+		// we don't care about changes in versions tables
 		schema.DropTable("versions")
+		schema.DropTable("versions_history")
 		// If tables and indexes of the schema are empty, set to nil
 		// to help with comparison to the snapshot.
 		if len(schema.Tables) == 0 {